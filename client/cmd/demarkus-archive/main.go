@@ -0,0 +1,154 @@
+// Command demarkus-archive snapshots a Mark Protocol site into a single
+// archive file holding every version of every document, and verifies such
+// archives offline.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/latebit/demarkus/client/internal/archive"
+	"github.com/latebit/demarkus/client/internal/fetch"
+)
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "verify" {
+		verifyMain(os.Args[2:])
+		return
+	}
+	snapshotMain()
+}
+
+func snapshotMain() {
+	insecure := flag.Bool("insecure", false, "skip TLS certificate verification")
+	output := flag.String("o", "", "archive file to write (default <host>-<date>.markarchive)")
+	quiet := flag.Bool("q", false, "do not print each document as it is archived")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: demarkus-archive [-insecure] [-o FILE] [-q] mark://host:port[/dir/]\n")
+		fmt.Fprintf(os.Stderr, "       demarkus-archive verify FILE\n\n")
+		fmt.Fprintf(os.Stderr, "Snapshot every version of every document under a directory (default /)\n")
+		fmt.Fprintf(os.Stderr, "into one file, with the hashes needed to verify it offline.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	host, root, err := fetch.ParseMarkURL(flag.Arg(0))
+	if err != nil {
+		log.Fatalf("invalid URL: %v", err)
+	}
+	if *output == "" {
+		*output = defaultOutput(host, time.Now())
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// No cache: the archive must reflect the server, not a local copy.
+	client := fetch.NewClient(fetch.Options{Insecure: *insecure, OnRateLimited: func(host string, wait time.Duration) {
+		fmt.Fprintf(os.Stderr, "%s is busy, retrying in %s\n", host, wait)
+	}})
+	defer client.Close()
+
+	var progress func(string)
+	if !*quiet {
+		progress = func(path string) { fmt.Fprintln(os.Stderr, path) }
+	}
+	a, err := archive.Snapshot(ctx, client, host, root, progress)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := archive.Save(*output, a); err != nil {
+		log.Fatalf("write archive: %v", err)
+	}
+
+	versions := 0
+	for _, d := range a.Documents {
+		versions += len(d.Versions)
+	}
+	fmt.Printf("Archived %d document(s), %d version(s) from %s%s to %s\n", len(a.Documents), versions, host, a.Root, *output)
+	for _, s := range a.Skipped {
+		fmt.Printf("  skipped %s: %s\n", s.Path, s.Reason)
+	}
+}
+
+// defaultOutput names the archive of host taken at t.
+func defaultOutput(host string, t time.Time) string {
+	return strings.ReplaceAll(host, ":", "_") + "-" + t.UTC().Format("20060102") + ".markarchive"
+}
+
+func verifyMain(args []string) {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: demarkus-archive verify FILE\n\n")
+		fmt.Fprintf(os.Stderr, "Check an archive offline: its digest, and every document's hash chain.\n")
+		fmt.Fprintf(os.Stderr, "Exits non-zero if any check fails.\n")
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	a, err := archive.Load(fs.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	report, err := archive.Verify(a)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("Archive of %s%s taken %s\n\n", a.Host, a.Root, a.Created.Format(time.RFC3339))
+	failed := printReport(os.Stdout, a, report)
+	digest := "ok"
+	if !report.DigestOK {
+		digest = "MISMATCH, the file was modified after it was written"
+	}
+	fmt.Printf("\n%d document(s), %d failed. Digest: %s\n", len(report.Documents), failed, digest)
+	if len(a.Skipped) > 0 {
+		fmt.Printf("%d path(s) were skipped when the archive was taken.\n", len(a.Skipped))
+	}
+	if !report.OK() {
+		os.Exit(1)
+	}
+}
+
+// printReport writes one PASS/FAIL row per document and returns the number
+// of failures. A document whose chain verifies but that the server claimed
+// was broken, or the reverse, is noted.
+func printReport(w io.Writer, a *archive.Archive, report archive.Report) int {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DOCUMENT\tVERSIONS\tRESULT\tDETAIL")
+	failed := 0
+	for i, d := range report.Documents {
+		status := "PASS"
+		var problems []string
+		for _, v := range d.Versions {
+			for _, p := range v.Problems {
+				problems = append(problems, fmt.Sprintf("v%d: %s", v.Version, p))
+			}
+		}
+		if !d.OK() {
+			status = "FAIL"
+			failed++
+		}
+		if claim := a.Documents[i].ChainValid; claim != "" && claim != fmt.Sprint(d.OK()) {
+			problems = append(problems, "server claimed chain-valid: "+claim)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", d.Path, len(d.Versions), status, strings.Join(problems, "; "))
+	}
+	_ = tw.Flush()
+	return failed
+}
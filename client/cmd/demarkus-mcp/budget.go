@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/latebit/demarkus/client/internal/fetch"
+)
+
+// budget caps what one MCP session may do, so that an errant agent cannot
+// hammer a public server or mass-publish. A zero limit means no limit.
+type budget struct {
+	MaxFetches   int   // FETCH, LIST and VERSIONS requests
+	MaxPublishes int   // PUBLISH, APPEND and ARCHIVE requests
+	MaxBytes     int64 // body bytes received plus body bytes sent
+}
+
+// unlimited reports whether the budget sets no limit at all.
+func (b budget) unlimited() bool {
+	return b.MaxFetches <= 0 && b.MaxPublishes <= 0 && b.MaxBytes <= 0
+}
+
+// budgetedClient is a markClient that refuses requests once the session's
+// budget is spent. Every tool reaches the server through it, so the
+// requests a tool makes on the agent's behalf, such as a graph crawl,
+// count too. A response can take the byte count past MaxBytes, since its
+// size is not known until it arrives; the next request is then refused.
+type budgetedClient struct {
+	markClient
+	limits budget
+
+	mu        sync.Mutex
+	fetches   int
+	publishes int
+	bytes     int64
+}
+
+// withBudget wraps c in limits, or returns it unchanged when there are none.
+func withBudget(c markClient, limits budget) markClient {
+	if limits.unlimited() {
+		return c
+	}
+	return &budgetedClient{markClient: c, limits: limits}
+}
+
+// reserve counts n requests of the given kind carrying sent body bytes,
+// or explains which limit they would exceed.
+func (c *budgetedClient) reserve(publish bool, n, sent int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if publish {
+		if c.limits.MaxPublishes > 0 && c.publishes+n > c.limits.MaxPublishes {
+			return fmt.Errorf("session budget exhausted: %d of %d publishes used (-max-publishes); no further writes are allowed in this session", c.publishes, c.limits.MaxPublishes)
+		}
+	} else if c.limits.MaxFetches > 0 && c.fetches+n > c.limits.MaxFetches {
+		return fmt.Errorf("session budget exhausted: %d of %d fetches used (-max-fetches); no further reads are allowed in this session", c.fetches, c.limits.MaxFetches)
+	}
+	if c.limits.MaxBytes > 0 && c.bytes+int64(sent) > c.limits.MaxBytes {
+		return fmt.Errorf("session budget exhausted: %d of %d bytes used (-max-bytes); no further requests are allowed in this session", c.bytes, c.limits.MaxBytes)
+	}
+	if publish {
+		c.publishes += n
+	} else {
+		c.fetches += n
+	}
+	c.bytes += int64(sent)
+	return nil
+}
+
+// received counts the body of a response against MaxBytes.
+func (c *budgetedClient) received(r fetch.Result) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.bytes += int64(len(r.Response.Body))
+}
+
+func (c *budgetedClient) read(do func() (fetch.Result, error)) (fetch.Result, error) {
+	if err := c.reserve(false, 1, 0); err != nil {
+		return fetch.Result{}, err
+	}
+	r, err := do()
+	c.received(r)
+	return r, err
+}
+
+func (c *budgetedClient) write(body string, do func() (fetch.Result, error)) (fetch.Result, error) {
+	if err := c.reserve(true, 1, len(body)); err != nil {
+		return fetch.Result{}, err
+	}
+	r, err := do()
+	c.received(r)
+	return r, err
+}
+
+func (c *budgetedClient) Fetch(host, path string) (fetch.Result, error) {
+	return c.read(func() (fetch.Result, error) { return c.markClient.Fetch(host, path) })
+}
+
+// FetchMany counts as a fetch per document, all or none.
+func (c *budgetedClient) FetchMany(host string, paths []string) ([]fetch.Result, error) {
+	if err := c.reserve(false, len(paths), 0); err != nil {
+		return nil, err
+	}
+	results, err := c.markClient.FetchMany(host, paths)
+	for _, r := range results {
+		c.received(r)
+	}
+	return results, err
+}
+
+func (c *budgetedClient) List(host, path string) (fetch.Result, error) {
+	return c.read(func() (fetch.Result, error) { return c.markClient.List(host, path) })
+}
+
+func (c *budgetedClient) ListEntries(host, path string) (fetch.Result, error) {
+	return c.read(func() (fetch.Result, error) { return c.markClient.ListEntries(host, path) })
+}
+
+func (c *budgetedClient) Versions(host, path string) (fetch.Result, error) {
+	return c.read(func() (fetch.Result, error) { return c.markClient.Versions(host, path) })
+}
+
+func (c *budgetedClient) Publish(host, path, body, token string, expectedVersion int, meta map[string]string) (fetch.Result, error) {
+	return c.write(body, func() (fetch.Result, error) {
+		return c.markClient.Publish(host, path, body, token, expectedVersion, meta)
+	})
+}
+
+func (c *budgetedClient) Append(host, path, body, token string, expectedVersion int, meta map[string]string) (fetch.Result, error) {
+	return c.write(body, func() (fetch.Result, error) {
+		return c.markClient.Append(host, path, body, token, expectedVersion, meta)
+	})
+}
+
+func (c *budgetedClient) Archive(host, path, token string) (fetch.Result, error) {
+	return c.write("", func() (fetch.Result, error) { return c.markClient.Archive(host, path, token) })
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/latebit/demarkus/client/internal/fetch"
+	"github.com/latebit/demarkus/protocol"
+)
+
+func TestWithBudgetUnlimited(t *testing.T) {
+	c := &stubClient{}
+	if got := withBudget(c, budget{}); got != markClient(c) {
+		t.Errorf("no limits should leave the client unwrapped, got %T", got)
+	}
+}
+
+func TestBudgetLimitsTools(t *testing.T) {
+	ctx := context.Background()
+	calls := 0
+	ok := func() (fetch.Result, error) {
+		calls++
+		return fetch.Result{Response: protocol.Response{Status: protocol.StatusOK, Body: "0123456789"}}, nil
+	}
+	stub := &stubClient{
+		fetchFn:   func(_, _ string) (fetch.Result, error) { return ok() },
+		listFn:    func(_, _ string) (fetch.Result, error) { return ok() },
+		publishFn: func(_, _, _, _ string, _ int, _ map[string]string) (fetch.Result, error) { return ok() },
+	}
+
+	h := &handler{client: withBudget(stub, budget{MaxFetches: 2}), token: "t"}
+	fetchArgs := newCallToolRequest(map[string]any{"url": "mark://example.com/doc.md"})
+	for i := range 2 {
+		if result, _ := h.markFetch(ctx, fetchArgs); result.IsError {
+			t.Fatalf("fetch %d refused: %+v", i, result)
+		}
+	}
+	// LIST shares the read budget.
+	result, _ := h.markList(ctx, newCallToolRequest(map[string]any{"url": "mark://example.com/"}))
+	assertIsToolError(t, result, "2 of 2 fetches used (-max-fetches)")
+	if calls != 2 {
+		t.Errorf("refused request reached the server: %d calls", calls)
+	}
+
+	// Writes have their own budget, and reads stay unlimited.
+	calls = 0
+	h = &handler{client: withBudget(stub, budget{MaxPublishes: 1}), token: "t"}
+	publishArgs := newCallToolRequest(map[string]any{"url": "mark://example.com/doc.md", "body": "# Hi", "expected_version": float64(0)})
+	if result, _ := h.markPublish(ctx, publishArgs); result.IsError {
+		t.Fatalf("first publish refused: %+v", result)
+	}
+	result, _ = h.markPublish(ctx, publishArgs)
+	assertIsToolError(t, result, "-max-publishes")
+	if result, _ := h.markFetch(ctx, fetchArgs); result.IsError {
+		t.Errorf("fetch refused under a publish budget: %+v", result)
+	}
+
+	// Bytes count both directions; the response that crosses the limit is
+	// delivered, the next request is refused.
+	h = &handler{client: withBudget(stub, budget{MaxBytes: 15}), token: "t"}
+	if result, _ := h.markFetch(ctx, fetchArgs); result.IsError {
+		t.Fatalf("fetch refused: %+v", result)
+	}
+	result, _ = h.markPublish(ctx, newCallToolRequest(map[string]any{"url": "mark://example.com/doc.md", "body": "# Too long", "expected_version": float64(0)}))
+	assertIsToolError(t, result, "10 of 15 bytes used (-max-bytes)")
+	if result, _ := h.markFetch(ctx, fetchArgs); result.IsError {
+		t.Fatalf("fetch under the byte limit refused: %+v", result)
+	}
+	result, _ = h.markFetch(ctx, fetchArgs)
+	assertIsToolError(t, result, "-max-bytes")
+}
+
+func TestBudgetFetchMany(t *testing.T) {
+	calls := 0
+	stub := &stubClient{fetchFn: func(_, _ string) (fetch.Result, error) {
+		calls++
+		return fetch.Result{Response: protocol.Response{Status: protocol.StatusOK}}, nil
+	}}
+	c := withBudget(stub, budget{MaxFetches: 3})
+
+	// A batch costs a fetch per document, and is refused whole.
+	if _, err := c.FetchMany("example.com", []string{"/a.md", "/b.md"}); err != nil {
+		t.Fatalf("batch of 2 refused: %v", err)
+	}
+	if _, err := c.FetchMany("example.com", []string{"/c.md", "/d.md"}); err == nil || !strings.Contains(err.Error(), "2 of 3 fetches used") {
+		t.Errorf("batch past the budget: err = %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("refused batch reached the server: %d calls", calls)
+	}
+	if _, err := c.Fetch("example.com", "/c.md"); err != nil {
+		t.Errorf("last fetch refused: %v", err)
+	}
+}
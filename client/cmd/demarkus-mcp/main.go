@@ -9,9 +9,12 @@ import (
 	"flag"
 	"fmt"
 	"log"
-	"strconv"
+	"maps"
+	"sort"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/latebit/demarkus/client/internal/cache"
 	"github.com/latebit/demarkus/client/internal/fetch"
@@ -20,6 +23,7 @@ import (
 	"github.com/latebit/demarkus/client/internal/index"
 	"github.com/latebit/demarkus/client/internal/links"
 	"github.com/latebit/demarkus/client/internal/tokens"
+	"github.com/latebit/demarkus/client/internal/urlnorm"
 	"github.com/latebit/demarkus/protocol"
 	"github.com/mark3labs/mcp-go/mcp"
 	mcpserver "github.com/mark3labs/mcp-go/server"
@@ -34,9 +38,12 @@ func main() {
 	insecure := flag.Bool("insecure", false, "skip TLS certificate verification")
 	noCache := flag.Bool("no-cache", false, "disable response caching")
 	cacheDir := flag.String("cache-dir", cache.DefaultDir(), "cache directory")
+	maxFetches := flag.Int("max-fetches", 0, "most FETCH, LIST and VERSIONS requests per session (0: no limit)")
+	maxPublishes := flag.Int("max-publishes", 0, "most PUBLISH, APPEND and ARCHIVE requests per session (0: no limit)")
+	maxBytes := flag.Int64("max-bytes", 0, "most body bytes sent and received per session (0: no limit)")
 	flag.Parse()
 
-	opts := fetch.Options{Insecure: *insecure}
+	opts := fetch.Options{Insecure: *insecure, MaxRedirects: fetch.DefaultMaxRedirects, CrossHostRedirects: true}
 	if !*noCache {
 		opts.Cache = cache.New(*cacheDir)
 	}
@@ -49,10 +56,12 @@ func main() {
 	if gsErr != nil {
 		log.Printf("warning: graph store unavailable: %v", gsErr)
 	}
-	h := &handler{client: client, defaultHost: *defaultHost, token: *token, graphStore: gs}
+	limits := budget{MaxFetches: *maxFetches, MaxPublishes: *maxPublishes, MaxBytes: *maxBytes}
+	h := &handler{client: withBudget(client, limits), defaultHost: *defaultHost, token: *token, graphStore: gs}
 	s.AddTool(markFetchTool(*defaultHost), h.markFetch)
 	s.AddTool(markListTool(*defaultHost), h.markList)
 	s.AddTool(markGraphTool(*defaultHost), h.markGraph)
+	s.AddTool(markOutlineTool(*defaultHost), h.markOutline)
 	s.AddTool(markVersionsTool(*defaultHost), h.markVersions)
 	s.AddTool(markPublishTool(*defaultHost), h.markPublish)
 	s.AddTool(markArchiveTool(*defaultHost), h.markArchive)
@@ -60,9 +69,12 @@ func main() {
 	s.AddTool(markDiscoverTool(*defaultHost), h.markDiscover)
 	s.AddTool(markResolveTool(*defaultHost), h.markResolve)
 	s.AddTool(markIndexTool(*defaultHost), h.markIndex)
+	s.AddTool(markRecentChangesTool(*defaultHost), h.markRecentChanges)
 	s.AddTool(markBacklinksTool(*defaultHost), h.markBacklinks)
+	s.AddTool(markPathTool(*defaultHost), h.markPath)
 	s.AddTool(markGraphExportTool(), h.markGraphExport)
 	s.AddTool(markGraphPublishTool(*defaultHost), h.markGraphPublish)
+	s.AddTool(markDiagnosticsTool(), h.markDiagnostics)
 
 	if err := mcpserver.ServeStdio(s); err != nil {
 		log.Fatal(err)
@@ -72,11 +84,14 @@ func main() {
 // markClient defines the fetch operations used by MCP tool handlers.
 type markClient interface {
 	Fetch(host, path string) (fetch.Result, error)
+	FetchMany(host string, paths []string) ([]fetch.Result, error)
 	List(host, path string) (fetch.Result, error)
+	ListEntries(host, path string) (fetch.Result, error)
 	Versions(host, path string) (fetch.Result, error)
 	Publish(host, path, body, token string, expectedVersion int, meta map[string]string) (fetch.Result, error)
 	Append(host, path, body, token string, expectedVersion int, meta map[string]string) (fetch.Result, error)
 	Archive(host, path, token string) (fetch.Result, error)
+	Health() map[string]fetch.Health
 }
 
 type handler struct {
@@ -120,7 +135,8 @@ func markFetchTool(host string) mcp.Tool {
 	return mcp.NewTool("mark_fetch",
 		mcp.WithDescription(
 			"Fetch a document from a Mark Protocol server. "+
-				"Returns the document status, version, modified timestamp, etag, and markdown body. "+
+				"Returns the document status, version, modified timestamp, etag, content-length in bytes, word-count and reading-time in minutes, "+
+				"and markdown body. "+
 				urlHint(host),
 		),
 		mcp.WithString("url",
@@ -135,6 +151,7 @@ func markListTool(host string) mcp.Tool {
 		mcp.WithDescription(
 			"List documents and subdirectories on a Mark Protocol server. "+
 				"Use this to discover what documents exist. "+
+				"Each entry gives its name and type, and documents their size in bytes, modified timestamp and version. "+
 				urlHint(host),
 		),
 		mcp.WithString("url",
@@ -161,6 +178,9 @@ func markGraphTool(host string) mcp.Tool {
 		mcp.WithNumber("depth",
 			mcp.Description("Maximum link depth to follow (default 2, max 5)"),
 		),
+		mcp.WithBoolean("same_host",
+			mcp.Description("Only follow links on the starting document's host; links to other hosts are recorded as off-host (default false)"),
+		),
 	)
 }
 
@@ -314,10 +334,24 @@ func markIndexTool(host string) mcp.Tool {
 
 // formatResult builds a text response with status, selected metadata keys, and body.
 // After the explicitly requested keys, any remaining metadata keys (e.g. publisher
-// metadata) are appended so agents always see the full picture.
+// metadata) are appended so agents always see the full picture. A body that is
+// not UTF-8 text, such as an image, is given in base64 and marked with a
+// content-encoding line, since tool results are JSON strings.
 func formatResult(r fetch.Result, keys ...string) string {
+	if body := r.Response.Body; !utf8.ValidString(body) {
+		encoded, _ := protocol.EncodeBody(body, protocol.EncodingBase64)
+		r.Response.Metadata = maps.Clone(r.Response.Metadata)
+		if r.Response.Metadata == nil {
+			r.Response.Metadata = make(map[string]string)
+		}
+		r.Response.Metadata["content-encoding"] = protocol.EncodingBase64
+		r.Response.Body = encoded
+	}
 	var b strings.Builder
 	fmt.Fprintf(&b, "status: %s\n", r.Response.Status)
+	if len(r.Redirects) > 0 {
+		fmt.Fprintf(&b, "url: %s\nredirected-from: %s\n", r.URL, strings.Join(r.Redirects, ", "))
+	}
 	shown := make(map[string]bool, len(keys))
 	for _, key := range keys {
 		if v, ok := r.Response.Metadata[key]; ok {
@@ -371,7 +405,7 @@ func (h *handler) markFetch(_ context.Context, req mcp.CallToolRequest) (*mcp.Ca
 		return mcp.NewToolResultError(fmt.Sprintf("fetch failed: %v", err)), nil
 	}
 
-	return mcp.NewToolResultText(formatResult(result, "version", "modified", "etag")), nil
+	return mcp.NewToolResultText(formatResult(result, "version", "modified", "etag", "content-length", "word-count", "reading-time")), nil
 }
 
 func (h *handler) markList(_ context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) { //nolint:gocritic // signature required by mcp-go
@@ -385,12 +419,12 @@ func (h *handler) markList(_ context.Context, req mcp.CallToolRequest) (*mcp.Cal
 		return mcp.NewToolResultError(fmt.Sprintf("invalid URL: %v", err)), nil
 	}
 
-	result, err := h.client.List(host, path)
+	result, err := h.client.ListEntries(host, path)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("list failed: %v", err)), nil
 	}
 
-	return mcp.NewToolResultText(formatResult(result, "modified")), nil
+	return mcp.NewToolResultText(formatResult(result, "entries", "total")), nil
 }
 
 func (h *handler) markVersions(_ context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) { //nolint:gocritic // signature required by mcp-go
@@ -517,17 +551,14 @@ func (h *handler) markAppend(ctx context.Context, req mcp.CallToolRequest) (*mcp
 		if vErr != nil {
 			return mcp.NewToolResultError(fmt.Sprintf("could not resolve version: %v", vErr)), nil
 		}
-		if vResult.Response.Status != protocol.StatusOK {
-			return mcp.NewToolResultError(fmt.Sprintf("could not resolve version: %s", vResult.Response.Status)), nil
+		history, hErr := fetch.ParseVersionHistory(vResult.Response)
+		if hErr != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("could not resolve version: %v", hErr)), nil
 		}
-		cur, ok := vResult.Response.Metadata["current"]
-		if !ok {
+		if history.Current < 1 {
 			return mcp.NewToolResultError("could not resolve version: no current version in response"), nil
 		}
-		expectedVersion, err = strconv.Atoi(cur)
-		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("could not resolve version: invalid current version %q", cur)), nil
-		}
+		expectedVersion = history.Current
 	}
 
 	result, err := h.client.Append(host, path, body, token, expectedVersion, agentMeta(ctx))
@@ -604,8 +635,8 @@ func (h *handler) markResolve(_ context.Context, req mcp.CallToolRequest) (*mcp.
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("failed to fetch index: %v", err)), nil
 	}
-	if indexResult.Response.Status != protocol.StatusOK {
-		return mcp.NewToolResultError(fmt.Sprintf("index fetch returned: %s", indexResult.Response.Status)), nil
+	if err := indexResult.Err(); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("index fetch returned: %v", err)), nil
 	}
 
 	// Parse index and filter for matching hash.
@@ -633,8 +664,8 @@ func (h *handler) markResolve(_ context.Context, req mcp.CallToolRequest) (*mcp.
 			lastErr = fmt.Sprintf("%s: %v", m.Server, err)
 			continue
 		}
-		if result.Response.Status != protocol.StatusOK {
-			lastErr = fmt.Sprintf("%s: %s", m.Server, result.Response.Status)
+		if err := result.Err(); err != nil {
+			lastErr = fmt.Sprintf("%s: %v", m.Server, err)
 			continue
 		}
 		// Verify content hash matches.
@@ -658,14 +689,14 @@ var errIndexTruncated = errors.New("document limit reached, index is truncated")
 func (h *handler) checkManifests(sourceHost, targetHost string, dryRun, force bool) (warnings []string, block *mcp.CallToolResult) {
 	// Check source manifest (warn only).
 	srcManifest, err := h.client.Fetch(sourceHost, protocol.WellKnownManifestPath)
-	if err != nil || srcManifest.Response.Status != protocol.StatusOK {
+	if err != nil || srcManifest.Err() != nil {
 		warnings = append(warnings, "warning: source server has no agent manifest")
 	}
 
 	// Check target manifest (block unless force or dry run).
 	if !dryRun {
 		tgtManifest, err := h.client.Fetch(targetHost, protocol.WellKnownManifestPath)
-		if err != nil || tgtManifest.Response.Status != protocol.StatusOK {
+		if err != nil || tgtManifest.Err() != nil {
 			if !force {
 				return warnings, mcp.NewToolResultError(
 					"target server has no agent manifest — cannot verify it accepts index publications. " +
@@ -751,8 +782,8 @@ func (h *handler) markIndex(ctx context.Context, req mcp.CallToolRequest) (*mcp.
 		if err != nil {
 			return mcp.NewToolResultError(fmt.Sprintf("failed to fetch existing index: %v", err)), nil
 		}
-		if existing.Response.Status != protocol.StatusOK {
-			return mcp.NewToolResultError(fmt.Sprintf("failed to fetch existing index: %s", existing.Response.Status)), nil
+		if err := existing.Err(); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("failed to fetch existing index: %v", err)), nil
 		}
 		existingEntries := index.Parse(existing.Response.Body)
 		merged := index.Merge(existingEntries, sourceScheme, entries)
@@ -785,35 +816,57 @@ func (h *handler) walkDir(host, dirPath, sourceScheme string, entries *[]index.E
 	if err != nil {
 		return fmt.Errorf("list %s: %w", dirPath, err)
 	}
-	if result.Response.Status != protocol.StatusOK {
+	if result.Err() != nil {
 		return nil // skip inaccessible directories
 	}
+	listing, err := fetch.ParseDirListing(result.Response)
+	if err != nil {
+		return fmt.Errorf("list %s: %w", dirPath, err)
+	}
+
+	prefix := dirPath
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	// Fetch the directory's documents together; any left out, or all of
+	// them if that fails, are fetched one by one below.
+	var files []string
+	for _, dirEntry := range listing.Entries {
+		if !dirEntry.IsDir && len(files) < maxIndexDocuments-len(*entries) {
+			files = append(files, prefix+dirEntry.Name)
+		}
+	}
+	docs := make(map[string]fetch.Result, len(files))
+	if results, err := h.client.FetchMany(host, files); err == nil {
+		for i, r := range results {
+			docs[files[i]] = r
+		}
+	}
 
-	for _, dest := range links.Extract(result.Response.Body) {
+	for _, dirEntry := range listing.Entries {
 		if len(*entries) >= maxIndexDocuments {
 			return errIndexTruncated
 		}
 
-		fullPath := dirPath
-		if !strings.HasSuffix(fullPath, "/") {
-			fullPath += "/"
-		}
-		fullPath += dest
+		fullPath := prefix + dirEntry.Name
 
-		if strings.HasSuffix(dest, "/") {
+		if dirEntry.IsDir {
 			// Directory — recurse.
-			if err := h.walkDir(host, fullPath, sourceScheme, entries); err != nil {
+			if err := h.walkDir(host, fullPath+"/", sourceScheme, entries); err != nil {
 				return err
 			}
 			continue
 		}
 
 		// File — fetch and collect content-hash.
-		doc, err := h.client.Fetch(host, fullPath)
-		if err != nil {
-			continue // skip unreachable documents
+		doc, ok := docs[fullPath]
+		if !ok {
+			if doc, err = h.client.Fetch(host, fullPath); err != nil {
+				continue // skip unreachable documents
+			}
 		}
-		if doc.Response.Status != protocol.StatusOK {
+		if doc.Err() != nil {
 			continue
 		}
 		contentHash, ok := doc.Response.Metadata["content-hash"]
@@ -849,16 +902,13 @@ func (h *handler) markGraph(ctx context.Context, req mcp.CallToolRequest) (*mcp.
 		startURL = h.defaultHost + rawURL
 	}
 
-	g, err := h.graphStore.CrawlAndPersist(ctx, startURL, func(host, path string) (string, string, string, error) {
-		r, fetchErr := h.client.Fetch(host, path)
-		if fetchErr != nil {
-			return "", "", "", fetchErr
-		}
-		return r.Response.Status, r.Response.Body, r.Response.Metadata["etag"], nil
-	}, fetch.ParseMarkURL, graphstore.CrawlOptions{
-		MaxDepth: depth,
-		MaxNodes: 200,
-		Workers:  5,
+	fetchOne, fetchMany := h.crawlFetchers(nil)
+	g, err := h.graphStore.CrawlAndPersist(ctx, startURL, fetchOne, fetch.ParseMarkURL, graphstore.CrawlOptions{
+		MaxDepth:  depth,
+		MaxNodes:  200,
+		Workers:   5,
+		SameHost:  req.GetBool("same_host", false),
+		FetchMany: fetchMany,
 	})
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("crawl failed: %v", err)), nil
@@ -867,6 +917,40 @@ func (h *handler) markGraph(ctx context.Context, req mcp.CallToolRequest) (*mcp.
 	return mcp.NewToolResultText(formatGraph(g, startURL)), nil
 }
 
+// crawlFetchers returns the fetch functions CrawlAndPersist takes, for
+// one document and for several at once. seen, if set, is called with each
+// document fetched.
+func (h *handler) crawlFetchers(seen func(host, path string, r fetch.Result)) (
+	func(host, path string) (status, body, etag string, err error),
+	func(host string, paths []string) ([]graphstore.Fetched, error),
+) {
+	fetchOne := func(host, path string) (string, string, string, error) {
+		r, err := h.client.Fetch(host, path)
+		if err != nil {
+			return "", "", "", err
+		}
+		if seen != nil {
+			seen(host, path, r)
+		}
+		return r.Response.Status, r.Response.Body, r.Response.Metadata["etag"], nil
+	}
+	fetchMany := func(host string, paths []string) ([]graphstore.Fetched, error) {
+		results, err := h.client.FetchMany(host, paths)
+		if err != nil {
+			return nil, err
+		}
+		fetched := make([]graphstore.Fetched, len(results))
+		for i, r := range results {
+			if seen != nil {
+				seen(host, paths[i], r)
+			}
+			fetched[i] = graphstore.Fetched{Status: r.Response.Status, Body: r.Response.Body, Etag: r.Response.Metadata["etag"]}
+		}
+		return fetched, nil
+	}
+	return fetchOne, fetchMany
+}
+
 // formatGraph renders a graph as a plain-text summary for LLM consumption.
 func formatGraph(g *graph.Graph, startURL string) string {
 	var b strings.Builder
@@ -897,6 +981,153 @@ func formatGraph(g *graph.Graph, startURL string) string {
 	return b.String()
 }
 
+func markOutlineTool(host string) mcp.Tool {
+	return mcp.NewTool("mark_outline",
+		mcp.WithDescription(
+			"Crawl a site from a document and return a hierarchical outline: documents "+
+				"grouped by directory, each with its title and heading structure. Use this "+
+				"to learn what a site contains before fetching individual documents. Only "+
+				"links on the starting document's host are followed. "+
+				urlHint(host),
+		),
+		mcp.WithString("url",
+			mcp.Required(),
+			mcp.Description(urlDesc(host)),
+		),
+		mcp.WithNumber("depth",
+			mcp.Description("Maximum link depth to follow (default 2, max 5)"),
+		),
+	)
+}
+
+func (h *handler) markOutline(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) { //nolint:gocritic // signature required by mcp-go
+	rawURL, err := req.RequireString("url")
+	if err != nil {
+		return mcp.NewToolResultError("url is required"), nil
+	}
+
+	depth := max(1, min(req.GetInt("depth", 2), 5))
+
+	if _, _, err := h.resolveURL(rawURL); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid URL: %v", err)), nil
+	}
+
+	startURL := rawURL
+	if strings.HasPrefix(rawURL, "/") {
+		startURL = h.defaultHost + rawURL
+	}
+
+	// Headings are collected while crawling; the graph only keeps titles.
+	var mu sync.Mutex
+	headings := make(map[string][]links.Heading)
+	fetchOne, fetchMany := h.crawlFetchers(func(host, path string, r fetch.Result) {
+		if r.Err() == nil {
+			hs := links.Headings(r.Response.Body)
+			mu.Lock()
+			headings["mark://"+host+path] = hs
+			mu.Unlock()
+		}
+	})
+	g, err := h.graphStore.CrawlAndPersist(ctx, startURL, fetchOne, fetch.ParseMarkURL, graphstore.CrawlOptions{
+		MaxDepth:  depth,
+		MaxNodes:  200,
+		Workers:   5,
+		SameHost:  true,
+		FetchMany: fetchMany,
+	})
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("crawl failed: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(formatOutline(g, startURL, headings)), nil
+}
+
+// maxOutlineHeadings caps the headings listed per document in an outline.
+const maxOutlineHeadings = 12
+
+// formatOutline renders the crawled documents as an outline: grouped by
+// host and directory, each with its title and its level 2 and 3 headings.
+// Documents that could not be fetched are listed at the end.
+func formatOutline(g *graph.Graph, startURL string, headings map[string][]links.Heading) string {
+	type doc struct {
+		host, dir, name string
+		node            *graph.Node
+	}
+	var docs []doc
+	var broken []*graph.Node
+	leaving := 0
+	for _, n := range g.AllNodes() {
+		switch n.Status {
+		case protocol.StatusOK:
+		case "external", graph.StatusOffHost:
+			leaving++
+			continue
+		default:
+			broken = append(broken, n)
+			continue
+		}
+		host, path, err := fetch.ParseMarkURL(n.URL)
+		if err != nil {
+			continue
+		}
+		i := strings.LastIndex(path, "/")
+		dir, name := path[:i+1], path[i+1:]
+		docs = append(docs, doc{host: host, dir: dir, name: name, node: n})
+	}
+	sort.Slice(docs, func(i, j int) bool {
+		if docs[i].host != docs[j].host {
+			return docs[i].host < docs[j].host
+		}
+		if docs[i].dir != docs[j].dir {
+			return docs[i].dir < docs[j].dir
+		}
+		return docs[i].name < docs[j].name
+	})
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Outline of %s: %d documents\n", startURL, len(docs))
+	var host, dir string
+	for _, d := range docs {
+		if d.host != host {
+			host, dir = d.host, ""
+			fmt.Fprintf(&b, "\nmark://%s\n", host)
+		}
+		if d.dir != dir {
+			dir = d.dir
+			fmt.Fprintf(&b, "  %s\n", dir)
+		}
+		line := "    " + d.name
+		if d.node.Title != "" {
+			line += " — " + d.node.Title
+		}
+		b.WriteString(line + "\n")
+
+		listed := 0
+		for _, hd := range headings[d.node.URL] {
+			if hd.Level < 2 || hd.Level > 3 {
+				continue
+			}
+			if listed == maxOutlineHeadings {
+				b.WriteString("        ...\n")
+				break
+			}
+			fmt.Fprintf(&b, "%s- %s\n", strings.Repeat("  ", hd.Level+2), hd.Text)
+			listed++
+		}
+	}
+
+	if len(broken) > 0 {
+		b.WriteString("\nUnreachable:\n")
+		for _, n := range broken {
+			fmt.Fprintf(&b, "  [%s] %s\n", n.Status, n.URL)
+		}
+	}
+	if leaving > 0 {
+		fmt.Fprintf(&b, "\n%d links lead off the site and were not followed.\n", leaving)
+	}
+	return b.String()
+}
+
 func markBacklinksTool(host string) mcp.Tool {
 	return mcp.NewTool("mark_backlinks",
 		mcp.WithDescription(
@@ -948,6 +1179,65 @@ func (h *handler) markBacklinks(_ context.Context, req mcp.CallToolRequest) (*mc
 	return mcp.NewToolResultText(b.String()), nil
 }
 
+func markPathTool(host string) mcp.Tool {
+	return mcp.NewTool("mark_path",
+		mcp.WithDescription(
+			"Find the shortest route of links from one document to another, using the local graph store. "+
+				"Use it to see how a reader reaches a page from an index, or that they cannot. "+
+				"Returns results from previous crawls — run mark_graph on the starting document first to populate. "+
+				urlHint(host),
+		),
+		mcp.WithString("from",
+			mcp.Required(),
+			mcp.Description("Document the route starts at: "+urlDesc(host)),
+		),
+		mcp.WithString("to",
+			mcp.Required(),
+			mcp.Description("Document the route ends at: "+urlDesc(host)),
+		),
+	)
+}
+
+func (h *handler) markPath(_ context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) { //nolint:gocritic // signature required by mcp-go
+	var ends [2]string
+	for i, name := range []string{"from", "to"} {
+		rawURL, err := req.RequireString(name)
+		if err != nil {
+			return mcp.NewToolResultError(name + " is required"), nil
+		}
+		if strings.HasPrefix(rawURL, "/") {
+			if h.defaultHost == "" {
+				return mcp.NewToolResultError(fmt.Sprintf("bare path %q requires -host flag", rawURL)), nil
+			}
+			rawURL = h.defaultHost + rawURL
+		}
+		ends[i] = urlnorm.Normalize(rawURL)
+	}
+
+	if h.graphStore == nil {
+		return mcp.NewToolResultError("graph store not available"), nil
+	}
+
+	g := h.graphStore.ToGraph()
+	route := g.ShortestPath(ends[0], ends[1])
+	if route == nil {
+		return mcp.NewToolResultText(
+			fmt.Sprintf("No route found from %s to %s\nRun mark_graph on %s to populate the graph store.", ends[0], ends[1], ends[0]),
+		), nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Shortest route from %s to %s (%d links):\n\n", ends[0], ends[1], len(route)-1)
+	for i, url := range route {
+		if n := g.GetNode(url); n != nil && n.Title != "" {
+			fmt.Fprintf(&b, "%d. [%s](%s)\n", i+1, n.Title, url)
+		} else {
+			fmt.Fprintf(&b, "%d. %s\n", i+1, url)
+		}
+	}
+	return mcp.NewToolResultText(b.String()), nil
+}
+
 func markGraphExportTool() mcp.Tool {
 	return mcp.NewTool("mark_graph_export",
 		mcp.WithDescription(
@@ -958,6 +1248,16 @@ func markGraphExportTool() mcp.Tool {
 	)
 }
 
+func markDiagnosticsTool() mcp.Tool {
+	return mcp.NewTool("mark_diagnostics",
+		mcp.WithDescription(
+			"Report the health of this session's connections to Mark Protocol servers: "+
+				"per host, the connections dialed, requests answered, retries, failures, "+
+				"requests in flight and mean latency. Use it when requests are slow or failing.",
+		),
+	)
+}
+
 func markGraphPublishTool(host string) mcp.Tool {
 	return mcp.NewTool("mark_graph_publish",
 		mcp.WithDescription(
@@ -989,6 +1289,27 @@ func (h *handler) markGraphExport(_ context.Context, _ mcp.CallToolRequest) (*mc
 	return mcp.NewToolResultText(md), nil
 }
 
+func (h *handler) markDiagnostics(_ context.Context, _ mcp.CallToolRequest) (*mcp.CallToolResult, error) { //nolint:gocritic // signature required by mcp-go
+	health := h.client.Health()
+	if len(health) == 0 {
+		return mcp.NewToolResultText("No connections yet."), nil
+	}
+	hosts := make([]string, 0, len(health))
+	for host := range health {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	var b strings.Builder
+	for _, host := range hosts {
+		hh := health[host]
+		fmt.Fprintf(&b, "%s\n", host)
+		fmt.Fprintf(&b, "  dials: %d\n  requests: %d\n  retries: %d\n  failures: %d\n  active-streams: %d\n  mean-latency: %s\n",
+			hh.Dials, hh.Requests, hh.Retries, hh.Failures, hh.ActiveStreams, hh.MeanLatency.Round(time.Millisecond/10))
+	}
+	return mcp.NewToolResultText(b.String()), nil
+}
+
 func (h *handler) markGraphPublish(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) { //nolint:gocritic // signature required by mcp-go
 	if h.graphStore == nil {
 		return mcp.NewToolResultError("graph store not available"), nil
@@ -5,6 +5,7 @@ import (
 	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"path/filepath"
 
@@ -123,6 +124,20 @@ func TestToolDefinitions(t *testing.T) {
 			wantRequired: []string{"url"},
 			wantDesc:     "Crawl outbound links",
 		},
+		{
+			name:         "mark_path",
+			tool:         markPathTool(""),
+			wantName:     "mark_path",
+			wantRequired: []string{"from", "to"},
+			wantDesc:     "shortest route",
+		},
+		{
+			name:         "mark_outline",
+			tool:         markOutlineTool(""),
+			wantName:     "mark_outline",
+			wantRequired: []string{"url"},
+			wantDesc:     "hierarchical outline",
+		},
 		{
 			name:         "mark_versions",
 			tool:         markVersionsTool(""),
@@ -315,6 +330,15 @@ func TestFormatResult(t *testing.T) {
 			keys:     []string{"version", "etag"},
 			wantSubs: []string{"status: ok", "version: 1", "content"},
 		},
+		{
+			name: "redirects are reported",
+			result: fetch.Result{
+				Response:  protocol.Response{Status: "ok", Body: "new"},
+				URL:       "mark://h:6309/new.md",
+				Redirects: []string{"mark://h:6309/old.md"},
+			},
+			wantSubs: []string{"url: mark://h:6309/new.md", "redirected-from: mark://h:6309/old.md"},
+		},
 		{
 			name: "no body omits trailing newline",
 			result: fetch.Result{
@@ -362,6 +386,18 @@ func TestFormatResult(t *testing.T) {
 			keys:     []string{"version", "type"},
 			wantSubs: []string{"version: 1", "type: log"},
 		},
+		{
+			name: "binary body given in base64",
+			result: fetch.Result{
+				Response: protocol.Response{
+					Status:   "ok",
+					Metadata: map[string]string{"content-type": "image/png", "disposition": "attachment"},
+					Body:     "\x89PNG\r\n\x1a\n",
+				},
+			},
+			keys:     []string{"content-type"},
+			wantSubs: []string{"content-type: image/png", "content-encoding: base64", "\n\niVBORw0KGgo="},
+		},
 	}
 
 	for _, tt := range tests {
@@ -486,6 +522,11 @@ type stubClient struct {
 	versionsFn func(host, path string) (fetch.Result, error)
 	publishFn  func(host, path, body, token string, expectedVersion int, meta map[string]string) (fetch.Result, error)
 	appendFn   func(host, path, body, token string, expectedVersion int, meta map[string]string) (fetch.Result, error)
+	health     map[string]fetch.Health
+}
+
+func (s *stubClient) Health() map[string]fetch.Health {
+	return s.health
 }
 
 func (s *stubClient) Fetch(host, path string) (fetch.Result, error) {
@@ -494,12 +535,26 @@ func (s *stubClient) Fetch(host, path string) (fetch.Result, error) {
 	}
 	return fetch.Result{}, nil
 }
+func (s *stubClient) FetchMany(host string, paths []string) ([]fetch.Result, error) {
+	results := make([]fetch.Result, len(paths))
+	for i, path := range paths {
+		r, err := s.Fetch(host, path)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = r
+	}
+	return results, nil
+}
 func (s *stubClient) List(host, path string) (fetch.Result, error) {
 	if s.listFn != nil {
 		return s.listFn(host, path)
 	}
 	return fetch.Result{}, nil
 }
+func (s *stubClient) ListEntries(host, path string) (fetch.Result, error) {
+	return s.List(host, path)
+}
 func (s *stubClient) Publish(host, path, body, token string, expectedVersion int, meta map[string]string) (fetch.Result, error) {
 	if s.publishFn != nil {
 		return s.publishFn(host, path, body, token, expectedVersion, meta)
@@ -1096,6 +1151,60 @@ func TestHandlerMarkBacklinks_HappyPath(t *testing.T) {
 	}
 }
 
+func TestHandlerMarkPath(t *testing.T) {
+	gs, err := graphstore.Load(filepath.Join(t.TempDir(), "graph.json"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	g := graph.New()
+	g.AddNode(&graph.Node{URL: "mark://host:6309/index.md", Title: "Home", Status: "ok"})
+	g.AddNode(&graph.Node{URL: "mark://host:6309/guide.md", Title: "Guide", Status: "ok"})
+	g.AddNode(&graph.Node{URL: "mark://host:6309/deep.md", Status: "ok"})
+	g.AddNode(&graph.Node{URL: "mark://host:6309/lost.md", Status: "ok"})
+	g.AddEdge("mark://host:6309/index.md", "mark://host:6309/guide.md")
+	g.AddEdge("mark://host:6309/guide.md", "mark://host:6309/deep.md")
+	gs.Merge(g, nil)
+
+	h := &handler{defaultHost: "mark://host:6309", graphStore: gs}
+	ctx := context.Background()
+
+	result, err := h.markPath(ctx, newCallToolRequest(map[string]any{"from": "/index.md", "to": "mark://HOST/deep.md"}))
+	if err != nil {
+		t.Fatalf("unexpected Go error: %v", err)
+	}
+	if result.IsError {
+		t.Fatal("expected success result")
+	}
+	text, ok := result.Content[0].(mcp.TextContent)
+	if !ok {
+		t.Fatalf("expected TextContent, got %T", result.Content[0])
+	}
+	for _, want := range []string{"(2 links)", "1. [Home](mark://host:6309/index.md)", "2. [Guide]", "3. mark://host:6309/deep.md"} {
+		if !strings.Contains(text.Text, want) {
+			t.Errorf("expected %q in output: %s", want, text.Text)
+		}
+	}
+
+	result, err = h.markPath(ctx, newCallToolRequest(map[string]any{"from": "/index.md", "to": "/lost.md"}))
+	if err != nil {
+		t.Fatalf("unexpected Go error: %v", err)
+	}
+	text, ok = result.Content[0].(mcp.TextContent)
+	if !ok || result.IsError || !strings.Contains(text.Text, "No route found") {
+		t.Errorf("expected no route, got %+v", result.Content[0])
+	}
+}
+
+func TestHandlerMarkPath_BarePathWithoutHost(t *testing.T) {
+	h := &handler{}
+	result, err := h.markPath(context.Background(), newCallToolRequest(map[string]any{"from": "/index.md", "to": "/a.md"}))
+	if err != nil {
+		t.Fatalf("unexpected Go error: %v", err)
+	}
+	assertIsToolError(t, result, "requires -host flag")
+}
+
 func TestHandlerMarkBacklinks_NilStore(t *testing.T) {
 	h := &handler{defaultHost: "mark://host:6309"}
 	ctx := context.Background()
@@ -1256,6 +1365,49 @@ func TestHandlerMarkGraphPublish_NoToken(t *testing.T) {
 	assertIsToolError(t, result, "requires a token")
 }
 
+func TestHandlerMarkOutline(t *testing.T) {
+	site := map[string]string{
+		"/index.md":      "# Home\n\n## Start here\n\n- [Guide](/docs/guide.md)\n- [API](/docs/api.md)\n- [Gone](/gone.md)\n- [Elsewhere](mark://other.com/x.md)\n",
+		"/docs/guide.md": "# Guide\n\n## Install\n\n### From source\n\n#### Too deep\n\n## Use\n",
+		"/docs/api.md":   "# API\n\nNo sections.\n",
+	}
+	sc := &stubClient{
+		fetchFn: func(host, path string) (fetch.Result, error) {
+			body, ok := site[path]
+			if !ok || host != "h:6309" {
+				return fetch.Result{Response: protocol.Response{Status: protocol.StatusNotFound}}, nil
+			}
+			return fetch.Result{Response: protocol.Response{Status: protocol.StatusOK, Body: body}}, nil
+		},
+	}
+	h := &handler{client: sc, defaultHost: "mark://h:6309"}
+
+	result, err := h.markOutline(context.Background(), newCallToolRequest(map[string]any{"url": "/index.md"}))
+	if err != nil {
+		t.Fatalf("unexpected Go error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("unexpected tool error: %v", result.Content)
+	}
+	text := result.Content[0].(mcp.TextContent).Text
+
+	want := []string{
+		"Outline of mark://h:6309/index.md: 3 documents",
+		"mark://h:6309\n  /\n    index.md — Home\n        - Start here\n",
+		"  /docs/\n    api.md — API\n    guide.md — Guide\n        - Install\n          - From source\n        - Use\n",
+		"[not-found] mark://h:6309/gone.md",
+		"1 links lead off the site",
+	}
+	for _, w := range want {
+		if !strings.Contains(text, w) {
+			t.Errorf("outline missing %q:\n%s", w, text)
+		}
+	}
+	if strings.Contains(text, "Too deep") {
+		t.Errorf("outline lists level 4 headings:\n%s", text)
+	}
+}
+
 // assertIsToolError checks that a CallToolResult is an error containing the given substring.
 func assertIsToolError(t *testing.T, result *mcp.CallToolResult, substr string) {
 	t.Helper()
@@ -1273,3 +1425,32 @@ func assertIsToolError(t *testing.T, result *mcp.CallToolResult, substr string)
 		t.Errorf("error text %q does not contain %q", text.Text, substr)
 	}
 }
+
+func TestHandlerMarkDiagnostics(t *testing.T) {
+	ctx := context.Background()
+	text := func(h *handler) string {
+		t.Helper()
+		result, err := h.markDiagnostics(ctx, newCallToolRequest(nil))
+		if err != nil || result.IsError {
+			t.Fatalf("unexpected error: %v, %+v", err, result)
+		}
+		return result.Content[0].(mcp.TextContent).Text
+	}
+
+	if got := text(&handler{client: &stubClient{}}); got != "No connections yet." {
+		t.Errorf("no connections: got %q", got)
+	}
+
+	got := text(&handler{client: &stubClient{health: map[string]fetch.Health{
+		"b.example:6309": {Dials: 1, Requests: 3, MeanLatency: 12 * time.Millisecond},
+		"a.example:6309": {Dials: 2, Requests: 5, Retries: 1, Failures: 1, ActiveStreams: 1},
+	}}})
+	for _, want := range []string{"dials: 2", "retries: 1", "failures: 1", "active-streams: 1", "mean-latency: 12ms"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Index(got, "a.example") > strings.Index(got, "b.example") {
+		t.Errorf("hosts not sorted:\n%s", got)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/latebit/demarkus/client/internal/fetch"
+	"github.com/latebit/demarkus/protocol"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// defaultRecentLimit is how many changes mark_recent_changes returns
+	// when the agent does not say.
+	defaultRecentLimit = 50
+	// maxRecentDirs bounds the directories one mark_recent_changes call
+	// lists, so that a huge server cannot run an agent's budget dry.
+	maxRecentDirs = 500
+)
+
+func markRecentChangesTool(host string) mcp.Tool {
+	return mcp.NewTool("mark_recent_changes",
+		mcp.WithDescription(
+			"List the documents under a directory of a Mark Protocol server that were modified since a time, "+
+				"newest first, with their current versions. Use it to sync a knowledge base incrementally: "+
+				"pass the next_since it returns as since on the next call. Set include_body to read the changed documents too. "+
+				"The tool walks the server's directory listings. "+
+				urlHint(host),
+		),
+		mcp.WithString("url",
+			mcp.Required(),
+			mcp.Description("Directory to look under, e.g. the server root: "+urlDesc(host)),
+		),
+		mcp.WithString("since",
+			mcp.Required(),
+			mcp.Description("RFC 3339 timestamp, e.g. 2026-01-02T15:04:05Z; documents modified at or after it are listed"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description(fmt.Sprintf("most documents to return, newest first (default %d)", defaultRecentLimit)),
+		),
+		mcp.WithBoolean("include_body",
+			mcp.Description("if true, also fetch the listed documents and return their content (default false)"),
+		),
+	)
+}
+
+// change is a document found by mark_recent_changes.
+type change struct {
+	Path     string
+	Version  int
+	Modified time.Time
+}
+
+// recentScan is what a walk of a server's listings found.
+type recentScan struct {
+	changes   []change
+	dirs      int      // directories listed
+	truncated []string // directories whose listing was cut short
+	stopped   bool     // maxRecentDirs was reached
+}
+
+func (h *handler) markRecentChanges(_ context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) { //nolint:gocritic // signature required by mcp-go
+	rawURL, err := req.RequireString("url")
+	if err != nil {
+		return mcp.NewToolResultError("url is required"), nil
+	}
+	rawSince, err := req.RequireString("since")
+	if err != nil {
+		return mcp.NewToolResultError("since is required"), nil
+	}
+	since, err := time.Parse(time.RFC3339, rawSince)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("since must be an RFC 3339 timestamp: %v", err)), nil
+	}
+	limit := req.GetInt("limit", defaultRecentLimit)
+	if limit < 1 {
+		return mcp.NewToolResultError("limit must be at least 1"), nil
+	}
+
+	host, dir, err := h.resolveURL(rawURL)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid URL: %v", err)), nil
+	}
+	if !strings.HasSuffix(dir, "/") {
+		dir += "/"
+	}
+
+	scan, err := h.scanChanges(host, dir, since)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("crawl failed: %v", err)), nil
+	}
+	changes := scan.changes
+	sort.Slice(changes, func(i, j int) bool {
+		if !changes[i].Modified.Equal(changes[j].Modified) {
+			return changes[i].Modified.After(changes[j].Modified)
+		}
+		return changes[i].Path < changes[j].Path
+	})
+	nextSince := since
+	if len(changes) > 0 {
+		nextSince = changes[0].Modified
+	}
+	shown := changes[:min(limit, len(changes))]
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d documents modified since %s under mark://%s%s (%d directories listed)",
+		len(changes), since.UTC().Format(time.RFC3339), host, dir, scan.dirs)
+	if len(shown) < len(changes) {
+		fmt.Fprintf(&b, ", showing the newest %d", len(shown))
+	}
+	b.WriteString(":\n\n")
+	for _, c := range shown {
+		fmt.Fprintf(&b, "- %s mark://%s%s", c.Modified.UTC().Format(time.RFC3339), host, c.Path)
+		if c.Version > 0 {
+			fmt.Fprintf(&b, " (v%d)", c.Version)
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "\nnext_since: %s\n", nextSince.UTC().Format(time.RFC3339))
+	if scan.stopped {
+		fmt.Fprintf(&b, "warning: stopped after %d directories; narrow url to see the rest\n", maxRecentDirs)
+	}
+	for _, d := range scan.truncated {
+		fmt.Fprintf(&b, "warning: the listing of %s was cut short by the server\n", d)
+	}
+
+	if req.GetBool("include_body", false) && len(shown) > 0 {
+		paths := make([]string, len(shown))
+		for i, c := range shown {
+			paths[i] = c.Path
+		}
+		docs, err := h.client.FetchMany(host, paths)
+		for i, p := range paths {
+			fmt.Fprintf(&b, "\n---\n# mark://%s%s\n\n", host, p)
+			switch {
+			case err != nil:
+				fmt.Fprintf(&b, "fetch failed: %v\n", err)
+			case i >= len(docs):
+				b.WriteString("fetch failed: no response\n")
+			default:
+				b.WriteString(formatResult(docs[i], "version", "modified"))
+			}
+		}
+	}
+	return mcp.NewToolResultText(b.String()), nil
+}
+
+// scanChanges lists dir and the directories under it, breadth first, and
+// collects the documents modified at or after since. A directory the
+// server refuses to list is skipped, unless it is dir itself.
+func (h *handler) scanChanges(host, dir string, since time.Time) (recentScan, error) {
+	var scan recentScan
+	queue := []string{dir}
+	for len(queue) > 0 {
+		if scan.dirs >= maxRecentDirs {
+			scan.stopped = true
+			break
+		}
+		d := queue[0]
+		queue = queue[1:]
+		scan.dirs++
+
+		result, err := h.client.ListEntries(host, d)
+		if err != nil {
+			return recentScan{}, fmt.Errorf("list %s: %w", d, err)
+		}
+		err = result.Err()
+		var listing fetch.DirListing
+		if err == nil {
+			listing, err = fetch.ParseDirListing(result.Response)
+		}
+		if err != nil {
+			if d == dir {
+				return recentScan{}, fmt.Errorf("list %s: %w", d, err)
+			}
+			continue // skip inaccessible directories
+		}
+		if result.Response.Metadata["content-type"] != protocol.ListingContentType {
+			// Without a structured listing there are no modification times.
+			return recentScan{}, fmt.Errorf("list %s: the server does not give structured listings", d)
+		}
+		if listing.Truncated {
+			scan.truncated = append(scan.truncated, d)
+		}
+		for _, e := range listing.Entries {
+			switch {
+			case e.IsDir:
+				queue = append(queue, d+e.Name+"/")
+			case !e.Modified.IsZero() && !e.Modified.Before(since):
+				scan.changes = append(scan.changes, change{Path: d + e.Name, Version: e.Version, Modified: e.Modified})
+			}
+		}
+	}
+	return scan, nil
+}
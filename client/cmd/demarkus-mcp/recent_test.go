@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/latebit/demarkus/client/internal/fetch"
+	"github.com/latebit/demarkus/protocol"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestHandlerMarkRecentChanges(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 10, d, 12, 0, 0, 0, time.UTC) }
+	listings := map[string][]protocol.ListEntry{
+		"/": {
+			{Name: "docs", Type: protocol.EntryDirectory},
+			{Name: "private", Type: protocol.EntryDirectory},
+			{Name: "index.md", Type: protocol.EntryDocument, Modified: day(1), Version: 4},
+			{Name: "news.md", Type: protocol.EntryDocument, Modified: day(12), Version: 9},
+		},
+		"/docs/": {
+			{Name: "old.md", Type: protocol.EntryDocument, Modified: day(2), Version: 1},
+			{Name: "setup.md", Type: protocol.EntryDocument, Modified: day(10), Version: 3},
+			{Name: "notes.txt", Type: protocol.EntryFile},
+		},
+	}
+	var listed []string
+	sc := &stubClient{
+		listFn: func(_, path string) (fetch.Result, error) {
+			listed = append(listed, path)
+			entries, ok := listings[path]
+			if !ok {
+				return fetch.Result{Response: protocol.Response{Status: protocol.StatusNotPermitted}}, nil
+			}
+			body, err := protocol.FormatListing(entries)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return fetch.Result{Response: protocol.Response{
+				Status:   protocol.StatusOK,
+				Metadata: map[string]string{"content-type": protocol.ListingContentType},
+				Body:     body,
+			}}, nil
+		},
+		fetchFn: func(_, path string) (fetch.Result, error) {
+			return fetch.Result{Response: protocol.Response{
+				Status:   protocol.StatusOK,
+				Metadata: map[string]string{"version": "9"},
+				Body:     "# Body of " + path + "\n",
+			}}, nil
+		},
+	}
+	h := &handler{client: sc, defaultHost: "mark://host:6309"}
+
+	result, err := h.markRecentChanges(context.Background(), newCallToolRequest(map[string]any{
+		"url":   "/",
+		"since": "2026-10-10T12:00:00Z",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IsError {
+		t.Fatalf("unexpected tool error: %v", result.Content)
+	}
+	text := result.Content[0].(mcp.TextContent).Text
+	news := strings.Index(text, "- 2026-10-12T12:00:00Z mark://host:6309/news.md (v9)")
+	setup := strings.Index(text, "- 2026-10-10T12:00:00Z mark://host:6309/docs/setup.md (v3)")
+	if news < 0 || setup < news {
+		t.Errorf("changes missing or not newest first:\n%s", text)
+	}
+	for _, old := range []string{"index.md", "old.md", "notes.txt"} {
+		if strings.Contains(text, old) {
+			t.Errorf("%s listed:\n%s", old, text)
+		}
+	}
+	if !strings.Contains(text, "2 documents modified since") || !strings.Contains(text, "next_since: 2026-10-12T12:00:00Z") {
+		t.Errorf("summary:\n%s", text)
+	}
+	if strings.Join(listed, " ") != "/ /docs/ /private/" {
+		t.Errorf("listed %v", listed)
+	}
+
+	result, err = h.markRecentChanges(context.Background(), newCallToolRequest(map[string]any{
+		"url":          "/",
+		"since":        "2026-10-10T12:00:00Z",
+		"limit":        float64(1),
+		"include_body": true,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	text = result.Content[0].(mcp.TextContent).Text
+	if !strings.Contains(text, "showing the newest 1") || strings.Contains(text, "setup.md") {
+		t.Errorf("limit not applied:\n%s", text)
+	}
+	if !strings.Contains(text, "# Body of /news.md") {
+		t.Errorf("body missing:\n%s", text)
+	}
+}
+
+func TestHandlerMarkRecentChanges_Errors(t *testing.T) {
+	markdownListing := &stubClient{
+		listFn: func(_, _ string) (fetch.Result, error) {
+			return fetch.Result{Response: protocol.Response{
+				Status: protocol.StatusOK,
+				Body:   "# Index of /\n\n- [doc.md](doc.md)\n",
+			}}, nil
+		},
+	}
+	tests := []struct {
+		name string
+		args map[string]any
+		want string
+	}{
+		{"missing since", map[string]any{"url": "/"}, "since is required"},
+		{"bad since", map[string]any{"url": "/", "since": "yesterday"}, "RFC 3339"},
+		{"bad limit", map[string]any{"url": "/", "since": "2026-10-10T12:00:00Z", "limit": float64(0)}, "limit must be at least 1"},
+		{"no structured listing", map[string]any{"url": "/", "since": "2026-10-10T12:00:00Z"}, "structured listings"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &handler{client: markdownListing, defaultHost: "mark://host:6309"}
+			result, err := h.markRecentChanges(context.Background(), newCallToolRequest(tt.args))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !result.IsError || !strings.Contains(result.Content[0].(mcp.TextContent).Text, tt.want) {
+				t.Errorf("got %v, want an error containing %q", result.Content, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/latebit/demarkus/client/internal/annotations"
+)
+
+// annotateStage is the step of adding an annotation: the passage is typed
+// first, then the note.
+type annotateStage int
+
+const (
+	annotateOff annotateStage = iota
+	annotatePassage
+	annotateNote
+)
+
+// pageVersion returns the version of the current document, which
+// annotations are pinned to.
+func pageVersion(metadata map[string]string) (int, bool) {
+	v, err := strconv.Atoi(metadata["version"])
+	return v, err == nil && v > 0
+}
+
+// versionAnnotations returns the annotations of url made on version v.
+func (m model) versionAnnotations(url string, v int) []annotations.Annotation {
+	if m.annotationStore == nil {
+		return nil
+	}
+	var out []annotations.Annotation
+	for _, a := range m.annotationStore.For(url) {
+		if a.Version == v {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// annotatedBody returns body with the passages annotated on its version
+// highlighted.
+func (m model) annotatedBody(url string, metadata map[string]string, body string) string {
+	v, ok := pageVersion(metadata)
+	if !ok {
+		return body
+	}
+	return annotations.Highlight(body, m.versionAnnotations(url, v))
+}
+
+// startAnnotation prompts for a passage of the current document.
+func (m model) startAnnotation() (tea.Model, tea.Cmd) {
+	if m.annotationStore == nil || isLocalPage(m.status) || m.rawBody == "" {
+		return m, nil
+	}
+	if _, ok := pageVersion(m.metadata); !ok {
+		return m.flash("Only versioned documents can be annotated")
+	}
+	m.annotateInput = textinput.New()
+	m.annotateInput.Prompt = "Highlight: "
+	m.annotateInput.Placeholder = "passage as written in the document"
+	m.annotateInput.Focus()
+	m.annotateStage = annotatePassage
+	return m, textinput.Blink
+}
+
+// handleAnnotateKey feeds keys to the annotation prompt. Enter moves from
+// the passage to the note and then saves; Esc cancels.
+func (m model) handleAnnotateKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEscape:
+		m.annotateStage = annotateOff
+		return m, nil
+	case tea.KeyEnter:
+		value := m.annotateInput.Value()
+		if m.annotateStage == annotatePassage {
+			if strings.TrimSpace(value) == "" {
+				m.annotateStage = annotateOff
+				return m, nil
+			}
+			if !strings.Contains(m.rawBody, value) {
+				m.annotateStage = annotateOff
+				return m.flash("Passage not found in this version")
+			}
+			m.annotateText = value
+			m.annotateInput.Reset()
+			m.annotateInput.Prompt = "Note: "
+			m.annotateInput.Placeholder = "optional"
+			m.annotateStage = annotateNote
+			return m, nil
+		}
+		m.annotateStage = annotateOff
+		return m.saveAnnotation(m.annotateText, strings.TrimSpace(value))
+	}
+	var cmd tea.Cmd
+	m.annotateInput, cmd = m.annotateInput.Update(msg)
+	return m, cmd
+}
+
+// saveAnnotation pins text to its first occurrence in the current version
+// that is not annotated yet, or, when every occurrence is, updates the
+// note of the first.
+func (m model) saveAnnotation(text, note string) (tea.Model, tea.Cmd) {
+	url := annotations.DocumentURL(m.addressBar.Value())
+	v, _ := pageVersion(m.metadata)
+	taken := make(map[int]bool)
+	for _, a := range m.versionAnnotations(url, v) {
+		taken[a.Offset] = true
+	}
+	offset := passageOffset(m.rawBody, text, taken)
+	a := annotations.Annotation{URL: url, Version: v, Offset: offset, Text: text, Note: note}
+	if err := m.annotationStore.Add(a); err != nil {
+		return m.flash("Failed to save annotation: " + err.Error())
+	}
+
+	// Drop rendered snapshots of this document so they pick up the highlight.
+	for i := range m.history {
+		if annotations.DocumentURL(m.history[i].url) == url {
+			m.history[i].rendered = ""
+		}
+	}
+	for i := range m.jumps {
+		if annotations.DocumentURL(m.jumps[i].url) == url {
+			m.jumps[i].rendered = ""
+		}
+	}
+	if m.ready {
+		offsetY := m.viewport.YOffset
+		if rendered, err := m.renderPage(url, m.annotatedBody(url, m.metadata, m.rawBody)); err == nil {
+			m.viewport.SetContent(rendered)
+			m.viewport.SetYOffset(offsetY)
+		}
+	}
+	return m.flash("Annotation saved")
+}
+
+// passageOffset returns the first occurrence of text in body not in taken,
+// or the first occurrence when all are taken.
+func passageOffset(body, text string, taken map[int]bool) int {
+	first := -1
+	for from := 0; from <= len(body); {
+		i := strings.Index(body[from:], text)
+		if i < 0 {
+			break
+		}
+		at := from + i
+		if !taken[at] {
+			return at
+		}
+		if first < 0 {
+			first = at
+		}
+		from = at + 1
+	}
+	return first
+}
+
+// handleAnnotationsView shows the annotations of the current document, or
+// of every document when no document is open.
+func (m model) handleAnnotationsView() (tea.Model, tea.Cmd) {
+	if m.annotationStore == nil {
+		return m, nil
+	}
+	url := ""
+	if !isLocalPage(m.status) {
+		url = m.addressBar.Value()
+	}
+	return m.showLocalPage(pageAnnotations, m.annotationStore.Export(url)), nil
+}
+
+// annotationBadge is the status-bar marker for annotations on the current
+// version.
+func annotationBadge(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return fmt.Sprintf("✎ %d", n)
+}
+
+// flash shows a transient message in the status bar.
+func (m model) flash(msg string) (tea.Model, tea.Cmd) {
+	m.bookmarkMsg = msg
+	m.bookmarkSeq++
+	seq := m.bookmarkSeq
+	return m, tea.Tick(2*time.Second, func(time.Time) tea.Msg {
+		return clearBookmarkMsg{seq: seq}
+	})
+}
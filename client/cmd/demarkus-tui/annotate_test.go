@@ -0,0 +1,83 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/latebit/demarkus/client/internal/annotations"
+	"github.com/latebit/demarkus/protocol"
+)
+
+func TestAnnotateCurrentVersion(t *testing.T) {
+	as, err := annotations.Load(filepath.Join(t.TempDir(), "annotations.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := model{
+		addressBar:      textinput.New(),
+		focus:           focusViewport,
+		histIdx:         -1,
+		linkIdx:         -1,
+		status:          protocol.StatusOK,
+		metadata:        map[string]string{"version": "2"},
+		rawBody:         "# Doc\n\nA word, another word.\n",
+		annotationStore: as,
+	}
+	m.addressBar.SetValue("mark://h/doc.md/v2")
+
+	press := func(key tea.KeyMsg) {
+		t.Helper()
+		next, _ := m.handleKey(key)
+		m = next.(model)
+	}
+	annotate := func(passage, note string) {
+		t.Helper()
+		press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("a")})
+		if m.annotateStage != annotatePassage {
+			t.Fatal("annotation prompt not shown")
+		}
+		m.annotateInput.SetValue(passage)
+		press(tea.KeyMsg{Type: tea.KeyEnter})
+		m.annotateInput.SetValue(note)
+		press(tea.KeyMsg{Type: tea.KeyEnter})
+	}
+	annotate("word", "first")
+	annotate("word", "second")
+
+	got := as.For("mark://h/doc.md")
+	if len(got) != 2 {
+		t.Fatalf("annotations = %+v, want 2", got)
+	}
+	// The second highlight of the same passage takes its next occurrence.
+	if got[0].Offset != 9 || got[1].Offset != 23 || got[1].Note != "second" || got[1].Version != 2 {
+		t.Errorf("annotations = %+v", got)
+	}
+	m.bookmarkMsg = ""
+	if !strings.Contains(m.statusBarView(), "✎ 2") {
+		t.Errorf("status bar = %q", m.statusBarView())
+	}
+
+	// A passage missing from the version is rejected.
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("a")})
+	m.annotateInput.SetValue("absent")
+	press(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.annotateStage != annotateOff || as.Len() != 2 {
+		t.Errorf("stage = %v, %d annotations", m.annotateStage, as.Len())
+	}
+
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("A")})
+	if m.status != pageAnnotations || !strings.Contains(m.rawBody, "second — [v2](mark://h/doc.md/v2)") {
+		t.Errorf("annotations page %q:\n%s", m.status, m.rawBody)
+	}
+}
+
+func TestAnnotateUnversioned(t *testing.T) {
+	m := model{addressBar: textinput.New(), status: protocol.StatusOK, rawBody: "text", annotationStore: &annotations.Store{}}
+	next, _ := m.startAnnotation()
+	if next.(model).annotateStage != annotateOff {
+		t.Error("unversioned page opened the annotation prompt")
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/latebit/demarkus/client/internal/fetch"
+)
+
+// isAttachment reports whether the server marked a response body as a
+// download (disposition: attachment), which is never rendered or mined for
+// links and titles, since its content type is not one the TUI can show
+// safely.
+func isAttachment(metadata map[string]string) bool {
+	return metadata["disposition"] == "attachment"
+}
+
+// attachmentNotice is the page shown in place of an attachment's body.
+func attachmentNotice(url string, metadata map[string]string, size int) string {
+	name := url
+	if _, p, err := fetch.ParseMarkURL(url); err == nil && strings.Trim(p, "/") != "" {
+		name = path.Base(p)
+	}
+	contentType := metadata["content-type"]
+	if contentType == "" {
+		contentType = "unknown"
+	}
+	noTicks := func(s string) string { return strings.ReplaceAll(s, "`", "") }
+	return fmt.Sprintf("# %s\n\nThe server marks this document as a download, so it was not displayed.\n\n"+
+		"- Content type: `%s`\n- Size: %d bytes\n\nSave it with `demarkus %s > %s`.\n",
+		escapeLinkText(name), noTicks(contentType), size, noTicks(url), noTicks(name))
+}
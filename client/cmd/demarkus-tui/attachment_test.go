@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/latebit/demarkus/client/internal/fetch"
+	"github.com/latebit/demarkus/protocol"
+)
+
+func TestAttachmentNotShown(t *testing.T) {
+	m := model{fetchSeq: 1, histIdx: -1}
+	body := "# Run me\n\n[payload](/evil.md)\n\x1b]0;owned\x07\n"
+	msg := fetchResult{
+		result: fetch.Result{Response: protocol.Response{
+			Status:   protocol.StatusOK,
+			Metadata: map[string]string{"content-type": "application/x-sh", "disposition": "attachment"},
+			Body:     body,
+		}},
+		url: "mark://h/tools/install.sh",
+		seq: 1,
+	}
+	next, _ := m.handleFetchResult(msg)
+	got := next.(model)
+
+	if strings.Contains(got.rawBody, "Run me") || strings.Contains(got.rawBody, "\x1b") {
+		t.Errorf("attachment body was shown:\n%s", got.rawBody)
+	}
+	for _, want := range []string{"# install.sh", "application/x-sh", "demarkus mark://h/tools/install.sh > install.sh"} {
+		if !strings.Contains(got.pendingBody, want) {
+			t.Errorf("notice missing %q:\n%s", want, got.pendingBody)
+		}
+	}
+	if len(got.links) != 0 {
+		t.Errorf("links extracted from attachment: %v", got.links)
+	}
+	if got.status != protocol.StatusOK || got.metadata["content-type"] != "application/x-sh" {
+		t.Errorf("response details lost: status %q, metadata %v", got.status, got.metadata)
+	}
+
+	// Without the hint the body is rendered as before.
+	msg.seq = 2
+	got.fetchSeq = 2
+	delete(msg.result.Response.Metadata, "disposition")
+	next, _ = got.handleFetchResult(msg)
+	if got := next.(model); got.rawBody != body || len(got.links) != 1 {
+		t.Errorf("inline body: rawBody %q, links %v", got.rawBody, got.links)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// rateLimitedMsg reports that a request was rate limited and the client is
+// waiting before retrying it.
+type rateLimitedMsg struct {
+	host string
+	wait time.Duration
+}
+
+// handleRateLimited shows a busy notice in place of "Loading..." until the
+// retried fetch completes.
+func (m model) handleRateLimited(msg rateLimitedMsg) (tea.Model, tea.Cmd) {
+	if m.loading {
+		m.busy = busyNote(msg.host, msg.wait)
+	}
+	return m, nil
+}
+
+func busyNote(host string, wait time.Duration) string {
+	return fmt.Sprintf("%s is busy, retrying in %s...", host, wait.Round(time.Second))
+}
+
+// retryingMsg reports that the client is making another attempt at a
+// request, and when it will give up: the zero time for never.
+type retryingMsg struct {
+	host     string
+	attempt  int
+	deadline time.Time
+}
+
+// handleRetrying shows a retry notice in place of "Loading..." until the
+// fetch completes. First attempts are not worth a notice.
+func (m model) handleRetrying(msg retryingMsg) (tea.Model, tea.Cmd) {
+	if m.loading && msg.attempt > 1 {
+		m.busy = retryNote(msg.host, msg.attempt, msg.deadline)
+	}
+	return m, nil
+}
+
+func retryNote(host string, attempt int, deadline time.Time) string {
+	note := fmt.Sprintf("Retrying %s (attempt %d", host, attempt)
+	if !deadline.IsZero() {
+		note += fmt.Sprintf(", giving up in %s", max(time.Until(deadline), 0).Round(time.Second))
+	}
+	return note + ")..."
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/latebit/demarkus/client/internal/fetch"
+	"github.com/latebit/demarkus/protocol"
+)
+
+func TestRateLimitedNotice(t *testing.T) {
+	m := model{loading: true, fetchSeq: 1, histIdx: -1}
+	next, _ := m.handleRateLimited(rateLimitedMsg{host: "h:6309", wait: 5 * time.Second})
+	m = next.(model)
+	if got := m.statusBarView(); !strings.Contains(got, "h:6309 is busy, retrying in 5s") {
+		t.Errorf("status bar = %q, want busy notice", got)
+	}
+
+	next, _ = m.handleFetchResult(fetchResult{
+		result: fetch.Result{Response: protocol.Response{Status: protocol.StatusOK}},
+		url:    "mark://h/doc.md",
+		seq:    1,
+	})
+	if m = next.(model); m.busy != "" {
+		t.Errorf("busy = %q after the fetch completed, want empty", m.busy)
+	}
+
+	// A notice arriving after the fetch finished (e.g. from a graph crawl)
+	// is not shown.
+	next, _ = m.handleRateLimited(rateLimitedMsg{host: "h:6309", wait: time.Second})
+	if m = next.(model); m.busy != "" {
+		t.Errorf("busy = %q while idle, want empty", m.busy)
+	}
+}
+
+func TestRetryingNotice(t *testing.T) {
+	m := model{loading: true, fetchSeq: 1, histIdx: -1}
+	next, _ := m.handleRetrying(retryingMsg{host: "h:6309", attempt: 1, deadline: time.Now().Add(10 * time.Second)})
+	if m = next.(model); m.busy != "" {
+		t.Errorf("busy = %q on the first attempt, want empty", m.busy)
+	}
+	next, _ = m.handleRetrying(retryingMsg{host: "h:6309", attempt: 2, deadline: time.Now().Add(7*time.Second + 100*time.Millisecond)})
+	m = next.(model)
+	if got := m.statusBarView(); !strings.Contains(got, "Retrying h:6309 (attempt 2, giving up in 7s)") {
+		t.Errorf("status bar = %q, want retry notice", got)
+	}
+	if got := retryNote("h:6309", 3, time.Time{}); got != "Retrying h:6309 (attempt 3)..." {
+		t.Errorf("retryNote without deadline = %q", got)
+	}
+}
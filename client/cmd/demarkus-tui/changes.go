@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/latebit/demarkus/client/internal/cache"
+	"github.com/latebit/demarkus/client/internal/diff"
+	"github.com/latebit/demarkus/client/internal/links"
+)
+
+// changesContext is the number of unchanged lines shown around each change.
+const changesContext = 3
+
+// handleRefresh fetches the current page again. If it changed since the
+// cached copy, the status bar offers the changes.
+func (m model) handleRefresh() (tea.Model, tea.Cmd) {
+	url := m.addressBar.Value()
+	if url == "" || isLocalPage(m.status) {
+		return m, nil
+	}
+	m.loading = true
+	m.fetchSeq++
+	m.err = nil
+	return m, m.doFetch(url)
+}
+
+// handleShowChanges shows the differences between the cached copy the
+// last fetch replaced and the current page.
+func (m model) handleShowChanges() (tea.Model, tea.Cmd) {
+	if m.previous == nil || isLocalPage(m.status) {
+		return m, nil
+	}
+	return m.showLocalPage(pageChanges, renderChanges(m.addressBar.Value(), m.previous, m.metadata, m.rawBody)), nil
+}
+
+// renderChanges returns a markdown page with a unified diff from the
+// cached copy old to the current body.
+func renderChanges(url string, old *cache.Entry, meta map[string]string, body string) string {
+	title := links.ExtractTitle(body)
+	if title == "" {
+		title = url
+	}
+	var sb strings.Builder
+	sb.WriteString("# Changes\n\n")
+	fmt.Fprintf(&sb, "[%s](%s): cached copy", escapeLinkText(title), url)
+	if v := old.Response.Metadata["version"]; v != "" {
+		fmt.Fprintf(&sb, " v%s", v)
+	}
+	if !old.CachedAt.IsZero() {
+		fmt.Fprintf(&sb, " from %s", old.CachedAt.Format(time.DateTime))
+	}
+	sb.WriteString(" → current")
+	if v := meta["version"]; v != "" {
+		fmt.Fprintf(&sb, " v%s", v)
+	}
+	sb.WriteString(".\n\n")
+
+	// The fence must be longer than any backtick run in the diff.
+	d := diff.Unified(old.Response.Body, body, changesContext)
+	fence := "```"
+	for strings.Contains(d, fence) {
+		fence += "`"
+	}
+	fmt.Fprintf(&sb, "%sdiff\n%s%s\n", fence, d, fence)
+	return sb.String()
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/latebit/demarkus/client/internal/cache"
+	"github.com/latebit/demarkus/client/internal/fetch"
+	"github.com/latebit/demarkus/protocol"
+)
+
+func TestShowChanges(t *testing.T) {
+	const url = "mark://h/news.md"
+	m := model{addressBar: textinput.New(), focus: focusViewport, histIdx: -1, linkIdx: -1}
+	m.addressBar.SetValue(url)
+
+	old := &cache.Entry{
+		Response: protocol.Response{Status: protocol.StatusOK, Metadata: map[string]string{"version": "1"}, Body: "# News\n\nOld item.\n"},
+		CachedAt: time.Date(2026, 3, 5, 9, 0, 0, 0, time.UTC),
+	}
+	next, cmd := m.handleKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r")})
+	m = next.(model)
+	if !m.loading || cmd == nil {
+		t.Fatal("refresh did not fetch")
+	}
+	next, _ = m.handleFetchResult(fetchResult{url: url, seq: m.fetchSeq, result: fetch.Result{
+		Response: protocol.Response{Status: protocol.StatusOK, Metadata: map[string]string{"version": "2"}, Body: "# News\n\nNew item.\n"},
+		Previous: old,
+	}})
+	m = next.(model)
+	if !strings.Contains(m.statusBarView(), "c: show changes") {
+		t.Errorf("status bar = %q", m.statusBarView())
+	}
+
+	next, _ = m.handleKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("c")})
+	m = next.(model)
+	if m.status != pageChanges {
+		t.Fatalf("status = %q, want %q", m.status, pageChanges)
+	}
+	for _, want := range []string{"cached copy v1 from 2026-03-05 09:00:00 → current v2", "-Old item.\n+New item.\n"} {
+		if !strings.Contains(m.rawBody, want) {
+			t.Errorf("changes page missing %q:\n%s", want, m.rawBody)
+		}
+	}
+
+	// Back on the page, the changes are still on offer.
+	next, _ = m.handleKey(tea.KeyMsg{Type: tea.KeyEscape})
+	m = next.(model)
+	if m.status != protocol.StatusOK || m.previous != old {
+		t.Errorf("after Esc: status %q, previous %v", m.status, m.previous)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// compact reports whether the terminal is narrower than compactWidth or
+// shorter than compactHeight, in which case the TUI drops the address bar
+// and divider, shows the page's URL in the status line instead, and trims
+// padding and metadata. A zero threshold is never crossed.
+func (m model) compact() bool {
+	return (m.compactWidth > 0 && m.width < m.compactWidth) ||
+		(m.compactHeight > 0 && m.height < m.compactHeight)
+}
+
+// padding is the number of columns left blank at either end of the
+// address bar, status bar and banners.
+func (m model) padding() int {
+	if m.compact() {
+		return 0
+	}
+	return 1
+}
+
+// contentWidth is the width pages are wrapped at.
+func (m model) contentWidth() int {
+	if m.compact() {
+		return max(m.width-2, 1)
+	}
+	return m.width - 4
+}
+
+// compactView lays the screen out for a small terminal: banner, content,
+// and one line that is the address bar while it has focus and the status
+// bar otherwise.
+func (m model) compactView() string {
+	var b strings.Builder
+	if banner := m.integrityBanner(); banner != "" {
+		b.WriteString(banner)
+		b.WriteByte('\n')
+	}
+	b.WriteString(m.viewport.View())
+	b.WriteByte('\n')
+	if m.focus == focusAddressBar {
+		b.WriteString(lipgloss.NewStyle().Width(m.width).MaxHeight(1).Bold(true).Render(m.addressBar.View()))
+	} else {
+		b.WriteString(m.statusBarView())
+	}
+	return b.String()
+}
+
+// shortURL abbreviates url to at most width columns for the compact
+// status line, dropping the scheme and then the start of the host, so the
+// document's name stays visible.
+func shortURL(url string, width int) string {
+	url = strings.TrimPrefix(url, "mark://")
+	r := []rune(url)
+	if width < 1 || len(r) <= width {
+		return url
+	}
+	return "…" + string(r[len(r)-width+1:])
+}
+
+// shortDate reduces an RFC 3339 timestamp to its date.
+func shortDate(ts string) string {
+	if len(ts) > len("2006-01-02") && ts[len("2006-01-02")] == 'T' {
+		return ts[:len("2006-01-02")]
+	}
+	return ts
+}
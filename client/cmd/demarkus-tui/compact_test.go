@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/latebit/demarkus/client/internal/fetch"
+	"github.com/latebit/demarkus/protocol"
+)
+
+func TestCompactLayout(t *testing.T) {
+	m := model{addressBar: textinput.New(), histIdx: -1, linkIdx: -1, compactWidth: 90, compactHeight: 30}
+	next, _ := m.handleWindowSize(tea.WindowSizeMsg{Width: 80, Height: 24})
+	m = next.(model)
+	if !m.compact() {
+		t.Fatal("80x24 should be compact")
+	}
+	m.addressBar.SetValue("mark://docs.example.com:6309/guide/setup.md")
+	next, _ = m.handleFetchResult(fetchResult{
+		result: fetch.Result{Response: protocol.Response{
+			Status: protocol.StatusOK,
+			Metadata: map[string]string{
+				"version":      "3",
+				"modified":     "2026-10-12T09:30:00Z",
+				"word-count":   "1250",
+				"reading-time": "7",
+			},
+			Body: "# Setup\n",
+		}},
+		url: "mark://docs.example.com:6309/guide/setup.md",
+	})
+	m = next.(model)
+
+	if m.viewport.Height != 23 {
+		t.Errorf("viewport height = %d, want 23", m.viewport.Height)
+	}
+	view := m.View()
+	if strings.Contains(view, "───") {
+		t.Errorf("compact view has a divider:\n%s", view)
+	}
+	status := m.statusBarView()
+	for _, want := range []string{"docs.example.com:6309/guide/setup.md", "v3", "2026-10-12"} {
+		if !strings.Contains(status, want) {
+			t.Errorf("status line %q lacks %q", status, want)
+		}
+	}
+	for _, unwanted := range []string{"[ok]", "T09:30", "words"} {
+		if strings.Contains(status, unwanted) {
+			t.Errorf("status line %q has %q", status, unwanted)
+		}
+	}
+
+	// Clicking the status line edits the address, in the status line.
+	next, _ = m.handleMouse(tea.MouseMsg{X: 3, Y: 23, Action: tea.MouseActionPress, Button: tea.MouseButtonLeft})
+	m = next.(model)
+	if m.focus != focusAddressBar {
+		t.Fatal("click on the status line should focus the address bar")
+	}
+	lines := strings.Split(m.View(), "\n")
+	if last := lines[len(lines)-1]; !strings.Contains(last, "mark://docs.example.com") {
+		t.Errorf("last line = %q, want the address bar", last)
+	}
+
+	// A larger terminal gets the full layout back.
+	next, _ = m.handleWindowSize(tea.WindowSizeMsg{Width: 120, Height: 40})
+	m = next.(model)
+	if m.compact() || m.viewport.Height != 37 {
+		t.Errorf("compact = %v, viewport height = %d at 120x40", m.compact(), m.viewport.Height)
+	}
+}
+
+func TestShortURL(t *testing.T) {
+	tests := []struct {
+		url   string
+		width int
+		want  string
+	}{
+		{"mark://h:6309/doc.md", 40, "h:6309/doc.md"},
+		{"mark://docs.example.com:6309/guide/setup.md", 16, "…/guide/setup.md"},
+		{"mark://h/doc.md", 1, "…"},
+	}
+	for _, tt := range tests {
+		if got := shortURL(tt.url, tt.width); got != tt.want {
+			t.Errorf("shortURL(%q, %d) = %q, want %q", tt.url, tt.width, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/latebit/demarkus/client/internal/fetch"
+)
+
+// crossHostTarget reports the host of target when it differs from the host
+// of currentURL. Both URLs must be mark:// URLs; anything else (including a
+// blank current URL, e.g. on the bookmarks page) is not considered a
+// cross-host navigation. Hosts are compared after default-port
+// normalisation so mark://h and mark://h:6309 are the same host.
+func crossHostTarget(currentURL, target string) (string, bool) {
+	if currentURL == "" {
+		return "", false
+	}
+	curHost, _, err := fetch.ParseMarkURL(currentURL)
+	if err != nil {
+		return "", false
+	}
+	targetHost, _, err := fetch.ParseMarkURL(target)
+	if err != nil {
+		return "", false
+	}
+	if curHost == targetHost {
+		return "", false
+	}
+	return targetHost, true
+}
+
+// crossHostPrompt formats the status bar confirmation for a pending link.
+func crossHostPrompt(host string) string {
+	return fmt.Sprintf("Follow link to another host %s? [y/N]", host)
+}
+
+// handleCrossHostConfirm resolves a pending cross-host link: y follows it,
+// any other key cancels.
+func (m model) handleCrossHostConfirm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	target := m.pendingLink
+	m.pendingLink = ""
+	m.pendingLinkHost = ""
+	if msg.String() != "y" && msg.String() != "Y" {
+		return m, nil
+	}
+	return m.navigateTo(target)
+}
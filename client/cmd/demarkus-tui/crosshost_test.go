@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/latebit/demarkus/client/internal/fetch"
+	"github.com/latebit/demarkus/protocol"
+)
+
+func TestCrossHostTarget(t *testing.T) {
+	tests := []struct {
+		current, target string
+		wantHost        string
+		wantOK          bool
+	}{
+		{"mark://a.example/index.md", "mark://a.example/doc.md", "", false},
+		{"mark://a.example/index.md", "mark://a.example:6309/doc.md", "", false},
+		{"mark://a.example/index.md", "mark://b.example/doc.md", "b.example:6309", true},
+		{"mark://a.example:7000/", "mark://a.example/doc.md", "a.example:6309", true},
+		{"", "mark://b.example/doc.md", "", false},
+		{"mark://a.example/", "https://b.example/", "", false},
+	}
+	for _, tt := range tests {
+		host, ok := crossHostTarget(tt.current, tt.target)
+		if host != tt.wantHost || ok != tt.wantOK {
+			t.Errorf("crossHostTarget(%q, %q) = %q, %v; want %q, %v",
+				tt.current, tt.target, host, ok, tt.wantHost, tt.wantOK)
+		}
+	}
+}
+
+func TestHandleCrossHostConfirm(t *testing.T) {
+	pending := model{
+		linkIdx:         -1,
+		pendingLink:     "mark://b.example/doc.md",
+		pendingLinkHost: "b.example:6309",
+	}
+
+	next, cmd := pending.handleCrossHostConfirm(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'n'}})
+	got := next.(model)
+	if got.pendingLink != "" || cmd != nil || got.loading {
+		t.Errorf("n should cancel: pending=%q loading=%v cmd=%v", got.pendingLink, got.loading, cmd != nil)
+	}
+
+	next, cmd = pending.handleCrossHostConfirm(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'y'}})
+	got = next.(model)
+	if got.pendingLink != "" || cmd == nil || !got.loading {
+		t.Errorf("y should follow: pending=%q loading=%v cmd=%v", got.pendingLink, got.loading, cmd != nil)
+	}
+	if got.addressBar.Value() != "mark://b.example/doc.md" {
+		t.Errorf("address bar = %q, want %q", got.addressBar.Value(), "mark://b.example/doc.md")
+	}
+}
+
+func TestCrossHostRedirectConfirm(t *testing.T) {
+	m := model{addressBar: textinput.New(), histIdx: -1, linkIdx: -1, confirmCrossHost: true}
+	next, _ := m.handleFetchResult(fetchResult{
+		result: fetch.Result{
+			Response: protocol.Response{Status: protocol.StatusMoved, Metadata: map[string]string{"location": "mark://b.example/doc.md"}},
+			URL:      "mark://a.example:6309/old.md",
+		},
+		url: "mark://a.example/old.md",
+	})
+	got := next.(model)
+	if got.pendingLink != "mark://b.example:6309/doc.md" || got.pendingLinkHost != "b.example:6309" {
+		t.Errorf("pending = %q (%q), want the redirect target", got.pendingLink, got.pendingLinkHost)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/latebit/demarkus/client/internal/fetch"
+	"github.com/latebit/demarkus/client/internal/links"
+	"github.com/latebit/demarkus/protocol"
+)
+
+// truncatedNote is the line a server ends a directory listing with when
+// entries follow.
+const truncatedNote = "*...truncated, too many entries*"
+
+// dirPageResult is the answer to a LIST for the next page of the directory
+// listing on screen, or for the listing in another order.
+type dirPageResult struct {
+	url    string
+	seq    uint64
+	result fetch.Result
+	err    error
+	more   bool   // append the entries to the listing rather than replace it
+	sort   string // the order asked for
+}
+
+// isDirListing reports whether the page is a listing the server generated
+// for a directory, rather than the directory's index.md.
+func isDirListing(url string, meta map[string]string) bool {
+	_, ok := meta["entries"]
+	return ok && strings.HasSuffix(url, "/")
+}
+
+// loadMoreEntries asks for the entries that follow the directory listing
+// on screen, in the same order.
+func (m model) loadMoreEntries() (tea.Model, tea.Cmd) {
+	url := m.addressBar.Value()
+	next, err := strconv.Atoi(m.metadata["next-offset"])
+	if !isDirListing(url, m.metadata) || err != nil || next <= 0 {
+		return m, nil
+	}
+	m.busy = "Loading more entries..."
+	return m, m.dirPageCmd(url, fetch.ListOptions{Offset: next, Sort: m.dirSort}, true)
+}
+
+// toggleDirSort lists the directory on screen again, switching between
+// name order and most recently modified first.
+func (m model) toggleDirSort() (tea.Model, tea.Cmd) {
+	url := m.addressBar.Value()
+	if !isDirListing(url, m.metadata) {
+		return m, nil
+	}
+	sort := protocol.SortModified
+	if m.dirSort == protocol.SortModified {
+		sort = protocol.SortName
+	}
+	m.busy = "Sorting by " + sort + "..."
+	return m, m.dirPageCmd(url, fetch.ListOptions{Sort: sort}, false)
+}
+
+func (m model) dirPageCmd(url string, opts fetch.ListOptions, more bool) tea.Cmd {
+	seq := m.fetchSeq
+	client := m.client
+	return func() tea.Msg {
+		host, path, err := fetch.ParseMarkURL(url)
+		if err != nil {
+			return dirPageResult{url: url, seq: seq, err: err}
+		}
+		r, err := client.ListPage(host, path, opts)
+		return dirPageResult{url: url, seq: seq, result: r, err: err, more: more, sort: opts.Sort}
+	}
+}
+
+// handleDirPage shows a page of a directory listing, unless the user has
+// moved on since asking for it.
+func (m model) handleDirPage(msg dirPageResult) (tea.Model, tea.Cmd) {
+	if msg.seq != m.fetchSeq || msg.url != m.addressBar.Value() {
+		return m, nil
+	}
+	m.busy = ""
+	if msg.err == nil {
+		msg.err = msg.result.Err()
+	}
+	if msg.err != nil {
+		return m.flash("Listing failed: " + msg.err.Error())
+	}
+
+	meta := make(map[string]string, len(m.metadata))
+	for k, v := range m.metadata {
+		meta[k] = v
+	}
+	delete(meta, "next-offset")
+	if next := msg.result.Response.Metadata["next-offset"]; next != "" {
+		meta["next-offset"] = next
+	}
+	body := msg.result.Response.Body
+	if msg.more {
+		body = appendListing(m.rawBody, body)
+		shown, _ := strconv.Atoi(m.metadata["entries"])
+		added, _ := strconv.Atoi(msg.result.Response.Metadata["entries"])
+		meta["entries"] = strconv.Itoa(shown + added)
+	} else {
+		meta["entries"] = msg.result.Response.Metadata["entries"]
+		m.dirSort = msg.sort
+	}
+	m.metadata = meta
+	m.rawBody = body
+	m.links = m.links[:0:0]
+	for _, dest := range links.Extract(body) {
+		m.links = append(m.links, links.Resolve(msg.url, dest))
+	}
+	m.linkIdx = -1
+
+	rendered := body
+	if r, err := m.renderPage(msg.url, body); err == nil {
+		rendered = r
+	}
+	if m.ready {
+		offset := m.viewport.YOffset
+		m.viewport.SetContent(rendered)
+		if msg.more {
+			m.viewport.SetYOffset(offset)
+		} else {
+			m.viewport.GotoTop()
+		}
+	}
+	if m.histIdx >= 0 && m.histIdx < len(m.history) && m.history[m.histIdx].url == msg.url {
+		e := &m.history[m.histIdx]
+		e.rendered, e.rawBody, e.metadata, e.links, e.dirSort = rendered, body, meta, m.links, m.dirSort
+	}
+	return m, nil
+}
+
+// appendListing returns the markdown listing body followed by the entries
+// of page, a listing of the next entries, ending with the note that more
+// follow if page has it.
+func appendListing(body, page string) string {
+	body = strings.TrimRight(strings.TrimSuffix(strings.TrimRight(body, "\n"), truncatedNote), "\n")
+	var b strings.Builder
+	b.WriteString(body)
+	b.WriteString("\n")
+	truncated := false
+	for line := range strings.SplitSeq(page, "\n") {
+		switch {
+		case strings.HasPrefix(line, "- ["):
+			b.WriteString(line + "\n")
+		case strings.TrimSpace(line) == truncatedNote:
+			truncated = true
+		}
+	}
+	if truncated {
+		b.WriteString("\n" + truncatedNote + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/latebit/demarkus/client/internal/fetch"
+	"github.com/latebit/demarkus/protocol"
+)
+
+func dirModel(url string, meta map[string]string, body string) model {
+	m := model{
+		addressBar: textinput.New(),
+		focus:      focusViewport,
+		histIdx:    -1,
+		linkIdx:    -1,
+		status:     protocol.StatusOK,
+		metadata:   meta,
+		rawBody:    body,
+	}
+	m.addressBar.SetValue(url)
+	return m
+}
+
+func TestAppendListing(t *testing.T) {
+	first := "# Index of /docs/\n\n- [a.md](a.md)\n- [b.md](b.md)\n\n" + truncatedNote + "\n"
+	second := "# Index of /docs/\n\n- [c.md](c.md)\n"
+
+	got := appendListing(first, second)
+	want := "# Index of /docs/\n\n- [a.md](a.md)\n- [b.md](b.md)\n- [c.md](c.md)\n"
+	if got != want {
+		t.Errorf("appendListing = %q, want %q", got, want)
+	}
+
+	got = appendListing(first, second+"\n"+truncatedNote+"\n")
+	if !strings.HasSuffix(got, "- [c.md](c.md)\n\n"+truncatedNote+"\n") {
+		t.Errorf("note not kept at the end: %q", got)
+	}
+	if strings.Count(got, truncatedNote) != 1 {
+		t.Errorf("note repeated: %q", got)
+	}
+}
+
+func TestIsDirListing(t *testing.T) {
+	meta := map[string]string{"entries": "2"}
+	if !isDirListing("mark://h/docs/", meta) {
+		t.Error("listing of /docs/ not recognised")
+	}
+	if isDirListing("mark://h/docs/index.md", meta) {
+		t.Error("document recognised as a listing")
+	}
+	if isDirListing("mark://h/docs/", map[string]string{"version": "1"}) {
+		t.Error("index.md served for a directory recognised as a listing")
+	}
+}
+
+func TestHandleDirPage(t *testing.T) {
+	const url = "mark://h/docs/"
+	first := "# Index of /docs/\n\n- [a.md](a.md)\n\n" + truncatedNote + "\n"
+
+	t.Run("more entries", func(t *testing.T) {
+		m := dirModel(url, map[string]string{"entries": "1", "next-offset": "1"}, first)
+		next, _ := m.handleDirPage(dirPageResult{
+			url:  url,
+			more: true,
+			result: fetch.Result{Response: protocol.Response{
+				Status:   protocol.StatusOK,
+				Metadata: map[string]string{"entries": "1"},
+				Body:     "# Index of /docs/\n\n- [b.md](b.md)\n",
+			}},
+		})
+		m = next.(model)
+		if !strings.Contains(m.rawBody, "- [a.md](a.md)\n- [b.md](b.md)\n") {
+			t.Errorf("body = %q", m.rawBody)
+		}
+		if strings.Contains(m.rawBody, truncatedNote) {
+			t.Error("truncated note kept after the last page")
+		}
+		if m.metadata["entries"] != "2" {
+			t.Errorf("entries = %q, want 2", m.metadata["entries"])
+		}
+		if _, ok := m.metadata["next-offset"]; ok {
+			t.Error("next-offset kept after the last page")
+		}
+		if len(m.links) != 2 || !strings.HasSuffix(m.links[1], "/docs/b.md") {
+			t.Errorf("links = %v", m.links)
+		}
+	})
+
+	t.Run("sorted", func(t *testing.T) {
+		m := dirModel(url, map[string]string{"entries": "1", "next-offset": "1"}, first)
+		next, _ := m.handleDirPage(dirPageResult{
+			url:  url,
+			sort: protocol.SortModified,
+			result: fetch.Result{Response: protocol.Response{
+				Status:   protocol.StatusOK,
+				Metadata: map[string]string{"entries": "1", "next-offset": "1"},
+				Body:     "# Index of /docs/\n\n- [b.md](b.md)\n\n" + truncatedNote + "\n",
+			}},
+		})
+		m = next.(model)
+		if m.dirSort != protocol.SortModified {
+			t.Errorf("dirSort = %q", m.dirSort)
+		}
+		if strings.Contains(m.rawBody, "a.md") {
+			t.Errorf("listing not replaced: %q", m.rawBody)
+		}
+		if m.metadata["next-offset"] != "1" {
+			t.Errorf("next-offset = %q, want 1", m.metadata["next-offset"])
+		}
+	})
+
+	t.Run("stale", func(t *testing.T) {
+		m := dirModel(url, map[string]string{"entries": "1"}, first)
+		m.fetchSeq = 2
+		next, _ := m.handleDirPage(dirPageResult{url: url, seq: 1, more: true, err: errors.New("late")})
+		if next.(model).rawBody != first || next.(model).bookmarkMsg != "" {
+			t.Error("stale result applied")
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		m := dirModel(url, map[string]string{"entries": "1"}, first)
+		next, _ := m.handleDirPage(dirPageResult{url: url, err: errors.New("refused")})
+		m = next.(model)
+		if m.rawBody != first || !strings.Contains(m.bookmarkMsg, "refused") {
+			t.Errorf("rawBody = %q, message = %q", m.rawBody, m.bookmarkMsg)
+		}
+	})
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/latebit/demarkus/client/internal/graph"
+	"github.com/latebit/demarkus/protocol"
+)
+
+// graphFilter restricts the graph view to nodes with certain statuses.
+type graphFilter int
+
+const (
+	filterAll      graphFilter = iota
+	filterBroken               // fetched but not ok: not-found, error, archived, ...
+	filterExternal             // not fetched: other schemes and off-host links
+)
+
+func (f graphFilter) String() string {
+	switch f {
+	case filterBroken:
+		return "broken"
+	case filterExternal:
+		return "external"
+	default:
+		return "all"
+	}
+}
+
+// next cycles all → broken → external → all.
+func (f graphFilter) next() graphFilter {
+	return (f + 1) % 3
+}
+
+func isExternalStatus(status string) bool {
+	return status == "external" || status == graph.StatusOffHost
+}
+
+func (f graphFilter) matches(status string) bool {
+	switch f {
+	case filterBroken:
+		return status != protocol.StatusOK && !isExternalStatus(status)
+	case filterExternal:
+		return isExternalStatus(status)
+	default:
+		return true
+	}
+}
+
+// visibleGraphItems applies collapsing and then filter to items. Nodes
+// whose parent chain includes a collapsed node are hidden, and the
+// outermost collapsed ancestor counts them in its hidden field.
+func visibleGraphItems(items []graphListItem, filter graphFilter, collapsed map[string]bool) []graphListItem {
+	parents := make(map[string]string, len(items))
+	for _, it := range items {
+		parents[it.url] = it.parent
+	}
+
+	// outermostCollapsed returns the collapsed ancestor closest to the
+	// root, or "" if none. The seen set guards against malformed chains.
+	outermostCollapsed := func(url string) string {
+		found := ""
+		seen := map[string]bool{url: true}
+		for p := parents[url]; p != "" && !seen[p]; p = parents[p] {
+			seen[p] = true
+			if collapsed[p] {
+				found = p
+			}
+		}
+		return found
+	}
+
+	hidden := map[string]int{}
+	shown := make([]graphListItem, 0, len(items))
+	for _, it := range items {
+		if anc := outermostCollapsed(it.url); anc != "" {
+			hidden[anc]++
+			continue
+		}
+		shown = append(shown, it)
+	}
+
+	out := shown[:0]
+	for _, it := range shown {
+		it.hidden = hidden[it.url]
+		if filter.matches(it.status) {
+			out = append(out, it)
+		}
+	}
+	return out
+}
+
+// toggleCollapsed flips url in set, allocating the set on first use.
+func toggleCollapsed(set map[string]bool, url string) map[string]bool {
+	if set == nil {
+		set = map[string]bool{}
+	}
+	if set[url] {
+		delete(set, url)
+	} else {
+		set[url] = true
+	}
+	return set
+}
+
+// graphLegend explains the status icons and markers used in graph views.
+func graphLegend() string {
+	rows := []struct{ mark, meaning string }{
+		{statusIcon(protocol.StatusOK), "ok"},
+		{statusIcon(protocol.StatusNotFound), "not found or other non-ok status"},
+		{statusIcon("error"), "fetch error"},
+		{statusIcon("external"), "external link (not mark://)"},
+		{statusIcon(graph.StatusOffHost), "off-host mark:// link (not crawled)"},
+		{"[3←]", "documents linking here"},
+		{"[+5]", "collapsed: hidden descendants"},
+	}
+	var b strings.Builder
+	b.WriteString("\n  Legend\n")
+	for _, r := range rows {
+		b.WriteString("    " + r.mark + "  " + r.meaning + "\n")
+	}
+	return b.String()
+}
+
+// visibleGraphNodes returns the graph items currently on screen.
+func (m model) visibleGraphNodes() []graphListItem {
+	return visibleGraphItems(m.graphNodes, m.graphFilter, m.graphCollapsed)
+}
+
+// selectedGraphItem returns the item under the cursor.
+func (m model) selectedGraphItem() (graphListItem, bool) {
+	items := m.visibleGraphNodes()
+	if m.graphIdx < 0 || m.graphIdx >= len(items) {
+		return graphListItem{}, false
+	}
+	return items[m.graphIdx], true
+}
+
+// showBacklinks switches to the backlinks of url.
+func (m model) showBacklinks(url string) model {
+	m.graphSubView = subViewBacklinks
+	m.graphBacklinksOf = url
+	m.graphNodes = backlinksList(m.graphStore, url)
+	m.graphIdx = 0
+	if m.ready {
+		m.viewport.SetContent(m.renderCurrentGraphSubView())
+		m.viewport.GotoTop()
+	}
+	return m
+}
+
+// refreshGraphView re-renders after a filter, collapse or legend change,
+// keeping the cursor on screen.
+func (m model) refreshGraphView() model {
+	m.graphIdx = max(0, min(m.graphIdx, len(m.visibleGraphNodes())-1))
+	if m.ready {
+		m.viewport.SetContent(m.renderCurrentGraphSubView())
+	}
+	return m
+}
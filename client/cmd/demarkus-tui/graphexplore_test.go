@@ -0,0 +1,123 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/latebit/demarkus/client/internal/graph"
+)
+
+// exploreItems is a small tree: a → b → d, a → c, plus an external link.
+func exploreItems() []graphListItem {
+	return []graphListItem{
+		{url: "mark://h/a.md", status: "ok"},
+		{url: "mark://h/b.md", status: "ok", parent: "mark://h/a.md", depth: 1},
+		{url: "mark://h/c.md", status: "not-found", parent: "mark://h/a.md", depth: 1},
+		{url: "https://x.example", status: "external", parent: "mark://h/a.md", depth: 1},
+		{url: "mark://h/d.md", status: "error", parent: "mark://h/b.md", depth: 2},
+	}
+}
+
+func urls(items []graphListItem) string {
+	var out []string
+	for _, it := range items {
+		out = append(out, it.url)
+	}
+	return strings.Join(out, " ")
+}
+
+func TestFlattenGraphParents(t *testing.T) {
+	g := graph.New()
+	g.AddNode(&graph.Node{URL: "mark://host/a.md", Status: "ok"})
+	g.AddNode(&graph.Node{URL: "mark://host/b.md", Status: "ok", Depth: 1})
+	g.AddNode(&graph.Node{URL: "mark://host/c.md", Status: "ok", Depth: 2})
+	g.AddEdge("mark://host/a.md", "mark://host/b.md")
+	g.AddEdge("mark://host/b.md", "mark://host/c.md")
+
+	items := flattenGraph(g, "mark://host/a.md")
+	want := map[string]string{"mark://host/a.md": "", "mark://host/b.md": "mark://host/a.md", "mark://host/c.md": "mark://host/b.md"}
+	for _, it := range items {
+		if it.parent != want[it.url] {
+			t.Errorf("%s: parent = %q, want %q", it.url, it.parent, want[it.url])
+		}
+	}
+}
+
+func TestVisibleGraphItemsFilter(t *testing.T) {
+	tests := []struct {
+		filter graphFilter
+		want   string
+	}{
+		{filterAll, "mark://h/a.md mark://h/b.md mark://h/c.md https://x.example mark://h/d.md"},
+		{filterBroken, "mark://h/c.md mark://h/d.md"},
+		{filterExternal, "https://x.example"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter.String(), func(t *testing.T) {
+			if got := urls(visibleGraphItems(exploreItems(), tt.filter, nil)); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+	if filterExternal.next() != filterAll {
+		t.Error("filter should cycle back to all")
+	}
+}
+
+func TestVisibleGraphItemsCollapse(t *testing.T) {
+	// Collapsing b hides d and counts it on b.
+	got := visibleGraphItems(exploreItems(), filterAll, map[string]bool{"mark://h/b.md": true})
+	if urls(got) != "mark://h/a.md mark://h/b.md mark://h/c.md https://x.example" {
+		t.Fatalf("got %q", urls(got))
+	}
+	if got[1].hidden != 1 {
+		t.Errorf("b.hidden = %d, want 1", got[1].hidden)
+	}
+
+	// Nested collapses count toward the outermost visible node.
+	got = visibleGraphItems(exploreItems(), filterAll, map[string]bool{"mark://h/a.md": true, "mark://h/b.md": true})
+	if len(got) != 1 || got[0].hidden != 4 {
+		t.Errorf("got %+v, want only a with 4 hidden", got)
+	}
+
+	out := renderGraphView(visibleGraphItems(exploreItems(), filterAll, map[string]bool{"mark://h/b.md": true}), 0, 120)
+	if !strings.Contains(out, "[+1]") {
+		t.Error("collapsed node should show its hidden count")
+	}
+}
+
+func TestGraphExploreKeys(t *testing.T) {
+	key := func(m model, k string) model {
+		next, _ := m.handleGraphKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)})
+		return next.(model)
+	}
+	m := model{viewMode: viewGraph, graphNodes: exploreItems(), histIdx: -1}
+
+	m = key(m, "f")
+	if m.graphFilter != filterBroken || len(m.visibleGraphNodes()) != 2 {
+		t.Errorf("after f: filter %v, %d visible; want broken, 2", m.graphFilter, len(m.visibleGraphNodes()))
+	}
+	m.graphFilter = filterAll
+
+	m.graphIdx = 1 // b.md
+	m = key(m, "c")
+	if !m.graphCollapsed["mark://h/b.md"] || len(m.visibleGraphNodes()) != 4 {
+		t.Errorf("after c: collapsed %v, %d visible", m.graphCollapsed, len(m.visibleGraphNodes()))
+	}
+	m = key(m, "c")
+	if m.graphCollapsed["mark://h/b.md"] {
+		t.Error("second c should expand")
+	}
+
+	m = key(m, "l")
+	if !m.graphLegend || !strings.Contains(m.renderCurrentGraphSubView(), "Legend") {
+		t.Error("l should show the legend")
+	}
+
+	m.graphIdx = 2 // c.md
+	m = key(m, "b")
+	if m.graphSubView != subViewBacklinks || m.graphBacklinksOf != "mark://h/c.md" {
+		t.Errorf("after b: sub-view %v of %q, want backlinks of c.md", m.graphSubView, m.graphBacklinksOf)
+	}
+}
@@ -12,12 +12,14 @@ import (
 	"github.com/latebit/demarkus/client/internal/graphstore"
 )
 
-// viewMode distinguishes between document reading and graph exploration.
+// viewMode distinguishes between document reading, graph exploration and
+// the horizontally scrollable table view.
 type viewMode int
 
 const (
 	viewDocument viewMode = iota
 	viewGraph
+	viewTable
 )
 
 // graphSubView selects which data the graph view displays.
@@ -43,7 +45,9 @@ type graphListItem struct {
 	title     string
 	status    string
 	depth     int
-	backlinks int // inbound link count from the graph
+	backlinks int    // inbound link count from the graph
+	parent    string // URL this node was first reached from in the links view
+	hidden    int    // descendants hidden because this node is collapsed
 }
 
 // maxCrawlNodes caps the number of documents crawled to prevent runaway graphs.
@@ -85,6 +89,7 @@ func flattenGraph(g *graph.Graph, rootURL string) []graphListItem {
 
 	var items []graphListItem
 	visited := map[string]bool{rootURL: true}
+	parents := map[string]string{}
 	queue := []string{rootURL}
 
 	for len(queue) > 0 {
@@ -102,11 +107,13 @@ func flattenGraph(g *graph.Graph, rootURL string) []graphListItem {
 			status:    n.Status,
 			depth:     n.Depth,
 			backlinks: inDegrees[n.URL],
+			parent:    parents[n.URL],
 		})
 
 		for _, neighbor := range g.Neighbors(url) {
 			if !visited[neighbor.URL] {
 				visited[neighbor.URL] = true
+				parents[neighbor.URL] = url
 				queue = append(queue, neighbor.URL)
 			}
 		}
@@ -193,6 +200,9 @@ func renderGraphView(items []graphListItem, selectedIdx, width int) string {
 		if item.backlinks > 0 {
 			density = fmt.Sprintf(" [%d←]", item.backlinks)
 		}
+		if item.hidden > 0 {
+			density += fmt.Sprintf(" [+%d]", item.hidden)
+		}
 
 		line := fmt.Sprintf("%s%s%s%s %s%s", cursor, indent, connector, icon, label, density)
 
@@ -206,6 +216,7 @@ func renderGraphView(items []graphListItem, selectedIdx, width int) string {
 	}
 
 	b.WriteString("\n  [Enter] navigate  [r] backlinks  [t] topology  [d/Esc] close  [q] quit\n")
+	b.WriteString("  [c] collapse  [b] node backlinks  [f] filter  [l] legend\n")
 	return b.String()
 }
 
@@ -231,6 +242,8 @@ func statusIcon(status string) string {
 		return "✗"
 	case "external":
 		return "→"
+	case graph.StatusOffHost:
+		return "⇢"
 	default:
 		return "○"
 	}
@@ -268,6 +281,7 @@ func renderBacklinksView(items []graphListItem, selectedIdx, width int) string {
 	}
 
 	b.WriteString("\n  [Enter] navigate  [d] links  [t] topology  [Esc] close  [q] quit\n")
+	b.WriteString("  [b] node backlinks  [f] filter  [l] legend\n")
 	return b.String()
 }
 
@@ -308,6 +322,7 @@ func renderTopologyView(items []graphListItem, selectedIdx, width int) string {
 	}
 
 	b.WriteString("\n  [Enter] navigate  [d] links  [r] backlinks  [Esc] close  [q] quit\n")
+	b.WriteString("  [b] node backlinks  [f] filter  [l] legend\n")
 	return b.String()
 }
 
@@ -333,7 +348,7 @@ func (m model) handleGraphKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				m.graphIdx = 0
 			}
 			if m.ready {
-				m.viewport.SetContent(renderGraphView(m.graphNodes, m.graphIdx, m.width))
+				m.viewport.SetContent(m.renderCurrentGraphSubView())
 				m.viewport.GotoTop()
 			}
 		} else {
@@ -346,13 +361,10 @@ func (m model) handleGraphKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 	case "r":
-		m.graphSubView = subViewBacklinks
-		url := m.addressBar.Value()
-		m.graphNodes = backlinksList(m.graphStore, url)
-		m.graphIdx = 0
-		if m.ready {
-			m.viewport.SetContent(renderBacklinksView(m.graphNodes, m.graphIdx, m.width))
-			m.viewport.GotoTop()
+		return m.showBacklinks(m.addressBar.Value()), nil
+	case "b":
+		if item, ok := m.selectedGraphItem(); ok {
+			return m.showBacklinks(item.url), nil
 		}
 		return m, nil
 	case "t":
@@ -360,12 +372,24 @@ func (m model) handleGraphKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.graphNodes = topologyList(m.graphStore)
 		m.graphIdx = 0
 		if m.ready {
-			m.viewport.SetContent(renderTopologyView(m.graphNodes, m.graphIdx, m.width))
+			m.viewport.SetContent(m.renderCurrentGraphSubView())
 			m.viewport.GotoTop()
 		}
 		return m, nil
+	case "f":
+		m.graphFilter = m.graphFilter.next()
+		m.graphIdx = 0
+		return m.refreshGraphView(), nil
+	case "c":
+		if item, ok := m.selectedGraphItem(); ok && m.graphSubView == subViewLinks {
+			m.graphCollapsed = toggleCollapsed(m.graphCollapsed, item.url)
+		}
+		return m.refreshGraphView(), nil
+	case "l":
+		m.graphLegend = !m.graphLegend
+		return m.refreshGraphView(), nil
 	case "j", "down":
-		if m.graphIdx < len(m.graphNodes)-1 {
+		if m.graphIdx < len(m.visibleGraphNodes())-1 {
 			m.graphIdx++
 			if m.ready {
 				m.viewport.SetContent(m.renderCurrentGraphSubView())
@@ -381,8 +405,8 @@ func (m model) handleGraphKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 	case "enter":
-		if m.graphIdx >= 0 && m.graphIdx < len(m.graphNodes) {
-			target := m.graphNodes[m.graphIdx].url
+		if item, ok := m.selectedGraphItem(); ok {
+			target := item.url
 			m.viewMode = viewDocument
 			m.addressBar.SetValue(target)
 			m.loading = true
@@ -394,14 +418,23 @@ func (m model) handleGraphKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
-// renderCurrentGraphSubView returns the rendered content for the active sub-view.
+// renderCurrentGraphSubView returns the rendered content for the active
+// sub-view, after filtering and collapsing, with the legend if enabled.
 func (m model) renderCurrentGraphSubView() string {
-	switch m.graphSubView {
-	case subViewBacklinks:
-		return renderBacklinksView(m.graphNodes, m.graphIdx, m.width)
-	case subViewTopology:
-		return renderTopologyView(m.graphNodes, m.graphIdx, m.width)
+	items := m.visibleGraphNodes()
+	var out string
+	switch {
+	case len(items) == 0 && len(m.graphNodes) > 0:
+		out = fmt.Sprintf("\n  No %s nodes in this view.\n\n  [f] change filter  [Esc] close\n", m.graphFilter)
+	case m.graphSubView == subViewBacklinks:
+		out = renderBacklinksView(items, m.graphIdx, m.width)
+	case m.graphSubView == subViewTopology:
+		out = renderTopologyView(items, m.graphIdx, m.width)
 	default:
-		return renderGraphView(m.graphNodes, m.graphIdx, m.width)
+		out = renderGraphView(items, m.graphIdx, m.width)
+	}
+	if m.graphLegend {
+		out += graphLegend()
 	}
+	return out
 }
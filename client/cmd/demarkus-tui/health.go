@@ -0,0 +1,39 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/latebit/demarkus/client/internal/fetch"
+)
+
+// connQuality returns a signal-strength indicator for a host's connection:
+// three bars while requests are fast and reliable, fewer as latency or the
+// share of failed attempts grows. Empty before the first attempt.
+func connQuality(h fetch.Health) string {
+	attempts := h.Requests + h.Failures
+	if attempts == 0 {
+		return ""
+	}
+	failRate := float64(h.Failures) / float64(attempts)
+	bars := 3
+	switch {
+	case h.Requests == 0 || failRate > 0.25 || h.MeanLatency > time.Second:
+		bars = 1
+	case failRate > 0.05 || h.MeanLatency > 300*time.Millisecond:
+		bars = 2
+	}
+	return strings.Join([]string{"▂", "▄", "▆"}[:bars], "") + strings.Repeat("·", 3-bars)
+}
+
+// pageConnQuality is the connection indicator for the current page's host.
+func (m model) pageConnQuality() string {
+	if m.client == nil || isLocalPage(m.status) {
+		return ""
+	}
+	host, _, err := fetch.ParseMarkURL(m.addressBar.Value())
+	if err != nil {
+		return ""
+	}
+	return connQuality(m.client.Health()[host])
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/latebit/demarkus/client/internal/fetch"
+)
+
+func TestConnQuality(t *testing.T) {
+	tests := []struct {
+		name string
+		h    fetch.Health
+		want string
+	}{
+		{"no attempts", fetch.Health{}, ""},
+		{"fast", fetch.Health{Requests: 10, MeanLatency: 20 * time.Millisecond}, "▂▄▆"},
+		{"slow", fetch.Health{Requests: 10, MeanLatency: 500 * time.Millisecond}, "▂▄·"},
+		{"some failures", fetch.Health{Requests: 9, Failures: 1, MeanLatency: 20 * time.Millisecond}, "▂▄·"},
+		{"very slow", fetch.Health{Requests: 10, MeanLatency: 2 * time.Second}, "▂··"},
+		{"only failures", fetch.Health{Failures: 3}, "▂··"},
+	}
+	for _, tt := range tests {
+		if got := connQuality(tt.h); got != tt.want {
+			t.Errorf("%s: connQuality(%+v) = %q, want %q", tt.name, tt.h, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"github.com/latebit/demarkus/client/internal/fetch"
+	"github.com/latebit/demarkus/client/internal/hostprefs"
+)
+
+// prefsFor returns the display preferences of the host url is on. Local
+// pages have none.
+func (m model) prefsFor(url string) hostprefs.Prefs {
+	host, _, err := fetch.ParseMarkURL(url)
+	if err != nil {
+		return hostprefs.Prefs{}
+	}
+	return m.hostPrefs.For(host)
+}
+
+// renderPage renders body, the page at url, as its host's preferences
+// ask: as its markdown source, or wrapped at the host's width when that is
+// narrower than the terminal.
+func (m *model) renderPage(url, body string) (string, error) {
+	prefs := m.prefsFor(url)
+	if prefs.Plain {
+		return body, nil
+	}
+	wrapWidth := m.contentWidth()
+	if prefs.Width > 0 {
+		wrapWidth = min(wrapWidth, prefs.Width)
+	}
+	return m.renderWrapped(body, wrapWidth)
+}
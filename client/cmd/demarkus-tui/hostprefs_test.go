@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/latebit/demarkus/client/internal/hostprefs"
+)
+
+func TestRenderPageHostPrefs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts.toml")
+	prefs := "[\"plain.example.com\"]\nplain = true\n\n[\"narrow.example.com\"]\nwidth = 30\n"
+	if err := os.WriteFile(path, []byte(prefs), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	store, err := hostprefs.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &model{width: 84, hostPrefs: store}
+	body := "# Title\n\n" + strings.Repeat("word ", 30) + "\n"
+
+	got, err := m.renderPage("mark://plain.example.com/ascii.md", body)
+	if err != nil || got != body {
+		t.Errorf("plain host: got %q, %v; want the source", got, err)
+	}
+	got, err = m.renderPage("mark://narrow.example.com/doc.md", body)
+	if err != nil || lipgloss.Width(got) > 30 {
+		t.Errorf("narrow host: widest line %d, want at most 30 (%v)", lipgloss.Width(got), err)
+	}
+	got, err = m.renderPage("mark://other.example.com/doc.md", body)
+	if err != nil || lipgloss.Width(got) <= 30 || got == body {
+		t.Errorf("other host: widest line %d, want the terminal's width (%v)", lipgloss.Width(got), err)
+	}
+
+	// A width wider than the terminal wraps at the terminal.
+	m.width = 24
+	if got, _ := m.renderPage("mark://narrow.example.com/doc.md", body); lipgloss.Width(got) > 20 {
+		t.Errorf("narrow terminal: widest line %d, want at most 20", lipgloss.Width(got))
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/latebit/demarkus/client/internal/cache"
+	"github.com/latebit/demarkus/client/internal/fetch"
+)
+
+// infoPanelKeys lists the response metadata fields shown first in the
+// info panel, in display order. Any other keys follow alphabetically.
+var infoPanelKeys = []string{"etag", "version", "modified", "content-hash", "chain-valid", "content-type"}
+
+// defaultContentType is assumed when a response carries no content-type.
+const defaultContentType = "text/markdown"
+
+// infoPanel renders the full response metadata for the current page.
+// now is passed in so cache ages are deterministic in tests.
+func infoPanel(url, status string, meta map[string]string, redirects []string, fromCache bool, cachedAt, now time.Time) string {
+	var b strings.Builder
+	b.WriteString("\n  Response Metadata\n\n")
+
+	row := func(key, value string) {
+		fmt.Fprintf(&b, "    %-14s %s\n", key, value)
+	}
+	row("url", url)
+	if note := redirectNote(redirects); note != "" {
+		row("", note)
+	}
+	row("status", status)
+
+	for _, key := range infoPanelKeys {
+		value, ok := meta[key]
+		switch {
+		case ok:
+			row(key, value)
+		case key == "content-type":
+			row(key, defaultContentType+" (default)")
+		case key == "chain-valid":
+			row(key, "(not reported)")
+		}
+	}
+
+	var extra []string
+	for key := range meta {
+		if key != "status" && !slices.Contains(infoPanelKeys, key) {
+			extra = append(extra, key)
+		}
+	}
+	slices.Sort(extra)
+	for _, key := range extra {
+		row(key, meta[key])
+	}
+
+	b.WriteString("\n")
+	switch {
+	case !fromCache:
+		row("cache", "fresh from server")
+	case cachedAt.IsZero():
+		row("cache", "served from cache")
+	default:
+		age := now.Sub(cachedAt).Truncate(time.Second)
+		row("cache", fmt.Sprintf("served from cache (age %s)", age))
+	}
+	return b.String()
+}
+
+// showInfoPanel replaces the viewport content with the metadata panel.
+// Pages without a protocol response (e.g. bookmarks) have nothing to show.
+func (m model) showInfoPanel() model {
+	if m.status == "" || isLocalPage(m.status) {
+		return m
+	}
+	m.showInfo = true
+	if m.ready {
+		meta := m.metadata
+		if _, ok := meta["chain-valid"]; !ok && m.chainChecks[chainCheckKey(m.addressBar.Value(), meta)] != "" {
+			meta = maps.Clone(meta)
+			meta["chain-valid"] = "false (background VERSIONS check)"
+		}
+		content := infoPanel(m.addressBar.Value(), m.status, meta, m.redirects, m.fromCache, m.cachedAt, time.Now())
+		if host, _, err := fetch.ParseMarkURL(m.addressBar.Value()); err == nil && m.client != nil {
+			if s, ok := m.client.CacheStats()[host]; ok {
+				content += cacheStatsRow(s)
+			}
+		}
+		m.viewport.SetContent(content)
+		m.viewport.GotoTop()
+	}
+	return m
+}
+
+// cacheStatsRow renders the info panel row with this session's cache hits
+// and misses for the page's host: a low ratio on a host where browsing
+// feels slow means its pages are fetched afresh rather than revalidated.
+func cacheStatsRow(s cache.HostStats) string {
+	return fmt.Sprintf("    %-14s %d hits, %d misses this session (%.0f%% hit ratio)\n",
+		"host cache", s.Hits, s.Misses, 100*s.HitRatio())
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/latebit/demarkus/client/internal/cache"
+)
+
+func TestInfoPanel(t *testing.T) {
+	meta := map[string]string{
+		"status":   "ok",
+		"etag":     "abc123",
+		"version":  "3",
+		"modified": "2025-01-02T03:04:05Z",
+		"x-custom": "hello",
+	}
+	now := time.Date(2025, 1, 2, 4, 0, 0, 0, time.UTC)
+	got := infoPanel("mark://h/doc.md", "ok", meta, []string{"mark://h/old.md"}, true, now.Add(-90*time.Second), now)
+
+	for _, want := range []string{
+		"mark://h/doc.md",
+		"abc123",
+		"2025-01-02T03:04:05Z",
+		"chain-valid    (not reported)",
+		"content-type   text/markdown (default)",
+		"x-custom       hello",
+		"served from cache (age 1m30s)",
+		"redirected from mark://h/old.md",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("panel missing %q:\n%s", want, got)
+		}
+	}
+
+	// Well-known keys come before custom ones.
+	if strings.Index(got, "etag") > strings.Index(got, "x-custom") {
+		t.Error("etag should be listed before custom keys")
+	}
+
+	fresh := infoPanel("mark://h/doc.md", "ok", map[string]string{"chain-valid": "true"}, nil, false, time.Time{}, now)
+	if !strings.Contains(fresh, "fresh from server") {
+		t.Errorf("expected fresh cache line:\n%s", fresh)
+	}
+	if !strings.Contains(fresh, "chain-valid    true") {
+		t.Errorf("expected chain-valid value:\n%s", fresh)
+	}
+}
+
+func TestCacheStatsRow(t *testing.T) {
+	got := cacheStatsRow(cache.HostStats{Hits: 3, Misses: 1})
+	if want := "    host cache     3 hits, 1 misses this session (75% hit ratio)\n"; got != want {
+		t.Errorf("cacheStatsRow = %q, want %q", got, want)
+	}
+}
+
+func TestShowInfoPanelSkipsBookmarks(t *testing.T) {
+	m := model{status: "bookmarks"}
+	if m.showInfoPanel().showInfo {
+		t.Error("info panel should not open on the bookmarks page")
+	}
+	m.status = "ok"
+	if !m.showInfoPanel().showInfo {
+		t.Error("info panel should open for a fetched page")
+	}
+}
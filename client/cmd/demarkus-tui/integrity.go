@@ -0,0 +1,141 @@
+package main
+
+import (
+	"strconv"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/latebit/demarkus/client/internal/fetch"
+	"github.com/latebit/demarkus/client/internal/urlnorm"
+	"github.com/latebit/demarkus/protocol"
+)
+
+// chainCheckMsg carries the outcome of the background VERSIONS check of
+// a page version: the warning to show, or "" if nothing is wrong or the
+// check could not be made.
+type chainCheckMsg struct {
+	key     string
+	warning string
+}
+
+// chainCheckKey identifies a page version for the chain checks, however
+// its URL was typed, or returns "" for pages that are not versioned
+// documents.
+func chainCheckKey(url string, metadata map[string]string) string {
+	v, ok := pageVersion(metadata)
+	if !ok || url == "" {
+		return ""
+	}
+	return urlnorm.Normalize(url) + "@" + strconv.Itoa(v)
+}
+
+// metadataWarning returns the integrity warning a response carries itself:
+// a broken chain reported with the document, or a version the server
+// flagged as tampered.
+func metadataWarning(metadata map[string]string) string {
+	if metadata["tampered"] == "true" {
+		return "This version does not match its recorded hash: the server flagged it as tampered."
+	}
+	if metadata["chain-valid"] == "false" {
+		return chainWarning(metadata["chain-error"])
+	}
+	return ""
+}
+
+// chainWarning describes a history whose hash chain does not verify.
+func chainWarning(reason string) string {
+	w := "Version history failed verification: its hash chain is broken."
+	if reason != "" {
+		w += " " + reason
+	}
+	return w
+}
+
+// pageWarning returns the integrity warning for the current page, if any.
+func (m model) pageWarning() string {
+	if m.status != protocol.StatusOK || m.err != nil {
+		return ""
+	}
+	if w := metadataWarning(m.metadata); w != "" {
+		return w
+	}
+	return m.chainChecks[chainCheckKey(m.addressBar.Value(), m.metadata)]
+}
+
+// checkChain returns a tea.Cmd that asks for the current page's history
+// in the background, so a broken chain is noticed without opening it.
+// Each page version is checked once per session. Returns nil for pages
+// that are not versioned documents, or whose response already settled it.
+func (m model) checkChain() tea.Cmd {
+	url := m.addressBar.Value()
+	key := chainCheckKey(url, m.metadata)
+	if key == "" || m.client == nil || m.status != protocol.StatusOK || isAttachment(m.metadata) {
+		return nil
+	}
+	if _, ok := m.metadata["chain-valid"]; ok {
+		return nil
+	}
+	if _, ok := m.chainChecks[key]; ok {
+		return nil
+	}
+	client := m.client
+	return func() tea.Msg {
+		host, path, err := fetch.ParseMarkURL(url)
+		if err != nil {
+			return chainCheckMsg{key: key}
+		}
+		r, err := client.Versions(host, path)
+		if err != nil {
+			return chainCheckMsg{key: key}
+		}
+		h, err := fetch.ParseVersionHistory(r.Response)
+		if err != nil || !h.ChainKnown || h.ChainValid {
+			return chainCheckMsg{key: key}
+		}
+		return chainCheckMsg{key: key, warning: chainWarning(h.ChainError)}
+	}
+}
+
+// handleChainCheck records a chain check and shows its warning if the
+// page it was made for is still shown.
+func (m model) handleChainCheck(msg chainCheckMsg) (tea.Model, tea.Cmd) {
+	if m.chainChecks == nil {
+		m.chainChecks = make(map[string]string)
+	}
+	m.chainChecks[msg.key] = msg.warning
+	m.fitViewport()
+	return m, nil
+}
+
+// fitViewport sizes the viewport to the space left by the address bar,
+// divider, status bar (only the last in the compact layout) and, when the
+// page has one, the integrity banner.
+func (m *model) fitViewport() {
+	if !m.ready {
+		return
+	}
+	chrome := 3 // address bar + divider + status bar
+	if m.compact() {
+		chrome = 1 // status bar, which holds the address bar
+	}
+	if banner := m.integrityBanner(); banner != "" {
+		chrome += lipgloss.Height(banner)
+	}
+	m.viewport.Height = max(m.height-chrome, 1)
+}
+
+// integrityBanner renders the current page's integrity warning as a
+// banner above the content, or "" when there is none.
+func (m model) integrityBanner() string {
+	w := m.pageWarning()
+	if w == "" {
+		return ""
+	}
+	return lipgloss.NewStyle().
+		Width(m.width).
+		Padding(0, m.padding()).
+		Bold(true).
+		Foreground(lipgloss.Color("15")).
+		Background(lipgloss.Color("1")).
+		Render("⚠ " + w)
+}
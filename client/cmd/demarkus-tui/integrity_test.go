@@ -0,0 +1,94 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/latebit/demarkus/client/internal/fetch"
+	"github.com/latebit/demarkus/protocol"
+)
+
+func TestMetadataWarning(t *testing.T) {
+	tests := []struct {
+		meta map[string]string
+		want string
+	}{
+		{map[string]string{"version": "2"}, ""},
+		{map[string]string{"chain-valid": "true"}, ""},
+		{map[string]string{"chain-valid": "false", "chain-error": "v2 does not follow v1"}, "hash chain is broken. v2 does not follow v1"},
+		{map[string]string{"tampered": "true"}, "flagged it as tampered"},
+	}
+	for _, tt := range tests {
+		got := metadataWarning(tt.meta)
+		if (tt.want == "") != (got == "") || !strings.Contains(got, tt.want) {
+			t.Errorf("metadataWarning(%v) = %q, want %q", tt.meta, got, tt.want)
+		}
+	}
+}
+
+func TestIntegrityBanner(t *testing.T) {
+	m := model{addressBar: textinput.New(), client: fetch.NewClient(fetch.Options{}), histIdx: -1, linkIdx: -1}
+	defer m.client.Close()
+	next, _ := m.handleWindowSize(tea.WindowSizeMsg{Width: 80, Height: 24})
+	m = next.(model)
+	plain := m.viewport.Height
+	m.addressBar.SetValue("mark://h/doc.md")
+
+	next, cmd := m.handleFetchResult(fetchResult{
+		result: fetch.Result{Response: protocol.Response{
+			Status:   protocol.StatusOK,
+			Metadata: map[string]string{"version": "3"},
+			Body:     "# Doc\n",
+		}},
+		url: "mark://h:6309/doc.md",
+	})
+	m = next.(model)
+	if cmd == nil {
+		t.Fatal("expected a background chain check")
+	}
+	if m.integrityBanner() != "" {
+		t.Fatal("no banner before the check reports")
+	}
+
+	next, _ = m.handleChainCheck(chainCheckMsg{key: "mark://h:6309/doc.md@3", warning: chainWarning("")})
+	m = next.(model)
+	if !strings.Contains(m.View(), "hash chain is broken") {
+		t.Errorf("view lacks the banner:\n%s", m.View())
+	}
+	if want := plain - lipgloss.Height(m.integrityBanner()); m.viewport.Height != want {
+		t.Errorf("viewport height = %d, want %d", m.viewport.Height, want)
+	}
+	if m.checkChain() != nil {
+		t.Error("a checked version must not be checked again")
+	}
+
+	// Leaving the page takes the banner and its row away.
+	m = m.showLocalPage(pageBookmarks, "# Bookmarks\n")
+	if m.integrityBanner() != "" || m.viewport.Height != plain {
+		t.Errorf("banner %q and height %d on a local page", m.integrityBanner(), m.viewport.Height)
+	}
+}
+
+func TestCheckChainSkipsSettledPages(t *testing.T) {
+	m := model{addressBar: textinput.New(), client: fetch.NewClient(fetch.Options{}), status: protocol.StatusOK}
+	defer m.client.Close()
+	m.addressBar.SetValue("mark://h:6309/doc.md")
+
+	for _, meta := range []map[string]string{
+		nil, // directory listings and other unversioned pages
+		{"version": "2", "chain-valid": "true"},
+		{"version": "2", "chain-valid": "false"},
+	} {
+		m.metadata = meta
+		if m.checkChain() != nil {
+			t.Errorf("checkChain with %v should not ask for VERSIONS", meta)
+		}
+	}
+	m.metadata = map[string]string{"version": "2"}
+	if m.checkChain() == nil {
+		t.Error("checkChain should ask for VERSIONS of a versioned document")
+	}
+}
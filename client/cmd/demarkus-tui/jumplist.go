@@ -0,0 +1,33 @@
+package main
+
+// maxJumps caps the jump list; the oldest jumps are dropped first.
+const maxJumps = 100
+
+// recordJump appends entry to the jump list. Unlike linear history, the
+// jump list is never truncated when navigating after going back, so a page
+// left behind by an accidental navigation can still be reached with ctrl+o.
+// Consecutive visits to the same URL collapse into one jump.
+func (m *model) recordJump(entry historyEntry) {
+	if n := len(m.jumps); n > 0 && m.jumps[n-1].url == entry.url {
+		m.jumps[n-1] = entry
+	} else {
+		m.jumps = append(m.jumps, entry)
+		if len(m.jumps) > maxJumps {
+			m.jumps = m.jumps[len(m.jumps)-maxJumps:]
+		}
+	}
+	m.jumpIdx = len(m.jumps) - 1
+}
+
+// jump moves delta steps through the jump list (-1 older, +1 newer) and
+// shows that page. The jump is pushed onto linear history so [ undoes it.
+func (m model) jump(delta int) model {
+	next := m.jumpIdx + delta
+	if next < 0 || next >= len(m.jumps) {
+		return m
+	}
+	m.jumpIdx = next
+	m.history, m.histIdx = pushHistory(m.history, m.histIdx, m.jumps[next])
+	m.restoreHistory()
+	return m
+}
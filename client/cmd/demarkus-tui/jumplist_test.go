@@ -0,0 +1,62 @@
+package main
+
+import "testing"
+
+func TestRecordJump(t *testing.T) {
+	var m model
+	m.recordJump(historyEntry{url: "mark://h/a.md"})
+	m.recordJump(historyEntry{url: "mark://h/a.md"})
+	m.recordJump(historyEntry{url: "mark://h/b.md"})
+	if len(m.jumps) != 2 {
+		t.Fatalf("jumps = %d, want 2 (consecutive duplicates collapse)", len(m.jumps))
+	}
+	if m.jumpIdx != 1 {
+		t.Errorf("jumpIdx = %d, want 1", m.jumpIdx)
+	}
+
+	for i := range maxJumps + 10 {
+		m.recordJump(historyEntry{url: "mark://h/" + string(rune('a'+i%26)) + ".md"})
+	}
+	if len(m.jumps) != maxJumps {
+		t.Errorf("jumps = %d, want cap %d", len(m.jumps), maxJumps)
+	}
+}
+
+func TestJumpSurvivesHistoryTruncation(t *testing.T) {
+	m := model{histIdx: -1}
+	for _, u := range []string{"mark://h/a.md", "mark://h/b.md"} {
+		e := historyEntry{url: u, status: "ok"}
+		m.history, m.histIdx = pushHistory(m.history, m.histIdx, e)
+		m.recordJump(e)
+	}
+
+	// Go back to a, then navigate to c: linear history drops b.
+	m.histIdx--
+	m.restoreHistory()
+	m.recordJump(m.history[m.histIdx])
+	c := historyEntry{url: "mark://h/c.md", status: "ok"}
+	m.history, m.histIdx = pushHistory(m.history, m.histIdx, c)
+	m.recordJump(c)
+	if len(m.history) != 2 {
+		t.Fatalf("history = %d entries, want 2", len(m.history))
+	}
+
+	// The jump list still reaches b: c -> a -> b.
+	m = m.jump(-1)
+	if got := m.addressBar.Value(); got != "mark://h/a.md" {
+		t.Errorf("first ctrl+o = %q, want a", got)
+	}
+	m = m.jump(-1)
+	if got := m.addressBar.Value(); got != "mark://h/b.md" {
+		t.Errorf("second ctrl+o = %q, want b", got)
+	}
+	m = m.jump(1)
+	if got := m.addressBar.Value(); got != "mark://h/a.md" {
+		t.Errorf("ctrl+n = %q, want a", got)
+	}
+
+	// Back in linear history undoes the jump.
+	if !m.canGoBack() {
+		t.Error("jump should be undoable with [")
+	}
+}
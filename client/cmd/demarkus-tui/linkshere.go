@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/latebit/demarkus/client/internal/fetch"
+	"github.com/latebit/demarkus/client/internal/links"
+)
+
+// whatLinksHereResult carries the server's list of the documents linking to
+// the page at url.
+type whatLinksHereResult struct {
+	url  string
+	body string
+	err  error
+	seq  uint64
+}
+
+// showWhatLinksHere asks the current page's server which of its documents
+// link to the page: "what links here".
+func (m model) showWhatLinksHere() (tea.Model, tea.Cmd) {
+	url := m.addressBar.Value()
+	if url == "" || m.client == nil || isLocalPage(m.status) {
+		return m, nil
+	}
+	host, path, err := fetch.ParseMarkURL(url)
+	if err != nil {
+		return m, nil
+	}
+	m.loading = true
+	m.fetchSeq++
+	m.err = nil
+	seq, client := m.fetchSeq, m.client
+	return m, func() tea.Msg {
+		r, err := client.Backlinks(host, path, "")
+		if err == nil {
+			err = r.Err()
+		}
+		if err != nil {
+			return whatLinksHereResult{url: url, err: err, seq: seq}
+		}
+		return whatLinksHereResult{url: url, body: r.Response.Body, seq: seq}
+	}
+}
+
+func (m model) handleWhatLinksHere(msg whatLinksHereResult) (tea.Model, tea.Cmd) {
+	if msg.seq != m.fetchSeq {
+		return m, nil
+	}
+	if msg.err != nil {
+		m.loading = false
+		m.err = fmt.Errorf("what links here: %w", msg.err)
+		return m, nil
+	}
+	return m.showLocalPage(pageLinksHere, renderWhatLinksHere(msg.url, msg.body)), nil
+}
+
+// renderWhatLinksHere returns a BACKLINKS body with the path of each listed
+// document resolved against url, so that the local page can follow them.
+func renderWhatLinksHere(url, body string) string {
+	lines := strings.Split(body, "\n")
+	for i, line := range lines {
+		if !strings.HasPrefix(line, "- [") || !strings.HasSuffix(line, ")") {
+			continue
+		}
+		k := strings.LastIndex(line, "](")
+		if k < 0 {
+			continue
+		}
+		dest := line[k+2 : len(line)-1]
+		lines[i] = line[:k+2] + links.Resolve(url, dest) + ")"
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,14 @@
+package main
+
+import "testing"
+
+func TestRenderWhatLinksHere(t *testing.T) {
+	body := "\n# Links to /guide/setup.md\n\n- [Home](/index.md)\n- [Setup \\[draft\\]](/guide/set%20up.md)\n"
+	want := "\n# Links to /guide/setup.md\n\n- [Home](mark://example.com:6309/index.md)\n- [Setup \\[draft\\]](mark://example.com:6309/guide/set%20up.md)\n"
+	if got := renderWhatLinksHere("mark://example.com:6309/guide/setup.md", body); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if got := renderWhatLinksHere("mark://example.com:6309/a.md", "No documents link here.\n"); got != "No documents link here.\n" {
+		t.Errorf("empty list rewritten: %q", got)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"crypto/ed25519"
 	"flag"
 	"fmt"
 	"os"
@@ -12,12 +13,16 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/glamour"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/latebit/demarkus/client/internal/annotations"
 	"github.com/latebit/demarkus/client/internal/bookmarks"
 	"github.com/latebit/demarkus/client/internal/cache"
 	"github.com/latebit/demarkus/client/internal/fetch"
 	"github.com/latebit/demarkus/client/internal/graph"
 	"github.com/latebit/demarkus/client/internal/graphstore"
+	"github.com/latebit/demarkus/client/internal/hostprefs"
 	"github.com/latebit/demarkus/client/internal/links"
+	"github.com/latebit/demarkus/client/internal/readinglist"
+	"github.com/latebit/demarkus/client/internal/urlnorm"
 	"github.com/latebit/demarkus/protocol"
 )
 
@@ -30,12 +35,15 @@ const (
 
 // historyEntry stores a snapshot of a visited page for instant back/forward.
 type historyEntry struct {
-	url      string
-	rendered string // glamour-rendered content
-	rawBody  string
-	status   string
-	metadata map[string]string
-	links    []string // resolved absolute mark:// URLs
+	url       string
+	rendered  string // glamour-rendered content
+	rawBody   string
+	status    string
+	metadata  map[string]string
+	links     []string // resolved absolute mark:// URLs
+	redirects []string // URLs that redirected here, oldest first
+	previous  *cache.Entry
+	dirSort   string // order of a directory listing, if not by name
 }
 
 type model struct {
@@ -45,22 +53,39 @@ type model struct {
 	status      string
 	metadata    map[string]string
 	fromCache   bool
+	cachedAt    time.Time
+	redirects   []string     // moved responses followed to reach this page
+	previous    *cache.Entry // cached copy the last fetch replaced, if it changed
+	dirSort     string       // order of a directory listing, if not by name
 	err         error
 	loading     bool
+	busy        string // rate-limit notice shown while loading
 	client      *fetch.Client
 	pendingBody string
+	pendingURL  string // page pendingBody belongs to; "" for local pages
 	width       int
 	height      int
 	ready       bool
 
+	// Compact layout below this terminal width or height; 0 disables.
+	compactWidth  int
+	compactHeight int
+
 	// Cached markdown renderer (re-created only when width changes).
 	renderer      *glamour.TermRenderer
 	rendererWidth int
 
+	// Per-host display preferences; nil when there are none.
+	hostPrefs *hostprefs.Store
+
 	// History navigation
 	history []historyEntry
 	histIdx int
 
+	// Jump list: every page shown, in order, never truncated.
+	jumps   []historyEntry
+	jumpIdx int
+
 	// Link navigation
 	rawBody string   // raw markdown body of current page
 	links   []string // resolved absolute mark:// URLs
@@ -73,20 +98,68 @@ type model struct {
 	viewMode     viewMode
 	graphSubView graphSubView
 	graphData    *graph.Graph
-	graphNodes   []graphListItem
-	graphIdx     int
+	graphNodes   []graphListItem // unfiltered; see visibleGraphNodes
+	graphIdx     int             // index into visibleGraphNodes
 	crawling     bool
 	crawlSeq     uint64
 
+	// Graph exploration: filter, collapsed subtrees (links view), legend,
+	// and the document whose backlinks are shown.
+	graphFilter      graphFilter
+	graphCollapsed   map[string]bool
+	graphLegend      bool
+	graphBacklinksOf string
+
+	// Table view: wide tables of the current page, scrolled horizontally.
+	tables      [][]string
+	tableIdx    int
+	tableOffset int
+
 	showHelp bool
+	showInfo bool
 
 	// Bookmarks
 	bookmarkStore *bookmarks.Store
 	bookmarkMsg   string // transient status message
 	bookmarkSeq   uint64 // sequence counter for stale clear prevention
 
+	// Annotations: highlighted passages pinned to document versions, and
+	// the two-step prompt (passage, then note) that adds them.
+	annotationStore *annotations.Store
+	annotateInput   textinput.Model
+	annotateStage   annotateStage
+	annotateText    string
+
+	// Reading list: pages saved for later, read state per version.
+	readingList *readinglist.Store
+
 	// Persistent graph
 	graphStore *graphstore.Store
+
+	// Link previews: titles prefetched for selected links.
+	linkTitles *linkTitleCache
+
+	// prefetch warms the cache with every page's links in the background.
+	prefetch bool
+
+	// Cross-host confirmation: links to another host wait for y/N.
+	confirmCrossHost bool
+	pendingLink      string
+	pendingLinkHost  string
+
+	// Notifications: bookmarked documents checked every watchInterval,
+	// with the last version seen of each.
+	watchInterval time.Duration
+	watchSeen     map[string]string
+	notifications []notification
+	unreadNotes   int
+
+	// Local full-text search over the response cache.
+	cache *cache.Cache
+
+	// Integrity: the warning found by the background VERSIONS check of
+	// each page version, "" when its chain verified, by chainCheckKey.
+	chainChecks map[string]string
 }
 
 type fetchResult struct {
@@ -135,16 +208,21 @@ func (m *model) restoreHistory() {
 	m.addressBar.SetValue(entry.url)
 	m.status = entry.status
 	m.metadata = entry.metadata
+	m.redirects = entry.redirects
+	m.previous = entry.previous
 	m.rawBody = entry.rawBody
 	m.links = entry.links
+	m.dirSort = entry.dirSort
 	m.linkIdx = -1
 	m.err = nil
 	m.loading = false
 	m.fromCache = false
+	m.cachedAt = time.Time{}
+	m.fitViewport()
 	if m.ready {
 		content := entry.rendered
 		if content == "" && entry.rawBody != "" {
-			r, err := m.renderMarkdown(entry.rawBody)
+			r, err := m.renderPage(entry.url, m.annotatedBody(entry.url, entry.metadata, entry.rawBody))
 			if err != nil {
 				content = entry.rawBody
 			} else {
@@ -162,15 +240,43 @@ const helpText = `
 
   Navigation
     Enter        Follow selected link / fetch URL
+                 (links to another host ask y/N first)
     [ / Alt+Left   Go back
     ] / Alt+Right  Go forward
-    Tab          Cycle through links on page
-    d            Document graph view
+    Ctrl+O       Jump to older page (jump list)
+    Ctrl+N       Jump to newer page (jump list)
+    Tab          Cycle through links on page (previews target title)
+    r            Refresh page
+    c            Show changes since the cached copy (when a fetch
+                 finds the page changed)
+    d            Document graph view (f filter, c collapse, b node
+                 backlinks, l legend)
+    t            View wide tables (h/l scroll, t next, Esc close)
     f            Focus address bar
+    /            Search cached pages offline (or type ?query in
+                 the address bar)
+    o            Read the page's markdown in $PAGER (or $EDITOR),
+                 read-only
+    p            Copy a permalink to the version on screen
+    w            What links here: the server's documents that
+                 link to this page
+
+  Directory listings
+    m            Load more entries (when the listing was cut short)
+    s            Sort by name / most recently modified first
 
   Bookmarks
     b            Toggle bookmark for current page
     B            View all bookmarks
+    N            View changes to bookmarked pages (✉ in status bar)
+    R            Save page to reading list / remove it
+    L            View reading list (pages are marked read when
+                 opened, and unread again when they change)
+
+  Annotations
+    a            Highlight a passage of this version and add a note
+    A            View annotations of this document (all documents
+                 from a local page)
 
   Scrolling
     j / Down     Scroll down
@@ -179,14 +285,17 @@ const helpText = `
     G            Go to bottom
 
   General
+    i            Show response metadata for current page
     ?            Toggle this help screen
+                 (type tutorial: in the address bar for the tutorial)
     q / Ctrl+C   Quit
-    Esc          Exit bookmarks / dismiss help / blur address bar
+    Esc          Exit bookmarks or notifications / dismiss overlay /
+                 blur address bar
 `
 
 func initialModel(initialURL string, client *fetch.Client) model {
 	ti := textinput.New()
-	ti.Placeholder = "mark://host:port/path"
+	ti.Placeholder = "mark://host:port/path or ?search"
 	ti.Prompt = " "
 	ti.SetValue(initialURL)
 	ti.Focus()
@@ -207,17 +316,46 @@ func initialModel(initialURL string, client *fetch.Client) model {
 		}
 	}
 
-	return model{
-		addressBar:    ti,
-		focus:         focusAddressBar,
-		client:        client,
-		loading:       initialURL != "",
-		histIdx:       -1,
-		linkIdx:       -1,
-		bookmarkStore: bs,
-		bookmarkMsg:   bmMsg,
-		graphStore:    gs,
+	as, asErr := annotations.Load(annotations.DefaultPath())
+	if asErr != nil {
+		msg := "Failed to load annotations: " + asErr.Error()
+		if bmMsg != "" {
+			bmMsg += " | " + msg
+		} else {
+			bmMsg = msg
+		}
+	}
+
+	rl, rlErr := readinglist.Load(readinglist.DefaultPath())
+	if rlErr != nil {
+		msg := "Failed to load reading list: " + rlErr.Error()
+		if bmMsg != "" {
+			bmMsg += " | " + msg
+		} else {
+			bmMsg = msg
+		}
+	}
+
+	m := model{
+		addressBar:      ti,
+		focus:           focusAddressBar,
+		client:          client,
+		loading:         initialURL != "",
+		histIdx:         -1,
+		linkIdx:         -1,
+		bookmarkStore:   bs,
+		bookmarkMsg:     bmMsg,
+		graphStore:      gs,
+		annotationStore: as,
+		readingList:     rl,
+		linkTitles:      newLinkTitleCache(linkTitleCacheSize),
+		watchSeen:       make(map[string]string),
+		chainChecks:     make(map[string]string),
+	}
+	if initialURL == "" {
+		m = m.showLocalPage(pageStart, m.renderStartPage())
 	}
+	return m
 }
 
 func (m model) Init() tea.Cmd {
@@ -225,6 +363,9 @@ func (m model) Init() tea.Cmd {
 	if m.addressBar.Value() != "" {
 		cmds = append(cmds, m.doFetch(m.addressBar.Value()))
 	}
+	if m.watchInterval > 0 {
+		cmds = append(cmds, m.watchCmd())
+	}
 	if m.bookmarkMsg != "" {
 		cmds = append(cmds, tea.Tick(2*time.Second, func(time.Time) tea.Msg {
 			return clearBookmarkMsg{seq: 0}
@@ -245,8 +386,38 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m.handleCrawlResult(msg)
 	case fetchResult:
 		return m.handleFetchResult(msg)
+	case dirPageResult:
+		return m.handleDirPage(msg)
+	case rateLimitedMsg:
+		return m.handleRateLimited(msg)
+	case retryingMsg:
+		return m.handleRetrying(msg)
+	case watchTickMsg:
+		return m, m.watchCmd()
+	case watchResult:
+		return m.handleWatchResult(msg)
+	case searchResult:
+		return m.handleSearchResult(msg)
+	case whatLinksHereResult:
+		return m.handleWhatLinksHere(msg)
+	case chainCheckMsg:
+		return m.handleChainCheck(msg)
 	case viewportReady:
 		return m.handleViewportReady()
+	case linkTitleResult:
+		if m.linkTitles != nil {
+			m.linkTitles.put(msg.url, msg.title)
+		}
+		return m, nil
+	case prefetchResult:
+		if m.linkTitles != nil {
+			for url, title := range msg.titles {
+				m.linkTitles.put(url, title)
+			}
+		}
+		return m, nil
+	case pagerDoneMsg:
+		return m.handlePagerDone(msg)
 	case clearBookmarkMsg:
 		if msg.seq == m.bookmarkSeq {
 			m.bookmarkMsg = ""
@@ -258,12 +429,17 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 func (m model) handleMouse(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
 	if msg.Action == tea.MouseActionPress && msg.Button == tea.MouseButtonLeft {
-		if msg.Y == 0 {
+		// The compact layout puts the address bar in the status line.
+		addressRow, contentTop := 0, 2
+		if m.compact() {
+			addressRow, contentTop = m.height-1, 0
+		}
+		if msg.Y == addressRow {
 			m.focus = focusAddressBar
 			m.addressBar.Focus()
 			return m, textinput.Blink
 		}
-		if msg.Y >= 2 {
+		if msg.Y >= contentTop {
 			m.focus = focusViewport
 			m.addressBar.Blur()
 		}
@@ -279,13 +455,10 @@ func (m model) handleMouse(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
 func (m model) handleWindowSize(msg tea.WindowSizeMsg) (tea.Model, tea.Cmd) {
 	m.width = msg.Width
 	m.height = msg.Height
-	headerHeight := 2 // address bar + divider
-	footerHeight := 1 // status bar
-	viewportHeight := max(m.height-headerHeight-footerHeight, 1)
-
 	if !m.ready {
-		m.viewport = viewport.New(m.width, viewportHeight)
+		m.viewport = viewport.New(m.width, 1)
 		m.ready = true
+		m.fitViewport()
 		// Defer pending content to a separate update cycle so the
 		// viewport has a chance to fully initialise before receiving
 		// content. Setting content in the same cycle as creation can
@@ -296,11 +469,14 @@ func (m model) handleWindowSize(msg tea.WindowSizeMsg) (tea.Model, tea.Cmd) {
 		}
 	} else {
 		m.viewport.Width = m.width
-		m.viewport.Height = viewportHeight
+		m.fitViewport()
 		// Re-render graph view with new width for correct truncation.
 		if m.viewMode == viewGraph && len(m.graphNodes) > 0 {
 			m.viewport.SetContent(m.renderCurrentGraphSubView())
 		}
+		if m.viewMode == viewTable {
+			m.viewport.SetContent(m.renderTableView())
+		}
 	}
 	m.addressBar.Width = m.width - 2
 	return m, nil
@@ -308,7 +484,7 @@ func (m model) handleWindowSize(msg tea.WindowSizeMsg) (tea.Model, tea.Cmd) {
 
 func (m model) handleViewportReady() (tea.Model, tea.Cmd) {
 	if m.pendingBody != "" {
-		rendered, err := m.renderMarkdown(m.pendingBody)
+		rendered, err := m.renderPage(m.pendingURL, m.pendingBody)
 		if err != nil {
 			m.viewport.SetContent(m.pendingBody)
 		} else {
@@ -341,7 +517,7 @@ func (m model) handleCrawlResult(msg crawlResult) (tea.Model, tea.Cmd) {
 	// Recompute display list for the active sub-view.
 	switch m.graphSubView {
 	case subViewBacklinks:
-		m.graphNodes = backlinksList(m.graphStore, msg.url)
+		m.graphNodes = backlinksList(m.graphStore, m.graphBacklinksOf)
 	case subViewTopology:
 		m.graphNodes = topologyList(m.graphStore)
 	default:
@@ -362,26 +538,45 @@ func (m model) handleFetchResult(msg fetchResult) (tea.Model, tea.Cmd) {
 		return m, nil
 	}
 	m.loading = false
+	m.busy = ""
 	if msg.err != nil {
 		m.err = msg.err
 		m.pendingBody = ""
 		m.status = ""
 		m.metadata = nil
 		m.fromCache = false
+		m.cachedAt = time.Time{}
+		m.redirects = nil
+		m.previous = nil
 		m.links = nil
 		m.linkIdx = -1
 		if m.ready {
 			m.viewport.SetContent(errorView(msg.err))
 		}
+		m.fitViewport()
 		return m, nil
 	}
+	// The client followed any redirects; the page is at the URL it ended on.
+	if msg.result.URL != "" {
+		msg.url = msg.result.URL
+		m.addressBar.SetValue(msg.url)
+	}
 	m.err = nil
 	m.status = msg.result.Response.Status
 	m.metadata = msg.result.Response.Metadata
 	m.fromCache = msg.result.FromCache
+	m.cachedAt = msg.result.CachedAt
+	m.redirects = msg.result.Redirects
+	m.previous = msg.result.Previous
+	m.dirSort = ""
+	m.noteSeen(msg.url, m.metadata)
+	m.markRead(msg.url, m.metadata)
 
 	// Extract and resolve links from raw body.
 	m.rawBody = msg.result.Response.Body
+	if isAttachment(m.metadata) {
+		m.rawBody = attachmentNotice(msg.url, m.metadata, len(msg.result.Response.Body))
+	}
 	raw := links.Extract(m.rawBody)
 	m.links = make([]string, 0, len(raw))
 	for _, dest := range raw {
@@ -392,7 +587,7 @@ func (m model) handleFetchResult(msg fetchResult) (tea.Model, tea.Cmd) {
 	// Render markdown.
 	var rendered string
 	if m.ready {
-		r, err := m.renderMarkdown(msg.result.Response.Body)
+		r, err := m.renderPage(msg.url, m.annotatedBody(msg.url, m.metadata, m.rawBody))
 		if err != nil {
 			rendered = msg.result.Response.Body
 		} else {
@@ -401,32 +596,59 @@ func (m model) handleFetchResult(msg fetchResult) (tea.Model, tea.Cmd) {
 		m.viewport.SetContent(rendered)
 		m.viewport.GotoTop()
 	} else {
-		m.pendingBody = msg.result.Response.Body
+		m.pendingBody = m.annotatedBody(msg.url, m.metadata, m.rawBody)
+		m.pendingURL = msg.url
 	}
 
-	m.history, m.histIdx = pushHistory(m.history, m.histIdx, historyEntry{
-		url:      msg.url,
-		rendered: rendered,
-		rawBody:  m.rawBody,
-		status:   m.status,
-		metadata: m.metadata,
-		links:    m.links,
-	})
+	entry := historyEntry{
+		url:       msg.url,
+		rendered:  rendered,
+		rawBody:   m.rawBody,
+		status:    m.status,
+		metadata:  m.metadata,
+		links:     m.links,
+		redirects: m.redirects,
+		previous:  m.previous,
+	}
+	m.history, m.histIdx = pushHistory(m.history, m.histIdx, entry)
+	m.recordJump(entry)
+
+	// The client stops at a redirect to another host, to be confirmed
+	// like a link there.
+	if m.status == protocol.StatusMoved && m.confirmCrossHost {
+		target := links.Resolve(msg.url, m.metadata["location"])
+		if host, ok := crossHostTarget(msg.url, target); ok {
+			m.pendingLink = target
+			m.pendingLinkHost = host
+		}
+	}
 
 	m.focus = focusViewport
 	m.addressBar.Blur()
-	return m, nil
+	m.fitViewport()
+	return m, tea.Batch(m.prefetchLinks(msg.url), m.checkChain())
 }
 
 func (m model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	if msg.Type == tea.KeyCtrlC {
 		return m, tea.Quit
 	}
+	if m.annotateStage != annotateOff {
+		return m.handleAnnotateKey(msg)
+	}
 
 	if m.focus == focusAddressBar {
 		switch msg.Type {
 		case tea.KeyEnter:
 			raw := m.addressBar.Value()
+			if query, ok := strings.CutPrefix(raw, "?"); ok {
+				return m.startSearch(query)
+			}
+			if isTutorialLink(raw) {
+				m.focus = focusViewport
+				m.addressBar.Blur()
+				return m.showTutorial(raw), nil
+			}
 			if raw != "" {
 				m.loading = true
 				m.fetchSeq++
@@ -456,30 +678,26 @@ func (m model) handleViewportKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	if m.viewMode == viewGraph {
 		return m.handleGraphKey(msg)
 	}
+	if m.viewMode == viewTable {
+		return m.handleTableKey(msg)
+	}
+
+	// When help or the info panel is showing, any key dismisses it.
+	if m.showHelp || m.showInfo {
+		return m.handleOverlayDismiss(msg)
+	}
 
-	// When help is showing, any key dismisses it.
-	if m.showHelp {
-		return m.handleHelpDismiss(msg)
+	// A pending cross-host link consumes the next key as its answer.
+	if m.pendingLink != "" {
+		return m.handleCrossHostConfirm(msg)
 	}
 
 	switch msg.String() {
 	case "q":
 		return m, tea.Quit
 	case "esc":
-		if m.status == "bookmarks" {
-			if m.histIdx >= 0 {
-				m.restoreHistory()
-			} else {
-				m.status = ""
-				m.links = nil
-				m.rawBody = ""
-				m.linkIdx = -1
-				m.metadata = nil
-				if m.ready {
-					m.viewport.SetContent("")
-				}
-			}
-			return m, nil
+		if isLocalPage(m.status) {
+			return m.closeLocalPage(), nil
 		}
 	case "?":
 		m.showHelp = true
@@ -488,6 +706,8 @@ func (m model) handleViewportKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.viewport.GotoTop()
 		}
 		return m, nil
+	case "i":
+		return m.showInfoPanel(), nil
 	case "f":
 		return m.toggleFocus(), textinput.Blink
 	case "g":
@@ -498,16 +718,18 @@ func (m model) handleViewportKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, nil
 	case "[", "alt+left":
 		if m.canGoBack() {
-			m.histIdx--
-			m.restoreHistory()
+			return m.stepHistory(-1), nil
 		}
 		return m, nil
 	case "]", "alt+right":
 		if m.canGoForward() {
-			m.histIdx++
-			m.restoreHistory()
+			return m.stepHistory(1), nil
 		}
 		return m, nil
+	case "ctrl+o":
+		return m.jump(-1), nil
+	case "ctrl+n":
+		return m.jump(1), nil
 	case "tab":
 		return m.handleTabNavigation()
 	case "enter":
@@ -516,8 +738,36 @@ func (m model) handleViewportKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m.handleBookmarkToggle()
 	case "B":
 		return m.handleBookmarkView()
+	case "N":
+		return m.handleNotificationsView()
+	case "r":
+		return m.handleRefresh()
+	case "c":
+		return m.handleShowChanges()
+	case "R":
+		return m.handleReadLaterToggle()
+	case "L":
+		return m.handleReadingListView()
+	case "a":
+		return m.startAnnotation()
+	case "A":
+		return m.handleAnnotationsView()
+	case "/":
+		return m.openSearchPrompt(), textinput.Blink
 	case "d":
 		return m.handleGraphToggle()
+	case "t":
+		return m.openTableView()
+	case "o":
+		return m.openInPager()
+	case "m":
+		return m.loadMoreEntries()
+	case "s":
+		return m.toggleDirSort()
+	case "p":
+		return m.copyPermalink()
+	case "w":
+		return m.showWhatLinksHere()
 	}
 
 	var cmd tea.Cmd
@@ -525,6 +775,34 @@ func (m model) handleViewportKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
+// stepHistory moves delta entries through linear history and records the
+// destination in the jump list.
+func (m model) stepHistory(delta int) model {
+	m.histIdx += delta
+	m.restoreHistory()
+	m.recordJump(m.history[m.histIdx])
+	return m
+}
+
+// closeLocalPage leaves the bookmarks or notifications page, returning to
+// the last document.
+func (m model) closeLocalPage() model {
+	if m.histIdx >= 0 {
+		m.restoreHistory()
+		return m
+	}
+	m.status = ""
+	m.links = nil
+	m.rawBody = ""
+	m.linkIdx = -1
+	m.metadata = nil
+	m.fitViewport()
+	if m.ready {
+		m.viewport.SetContent("")
+	}
+	return m
+}
+
 func (m model) toggleFocus() model {
 	if m.focus == focusAddressBar {
 		m.focus = focusViewport
@@ -536,11 +814,12 @@ func (m model) toggleFocus() model {
 	return m
 }
 
-func (m model) handleHelpDismiss(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+func (m model) handleOverlayDismiss(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	if msg.String() == "q" {
 		return m, tea.Quit
 	}
 	m.showHelp = false
+	m.showInfo = false
 	if m.histIdx >= 0 {
 		m.restoreHistory()
 	} else if m.ready {
@@ -556,22 +835,40 @@ func (m model) handleTabNavigation() (tea.Model, tea.Cmd) {
 			m.linkIdx = -1
 		}
 	}
+	if m.linkIdx >= 0 {
+		return m, m.prefetchLinkTitle(m.links[m.linkIdx])
+	}
 	return m, nil
 }
 
 func (m model) handleLinkFollow() (tea.Model, tea.Cmd) {
 	if m.linkIdx >= 0 && m.linkIdx < len(m.links) {
 		target := m.links[m.linkIdx]
-		m.addressBar.SetValue(target)
-		m.loading = true
-		m.fetchSeq++
-		m.links = nil
-		m.linkIdx = -1
-		return m, m.doFetch(target)
+		if isTutorialLink(target) {
+			return m.showTutorial(target), nil
+		}
+		if m.confirmCrossHost {
+			if host, ok := crossHostTarget(m.addressBar.Value(), target); ok {
+				m.pendingLink = target
+				m.pendingLinkHost = host
+				return m, nil
+			}
+		}
+		return m.navigateTo(target)
 	}
 	return m, nil
 }
 
+// navigateTo loads target as the current page.
+func (m model) navigateTo(target string) (tea.Model, tea.Cmd) {
+	m.addressBar.SetValue(target)
+	m.loading = true
+	m.fetchSeq++
+	m.links = nil
+	m.linkIdx = -1
+	return m, m.doFetch(target)
+}
+
 func (m model) handleGraphToggle() (tea.Model, tea.Cmd) {
 	url := m.addressBar.Value()
 	if url == "" {
@@ -595,7 +892,7 @@ func (m model) handleGraphToggle() (tea.Model, tea.Cmd) {
 
 	if m.ready {
 		if len(m.graphNodes) > 0 {
-			m.viewport.SetContent(renderGraphView(m.graphNodes, m.graphIdx, m.width))
+			m.viewport.SetContent(m.renderCurrentGraphSubView())
 		} else {
 			m.viewport.SetContent("\n  Crawling document links...")
 		}
@@ -637,7 +934,37 @@ func (m model) handleBookmarkView() (tea.Model, tea.Cmd) {
 	if m.bookmarkStore == nil {
 		return m, nil
 	}
-	body := m.bookmarkStore.Render()
+	return m.showLocalPage(pageBookmarks, m.bookmarkStore.Render()), nil
+}
+
+// Statuses of pages generated by the client rather than fetched.
+const (
+	pageBookmarks     = "bookmarks"
+	pageNotifications = "notifications"
+	pageSearch        = "search"
+	pageAnnotations   = "annotations"
+	pageReadingList   = "reading list"
+	pageStart         = "start"
+	pageChanges       = "changes"
+	pageTutorial      = "tutorial"
+	pageLinksHere     = "what links here"
+)
+
+// isLocalPage reports whether status belongs to a client-generated page.
+func isLocalPage(status string) bool {
+	return status == pageBookmarks || status == pageNotifications || status == pageSearch || status == pageAnnotations ||
+		status == pageReadingList || status == pageStart || status == pageChanges || status == pageTutorial ||
+		status == pageLinksHere
+}
+
+// escapeLinkText escapes s for use as markdown link text on a local page.
+func escapeLinkText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`).Replace(s)
+}
+
+// showLocalPage displays a page generated by the client itself (bookmarks,
+// notifications) in place of a fetched document. Its links are navigable.
+func (m model) showLocalPage(status, body string) model {
 	m.rawBody = body
 	raw := links.Extract(body)
 	m.links = make([]string, 0, len(raw))
@@ -645,13 +972,17 @@ func (m model) handleBookmarkView() (tea.Model, tea.Cmd) {
 		m.links = append(m.links, links.Resolve("", dest))
 	}
 	m.linkIdx = -1
-	m.status = "bookmarks"
+	m.status = status
 	m.addressBar.SetValue("")
 	m.loading = false
 	m.fetchSeq++
 	m.metadata = nil
 	m.fromCache = false
+	m.cachedAt = time.Time{}
+	m.redirects = nil
+	m.previous = nil
 	m.err = nil
+	m.fitViewport()
 	if m.ready {
 		rendered, err := m.renderMarkdown(body)
 		if err != nil {
@@ -660,20 +991,26 @@ func (m model) handleBookmarkView() (tea.Model, tea.Cmd) {
 			m.viewport.SetContent(rendered)
 		}
 		m.viewport.GotoTop()
+	} else {
+		m.pendingBody = body
+		m.pendingURL = ""
 	}
-	return m, nil
+	return m
 }
 
 func (m model) View() string {
 	if !m.ready {
 		return "Loading..."
 	}
+	if m.compact() {
+		return m.compactView()
+	}
 
 	var b strings.Builder
 
 	// Address bar.
 	barStyle := lipgloss.NewStyle().
-		Padding(0, 1).
+		Padding(0, m.padding()).
 		Width(m.width)
 	if m.focus == focusAddressBar {
 		barStyle = barStyle.Bold(true)
@@ -685,6 +1022,12 @@ func (m model) View() string {
 	b.WriteString(strings.Repeat("─", m.width))
 	b.WriteByte('\n')
 
+	// Integrity banner, kept in view whatever the scroll position.
+	if banner := m.integrityBanner(); banner != "" {
+		b.WriteString(banner)
+		b.WriteByte('\n')
+	}
+
 	// Viewport.
 	b.WriteString(m.viewport.View())
 	b.WriteByte('\n')
@@ -698,7 +1041,11 @@ func (m model) View() string {
 func (m model) statusBarView() string {
 	style := lipgloss.NewStyle().
 		Width(m.width).
-		Padding(0, 1)
+		Padding(0, m.padding())
+	compact := m.compact()
+	if compact {
+		style = style.MaxHeight(1)
+	}
 
 	if m.viewMode == viewGraph {
 		if m.crawling {
@@ -707,12 +1054,15 @@ func (m model) statusBarView() string {
 		var viewName string
 		switch m.graphSubView {
 		case subViewBacklinks:
-			viewName = "Backlinks"
+			viewName = "Backlinks to " + m.graphBacklinksOf
 		case subViewTopology:
 			viewName = "Topology"
 		default:
 			viewName = "Links"
 		}
+		if m.graphFilter != filterAll {
+			viewName += " [" + m.graphFilter.String() + "]"
+		}
 		if m.graphData != nil || m.graphSubView != subViewLinks {
 			var hint string
 			if m.graphData != nil {
@@ -725,16 +1075,31 @@ func (m model) statusBarView() string {
 		}
 		return style.Render("")
 	}
-	if m.showHelp {
+	if m.viewMode == viewTable {
+		hint := fmt.Sprintf("Table %d/%d  |  col %d  |  h/l scroll  |  t next  |  Esc close",
+			m.tableIdx+1, len(m.tables), m.tableOffset+1)
+		return style.Foreground(lipgloss.Color("14")).Render(hint)
+	}
+	if m.showHelp || m.showInfo {
 		return style.Faint(true).Render("Press any key to dismiss")
 	}
 	if m.loading {
+		if m.busy != "" {
+			return style.Foreground(lipgloss.Color("11")).Render(m.busy)
+		}
 		return style.Render("Loading...")
 	}
 	if m.err != nil {
 		return style.Foreground(lipgloss.Color("9")).Render("Error: " + m.err.Error())
 	}
 
+	if m.pendingLink != "" {
+		return style.Foreground(lipgloss.Color("11")).Render(crossHostPrompt(m.pendingLinkHost))
+	}
+	if m.annotateStage != annotateOff {
+		return style.Render(m.annotateInput.View())
+	}
+
 	// Show transient bookmark message.
 	if m.bookmarkMsg != "" {
 		return style.Foreground(lipgloss.Color("10")).Render(m.bookmarkMsg)
@@ -742,31 +1107,87 @@ func (m model) statusBarView() string {
 
 	// Show selected link in status bar (link navigation mode).
 	if m.linkIdx >= 0 && m.linkIdx < len(m.links) {
-		hint := fmt.Sprintf("[%d/%d] %s", m.linkIdx+1, len(m.links), m.links[m.linkIdx])
+		target := m.links[m.linkIdx]
+		var title string
+		if m.linkTitles != nil {
+			title, _ = m.linkTitles.get(target)
+		}
+		hint := linkPreview(m.linkIdx, len(m.links), target, title)
 		return style.Foreground(lipgloss.Color("12")).Render(hint)
 	}
 
-	if m.status == "" {
-		return style.Faint(true).Render("Enter a mark:// URL and press Enter  |  ? for help")
+	badge := notificationBadge(m.unreadNotes)
+	if m.status == "" || m.status == pageStart {
+		hint := "Enter a mark:// URL and press Enter  |  ? for help"
+		if compact {
+			hint = "f: enter a mark:// URL  |  ? help"
+		}
+		if badge != "" {
+			hint += "  |  " + badge + " (N)"
+		}
+		if m.readingList != nil {
+			if n := m.readingList.UnreadCount(); n > 0 {
+				hint += fmt.Sprintf("  |  %d unread (L)", n)
+			}
+		}
+		return style.Faint(true).Render(hint)
 	}
 
-	parts := []string{"[" + m.status + "]"}
-	if m.status != "bookmarks" && m.bookmarkStore != nil && m.bookmarkStore.Has(m.addressBar.Value()) {
+	var parts []string
+	if !compact || m.status != protocol.StatusOK {
+		parts = append(parts, "["+m.status+"]")
+	}
+	if badge != "" {
+		parts = append(parts, badge)
+	}
+	if !isLocalPage(m.status) && m.bookmarkStore != nil && m.bookmarkStore.Has(m.addressBar.Value()) {
 		parts = append(parts, "★")
 	}
+	if !isLocalPage(m.status) && m.readingList != nil && m.readingList.Has(m.addressBar.Value()) {
+		parts = append(parts, "☰")
+	}
+	if v, ok := pageVersion(m.metadata); ok && !isLocalPage(m.status) {
+		if note := annotationBadge(len(m.versionAnnotations(m.addressBar.Value(), v))); note != "" {
+			parts = append(parts, note)
+		}
+	}
 	if m.fromCache {
 		parts = append(parts, "(cached)")
 	}
+	if len(m.redirects) > 0 {
+		parts = append(parts, "(redirected)")
+	}
+	if q := m.pageConnQuality(); q != "" {
+		parts = append(parts, q)
+	}
+	if m.previous != nil && compact {
+		parts = append(parts, "changed (c)")
+	} else if m.previous != nil {
+		parts = append(parts, "(changed, c: show changes)")
+	}
+	if lang := m.metadata[protocol.MetaContentLanguage]; lang != "" && !isLocalPage(m.status) {
+		parts = append(parts, "["+lang+"]")
+	}
+	if note := readingNote(m.metadata); note != "" && !isLocalPage(m.status) && !compact {
+		parts = append(parts, note)
+	}
 	if v, ok := m.metadata["version"]; ok {
 		parts = append(parts, "v"+v)
 	}
-	if mod, ok := m.metadata["modified"]; ok {
+	if mod, ok := m.metadata["modified"]; ok && compact {
+		parts = append(parts, shortDate(mod))
+	} else if ok {
 		parts = append(parts, mod)
 	}
 	scroll := fmt.Sprintf("%d%%", int(m.viewport.ScrollPercent()*100))
 	parts = append(parts, scroll)
+	if compact && !isLocalPage(m.status) {
+		if room := m.width - lipgloss.Width(strings.Join(parts, "  ")) - 2; room > 0 {
+			parts = append([]string{shortURL(m.addressBar.Value(), room)}, parts...)
+		}
+	}
 
-	if m.status != protocol.StatusOK && m.status != "bookmarks" {
+	if m.status != protocol.StatusOK && !isLocalPage(m.status) {
 		style = style.Foreground(lipgloss.Color("11"))
 	}
 	return style.Render(strings.Join(parts, "  "))
@@ -774,6 +1195,7 @@ func (m model) statusBarView() string {
 
 func (m model) doFetch(raw string) tea.Cmd {
 	seq := m.fetchSeq
+	raw = urlnorm.Normalize(raw)
 	return func() tea.Msg {
 		host, path, err := fetch.ParseMarkURL(raw)
 		if err != nil {
@@ -785,7 +1207,10 @@ func (m model) doFetch(raw string) tea.Cmd {
 }
 
 func (m *model) renderMarkdown(body string) (string, error) {
-	wrapWidth := m.width - 4
+	return m.renderWrapped(body, m.contentWidth())
+}
+
+func (m *model) renderWrapped(body string, wrapWidth int) (string, error) {
 	if m.renderer == nil || m.rendererWidth != wrapWidth {
 		r, err := glamour.NewTermRenderer(
 			glamour.WithAutoStyle(),
@@ -806,11 +1231,42 @@ func errorView(err error) string {
 
 func main() {
 	insecure := flag.Bool("insecure", false, "skip TLS certificate verification")
+	confirmCrossHost := flag.Bool("confirm-cross-host", true, "ask before following links to a different host")
+	watch := flag.Duration("watch", 5*time.Minute, "how often to check bookmarked and reading list pages for changes (0 disables)")
+	prefetch := flag.Bool("prefetch", false, "fetch the pages linked from each page in the background, so following a link is instant")
+	timeout := flag.Duration("timeout", 15*time.Second, "how long loading a page may take, retries included, before it fails (0 for no limit)")
+	keepAlive := flag.Duration("keepalive", fetch.DefaultKeepAlive, "how often to send keep-alive packets on server connections, so they stay open between pages (0 disables)")
+	lang := flag.String("lang", "", "preferred languages, as an accept-language value such as \"de, en;q=0.5\": servers show a translation when they have one")
+	compactWidth := flag.Int("compact-width", 90, "use the compact layout in terminals narrower than this (0 never)")
+	compactHeight := flag.Int("compact-height", 30, "use the compact layout in terminals shorter than this (0 never)")
 	flag.Parse()
+	if *lang != "" && len(protocol.AcceptedLanguages(*lang)) == 0 {
+		fmt.Fprintf(os.Stderr, "-lang %q names no language\n", *lang)
+		os.Exit(2)
+	}
 
+	prefs, prefsErr := hostprefs.Load(hostprefs.DefaultPath())
+
+	// p is set before Run, and the client only calls back from commands
+	// started by the running program.
+	var p *tea.Program
+	c := cache.New(cache.DefaultDir())
 	client := fetch.NewClient(fetch.Options{
-		Cache:    cache.New(cache.DefaultDir()),
-		Insecure: *insecure,
+		Cache:              c,
+		Insecure:           *insecure,
+		MaxRedirects:       fetch.DefaultMaxRedirects,
+		CrossHostRedirects: !*confirmCrossHost,
+		AcceptLanguage:     *lang,
+		OperationTimeout:   *timeout,
+		KeepAlive:          *keepAlive,
+		OnRateLimited: func(host string, wait time.Duration) {
+			p.Send(rateLimitedMsg{host: host, wait: wait})
+		},
+		OnAttempt: func(host string, attempt int, deadline time.Time) {
+			p.Send(retryingMsg{host: host, attempt: attempt, deadline: deadline})
+		},
+		SkipCache: func(host string) bool { return prefs.For(host).NoCache },
+		PinnedKey: func(host string) ed25519.PublicKey { return prefs.For(host).PinnedKey() },
 	})
 	defer client.Close()
 
@@ -819,8 +1275,28 @@ func main() {
 		initialURL = flag.Arg(0)
 	}
 
-	p := tea.NewProgram(
-		initialModel(initialURL, client),
+	m := initialModel(initialURL, client)
+	m.confirmCrossHost = *confirmCrossHost
+	m.watchInterval = *watch
+	m.prefetch = *prefetch
+	m.compactWidth = *compactWidth
+	m.compactHeight = *compactHeight
+	m.cache = c
+	m.hostPrefs = prefs
+	if prefsErr != nil {
+		msg := "Failed to load host preferences: " + prefsErr.Error()
+		if m.bookmarkMsg != "" {
+			m.bookmarkMsg += " | " + msg
+		} else {
+			m.bookmarkMsg = msg
+		}
+	}
+	if initialURL == "" && isFirstRun(c, m.bookmarkStore, m.readingList) {
+		m = m.showTutorial("")
+	}
+
+	p = tea.NewProgram(
+		m,
 		tea.WithAltScreen(),
 		tea.WithMouseCellMotion(),
 	)
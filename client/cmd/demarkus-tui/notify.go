@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/latebit/demarkus/client/internal/bookmarks"
+	"github.com/latebit/demarkus/client/internal/fetch"
+)
+
+// Bookmarked documents are watched in the background. The protocol has no
+// SUBSCRIBE verb yet, so each bookmark is re-fetched every watch interval
+// (a conditional FETCH, cheap when nothing changed) and its version is
+// compared with the last one seen. Reading list items are checked in the
+// same round, so a page read at one version shows as unread at the next.
+
+// maxNotifications caps the notifications kept for the session.
+const maxNotifications = 50
+
+// notification records a bookmarked document that changed.
+type notification struct {
+	url     string
+	title   string
+	version string
+	at      time.Time
+}
+
+// watchTickMsg starts a round of bookmark checks.
+type watchTickMsg struct{}
+
+// watchResult carries the versions observed in one round, keyed by URL:
+// of bookmarks in versions, of reading list items in reading. Documents
+// that could not be fetched are absent.
+type watchResult struct {
+	versions map[string]string
+	titles   map[string]string
+	reading  map[string]string
+}
+
+// watchTick schedules the next round, or nothing when watching is off.
+func watchTick(interval time.Duration) tea.Cmd {
+	if interval <= 0 {
+		return nil
+	}
+	return tea.Tick(interval, func(time.Time) tea.Msg { return watchTickMsg{} })
+}
+
+// watchCmd fetches every bookmark and reading list item and reports the
+// versions it saw.
+func (m model) watchCmd() tea.Cmd {
+	if m.bookmarkStore == nil && m.readingList == nil {
+		return nil
+	}
+	var marks []bookmarks.Bookmark
+	if m.bookmarkStore != nil {
+		marks = m.bookmarkStore.List()
+	}
+	var reading []string
+	if m.readingList != nil {
+		for _, it := range m.readingList.List() {
+			reading = append(reading, it.URL)
+		}
+	}
+	client := m.client
+	return func() tea.Msg {
+		res := watchResult{versions: make(map[string]string), titles: make(map[string]string), reading: make(map[string]string)}
+		fetched := make(map[string]string) // URL → version, each fetched once
+		version := func(url string) string {
+			if v, ok := fetched[url]; ok {
+				return v
+			}
+			fetched[url] = ""
+			host, path, err := fetch.ParseMarkURL(url)
+			if err != nil {
+				return ""
+			}
+			result, err := client.Fetch(host, path)
+			if err != nil || result.Err() != nil {
+				return ""
+			}
+			fetched[url] = documentVersion(result.Response.Metadata)
+			return fetched[url]
+		}
+		for _, b := range marks {
+			if v := version(b.URL); v != "" {
+				res.versions[b.URL] = v
+				res.titles[b.URL] = b.Title
+			}
+		}
+		for _, url := range reading {
+			if v := version(url); v != "" {
+				res.reading[url] = v
+			}
+		}
+		return res
+	}
+}
+
+// documentVersion identifies a document revision: its version number, or
+// its etag for servers that do not version.
+func documentVersion(meta map[string]string) string {
+	if v := meta["version"]; v != "" {
+		return v
+	}
+	return meta["etag"]
+}
+
+// detectChanges records observed versions in seen and returns the URLs whose
+// version differs from the one seen before, in sorted order. The first
+// observation of a URL only sets its baseline.
+func detectChanges(seen, observed map[string]string) []string {
+	var changed []string
+	for url, v := range observed {
+		if old, ok := seen[url]; ok && old != v {
+			changed = append(changed, url)
+		}
+		seen[url] = v
+	}
+	slices.Sort(changed)
+	return changed
+}
+
+func (m model) handleWatchResult(msg watchResult) (tea.Model, tea.Cmd) {
+	now := time.Now()
+	for _, url := range detectChanges(m.watchSeen, msg.versions) {
+		m.notifications = append(m.notifications, notification{
+			url:     url,
+			title:   msg.titles[url],
+			version: msg.versions[url],
+			at:      now,
+		})
+		m.unreadNotes++
+	}
+	if len(m.notifications) > maxNotifications {
+		m.notifications = m.notifications[len(m.notifications)-maxNotifications:]
+	}
+	if m.status == pageNotifications && !m.loading {
+		m = m.showLocalPage(pageNotifications, renderNotifications(m.notifications))
+		m.unreadNotes = 0
+	}
+	m = m.observeReading(msg.reading)
+	return m, watchTick(m.watchInterval)
+}
+
+// noteSeen updates the baseline of a watched document the user just
+// fetched, so reading the new version does not raise a notification.
+func (m *model) noteSeen(url string, meta map[string]string) {
+	if _, ok := m.watchSeen[url]; !ok {
+		return
+	}
+	if v := documentVersion(meta); v != "" {
+		m.watchSeen[url] = v
+	}
+}
+
+func (m model) handleNotificationsView() (tea.Model, tea.Cmd) {
+	m = m.showLocalPage(pageNotifications, renderNotifications(m.notifications))
+	m.unreadNotes = 0
+	return m, nil
+}
+
+// renderNotifications returns the notifications as a markdown document,
+// newest first.
+func renderNotifications(notes []notification) string {
+	var sb strings.Builder
+	sb.WriteString("# Notifications\n\n")
+	if len(notes) == 0 {
+		sb.WriteString("No changes yet. Bookmarked pages are checked in the background.\n")
+		return sb.String()
+	}
+	for i := len(notes) - 1; i >= 0; i-- {
+		n := notes[i]
+		title := n.title
+		if title == "" {
+			title = n.url
+		}
+		fmt.Fprintf(&sb, "- [%s](%s) — ", escapeLinkText(title), n.url)
+		if _, err := strconv.Atoi(n.version); err == nil {
+			fmt.Fprintf(&sb, "v%s, ", n.version)
+		}
+		fmt.Fprintf(&sb, "changed at %s\n", n.at.Format("15:04"))
+	}
+	return sb.String()
+}
+
+// notificationBadge is the status-bar marker for unread notifications.
+func notificationBadge(unread int) string {
+	if unread == 0 {
+		return ""
+	}
+	return fmt.Sprintf("✉ %d", unread)
+}
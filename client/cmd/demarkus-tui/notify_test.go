@@ -0,0 +1,78 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/latebit/demarkus/client/internal/fetch"
+	"github.com/latebit/demarkus/protocol"
+)
+
+func TestDetectChanges(t *testing.T) {
+	seen := map[string]string{}
+	if got := detectChanges(seen, map[string]string{"mark://h/a.md": "1", "mark://h/b.md": "4"}); len(got) != 0 {
+		t.Errorf("first round = %v, want no changes (baseline only)", got)
+	}
+	got := detectChanges(seen, map[string]string{"mark://h/a.md": "2", "mark://h/b.md": "4", "mark://h/c.md": "1"})
+	if !slices.Equal(got, []string{"mark://h/a.md"}) {
+		t.Errorf("second round = %v, want [mark://h/a.md]", got)
+	}
+	// A document missing from a round (fetch failed) keeps its baseline.
+	if got := detectChanges(seen, map[string]string{"mark://h/b.md": "4"}); len(got) != 0 {
+		t.Errorf("third round = %v, want no changes", got)
+	}
+	if seen["mark://h/a.md"] != "2" || seen["mark://h/c.md"] != "1" {
+		t.Errorf("seen = %v", seen)
+	}
+}
+
+func TestWatchNotifications(t *testing.T) {
+	m := model{histIdx: -1, linkIdx: -1, status: protocol.StatusOK, watchSeen: map[string]string{}}
+	next, _ := m.handleWatchResult(watchResult{versions: map[string]string{"mark://h/a.md": "1"}})
+	m = next.(model)
+	if m.unreadNotes != 0 {
+		t.Fatalf("unread = %d after baseline round, want 0", m.unreadNotes)
+	}
+
+	next, _ = m.handleWatchResult(watchResult{
+		versions: map[string]string{"mark://h/a.md": "2"},
+		titles:   map[string]string{"mark://h/a.md": "Alpha"},
+	})
+	m = next.(model)
+	if m.unreadNotes != 1 {
+		t.Fatalf("unread = %d, want 1", m.unreadNotes)
+	}
+	if got := m.statusBarView(); !strings.Contains(got, "✉ 1") {
+		t.Errorf("status bar = %q, want badge", got)
+	}
+
+	next, _ = m.handleNotificationsView()
+	m = next.(model)
+	if m.unreadNotes != 0 || m.status != pageNotifications {
+		t.Errorf("after opening: unread = %d, status = %q", m.unreadNotes, m.status)
+	}
+	if !strings.Contains(m.rawBody, "[Alpha](mark://h/a.md) — v2, changed at") {
+		t.Errorf("page = %q", m.rawBody)
+	}
+	if !slices.Equal(m.links, []string{"mark://h:6309/a.md"}) {
+		t.Errorf("links = %v", m.links)
+	}
+	if m = m.closeLocalPage(); m.status != "" {
+		t.Errorf("status after esc = %q, want empty", m.status)
+	}
+}
+
+func TestReadingResetsWatchBaseline(t *testing.T) {
+	m := model{histIdx: -1, linkIdx: -1, fetchSeq: 1, watchSeen: map[string]string{"mark://h/a.md": "1"}}
+	next, _ := m.handleFetchResult(fetchResult{
+		result: fetch.Result{Response: protocol.Response{Status: protocol.StatusOK, Metadata: map[string]string{"version": "3"}}},
+		url:    "mark://h/a.md",
+		seq:    1,
+	})
+	m = next.(model)
+	next, _ = m.handleWatchResult(watchResult{versions: map[string]string{"mark://h/a.md": "3"}})
+	if m = next.(model); m.unreadNotes != 0 {
+		t.Errorf("unread = %d for a version already read, want 0", m.unreadNotes)
+	}
+}
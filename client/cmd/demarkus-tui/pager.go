@@ -0,0 +1,97 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/latebit/demarkus/client/internal/fetch"
+)
+
+// defaultPager is run when neither $PAGER nor $EDITOR is set.
+const defaultPager = "less"
+
+// readOnlyFlags are the flags that open a file read-only in editors that
+// would otherwise let the user modify the buffer. Other programs rely on
+// the temp file's permissions.
+var readOnlyFlags = map[string]string{
+	"vi":   "-R",
+	"vim":  "-R",
+	"nvim": "-R",
+	"nano": "-v",
+}
+
+// pagerDoneMsg reports that the pager opened by openInPager has exited.
+type pagerDoneMsg struct {
+	file string
+	err  error
+}
+
+// pagerCommand returns the program and arguments that show file read-only:
+// $PAGER, else $EDITOR, else less.
+func pagerCommand(pager, editor, file string) (name string, args []string) {
+	fields := strings.Fields(pager)
+	if len(fields) == 0 {
+		fields = strings.Fields(editor)
+	}
+	if len(fields) == 0 {
+		fields = []string{defaultPager}
+	}
+	args = append(args, fields[1:]...)
+	if flag, ok := readOnlyFlags[filepath.Base(fields[0])]; ok {
+		args = append(args, flag)
+	}
+	return fields[0], append(args, file)
+}
+
+// pagerFilePattern names the temp file for url after the document, so the
+// pager shows something recognisable and editors pick markdown highlighting.
+func pagerFilePattern(url string) string {
+	name := "page"
+	if _, p, err := fetch.ParseMarkURL(url); err == nil && strings.Trim(p, "/") != "" {
+		name = strings.TrimSuffix(path.Base(p), ".md")
+	}
+	return "demarkus-" + name + "-*.md"
+}
+
+// openInPager writes the raw markdown of the current page to a read-only
+// temp file and hands the terminal to the user's pager. Nothing is
+// published: the file is removed when the pager exits.
+func (m model) openInPager() (tea.Model, tea.Cmd) {
+	if m.rawBody == "" || m.loading {
+		return m, nil
+	}
+	f, err := os.CreateTemp("", pagerFilePattern(m.addressBar.Value()))
+	if err != nil {
+		return m.flash("Failed to open page: " + err.Error())
+	}
+	_, err = f.WriteString(m.rawBody)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0o400)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return m.flash("Failed to open page: " + err.Error())
+	}
+
+	name, args := pagerCommand(os.Getenv("PAGER"), os.Getenv("EDITOR"), f.Name())
+	return m, tea.ExecProcess(exec.Command(name, args...), func(err error) tea.Msg {
+		return pagerDoneMsg{file: f.Name(), err: err}
+	})
+}
+
+// handlePagerDone removes the temp file and reports a pager that failed.
+func (m model) handlePagerDone(msg pagerDoneMsg) (tea.Model, tea.Cmd) {
+	_ = os.Remove(msg.file)
+	if msg.err != nil {
+		return m.flash("Pager failed: " + msg.err.Error())
+	}
+	return m, nil
+}
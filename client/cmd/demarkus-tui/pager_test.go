@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestPagerCommand(t *testing.T) {
+	tests := []struct {
+		pager, editor string
+		wantName      string
+		wantArgs      []string
+	}{
+		{"", "", "less", []string{"f.md"}},
+		{"less -R", "vim", "less", []string{"-R", "f.md"}},
+		{"", "vim", "vim", []string{"-R", "f.md"}},
+		{"", "/usr/bin/nvim --clean", "/usr/bin/nvim", []string{"--clean", "-R", "f.md"}},
+		{"", "nano", "nano", []string{"-v", "f.md"}},
+		{"", "code --wait", "code", []string{"--wait", "f.md"}},
+	}
+	for _, tt := range tests {
+		name, args := pagerCommand(tt.pager, tt.editor, "f.md")
+		if name != tt.wantName || !slices.Equal(args, tt.wantArgs) {
+			t.Errorf("pagerCommand(%q, %q) = %q %q, want %q %q", tt.pager, tt.editor, name, args, tt.wantName, tt.wantArgs)
+		}
+	}
+}
+
+func TestPagerFilePattern(t *testing.T) {
+	for url, want := range map[string]string{
+		"mark://h/docs/guide.md": "demarkus-guide-*.md",
+		"mark://h/":              "demarkus-page-*.md",
+		"not a url":              "demarkus-page-*.md",
+	} {
+		if got := pagerFilePattern(url); got != want {
+			t.Errorf("pagerFilePattern(%q) = %q, want %q", url, got, want)
+		}
+	}
+}
+
+func TestOpenInPager(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	m := model{histIdx: -1, rawBody: "# Long read\n"}
+	m.addressBar.SetValue("mark://h/essay.md")
+
+	next, cmd := m.openInPager()
+	if cmd == nil {
+		t.Fatal("no command to run the pager")
+	}
+	files, _ := filepath.Glob(filepath.Join(os.TempDir(), "demarkus-essay-*.md"))
+	if len(files) != 1 {
+		t.Fatalf("temp files = %v", files)
+	}
+	info, err := os.Stat(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm()&0o222 != 0 {
+		t.Errorf("temp file is writable: %v", info.Mode())
+	}
+	if data, _ := os.ReadFile(files[0]); string(data) != m.rawBody {
+		t.Errorf("temp file = %q", data)
+	}
+
+	next.(model).handlePagerDone(pagerDoneMsg{file: files[0]})
+	if _, err := os.Stat(files[0]); !os.IsNotExist(err) {
+		t.Errorf("temp file not removed: %v", err)
+	}
+
+	if _, cmd := (model{histIdx: -1}).openInPager(); cmd != nil {
+		t.Error("opened a pager with no page")
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/latebit/demarkus/client/internal/fetch"
+	"github.com/latebit/demarkus/client/internal/links"
+)
+
+// linkTitleCacheSize bounds the number of prefetched link titles kept in memory.
+const linkTitleCacheSize = 128
+
+// linkTitleBytes is how much of a linked document a link preview fetches
+// to find its title, which is its first heading and so near the top.
+const linkTitleBytes = 4096
+
+// linkTitleCache is a small FIFO cache of link URL → document title used for
+// link previews. An empty cached title records a lookup that completed
+// without finding a heading (or failed), so the link is not fetched again.
+type linkTitleCache struct {
+	titles  map[string]string
+	pending map[string]bool
+	order   []string
+	size    int
+}
+
+func newLinkTitleCache(size int) *linkTitleCache {
+	return &linkTitleCache{
+		titles:  make(map[string]string),
+		pending: make(map[string]bool),
+		size:    size,
+	}
+}
+
+// get returns the cached title for url and whether a lookup has completed.
+func (c *linkTitleCache) get(url string) (string, bool) {
+	title, ok := c.titles[url]
+	return title, ok
+}
+
+// put records the title for url, evicting the oldest entry when full.
+func (c *linkTitleCache) put(url, title string) {
+	delete(c.pending, url)
+	if _, ok := c.titles[url]; ok {
+		c.titles[url] = title
+		return
+	}
+	if len(c.order) >= c.size {
+		oldest := c.order[0]
+		c.order = c.order[1:]
+		delete(c.titles, oldest)
+	}
+	c.titles[url] = title
+	c.order = append(c.order, url)
+}
+
+// linkTitleResult is sent when a background title prefetch completes.
+type linkTitleResult struct {
+	url   string
+	title string
+}
+
+// prefetchLinkTitle returns a tea.Cmd that fetches the first
+// linkTitleBytes of target in the background and extracts its title, so
+// hovering a link to a large document does not transfer all of it.
+// Returns nil for non-mark links, for links that are already cached or in
+// flight, and for links to another host while following those needs
+// confirmation, so that host sees no request before the user agrees.
+func (m model) prefetchLinkTitle(target string) tea.Cmd {
+	if m.linkTitles == nil || !strings.HasPrefix(target, "mark://") {
+		return nil
+	}
+	if _, ok := m.linkTitles.get(target); ok || m.linkTitles.pending[target] {
+		return nil
+	}
+	if m.confirmCrossHost {
+		if _, ok := crossHostTarget(m.addressBar.Value(), target); ok {
+			return nil
+		}
+	}
+	m.linkTitles.pending[target] = true
+	client := m.client
+	return func() tea.Msg {
+		host, path, err := fetch.ParseMarkURL(target)
+		if err != nil {
+			return linkTitleResult{url: target}
+		}
+		r, err := client.FetchRange(host, path, 0, linkTitleBytes-1)
+		if err != nil || r.Err() != nil || isAttachment(r.Response.Metadata) {
+			return linkTitleResult{url: target}
+		}
+		return linkTitleResult{url: target, title: links.ExtractTitle(r.Response.Body)}
+	}
+}
+
+// linkPreview formats the status bar hint for the selected link, prefixing
+// the target with its title once known.
+func linkPreview(idx, total int, target, title string) string {
+	if title == "" {
+		return fmt.Sprintf("[%d/%d] %s", idx+1, total, target)
+	}
+	return fmt.Sprintf("[%d/%d] %s — %s", idx+1, total, title, target)
+}
+
+// prefetchResult is sent when the links of a page have been prefetched.
+type prefetchResult struct {
+	titles map[string]string // link URL → title
+}
+
+// prefetchLinks returns a tea.Cmd that warms the cache with the documents
+// linked from page, so following one is instant. The titles it learns on
+// the way feed the link previews. Returns nil when prefetching is off.
+func (m model) prefetchLinks(page string) tea.Cmd {
+	if !m.prefetch || len(m.links) == 0 {
+		return nil
+	}
+	host, _, err := fetch.ParseMarkURL(page)
+	if err != nil {
+		return nil
+	}
+	client, targets := m.client, m.links
+	return func() tea.Msg {
+		responses := client.Prefetch(context.Background(), host, targets, fetch.PrefetchOptions{})
+		titles := make(map[string]string, len(responses))
+		for url, resp := range responses {
+			var title string
+			if !isAttachment(resp.Metadata) {
+				title = links.ExtractTitle(resp.Body)
+			}
+			titles[url] = title
+		}
+		return prefetchResult{titles: titles}
+	}
+}
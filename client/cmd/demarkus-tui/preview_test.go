@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/bubbles/textinput"
+)
+
+func TestLinkTitleCache(t *testing.T) {
+	c := newLinkTitleCache(2)
+
+	if _, ok := c.get("mark://h/a.md"); ok {
+		t.Fatal("empty cache reported a hit")
+	}
+
+	c.put("mark://h/a.md", "A")
+	c.put("mark://h/b.md", "")
+	if title, ok := c.get("mark://h/a.md"); !ok || title != "A" {
+		t.Errorf("get(a) = %q, %v; want %q, true", title, ok, "A")
+	}
+	if title, ok := c.get("mark://h/b.md"); !ok || title != "" {
+		t.Errorf("get(b) = %q, %v; want empty hit", title, ok)
+	}
+
+	// Adding a third entry evicts the oldest.
+	c.put("mark://h/c.md", "C")
+	if _, ok := c.get("mark://h/a.md"); ok {
+		t.Error("oldest entry was not evicted")
+	}
+	if title, _ := c.get("mark://h/c.md"); title != "C" {
+		t.Errorf("get(c) = %q, want %q", title, "C")
+	}
+
+	// Updating an existing entry does not evict.
+	c.put("mark://h/b.md", "B")
+	if title, _ := c.get("mark://h/c.md"); title != "C" {
+		t.Errorf("update evicted c: got %q", title)
+	}
+}
+
+func TestPrefetchLinkTitleSkips(t *testing.T) {
+	m := model{linkTitles: newLinkTitleCache(4)}
+
+	if cmd := m.prefetchLinkTitle("https://example.com"); cmd != nil {
+		t.Error("expected nil cmd for non-mark link")
+	}
+
+	m.linkTitles.put("mark://h/a.md", "A")
+	if cmd := m.prefetchLinkTitle("mark://h/a.md"); cmd != nil {
+		t.Error("expected nil cmd for cached link")
+	}
+
+	if cmd := m.prefetchLinkTitle("mark://h/b.md"); cmd == nil {
+		t.Fatal("expected cmd for uncached link")
+	}
+	if cmd := m.prefetchLinkTitle("mark://h/b.md"); cmd != nil {
+		t.Error("expected nil cmd for in-flight link")
+	}
+}
+
+func TestPrefetchLinkTitleCrossHost(t *testing.T) {
+	m := model{linkTitles: newLinkTitleCache(4), addressBar: textinput.New(), confirmCrossHost: true}
+	m.addressBar.SetValue("mark://h/index.md")
+
+	if cmd := m.prefetchLinkTitle("mark://other/a.md"); cmd != nil {
+		t.Error("expected nil cmd for link to another host")
+	}
+	if m.linkTitles.pending["mark://other/a.md"] {
+		t.Error("link to another host marked in flight")
+	}
+	if cmd := m.prefetchLinkTitle("mark://h/a.md"); cmd == nil {
+		t.Error("expected cmd for link on the same host")
+	}
+
+	m.confirmCrossHost = false
+	if cmd := m.prefetchLinkTitle("mark://other/a.md"); cmd == nil {
+		t.Error("expected cmd for link to another host without confirmation")
+	}
+}
+
+func TestLinkPreview(t *testing.T) {
+	if got, want := linkPreview(0, 3, "mark://h/a.md", ""), "[1/3] mark://h/a.md"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := linkPreview(1, 3, "mark://h/a.md", "Alpha"), "[2/3] Alpha — mark://h/a.md"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
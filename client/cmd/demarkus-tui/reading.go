@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// readingNote describes a page's length from its word-count and
+// reading-time metadata, as "1,250 words, 7 min", or "" when the server
+// sent none.
+func readingNote(metadata map[string]string) string {
+	words, err := strconv.Atoi(metadata["word-count"])
+	if err != nil || words < 0 {
+		return ""
+	}
+	note := groupThousands(words) + " words"
+	if words == 1 {
+		note = "1 word"
+	}
+	if mins, err := strconv.Atoi(metadata["reading-time"]); err == nil && mins > 0 {
+		note += fmt.Sprintf(", %d min", mins)
+	}
+	return note
+}
+
+// groupThousands formats n with commas between groups of three digits.
+func groupThousands(n int) string {
+	s := strconv.Itoa(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/latebit/demarkus/protocol"
+)
+
+func TestReadingNote(t *testing.T) {
+	tests := []struct {
+		meta map[string]string
+		want string
+	}{
+		{nil, ""},
+		{map[string]string{"word-count": "many"}, ""},
+		{map[string]string{"word-count": "0", "reading-time": "0"}, "0 words"},
+		{map[string]string{"word-count": "1", "reading-time": "1"}, "1 word, 1 min"},
+		{map[string]string{"word-count": "1250", "reading-time": "7"}, "1,250 words, 7 min"},
+		{map[string]string{"word-count": "1234567"}, "1,234,567 words"},
+	}
+	for _, tt := range tests {
+		if got := readingNote(tt.meta); got != tt.want {
+			t.Errorf("readingNote(%v) = %q, want %q", tt.meta, got, tt.want)
+		}
+	}
+
+	m := model{addressBar: textinput.New(), status: protocol.StatusOK, histIdx: -1, linkIdx: -1,
+		metadata: map[string]string{"version": "2", "word-count": "450", "reading-time": "3"}}
+	if got := m.statusBarView(); !strings.Contains(got, "450 words, 3 min") {
+		t.Errorf("status bar = %q", got)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/latebit/demarkus/client/internal/links"
+)
+
+// maxStartPageItems caps the unread items listed on the start page.
+const maxStartPageItems = 10
+
+// handleReadLaterToggle adds the current page to the reading list, or
+// removes it if it is already there.
+func (m model) handleReadLaterToggle() (tea.Model, tea.Cmd) {
+	url := m.addressBar.Value()
+	if url == "" || m.readingList == nil || isLocalPage(m.status) {
+		return m, nil
+	}
+	if m.readingList.Has(url) {
+		if err := m.readingList.Remove(url); err != nil {
+			return m.flash("Failed to update reading list: " + err.Error())
+		}
+		return m.flash("Removed from reading list")
+	}
+	title := links.ExtractTitle(m.rawBody)
+	if title == "" {
+		title = url
+	}
+	if err := m.readingList.Add(url, title, documentVersion(m.metadata)); err != nil {
+		return m.flash("Failed to update reading list: " + err.Error())
+	}
+	return m.flash("Saved to reading list")
+}
+
+// markRead records that the fetched version of a listed page was read.
+func (m *model) markRead(url string, meta map[string]string) {
+	if m.readingList == nil || !m.readingList.Has(url) {
+		return
+	}
+	if err := m.readingList.MarkRead(url, documentVersion(meta)); err != nil {
+		m.bookmarkMsg = "Failed to update reading list: " + err.Error()
+	}
+}
+
+// observeReading records versions seen by the watcher, making changed
+// items unread again. The open reading list page is refreshed.
+func (m model) observeReading(versions map[string]string) model {
+	if m.readingList == nil {
+		return m
+	}
+	for url, v := range versions {
+		if _, err := m.readingList.Observe(url, v); err != nil {
+			m.bookmarkMsg = "Failed to update reading list: " + err.Error()
+		}
+	}
+	if m.status == pageReadingList && !m.loading {
+		m = m.showLocalPage(pageReadingList, m.readingList.Render())
+	}
+	return m
+}
+
+func (m model) handleReadingListView() (tea.Model, tea.Cmd) {
+	if m.readingList == nil {
+		return m, nil
+	}
+	return m.showLocalPage(pageReadingList, m.readingList.Render()), nil
+}
+
+// renderStartPage returns the page shown when the TUI starts without a
+// URL: how to begin, and what is waiting on the reading list.
+func (m model) renderStartPage() string {
+	var sb strings.Builder
+	sb.WriteString("# Demarkus\n\n")
+	sb.WriteString("Type a `mark://` URL in the address bar and press Enter, or `?words` to search pages you have visited. Press `?` for help, or read the [tutorial](tutorial:).\n")
+
+	if m.readingList != nil {
+		items := m.readingList.List()
+		unread := m.readingList.UnreadCount()
+		sb.WriteString("\n## Reading list\n\n")
+		switch {
+		case len(items) == 0:
+			sb.WriteString("Empty. Press `R` on any page to save it for later.\n")
+		case unread == 0:
+			fmt.Fprintf(&sb, "All %d saved pages read. Press `L` to open the list.\n", len(items))
+		default:
+			fmt.Fprintf(&sb, "%d unread of %d saved. Press `L` to open the list.\n\n", unread, len(items))
+			shown := 0
+			for _, it := range items {
+				if !it.Unread() || shown == maxStartPageItems {
+					continue
+				}
+				fmt.Fprintf(&sb, "- [%s](%s)", escapeLinkText(it.Title), it.URL)
+				if it.Updated() {
+					sb.WriteString(" — updated")
+				}
+				sb.WriteString("\n")
+				shown++
+			}
+		}
+	}
+	if m.bookmarkStore != nil {
+		if n := len(m.bookmarkStore.List()); n > 0 {
+			fmt.Fprintf(&sb, "\n## Bookmarks\n\n%d bookmarked pages. Press `B` to list them.\n", n)
+		}
+	}
+	return sb.String()
+}
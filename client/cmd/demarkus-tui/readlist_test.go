@@ -0,0 +1,65 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/latebit/demarkus/client/internal/fetch"
+	"github.com/latebit/demarkus/client/internal/readinglist"
+	"github.com/latebit/demarkus/protocol"
+)
+
+func TestReadingList(t *testing.T) {
+	rl, err := readinglist.Load(filepath.Join(t.TempDir(), "readinglist.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const url = "mark://h/long.md"
+	m := model{
+		addressBar:  textinput.New(),
+		focus:       focusViewport,
+		histIdx:     -1,
+		linkIdx:     -1,
+		status:      protocol.StatusOK,
+		metadata:    map[string]string{"version": "1"},
+		rawBody:     "# Long Read\n",
+		readingList: rl,
+		watchSeen:   map[string]string{},
+	}
+	m.addressBar.SetValue(url)
+
+	next, _ := m.handleKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("R")})
+	m = next.(model)
+	if !rl.Has(url) || rl.UnreadCount() != 1 {
+		t.Fatalf("after R: %+v", rl.List())
+	}
+	if page := m.renderStartPage(); !strings.Contains(page, "1 unread of 1 saved") || !strings.Contains(page, "[Long Read]("+url+")") {
+		t.Errorf("start page:\n%s", page)
+	}
+
+	// Opening the page marks it read at the fetched version.
+	fetched := fetchResult{url: url, seq: m.fetchSeq, result: fetch.Result{Response: protocol.Response{
+		Status: protocol.StatusOK, Metadata: map[string]string{"version": "1"}, Body: "# Long Read\n",
+	}}}
+	next, _ = m.handleFetchResult(fetched)
+	m = next.(model)
+	if rl.UnreadCount() != 0 {
+		t.Fatalf("after reading: %+v", rl.List())
+	}
+
+	// The watcher sees v2: unread again.
+	next, _ = m.handleWatchResult(watchResult{reading: map[string]string{url: "2"}})
+	m = next.(model)
+	if rl.UnreadCount() != 1 || !rl.List()[0].Updated() {
+		t.Errorf("after new version: %+v", rl.List())
+	}
+
+	next, _ = m.handleKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("L")})
+	m = next.(model)
+	if m.status != pageReadingList || !strings.Contains(m.rawBody, "— updated (v2)") {
+		t.Errorf("reading list page %q:\n%s", m.status, m.rawBody)
+	}
+}
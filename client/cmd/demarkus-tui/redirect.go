@@ -0,0 +1,11 @@
+package main
+
+import "strings"
+
+// redirectNote describes the chain that led to the current page.
+func redirectNote(redirects []string) string {
+	if len(redirects) == 0 {
+		return ""
+	}
+	return "redirected from " + strings.Join(redirects, " → ")
+}
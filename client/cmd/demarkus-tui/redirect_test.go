@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/latebit/demarkus/client/internal/fetch"
+	"github.com/latebit/demarkus/protocol"
+)
+
+func TestFollowRedirect(t *testing.T) {
+	m := model{fetchSeq: 3, histIdx: -1}
+	msg := fetchResult{
+		result: fetch.Result{
+			Response:  protocol.Response{Status: protocol.StatusOK, Body: "# New\n"},
+			URL:       "mark://h:6309/new.md",
+			Redirects: []string{"mark://h:6309/old.md"},
+		},
+		url: "mark://h:6309/old.md",
+		seq: 3,
+	}
+	next, _ := m.handleFetchResult(msg)
+	got := next.(model)
+	if got.addressBar.Value() != "mark://h:6309/new.md" {
+		t.Errorf("address bar = %q, want mark://h:6309/new.md", got.addressBar.Value())
+	}
+	if e := got.history[got.histIdx]; e.url != "mark://h:6309/new.md" || len(e.redirects) != 1 {
+		t.Errorf("history entry = %q redirected from %v", e.url, e.redirects)
+	}
+	if note := redirectNote(got.redirects); note != "redirected from mark://h:6309/old.md" {
+		t.Errorf("note = %q", note)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/latebit/demarkus/client/internal/search"
+)
+
+// maxSearchHits caps the results shown on the search page.
+const maxSearchHits = 50
+
+// searchResult carries the hits of a local search.
+type searchResult struct {
+	query string
+	hits  []search.Hit
+	total int // documents searched
+	err   error
+	seq   uint64
+}
+
+// openSearchPrompt focuses the address bar with the search prefix typed.
+func (m model) openSearchPrompt() model {
+	m.focus = focusAddressBar
+	m.addressBar.Focus()
+	m.addressBar.SetValue("?")
+	m.addressBar.CursorEnd()
+	return m
+}
+
+// startSearch indexes the cache and searches it in the background.
+func (m model) startSearch(query string) (tea.Model, tea.Cmd) {
+	query = strings.TrimSpace(query)
+	if query == "" || m.cache == nil {
+		return m, nil
+	}
+	m.loading = true
+	m.fetchSeq++
+	m.err = nil
+	m.focus = focusViewport
+	m.addressBar.Blur()
+	seq, c := m.fetchSeq, m.cache
+	return m, func() tea.Msg {
+		idx, err := search.FromCache(c)
+		if err != nil {
+			return searchResult{query: query, err: err, seq: seq}
+		}
+		return searchResult{query: query, hits: idx.Search(query, maxSearchHits), total: idx.Len(), seq: seq}
+	}
+}
+
+func (m model) handleSearchResult(msg searchResult) (tea.Model, tea.Cmd) {
+	if msg.seq != m.fetchSeq {
+		return m, nil
+	}
+	if msg.err != nil {
+		m.loading = false
+		m.err = fmt.Errorf("search: %w", msg.err)
+		return m, nil
+	}
+	return m.showLocalPage(pageSearch, renderSearch(msg.query, msg.hits, msg.total)), nil
+}
+
+// renderSearch returns the results of a local search as a markdown page.
+func renderSearch(query string, hits []search.Hit, total int) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Search: %s\n\n", query)
+	if len(hits) == 0 {
+		fmt.Fprintf(&sb, "No matches in %d cached documents. Only pages fetched before are searched.\n", total)
+		return sb.String()
+	}
+	fmt.Fprintf(&sb, "%d matches in %d cached documents.\n\n", len(hits), total)
+	for _, h := range hits {
+		title := h.Title
+		if title == "" {
+			title = h.URL
+		}
+		fmt.Fprintf(&sb, "- [%s](%s)", escapeLinkText(title), h.URL)
+		if h.Snippet != "" {
+			// Escaped so links in the snippet are not navigable.
+			fmt.Fprintf(&sb, " — %s", escapeLinkText(h.Snippet))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/latebit/demarkus/client/internal/cache"
+	"github.com/latebit/demarkus/protocol"
+)
+
+func TestLocalSearchPage(t *testing.T) {
+	c := cache.New(t.TempDir())
+	body := "# Transport\n\nRuns over QUIC, see [the spec](/spec.md).\n"
+	if err := c.Put("h:6309", "/transport.md", protocol.VerbFetch, protocol.Response{Status: protocol.StatusOK, Body: body}); err != nil {
+		t.Fatal(err)
+	}
+
+	m := model{addressBar: textinput.New(), histIdx: -1, linkIdx: -1, cache: c}
+	m = m.openSearchPrompt()
+	m.addressBar.SetValue("?quic")
+	next, cmd := m.handleKey(tea.KeyMsg{Type: tea.KeyEnter})
+	m = next.(model)
+	if !m.loading || cmd == nil {
+		t.Fatal("search did not start")
+	}
+	next, _ = m.Update(cmd())
+	m = next.(model)
+
+	if m.status != pageSearch || m.loading {
+		t.Fatalf("status = %q, loading = %v", m.status, m.loading)
+	}
+	if !strings.Contains(m.rawBody, "[Transport](mark://h:6309/transport.md)") {
+		t.Errorf("page = %q", m.rawBody)
+	}
+	// Only the result is a link; the snippet's link is escaped.
+	if !slices.Equal(m.links, []string{"mark://h:6309/transport.md"}) {
+		t.Errorf("links = %v", m.links)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/latebit/demarkus/client/internal/fetch"
+)
+
+// permalink returns the URL of the version of the document at url that
+// meta describes, so a shared link keeps pointing at what was read rather
+// than at whatever the path holds later.
+func permalink(url string, meta map[string]string) (string, error) {
+	v, ok := pageVersion(meta)
+	if !ok {
+		return "", fmt.Errorf("only versioned documents have permalinks")
+	}
+	host, path, err := fetch.ParseMarkURL(url)
+	if err != nil {
+		return "", err
+	}
+	// A pinned version already ends in /vN, and its response says which
+	// version is current.
+	if _, pinned := meta["current-version"]; pinned {
+		path = strings.TrimSuffix(path, "/v"+strconv.Itoa(v))
+	}
+	return fmt.Sprintf("mark://%s%s/v%d", host, path, v), nil
+}
+
+// osc52 returns the escape sequence asking the terminal to put text on the
+// system clipboard, which also works over SSH. Inside tmux the sequence is
+// passed through to the outer terminal.
+func osc52(text string, tmux bool) string {
+	seq := "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a"
+	if tmux {
+		seq = "\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + "\x1b\\"
+	}
+	return seq
+}
+
+// copyPermalink copies the permalink of the page on screen to the
+// clipboard, and shows it in the status bar for terminals that do not
+// support that.
+func (m model) copyPermalink() (tea.Model, tea.Cmd) {
+	if isLocalPage(m.status) || m.rawBody == "" || m.loading {
+		return m, nil
+	}
+	link, err := permalink(m.addressBar.Value(), m.metadata)
+	if err != nil {
+		return m.flash("No permalink: " + err.Error())
+	}
+	if _, err := fmt.Fprint(os.Stdout, osc52(link, os.Getenv("TMUX") != "")); err != nil {
+		return m.flash("Permalink: " + link)
+	}
+	return m.flash("Copied " + link)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestPermalink(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		meta map[string]string
+		want string
+	}{
+		{"current", "mark://h:6309/doc.md", map[string]string{"version": "7"}, "mark://h:6309/doc.md/v7"},
+		{"pinned", "mark://h:6309/doc.md/v3", map[string]string{"version": "3", "current-version": "7"}, "mark://h:6309/doc.md/v3"},
+		{"doc named like a version", "mark://h:6309/notes/v2", map[string]string{"version": "2"}, "mark://h:6309/notes/v2/v2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := permalink(tt.url, tt.meta)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("permalink = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := permalink("mark://h:6309/docs/", map[string]string{"entries": "3"}); err == nil {
+		t.Error("permalink of a directory listing: no error")
+	}
+}
+
+func TestOSC52(t *testing.T) {
+	link := "mark://h:6309/doc.md/v7"
+	enc := base64.StdEncoding.EncodeToString([]byte(link))
+	if got, want := osc52(link, false), "\x1b]52;c;"+enc+"\a"; got != want {
+		t.Errorf("osc52 = %q, want %q", got, want)
+	}
+	got := osc52(link, true)
+	if !strings.HasPrefix(got, "\x1bPtmux;\x1b\x1b]52;c;"+enc) || !strings.HasSuffix(got, "\x1b\\") {
+		t.Errorf("osc52 in tmux = %q", got)
+	}
+}
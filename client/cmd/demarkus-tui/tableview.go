@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// tableScrollStep is how many columns h/l shift the table view.
+const tableScrollStep = 8
+
+// extractTables returns the markdown tables in body, each as its raw lines.
+// A table is a run of pipe-prefixed lines whose second line is a delimiter
+// row (e.g. |---|:--:|). Tables inside fenced code blocks are ignored.
+func extractTables(body string) [][]string {
+	var tables [][]string
+	var cur []string
+	inFence := false
+	flush := func() {
+		if len(cur) >= 2 && isTableDelimiter(cur[1]) {
+			tables = append(tables, cur)
+		}
+		cur = nil
+	}
+	for line := range strings.SplitSeq(body, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			flush()
+			inFence = !inFence
+			continue
+		}
+		if !inFence && strings.HasPrefix(trimmed, "|") {
+			cur = append(cur, trimmed)
+			continue
+		}
+		flush()
+	}
+	flush()
+	return tables
+}
+
+// isTableDelimiter reports whether line is a markdown table delimiter row.
+func isTableDelimiter(line string) bool {
+	cells := splitTableRow(line)
+	if len(cells) == 0 {
+		return false
+	}
+	for _, c := range cells {
+		c = strings.Trim(c, ":")
+		if c == "" || strings.Trim(c, "-") != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// splitTableRow splits a pipe-delimited row into trimmed cells, honouring
+// escaped pipes (\|) inside cells.
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\\' && i+1 < len(line) && line[i+1] == '|' {
+			cell.WriteByte('|')
+			i++
+			continue
+		}
+		if line[i] == '|' {
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+			continue
+		}
+		cell.WriteByte(line[i])
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// formatTable lays out raw table lines as aligned, unwrapped text rows.
+func formatTable(raw []string) []string {
+	var rows [][]string
+	for i, line := range raw {
+		if i == 1 {
+			continue // delimiter row
+		}
+		rows = append(rows, splitTableRow(line))
+	}
+	var widths []int
+	for _, row := range rows {
+		for i, cell := range row {
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], lipgloss.Width(cell))
+		}
+	}
+
+	out := make([]string, 0, len(rows)+1)
+	for r, row := range rows {
+		cells := make([]string, len(widths))
+		for i, w := range widths {
+			var cell string
+			if i < len(row) {
+				cell = row[i]
+			}
+			cells[i] = cell + strings.Repeat(" ", w-lipgloss.Width(cell))
+		}
+		out = append(out, strings.Join(cells, " │ "))
+		if r == 0 {
+			seps := make([]string, len(widths))
+			for i, w := range widths {
+				seps[i] = strings.Repeat("─", w)
+			}
+			out = append(out, strings.Join(seps, "─┼─"))
+		}
+	}
+	return out
+}
+
+// tableWidth returns the display width of the widest formatted row.
+func tableWidth(lines []string) int {
+	w := 0
+	for _, l := range lines {
+		w = max(w, lipgloss.Width(l))
+	}
+	return w
+}
+
+// wideTables returns the formatted tables in body that do not fit in width
+// columns and would therefore be mangled by word wrap.
+func wideTables(body string, width int) [][]string {
+	var wide [][]string
+	for _, raw := range extractTables(body) {
+		lines := formatTable(raw)
+		if tableWidth(lines) > width {
+			wide = append(wide, lines)
+		}
+	}
+	return wide
+}
+
+// hscroll returns lines shifted left by offset columns and clipped to width.
+// Columns are terminal cells, as lipgloss.Width counts them, so a wide
+// character cut in half at either edge is dropped.
+func hscroll(lines []string, offset, width int) string {
+	var b strings.Builder
+	for _, l := range lines {
+		b.WriteString(ansi.Cut(l, offset, offset+width))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// renderTableView renders the active wide table at the current offset.
+func (m model) renderTableView() string {
+	if m.tableIdx >= len(m.tables) {
+		return ""
+	}
+	width := max(m.width-4, 1)
+	header := fmt.Sprintf("\n  Table %d of %d\n\n", m.tableIdx+1, len(m.tables))
+	body := hscroll(m.tables[m.tableIdx], m.tableOffset, width)
+	return header + indent(body, "  ")
+}
+
+// indent prefixes every non-empty line of s with prefix.
+func indent(s, prefix string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		if l != "" {
+			lines[i] = prefix + l
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// openTableView shows the next wide table of the current page, cycling
+// through them on repeated presses.
+func (m model) openTableView() (tea.Model, tea.Cmd) {
+	if m.viewMode != viewTable {
+		m.tables = wideTables(m.rawBody, max(m.width-4, 1))
+		if len(m.tables) == 0 {
+			return m, nil
+		}
+		m.tableIdx = 0
+	} else {
+		m.tableIdx = (m.tableIdx + 1) % len(m.tables)
+	}
+	m.viewMode = viewTable
+	m.tableOffset = 0
+	if m.ready {
+		m.viewport.SetContent(m.renderTableView())
+		m.viewport.GotoTop()
+	}
+	return m, nil
+}
+
+// handleTableKey processes key events when the table view is active.
+func (m model) handleTableKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "q":
+		return m, tea.Quit
+	case "esc":
+		m.viewMode = viewDocument
+		m.tables = nil
+		if m.histIdx >= 0 {
+			m.restoreHistory()
+		}
+		return m, nil
+	case "t":
+		return m.openTableView()
+	case "h", "left":
+		m.tableOffset = max(m.tableOffset-tableScrollStep, 0)
+	case "l", "right":
+		maxOffset := max(tableWidth(m.tables[m.tableIdx])-(m.width-4), 0)
+		m.tableOffset = min(m.tableOffset+tableScrollStep, maxOffset)
+	case "0", "home":
+		m.tableOffset = 0
+	default:
+		var cmd tea.Cmd
+		m.viewport, cmd = m.viewport.Update(msg)
+		return m, cmd
+	}
+	if m.ready {
+		m.viewport.SetContent(m.renderTableView())
+	}
+	return m, nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const tableDoc = "# Doc\n\n" +
+	"| Name | Description |\n" +
+	"|------|:-----------:|\n" +
+	"| a | a rather long description that will not fit in a narrow terminal |\n" +
+	"| b \\| c | short |\n" +
+	"\n```\n| not | a table |\n|---|---|\n```\n"
+
+func TestExtractTables(t *testing.T) {
+	tables := extractTables(tableDoc)
+	if len(tables) != 1 {
+		t.Fatalf("got %d tables, want 1 (fenced table must be ignored)", len(tables))
+	}
+	if len(tables[0]) != 4 {
+		t.Errorf("got %d lines, want 4", len(tables[0]))
+	}
+	if extractTables("| just | pipes |\n| no | delimiter |\n") != nil {
+		t.Error("rows without a delimiter line are not a table")
+	}
+}
+
+func TestSplitTableRow(t *testing.T) {
+	got := splitTableRow(`| b \| c | short |`)
+	if len(got) != 2 || got[0] != "b | c" || got[1] != "short" {
+		t.Errorf("got %q, want [\"b | c\" \"short\"]", got)
+	}
+}
+
+func TestFormatTable(t *testing.T) {
+	lines := formatTable(extractTables(tableDoc)[0])
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines, want header, divider and 2 rows", len(lines))
+	}
+	w := tableWidth(lines)
+	for i, l := range lines {
+		if !strings.Contains(l, "│") && !strings.Contains(l, "┼") {
+			t.Errorf("line %d missing column separator: %q", i, l)
+		}
+		if i != 1 && len([]rune(strings.TrimRight(l, " "))) > w {
+			t.Errorf("line %d wider than table: %q", i, l)
+		}
+	}
+}
+
+func TestWideTables(t *testing.T) {
+	if got := len(wideTables(tableDoc, 200)); got != 0 {
+		t.Errorf("wide at 200 cols: got %d, want 0", got)
+	}
+	if got := len(wideTables(tableDoc, 40)); got != 1 {
+		t.Errorf("wide at 40 cols: got %d, want 1", got)
+	}
+}
+
+func TestHscroll(t *testing.T) {
+	got := hscroll([]string{"abcdefgh", "xy"}, 2, 3)
+	if want := "cde\n\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// Wide characters take two columns each.
+	got = hscroll([]string{"| 東京都 | x |"}, 2, 4)
+	if want := "東京\n"; got != want {
+		t.Errorf("wide: got %q, want %q", got, want)
+	}
+	if w := lipgloss.Width(hscroll([]string{"| 東京都 | x |"}, 3, 4)); w > 4 {
+		t.Errorf("wide at an odd offset: width %d, want at most 4", w)
+	}
+}
+
+func TestTableViewScroll(t *testing.T) {
+	m := model{rawBody: tableDoc, width: 40, histIdx: -1}
+	next, _ := m.openTableView()
+	m = next.(model)
+	if m.viewMode != viewTable {
+		t.Fatal("expected table view to open")
+	}
+
+	right := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'l'}}
+	for range 50 {
+		next, _ = m.handleTableKey(right)
+		m = next.(model)
+	}
+	if maxOffset := tableWidth(m.tables[0]) - 36; m.tableOffset != maxOffset {
+		t.Errorf("offset = %d, want clamp at %d", m.tableOffset, maxOffset)
+	}
+
+	next, _ = m.handleTableKey(tea.KeyMsg{Type: tea.KeyEscape})
+	if next.(model).viewMode != viewDocument {
+		t.Error("esc should return to the document")
+	}
+
+	narrow := model{rawBody: "no tables here", width: 40}
+	next, _ = narrow.openTableView()
+	if next.(model).viewMode != viewDocument {
+		t.Error("t without wide tables should stay in document view")
+	}
+}
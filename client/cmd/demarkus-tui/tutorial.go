@@ -0,0 +1,135 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/latebit/demarkus/client/internal/bookmarks"
+	"github.com/latebit/demarkus/client/internal/cache"
+	"github.com/latebit/demarkus/client/internal/readinglist"
+)
+
+// tutorialScheme prefixes links between the pages of the built-in
+// tutorial, which the TUI shows itself instead of fetching.
+const tutorialScheme = "tutorial:"
+
+// tutorialPages are the pages of the tutorial by name. The first, "", is
+// shown on first run and linked from the start page.
+var tutorialPages = map[string]string{
+	"": `# Welcome to Demarkus
+
+Demarkus reads markdown documents served over the Mark Protocol, at
+addresses that start with ` + "`mark://`" + `. This short tutorial is built in:
+it works offline, and each page links to the next.
+
+Links are followed with the keyboard. Press **Tab** now to select the first
+link below (its address shows in the status bar), **Tab** again for the
+next one, and **Enter** to open the selected link.
+
+1. [Moving around](tutorial:keys)
+2. [The address bar](tutorial:address)
+3. [History and saving pages](tutorial:history)
+4. [Publishing with tokens](tutorial:tokens)
+
+Press **Esc** to leave the tutorial at any time, and **?** for the full
+list of keys. The start page links back here.
+`,
+
+	"keys": `# Moving around
+
+You just followed a link: that is most of what there is to browsing.
+
+- **j** / **k** or the arrow keys scroll; **g** and **G** jump to the top
+  and bottom.
+- **Tab** cycles through the links of a page and **Enter** follows the
+  selected one. Links to another server ask before leaving.
+- **i** shows the metadata the server sent with a page: its version,
+  when it was modified, its hash.
+- **d** draws the graph of documents linked from the current one.
+- **q** quits.
+
+Next: [The address bar](tutorial:address) · [Contents](tutorial:)
+`,
+
+	"address": `# The address bar
+
+The line at the top of the screen is the address bar. Press **f** to move
+the cursor into it (and **Esc** or **Tab** to move back to the page), type
+an address and press **Enter**:
+
+    mark://soul.demarkus.io/index.md
+
+Documents live at ` + "`mark://host/path`" + `; the port defaults to 6309. An
+address ending in ` + "`/`" + ` lists a directory. Add ` + "`/v2`" + ` to a document's
+path to read its second version: every change to a document on the Mark
+Protocol is kept.
+
+Type ` + "`?`" + ` followed by some words instead of an address to search the pages
+you have already visited, offline. **/** starts such a search too.
+
+Next: [History and saving pages](tutorial:history) · [Contents](tutorial:)
+`,
+
+	"history": `# History and saving pages
+
+- **[** and **]** go back and forward, like a web browser.
+- **Ctrl+O** and **Ctrl+N** walk through every page you have opened, in
+  order, even after going back and taking another path.
+- **r** fetches the page again. Pages are cached, so revisits are instant
+  and work offline; when a page has changed since, **c** shows what changed.
+
+To keep a page, press **b** to bookmark it (**B** lists bookmarks) or
+**R** to save it to your reading list (**L** opens it). Demarkus checks
+saved pages in the background and marks those that change with ✉ in the
+status bar; **N** lists the changes. **a** highlights a passage and
+attaches a note to it.
+
+Next: [Publishing with tokens](tutorial:tokens) · [Contents](tutorial:)
+`,
+
+	"tokens": `# Publishing with tokens
+
+Reading is open: no account, no login, no cookies. Writing needs a
+token, a secret the operator of a server hands out. Each token grants
+some operations (such as publish) on some paths of one server.
+
+This browser only reads. To publish, use the ` + "`demarkus`" + ` command with
+your token, or set it once in ` + "`DEMARKUS_AUTH`" + `:
+
+    demarkus -X PUBLISH -auth TOKEN -body "# Hello" mark://host/hello.md
+
+Your published page shows up here as soon as you fetch it. Keep tokens
+secret: anyone holding one can write what it allows.
+
+That is all. Press **Esc** to close the tutorial, or **f** to type your
+first address.
+
+[Contents](tutorial:)
+`,
+}
+
+// isTutorialLink reports whether target is a link between tutorial pages.
+func isTutorialLink(target string) bool {
+	return strings.HasPrefix(target, tutorialScheme)
+}
+
+// showTutorial displays the tutorial page a tutorial: link names, or the
+// first page for an unknown one.
+func (m model) showTutorial(target string) model {
+	page, ok := tutorialPages[strings.TrimPrefix(target, tutorialScheme)]
+	if !ok {
+		page = tutorialPages[""]
+	}
+	return m.showLocalPage(pageTutorial, page)
+}
+
+// isFirstRun reports whether the TUI has never been used on this machine:
+// nothing cached, bookmarked or saved for later.
+func isFirstRun(c *cache.Cache, bs *bookmarks.Store, rl *readinglist.Store) bool {
+	if c != nil && !c.Empty() {
+		return false
+	}
+	if bs != nil && len(bs.List()) > 0 {
+		return false
+	}
+	return rl == nil || len(rl.List()) == 0
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/latebit/demarkus/client/internal/bookmarks"
+	"github.com/latebit/demarkus/client/internal/cache"
+	"github.com/latebit/demarkus/client/internal/links"
+	"github.com/latebit/demarkus/client/internal/readinglist"
+	"github.com/latebit/demarkus/protocol"
+)
+
+func TestTutorialLinks(t *testing.T) {
+	for name, page := range tutorialPages {
+		dests := links.Extract(page)
+		if len(dests) == 0 {
+			t.Errorf("page %q links nowhere", name)
+		}
+		for _, dest := range dests {
+			if !isTutorialLink(dest) {
+				t.Errorf("page %q links outside the tutorial: %s", name, dest)
+				continue
+			}
+			if _, ok := tutorialPages[strings.TrimPrefix(dest, tutorialScheme)]; !ok {
+				t.Errorf("page %q links to missing page %s", name, dest)
+			}
+		}
+	}
+}
+
+func TestTutorialNavigation(t *testing.T) {
+	m := model{addressBar: textinput.New(), focus: focusViewport, histIdx: -1, linkIdx: -1}
+	m = m.showTutorial("")
+	if m.status != pageTutorial || !isLocalPage(m.status) || len(m.links) != 4 {
+		t.Fatalf("first page: status %q, links %v", m.status, m.links)
+	}
+
+	press := func(key tea.KeyMsg) {
+		t.Helper()
+		next, cmd := m.handleKey(key)
+		m = next.(model)
+		if cmd != nil {
+			t.Fatalf("%v started a command; tutorial pages are not fetched", key)
+		}
+	}
+	press(tea.KeyMsg{Type: tea.KeyTab})
+	press(tea.KeyMsg{Type: tea.KeyTab})
+	press(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.rawBody != tutorialPages["address"] || m.loading {
+		t.Fatalf("after following the second link: %q", links.ExtractTitle(m.rawBody))
+	}
+
+	press(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.status == pageTutorial {
+		t.Error("Esc did not close the tutorial")
+	}
+
+	m.focus = focusAddressBar
+	m.addressBar.SetValue("tutorial:tokens")
+	press(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.rawBody != tutorialPages["tokens"] || m.focus != focusViewport {
+		t.Errorf("typed tutorial: address: %q, focus %v", links.ExtractTitle(m.rawBody), m.focus)
+	}
+}
+
+func TestIsFirstRun(t *testing.T) {
+	dir := t.TempDir()
+	c := cache.New(filepath.Join(dir, "cache"))
+	bs, err := bookmarks.Load(filepath.Join(dir, "bookmarks.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rl, err := readinglist.Load(filepath.Join(dir, "readinglist.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isFirstRun(c, bs, rl) {
+		t.Fatal("fresh install: not a first run")
+	}
+	if err := rl.Add("mark://h/a.md", "A", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if isFirstRun(c, bs, rl) {
+		t.Error("reading list saved: still a first run")
+	}
+	if err := c.Put("h:6309", "/a.md", protocol.VerbFetch, protocol.Response{Status: protocol.StatusOK}); err != nil {
+		t.Fatal(err)
+	}
+	if isFirstRun(c, bs, nil) {
+		t.Error("page cached: still a first run")
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"os"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/latebit/demarkus/client/internal/cache"
+)
+
+// cacheMain handles the cache subcommands.
+func cacheMain(args []string) {
+	if len(args) < 1 || args[0] != "stats" {
+		fmt.Fprintf(os.Stderr, "usage: demarkus cache stats [-reset] [-cache-dir DIR]\n")
+		fmt.Fprintf(os.Stderr, "  stats  Show cache hits and misses per host, over past sessions\n")
+		os.Exit(1)
+	}
+
+	fs := flag.NewFlagSet("cache stats", flag.ExitOnError)
+	reset := fs.Bool("reset", false, "forget the recorded counts")
+	cacheDir := fs.String("cache-dir", cache.DefaultDir(), "cache directory (env: DEMARKUS_CACHE_DIR)")
+	_ = fs.Parse(args[1:])
+
+	c := cache.New(*cacheDir)
+	if *reset {
+		if err := c.ResetStats(); err != nil {
+			log.Fatalf("reset cache stats: %v", err)
+		}
+		fmt.Println("Cache statistics reset.")
+		return
+	}
+	stats, err := c.Stats()
+	if err != nil {
+		log.Fatalf("read cache stats: %v", err)
+	}
+	if len(stats) == 0 {
+		fmt.Println("No cache statistics recorded yet.")
+		return
+	}
+	writeCacheStats(os.Stdout, stats)
+}
+
+// writeCacheStats prints a row per host, sorted by host, and the totals.
+// A low hit ratio means the host's documents change between visits, or
+// that they are rarely visited twice.
+func writeCacheStats(w io.Writer, stats map[string]cache.HostStats) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "HOST\tHITS\tMISSES\tHIT RATIO")
+	var total cache.HostStats
+	for _, host := range slices.Sorted(maps.Keys(stats)) {
+		s := stats[host]
+		total.Hits += s.Hits
+		total.Misses += s.Misses
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.0f%%\n", host, s.Hits, s.Misses, 100*s.HitRatio())
+	}
+	if len(stats) > 1 {
+		fmt.Fprintf(tw, "total\t%d\t%d\t%.0f%%\n", total.Hits, total.Misses, 100*total.HitRatio())
+	}
+	_ = tw.Flush()
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/latebit/demarkus/client/internal/cache"
+)
+
+func TestWriteCacheStats(t *testing.T) {
+	var b strings.Builder
+	writeCacheStats(&b, map[string]cache.HostStats{
+		"slow.example:6309": {Hits: 1, Misses: 3},
+		"docs.example:6309": {Hits: 9, Misses: 1},
+	})
+	want := "HOST               HITS  MISSES  HIT RATIO\n" +
+		"docs.example:6309  9     1       90%\n" +
+		"slow.example:6309  1     3       25%\n" +
+		"total              10    4       71%\n"
+	if b.String() != want {
+		t.Errorf("writeCacheStats:\n got %q\nwant %q", b.String(), want)
+	}
+
+	b.Reset()
+	writeCacheStats(&b, map[string]cache.HostStats{"h:6309": {Hits: 1}})
+	if strings.Contains(b.String(), "total") {
+		t.Errorf("total row for a single host:\n%s", b.String())
+	}
+}
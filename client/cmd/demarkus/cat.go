@@ -0,0 +1,194 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/latebit/demarkus/client/internal/cache"
+	"github.com/latebit/demarkus/client/internal/fetch"
+)
+
+// catDoc is one document of a cat: its URL, and its body or why it could
+// not be fetched.
+type catDoc struct {
+	url  string
+	body string
+	err  error
+}
+
+// catMain fetches several documents and prints them in the order given,
+// each under a header, or with -merge as one markdown document. Exits
+// non-zero if any could not be fetched.
+func catMain(args []string) {
+	fs := flag.NewFlagSet("cat", flag.ExitOnError)
+	merge := fs.Bool("merge", false, "print one markdown document, with a section per document")
+	noCache := fs.Bool("no-cache", false, "disable caching")
+	insecure := fs.Bool("insecure", false, "skip TLS certificate verification")
+	cacheDir := fs.String("cache-dir", cache.DefaultDir(), "cache directory (env: DEMARKUS_CACHE_DIR)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: demarkus cat [-merge] [-insecure] mark://host:port/a.md mark://host:port/b.md ...\n\n")
+		fmt.Fprintf(os.Stderr, "Fetch documents concurrently and print them in order, each under a\n")
+		fmt.Fprintf(os.Stderr, "==> URL <== header, or with -merge as a single markdown document, such as\n")
+		fmt.Fprintf(os.Stderr, "to pipe a set of documents into a pager or a language model.\n\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	opts := fetch.Options{Insecure: *insecure, OnRateLimited: reportBusy, MaxRedirects: fetch.DefaultMaxRedirects, CrossHostRedirects: true}
+	if !*noCache {
+		opts.Cache = cache.New(*cacheDir)
+	}
+	applyHostPrefs(&opts)
+	client := fetch.NewClient(opts)
+	defer client.Close()
+
+	docs := fetchAll(client, fs.Args())
+	failed := 0
+	for _, d := range docs {
+		if d.err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", d.url, d.err)
+			failed++
+		}
+	}
+	if *merge {
+		writeMerged(os.Stdout, docs)
+	} else {
+		writeSections(os.Stdout, docs)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// fetchAll fetches the documents at urls, those on each host together and
+// the hosts concurrently, and returns them in the order of urls.
+func fetchAll(client *fetch.Client, urls []string) []catDoc {
+	docs := make([]catDoc, len(urls))
+	byHost := make(map[string][]int)
+	var hosts []string
+	paths := make([]string, len(urls))
+	for i, raw := range urls {
+		docs[i].url = raw
+		host, path, err := fetch.ParseMarkURL(raw)
+		if err != nil {
+			docs[i].err = fmt.Errorf("invalid URL: %w", err)
+			continue
+		}
+		if _, ok := byHost[host]; !ok {
+			hosts = append(hosts, host)
+		}
+		byHost[host] = append(byHost[host], i)
+		paths[i] = path
+	}
+
+	var wg sync.WaitGroup
+	for _, host := range hosts {
+		wg.Go(func() {
+			indexes := byHost[host]
+			hostPaths := make([]string, len(indexes))
+			for k, i := range indexes {
+				hostPaths[k] = paths[i]
+			}
+			results, err := client.FetchMany(host, hostPaths)
+			for k, i := range indexes {
+				switch {
+				case err != nil:
+					docs[i].err = err
+				case results[k].Err() != nil:
+					docs[i].err = results[k].Err()
+				default:
+					docs[i].body = results[k].Response.Body
+				}
+			}
+		})
+	}
+	wg.Wait()
+	return docs
+}
+
+// writeSections prints each fetched document under a "==> URL <==" header,
+// separated by blank lines.
+func writeSections(w io.Writer, docs []catDoc) {
+	first := true
+	for _, d := range docs {
+		if d.err != nil {
+			continue
+		}
+		if !first {
+			fmt.Fprintln(w)
+		}
+		first = false
+		fmt.Fprintf(w, "==> %s <==\n%s", d.url, d.body)
+		if !strings.HasSuffix(d.body, "\n") {
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+// writeMerged prints the fetched documents as one markdown document: each
+// becomes a level 1 section titled by its own level 1 heading, or by its
+// URL if it has none, with the URL under it and its other headings a level
+// down.
+func writeMerged(w io.Writer, docs []catDoc) {
+	first := true
+	for _, d := range docs {
+		if d.err != nil {
+			continue
+		}
+		if !first {
+			fmt.Fprintln(w)
+		}
+		first = false
+		title, body := demoteHeadings(d.body)
+		if title == "" {
+			title = d.url
+		}
+		fmt.Fprintf(w, "# %s\n\nSource: <%s>\n\n%s", title, d.url, strings.TrimLeft(body, "\n"))
+		if !strings.HasSuffix(body, "\n") {
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+// demoteHeadings removes the first level 1 ATX heading of body, returning
+// its text as title, and moves every other heading one level down, to at
+// most level 6. Headings in fenced code blocks are left alone.
+func demoteHeadings(body string) (title, demoted string) {
+	var b strings.Builder
+	var fence string
+	titled := false
+	for line := range strings.SplitAfterSeq(body, "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		switch {
+		case fence != "":
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+		case strings.HasPrefix(trimmed, "```"), strings.HasPrefix(trimmed, "~~~"):
+			fence = trimmed[:3]
+		case strings.HasPrefix(line, "#"):
+			level := len(line) - len(strings.TrimLeft(line, "#"))
+			rest := line[level:]
+			if level > 6 || (rest != "" && rest[0] != ' ' && rest[0] != '\t' && rest[0] != '\n' && rest[0] != '\r') {
+				break
+			}
+			if level == 1 && !titled {
+				title, titled = strings.TrimSpace(rest), true
+				continue
+			}
+			if level < 6 {
+				line = "#" + line
+			}
+		}
+		b.WriteString(line)
+	}
+	return title, b.String()
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDemoteHeadings(t *testing.T) {
+	body := "# Guide\n\nIntro.\n\n## Install\n\n```sh\n# not a heading\n```\n\n###### Deep\n#hashtag\n# Second\n"
+	title, got := demoteHeadings(body)
+	want := "\nIntro.\n\n### Install\n\n```sh\n# not a heading\n```\n\n###### Deep\n#hashtag\n## Second\n"
+	if title != "Guide" || got != want {
+		t.Errorf("demoteHeadings = %q, %q\nwant %q, %q", title, got, "Guide", want)
+	}
+	if title, got := demoteHeadings("No heading.\n## Sub\n"); title != "" || got != "No heading.\n### Sub\n" {
+		t.Errorf("untitled: %q, %q", title, got)
+	}
+}
+
+func TestWriteSections(t *testing.T) {
+	docs := []catDoc{
+		{url: "mark://h/a.md", body: "# A\n"},
+		{url: "mark://h/missing.md", err: errors.New("not found")},
+		{url: "mark://h/b.md", body: "no newline"},
+	}
+	var b strings.Builder
+	writeSections(&b, docs)
+	want := "==> mark://h/a.md <==\n# A\n\n==> mark://h/b.md <==\nno newline\n"
+	if b.String() != want {
+		t.Errorf("writeSections:\n got %q\nwant %q", b.String(), want)
+	}
+}
+
+func TestWriteMerged(t *testing.T) {
+	docs := []catDoc{
+		{url: "mark://h/a.md", body: "# Alpha\n\nText.\n\n## Part\n"},
+		{url: "mark://h/missing.md", err: errors.New("not found")},
+		{url: "mark://h/b.md", body: "Untitled."},
+	}
+	var b strings.Builder
+	writeMerged(&b, docs)
+	want := "# Alpha\n\nSource: <mark://h/a.md>\n\nText.\n\n### Part\n\n" +
+		"# mark://h/b.md\n\nSource: <mark://h/b.md>\n\nUntitled.\n"
+	if b.String() != want {
+		t.Errorf("writeMerged:\n got %q\nwant %q", b.String(), want)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/latebit/demarkus/protocol"
+	"gopkg.in/yaml.v3"
+)
+
+// errorBannerPrefix marks the comment line describing why the last save was
+// rejected. Old banners are replaced rather than accumulated.
+const errorBannerPrefix = "# ERROR: "
+
+// splitMeta separates publisher metadata from server-managed fields. The
+// editor shows the latter as read-only comments and never sends them back.
+// Echoed keys, such as request-id, describe the fetch and are dropped.
+func splitMeta(meta map[string]string) (author, managed map[string]string) {
+	for k, v := range meta {
+		target := &author
+		switch protocol.MetaKeyKind(k) {
+		case protocol.KeyEcho:
+			continue
+		case protocol.KeyServer:
+			target = &managed
+		}
+		if *target == nil {
+			*target = make(map[string]string)
+		}
+		(*target)[k] = v
+	}
+	return author, managed
+}
+
+// composeEditBuffer renders the text handed to the editor: a frontmatter
+// block with the author's metadata (server-managed fields listed as
+// comments) followed by the body. The block is omitted when there is
+// nothing to show, unless the body itself starts with "---" and would be
+// mistaken for frontmatter.
+func composeEditBuffer(body string, author, managed map[string]string) (string, error) {
+	if len(author) == 0 && len(managed) == 0 && !strings.HasPrefix(body, "---") {
+		return body, nil
+	}
+	var b strings.Builder
+	b.WriteString("---\n")
+	if len(managed) > 0 {
+		b.WriteString("# Server-managed (read-only):\n")
+		for _, k := range slices.Sorted(maps.Keys(managed)) {
+			fmt.Fprintf(&b, "#   %s: %s\n", k, managed[k])
+		}
+	}
+	if len(author) > 0 {
+		out, err := yaml.Marshal(author)
+		if err != nil {
+			return "", fmt.Errorf("encode metadata: %w", err)
+		}
+		b.Write(out)
+	}
+	b.WriteString("---\n")
+	b.WriteString(body)
+	return b.String(), nil
+}
+
+// parseEditBuffer splits an edited buffer into body and publisher metadata,
+// validating the frontmatter the way the server will.
+func parseEditBuffer(buf string) (body string, meta map[string]string, err error) {
+	rest, ok := strings.CutPrefix(buf, "---\n")
+	if !ok {
+		return buf, nil, nil
+	}
+	var fm string
+	if after, empty := strings.CutPrefix(rest, "---\n"); empty {
+		body, ok = after, true
+	} else {
+		fm, body, ok = strings.Cut(rest, "\n---\n")
+	}
+	if !ok {
+		fm, ok = strings.CutSuffix(rest, "\n---")
+		if !ok {
+			return "", nil, fmt.Errorf("frontmatter is not closed: add a line containing only ---")
+		}
+	}
+	if err := yaml.Unmarshal([]byte(fm), &meta); err != nil {
+		return "", nil, fmt.Errorf("invalid frontmatter: %w", err)
+	}
+	size := 0
+	for k, v := range meta {
+		if protocol.IsServerKey(k) {
+			return "", nil, fmt.Errorf("%q is managed by the server and cannot be set; remove it", k)
+		}
+		if !protocol.IsValidMetaKey(k) {
+			return "", nil, fmt.Errorf("metadata key %q may only contain lowercase letters, digits and hyphens", k)
+		}
+		if !protocol.IsValidMetaValue(v) {
+			return "", nil, fmt.Errorf("metadata value for %q must be a single line", k)
+		}
+		size += len(k) + len(v)
+	}
+	if len(meta) > protocol.MaxMetaKeys {
+		return "", nil, fmt.Errorf("too many metadata keys: %d (max %d)", len(meta), protocol.MaxMetaKeys)
+	}
+	if size > protocol.MaxMetaBytes {
+		return "", nil, fmt.Errorf("metadata too large: %d bytes (max %d)", size, protocol.MaxMetaBytes)
+	}
+	return body, meta, nil
+}
+
+// withErrorBanner returns buf with a comment explaining err placed at the
+// top of its frontmatter, replacing any previous banner.
+func withErrorBanner(buf string, err error) string {
+	banner := errorBannerPrefix + strings.ReplaceAll(err.Error(), "\n", " ") + "\n" +
+		errorBannerPrefix + "fix the frontmatter and save again, or save an empty file to abort\n"
+	rest, ok := strings.CutPrefix(buf, "---\n")
+	if !ok {
+		return "---\n" + banner + "---\n" + buf
+	}
+	for strings.HasPrefix(rest, errorBannerPrefix) {
+		_, rest, _ = strings.Cut(rest, "\n")
+	}
+	return "---\n" + banner + rest
+}
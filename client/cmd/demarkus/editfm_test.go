@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSplitMeta(t *testing.T) {
+	author, managed := splitMeta(map[string]string{
+		"version":    "3",
+		"etag":       "abc",
+		"title":      "Hello",
+		"request-id": "abc123",
+	})
+	if len(author) != 1 || author["title"] != "Hello" {
+		t.Errorf("author = %v, want title only", author)
+	}
+	if len(managed) != 2 || managed["version"] != "3" {
+		t.Errorf("managed = %v, want version and etag", managed)
+	}
+}
+
+func TestComposeParseRoundTrip(t *testing.T) {
+	author := map[string]string{"title": "Hello: world", "tags": "a,b"}
+	managed := map[string]string{"version": "3"}
+	buf, err := composeEditBuffer("# Body\n", author, managed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf, "#   version: 3") {
+		t.Errorf("managed fields not shown as comments:\n%s", buf)
+	}
+
+	body, meta, err := parseEditBuffer(buf)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if body != "# Body\n" {
+		t.Errorf("body = %q, want %q", body, "# Body\n")
+	}
+	if len(meta) != 2 || meta["title"] != "Hello: world" || meta["tags"] != "a,b" {
+		t.Errorf("meta = %v, want %v", meta, author)
+	}
+}
+
+func TestComposePlainBody(t *testing.T) {
+	buf, _ := composeEditBuffer("# Body\n", nil, nil)
+	if buf != "# Body\n" {
+		t.Errorf("got %q, want body unchanged", buf)
+	}
+
+	// A body starting with a thematic break gets an empty block so it is
+	// not read back as frontmatter.
+	buf, _ = composeEditBuffer("---\nnot: meta\n---\ntext\n", nil, nil)
+	body, meta, err := parseEditBuffer(buf)
+	if err != nil || len(meta) != 0 || body != "---\nnot: meta\n---\ntext\n" {
+		t.Errorf("round trip = %q, %v, %v", body, meta, err)
+	}
+}
+
+func TestParseEditBufferErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		buf  string
+		want string
+	}{
+		{"unclosed", "---\ntitle: x\n# Body\n", "not closed"},
+		{"bad yaml", "---\ntitle: [x\n---\nbody", "invalid frontmatter"},
+		{"list value", "---\ntags: [a, b]\n---\nbody", "invalid frontmatter"},
+		{"managed", "---\nversion: 9\n---\nbody", "managed by the server"},
+		{"bad key", "---\nTitle: x\n---\nbody", "lowercase"},
+		{"too many", "---\n" + manyKeys(11) + "---\nbody", "too many"},
+		{"too large", "---\nbig: " + strings.Repeat("x", 600) + "\n---\nbody", "too large"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := parseEditBuffer(tt.buf)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func manyKeys(n int) string {
+	var b strings.Builder
+	for i := range n {
+		b.WriteString("key-" + string(rune('a'+i)) + ": v\n")
+	}
+	return b.String()
+}
+
+func TestWithErrorBanner(t *testing.T) {
+	buf := "---\nversion: 9\n---\nbody"
+	once := withErrorBanner(buf, errors.New("first"))
+	twice := withErrorBanner(once, errors.New("second"))
+	if strings.Contains(twice, "first") {
+		t.Errorf("old banner not replaced:\n%s", twice)
+	}
+	if !strings.HasPrefix(twice, "---\n# ERROR: second\n") {
+		t.Errorf("banner not at top of frontmatter:\n%s", twice)
+	}
+	// The banner is a YAML comment, so fixing the error is enough to parse.
+	fixed := strings.Replace(twice, "version: 9\n", "", 1)
+	if _, _, err := parseEditBuffer(fixed); err != nil {
+		t.Errorf("banner broke parsing: %v", err)
+	}
+}
+
+func TestWithErrorBannerKeepsBodyHeadings(t *testing.T) {
+	buf := "---\ntitle: [x\n---\n# ERROR: codes\n"
+	got := withErrorBanner(withErrorBanner(buf, errors.New("a")), errors.New("b"))
+	if !strings.HasSuffix(got, "---\n# ERROR: codes\n") {
+		t.Errorf("body heading was stripped:\n%s", got)
+	}
+}
@@ -1,23 +1,36 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"crypto/ed25519"
 	"flag"
 	"fmt"
 	"io"
 	"log"
+	"maps"
 	"os"
 	"os/exec"
+	"slices"
 	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
 
+	"github.com/latebit/demarkus/client/internal/annotations"
 	"github.com/latebit/demarkus/client/internal/bookmarks"
 	"github.com/latebit/demarkus/client/internal/cache"
+	"github.com/latebit/demarkus/client/internal/drafts"
 	"github.com/latebit/demarkus/client/internal/fetch"
 	"github.com/latebit/demarkus/client/internal/graph"
 	"github.com/latebit/demarkus/client/internal/graphstore"
+	"github.com/latebit/demarkus/client/internal/hostprefs"
 	"github.com/latebit/demarkus/client/internal/links"
+	"github.com/latebit/demarkus/client/internal/readinglist"
+	"github.com/latebit/demarkus/client/internal/search"
 	"github.com/latebit/demarkus/client/internal/tokens"
+	"github.com/latebit/demarkus/client/internal/urlnorm"
+	"github.com/latebit/demarkus/client/internal/verify"
 	"github.com/latebit/demarkus/protocol"
 )
 
@@ -33,33 +46,87 @@ func main() {
 		case "info":
 			infoMain(os.Args[2:])
 			return
+		case "cat":
+			catMain(os.Args[2:])
+			return
+		case "publish":
+			publishMain(os.Args[2:])
+			return
+		case "relink":
+			relinkMain(os.Args[2:])
+			return
+		case "verify":
+			verifyMain(os.Args[2:])
+			return
 		case "token":
 			tokenMain(os.Args[2:])
 			return
 		case "bookmark":
 			bookmarkMain(os.Args[2:])
 			return
+		case "search":
+			searchMain(os.Args[2:])
+			return
+		case "annotations":
+			annotationsMain(os.Args[2:])
+			return
+		case "reading-list":
+			readingListMain(os.Args[2:])
+			return
+		case "cache":
+			cacheMain(os.Args[2:])
+			return
 		}
 	}
 	requestMain()
 }
 
+// exitConflict is the exit status for a write rejected because the document
+// moved past -expected-version, so scripts can refetch and retry.
+const exitConflict = 3
+
 func requestMain() {
-	verb := flag.String("X", protocol.VerbFetch, "request verb (FETCH, LIST, VERSIONS, PUBLISH, ARCHIVE, APPEND)")
+	verb := flag.String("X", protocol.VerbFetch, "request verb (FETCH, LIST, VERSIONS, PUBLISH, ARCHIVE, APPEND, SEARCH, DIFF, PURGE, INFO, MOVE, BACKLINKS, JOB, or an experimental X- verb)")
 	body := flag.String("body", "", "request body (for PUBLISH/APPEND); reads stdin if omitted")
-	authToken := flag.String("auth", "", "auth token for PUBLISH/ARCHIVE/APPEND requests (env: DEMARKUS_AUTH)")
+	authToken := flag.String("auth", "", "auth token for PUBLISH/ARCHIVE/APPEND/SEARCH/PURGE/MOVE/BACKLINKS/JOB requests (env: DEMARKUS_AUTH)")
+	query := flag.String("q", "", "search query (for SEARCH)")
+	moveTo := flag.String("to", "", "destination path (for MOVE)")
+	redirect := flag.Bool("redirect", false, "keep the old path as an alias of the new one (for MOVE)")
+	async := flag.Bool("async", false, "let the server answer accepted and write the document later (for PUBLISH)")
+	jobID := flag.String("job", "", "job ID of an accepted PUBLISH (for JOB)")
+	offset := flag.Int("offset", 0, "entries to skip (for LIST)")
+	limit := flag.Int("limit", 0, "most entries to return (for LIST; 0 for the server's maximum)")
+	sortBy := flag.String("sort", "", "entry order for LIST: name (default) or modified (newest first)")
 	expectedVersion := flag.Int("expected-version", -1, "version check: -1 skip (default), 0 create-only, >0 require match; required (>0) for APPEND")
 	verbose := flag.Bool("v", false, "show status and metadata header before body")
+	maxRedirects := flag.Int("max-redirects", fetch.DefaultMaxRedirects, "most moved responses to follow for a FETCH (0 shows the moved response)")
+	lang := flag.String("lang", "", "preferred languages, as an accept-language value such as \"de, en;q=0.5\": servers answer with a translation when they have one")
+	trailers := flag.Bool("trailers", false, "ask for a response trailer and verify the body against its hash; -v shows it")
 	noCache := flag.Bool("no-cache", false, "disable caching")
 	insecure := flag.Bool("insecure", false, "skip TLS certificate verification")
 	cacheDir := flag.String("cache-dir", cache.DefaultDir(), "cache directory (env: DEMARKUS_CACHE_DIR)")
+	meta := metaFlags{}
+	flag.Var(meta, "meta", "publisher metadata key=value for PUBLISH/APPEND (repeatable, e.g. -meta tags=status,ops)")
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "usage: demarkus [-v] [-X VERB] [-body TEXT] [-auth TOKEN] mark://host:port/path\n")
+		fmt.Fprintf(os.Stderr, "usage: demarkus [-v] [-X VERB] [-body TEXT] [-auth TOKEN] [-expected-version N] [-meta key=value ...] mark://host:port/path\n")
+		fmt.Fprintf(os.Stderr, "       demarkus -X PUBLISH -async [-auth TOKEN] mark://host:port/path.md\n")
+		fmt.Fprintf(os.Stderr, "       demarkus -X JOB -job ID [-auth TOKEN] mark://host:port/path.md\n")
+		fmt.Fprintf(os.Stderr, "       demarkus -X LIST [-offset N] [-limit N] [-sort name|modified] mark://host:port/dir/\n")
+		fmt.Fprintf(os.Stderr, "       demarkus -X SEARCH -q QUERY [-auth TOKEN] mark://host:port/dir/\n")
+		fmt.Fprintf(os.Stderr, "       demarkus -X DIFF mark://host:port/path.md/vA..vB\n")
+		fmt.Fprintf(os.Stderr, "       demarkus -X BACKLINKS [-auth TOKEN] mark://host:port/path.md\n")
+		fmt.Fprintf(os.Stderr, "       demarkus -X MOVE -to /new/path.md [-redirect] [-auth TOKEN] mark://host:port/old/path.md\n")
+		fmt.Fprintf(os.Stderr, "       demarkus search [-n N] [-auth TOKEN] [-insecure] mark://host:port/dir/ QUERY\n")
 		fmt.Fprintf(os.Stderr, "       demarkus edit [-auth TOKEN] [-insecure] mark://host:port/path.md\n")
+		fmt.Fprintf(os.Stderr, "       demarkus publish [-auth TOKEN] [-insecure] mark://host:port/dir/ FILE...\n")
+		fmt.Fprintf(os.Stderr, "       demarkus relink [-n] [-publish mark://host:port/dir/] DIR OLD-HOST NEW-HOST\n")
 		fmt.Fprintf(os.Stderr, "       demarkus graph [-depth N] [-insecure] mark://host:port/path\n")
+		fmt.Fprintf(os.Stderr, "       demarkus cat [-merge] [-insecure] mark://host:port/a.md mark://host:port/b.md ...\n")
 		fmt.Fprintf(os.Stderr, "       demarkus info [-insecure] mark://host:port\n")
+		fmt.Fprintf(os.Stderr, "       demarkus verify [-insecure] mark://host:port/path.md\n")
 		fmt.Fprintf(os.Stderr, "       demarkus bookmark <add|list|remove>\n")
-		fmt.Fprintf(os.Stderr, "       demarkus token <add|remove|list>\n\n")
+		fmt.Fprintf(os.Stderr, "       demarkus cache stats [-reset]\n")
+		fmt.Fprintf(os.Stderr, "       demarkus token <add|remove|list|test>\n\n")
 		flag.PrintDefaults()
 	}
 	flag.Parse()
@@ -79,10 +146,44 @@ func requestMain() {
 		log.Fatal(err)
 	}
 
-	opts := fetch.Options{Insecure: *insecure}
+	if *lang != "" && len(protocol.AcceptedLanguages(*lang)) == 0 {
+		log.Fatalf("-lang %q names no language", *lang)
+	}
+	opts := fetch.Options{Insecure: *insecure, OnRateLimited: reportBusy, Trailers: *trailers, MaxRedirects: *maxRedirects, CrossHostRedirects: true, AcceptLanguage: *lang}
 	if !*noCache {
 		opts.Cache = cache.New(*cacheDir)
 	}
+	applyHostPrefs(&opts)
+
+	experimental := protocol.IsExperimentalVerb(*verb)
+	if len(meta) > 0 && *verb != protocol.VerbPublish && *verb != protocol.VerbAppend && !experimental {
+		log.Fatalf("-meta is only valid with PUBLISH, APPEND or an experimental verb, not %s", *verb)
+	}
+	if *expectedVersion != -1 && *verb != protocol.VerbPublish && *verb != protocol.VerbAppend {
+		log.Fatalf("-expected-version is only valid with PUBLISH or APPEND, not %s", *verb)
+	}
+	if (*query != "") != (*verb == protocol.VerbSearch) {
+		log.Fatal("-q is required with SEARCH and only valid with it")
+	}
+	if (*moveTo != "") != (*verb == protocol.VerbMove) {
+		log.Fatal("-to is required with MOVE and only valid with it")
+	}
+	if *redirect && *verb != protocol.VerbMove {
+		log.Fatalf("-redirect is only valid with MOVE, not %s", *verb)
+	}
+	if *async && *verb != protocol.VerbPublish {
+		log.Fatalf("-async is only valid with PUBLISH, not %s", *verb)
+	}
+	if (*jobID != "") != (*verb == protocol.VerbJob) {
+		log.Fatal("-job is required with JOB and only valid with it")
+	}
+	paged := *offset != 0 || *limit != 0 || *sortBy != ""
+	if paged && *verb != protocol.VerbList {
+		log.Fatalf("-offset, -limit and -sort are only valid with LIST, not %s", *verb)
+	}
+	if *offset < 0 || *limit < 0 {
+		log.Fatal("-offset and -limit must not be negative")
+	}
 
 	token := resolveAuthToken(*authToken, host)
 	reqBody := resolveBody(*verb, *body)
@@ -95,6 +196,10 @@ func requestMain() {
 		}
 	}
 
+	if *async {
+		meta[protocol.MetaAsync] = "true"
+	}
+
 	client := fetch.NewClient(opts)
 	defer client.Close()
 
@@ -103,21 +208,48 @@ func requestMain() {
 	case protocol.VerbFetch:
 		result, err = client.Fetch(host, path)
 	case protocol.VerbList:
-		result, err = client.List(host, path)
+		if paged {
+			result, err = client.ListPage(host, path, fetch.ListOptions{Offset: *offset, Limit: *limit, Sort: *sortBy})
+		} else {
+			result, err = client.List(host, path)
+		}
 	case protocol.VerbVersions:
 		result, err = client.Versions(host, path)
 	case protocol.VerbPublish:
-		result, err = client.Publish(host, path, reqBody, token, *expectedVersion, nil)
+		result, err = client.Publish(host, path, reqBody, token, *expectedVersion, meta)
 	case protocol.VerbArchive:
 		result, err = client.Archive(host, path, token)
 	case protocol.VerbAppend:
-		result, err = client.Append(host, path, reqBody, token, *expectedVersion, nil)
+		result, err = client.Append(host, path, reqBody, token, *expectedVersion, meta)
+	case protocol.VerbSearch:
+		result, err = client.Search(host, path, *query, token, 0)
+	case protocol.VerbDiff:
+		result, err = client.Diff(host, path)
+	case protocol.VerbPurge:
+		result, err = client.Purge(host, path, token)
+	case protocol.VerbInfo:
+		result, err = client.Info(host, path)
+	case protocol.VerbMove:
+		result, err = client.Move(host, path, *moveTo, token, *redirect)
+	case protocol.VerbBacklinks:
+		result, err = client.Backlinks(host, path, token)
+	case protocol.VerbJob:
+		result, err = client.Job(host, path, *jobID, token)
+	default:
+		result, err = client.Experimental(host, *verb, path, reqBody, token, meta)
 	}
 	if err != nil {
 		log.Fatal(err)
 	}
+	if fetch.IsConflict(result.Err()) {
+		fmt.Fprintln(os.Stderr, conflictMessage(result.Response.Metadata, *expectedVersion))
+		os.Exit(exitConflict)
+	}
 
 	if *verbose {
+		if len(result.Redirects) > 0 {
+			fmt.Fprintf(os.Stderr, "[redirected] %s -> %s\n", strings.Join(result.Redirects, " -> "), result.URL)
+		}
 		fmt.Fprintf(os.Stderr, "[%s]", result.Response.Status)
 		for k, v := range result.Response.Metadata {
 			fmt.Fprintf(os.Stderr, " %s=%s", k, v)
@@ -126,8 +258,59 @@ func requestMain() {
 			fmt.Fprint(os.Stderr, " (cached)")
 		}
 		fmt.Fprintln(os.Stderr)
+		if len(result.Response.Trailer) > 0 {
+			fmt.Fprint(os.Stderr, "[trailer]")
+			for k, v := range result.Response.Trailer {
+				fmt.Fprintf(os.Stderr, " %s=%s", k, v)
+			}
+			fmt.Fprintln(os.Stderr)
+		}
+	}
+	// INFO has no body; its answer is the metadata.
+	if *verb == protocol.VerbInfo && result.Response.Status == protocol.StatusOK {
+		meta := result.Response.Metadata
+		for _, k := range slices.Sorted(maps.Keys(meta)) {
+			fmt.Printf("%s: %s\n", k, meta[k])
+		}
+		return
 	}
 	fmt.Print(result.Response.Body)
+	if next := result.Response.Metadata["next-offset"]; *verb == protocol.VerbList && next != "" {
+		fmt.Fprintf(os.Stderr, "more entries follow: rerun with -offset %s for the next page\n", next)
+	}
+	if job := result.Response.Metadata[protocol.MetaJob]; result.Response.Status == protocol.StatusAccepted && job != "" {
+		fmt.Fprintf(os.Stderr, "not written yet: rerun with -X JOB -job %s for the outcome\n", job)
+	}
+}
+
+// reportBusy tells the user why a rate-limited request is taking longer.
+func reportBusy(host string, wait time.Duration) {
+	fmt.Fprintf(os.Stderr, "%s is busy, retrying in %s\n", host, wait)
+}
+
+// applyHostPrefs sets the fetch options that follow the user's host
+// preferences: the hosts configured with no_cache skip the cache, and
+// those with a signing_key must sign their documents with it.
+func applyHostPrefs(opts *fetch.Options) {
+	prefs, err := hostprefs.Load(hostprefs.DefaultPath())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: host preferences ignored: %v\n", err)
+	}
+	opts.SkipCache = func(host string) bool { return prefs.For(host).NoCache }
+	opts.PinnedKey = func(host string) ed25519.PublicKey { return prefs.For(host).PinnedKey() }
+}
+
+// conflictMessage explains a conflict response to a PUBLISH or APPEND sent
+// with expected version expected.
+func conflictMessage(meta map[string]string, expected int) string {
+	serverVersion := meta["server-version"]
+	if serverVersion == "" {
+		serverVersion = "unknown"
+	}
+	if expected == 0 {
+		return fmt.Sprintf("conflict: document already exists at version %s (-expected-version 0 only creates new documents)", serverVersion)
+	}
+	return fmt.Sprintf("conflict: document is at version %s, not %d; fetch the latest version and retry with -expected-version %s", serverVersion, expected, serverVersion)
 }
 
 func editMain(args []string) {
@@ -136,10 +319,14 @@ func editMain(args []string) {
 	insecure := fs.Bool("insecure", false, "skip TLS certificate verification")
 	useCache := fs.Bool("cache", false, "enable caching (disabled by default for edit)")
 	cacheDir := fs.String("cache-dir", cache.DefaultDir(), "cache directory (env: DEMARKUS_CACHE_DIR)")
+	draftDir := fs.String("draft-dir", drafts.DefaultDir(), "directory for unpublished drafts")
 	fs.Usage = func() {
 		fmt.Fprintf(os.Stderr, "usage: demarkus edit [-auth TOKEN] [-insecure] mark://host:port/path.md\n\n")
 		fmt.Fprintf(os.Stderr, "Fetch a document, open it in $EDITOR, and publish changes.\n")
-		fmt.Fprintf(os.Stderr, "Creates a new document if it doesn't exist.\n\n")
+		fmt.Fprintf(os.Stderr, "Creates a new document if it doesn't exist. Edits are kept as a draft\n")
+		fmt.Fprintf(os.Stderr, "until published, and offered for resume on the next edit.\n")
+		fmt.Fprintf(os.Stderr, "Publisher metadata is editable as frontmatter; server-managed fields\n")
+		fmt.Fprintf(os.Stderr, "are shown as read-only comments.\n\n")
 		fs.PrintDefaults()
 	}
 	_ = fs.Parse(args)
@@ -171,7 +358,7 @@ func editMain(args []string) {
 		}
 	}
 
-	opts := fetch.Options{Insecure: *insecure}
+	opts := fetch.Options{Insecure: *insecure, OnRateLimited: reportBusy}
 	if *useCache {
 		opts.Cache = cache.New(*cacheDir)
 	}
@@ -182,94 +369,78 @@ func editMain(args []string) {
 	// Default to -1 (no check) so a missing/malformed version doesn't cause
 	// a false create-only conflict on an existing document.
 	var original string
+	var author, managed map[string]string
 	fetchedVersion := -1
 	result, err := client.Fetch(host, path)
 	if err != nil {
 		log.Fatal(err)
 	}
-	switch result.Response.Status {
-	case protocol.StatusOK:
+	switch err := result.Err(); {
+	case err == nil:
 		original = result.Response.Body
+		author, managed = splitMeta(result.Response.Metadata)
 		if v, err := strconv.Atoi(result.Response.Metadata["version"]); err == nil {
 			fetchedVersion = v
 		}
-	case protocol.StatusNotFound:
+	case fetch.IsNotFound(err):
 		// New document — start with empty content; 0 means create-only.
 		fetchedVersion = 0
 		fmt.Fprintf(os.Stderr, "Document not found, creating new document.\n")
 	default:
-		log.Fatalf("fetch failed: %s", result.Response.Status)
+		log.Fatalf("fetch failed: %v", err)
 	}
 
-	// Write content to a temp file for editing.
-	tmpFile, err := os.CreateTemp("", "demarkus-edit-*.md")
+	// Edit in a draft file that outlives this process: if the editor
+	// crashes or the publish fails, the next edit offers to resume it.
+	ds, err := drafts.New(*draftDir)
 	if err != nil {
-		log.Fatalf("create temp file: %v", err)
-	}
-	defer func() { _ = os.Remove(tmpFile.Name()) }()
-
-	if _, err := tmpFile.WriteString(original); err != nil {
-		log.Fatalf("write temp file: %v", err)
+		log.Fatal(err)
 	}
-	if err := tmpFile.Close(); err != nil {
-		log.Fatalf("close temp file: %v", err)
+	buffer, err := composeEditBuffer(original, author, managed)
+	if err != nil {
+		log.Fatal(err)
 	}
-
-	// Open the editor.
-	name, args := editorCommand(editorFields, tmpFile.Name())
-	cmd := exec.Command(name, args...)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		log.Fatalf("editor exited with error: %v", err)
+	draftFile, err := startDraft(ds, host, path, buffer, fetchedVersion, os.Stdin, os.Stderr)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	// Read back the edited content.
-	edited, err := os.ReadFile(tmpFile.Name())
+	newBody, newMeta, err := editUntilValid(editorFields, draftFile)
 	if err != nil {
-		log.Fatalf("read temp file: %v", err)
+		log.Fatalf("%v (draft kept at %s)", err, draftFile)
 	}
-	newBody := string(edited)
 
 	if strings.TrimSpace(newBody) == "" {
+		_ = ds.Remove(host, path)
 		fmt.Fprintln(os.Stderr, "Document is empty, skipping publish.")
 		os.Exit(1)
 	}
 
-	if newBody == original {
+	if newBody == original && maps.Equal(newMeta, author) {
+		_ = ds.Remove(host, path)
 		fmt.Fprintln(os.Stderr, "No changes, skipping publish.")
 		return
 	}
 
 	// Publish the edited content with optimistic concurrency check.
-	result, err = client.Publish(host, path, newBody, token, fetchedVersion, nil)
+	result, err = client.Publish(host, path, newBody, token, fetchedVersion, newMeta)
 	if err != nil {
-		log.Fatal(err)
+		log.Fatalf("%v (draft kept at %s)", err, draftFile)
 	}
 
-	if result.Response.Status == protocol.StatusConflict {
-		// Save the user's edits so they aren't lost. Use CreateTemp for
-		// safe file creation (avoids symlink attacks on predictable names).
-		safeName := strings.ReplaceAll(strings.TrimLeft(path, "/"), "/", "-")
-		f, writeErr := os.CreateTemp("", "demarkus-conflict-"+safeName+"-*.md")
-		if writeErr != nil {
-			fmt.Fprintf(os.Stderr, "failed to save edits: %v\n", writeErr)
-			os.Exit(1)
+	switch err := result.Err(); {
+	case err == nil:
+		if err := ds.Remove(host, path); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
 		}
-		conflictFile := f.Name()
-		_, writeErr = f.WriteString(newBody)
-		if writeErr != nil {
-			_ = f.Close()
-			fmt.Fprintf(os.Stderr, "failed to save edits: %v\n", writeErr)
-			os.Exit(1)
-		}
-		_ = f.Close()
+	case fetch.IsConflict(err):
 		serverVersion := result.Response.Metadata["server-version"]
 		fmt.Fprintf(os.Stderr, "Conflict: document updated to version %s since you fetched version %d.\n", serverVersion, fetchedVersion)
-		fmt.Fprintf(os.Stderr, "Your edits saved to %s\n", conflictFile)
-		fmt.Fprintf(os.Stderr, "Re-fetch and reapply your changes.\n")
+		fmt.Fprintf(os.Stderr, "Your edits are kept as a draft at %s\n", draftFile)
+		fmt.Fprintf(os.Stderr, "Re-run edit to resume the draft and reapply your changes on the new version.\n")
 		os.Exit(1)
+	default:
+		fmt.Fprintf(os.Stderr, "Publish not accepted; draft kept at %s\n", draftFile)
 	}
 
 	fmt.Printf("[%s]", result.Response.Status)
@@ -291,24 +462,35 @@ func graphMain(args []string) {
 
 	fs := flag.NewFlagSet("graph", flag.ExitOnError)
 	depth := fs.Int("depth", 2, "maximum crawl depth (link hops from start)")
+	sameHost := fs.Bool("same-host", false, "only fetch documents on the start URL's host")
+	allowHosts := fs.String("allow-hosts", "", "comma-separated extra hosts to fetch (implies -same-host)")
+	hostDepth := fs.String("host-depth", "", "comma-separated per-host depth limits, e.g. docs.example.com=4,other:6309=1")
 	insecure := fs.Bool("insecure", false, "skip TLS certificate verification")
 	noCache := fs.Bool("no-cache", false, "disable caching")
 	cacheDir := fs.String("cache-dir", cache.DefaultDir(), "cache directory (env: DEMARKUS_CACHE_DIR)")
+	showPath := fs.Bool("path", false, "print the shortest route of links from the first URL to the second, instead of the edges")
 	fs.Usage = func() {
-		fmt.Fprintf(os.Stderr, "usage: demarkus graph [-depth N] [-insecure] mark://host:port/path\n")
+		fmt.Fprintf(os.Stderr, "usage: demarkus graph [-depth N] [-same-host] [-allow-hosts h1,h2] [-host-depth h=N,...] [-insecure] mark://host:port/path\n")
+		fmt.Fprintf(os.Stderr, "       demarkus graph -path [-depth N] [flags] mark://host:port/from mark://host:port/to\n")
 		fmt.Fprintf(os.Stderr, "       demarkus graph export [-o file.md]\n\n")
 		fs.PrintDefaults()
 	}
 	_ = fs.Parse(args)
 
-	if fs.NArg() < 1 {
+	if fs.NArg() < 1 || (*showPath && fs.NArg() != 2) {
 		fs.Usage()
 		os.Exit(1)
 	}
 
 	rawURL := fs.Arg(0)
 
-	opts := fetch.Options{Insecure: *insecure}
+	hostDepths, err := parseHostDepths(*hostDepth)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: -host-depth: %v\n", err)
+		os.Exit(2)
+	}
+
+	opts := fetch.Options{Insecure: *insecure, OnRateLimited: reportBusy}
 	if !*noCache {
 		opts.Cache = cache.New(*cacheDir)
 	}
@@ -329,7 +511,10 @@ func graphMain(args []string) {
 		}
 		return r.Response.Status, r.Response.Body, r.Response.Metadata["etag"], nil
 	}, fetch.ParseMarkURL, graphstore.CrawlOptions{
-		MaxDepth: *depth,
+		MaxDepth:   *depth,
+		SameHost:   *sameHost,
+		AllowHosts: splitList(*allowHosts),
+		HostDepth:  hostDepths,
 		OnNode: func(n *graph.Node) {
 			title := n.Title
 			if title == "" {
@@ -343,6 +528,10 @@ func graphMain(args []string) {
 	}
 
 	fmt.Printf("\nGraph: %d nodes, %d edges\n", g.NodeCount(), g.EdgeCount())
+	if *showPath {
+		printRoute(g, rawURL, fs.Arg(1), *depth)
+		return
+	}
 	if g.EdgeCount() > 0 {
 		fmt.Println("\nEdges:")
 		for _, e := range g.GetEdges() {
@@ -353,6 +542,54 @@ func graphMain(args []string) {
 	}
 }
 
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(s string) []string {
+	var out []string
+	for item := range strings.SplitSeq(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// parseHostDepths parses "host=N,host2=M" into per-host depth limits.
+func parseHostDepths(s string) (map[string]int, error) {
+	items := splitList(s)
+	if len(items) == 0 {
+		return nil, nil
+	}
+	depths := make(map[string]int, len(items))
+	for _, item := range items {
+		host, n, ok := strings.Cut(item, "=")
+		d, err := strconv.Atoi(n)
+		if !ok || host == "" || err != nil || d < 0 {
+			return nil, fmt.Errorf("want host=N with N >= 0, got %q", item)
+		}
+		depths[host] = d
+	}
+	return depths, nil
+}
+
+// printRoute prints the shortest route of links in g from one URL to
+// another, and exits with status 1 if there is none within the crawl.
+func printRoute(g *graph.Graph, from, to string, depth int) {
+	from, to = urlnorm.Normalize(from), urlnorm.Normalize(to)
+	route := g.ShortestPath(from, to)
+	if route == nil {
+		fmt.Printf("\nNo route from %s to %s within depth %d.\n", from, to, depth)
+		os.Exit(1)
+	}
+	fmt.Printf("\nShortest route (%d links):\n", len(route)-1)
+	for i, url := range route {
+		arrow := "  "
+		if i > 0 {
+			arrow = "->"
+		}
+		fmt.Printf("  %s %s\n", arrow, nodeLabel(g, url))
+	}
+}
+
 func nodeLabel(g *graph.Graph, url string) string {
 	if n := g.GetNode(url); n != nil && n.Title != "" {
 		return n.Title
@@ -390,6 +627,36 @@ func graphExportMain(args []string) {
 	}
 }
 
+func annotationsMain(args []string) {
+	fs := flag.NewFlagSet("annotations", flag.ExitOnError)
+	outFile := fs.String("o", "", "output file (default: stdout)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: demarkus annotations [-o file.md] [mark://host:port/path]\n\n")
+		fmt.Fprintf(os.Stderr, "Export highlights and notes made in demarkus-tui as markdown, for one\ndocument or all of them.\n\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() > 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	as, err := annotations.Load(annotations.DefaultPath())
+	if err != nil {
+		log.Fatalf("failed to load annotations: %v", err)
+	}
+	md := as.Export(fs.Arg(0))
+
+	if *outFile != "" {
+		if err := os.WriteFile(*outFile, []byte(md), 0o644); err != nil {
+			log.Fatalf("failed to write %s: %v", *outFile, err)
+		}
+		fmt.Fprintf(os.Stderr, "Exported annotations to %s\n", *outFile)
+	} else {
+		fmt.Print(md)
+	}
+}
+
 func infoMain(args []string) {
 	fs := flag.NewFlagSet("info", flag.ExitOnError)
 	insecure := fs.Bool("insecure", false, "skip TLS certificate verification")
@@ -410,7 +677,7 @@ func infoMain(args []string) {
 		log.Fatalf("invalid URL: %v", err)
 	}
 
-	client := fetch.NewClient(fetch.Options{Insecure: *insecure})
+	client := fetch.NewClient(fetch.Options{Insecure: *insecure, OnRateLimited: reportBusy})
 	defer client.Close()
 
 	result, err := client.Fetch(host, protocol.WellKnownManifestPath)
@@ -418,7 +685,7 @@ func infoMain(args []string) {
 		log.Fatal(err)
 	}
 
-	if result.Response.Status == protocol.StatusNotFound {
+	if fetch.IsNotFound(result.Err()) {
 		fmt.Fprintln(os.Stderr, "No agent manifest found at "+protocol.WellKnownManifestPath)
 		os.Exit(1)
 	}
@@ -431,12 +698,170 @@ func infoMain(args []string) {
 	fmt.Print(result.Response.Body)
 }
 
+func searchMain(args []string) {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	local := fs.Bool("local", false, "search documents in the local cache")
+	limit := fs.Int("n", 20, "maximum number of results")
+	authToken := fs.String("auth", "", "auth token, to include read-protected documents (env: DEMARKUS_AUTH)")
+	insecure := fs.Bool("insecure", false, "skip TLS certificate verification")
+	cacheDir := fs.String("cache-dir", cache.DefaultDir(), "cache directory (env: DEMARKUS_CACHE_DIR)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: demarkus search [-n N] [-auth TOKEN] [-insecure] mark://host:port/dir/ \"query\"\n")
+		fmt.Fprintf(os.Stderr, "       demarkus search -local [-n N] \"query\"\n\n")
+		fmt.Fprintf(os.Stderr, "Full-text search of the documents under a directory on a server, or with\n")
+		fmt.Fprintf(os.Stderr, "-local of every document fetched so far. -local works offline and against\n")
+		fmt.Fprintf(os.Stderr, "servers without search support.\n\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if fs.NArg() < 1 || (!*local && fs.NArg() < 2) {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if !*local {
+		searchServer(fs.Arg(0), strings.Join(fs.Args()[1:], " "), *authToken, *limit, *insecure)
+		return
+	}
+
+	idx, err := search.FromCache(cache.New(*cacheDir))
+	if err != nil {
+		log.Fatalf("read cache: %v", err)
+	}
+	hits := idx.Search(strings.Join(fs.Args(), " "), *limit)
+	if len(hits) == 0 {
+		fmt.Fprintf(os.Stderr, "No matches in %d cached documents.\n", idx.Len())
+		os.Exit(1)
+	}
+	for _, h := range hits {
+		title := h.Title
+		if title == "" {
+			title = "(no title)"
+		}
+		fmt.Printf("%s  %s\n", h.URL, title)
+		if h.Snippet != "" {
+			fmt.Printf("    %s\n", h.Snippet)
+		}
+	}
+}
+
+// searchServer sends a SEARCH for query to the directory at rawURL and
+// prints the server's results. Exits non-zero when nothing matches.
+func searchServer(rawURL, query, authToken string, limit int, insecure bool) {
+	host, path, err := fetch.ParseMarkURL(rawURL)
+	if err != nil {
+		log.Fatalf("invalid URL: %v", err)
+	}
+	client := fetch.NewClient(fetch.Options{Insecure: insecure, OnRateLimited: reportBusy})
+	defer client.Close()
+
+	result, err := client.Search(host, path, query, resolveAuthToken(authToken, host), limit)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := result.Err(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Print(result.Response.Body)
+	if result.Response.Metadata["results"] == "0" {
+		os.Exit(1)
+	}
+}
+
+func verifyMain(args []string) {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	insecure := fs.Bool("insecure", false, "skip TLS certificate verification")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: demarkus verify [-insecure] mark://host:port/path.md\n\n")
+		fmt.Fprintf(os.Stderr, "Download every version of a document and verify its hash chain locally.\n")
+		fmt.Fprintf(os.Stderr, "Exits non-zero if any version fails.\n\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	host, path, err := fetch.ParseMarkURL(fs.Arg(0))
+	if err != nil {
+		log.Fatalf("invalid URL: %v", err)
+	}
+
+	client := fetch.NewClient(fetch.Options{Insecure: *insecure, OnRateLimited: reportBusy})
+	defer client.Close()
+
+	result, err := client.Versions(host, path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	history, err := fetch.ParseVersionHistory(result.Response)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if history.Current < 1 {
+		log.Fatalf("server reported no versions for %s", path)
+	}
+	serverClaim := ""
+	if history.ChainKnown {
+		serverClaim = strconv.FormatBool(history.ChainValid)
+	}
+
+	var versions []verify.Version
+	for n := 1; n <= history.Current; n++ {
+		r, err := client.Fetch(host, fmt.Sprintf("%s/v%d", path, n))
+		if err != nil {
+			log.Fatalf("fetch v%d: %v", n, err)
+		}
+		if err := r.Err(); err != nil {
+			log.Fatalf("fetch v%d: %v", n, err)
+		}
+		versions = append(versions, verify.Version{Number: n, Body: r.Response.Body, Metadata: r.Response.Metadata})
+	}
+
+	results := verify.Chain(versions)
+	failed := printVerifyTable(os.Stdout, results)
+
+	local := "true"
+	if failed > 0 {
+		local = "false"
+	}
+	fmt.Printf("\n%d version(s), %d failed. Server chain-valid: %s; local: %s\n", len(results), failed, serverClaim, local)
+	if serverClaim != "" && serverClaim != local {
+		fmt.Println("Warning: local verification disagrees with the server's chain-valid claim.")
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// printVerifyTable writes one PASS/FAIL row per version and returns the
+// number of failures.
+func printVerifyTable(w io.Writer, results []verify.Result) int {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tRESULT\tDETAIL")
+	failed := 0
+	for _, r := range results {
+		status := "PASS"
+		if !r.OK() {
+			status = "FAIL"
+			failed++
+		}
+		fmt.Fprintf(tw, "v%d\t%s\t%s\n", r.Version, status, strings.Join(r.Problems, "; "))
+	}
+	_ = tw.Flush()
+	return failed
+}
+
 func tokenMain(args []string) {
 	if len(args) < 1 {
-		fmt.Fprintf(os.Stderr, "usage: demarkus token <add|remove|list>\n")
+		fmt.Fprintf(os.Stderr, "usage: demarkus token <add|remove|list|test>\n")
 		fmt.Fprintf(os.Stderr, "  add    mark://host:port <token>  Store a token for a server\n")
 		fmt.Fprintf(os.Stderr, "  remove mark://host:port          Remove a stored token\n")
 		fmt.Fprintf(os.Stderr, "  list                             List servers with stored tokens\n")
+		fmt.Fprintf(os.Stderr, "  test   [-auth TOKEN] [-insecure] mark://host:port\n")
+		fmt.Fprintf(os.Stderr, "                                   Check a token with the server and show what it grants\n")
 		os.Exit(1)
 	}
 
@@ -489,6 +914,9 @@ func tokenMain(args []string) {
 			fmt.Println(h)
 		}
 
+	case "test":
+		tokenTest(args[1:])
+
 	default:
 		log.Fatalf("unknown token command: %s", args[0])
 	}
@@ -519,10 +947,10 @@ func bookmarkMain(args []string) {
 
 		// Fetch the document to extract the title.
 		title := path
-		client := fetch.NewClient(fetch.Options{Insecure: *insecure})
+		client := fetch.NewClient(fetch.Options{Insecure: *insecure, OnRateLimited: reportBusy})
 		defer client.Close()
 		result, err := client.Fetch(host, path)
-		if err == nil && result.Response.Status == protocol.StatusOK {
+		if err == nil && result.Err() == nil {
 			if t := links.ExtractTitle(result.Response.Body); t != "" {
 				title = t
 			}
@@ -571,6 +999,120 @@ func bookmarkMain(args []string) {
 	}
 }
 
+func readingListMain(args []string) {
+	if len(args) < 1 {
+		fmt.Fprintf(os.Stderr, "usage: demarkus reading-list <add|read|remove|list>\n")
+		fmt.Fprintf(os.Stderr, "  add    [-insecure] mark://host:port/path  Save a document to read later\n")
+		fmt.Fprintf(os.Stderr, "  read   [-insecure] mark://host:port/path  Mark its current version read\n")
+		fmt.Fprintf(os.Stderr, "  remove mark://host:port/path              Remove a document\n")
+		fmt.Fprintf(os.Stderr, "  list   [-check] [-insecure]               List saved documents (-check fetches\n")
+		fmt.Fprintf(os.Stderr, "                                            each to find updated ones)\n")
+		os.Exit(1)
+	}
+
+	rl, err := readinglist.Load(readinglist.DefaultPath())
+	if err != nil {
+		log.Fatalf("load reading list: %v", err)
+	}
+
+	switch args[0] {
+	case "add", "read":
+		fs := flag.NewFlagSet("reading-list "+args[0], flag.ExitOnError)
+		insecure := fs.Bool("insecure", false, "skip TLS certificate verification")
+		_ = fs.Parse(args[1:])
+		if fs.NArg() < 1 {
+			log.Fatalf("usage: demarkus reading-list %s [-insecure] mark://host:port/path", args[0])
+		}
+		rawURL := fs.Arg(0)
+		host, path, err := fetch.ParseMarkURL(rawURL)
+		if err != nil {
+			log.Fatalf("invalid URL: %v", err)
+		}
+		client := fetch.NewClient(fetch.Options{Insecure: *insecure, OnRateLimited: reportBusy})
+		defer client.Close()
+		result, err := client.Fetch(host, path)
+		if err != nil {
+			log.Fatalf("fetch: %v", err)
+		}
+		if err := result.Err(); err != nil {
+			log.Fatalf("fetch: %v", err)
+		}
+		version := documentVersion(result.Response.Metadata)
+
+		if args[0] == "read" {
+			if !rl.Has(rawURL) {
+				log.Fatalf("not on the reading list: %s", rawURL)
+			}
+			if err := rl.MarkRead(rawURL, version); err != nil {
+				log.Fatalf("mark read: %v", err)
+			}
+			fmt.Fprintf(os.Stderr, "Marked read: %s\n", rawURL)
+			return
+		}
+		if rl.Has(rawURL) {
+			fmt.Fprintln(os.Stderr, "Already on the reading list.")
+			return
+		}
+		title := links.ExtractTitle(result.Response.Body)
+		if title == "" {
+			title = path
+		}
+		if err := rl.Add(rawURL, title, version); err != nil {
+			log.Fatalf("add to reading list: %v", err)
+		}
+		fmt.Fprintf(os.Stderr, "Saved: [%s](%s)\n", title, rawURL)
+
+	case "remove":
+		if len(args) < 2 {
+			log.Fatal("usage: demarkus reading-list remove mark://host:port/path")
+		}
+		if err := rl.Remove(args[1]); err != nil {
+			log.Fatalf("remove from reading list: %v", err)
+		}
+		fmt.Fprintf(os.Stderr, "Removed: %s\n", args[1])
+
+	case "list":
+		fs := flag.NewFlagSet("reading-list list", flag.ExitOnError)
+		check := fs.Bool("check", false, "fetch every document to find ones updated since read")
+		insecure := fs.Bool("insecure", false, "skip TLS certificate verification")
+		_ = fs.Parse(args[1:])
+		if *check {
+			client := fetch.NewClient(fetch.Options{Insecure: *insecure, OnRateLimited: reportBusy})
+			defer client.Close()
+			for _, it := range rl.List() {
+				host, path, err := fetch.ParseMarkURL(it.URL)
+				if err != nil {
+					continue
+				}
+				result, err := client.Fetch(host, path)
+				if err == nil {
+					err = result.Err()
+				}
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "warning: %s: could not check: %v\n", it.URL, err)
+					continue
+				}
+				if _, err := rl.Observe(it.URL, documentVersion(result.Response.Metadata)); err != nil {
+					log.Fatalf("update reading list: %v", err)
+				}
+			}
+		}
+		fmt.Print(rl.Render())
+
+	default:
+		log.Fatalf("unknown reading-list command: %s", args[0])
+	}
+}
+
+// documentVersion identifies a document revision for the reading list: its
+// version number, or its etag for servers that do not version.
+func documentVersion(meta map[string]string) string {
+	if v := meta["version"]; v != "" {
+		return v
+	}
+	return meta["etag"]
+}
+
 // resolveAuthToken returns the auth token from flag, env, or stored tokens.
 func resolveAuthToken(flagValue, host string) string {
 	if flagValue != "" {
@@ -590,7 +1132,7 @@ func resolveBody(verb, flagValue string) string {
 	if flagValue != "" {
 		return flagValue
 	}
-	if verb != protocol.VerbPublish && verb != protocol.VerbAppend {
+	if verb != protocol.VerbPublish && verb != protocol.VerbAppend && !protocol.IsExperimentalVerb(verb) {
 		return ""
 	}
 	info, err := os.Stdin.Stat()
@@ -616,18 +1158,147 @@ func editorCommand(fields []string, file string) (name string, args []string) {
 	return fields[0], args
 }
 
+// editUntilValid opens file in the editor until its frontmatter validates,
+// re-opening it with an error banner after each rejected save. An empty
+// file ends the session with an empty body.
+func editUntilValid(editorFields []string, file string) (body string, meta map[string]string, err error) {
+	for {
+		name, args := editorCommand(editorFields, file)
+		cmd := exec.Command(name, args...)
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return "", nil, fmt.Errorf("editor exited with error: %w", err)
+		}
+
+		edited, err := os.ReadFile(file)
+		if err != nil {
+			return "", nil, fmt.Errorf("read draft: %w", err)
+		}
+		buf := string(edited)
+		if strings.TrimSpace(buf) == "" {
+			return "", nil, nil
+		}
+
+		body, meta, parseErr := parseEditBuffer(buf)
+		if parseErr == nil {
+			return body, meta, nil
+		}
+		fmt.Fprintf(os.Stderr, "Invalid frontmatter: %v\n", parseErr)
+		if err := os.WriteFile(file, []byte(withErrorBanner(buf, parseErr)), 0o600); err != nil {
+			return "", nil, fmt.Errorf("write draft: %w", err)
+		}
+	}
+}
+
+// startDraft prepares the draft file for an edit session on version of
+// the document (0 for a new one, -1 if unknown). An existing draft that
+// differs from the fetched document is offered for resume; otherwise (or
+// if declined) the draft starts from original. A draft of an earlier
+// version would overwrite the changes published since, so resuming it
+// needs a second confirmation; without one, the draft is set aside for
+// the user to reapply to the current version. Returns the file to edit.
+func startDraft(ds *drafts.Store, host, path, original string, version int, in io.Reader, out io.Writer) (string, error) {
+	draft, err := ds.Load(host, path)
+	if err != nil {
+		return "", err
+	}
+	// One reader for every prompt, so none reads ahead into the next.
+	in = bufio.NewReader(in)
+	start := original
+	if draft != nil && draft.Body != original {
+		prompt := fmt.Sprintf("Unpublished draft from %s found. Resume it? [Y/n] ", draft.Modified.Format(time.DateTime))
+		if promptYes(in, out, prompt) {
+			start = draft.Body
+		}
+		if start == draft.Body && draft.BaseVersion >= 0 && version >= 0 && draft.BaseVersion != version {
+			fmt.Fprintf(out, "The draft edits version %d, but the document is now at version %d.\n", draft.BaseVersion, version)
+			if !promptNo(in, out, "Publish it over the changes made since? [y/N] ") {
+				aside, err := ds.SetAside(host, path)
+				if err != nil {
+					return "", err
+				}
+				fmt.Fprintf(out, "Draft set aside at %s; reapply it to the current version.\n", aside)
+				start = original
+			}
+		}
+	}
+	return ds.Save(host, path, start, version)
+}
+
+// promptYes writes prompt to w and reads a line from r. An empty answer or
+// one starting with y counts as yes; EOF counts as no.
+func promptYes(r io.Reader, w io.Writer, prompt string) bool {
+	fmt.Fprint(w, prompt)
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && line == "" {
+		return false
+	}
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "" || strings.HasPrefix(answer, "y")
+}
+
+// promptNo is promptYes for a question whose default is no: only an answer
+// starting with y counts as yes.
+func promptNo(r io.Reader, w io.Writer, prompt string) bool {
+	fmt.Fprint(w, prompt)
+	line, _ := bufio.NewReader(r).ReadString('\n')
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(line)), "y")
+}
+
+// metaFlags collects repeated -meta key=value flags into publisher metadata.
+type metaFlags map[string]string
+
+func (m metaFlags) String() string {
+	pairs := make([]string, 0, len(m))
+	for _, k := range slices.Sorted(maps.Keys(m)) {
+		pairs = append(pairs, k+"="+m[k])
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (m metaFlags) Set(s string) error {
+	k, v, ok := strings.Cut(s, "=")
+	if !ok {
+		return fmt.Errorf("expected key=value, got %q", s)
+	}
+	k = strings.TrimSpace(k)
+	if !protocol.IsValidMetaKey(k) {
+		return fmt.Errorf("invalid metadata key %q: use lowercase letters, digits and hyphens", k)
+	}
+	if protocol.IsServerKey(k) {
+		return fmt.Errorf("metadata key %q is managed by the server", k)
+	}
+	if !protocol.IsValidMetaValue(v) {
+		return fmt.Errorf("metadata value for %q must be a single line", k)
+	}
+	if _, dup := m[k]; dup {
+		return fmt.Errorf("metadata key %q given more than once", k)
+	}
+	m[k] = v
+	return nil
+}
+
 var validVerbs = map[string]bool{
-	protocol.VerbFetch:    true,
-	protocol.VerbList:     true,
-	protocol.VerbVersions: true,
-	protocol.VerbPublish:  true,
-	protocol.VerbArchive:  true,
-	protocol.VerbAppend:   true,
+	protocol.VerbFetch:     true,
+	protocol.VerbList:      true,
+	protocol.VerbVersions:  true,
+	protocol.VerbPublish:   true,
+	protocol.VerbArchive:   true,
+	protocol.VerbAppend:    true,
+	protocol.VerbSearch:    true,
+	protocol.VerbDiff:      true,
+	protocol.VerbPurge:     true,
+	protocol.VerbInfo:      true,
+	protocol.VerbMove:      true,
+	protocol.VerbBacklinks: true,
+	protocol.VerbJob:       true,
 }
 
 func validateVerb(verb string) error {
-	if !validVerbs[verb] {
-		return fmt.Errorf("unsupported verb: %s (valid: FETCH, LIST, VERSIONS, PUBLISH, ARCHIVE, APPEND)", verb)
+	if !validVerbs[verb] && !protocol.IsExperimentalVerb(verb) {
+		return fmt.Errorf("unsupported verb: %s (valid: FETCH, LIST, VERSIONS, PUBLISH, ARCHIVE, APPEND, SEARCH, DIFF, PURGE, INFO, MOVE, BACKLINKS, JOB, X-NAME)", verb)
 	}
 	return nil
 }
@@ -1,8 +1,13 @@
 package main
 
 import (
+	"io"
+	"os"
+	"strings"
 	"testing"
 
+	"github.com/latebit/demarkus/client/internal/drafts"
+	"github.com/latebit/demarkus/client/internal/verify"
 	"github.com/latebit/demarkus/protocol"
 )
 
@@ -15,7 +20,15 @@ func TestValidateVerb(t *testing.T) {
 		{protocol.VerbList, false},
 		{protocol.VerbVersions, false},
 		{protocol.VerbPublish, false},
+		{protocol.VerbSearch, false},
+		{protocol.VerbDiff, false},
+		{protocol.VerbPurge, false},
+		{protocol.VerbInfo, false},
+		{protocol.VerbMove, false},
+		{protocol.VerbJob, false},
+		{"X-SUBSCRIBE", false},
 		{"DELETE", true},
+		{"X-", true},
 		{"", true},
 		{"fetch", true},
 	}
@@ -78,3 +91,175 @@ func TestEditorCommand(t *testing.T) {
 		})
 	}
 }
+
+func TestPromptYes(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"\n", true},
+		{"y\n", true},
+		{"Yes\n", true},
+		{"n\n", false},
+		{"no\n", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := promptYes(strings.NewReader(tt.input), io.Discard, "? "); got != tt.want {
+			t.Errorf("promptYes(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestStartDraft(t *testing.T) {
+	ds, err := drafts.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	const host, path = "localhost:6309", "/doc.md"
+
+	read := func(p string) string {
+		t.Helper()
+		data, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	// No draft: start from the fetched document without prompting.
+	p, err := startDraft(ds, host, path, "original", 2, strings.NewReader(""), io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := read(p); got != "original" {
+		t.Errorf("fresh draft = %q, want %q", got, "original")
+	}
+
+	// A leftover draft is resumed when accepted.
+	if _, err := ds.Save(host, path, "unsaved work", 2); err != nil {
+		t.Fatal(err)
+	}
+	p, _ = startDraft(ds, host, path, "original", 2, strings.NewReader("y\n"), io.Discard)
+	if got := read(p); got != "unsaved work" {
+		t.Errorf("resumed draft = %q, want %q", got, "unsaved work")
+	}
+
+	// Declining discards it in favour of the fetched document.
+	p, _ = startDraft(ds, host, path, "original", 2, strings.NewReader("n\n"), io.Discard)
+	if got := read(p); got != "original" {
+		t.Errorf("declined draft = %q, want %q", got, "original")
+	}
+
+	// A draft of an earlier version is resumed only when confirmed.
+	if _, err := ds.Save(host, path, "stale work", 1); err != nil {
+		t.Fatal(err)
+	}
+	p, _ = startDraft(ds, host, path, "original", 2, strings.NewReader("y\ny\n"), io.Discard)
+	if d, _ := ds.Load(host, path); read(p) != "stale work" || d.BaseVersion != 2 {
+		t.Errorf("confirmed stale draft = %q based on v%d, want %q based on v2", read(p), d.BaseVersion, "stale work")
+	}
+
+	// Otherwise it is set aside and the edit starts from the current version.
+	if _, err := ds.Save(host, path, "stale work", 1); err != nil {
+		t.Fatal(err)
+	}
+	p, _ = startDraft(ds, host, path, "original", 2, strings.NewReader("y\n\n"), io.Discard)
+	if got := read(p); got != "original" {
+		t.Errorf("unconfirmed stale draft = %q, want %q", got, "original")
+	}
+	if got := read(p + ".v1"); got != "stale work" {
+		t.Errorf("set-aside draft = %q, want %q", got, "stale work")
+	}
+}
+
+func TestMetaFlags(t *testing.T) {
+	m := metaFlags{}
+	for _, arg := range []string{"message=nightly build", "tags=status,ops", "content-type=text/markdown", "empty="} {
+		if err := m.Set(arg); err != nil {
+			t.Fatalf("Set(%q): %v", arg, err)
+		}
+	}
+	if m["message"] != "nightly build" || m["tags"] != "status,ops" || m["empty"] != "" {
+		t.Errorf("got %v", map[string]string(m))
+	}
+	if got, want := m.String(), "content-type=text/markdown,empty=,message=nightly build,tags=status,ops"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	for _, bad := range []string{"novalue", "Bad=x", "version=3", "message=again", "x=a\nb"} {
+		if err := m.Set(bad); err == nil {
+			t.Errorf("Set(%q) should fail", bad)
+		}
+	}
+}
+
+func TestPrintVerifyTable(t *testing.T) {
+	var buf strings.Builder
+	failed := printVerifyTable(&buf, []verify.Result{
+		{Version: 1},
+		{Version: 2, Problems: []string{"content-hash does not match body"}},
+	})
+	if failed != 1 {
+		t.Errorf("failed = %d, want 1", failed)
+	}
+	out := buf.String()
+	for _, want := range []string{"v1  ", "PASS", "v2  ", "FAIL", "content-hash does not match body"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestConflictMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		meta     map[string]string
+		expected int
+		want     string
+	}{
+		{
+			name:     "stale version",
+			meta:     map[string]string{"server-version": "4", "your-version": "2"},
+			expected: 2,
+			want:     "conflict: document is at version 4, not 2; fetch the latest version and retry with -expected-version 4",
+		},
+		{
+			name:     "create only",
+			meta:     map[string]string{"server-version": "1"},
+			expected: 0,
+			want:     "conflict: document already exists at version 1 (-expected-version 0 only creates new documents)",
+		},
+		{
+			name:     "missing server version",
+			expected: 0,
+			want:     "conflict: document already exists at version unknown (-expected-version 0 only creates new documents)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := conflictMessage(tt.meta, tt.expected); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseHostDepths(t *testing.T) {
+	got, err := parseHostDepths(" docs.example.com=4, other:6309=0 ")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 2 || got["docs.example.com"] != 4 || got["other:6309"] != 0 {
+		t.Errorf("got %v", got)
+	}
+
+	if got, err := parseHostDepths(""); got != nil || err != nil {
+		t.Errorf("empty: got %v, %v; want nil, nil", got, err)
+	}
+	for _, bad := range []string{"host", "host=x", "=2", "host=-1"} {
+		if _, err := parseHostDepths(bad); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/latebit/demarkus/client/internal/fetch"
+	"github.com/latebit/demarkus/protocol"
+)
+
+// publishMain publishes local files under a directory of a server
+// together: either every document gets its new version or none does.
+func publishMain(args []string) {
+	fs := flag.NewFlagSet("publish", flag.ExitOnError)
+	authToken := fs.String("auth", "", "auth token (env: DEMARKUS_AUTH)")
+	insecure := fs.Bool("insecure", false, "skip TLS certificate verification")
+	meta := metaFlags{}
+	fs.Var(meta, "meta", "publisher metadata key=value for every document (repeatable)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: demarkus publish [-auth TOKEN] [-meta key=value ...] [-insecure] mark://host:port/dir/ FILE...\n\n")
+		fmt.Fprintf(os.Stderr, "Publish local files under dir/, at their paths relative to the current\n")
+		fmt.Fprintf(os.Stderr, "directory, in one atomic BATCH: if any is rejected, none is published.\n\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() < 2 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	host, dir, err := fetch.ParseMarkURL(fs.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	paths, err := publishPaths(dir, fs.Args()[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	docs := make([]fetch.PublishDoc, len(paths))
+	for i, file := range fs.Args()[1:] {
+		body, err := os.ReadFile(file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		docs[i] = fetch.PublishDoc{Path: paths[i], Body: string(body), ExpectedVersion: -1, Meta: meta}
+	}
+
+	client := fetch.NewClient(fetch.Options{Insecure: *insecure, OnRateLimited: reportBusy})
+	defer client.Close()
+	results, err := client.PublishAll(host, docs, resolveAuthToken(*authToken, host))
+	var pe *fetch.PublishError
+	if errors.As(err, &pe) {
+		fmt.Fprintf(os.Stderr, "%v\nNothing was published.\n", pe)
+		if pe.Response.Status == protocol.StatusConflict {
+			os.Exit(exitConflict)
+		}
+		os.Exit(1)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	for i, r := range results {
+		fmt.Printf("[%s] %s v%s\n", r.Response.Status, paths[i], r.Response.Metadata["version"])
+	}
+}
+
+// publishPaths returns the server paths of files published under dir: each
+// file's path relative to the current directory, which must stay inside it.
+func publishPaths(dir string, files []string) ([]string, error) {
+	if !strings.HasSuffix(dir, "/") {
+		return nil, fmt.Errorf("%s is not a directory: end it with /", dir)
+	}
+	paths := make([]string, len(files))
+	for i, file := range files {
+		rel := filepath.ToSlash(filepath.Clean(file))
+		if filepath.IsAbs(file) || rel == ".." || strings.HasPrefix(rel, "../") {
+			return nil, fmt.Errorf("%s is outside the current directory", file)
+		}
+		paths[i] = path.Join(dir, rel)
+	}
+	return paths, nil
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPublishPaths(t *testing.T) {
+	got, err := publishPaths("/docs/", []string{"index.md", "./guide/setup.md"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/docs/index.md", "/docs/guide/setup.md"}; !slices.Equal(got, want) {
+		t.Errorf("publishPaths = %v, want %v", got, want)
+	}
+
+	for _, tt := range []struct {
+		dir  string
+		file string
+	}{
+		{"/docs", "index.md"},
+		{"/docs/", "../secret.md"},
+		{"/docs/", "/etc/passwd"},
+	} {
+		if _, err := publishPaths(tt.dir, []string{tt.file}); err == nil {
+			t.Errorf("publishPaths(%q, %q) succeeded", tt.dir, tt.file)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/latebit/demarkus/client/internal/fetch"
+	"github.com/latebit/demarkus/client/internal/links"
+	"github.com/latebit/demarkus/protocol"
+)
+
+// relinked is a markdown file of a tree whose links relink changed.
+type relinked struct {
+	file string // on disk
+	rel  string // slash-separated, relative to the tree
+	body string // rewritten
+}
+
+// relinkMain rewrites absolute mark:// links to one host across a local
+// tree of markdown files, such as a mirror of a site that changed domain
+// or port, and optionally publishes the rewritten documents.
+func relinkMain(args []string) {
+	fset := flag.NewFlagSet("relink", flag.ExitOnError)
+	dryRun := fset.Bool("n", false, "list the files that would change without writing or publishing them")
+	publish := fset.String("publish", "", "publish the rewritten documents under this mark:// directory URL")
+	authToken := fset.String("auth", "", "auth token for -publish (env: DEMARKUS_AUTH)")
+	insecure := fset.Bool("insecure", false, "skip TLS certificate verification")
+	fset.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: demarkus relink [-n] [-publish mark://host:port/dir/ [-auth TOKEN] [-insecure]] DIR OLD-HOST NEW-HOST\n\n")
+		fmt.Fprintf(os.Stderr, "Rewrite mark://OLD-HOST links to mark://NEW-HOST in the markdown files under\n")
+		fmt.Fprintf(os.Stderr, "DIR. With -publish, the rewritten files are also published, at their paths\n")
+		fmt.Fprintf(os.Stderr, "under DIR, %d to an atomic batch; a file is only rewritten on disk once its\n", protocol.MaxBatchSize)
+		fmt.Fprintf(os.Stderr, "batch is published.\n\n")
+		fset.PrintDefaults()
+	}
+	_ = fset.Parse(args)
+	if fset.NArg() != 3 {
+		fset.Usage()
+		os.Exit(1)
+	}
+	dir, from, to := fset.Arg(0), fset.Arg(1), fset.Arg(2)
+
+	changed, err := relinkTree(dir, from, to)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *dryRun {
+		for _, r := range changed {
+			fmt.Println(r.file)
+		}
+		fmt.Printf("%d file(s) would change\n", len(changed))
+		return
+	}
+	if *publish == "" {
+		for _, r := range changed {
+			writeRelinked(r)
+		}
+		fmt.Printf("%d file(s) rewritten\n", len(changed))
+		return
+	}
+
+	host, root, err := fetch.ParseMarkURL(*publish)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !strings.HasSuffix(root, "/") {
+		log.Fatalf("%s is not a directory: end it with /", *publish)
+	}
+	client := fetch.NewClient(fetch.Options{Insecure: *insecure, OnRateLimited: reportBusy})
+	defer client.Close()
+	token := resolveAuthToken(*authToken, host)
+	for batch := range slices.Chunk(changed, protocol.MaxBatchSize) {
+		docs := make([]fetch.PublishDoc, len(batch))
+		for i, r := range batch {
+			docs[i] = fetch.PublishDoc{Path: path.Join(root, r.rel), Body: r.body, ExpectedVersion: -1}
+		}
+		results, err := client.PublishAll(host, docs, token)
+		if err != nil {
+			log.Fatalf("%v\nthis batch was not published; files of earlier batches were", err)
+		}
+		for i, r := range batch {
+			writeRelinked(r)
+			fmt.Printf("[%s] %s v%s\n", results[i].Response.Status, docs[i].Path, results[i].Response.Metadata["version"])
+		}
+	}
+}
+
+// relinkTree returns the markdown files under dir whose mark:// links to
+// the host from would change to name to, with their rewritten bodies, in
+// path order. Hidden files and directories are skipped.
+func relinkTree(dir, from, to string) ([]relinked, error) {
+	var changed []relinked
+	err := filepath.WalkDir(dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(d.Name(), ".") && file != dir {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), ".md") {
+			return nil
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		body := links.RelinkHost(string(data), from, to)
+		if body == string(data) {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		changed = append(changed, relinked{file: file, rel: filepath.ToSlash(rel), body: body})
+		return nil
+	})
+	return changed, err
+}
+
+// writeRelinked writes a rewritten file back, keeping its permissions.
+func writeRelinked(r relinked) {
+	info, err := os.Stat(r.file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(r.file, []byte(r.body), info.Mode().Perm()); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRelinkTree(t *testing.T) {
+	dir := t.TempDir()
+	for name, body := range map[string]string{
+		"index.md":         "# Home\n\n[Guide](mark://old.example/guide/setup.md)\n",
+		"guide/setup.md":   "# Setup\n\nBack to [home](mark://OLD.example:6309/).\n",
+		"other.md":         "# Other\n\n[Elsewhere](mark://else.example/)\n",
+		"notes.txt":        "mark://old.example/\n",
+		".drafts/draft.md": "mark://old.example/\n",
+	} {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	changed, err := relinkTree(dir, "old.example", "new.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != 2 || changed[0].rel != "guide/setup.md" || changed[1].rel != "index.md" {
+		t.Fatalf("changed = %+v", changed)
+	}
+	if want := "# Setup\n\nBack to [home](mark://new.example/).\n"; changed[0].body != want {
+		t.Errorf("setup.md = %q, want %q", changed[0].body, want)
+	}
+	if data, _ := os.ReadFile(changed[1].file); string(data) != "# Home\n\n[Guide](mark://old.example/guide/setup.md)\n" {
+		t.Errorf("relinkTree wrote %s: %q", changed[1].file, data)
+	}
+}
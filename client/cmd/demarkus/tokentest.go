@@ -0,0 +1,111 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/latebit/demarkus/client/internal/fetch"
+	"github.com/latebit/demarkus/protocol"
+)
+
+// tokenTest checks the token for a server against it: whether the server
+// accepts it, and what it grants. Exits non-zero if it is not accepted.
+func tokenTest(args []string) {
+	fs := flag.NewFlagSet("token test", flag.ExitOnError)
+	authToken := fs.String("auth", "", "token to test (default: DEMARKUS_AUTH, then the stored token)")
+	insecure := fs.Bool("insecure", false, "skip TLS certificate verification")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: demarkus token test [-auth TOKEN] [-insecure] mark://host:port\n\n")
+		fmt.Fprintf(os.Stderr, "Ask the server whether it accepts the token, and which operations and\n")
+		fmt.Fprintf(os.Stderr, "paths it grants until when. Nothing is written.\n\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	host, _, err := fetch.ParseMarkURL(fs.Arg(0))
+	if err != nil {
+		log.Fatalf("invalid URL: %v", err)
+	}
+	token := resolveAuthToken(*authToken, host)
+	if token == "" {
+		log.Fatalf("no token for %s: pass -auth, set DEMARKUS_AUTH, or run demarkus token add", host)
+	}
+
+	client := fetch.NewClient(fetch.Options{Insecure: *insecure, OnRateLimited: reportBusy})
+	defer client.Close()
+	result, err := client.TokenInfo(host, token)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !describeToken(os.Stdout, host, result.Response, time.Now()) {
+		os.Exit(1)
+	}
+}
+
+// describeToken explains the server's answer to a token check and reports
+// whether the token was accepted.
+func describeToken(w io.Writer, host string, resp protocol.Response, now time.Time) bool {
+	meta := resp.Metadata
+	switch resp.Status {
+	case protocol.StatusOK:
+	case protocol.StatusUnauthorized:
+		if exp := meta["token-expires"]; exp != "" {
+			fmt.Fprintf(w, "Token for %s: rejected, it expired %s\n", host, describeExpiry(exp, now))
+		} else {
+			fmt.Fprintf(w, "Token for %s: rejected, the server does not know it\n", host)
+		}
+		return false
+	case protocol.StatusNotFound:
+		fmt.Fprintf(w, "Token for %s: unknown, the server does not support token checks (%s)\n", host, protocol.WellKnownTokenPath)
+		return false
+	case protocol.StatusNotPermitted:
+		fmt.Fprintf(w, "Token for %s: rejected, the server has no tokens configured\n", host)
+		return false
+	default:
+		fmt.Fprintf(w, "Token for %s: unknown, the server answered %s\n", host, resp.Status)
+		return false
+	}
+
+	list := func(v string) string {
+		if v == "" {
+			return "(none)"
+		}
+		return strings.ReplaceAll(v, ",", ", ")
+	}
+	expires := "never"
+	if exp := meta["token-expires"]; exp != "" {
+		expires = describeExpiry(exp, now)
+	}
+	fmt.Fprintf(w, "Token for %s: accepted\n", host)
+	fmt.Fprintf(w, "  label:      %s\n", meta["token-label"])
+	fmt.Fprintf(w, "  operations: %s\n", list(meta["token-operations"]))
+	fmt.Fprintf(w, "  paths:      %s\n", list(meta["token-paths"]))
+	fmt.Fprintf(w, "  expires:    %s\n", expires)
+	return true
+}
+
+// describeExpiry formats an RFC 3339 expiry with how far it is from now.
+func describeExpiry(exp string, now time.Time) string {
+	t, err := time.Parse(time.RFC3339, exp)
+	if err != nil {
+		return exp
+	}
+	d := t.Sub(now)
+	switch {
+	case d >= 48*time.Hour:
+		return fmt.Sprintf("%s (in %d days)", exp, int(d.Hours()/24))
+	case d > 0:
+		return fmt.Sprintf("%s (in %s)", exp, d.Round(time.Minute))
+	default:
+		return fmt.Sprintf("%s (%d days ago)", exp, int(-d.Hours()/24))
+	}
+}
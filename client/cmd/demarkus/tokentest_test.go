@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/latebit/demarkus/protocol"
+)
+
+func TestDescribeToken(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		resp   protocol.Response
+		wantOK bool
+		want   []string
+	}{
+		{
+			"accepted",
+			protocol.Response{Status: protocol.StatusOK, Metadata: map[string]string{
+				"token-label": "laptop", "token-operations": "publish,read", "token-paths": "/docs/**,/blog/*",
+				"token-expires": "2026-01-31T00:00:00Z",
+			}},
+			true,
+			[]string{"accepted", "label:      laptop", "operations: publish, read", "paths:      /docs/**, /blog/*", "2026-01-31T00:00:00Z (in 30 days)"},
+		},
+		{
+			"never expires",
+			protocol.Response{Status: protocol.StatusOK, Metadata: map[string]string{"token-label": "ci", "token-operations": "read"}},
+			true,
+			[]string{"expires:    never", "paths:      (none)"},
+		},
+		{
+			"expired",
+			protocol.Response{Status: protocol.StatusUnauthorized, Metadata: map[string]string{"token-expires": "2025-12-22T00:00:00Z"}},
+			false,
+			[]string{"rejected, it expired 2025-12-22T00:00:00Z (10 days ago)"},
+		},
+		{
+			"unknown",
+			protocol.Response{Status: protocol.StatusUnauthorized, Metadata: map[string]string{}},
+			false,
+			[]string{"rejected, the server does not know it"},
+		},
+		{
+			"old server",
+			protocol.Response{Status: protocol.StatusNotFound, Metadata: map[string]string{}},
+			false,
+			[]string{"does not support token checks"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sb strings.Builder
+			if ok := describeToken(&sb, "h:6309", tt.resp, now); ok != tt.wantOK {
+				t.Errorf("ok = %v, want %v", ok, tt.wantOK)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(sb.String(), want) {
+					t.Errorf("output missing %q:\n%s", want, sb.String())
+				}
+			}
+		})
+	}
+}
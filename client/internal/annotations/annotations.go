@@ -0,0 +1,247 @@
+// Package annotations stores highlighted passages and notes on documents.
+//
+// Annotations are kept in ~/.mark/annotations.json. Each one is pinned to
+// a document version and a byte offset into that version's body: versions
+// are immutable, so the offset stays valid however the document changes
+// later, and the annotated version remains fetchable at its /vN URL.
+package annotations
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// schemaVersion is the on-disk format version. Increment on breaking changes.
+const schemaVersion = 1
+
+// versionSuffixRe matches the /vN suffix of a version-pinned URL.
+var versionSuffixRe = regexp.MustCompile(`/v[0-9]+$`)
+
+// Annotation is a highlighted passage of one document version.
+type Annotation struct {
+	URL     string    `json:"url"` // document URL without a version suffix
+	Version int       `json:"version"`
+	Offset  int       `json:"offset"` // byte offset of Text in the version's body
+	Text    string    `json:"text"`
+	Note    string    `json:"note,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// PinnedURL returns the URL of the annotated version.
+func (a Annotation) PinnedURL() string {
+	return fmt.Sprintf("%s/v%d", a.URL, a.Version)
+}
+
+// document is the on-disk JSON envelope.
+type document struct {
+	Version     int          `json:"version"`
+	Annotations []Annotation `json:"annotations"`
+}
+
+// Store manages annotations persisted as a JSON file.
+type Store struct {
+	path        string
+	annotations []Annotation
+}
+
+// DefaultPath returns the default annotations file path (~/.mark/annotations.json).
+func DefaultPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".mark", "annotations.json")
+}
+
+// Load reads annotations from path. Returns an empty store if the file
+// does not exist yet.
+func Load(path string) (*Store, error) {
+	if path == "" {
+		return nil, errors.New("annotations file path is empty (could not determine home directory)")
+	}
+	s := &Store{path: path}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("read annotations file %q: %w", path, err)
+	}
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse annotations file %q: %w", path, err)
+	}
+	if doc.Version > schemaVersion {
+		return nil, fmt.Errorf("annotations file %q: unsupported version %d", path, doc.Version)
+	}
+	s.annotations = doc.Annotations
+	return s, nil
+}
+
+// DocumentURL strips the version suffix from a version-pinned URL, so
+// annotations made on /doc.md and /doc.md/v3 belong to the same document.
+func DocumentURL(url string) string {
+	return versionSuffixRe.ReplaceAllString(url, "")
+}
+
+// Add saves a, replacing the note of an existing annotation with the same
+// URL, version and offset.
+func (s *Store) Add(a Annotation) error {
+	a.URL = DocumentURL(a.URL)
+	if a.Created.IsZero() {
+		a.Created = time.Now()
+	}
+	for i, old := range s.annotations {
+		if old.URL == a.URL && old.Version == a.Version && old.Offset == a.Offset {
+			s.annotations[i] = a
+			return s.save()
+		}
+	}
+	s.annotations = append(s.annotations, a)
+	return s.save()
+}
+
+// For returns the annotations of a document across all its versions,
+// ordered by version and offset. url may be version-pinned.
+func (s *Store) For(url string) []Annotation {
+	url = DocumentURL(url)
+	var out []Annotation
+	for _, a := range s.annotations {
+		if a.URL == url {
+			out = append(out, a)
+		}
+	}
+	sortAnnotations(out)
+	return out
+}
+
+// Len returns the number of stored annotations.
+func (s *Store) Len() int {
+	return len(s.annotations)
+}
+
+// Export returns the annotations of the document at url, or of every
+// document when url is empty, as a markdown document.
+func (s *Store) Export(url string) string {
+	anns := s.annotations
+	if url != "" {
+		anns = s.For(url)
+	} else {
+		anns = append([]Annotation(nil), anns...)
+		sortAnnotations(anns)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("# Annotations\n")
+	if len(anns) == 0 {
+		sb.WriteString("\nNo annotations yet. Press `a` on a page in demarkus-tui to highlight a passage.\n")
+		return sb.String()
+	}
+	doc := ""
+	for _, a := range anns {
+		if a.URL != doc {
+			doc = a.URL
+			fmt.Fprintf(&sb, "\n## %s\n", doc)
+		}
+		sb.WriteString("\n")
+		for line := range strings.SplitSeq(a.Text, "\n") {
+			sb.WriteString(strings.TrimRight("> "+line, " ") + "\n")
+		}
+		sb.WriteString("\n")
+		if a.Note != "" {
+			sb.WriteString(a.Note + " — ")
+		}
+		fmt.Fprintf(&sb, "[v%d](%s), %s\n", a.Version, a.PinnedURL(), a.Created.Format("2006-01-02"))
+	}
+	return sb.String()
+}
+
+// Highlight returns body with the passages of anns emphasised. Only
+// annotations whose text is still at their offset are applied; passages
+// spanning lines, containing emphasis markers or inside code blocks are
+// left as they are, since wrapping them would break the markdown.
+func Highlight(body string, anns []Annotation) string {
+	anns = append([]Annotation(nil), anns...)
+	sort.Slice(anns, func(i, j int) bool { return anns[i].Offset > anns[j].Offset })
+	fences := fencedRanges(body)
+	end := len(body) + 1
+	for _, a := range anns {
+		stop := a.Offset + len(a.Text)
+		switch {
+		case a.Text == "" || a.Offset < 0 || stop > len(body) || stop > end:
+			continue
+		case body[a.Offset:stop] != a.Text:
+			continue
+		case strings.ContainsAny(a.Text, "\n*_`") || inRanges(fences, a.Offset):
+			continue
+		}
+		body = body[:a.Offset] + "**" + a.Text + "**" + body[stop:]
+		end = a.Offset
+	}
+	return body
+}
+
+// fencedRanges returns the byte ranges of fenced code blocks in body.
+func fencedRanges(body string) [][2]int {
+	var ranges [][2]int
+	start, pos := -1, 0
+	for line := range strings.SplitAfterSeq(body, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			if start < 0 {
+				start = pos
+			} else {
+				ranges = append(ranges, [2]int{start, pos + len(line)})
+				start = -1
+			}
+		}
+		pos += len(line)
+	}
+	if start >= 0 {
+		ranges = append(ranges, [2]int{start, len(body)})
+	}
+	return ranges
+}
+
+func inRanges(ranges [][2]int, off int) bool {
+	for _, r := range ranges {
+		if off >= r[0] && off < r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+func sortAnnotations(anns []Annotation) {
+	sort.SliceStable(anns, func(i, j int) bool {
+		a, b := anns[i], anns[j]
+		if a.URL != b.URL {
+			return a.URL < b.URL
+		}
+		if a.Version != b.Version {
+			return a.Version < b.Version
+		}
+		return a.Offset < b.Offset
+	})
+}
+
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(document{Version: schemaVersion, Annotations: s.annotations}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("create annotations directory: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write annotations file: %w", err)
+	}
+	return os.Rename(tmp, s.path)
+}
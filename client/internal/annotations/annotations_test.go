@@ -0,0 +1,72 @@
+package annotations
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStoreRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "annotations.json")
+	s, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC)
+	add := func(a Annotation) {
+		t.Helper()
+		a.Created = created
+		if err := s.Add(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	add(Annotation{URL: "mark://h/doc.md/v2", Version: 2, Offset: 10, Text: "later", Note: "first"})
+	add(Annotation{URL: "mark://h/doc.md", Version: 1, Offset: 4, Text: "earlier"})
+	add(Annotation{URL: "mark://h/doc.md", Version: 2, Offset: 10, Text: "later", Note: "edited"})
+	add(Annotation{URL: "mark://h/other.md", Version: 1, Offset: 0, Text: "elsewhere"})
+
+	s, err = Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := s.For("mark://h/doc.md/v7")
+	if len(got) != 2 {
+		t.Fatalf("For = %+v, want 2 annotations", got)
+	}
+	if got[0].Version != 1 || got[1].Note != "edited" || got[1].URL != "mark://h/doc.md" {
+		t.Errorf("For = %+v", got)
+	}
+	if s.Len() != 3 {
+		t.Errorf("Len = %d, want 3", s.Len())
+	}
+
+	md := s.Export("mark://h/doc.md")
+	for _, want := range []string{"## mark://h/doc.md\n", "> earlier\n", "edited — [v2](mark://h/doc.md/v2), 2026-03-05\n"} {
+		if !strings.Contains(md, want) {
+			t.Errorf("Export missing %q:\n%s", want, md)
+		}
+	}
+	if strings.Contains(md, "elsewhere") {
+		t.Errorf("Export of one document includes another:\n%s", md)
+	}
+	if all := s.Export(""); !strings.Contains(all, "## mark://h/other.md\n") {
+		t.Errorf("Export of all documents:\n%s", all)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	body := "# Title\n\nSome plain words here.\n\n```\ncode words\n```\n"
+	anns := []Annotation{
+		{Offset: strings.Index(body, "plain"), Text: "plain"},
+		{Offset: strings.Index(body, "here"), Text: "here"},
+		{Offset: strings.Index(body, "code"), Text: "code"},   // in a code block
+		{Offset: 0, Text: "nope"},                             // text moved
+		{Offset: strings.Index(body, "words"), Text: "words"}, // applied
+	}
+	got := Highlight(body, anns)
+	want := "# Title\n\nSome **plain** **words** **here**.\n\n```\ncode words\n```\n"
+	if got != want {
+		t.Errorf("Highlight =\n%q\nwant\n%q", got, want)
+	}
+}
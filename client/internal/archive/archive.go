@@ -0,0 +1,133 @@
+// Package archive snapshots a Mark Protocol site into a single file and
+// verifies such snapshots offline.
+//
+// An archive holds every version of every document under a path, with the
+// metadata the server sent for each: etag, content-hash and previous-hash
+// are the hash-chain material that lets the verify package check each
+// document's history without the server. The archive as a whole carries a
+// digest over its documents, so corruption or editing of the file is
+// detected too. It is stored as gzip-compressed JSON.
+package archive
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Format is the archive format version written by this package.
+const Format = 1
+
+// Archive is a snapshot of a site.
+type Archive struct {
+	Format    int        `json:"format"`
+	Host      string     `json:"host"`
+	Root      string     `json:"root"` // the directory that was archived, e.g. "/"
+	Created   time.Time  `json:"created"`
+	Documents []Document `json:"documents"` // sorted by path
+	Skipped   []Skipped  `json:"skipped,omitempty"`
+	Digest    string     `json:"digest"` // see Digest
+}
+
+// Document is the full version history of one document.
+type Document struct {
+	Path string `json:"path"`
+
+	// ChainValid is the server's chain-valid claim at snapshot time: "true",
+	// "false", or "" if it made none.
+	ChainValid string    `json:"chain_valid,omitempty"`
+	Versions   []Version `json:"versions"` // oldest first
+}
+
+// Version is one version of a document as the server returned it.
+type Version struct {
+	Number   int               `json:"number"`
+	Body     string            `json:"body"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+// Skipped records a path the snapshot could not archive, and why.
+type Skipped struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// Digest returns the SHA-256 of the JSON encoding of documents, as
+// "sha256-<hex>". Map keys are encoded sorted, so the encoding, and the
+// digest, only depend on the archive's content.
+func Digest(documents []Document) (string, error) {
+	data, err := json.Marshal(documents)
+	if err != nil {
+		return "", fmt.Errorf("encode documents: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return "sha256-" + hex.EncodeToString(sum[:]), nil
+}
+
+// Write encodes a to w, setting its digest.
+func Write(w io.Writer, a *Archive) error {
+	digest, err := Digest(a.Documents)
+	if err != nil {
+		return err
+	}
+	a.Digest = digest
+
+	zw := gzip.NewWriter(w)
+	if err := json.NewEncoder(zw).Encode(a); err != nil {
+		_ = zw.Close()
+		return fmt.Errorf("encode archive: %w", err)
+	}
+	return zw.Close()
+}
+
+// Read decodes an archive from r. It does not verify it.
+func Read(r io.Reader) (*Archive, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("read archive: %w", err)
+	}
+	defer func() { _ = zr.Close() }()
+
+	var a Archive
+	if err := json.NewDecoder(zr).Decode(&a); err != nil {
+		return nil, fmt.Errorf("decode archive: %w", err)
+	}
+	if a.Format != Format {
+		return nil, fmt.Errorf("unsupported archive format %d (want %d)", a.Format, Format)
+	}
+	return &a, nil
+}
+
+// Save writes a to the file at path, replacing it only once fully written.
+func Save(path string, a *Archive) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := Write(f, a); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Load reads the archive file at path.
+func Load(path string) (*Archive, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	return Read(f)
+}
@@ -0,0 +1,192 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/latebit/demarkus/client/internal/fetch"
+	"github.com/latebit/demarkus/protocol"
+)
+
+// site is a fake server: directory listings, and the versions of each
+// document built the way the server store chains them.
+type site struct {
+	dirs map[string]string    // path → LIST body
+	docs map[string][]Version // path → versions, oldest first
+}
+
+func hexSum(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *site) addDoc(path string, bodies ...string) {
+	var versions []Version
+	prevEtag := ""
+	for i, body := range bodies {
+		n := i + 1
+		meta := map[string]string{"version": fmt.Sprint(n), "content-hash": "sha256-" + hexSum(body)}
+		raw := fmt.Sprintf("---\nversion: %d\narchived: false\n", n)
+		if prevEtag != "" {
+			meta["previous-hash"] = "sha256-" + prevEtag
+			raw += "previous-hash: sha256-" + prevEtag + "\n"
+		}
+		raw += "---\n" + body
+		meta["etag"] = hexSum(raw)
+		prevEtag = meta["etag"]
+		versions = append(versions, Version{Number: n, Body: body, Metadata: meta})
+	}
+	s.docs[path] = versions
+}
+
+func ok(body string, meta map[string]string) fetch.Result {
+	return fetch.Result{Response: protocol.Response{Status: protocol.StatusOK, Body: body, Metadata: meta}}
+}
+
+func notFound() fetch.Result {
+	return fetch.Result{Response: protocol.Response{Status: protocol.StatusNotFound, Body: "# Not Found\n"}}
+}
+
+func (s *site) List(_, path string) (fetch.Result, error) {
+	body, found := s.dirs[path]
+	if !found {
+		return notFound(), nil
+	}
+	return ok(body, map[string]string{}), nil
+}
+
+func (s *site) Versions(_, path string) (fetch.Result, error) {
+	versions, found := s.docs[path]
+	if !found {
+		return notFound(), nil
+	}
+	var b strings.Builder
+	for i := len(versions); i >= 1; i-- {
+		fmt.Fprintf(&b, "- [v%d](%s/v%d)\n", i, path, i)
+	}
+	return ok(b.String(), map[string]string{"current": fmt.Sprint(len(versions)), "chain-valid": "true"}), nil
+}
+
+func (s *site) Fetch(_, path string) (fetch.Result, error) {
+	i := strings.LastIndex(path, "/v")
+	var n int
+	if _, err := fmt.Sscanf(path[i:], "/v%d", &n); err != nil {
+		return notFound(), nil
+	}
+	versions := s.docs[path[:i]]
+	if n < 1 || n > len(versions) {
+		return notFound(), nil
+	}
+	return ok(versions[n-1].Body, versions[n-1].Metadata), nil
+}
+
+func newSite() *site {
+	s := &site{
+		dirs: map[string]string{
+			"/":      "# Index of /\n\n- [index.md](index.md)\n- [docs/](docs/)\n- [ghost.md](ghost.md)\n",
+			"/docs/": "# Index of /docs/\n\n- [guide.md](guide.md)\n",
+		},
+		docs: map[string][]Version{},
+	}
+	s.addDoc("/index.md", "# Home\n", "# Home\n\nNow with [docs](docs/guide.md).\n")
+	s.addDoc("/docs/guide.md", "# Guide\n")
+	return s
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	var seen []string
+	a, err := Snapshot(context.Background(), newSite(), "h:6309", "/", func(p string) { seen = append(seen, p) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.Documents) != 2 || a.Documents[0].Path != "/docs/guide.md" || a.Documents[1].Path != "/index.md" {
+		t.Fatalf("documents = %+v", a.Documents)
+	}
+	if got := len(a.Documents[1].Versions); got != 2 {
+		t.Errorf("/index.md versions = %d, want 2", got)
+	}
+	if len(a.Skipped) != 1 || a.Skipped[0].Path != "/ghost.md" {
+		t.Errorf("skipped = %+v, want /ghost.md", a.Skipped)
+	}
+	if len(seen) != 3 {
+		t.Errorf("progress saw %v", seen)
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, a); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := Verify(loaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() {
+		t.Errorf("fresh archive failed verification: %+v", report)
+	}
+	if loaded.Documents[1].ChainValid != "true" || loaded.Host != "h:6309" {
+		t.Errorf("loaded archive lost fields: %+v", loaded)
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	a, err := Snapshot(context.Background(), newSite(), "h:6309", "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, a); err != nil {
+		t.Fatal(err)
+	}
+
+	// Editing a body breaks both the digest and the document's chain.
+	a.Documents[1].Versions[0].Body = "# Hacked\n"
+	report, err := Verify(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.DigestOK || !report.Documents[0].OK() || report.Documents[1].OK() {
+		t.Errorf("tampered body: %+v", report)
+	}
+
+	// Recomputing the digest after the edit still leaves the chain broken.
+	buf.Reset()
+	if err := Write(&buf, a); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report, _ := Verify(loaded); !report.DigestOK || report.OK() {
+		t.Errorf("re-digested tampered archive: %+v", report)
+	}
+
+	// Dropping a document is caught by the digest.
+	a, _ = Snapshot(context.Background(), newSite(), "h:6309", "/", nil)
+	if err := Write(&bytes.Buffer{}, a); err != nil {
+		t.Fatal(err)
+	}
+	a.Documents = a.Documents[1:]
+	if report, _ := Verify(a); report.DigestOK {
+		t.Error("dropped document not detected")
+	}
+}
+
+func TestReadRejectsUnknownFormat(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, &Archive{Format: Format + 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Read(&buf); err == nil {
+		t.Error("expected error for a newer format")
+	}
+}
@@ -0,0 +1,134 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/latebit/demarkus/client/internal/fetch"
+)
+
+// Client is the subset of *fetch.Client a snapshot uses.
+type Client interface {
+	Fetch(host, path string) (fetch.Result, error)
+	List(host, path string) (fetch.Result, error)
+	Versions(host, path string) (fetch.Result, error)
+}
+
+// Snapshot archives every document under the directory root on host,
+// walking its listings. Documents that cannot be archived in full, such as
+// ones the server refuses or whose history it will not list, are recorded
+// as skipped rather than failing the snapshot; so are directories whose
+// listing the server truncated. progress, if set, is called with each
+// document path before it is archived.
+func Snapshot(ctx context.Context, c Client, host, root string, progress func(path string)) (*Archive, error) {
+	if !strings.HasSuffix(root, "/") {
+		root += "/"
+	}
+	a := &Archive{Format: Format, Host: host, Root: root, Created: time.Now().UTC()}
+
+	paths, skipped, err := walk(ctx, c, host, root)
+	if err != nil {
+		return nil, err
+	}
+	a.Skipped = skipped
+
+	for _, p := range paths {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if progress != nil {
+			progress(p)
+		}
+		doc, reason, err := snapshotDocument(c, host, p)
+		if err != nil {
+			return nil, err
+		}
+		if reason != "" {
+			a.Skipped = append(a.Skipped, Skipped{Path: p, Reason: reason})
+			continue
+		}
+		a.Documents = append(a.Documents, doc)
+	}
+	slices.SortFunc(a.Skipped, func(x, y Skipped) int { return strings.Compare(x.Path, y.Path) })
+	return a, nil
+}
+
+// walk returns the paths of the documents under dir, sorted, and the
+// directories it could not list in full.
+func walk(ctx context.Context, c Client, host, dir string) ([]string, []Skipped, error) {
+	var paths []string
+	var skipped []Skipped
+	queue := []string{dir}
+	for len(queue) > 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		d := queue[0]
+		queue = queue[1:]
+
+		r, err := c.List(host, d)
+		if err != nil {
+			return nil, nil, fmt.Errorf("list %s: %w", d, err)
+		}
+		if err := r.Err(); err != nil {
+			skipped = append(skipped, Skipped{Path: d, Reason: err.Error()})
+			continue
+		}
+		listing, err := fetch.ParseDirListing(r.Response)
+		if err != nil {
+			skipped = append(skipped, Skipped{Path: d, Reason: err.Error()})
+			continue
+		}
+		if listing.Truncated {
+			skipped = append(skipped, Skipped{Path: d, Reason: "listing truncated by the server; some entries are missing"})
+		}
+		for _, e := range listing.Entries {
+			if e.IsDir {
+				queue = append(queue, d+e.Name+"/")
+			} else {
+				paths = append(paths, d+e.Name)
+			}
+		}
+	}
+	slices.Sort(paths)
+	return paths, skipped, nil
+}
+
+// snapshotDocument fetches every version of the document at path. A
+// document the server will not serve in full is reported by reason; err is
+// for failures to talk to the server at all.
+func snapshotDocument(c Client, host, path string) (doc Document, reason string, err error) {
+	r, err := c.Versions(host, path)
+	if err != nil {
+		return Document{}, "", fmt.Errorf("versions %s: %w", path, err)
+	}
+	if err := r.Err(); err != nil {
+		return Document{}, "versions: " + err.Error(), nil
+	}
+	history, err := fetch.ParseVersionHistory(r.Response)
+	if err != nil {
+		return Document{}, err.Error(), nil
+	}
+	if history.Current < 1 {
+		return Document{}, "server reported no versions", nil
+	}
+
+	doc = Document{Path: path}
+	if history.ChainKnown {
+		doc.ChainValid = fmt.Sprint(history.ChainValid)
+	}
+	for n := 1; n <= history.Current; n++ {
+		r, err := c.Fetch(host, fmt.Sprintf("%s/v%d", path, n))
+		if err != nil {
+			return Document{}, "", fmt.Errorf("fetch %s/v%d: %w", path, n, err)
+		}
+		if err := r.Err(); err != nil {
+			return Document{}, fmt.Sprintf("v%d: %v", n, err), nil
+		}
+		doc.Versions = append(doc.Versions, Version{Number: n, Body: r.Response.Body, Metadata: r.Response.Metadata})
+	}
+	return doc, "", nil
+}
@@ -0,0 +1,60 @@
+package archive
+
+import (
+	"github.com/latebit/demarkus/client/internal/verify"
+)
+
+// DocumentResult is the outcome of verifying one archived document.
+type DocumentResult struct {
+	Path     string
+	Versions []verify.Result
+}
+
+// OK reports whether every version of the document passed.
+func (r DocumentResult) OK() bool {
+	for _, v := range r.Versions {
+		if !v.OK() {
+			return false
+		}
+	}
+	return true
+}
+
+// Report is the outcome of verifying an archive.
+type Report struct {
+	// DigestOK reports whether the archive's documents still match its
+	// digest. A mismatch means the file was altered after it was written.
+	DigestOK  bool
+	Documents []DocumentResult
+}
+
+// OK reports whether the archive passed every check.
+func (r Report) OK() bool {
+	if !r.DigestOK {
+		return false
+	}
+	for _, d := range r.Documents {
+		if !d.OK() {
+			return false
+		}
+	}
+	return true
+}
+
+// Verify checks an archive offline: its digest, and the hash chain of each
+// document's versions.
+func Verify(a *Archive) (Report, error) {
+	digest, err := Digest(a.Documents)
+	if err != nil {
+		return Report{}, err
+	}
+	report := Report{DigestOK: digest == a.Digest}
+	for _, doc := range a.Documents {
+		versions := make([]verify.Version, len(doc.Versions))
+		for i, v := range doc.Versions {
+			versions[i] = verify.Version{Number: v.Number, Body: v.Body, Metadata: v.Metadata}
+		}
+		report.Documents = append(report.Documents, DocumentResult{Path: doc.Path, Versions: verify.Chain(versions)})
+	}
+	return report, nil
+}
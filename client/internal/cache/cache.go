@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/BurntSushi/toml"
+	"github.com/latebit/demarkus/client/internal/urlnorm"
 	"github.com/latebit/demarkus/protocol"
 )
 
@@ -53,6 +54,16 @@ func New(dir string) *Cache {
 	return &Cache{Dir: dir}
 }
 
+// Empty reports whether nothing has ever been cached in the directory, as
+// on a client's first run.
+func (c *Cache) Empty() bool {
+	entries, err := os.ReadDir(c.Dir)
+	if err != nil {
+		return os.IsNotExist(err)
+	}
+	return len(entries) == 0
+}
+
 // Put writes a response to the cache atomically.
 // Writes metadata first (which is smaller), then body. This ensures
 // if we crash, we don't have orphaned body files without metadata.
@@ -76,7 +87,7 @@ func (c *Cache) Put(host, path, verb string, resp protocol.Response) error {
 	}
 
 	m := meta{
-		URL:      protocol.ALPN + "://" + host + path,
+		URL:      urlnorm.Normalize(protocol.ALPN + "://" + host + path),
 		Verb:     verb,
 		Status:   resp.Status,
 		CachedAt: time.Now().UTC(),
@@ -145,6 +156,34 @@ func (c *Cache) Get(host, path, verb string) (*Entry, error) {
 	}, nil
 }
 
+// Each calls fn for every cached response to verb, with the mark:// URL it
+// was fetched from. Unreadable entries are skipped. Iteration stops at the
+// first error fn returns.
+func (c *Cache) Each(verb string, fn func(url string, e Entry) error) error {
+	sentinel := "." + strings.ToLower(verb)
+	err := filepath.WalkDir(c.Dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || d.Name() != sentinel+".meta" {
+			return nil
+		}
+		var m meta
+		if _, err := toml.DecodeFile(path, &m); err != nil || m.Verb != verb {
+			return nil
+		}
+		body, err := os.ReadFile(strings.TrimSuffix(path, ".meta"))
+		if err != nil {
+			return nil
+		}
+		return fn(m.URL, Entry{
+			Response: protocol.Response{Status: m.Status, Metadata: m.Metadata, Body: string(body)},
+			CachedAt: m.CachedAt,
+		})
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
 // filePath returns the cache file path for a given host, request path, and verb.
 //
 // Each path gets its own directory with verb-specific sentinel files inside,
@@ -155,7 +194,9 @@ func (c *Cache) Get(host, path, verb string) (*Entry, error) {
 //	cache/host/.fetch               ← FETCH /
 //	cache/host/.list                ← LIST  /
 func (c *Cache) filePath(host, reqPath, verb string) string {
-	safeHost := strings.ReplaceAll(host, "..", "_")
+	// Normalize the host so that Example.org and example.org:6309 share
+	// entries; filepath.Clean below does the same for the path.
+	safeHost := strings.ReplaceAll(urlnorm.Host(host), "..", "_")
 	safeHost = strings.ReplaceAll(safeHost, string(filepath.Separator), "_")
 
 	cleaned := filepath.Clean(reqPath)
@@ -57,6 +57,23 @@ func TestCacheMiss(t *testing.T) {
 	}
 }
 
+func TestEmpty(t *testing.T) {
+	dir := t.TempDir()
+	if !New(filepath.Join(dir, "missing")).Empty() {
+		t.Error("missing directory: not empty")
+	}
+	c := New(dir)
+	if !c.Empty() {
+		t.Error("new directory: not empty")
+	}
+	if err := c.Put("localhost:6309", "/index.md", protocol.VerbFetch, protocol.Response{Status: protocol.StatusOK, Body: "# Home\n"}); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if c.Empty() {
+		t.Error("after Put: empty")
+	}
+}
+
 func TestListAndFetchSeparate(t *testing.T) {
 	c := New(t.TempDir())
 
@@ -310,3 +327,83 @@ func TestNestedPath(t *testing.T) {
 		t.Errorf("version: got %q, want %q", entry.Response.Metadata["version"], "2")
 	}
 }
+
+func TestEach(t *testing.T) {
+	c := New(t.TempDir())
+	ok := protocol.Response{Status: protocol.StatusOK, Body: "# Doc\n"}
+	for _, p := range []string{"/a.md", "/docs/b.md"} {
+		if err := c.Put("h:6309", p, protocol.VerbFetch, ok); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Put("h:6309", "/docs/", protocol.VerbList, ok); err != nil {
+		t.Fatal(err)
+	}
+
+	var urls []string
+	err := c.Each(protocol.VerbFetch, func(url string, e Entry) error {
+		if e.Response.Body != "# Doc\n" {
+			t.Errorf("%s body = %q", url, e.Response.Body)
+		}
+		urls = append(urls, url)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Each: %v", err)
+	}
+	if len(urls) != 2 || urls[0] != "mark://h:6309/a.md" || urls[1] != "mark://h:6309/docs/b.md" {
+		t.Errorf("urls = %v", urls)
+	}
+
+	if err := New(filepath.Join(t.TempDir(), "missing")).Each(protocol.VerbFetch, func(string, Entry) error {
+		t.Error("callback on empty cache")
+		return nil
+	}); err != nil {
+		t.Errorf("Each on missing dir: %v", err)
+	}
+}
+
+func TestStats(t *testing.T) {
+	c := New(t.TempDir())
+	if s, err := c.Stats(); err != nil || len(s) != 0 {
+		t.Fatalf("Stats before any session = %v, %v", s, err)
+	}
+
+	if err := c.AddStats(map[string]HostStats{"a:6309": {Hits: 3, Misses: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddStats(map[string]HostStats{"a:6309": {Hits: 1}, "b:6309": {Misses: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	s, err := c.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s["a:6309"] != (HostStats{Hits: 4, Misses: 1}) || s["b:6309"] != (HostStats{Misses: 2}) {
+		t.Errorf("Stats = %v", s)
+	}
+	if got := s["a:6309"].HitRatio(); got != 0.8 {
+		t.Errorf("HitRatio = %v, want 0.8", got)
+	}
+	if got := (HostStats{}).HitRatio(); got != 0 {
+		t.Errorf("HitRatio with no requests = %v, want 0", got)
+	}
+
+	// The stats file is not a cached response.
+	if err := c.Each(protocol.VerbFetch, func(url string, _ Entry) error {
+		t.Errorf("Each visited %s", url)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.ResetStats(); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := c.Stats(); err != nil || len(s) != 0 {
+		t.Errorf("Stats after reset = %v, %v", s, err)
+	}
+	if err := c.ResetStats(); err != nil {
+		t.Errorf("second reset: %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+)
+
+// statsFile holds the hit and miss counts of past sessions, in the cache
+// directory. Its leading dot keeps it apart from the host directories.
+const statsFile = ".stats.toml"
+
+// HostStats counts how the requests to a host that go through the cache
+// were answered. A hit is a not-modified response, answered from the
+// cached copy; a miss is a response carrying the document, because there
+// was no cached copy or it had changed.
+type HostStats struct {
+	Hits   int `toml:"hits"`
+	Misses int `toml:"misses"`
+}
+
+// HitRatio returns the share of requests answered from the cache, from 0
+// to 1, or 0 if there were none.
+func (s HostStats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// Stats returns the counts recorded by AddStats, keyed by host. Returns an
+// empty map if none were.
+func (c *Cache) Stats() (map[string]HostStats, error) {
+	stats := make(map[string]HostStats)
+	if _, err := toml.DecodeFile(filepath.Join(c.Dir, statsFile), &stats); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// AddStats adds the counts of a session to the recorded ones. Sessions
+// ending at the same moment may lose each other's counts; the statistics
+// are a guide, not an audit.
+func (c *Cache) AddStats(session map[string]HostStats) error {
+	if len(session) == 0 {
+		return nil
+	}
+	stats, err := c.Stats()
+	if err != nil {
+		stats = make(map[string]HostStats) // start over from an unreadable file
+	}
+	for host, s := range session {
+		total := stats[host]
+		total.Hits += s.Hits
+		total.Misses += s.Misses
+		stats[host] = total
+	}
+	return c.writeStats(stats)
+}
+
+// ResetStats forgets the recorded counts.
+func (c *Cache) ResetStats() error {
+	err := os.Remove(filepath.Join(c.Dir, statsFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// writeStats replaces the stats file atomically.
+func (c *Cache) writeStats(stats map[string]HostStats) error {
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(stats); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(c.Dir, statsFile+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(buf.Bytes())
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(c.Dir, statsFile))
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
@@ -0,0 +1,156 @@
+// Package diff compares documents line by line.
+package diff
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Op is the kind of an edit.
+type Op int
+
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+// Edit is one line of a line-by-line comparison.
+type Edit struct {
+	Op   Op
+	Line string
+}
+
+// Lines returns the shortest edit script turning a into b, using Myers'
+// algorithm. Deletions come before insertions within a change.
+func Lines(a, b []string) []Edit {
+	n, m := len(a), len(b)
+	maxD := n + m
+	off := maxD + 1
+	v := make([]int, 2*maxD+3)
+	var trace [][]int
+	var d int
+search:
+	for d = 0; d <= maxD; d++ {
+		trace = append(trace, slices.Clone(v))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	var edits []Edit
+	x, y := n, m
+	for ; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+			prevK = k + 1
+		}
+		prevX := v[off+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			edits = append(edits, Edit{Equal, a[x-1]})
+			x--
+			y--
+		}
+		if x == prevX {
+			edits = append(edits, Edit{Insert, b[y-1]})
+		} else {
+			edits = append(edits, Edit{Delete, a[x-1]})
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		edits = append(edits, Edit{Equal, a[x-1]})
+		x--
+		y--
+	}
+	slices.Reverse(edits)
+	return edits
+}
+
+// Unified returns the changes from a to b in unified diff format, with
+// context unchanged lines around each hunk. It returns "" when a and b
+// have the same lines.
+func Unified(a, b string, context int) string {
+	edits := Lines(splitLines(a), splitLines(b))
+
+	var sb strings.Builder
+	for i := 0; i < len(edits); {
+		if edits[i].Op == Equal {
+			i++
+			continue
+		}
+		// Extend the hunk while changes are closer than two contexts apart.
+		start := max(i-context, 0)
+		end := i
+		for j := i; j < len(edits); j++ {
+			if edits[j].Op != Equal {
+				end = j + 1
+			} else if j-end >= 2*context {
+				break
+			}
+		}
+		end = min(end+context, len(edits))
+
+		aLine, bLine := 1, 1
+		for _, e := range edits[:start] {
+			if e.Op != Insert {
+				aLine++
+			}
+			if e.Op != Delete {
+				bLine++
+			}
+		}
+		var aCount, bCount int
+		for _, e := range edits[start:end] {
+			if e.Op != Insert {
+				aCount++
+			}
+			if e.Op != Delete {
+				bCount++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(aLine, aCount), hunkRange(bLine, bCount))
+		for _, e := range edits[start:end] {
+			sb.WriteString([]string{" ", "+", "-"}[e.Op] + e.Line + "\n")
+		}
+		i = end
+	}
+	return sb.String()
+}
+
+// hunkRange formats a hunk's start and length; an empty range starts at
+// the line before it, as in diff -u.
+func hunkRange(line, count int) string {
+	if count == 0 {
+		line--
+	}
+	if count == 1 {
+		return fmt.Sprint(line)
+	}
+	return fmt.Sprintf("%d,%d", line, count)
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,58 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	a := []string{"a", "b", "c", "a", "b", "b", "a"}
+	b := []string{"c", "b", "a", "b", "a", "c"}
+	edits := Lines(a, b)
+
+	var gotA, gotB []string
+	changes := 0
+	for _, e := range edits {
+		if e.Op != Insert {
+			gotA = append(gotA, e.Line)
+		}
+		if e.Op != Delete {
+			gotB = append(gotB, e.Line)
+		}
+		if e.Op != Equal {
+			changes++
+		}
+	}
+	if strings.Join(gotA, "") != strings.Join(a, "") || strings.Join(gotB, "") != strings.Join(b, "") {
+		t.Fatalf("edits do not rebuild the inputs: %v", edits)
+	}
+	// The classic example has a shortest edit script of 5.
+	if changes != 5 {
+		t.Errorf("%d changes, want 5: %v", changes, edits)
+	}
+	if got := Lines(nil, nil); len(got) != 0 {
+		t.Errorf("Lines(nil, nil) = %v", got)
+	}
+}
+
+func TestUnified(t *testing.T) {
+	var lines []string
+	for i := range 20 {
+		lines = append(lines, string(rune('a'+i)))
+	}
+	old := strings.Join(lines, "\n") + "\n"
+	lines[1] = "B"
+	lines = append(lines[:15], lines[16:]...) // drop "p"
+	got := Unified(old, strings.Join(lines, "\n")+"\n", 2)
+	want := "@@ -1,4 +1,4 @@\n a\n-b\n+B\n c\n d\n" +
+		"@@ -14,5 +14,4 @@\n n\n o\n-p\n q\n r\n"
+	if got != want {
+		t.Errorf("Unified =\n%s\nwant\n%s", got, want)
+	}
+	if got := Unified(old, old, 3); got != "" {
+		t.Errorf("Unified of equal texts = %q", got)
+	}
+	if got := Unified("", "new\n", 3); got != "@@ -0,0 +1 @@\n+new\n" {
+		t.Errorf("Unified from empty = %q", got)
+	}
+}
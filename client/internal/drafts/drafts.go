@@ -0,0 +1,169 @@
+// Package drafts keeps in-progress document edits on disk so they survive
+// editor crashes and failed publishes.
+//
+// Drafts mirror the document URL under ~/.mark/drafts:
+//
+//	~/.mark/drafts/localhost:6309/notes/today.md  ← mark://localhost:6309/notes/today.md
+//
+// A draft exists from the moment an edit starts until the edited content is
+// published successfully (or discarded). The version of the document it
+// edits is kept beside it, in a hidden .base file, so that a draft resumed
+// after someone else has published can be told apart from a current one.
+package drafts
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Store manages drafts rooted at a directory.
+type Store struct {
+	dir string
+}
+
+// Draft is a saved, unpublished edit.
+type Draft struct {
+	Body     string
+	Modified time.Time
+
+	// BaseVersion is the version of the document the draft edits: 0 for
+	// a new document, or -1 if it was not recorded.
+	BaseVersion int
+}
+
+// DefaultDir returns the default drafts directory (~/.mark/drafts).
+func DefaultDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".mark", "drafts")
+}
+
+// New creates a draft store rooted at dir. Returns an error if dir is empty.
+func New(dir string) (*Store, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("drafts directory is empty (could not determine home directory)")
+	}
+	return &Store{dir: dir}, nil
+}
+
+// Path returns the file that holds the draft for host and document path.
+// The path is cleaned so it cannot escape the host directory.
+func (s *Store) Path(host, docPath string) (string, error) {
+	safeHost := strings.ReplaceAll(host, "..", "_")
+	safeHost = strings.ReplaceAll(safeHost, string(filepath.Separator), "_")
+
+	cleaned := strings.TrimLeft(filepath.Clean("/"+docPath), "/")
+	if cleaned == "" || strings.HasSuffix(docPath, "/") {
+		return "", fmt.Errorf("draft path %q is not a document", docPath)
+	}
+	return filepath.Join(s.dir, safeHost, cleaned), nil
+}
+
+// Load returns the draft for host and document path, or nil if none exists.
+func (s *Store) Load(host, docPath string) (*Draft, error) {
+	p, err := s.Path(host, docPath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("stat draft %q: %w", p, err)
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, fmt.Errorf("read draft %q: %w", p, err)
+	}
+	base := -1
+	if b, err := os.ReadFile(basePath(p)); err == nil {
+		if v, err := strconv.Atoi(strings.TrimSpace(string(b))); err == nil && v >= 0 {
+			base = v
+		}
+	}
+	return &Draft{Body: string(data), Modified: info.ModTime(), BaseVersion: base}, nil
+}
+
+// basePath returns the file that records the base version of the draft in
+// file p.
+func basePath(p string) string {
+	return filepath.Join(filepath.Dir(p), "."+filepath.Base(p)+".base")
+}
+
+// Save writes body as the draft for host and document path, editing
+// baseVersion of the document (0 for a new one, -1 if unknown), replacing
+// any existing draft atomically. Returns the draft file path.
+func (s *Store) Save(host, docPath, body string, baseVersion int) (string, error) {
+	p, err := s.Path(host, docPath)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+		return "", fmt.Errorf("create drafts directory: %w", err)
+	}
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, []byte(body), 0o600); err != nil {
+		return "", fmt.Errorf("write draft: %w", err)
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		return "", fmt.Errorf("write draft: %w", err)
+	}
+	if baseVersion < 0 {
+		if err := os.Remove(basePath(p)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("write draft: %w", err)
+		}
+		return p, nil
+	}
+	if err := os.WriteFile(basePath(p), []byte(strconv.Itoa(baseVersion)+"\n"), 0o600); err != nil {
+		return "", fmt.Errorf("write draft: %w", err)
+	}
+	return p, nil
+}
+
+// SetAside moves the draft for host and document path to a file beside
+// it named for its base version, such as today.md.v3, so that a fresh
+// draft can take its place. Returns the file it was moved to.
+func (s *Store) SetAside(host, docPath string) (string, error) {
+	d, err := s.Load(host, docPath)
+	if err != nil {
+		return "", err
+	}
+	if d == nil {
+		return "", fmt.Errorf("no draft for %s", docPath)
+	}
+	p, _ := s.Path(host, docPath)
+	aside := p + ".orig"
+	if d.BaseVersion >= 0 {
+		aside = fmt.Sprintf("%s.v%d", p, d.BaseVersion)
+	}
+	if err := os.Rename(p, aside); err != nil {
+		return "", fmt.Errorf("set draft aside: %w", err)
+	}
+	if err := os.Remove(basePath(p)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("set draft aside: %w", err)
+	}
+	return aside, nil
+}
+
+// Remove deletes the draft for host and document path. Removing a draft
+// that does not exist is not an error.
+func (s *Store) Remove(host, docPath string) error {
+	p, err := s.Path(host, docPath)
+	if err != nil {
+		return err
+	}
+	for _, f := range []string{p, basePath(p)} {
+		if err := os.Remove(f); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("remove draft: %w", err)
+		}
+	}
+	return nil
+}
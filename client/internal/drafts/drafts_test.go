@@ -0,0 +1,114 @@
+package drafts
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveLoadRemove(t *testing.T) {
+	s, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := s.Load("localhost:6309", "/notes/today.md")
+	if err != nil || d != nil {
+		t.Fatalf("Load before Save = %v, %v; want nil, nil", d, err)
+	}
+
+	p, err := s.Save("localhost:6309", "/notes/today.md", "# Draft\n", 3)
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if want := filepath.Join("localhost:6309", "notes", "today.md"); !strings.HasSuffix(p, want) {
+		t.Errorf("path = %q, want suffix %q", p, want)
+	}
+
+	d, err = s.Load("localhost:6309", "/notes/today.md")
+	if err != nil || d == nil {
+		t.Fatalf("Load after Save = %v, %v", d, err)
+	}
+	if d.Body != "# Draft\n" || d.BaseVersion != 3 {
+		t.Errorf("draft = %q based on v%d, want %q based on v3", d.Body, d.BaseVersion, "# Draft\n")
+	}
+	if d.Modified.IsZero() {
+		t.Error("expected modified time")
+	}
+
+	if err := s.Remove("localhost:6309", "/notes/today.md"); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if d, _ := s.Load("localhost:6309", "/notes/today.md"); d != nil {
+		t.Error("draft still present after Remove")
+	}
+	if _, err := os.Stat(basePath(p)); !os.IsNotExist(err) {
+		t.Errorf("base version still present after Remove: %v", err)
+	}
+	if err := s.Remove("localhost:6309", "/notes/today.md"); err != nil {
+		t.Errorf("Remove of missing draft: %v", err)
+	}
+}
+
+func TestSetAside(t *testing.T) {
+	s, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := s.Save("localhost:6309", "/doc.md", "stale edit", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aside, err := s.SetAside("localhost:6309", "/doc.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aside != p+".v2" {
+		t.Errorf("set aside at %q, want %q", aside, p+".v2")
+	}
+	if data, err := os.ReadFile(aside); err != nil || string(data) != "stale edit" {
+		t.Errorf("set-aside draft = %q, %v", data, err)
+	}
+	if d, _ := s.Load("localhost:6309", "/doc.md"); d != nil {
+		t.Error("draft still present after SetAside")
+	}
+
+	// A draft saved without a base version loads as unknown.
+	if _, err := s.Save("localhost:6309", "/doc.md", "edit", -1); err != nil {
+		t.Fatal(err)
+	}
+	if d, _ := s.Load("localhost:6309", "/doc.md"); d == nil || d.BaseVersion != -1 {
+		t.Errorf("draft without base = %+v, want BaseVersion -1", d)
+	}
+}
+
+func TestPathContainment(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := New(dir)
+
+	p, err := s.Path("host:6309", "/../../etc/passwd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(p, filepath.Join(dir, "host:6309")+string(filepath.Separator)) {
+		t.Errorf("path %q escapes the host directory", p)
+	}
+
+	p, _ = s.Path("../evil", "/doc.md")
+	if !strings.HasPrefix(p, dir+string(filepath.Separator)) || strings.Contains(p, "..") {
+		t.Errorf("host was not sanitized: %q", p)
+	}
+
+	for _, bad := range []string{"/", "", "/dir/"} {
+		if _, err := s.Path("host:6309", bad); err == nil {
+			t.Errorf("Path(%q) should fail", bad)
+		}
+	}
+}
+
+func TestNewEmptyDir(t *testing.T) {
+	if _, err := New(""); err == nil {
+		t.Error("expected error for empty dir")
+	}
+}
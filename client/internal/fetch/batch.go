@@ -0,0 +1,304 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/latebit/demarkus/client/internal/cache"
+	"github.com/latebit/demarkus/protocol"
+	"github.com/quic-go/quic-go"
+)
+
+// fetchManyWorkers is how many documents FetchMany fetches at once from a
+// server that does not accept BATCH.
+const fetchManyWorkers = 4
+
+// FetchMany retrieves the documents at paths on host and returns their
+// results in the same order. Servers that accept BATCH get them up to
+// protocol.MaxBatchSize to a stream; others get a FETCH each, a few at a
+// time. As with Fetch, requests are conditional on the cache and
+// prefetched documents are answered without a round trip.
+//
+// A server's version is only known once it has answered, so on a new
+// connection the first document is fetched on its own.
+//
+// FetchMany fails as a whole: on error, some documents were not fetched.
+func (c *Client) FetchMany(host string, paths []string) ([]Result, error) {
+	results := make([]Result, len(paths))
+	var pending []int
+	for i, path := range paths {
+		if r, ok := c.takePrefetched(host, path); ok {
+			results[i] = r
+		} else {
+			pending = append(pending, i)
+		}
+	}
+
+	if len(pending) > 0 && c.hostProto(host) == "" {
+		i := pending[0]
+		pending = pending[1:]
+		r, err := c.cachedRequest(host, paths[i], protocol.VerbFetch)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = r
+	}
+	if len(pending) == 0 {
+		return results, nil
+	}
+
+	if !protocol.SupportsBatch(c.hostProto(host)) {
+		if err := c.fetchEach(host, paths, pending, results); err != nil {
+			return nil, err
+		}
+		return results, nil
+	}
+	for chunk := range slices.Chunk(pending, protocol.MaxBatchSize) {
+		if err := c.fetchBatch(host, paths, chunk, results); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// hostProto returns the protocol version agreed on the pooled connection
+// to host, or "" if there is none or its server has not answered yet.
+func (c *Client) hostProto(host string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.protos[c.conns[host]]
+}
+
+// fetchEach fetches paths[i] for each i in indexes into results[i], with
+// a FETCH each, and returns the first error.
+func (c *Client) fetchEach(host string, paths []string, indexes []int, results []Result) error {
+	var (
+		once     sync.Once
+		firstErr error
+		wg       sync.WaitGroup
+		jobs     = make(chan int)
+	)
+	for range min(fetchManyWorkers, len(indexes)) {
+		wg.Go(func() {
+			for i := range jobs {
+				r, err := c.cachedRequest(host, paths[i], protocol.VerbFetch)
+				if err != nil {
+					once.Do(func() { firstErr = err })
+					continue
+				}
+				results[i] = r
+			}
+		})
+	}
+	for _, i := range indexes {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return firstErr
+}
+
+// fetchBatch fetches paths[i] for each i in indexes into results[i], with
+// one BATCH.
+func (c *Client) fetchBatch(host string, paths []string, indexes []int, results []Result) error {
+	reqs := make([]protocol.Request, len(indexes))
+	cached := make([]*cache.Entry, len(indexes))
+	for k, i := range indexes {
+		reqs[k], cached[k] = c.conditional(host, paths[i], protocol.VerbFetch)
+		if c.opts.AcceptLanguage != "" {
+			reqs[k].Metadata[protocol.MetaAcceptLanguage] = c.opts.AcceptLanguage
+		}
+	}
+	body, err := batchBody(reqs, c.hostProto(host), c.opts.Trailers)
+	if err != nil {
+		return err
+	}
+
+	outer, err := c.doWithRetry(host, func(ctx context.Context, conn *quic.Conn) (Result, error) {
+		return c.requestOnConn(ctx, conn, protocol.Request{Verb: protocol.VerbBatch, Path: "/", Body: body})
+	})
+	if err != nil {
+		return err
+	}
+	if outer.Response.Status != protocol.StatusOK {
+		return fmt.Errorf("batch: %s: %s", outer.Response.Status, strings.TrimSpace(outer.Response.Body))
+	}
+	responses, err := splitBatch(outer.Response.Body, len(indexes))
+	if err != nil {
+		return fmt.Errorf("batch: %w", err)
+	}
+	for k, i := range indexes {
+		if err := c.verify(host, paths[i], responses[k]); err != nil {
+			return err
+		}
+	}
+	for k, i := range indexes {
+		results[i] = c.settle(host, paths[i], protocol.VerbFetch, cached[k], Result{Response: responses[k]})
+	}
+	return nil
+}
+
+// PublishDoc is one document published by PublishAll.
+type PublishDoc struct {
+	Path            string
+	Body            string
+	ExpectedVersion int // as for Publish: -1 for no check
+	Meta            map[string]string
+}
+
+// PublishError reports the document that failed a PublishAll, with the
+// server's response to it. Nothing was published.
+type PublishError struct {
+	Index    int // into the documents
+	Path     string
+	Response protocol.Response
+}
+
+func (e *PublishError) Error() string {
+	return fmt.Sprintf("%s: %s: %s", e.Path, e.Response.Status, strings.TrimSpace(e.Response.Body))
+}
+
+// PublishAll publishes docs on host in one atomic BATCH: either every
+// document gets its new version or none does. It returns a result per
+// document, in order. If the server refused the batch because of one
+// document, the error is a *PublishError.
+func (c *Client) PublishAll(host string, docs []PublishDoc, token string) ([]Result, error) {
+	if len(docs) == 0 || len(docs) > protocol.MaxBatchSize {
+		return nil, fmt.Errorf("publish: want 1 to %d documents, got %d", protocol.MaxBatchSize, len(docs))
+	}
+	reqs := make([]protocol.Request, len(docs))
+	for k, d := range docs {
+		reqs[k] = protocol.Request{Verb: protocol.VerbPublish, Path: d.Path, Metadata: maps.Clone(d.Meta), Body: d.Body}
+		if reqs[k].Metadata == nil {
+			reqs[k].Metadata = make(map[string]string)
+		}
+		if token != "" {
+			reqs[k].Metadata["auth"] = token
+		}
+		if d.ExpectedVersion >= 0 {
+			reqs[k].Metadata["expected-version"] = strconv.Itoa(d.ExpectedVersion)
+		}
+	}
+	proto := c.hostProto(host)
+	if proto == "" {
+		proto = protocol.ProtocolVersion
+	}
+	body, err := batchBody(reqs, proto, false)
+	if err != nil {
+		return nil, err
+	}
+
+	outer, err := c.doWithRetry(host, func(ctx context.Context, conn *quic.Conn) (Result, error) {
+		return c.requestOnConn(ctx, conn, protocol.Request{
+			Verb:     protocol.VerbBatch,
+			Path:     "/",
+			Metadata: map[string]string{protocol.MetaAtomic: "true"},
+			Body:     body,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if id, ok := outer.Response.Metadata[protocol.MetaFailedRequest]; ok {
+		k, resp, err := failedRequest(outer.Response.Body, id, len(docs))
+		if err != nil {
+			return nil, fmt.Errorf("publish: %w", err)
+		}
+		return nil, &PublishError{Index: k, Path: docs[k].Path, Response: resp}
+	}
+	if outer.Response.Status != protocol.StatusOK {
+		return nil, fmt.Errorf("publish: %s: %s", outer.Response.Status, strings.TrimSpace(outer.Response.Body))
+	}
+	responses, err := splitBatch(outer.Response.Body, len(docs))
+	if err != nil {
+		return nil, fmt.Errorf("publish: %w", err)
+	}
+	results := make([]Result, len(docs))
+	for k, resp := range responses {
+		results[k] = Result{Response: resp}
+	}
+	return results, nil
+}
+
+// failedRequest parses the body of a response to an atomic BATCH of n
+// requests that request id failed, returning its index and response.
+func failedRequest(body, id string, n int) (int, protocol.Response, error) {
+	k, err := strconv.Atoi(id)
+	if err != nil || k < 0 || k >= n || strconv.Itoa(k) != id {
+		return 0, protocol.Response{}, fmt.Errorf("failure of unknown request %q", id)
+	}
+	records, err := protocol.ParseBatch(body)
+	if err != nil {
+		return 0, protocol.Response{}, err
+	}
+	if len(records) != 1 || records[0].ID != id {
+		return 0, protocol.Response{}, fmt.Errorf("want the record of request %s alone", id)
+	}
+	resp, err := protocol.ParseResponse(strings.NewReader(records[0].Message))
+	if err != nil {
+		return 0, protocol.Response{}, fmt.Errorf("request %s: %w", id, err)
+	}
+	return k, resp, nil
+}
+
+// batchBody returns the body of a BATCH carrying reqs, each in proto and
+// identified by its index. trailers asks for a trailer on each response.
+func batchBody(reqs []protocol.Request, proto string, trailers bool) (string, error) {
+	records := make([]protocol.BatchRecord, len(reqs))
+	for k, req := range reqs {
+		req.Proto = proto
+		if trailers {
+			req.Metadata = maps.Clone(req.Metadata)
+			if req.Metadata == nil {
+				req.Metadata = make(map[string]string)
+			}
+			req.Metadata["accept-trailers"] = "true"
+		}
+		var buf bytes.Buffer
+		if _, err := req.WriteTo(&buf); err != nil {
+			return "", err
+		}
+		records[k] = protocol.BatchRecord{ID: strconv.Itoa(k), Message: buf.String()}
+	}
+	return protocol.FormatBatch(records)
+}
+
+// splitBatch parses the body of a response to a BATCH of n requests
+// identified by their index, returning the responses in request order.
+// Each response's trailer is verified and its body decoded.
+func splitBatch(body string, n int) ([]protocol.Response, error) {
+	records, err := protocol.ParseBatch(body)
+	if err != nil {
+		return nil, err
+	}
+	responses := make([]protocol.Response, n)
+	answered := make([]bool, n)
+	for _, r := range records {
+		k, err := strconv.Atoi(r.ID)
+		if err != nil || k < 0 || k >= n || strconv.Itoa(k) != r.ID {
+			return nil, fmt.Errorf("response to unknown request %q", r.ID)
+		}
+		resp, err := protocol.ParseResponse(strings.NewReader(r.Message))
+		if err != nil {
+			return nil, fmt.Errorf("request %s: %w", r.ID, err)
+		}
+		if err := resp.VerifyTrailer(); err != nil {
+			return nil, fmt.Errorf("request %s: %w", r.ID, err)
+		}
+		if resp, err = resp.Decode(); err != nil {
+			return nil, fmt.Errorf("request %s: %w", r.ID, err)
+		}
+		responses[k] = resp
+		answered[k] = true
+	}
+	if k := slices.Index(answered, false); k >= 0 {
+		return nil, fmt.Errorf("no response to request %d", k)
+	}
+	return responses, nil
+}
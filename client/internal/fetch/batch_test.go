@@ -0,0 +1,99 @@
+package fetch
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/latebit/demarkus/protocol"
+)
+
+func TestBatchBody(t *testing.T) {
+	reqs := []protocol.Request{
+		{Verb: protocol.VerbFetch, Path: "/index.md", Metadata: map[string]string{}},
+		{Verb: protocol.VerbFetch, Path: "/about.md", Metadata: map[string]string{"if-none-match": "abc"}},
+	}
+	body, err := batchBody(reqs, "MARK/1.2", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := protocol.ParseBatch(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].ID != "0" || records[1].ID != "1" {
+		t.Fatalf("records = %+v", records)
+	}
+	for k, r := range records {
+		req, err := protocol.ParseRequest(strings.NewReader(r.Message))
+		if err != nil {
+			t.Fatalf("record %s: %v", r.ID, err)
+		}
+		if req.Path != reqs[k].Path || req.Proto != "MARK/1.2" || req.Metadata["accept-trailers"] != "true" {
+			t.Errorf("record %s = %+v", r.ID, req)
+		}
+	}
+	if !strings.Contains(records[1].Message, "if-none-match: abc") {
+		t.Errorf("conditional metadata lost: %q", records[1].Message)
+	}
+	if _, ok := reqs[0].Metadata["accept-trailers"]; ok {
+		t.Error("batchBody modified the caller's metadata")
+	}
+}
+
+func TestSplitBatch(t *testing.T) {
+	response := func(status, body string) string {
+		return "---\nstatus: " + status + "\n---\n" + body
+	}
+	body, err := protocol.FormatBatch([]protocol.BatchRecord{
+		{ID: "1", Message: response("not-found", "gone\n")},
+		{ID: "0", Message: response("ok", "# Home\n")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := splitBatch(body, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[0].Status != protocol.StatusOK || got[0].Body != "# Home\n" || got[1].Status != protocol.StatusNotFound {
+		t.Errorf("responses = %+v", got)
+	}
+
+	for name, records := range map[string][]protocol.BatchRecord{
+		"missing":      {{ID: "0", Message: response("ok", "")}},
+		"unknown":      {{ID: "0", Message: response("ok", "")}, {ID: "2", Message: response("ok", "")}},
+		"padded":       {{ID: "0", Message: response("ok", "")}, {ID: "01", Message: response("ok", "")}},
+		"unterminated": {{ID: "0", Message: response("ok", "")}, {ID: "1", Message: "---\nstatus: ok\n"}},
+	} {
+		body, err := protocol.FormatBatch(records)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := splitBatch(body, 2); err == nil {
+			t.Errorf("%s: splitBatch succeeded", name)
+		}
+	}
+}
+
+func TestFailedRequest(t *testing.T) {
+	record := func(id string) string {
+		body, err := protocol.FormatBatch([]protocol.BatchRecord{{ID: id, Message: "---\nstatus: conflict\nserver-version: 3\n---\n# Version Conflict\n"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return body
+	}
+	k, resp, err := failedRequest(record("1"), "1", 2)
+	if err != nil || k != 1 || resp.Status != protocol.StatusConflict || resp.Metadata["server-version"] != "3" {
+		t.Errorf("failedRequest = %d, %+v, %v", k, resp, err)
+	}
+	for _, tt := range []struct{ body, id string }{
+		{record("1"), "0"}, // not the record named
+		{record("2"), "2"}, // no such request
+		{record("1") + record("0"), "1"},
+	} {
+		if _, _, err := failedRequest(tt.body, tt.id, 2); err == nil {
+			t.Errorf("failedRequest(%q, %q) succeeded", tt.body, tt.id)
+		}
+	}
+}
@@ -3,7 +3,9 @@ package fetch
 
 import (
 	"context"
+	"crypto/ed25519"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"log"
 	"maps"
@@ -14,11 +16,14 @@ import (
 	"time"
 
 	"github.com/latebit/demarkus/client/internal/cache"
+	"github.com/latebit/demarkus/client/internal/urlnorm"
 	"github.com/latebit/demarkus/protocol"
 	"github.com/quic-go/quic-go"
 )
 
-// ParseMarkURL parses a mark:// URL and returns the host (with default port) and path.
+// ParseMarkURL parses a mark:// URL and returns its host and path in the
+// canonical form urlnorm gives them: the host lowercased with the default
+// port added, and the path without duplicate slashes or dot segments.
 func ParseMarkURL(raw string) (host, path string, err error) {
 	u, err := url.Parse(raw)
 	if err != nil {
@@ -27,21 +32,24 @@ func ParseMarkURL(raw string) (host, path string, err error) {
 	if u.Scheme != "mark" {
 		return "", "", fmt.Errorf("unsupported scheme: %s (expected mark://)", u.Scheme)
 	}
-	host = u.Host
-	if u.Port() == "" {
-		host = fmt.Sprintf("%s:%d", u.Hostname(), protocol.DefaultPort)
-	}
-	path = u.Path
-	if path == "" {
-		path = "/"
-	}
-	return host, path, nil
+	return urlnorm.Host(u.Host), urlnorm.Path(u.Path), nil
 }
 
 // Result holds a response and metadata about how it was served.
 type Result struct {
 	Response  protocol.Response
 	FromCache bool
+	CachedAt  time.Time // when the cached copy was stored; zero unless FromCache
+
+	// Previous is the cached copy this response replaced, when its body
+	// differs; nil otherwise.
+	Previous *cache.Entry
+
+	// URL is where Fetch got the response after following redirects, and
+	// Redirects the URLs it was redirected from, oldest first. Both are
+	// empty when no redirect was followed.
+	URL       string
+	Redirects []string
 }
 
 // Options configures client behavior.
@@ -50,6 +58,60 @@ type Options struct {
 	Insecure       bool
 	DialTimeout    time.Duration
 	RequestTimeout time.Duration
+
+	// MaxRetryAfter is the longest retry-after the client waits out on a
+	// rate-limited response. Longer waits return the response instead.
+	MaxRetryAfter time.Duration
+
+	// OnRateLimited, if set, is called before the client waits to retry a
+	// rate-limited request. It may be called from any goroutine.
+	OnRateLimited func(host string, wait time.Duration)
+
+	// Trailers asks servers for a response trailer and fails responses
+	// whose body does not match the trailer's body hash.
+	Trailers bool
+
+	// SkipCache, if set, reports hosts whose documents are neither
+	// answered from the cache nor stored in it.
+	SkipCache func(host string) bool
+
+	// PinnedKey, if set, returns the public key documents from host must
+	// be signed with, or nil to check only their content-sha256.
+	PinnedKey func(host string) ed25519.PublicKey
+
+	// MaxRedirects is how many moved responses Fetch follows before
+	// failing. 0 follows none: the moved response is returned, for the
+	// caller to follow.
+	MaxRedirects int
+
+	// CrossHostRedirects lets Fetch follow moved responses to other
+	// hosts. Without it, Fetch returns a moved response to another host,
+	// with the URL it came from, so the caller can ask the user first.
+	CrossHostRedirects bool
+
+	// OperationTimeout, if set, bounds each operation as a whole: dialing,
+	// sending its request, and every retry, including waits for a
+	// rate-limited server. An operation that runs out of it fails with
+	// ErrOperationTimeout. 0 leaves only DialTimeout and RequestTimeout,
+	// which bound each attempt.
+	OperationTimeout time.Duration
+
+	// OnAttempt, if set, is called before each attempt of an operation
+	// on host, numbered from 1, with the deadline of the operation, or the
+	// zero time without OperationTimeout. It may be called from any
+	// goroutine.
+	OnAttempt func(host string, attempt int, deadline time.Time)
+
+	// AcceptLanguage, if set, is sent as the accept-language of every
+	// read, such as "de, en;q=0.5", so servers answer with the variant
+	// of each document in the first of those languages they have.
+	AcceptLanguage string
+
+	// KeepAlive, if set, is how often QUIC sends keep-alive packets on
+	// the client's connections, so that a long-lived client does not pay
+	// a new handshake after every pause longer than the idle timeout.
+	// DefaultKeepAlive suits servers' default idle timeout.
+	KeepAlive time.Duration
 }
 
 func (o *Options) applyDefaults() {
@@ -59,6 +121,9 @@ func (o *Options) applyDefaults() {
 	if o.RequestTimeout == 0 {
 		o.RequestTimeout = 10 * time.Second
 	}
+	if o.MaxRetryAfter == 0 {
+		o.MaxRetryAfter = 30 * time.Second
+	}
 }
 
 // Client manages QUIC connections and performs Mark Protocol operations.
@@ -67,19 +132,40 @@ type Client struct {
 	tlsConf *tls.Config
 	mu      sync.Mutex
 	conns   map[string]*quic.Conn
+
+	// prefetched holds Prefetch results not yet returned by Fetch, keyed
+	// by host and path. Guarded by mu.
+	prefetched map[string]prefetched
+
+	// health holds the connection counters behind Health, keyed by host.
+	// Guarded by mu.
+	health map[string]*hostHealth
+
+	// cacheStats counts cache hits and misses this session, keyed by
+	// host. Guarded by mu.
+	cacheStats map[string]cache.HostStats
+
+	// protos holds the protocol version each connection's server has
+	// agreed to, from its server-protocol metadata. Guarded by mu.
+	protos map[*quic.Conn]string
 }
 
 // NewClient creates a new client with the given options.
 func NewClient(opts Options) *Client {
 	opts.applyDefaults()
-	return &Client{
+	c := &Client{
 		opts: opts,
 		tlsConf: &tls.Config{
 			InsecureSkipVerify: opts.Insecure,
 			NextProtos:         []string{protocol.ALPN},
 		},
-		conns: make(map[string]*quic.Conn),
+		conns:      make(map[string]*quic.Conn),
+		prefetched: make(map[string]prefetched),
+		health:     make(map[string]*hostHealth),
+		cacheStats: make(map[string]cache.HostStats),
+		protos:     make(map[*quic.Conn]string),
 	}
+	return c
 }
 
 // Close closes all pooled connections.
@@ -89,11 +175,31 @@ func (c *Client) Close() {
 	for host, conn := range c.conns {
 		_ = conn.CloseWithError(0, "")
 		delete(c.conns, host)
+		delete(c.protos, conn)
 	}
+	if c.opts.Cache != nil {
+		if err := c.opts.Cache.AddStats(c.cacheStats); err != nil {
+			log.Printf("[WARN] cache stats: %v", err)
+		}
+	}
+	clear(c.cacheStats)
 }
 
-// Fetch retrieves a document from a Mark Protocol server.
+// Fetch retrieves a document from a Mark Protocol server, following up to
+// MaxRedirects moved responses.
 func (c *Client) Fetch(host, path string) (Result, error) {
+	result, err := c.fetchOne(host, path)
+	if err != nil || c.opts.MaxRedirects == 0 || result.Response.Status != protocol.StatusMoved {
+		return result, err
+	}
+	return c.followRedirects(host, path, result)
+}
+
+// fetchOne retrieves a document without following redirects.
+func (c *Client) fetchOne(host, path string) (Result, error) {
+	if r, ok := c.takePrefetched(host, path); ok {
+		return r, nil
+	}
 	return c.cachedRequest(host, path, protocol.VerbFetch)
 }
 
@@ -102,11 +208,119 @@ func (c *Client) List(host, path string) (Result, error) {
 	return c.cachedRequest(host, path, protocol.VerbList)
 }
 
+// ListEntries retrieves a directory listing as data, asking the server
+// for format: structured. A server that does not support it returns the
+// markdown listing; ParseDirListing reads either. The listing is not cached.
+func (c *Client) ListEntries(host, path string) (Result, error) {
+	return c.ListPage(host, path, ListOptions{Structured: true})
+}
+
+// ListOptions selects the part of a directory listing to retrieve.
+type ListOptions struct {
+	Offset     int    // entries to skip
+	Limit      int    // most entries to return; 0 for the server's maximum
+	Sort       string // protocol.SortName (the default) or protocol.SortModified
+	Structured bool   // ask for format: structured
+}
+
+// ListPage retrieves the page of a directory listing opts selects. The
+// response's next-offset, if any, is the Offset of the next page. Pages
+// are not cached.
+func (c *Client) ListPage(host, path string, opts ListOptions) (Result, error) {
+	req := protocol.Request{Verb: protocol.VerbList, Path: path, Metadata: make(map[string]string)}
+	if opts.Offset > 0 {
+		req.Metadata["offset"] = strconv.Itoa(opts.Offset)
+	}
+	if opts.Limit > 0 {
+		req.Metadata["limit"] = strconv.Itoa(opts.Limit)
+	}
+	if opts.Sort != "" {
+		req.Metadata["sort"] = opts.Sort
+	}
+	if opts.Structured {
+		req.Metadata["format"] = protocol.FormatStructured
+	}
+	return c.doWithRetry(host, func(ctx context.Context, conn *quic.Conn) (Result, error) {
+		return c.requestOnConn(ctx, conn, req)
+	})
+}
+
 // Versions retrieves the version history of a document.
 func (c *Client) Versions(host, path string) (Result, error) {
 	req := protocol.Request{Verb: protocol.VerbVersions, Path: path, Metadata: make(map[string]string)}
-	return c.doWithRetry(host, func(conn *quic.Conn) (Result, error) {
-		return c.requestOnConn(conn, req)
+	return c.doWithRetry(host, func(ctx context.Context, conn *quic.Conn) (Result, error) {
+		return c.requestOnConn(ctx, conn, req)
+	})
+}
+
+// TokenInfo asks the server what token grants: its label, operations,
+// path patterns and expiry, in the token-* metadata of the response.
+func (c *Client) TokenInfo(host, token string) (Result, error) {
+	req := protocol.Request{Verb: protocol.VerbFetch, Path: protocol.WellKnownTokenPath, Metadata: map[string]string{"auth": token}}
+	return c.doWithRetry(host, func(ctx context.Context, conn *quic.Conn) (Result, error) {
+		return c.requestOnConn(ctx, conn, req)
+	})
+}
+
+// Info retrieves a document's metadata without its body: etag, version,
+// modified, size and archived, for checking a large document for changes
+// without transferring it. It bypasses the cache, which holds bodies.
+func (c *Client) Info(host, path string) (Result, error) {
+	req := protocol.Request{Verb: protocol.VerbInfo, Path: path, Metadata: make(map[string]string)}
+	return c.doWithRetry(host, func(ctx context.Context, conn *quic.Conn) (Result, error) {
+		return c.requestOnConn(ctx, conn, req)
+	})
+}
+
+// Diff retrieves the changes between two versions of a document as a
+// unified diff. path names the range, as in /doc.md/v2..v4.
+func (c *Client) Diff(host, path string) (Result, error) {
+	req := protocol.Request{Verb: protocol.VerbDiff, Path: path, Metadata: make(map[string]string)}
+	return c.doWithRetry(host, func(ctx context.Context, conn *quic.Conn) (Result, error) {
+		return c.requestOnConn(ctx, conn, req)
+	})
+}
+
+// Search asks the server for the documents under the directory path that
+// match query, best first. limit <= 0 leaves the number of results to the
+// server. If token is non-empty, it is sent as the auth metadata so that
+// read-protected documents it covers are included.
+func (c *Client) Search(host, path, query, token string, limit int) (Result, error) {
+	req := protocol.Request{Verb: protocol.VerbSearch, Path: path, Metadata: map[string]string{"query": query}}
+	if limit > 0 {
+		req.Metadata["limit"] = strconv.Itoa(limit)
+	}
+	if token != "" {
+		req.Metadata["auth"] = token
+	}
+	return c.doWithRetry(host, func(ctx context.Context, conn *quic.Conn) (Result, error) {
+		return c.requestOnConn(ctx, conn, req)
+	})
+}
+
+// Backlinks asks the server for the documents that link to the document at
+// path. If token is non-empty, it is sent as the auth metadata so that
+// read-protected documents it covers are included.
+func (c *Client) Backlinks(host, path, token string) (Result, error) {
+	req := protocol.Request{Verb: protocol.VerbBacklinks, Path: path, Metadata: make(map[string]string)}
+	if token != "" {
+		req.Metadata["auth"] = token
+	}
+	return c.doWithRetry(host, func(ctx context.Context, conn *quic.Conn) (Result, error) {
+		return c.requestOnConn(ctx, conn, req)
+	})
+}
+
+// Job asks how the PUBLISH of path that the server accepted as job id
+// went: the response is accepted while the write is pending, and then the
+// one the PUBLISH would have had. The token must grant publish on path.
+func (c *Client) Job(host, path, id, token string) (Result, error) {
+	req := protocol.Request{Verb: protocol.VerbJob, Path: path, Metadata: map[string]string{protocol.MetaJob: id}}
+	if token != "" {
+		req.Metadata["auth"] = token
+	}
+	return c.doWithRetry(host, func(ctx context.Context, conn *quic.Conn) (Result, error) {
+		return c.requestOnConn(ctx, conn, req)
 	})
 }
 
@@ -125,8 +339,8 @@ func (c *Client) Publish(host, path, body, token string, expectedVersion int, me
 	if expectedVersion >= 0 {
 		req.Metadata["expected-version"] = strconv.Itoa(expectedVersion)
 	}
-	return c.doWithRetry(host, func(conn *quic.Conn) (Result, error) {
-		return c.requestOnConn(conn, req)
+	return c.doWithRetry(host, func(ctx context.Context, conn *quic.Conn) (Result, error) {
+		return c.requestOnConn(ctx, conn, req)
 	})
 }
 
@@ -146,8 +360,8 @@ func (c *Client) Append(host, path, body, token string, expectedVersion int, met
 		req.Metadata["auth"] = token
 	}
 	req.Metadata["expected-version"] = strconv.Itoa(expectedVersion)
-	return c.doWithRetry(host, func(conn *quic.Conn) (Result, error) {
-		return c.requestOnConn(conn, req)
+	return c.doWithRetry(host, func(ctx context.Context, conn *quic.Conn) (Result, error) {
+		return c.requestOnConn(ctx, conn, req)
 	})
 }
 
@@ -157,97 +371,257 @@ func (c *Client) Archive(host, path, token string) (Result, error) {
 	if token != "" {
 		req.Metadata["auth"] = token
 	}
-	return c.doWithRetry(host, func(conn *quic.Conn) (Result, error) {
-		return c.requestOnConn(conn, req)
+	return c.doWithRetry(host, func(ctx context.Context, conn *quic.Conn) (Result, error) {
+		return c.requestOnConn(ctx, conn, req)
+	})
+}
+
+// Purge deletes a document and its version history on a Mark Protocol
+// server. The token must grant the delete operation.
+func (c *Client) Purge(host, path, token string) (Result, error) {
+	req := protocol.Request{Verb: protocol.VerbPurge, Path: path, Metadata: make(map[string]string)}
+	if token != "" {
+		req.Metadata["auth"] = token
+	}
+	return c.doWithRetry(host, func(ctx context.Context, conn *quic.Conn) (Result, error) {
+		return c.requestOnConn(ctx, conn, req)
+	})
+}
+
+// Move renames a document on a Mark Protocol server, keeping its version
+// history. With redirect, the server keeps the old path as an alias of
+// the new one.
+func (c *Client) Move(host, path, dest, token string, redirect bool) (Result, error) {
+	req := protocol.Request{Verb: protocol.VerbMove, Path: path, Metadata: map[string]string{"destination": dest}}
+	if token != "" {
+		req.Metadata["auth"] = token
+	}
+	if redirect {
+		req.Metadata["redirect"] = "true"
+	}
+	return c.doWithRetry(host, func(ctx context.Context, conn *quic.Conn) (Result, error) {
+		return c.requestOnConn(ctx, conn, req)
+	})
+}
+
+// Experimental sends an experimental verb (see protocol.IsExperimentalVerb)
+// with meta and body, for prototyping extensions. Servers that do not
+// implement the verb respond not-implemented.
+func (c *Client) Experimental(host, verb, path, body, token string, meta map[string]string) (Result, error) {
+	if !protocol.IsExperimentalVerb(verb) {
+		return Result{}, fmt.Errorf("%q is not an experimental verb", verb)
+	}
+	req := protocol.Request{Verb: verb, Path: path, Metadata: make(map[string]string), Body: body}
+	maps.Copy(req.Metadata, meta)
+	if token != "" {
+		req.Metadata["auth"] = token
+	}
+	return c.doWithRetry(host, func(ctx context.Context, conn *quic.Conn) (Result, error) {
+		return c.requestOnConn(ctx, conn, req)
 	})
 }
 
 // cachedRequest handles FETCH and LIST with conditional caching.
 func (c *Client) cachedRequest(host, path, verb string) (Result, error) {
-	return c.doWithRetry(host, func(conn *quic.Conn) (Result, error) {
-		req := protocol.Request{Verb: verb, Path: path, Metadata: make(map[string]string)}
-
-		var cached *cache.Entry
-		if c.opts.Cache != nil {
-			cached, _ = c.opts.Cache.Get(host, path, verb)
-			if cached != nil {
-				if etag := cached.Response.Metadata["etag"]; etag != "" {
-					req.Metadata["if-none-match"] = etag
-				}
-				if mod := cached.Response.Metadata["modified"]; mod != "" {
-					req.Metadata["if-modified-since"] = mod
-				}
-			}
-		}
+	return c.doWithRetry(host, func(ctx context.Context, conn *quic.Conn) (Result, error) {
+		return c.conditionalRequest(ctx, conn, host, path, verb)
+	})
+}
 
-		result, err := c.requestOnConn(conn, req)
-		if err != nil {
-			return Result{}, err
-		}
+// conditionalRequest sends a request made conditional on the cached copy,
+// if any, and keeps the cache up to date with the response.
+func (c *Client) conditionalRequest(ctx context.Context, conn *quic.Conn, host, path, verb string) (Result, error) {
+	req, cached := c.conditional(host, path, verb)
+	result, err := c.requestOnConn(ctx, conn, req)
+	if err != nil {
+		return Result{}, err
+	}
+	if err := c.verify(host, path, result.Response); err != nil {
+		return Result{}, err
+	}
+	return c.settle(host, path, verb, cached, result), nil
+}
 
-		if result.Response.Status == protocol.StatusNotModified && cached != nil && cached.Response.Status == protocol.StatusOK {
-			return Result{Response: cached.Response, FromCache: true}, nil
+// conditional returns a request made conditional on the cached copy, if
+// any, and that copy.
+func (c *Client) conditional(host, path, verb string) (protocol.Request, *cache.Entry) {
+	req := protocol.Request{Verb: verb, Path: path, Metadata: make(map[string]string)}
+	if !c.caching(host) {
+		return req, nil
+	}
+	cached, _ := c.opts.Cache.Get(host, path, verb)
+	if cached != nil {
+		if etag := cached.Response.Metadata["etag"]; etag != "" {
+			req.Metadata["if-none-match"] = etag
 		}
-
-		if c.opts.Cache != nil && result.Response.Status == protocol.StatusOK {
-			if err := c.opts.Cache.Put(host, path, verb, result.Response); err != nil {
-				log.Printf("[WARN] cache write: %v", err)
+		// Servers reject malformed timestamps, so only echo a valid one.
+		if mod := cached.Response.Metadata["modified"]; mod != "" {
+			if _, err := time.Parse(time.RFC3339, mod); err == nil {
+				req.Metadata["if-modified-since"] = mod
 			}
 		}
+	}
+	return req, cached
+}
+
+// settle returns the result of a conditional request: the cached copy for
+// not-modified, or the response, which it caches.
+func (c *Client) settle(host, path, verb string, cached *cache.Entry, result Result) Result {
+	if result.Response.Status == protocol.StatusNotModified && cached != nil && cached.Response.Status == protocol.StatusOK {
+		c.recordCache(host, true)
+		return Result{Response: cached.Response, FromCache: true, CachedAt: cached.CachedAt}
+	}
+	if c.caching(host) && result.Response.Status == protocol.StatusOK {
+		c.recordCache(host, false)
+	}
+
+	if cached != nil && cached.Response.Status == protocol.StatusOK && result.Response.Status == protocol.StatusOK &&
+		cached.Response.Body != result.Response.Body {
+		result.Previous = cached
+	}
+	if c.caching(host) && result.Response.Status == protocol.StatusOK {
+		if err := c.opts.Cache.Put(host, path, verb, result.Response); err != nil {
+			log.Printf("[WARN] cache write: %v", err)
+		}
+	}
+	return result
+}
 
-		return result, nil
-	})
+// caching reports whether documents from host go through the cache.
+func (c *Client) caching(host string) bool {
+	return c.opts.Cache != nil && (c.opts.SkipCache == nil || !c.opts.SkipCache(host))
 }
 
 // requestOnConn opens a stream, sends a request, and reads the response.
-func (c *Client) requestOnConn(conn *quic.Conn, req protocol.Request) (Result, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), c.opts.RequestTimeout)
+// The stream is bounded by ctx's deadline, if it has one.
+func (c *Client) requestOnConn(ctx context.Context, conn *quic.Conn, req protocol.Request) (Result, error) {
+	openCtx, cancel := context.WithTimeout(ctx, c.opts.RequestTimeout)
 	defer cancel()
 
-	stream, err := conn.OpenStreamSync(ctx)
+	stream, err := conn.OpenStreamSync(openCtx)
 	if err != nil {
 		return Result{}, fmt.Errorf("open stream: %w", err)
 	}
 	defer func() { _ = stream.Close() }()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = stream.SetDeadline(deadline)
+	}
 
+	if req.Metadata == nil {
+		req.Metadata = make(map[string]string)
+	}
+	req.Proto = c.connProto(conn)
+	if c.opts.Trailers {
+		req.Metadata["accept-trailers"] = "true"
+	}
+	// Writes carry no large response, and servers that predate the keys
+	// would store them as publisher metadata. A write names its request
+	// only if the caller sets request-id.
+	if req.Verb != protocol.VerbPublish && req.Verb != protocol.VerbAppend {
+		req.Metadata["accept-encoding"] = protocol.EncodingGzip
+		if c.opts.AcceptLanguage != "" {
+			req.Metadata[protocol.MetaAcceptLanguage] = c.opts.AcceptLanguage
+		}
+		if req.Metadata[protocol.MetaRequestID] == "" {
+			req.Metadata[protocol.MetaRequestID] = protocol.NewRequestID()
+		}
+	}
 	if _, err := req.WriteTo(stream); err != nil {
 		return Result{}, fmt.Errorf("send request: %w", err)
 	}
 	_ = stream.Close()
 
+	// Failures from here on may be in the server's log, under the
+	// request's id.
+	readErr := "read response"
+	if id := req.Metadata[protocol.MetaRequestID]; id != "" {
+		readErr += " (request-id " + id + ")"
+	}
 	resp, err := protocol.ParseResponse(stream)
 	if err != nil {
-		return Result{}, fmt.Errorf("read response: %w", err)
+		return Result{}, fmt.Errorf("%s: %w", readErr, err)
+	}
+	c.learnProto(conn, resp)
+	if err := resp.VerifyTrailer(); err != nil {
+		return Result{}, fmt.Errorf("%s: %w", readErr, err)
+	}
+	if resp, err = resp.Decode(); err != nil {
+		return Result{}, fmt.Errorf("%s: %w", readErr, err)
 	}
 
 	return Result{Response: resp}, nil
 }
 
-// doWithRetry retries transient failures up to 5 times with a fixed 100ms delay.
-func (c *Client) doWithRetry(host string, fn func(conn *quic.Conn) (Result, error)) (Result, error) {
+// RetryAfter returns how long a rate-limited response asks the client to
+// wait. ok is false for other responses.
+func RetryAfter(resp protocol.Response) (wait time.Duration, ok bool) {
+	if resp.Status != protocol.StatusRateLimited {
+		return 0, false
+	}
+	secs, err := strconv.Atoi(resp.Metadata["retry-after"])
+	if err != nil || secs < 1 {
+		secs = 1
+	}
+	return time.Duration(secs) * time.Second, true
+}
+
+// doWithRetry retries transient failures up to 5 times with a fixed 100ms
+// delay. Rate-limited responses are retried after the server's retry-after
+// hint, up to MaxRetryAfter; the last one is returned if they persist.
+// With OperationTimeout, the attempts share its budget: a wait that would
+// outlast it is not made, and running out of it fails with
+// ErrOperationTimeout.
+func (c *Client) doWithRetry(host string, fn func(ctx context.Context, conn *quic.Conn) (Result, error)) (Result, error) {
 	const maxRetries = 5
 	const retryDelay = 100 * time.Millisecond
 
+	ctx, cancel := c.operation()
+	defer cancel()
 	var lastErr error
 	for attempt := range maxRetries {
-		conn, err := c.getConn(host)
+		if c.opts.OnAttempt != nil {
+			deadline, _ := ctx.Deadline()
+			c.opts.OnAttempt(host, attempt+1, deadline)
+		}
+		conn, err := c.getConn(ctx, host)
 		if err != nil {
+			if ctx.Err() != nil {
+				return Result{}, c.timedOut(host, err)
+			}
 			if attempt < maxRetries-1 && isTransientError(err) {
-				time.Sleep(retryDelay)
+				c.recordHealth(host, func(h *hostHealth) { h.Retries++ })
+				if !pause(ctx, retryDelay) {
+					return Result{}, c.timedOut(host, err)
+				}
 				c.removeConn(host)
 				continue
 			}
 			return Result{}, err
 		}
 
-		result, err := fn(conn)
+		result, err := c.tracked(host, func() (Result, error) { return fn(ctx, conn) })
 		if err == nil {
-			return result, nil
+			wait, limited := RetryAfter(result.Response)
+			if !limited || attempt == maxRetries-1 || wait > c.opts.MaxRetryAfter || !fits(ctx, wait) {
+				return result, nil
+			}
+			if c.opts.OnRateLimited != nil {
+				c.opts.OnRateLimited(host, wait)
+			}
+			c.recordHealth(host, func(h *hostHealth) { h.Retries++ })
+			pause(ctx, wait)
+			continue
 		}
 
 		lastErr = err
+		if ctx.Err() != nil {
+			return Result{}, c.timedOut(host, err)
+		}
 		if attempt < maxRetries-1 && isTransientError(err) {
-			time.Sleep(retryDelay)
+			c.recordHealth(host, func(h *hostHealth) { h.Retries++ })
+			if !pause(ctx, retryDelay) {
+				return Result{}, c.timedOut(host, err)
+			}
 			c.removeConn(host)
 			continue
 		}
@@ -258,20 +632,59 @@ func (c *Client) doWithRetry(host string, fn func(conn *quic.Conn) (Result, erro
 	return Result{}, lastErr
 }
 
-func (c *Client) getConn(host string) (*quic.Conn, error) {
+// ErrOperationTimeout is wrapped by the error of an operation that ran
+// out of its OperationTimeout.
+var ErrOperationTimeout = errors.New("operation timed out")
+
+// operation returns the context of one operation: its dial, requests and
+// retries. It has a deadline only when OperationTimeout is set.
+func (c *Client) operation() (context.Context, context.CancelFunc) {
+	if c.opts.OperationTimeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), c.opts.OperationTimeout)
+}
+
+// timedOut returns the error of an operation on host that ran out of its
+// OperationTimeout, last failing with err.
+func (c *Client) timedOut(host string, err error) error {
+	return fmt.Errorf("%w: no answer from %s within %v (%v)", ErrOperationTimeout, host, c.opts.OperationTimeout, err)
+}
+
+// fits reports whether waiting d leaves ctx time to spare.
+func fits(ctx context.Context, d time.Duration) bool {
+	deadline, ok := ctx.Deadline()
+	return !ok || time.Until(deadline) > d
+}
+
+// pause waits for d, and reports whether it did before ctx was done.
+func pause(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// getConn returns the pooled connection to host, dialing one if there is
+// none, within DialTimeout and ctx.
+func (c *Client) getConn(ctx context.Context, host string) (*quic.Conn, error) {
 	c.mu.Lock()
 	conn, ok := c.conns[host]
+	if ok && conn.Context().Err() == nil {
+		c.mu.Unlock()
+		return conn, nil
+	}
 	c.mu.Unlock()
 
 	if ok {
-		if conn.Context().Err() != nil {
-			c.removeConn(host)
-		} else {
-			return conn, nil
-		}
+		c.removeConn(host)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), c.opts.DialTimeout)
+	ctx, cancel := context.WithTimeout(ctx, c.opts.DialTimeout)
 	defer cancel()
 
 	// Clone TLS config and set ServerName for certificate validation.
@@ -282,24 +695,54 @@ func (c *Client) getConn(host string) (*quic.Conn, error) {
 		tlsConf.ServerName = host
 	}
 
-	conn, err := quic.DialAddr(ctx, host, tlsConf, nil)
+	conn, err := quic.DialAddr(ctx, host, tlsConf, c.quicConfig())
 	if err != nil {
+		c.recordHealth(host, func(h *hostHealth) { h.Failures++ })
 		return nil, fmt.Errorf("dial %s: %w", host, err)
 	}
 
 	c.mu.Lock()
 	c.conns[host] = conn
 	c.mu.Unlock()
+	c.recordHealth(host, func(h *hostHealth) { h.Dials++ })
 
 	return conn, nil
 }
 
 func (c *Client) removeConn(host string) {
 	c.mu.Lock()
+	delete(c.protos, c.conns[host])
 	delete(c.conns, host)
 	c.mu.Unlock()
 }
 
+// connProto returns the protocol version to name in requests on conn: the
+// one its server agreed to, or "" until it has answered with
+// server-protocol, since a MARK/1.0 server would take the version for
+// part of the path.
+func (c *Client) connProto(conn *quic.Conn) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.protos[conn]
+}
+
+// learnProto records the version the server on conn speaks, from a
+// response's server-protocol metadata.
+func (c *Client) learnProto(conn *quic.Conn, resp protocol.Response) {
+	serverProto := resp.Metadata["server-protocol"]
+	if serverProto == "" {
+		return
+	}
+	proto := protocol.NegotiateProtocol(protocol.ProtocolVersion, serverProto)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if proto == "" {
+		delete(c.protos, conn)
+		return
+	}
+	c.protos[conn] = proto
+}
+
 func isTransientError(err error) bool {
 	if err == nil {
 		return false
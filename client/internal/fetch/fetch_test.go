@@ -0,0 +1,183 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/latebit/demarkus/client/internal/cache"
+	"github.com/latebit/demarkus/protocol"
+	"github.com/quic-go/quic-go"
+)
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name     string
+		resp     protocol.Response
+		wantWait time.Duration
+		wantOK   bool
+	}{
+		{
+			name:     "rate limited with hint",
+			resp:     protocol.Response{Status: protocol.StatusRateLimited, Metadata: map[string]string{"retry-after": "5"}},
+			wantWait: 5 * time.Second,
+			wantOK:   true,
+		},
+		{
+			name:     "rate limited without hint",
+			resp:     protocol.Response{Status: protocol.StatusRateLimited, Metadata: map[string]string{}},
+			wantWait: time.Second,
+			wantOK:   true,
+		},
+		{
+			name:     "rate limited with bad hint",
+			resp:     protocol.Response{Status: protocol.StatusRateLimited, Metadata: map[string]string{"retry-after": "-3"}},
+			wantWait: time.Second,
+			wantOK:   true,
+		},
+		{
+			name: "ok",
+			resp: protocol.Response{Status: protocol.StatusOK, Metadata: map[string]string{"retry-after": "5"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wait, ok := RetryAfter(tt.resp)
+			if wait != tt.wantWait || ok != tt.wantOK {
+				t.Errorf("RetryAfter() = %v, %v; want %v, %v", wait, ok, tt.wantWait, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestLearnProto(t *testing.T) {
+	c := NewClient(Options{})
+	conn := new(quic.Conn)
+	if got := c.connProto(conn); got != "" {
+		t.Fatalf("before any response: %q, want none", got)
+	}
+
+	c.learnProto(conn, protocol.Response{Status: protocol.StatusOK, Metadata: map[string]string{}})
+	if got := c.connProto(conn); got != "" {
+		t.Errorf("server without server-protocol: %q, want none", got)
+	}
+	c.learnProto(conn, protocol.Response{Status: protocol.StatusOK, Metadata: map[string]string{"server-protocol": "MARK/1.0"}})
+	if got := c.connProto(conn); got != "MARK/1.0" {
+		t.Errorf("MARK/1.0 server: %q", got)
+	}
+	c.learnProto(conn, protocol.Response{Status: protocol.StatusOK, Metadata: map[string]string{"server-protocol": "MARK/1.99"}})
+	if got := c.connProto(conn); got != protocol.ProtocolVersion {
+		t.Errorf("newer server: %q, want %q", got, protocol.ProtocolVersion)
+	}
+	c.learnProto(conn, protocol.Response{Status: protocol.StatusOK, Metadata: map[string]string{"server-protocol": "MARK/2.0"}})
+	if got := c.connProto(conn); got != "" {
+		t.Errorf("server with another major version: %q, want none", got)
+	}
+}
+
+func TestCacheStats(t *testing.T) {
+	store := cache.New(t.TempDir())
+	c := NewClient(Options{Cache: store, SkipCache: func(host string) bool { return host == "fresh:6309" }})
+	ok := protocol.Response{Status: protocol.StatusOK, Metadata: map[string]string{"etag": "abc"}, Body: "# Doc\n"}
+	notModified := protocol.Response{Status: protocol.StatusNotModified, Metadata: map[string]string{}}
+
+	c.settle("h:6309", "/doc.md", protocol.VerbFetch, nil, Result{Response: ok})
+	_, cached := c.conditional("h:6309", "/doc.md", protocol.VerbFetch)
+	c.settle("h:6309", "/doc.md", protocol.VerbFetch, cached, Result{Response: notModified})
+	c.settle("h:6309", "/doc.md", protocol.VerbFetch, cached, Result{Response: notModified})
+	c.settle("h:6309", "/gone.md", protocol.VerbFetch, nil, Result{Response: protocol.Response{Status: protocol.StatusNotFound}})
+	c.settle("fresh:6309", "/doc.md", protocol.VerbFetch, nil, Result{Response: ok})
+
+	want := map[string]cache.HostStats{"h:6309": {Hits: 2, Misses: 1}}
+	if got := c.CacheStats(); !maps.Equal(got, want) {
+		t.Errorf("CacheStats = %v, want %v", got, want)
+	}
+
+	c.Close()
+	recorded, err := store.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !maps.Equal(recorded, want) {
+		t.Errorf("recorded stats = %v, want %v", recorded, want)
+	}
+	if got := c.CacheStats(); len(got) != 0 {
+		t.Errorf("CacheStats after Close = %v, want none", got)
+	}
+}
+
+func TestSkipCache(t *testing.T) {
+	store := cache.New(t.TempDir())
+	c := NewClient(Options{Cache: store, SkipCache: func(host string) bool { return host == "fresh:6309" }})
+	ok := protocol.Response{Status: protocol.StatusOK, Metadata: map[string]string{"etag": "abc"}, Body: "# Doc\n"}
+
+	for _, host := range []string{"fresh:6309", "cached:6309"} {
+		if err := store.Put(host, "/doc.md", protocol.VerbFetch, ok); err != nil {
+			t.Fatal(err)
+		}
+		req, cached := c.conditional(host, "/doc.md", protocol.VerbFetch)
+		skipped := host == "fresh:6309"
+		if (cached == nil) != skipped || (req.Metadata["if-none-match"] == "") != skipped {
+			t.Errorf("%s: cached %v, if-none-match %q", host, cached != nil, req.Metadata["if-none-match"])
+		}
+	}
+
+	changed := ok
+	changed.Body = "# Changed\n"
+	c.settle("fresh:6309", "/doc.md", protocol.VerbFetch, nil, Result{Response: changed})
+	if e, _ := store.Get("fresh:6309", "/doc.md", protocol.VerbFetch); e == nil || e.Response.Body != ok.Body {
+		t.Error("response from a skipped host was cached")
+	}
+	if got := c.Prefetch(context.Background(), "fresh:6309", []string{"mark://fresh:6309/a.md"}, PrefetchOptions{}); got != nil {
+		t.Errorf("Prefetch from a skipped host = %v, want nil", got)
+	}
+}
+
+func TestOperationTimeout(t *testing.T) {
+	// A socket that never answers: each dial waits out DialTimeout.
+	silent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("udp unavailable: %v", err)
+	}
+	defer func() { _ = silent.Close() }()
+
+	var attempts []time.Time
+	c := NewClient(Options{
+		Insecure:         true,
+		DialTimeout:      5 * time.Second,
+		OperationTimeout: 300 * time.Millisecond,
+		OnAttempt: func(host string, attempt int, deadline time.Time) {
+			if attempt != len(attempts)+1 {
+				t.Errorf("attempt %d after %d", attempt, len(attempts))
+			}
+			attempts = append(attempts, deadline)
+		},
+	})
+	defer c.Close()
+
+	start := time.Now()
+	_, err = c.Fetch(silent.LocalAddr().String(), "/doc.md")
+	if !errors.Is(err, ErrOperationTimeout) {
+		t.Fatalf("err = %v, want ErrOperationTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("took %v, want about 300ms", elapsed)
+	}
+	if len(attempts) == 0 || attempts[0].IsZero() || attempts[0].After(start.Add(time.Second)) {
+		t.Errorf("attempt deadlines = %v", attempts)
+	}
+}
+
+func TestFits(t *testing.T) {
+	if !fits(context.Background(), time.Hour) {
+		t.Error("an unbounded operation has time for any wait")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if !fits(ctx, 100*time.Millisecond) || fits(ctx, 2*time.Second) {
+		t.Error("fits ignores the deadline")
+	}
+}
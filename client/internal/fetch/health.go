@@ -0,0 +1,93 @@
+package fetch
+
+import (
+	"maps"
+	"time"
+
+	"github.com/latebit/demarkus/client/internal/cache"
+)
+
+// Health is a snapshot of the connection counters the client keeps for a
+// host, for showing connection quality. Counts cover the client's life.
+type Health struct {
+	Dials         int           // connections opened
+	Requests      int           // requests that got a response, of any status
+	Retries       int           // attempts repeated after a transient error or rate limit
+	Failures      int           // dials and requests that failed with an error
+	ActiveStreams int           // requests in flight now
+	MeanLatency   time.Duration // mean time from opening a stream to the full response
+}
+
+// hostHealth is the running state behind a host's Health.
+type hostHealth struct {
+	Health
+	latency time.Duration // total over Requests
+}
+
+// Health returns the connection counters of every host the client has
+// talked to, keyed by host.
+func (c *Client) Health() map[string]Health {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make(map[string]Health, len(c.health))
+	for host, h := range c.health {
+		snap := h.Health
+		if h.Requests > 0 {
+			snap.MeanLatency = h.latency / time.Duration(h.Requests)
+		}
+		out[host] = snap
+	}
+	return out
+}
+
+// recordHealth applies update to host's counters.
+func (c *Client) recordHealth(host string, update func(h *hostHealth)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	h := c.health[host]
+	if h == nil {
+		h = &hostHealth{}
+		c.health[host] = h
+	}
+	update(h)
+}
+
+// tracked runs fn, one request to host, counting it in the host's health.
+func (c *Client) tracked(host string, fn func() (Result, error)) (Result, error) {
+	c.recordHealth(host, func(h *hostHealth) { h.ActiveStreams++ })
+	start := time.Now()
+	r, err := fn()
+	elapsed := time.Since(start)
+	c.recordHealth(host, func(h *hostHealth) {
+		h.ActiveStreams--
+		if err != nil {
+			h.Failures++
+			return
+		}
+		h.Requests++
+		h.latency += elapsed
+	})
+	return r, err
+}
+
+// CacheStats returns the cache hits and misses of every host this session,
+// keyed by host. Close adds them to the counts kept in the cache.
+func (c *Client) CacheStats() map[string]cache.HostStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return maps.Clone(c.cacheStats)
+}
+
+// recordCache counts a response from host to a cached request as a hit
+// or a miss.
+func (c *Client) recordCache(host string, hit bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.cacheStats[host]
+	if hit {
+		s.Hits++
+	} else {
+		s.Misses++
+	}
+	c.cacheStats[host] = s
+}
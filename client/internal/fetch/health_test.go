@@ -0,0 +1,49 @@
+package fetch
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/latebit/demarkus/protocol"
+)
+
+func TestHealth(t *testing.T) {
+	c := NewClient(Options{})
+	if got := c.Health(); len(got) != 0 {
+		t.Fatalf("new client health = %v, want empty", got)
+	}
+
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		_, _ = c.tracked("h", func() (Result, error) {
+			<-release
+			return Result{}, nil
+		})
+		close(done)
+	}()
+	for c.Health()["h"].ActiveStreams != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	<-done
+
+	_, _ = c.tracked("h", func() (Result, error) {
+		time.Sleep(10 * time.Millisecond)
+		return Result{Response: protocol.Response{Status: protocol.StatusNotFound}}, nil
+	})
+	_, _ = c.tracked("h", func() (Result, error) { return Result{}, errors.New("stream reset") })
+	c.recordHealth("h", func(h *hostHealth) { h.Dials++ })
+
+	got := c.Health()["h"]
+	if got.Requests != 2 || got.Failures != 1 || got.Dials != 1 || got.ActiveStreams != 0 {
+		t.Errorf("health = %+v, want 2 requests, 1 failure, 1 dial, none active", got)
+	}
+	if got.MeanLatency < 5*time.Millisecond {
+		t.Errorf("mean latency = %v, want the requests' mean", got.MeanLatency)
+	}
+	if _, ok := c.Health()["other"]; ok {
+		t.Error("health reported for a host never contacted")
+	}
+}
@@ -0,0 +1,20 @@
+package fetch
+
+import (
+	"time"
+
+	"github.com/quic-go/quic-go"
+)
+
+// DefaultKeepAlive is a KeepAlive well inside the 30-second idle timeout
+// that servers and the client default to.
+const DefaultKeepAlive = 20 * time.Second
+
+// quicConfig returns the QUIC configuration the client dials with: nil
+// for quic-go's defaults unless KeepAlive is set.
+func (c *Client) quicConfig() *quic.Config {
+	if c.opts.KeepAlive <= 0 {
+		return nil
+	}
+	return &quic.Config{KeepAlivePeriod: c.opts.KeepAlive}
+}
@@ -0,0 +1,16 @@
+package fetch
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQUICConfigKeepAlive(t *testing.T) {
+	if conf := NewClient(Options{}).quicConfig(); conf != nil {
+		t.Errorf("quicConfig without KeepAlive = %+v, want nil", conf)
+	}
+	conf := NewClient(Options{KeepAlive: time.Second}).quicConfig()
+	if conf == nil || conf.KeepAlivePeriod != time.Second {
+		t.Errorf("quicConfig = %+v, want KeepAlivePeriod 1s", conf)
+	}
+}
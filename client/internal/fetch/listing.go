@@ -0,0 +1,104 @@
+package fetch
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/latebit/demarkus/protocol"
+)
+
+// DirEntry is one entry of a LIST response. Size, Modified and Version
+// are only known from a structured listing, and only for documents.
+type DirEntry struct {
+	Name     string // unescaped name, without the trailing slash for directories
+	IsDir    bool
+	Size     int
+	Modified time.Time
+	Version  int
+}
+
+// DirListing is the parsed form of a LIST response.
+type DirListing struct {
+	Entries    []DirEntry
+	Truncated  bool // the server stopped listing at its entry limit
+	NextOffset int  // the offset of the next page, when the server gave one
+	Metadata   map[string]string
+}
+
+// truncatedMarker is the line the server appends when a directory has more
+// entries than it will list.
+const truncatedMarker = "*...truncated, too many entries*"
+
+// ParseDirListing parses a LIST response (or the generated index served
+// for a FETCH of a directory without index.md), markdown or structured.
+// In markdown, entry names are taken from the link target, which is
+// URL-escaped and so unambiguous.
+func ParseDirListing(resp protocol.Response) (DirListing, error) {
+	if resp.Status != protocol.StatusOK {
+		return DirListing{}, fmt.Errorf("list: %s", resp.Status)
+	}
+	l := DirListing{Metadata: resp.Metadata}
+	if next, err := strconv.Atoi(resp.Metadata["next-offset"]); err == nil && next > 0 {
+		l.NextOffset, l.Truncated = next, true
+	}
+	if resp.Metadata["content-type"] == protocol.ListingContentType {
+		return parseStructuredListing(l, resp.Body)
+	}
+	for line := range strings.SplitSeq(resp.Body, "\n") {
+		line = strings.TrimSpace(line)
+		if line == truncatedMarker {
+			l.Truncated = true
+			continue
+		}
+		if !strings.HasPrefix(line, "- [") || !strings.HasSuffix(line, ")") {
+			continue
+		}
+		i := strings.LastIndex(line, "](")
+		if i < 0 {
+			return DirListing{}, fmt.Errorf("list: malformed entry %q", line)
+		}
+		entry, err := parseDirLink(line[i+2 : len(line)-1])
+		if err != nil {
+			return DirListing{}, fmt.Errorf("list: %w in %q", err, line)
+		}
+		l.Entries = append(l.Entries, entry)
+	}
+	return l, nil
+}
+
+func parseDirLink(link string) (DirEntry, error) {
+	escaped, isDir := strings.CutSuffix(link, "/")
+	name, err := url.PathUnescape(escaped)
+	if err != nil {
+		return DirEntry{}, fmt.Errorf("invalid link %q", link)
+	}
+	if name == "" || strings.Contains(name, "/") {
+		return DirEntry{}, fmt.Errorf("invalid entry name %q", name)
+	}
+	return DirEntry{Name: name, IsDir: isDir}, nil
+}
+
+// parseStructuredListing fills l from the body of a structured listing.
+// The listing is truncated when it has fewer entries than total.
+func parseStructuredListing(l DirListing, body string) (DirListing, error) {
+	entries, err := protocol.ParseListing(body)
+	if err != nil {
+		return DirListing{}, fmt.Errorf("list: %w", err)
+	}
+	for _, e := range entries {
+		l.Entries = append(l.Entries, DirEntry{
+			Name:     e.Name,
+			IsDir:    e.Type == protocol.EntryDirectory,
+			Size:     e.Size,
+			Modified: e.Modified,
+			Version:  e.Version,
+		})
+	}
+	if total, err := strconv.Atoi(l.Metadata["total"]); err == nil && total > len(entries) {
+		l.Truncated = true
+	}
+	return l, nil
+}
@@ -0,0 +1,103 @@
+package fetch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/latebit/demarkus/protocol"
+)
+
+func TestParseDirListing(t *testing.T) {
+	resp := protocol.Response{
+		Status:   protocol.StatusOK,
+		Metadata: map[string]string{"entries": "3"},
+		Body: "\n# Index of /docs/\n\n" +
+			"- [guides/](guides/)\n" +
+			"- [a \\[draft\\].md](a%20%5Bdraft%5D.md)\n" +
+			"- [readme.md](readme.md)\n" +
+			"\n*...truncated, too many entries*\n",
+	}
+
+	l, err := ParseDirListing(resp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []DirEntry{
+		{Name: "guides", IsDir: true},
+		{Name: "a [draft].md"},
+		{Name: "readme.md"},
+	}
+	if len(l.Entries) != len(want) {
+		t.Fatalf("entries: got %+v, want %+v", l.Entries, want)
+	}
+	for i := range want {
+		if l.Entries[i] != want[i] {
+			t.Errorf("entry %d: got %+v, want %+v", i, l.Entries[i], want[i])
+		}
+	}
+	if !l.Truncated {
+		t.Error("expected truncated listing")
+	}
+	if l.Metadata["entries"] != "3" {
+		t.Errorf("metadata: got %v", l.Metadata)
+	}
+}
+
+func TestParseDirListingStructured(t *testing.T) {
+	modified := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	body, err := protocol.FormatListing([]protocol.ListEntry{
+		{Name: "guides", Type: protocol.EntryDirectory},
+		{Name: "readme.md", Type: protocol.EntryDocument, Size: 42, Modified: modified, Version: 3},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := protocol.Response{
+		Status:   protocol.StatusOK,
+		Metadata: map[string]string{"entries": "2", "total": "5", "next-offset": "2", "content-type": protocol.ListingContentType},
+		Body:     body,
+	}
+
+	l, err := ParseDirListing(resp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []DirEntry{
+		{Name: "guides", IsDir: true},
+		{Name: "readme.md", Size: 42, Modified: modified, Version: 3},
+	}
+	if len(l.Entries) != len(want) {
+		t.Fatalf("entries: got %+v, want %+v", l.Entries, want)
+	}
+	for i := range want {
+		if l.Entries[i] != want[i] {
+			t.Errorf("entry %d: got %+v, want %+v", i, l.Entries[i], want[i])
+		}
+	}
+	if !l.Truncated || l.NextOffset != 2 {
+		t.Errorf("truncated, next offset = %v, %d, want true, 2", l.Truncated, l.NextOffset)
+	}
+
+	resp.Body = "---\nname: a/b.md\ntype: document\n"
+	if _, err := ParseDirListing(resp); err == nil {
+		t.Error("expected error for a nested name")
+	}
+}
+
+func TestParseDirListingErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		resp protocol.Response
+	}{
+		{"not ok", protocol.Response{Status: protocol.StatusNotFound}},
+		{"bad escape", protocol.Response{Status: protocol.StatusOK, Body: "- [x](%zz)\n"}},
+		{"nested name", protocol.Response{Status: protocol.StatusOK, Body: "- [x](a/b.md)\n"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseDirListing(tt.resp); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"iter"
+
+	"github.com/latebit/demarkus/protocol"
+	"github.com/quic-go/quic-go"
+)
+
+// FetchRange retrieves bytes first to last, inclusive, of a document's
+// body; last < 0 means to the end. The server answers with a partial
+// response whose content-range metadata places the bytes in the whole
+// body. Servers that predate ranges send the whole document with status
+// ok instead. Partial bodies are not cached.
+func (c *Client) FetchRange(host, path string, first, last int) (Result, error) {
+	if first < 0 {
+		return Result{}, fmt.Errorf("invalid range start %d", first)
+	}
+	rng := fmt.Sprintf("bytes=%d-", first)
+	if last >= 0 {
+		if last < first {
+			return Result{}, fmt.Errorf("invalid range %d-%d", first, last)
+		}
+		rng += fmt.Sprint(last)
+	}
+	req := protocol.Request{Verb: protocol.VerbFetch, Path: path, Metadata: map[string]string{"range": rng}}
+	return c.doWithRetry(host, func(ctx context.Context, conn *quic.Conn) (Result, error) {
+		return c.requestOnConn(ctx, conn, req)
+	})
+}
+
+// FetchPages returns an iterator over a document's body in pages of up to
+// pageSize bytes, fetched one at a time as the loop asks for them, so a
+// reader that stops early transfers only what it used. The first page can
+// be shown while the rest is still on the server.
+//
+// Iteration ends after the last page, or after the first error: a failed
+// request, a response that is neither partial nor ok, or a document that
+// changed between pages. A server without range support sends the whole
+// body as a single ok page.
+func (c *Client) FetchPages(host, path string, pageSize int) iter.Seq2[Result, error] {
+	return func(yield func(Result, error) bool) {
+		if pageSize <= 0 {
+			yield(Result{}, fmt.Errorf("invalid page size %d", pageSize))
+			return
+		}
+		var p pager
+		for {
+			r, err := c.FetchRange(host, path, p.next, p.next+pageSize-1)
+			if err == nil {
+				err = p.advance(r.Response)
+			}
+			if !yield(r, err) || err != nil || p.done {
+				return
+			}
+		}
+	}
+}
+
+// pager tracks a paged fetch: the offset of the next page, whether the
+// body has been read to its end, and the etag of the document being read.
+type pager struct {
+	next int
+	done bool
+	etag string
+}
+
+// advance records the page in resp, returning an error if it is not the
+// page asked for or belongs to a different version of the document.
+func (p *pager) advance(resp protocol.Response) error {
+	switch resp.Status {
+	case protocol.StatusOK:
+		p.done = true
+		return nil
+	case protocol.StatusPartial:
+	default:
+		return Result{Response: resp}.Err()
+	}
+
+	first, last, size, err := protocol.ParseContentRange(resp.Metadata["content-range"])
+	if err != nil {
+		return err
+	}
+	if first != p.next || last-first+1 != len(resp.Body) {
+		return fmt.Errorf("content-range %q does not match the page asked for", resp.Metadata["content-range"])
+	}
+	etag := resp.Metadata["etag"]
+	if p.etag != "" && etag != p.etag {
+		return fmt.Errorf("document changed while reading: etag %s, was %s", etag, p.etag)
+	}
+	p.etag = etag
+	p.next = last + 1
+	p.done = p.next >= size
+	return nil
+}
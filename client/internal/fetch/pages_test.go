@@ -0,0 +1,57 @@
+package fetch
+
+import (
+	"testing"
+
+	"github.com/latebit/demarkus/protocol"
+)
+
+func partial(contentRange, etag, body string) protocol.Response {
+	return protocol.Response{
+		Status:   protocol.StatusPartial,
+		Metadata: map[string]string{"content-range": contentRange, "etag": etag},
+		Body:     body,
+	}
+}
+
+func TestPagerAdvance(t *testing.T) {
+	var p pager
+	if err := p.advance(partial("bytes 0-3/10", "e1", "# Ti")); err != nil || p.next != 4 || p.done {
+		t.Fatalf("first page: next %d, done %v, err %v", p.next, p.done, err)
+	}
+	if err := p.advance(partial("bytes 4-7/10", "e1", "tle\n")); err != nil || p.next != 8 || p.done {
+		t.Fatalf("second page: next %d, done %v, err %v", p.next, p.done, err)
+	}
+	if err := p.advance(partial("bytes 8-9/10", "e1", "\nx")); err != nil || !p.done {
+		t.Fatalf("last page: done %v, err %v", p.done, err)
+	}
+}
+
+func TestPagerAdvanceErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		resp protocol.Response
+	}{
+		{"changed document", partial("bytes 4-7/10", "e2", "tle\n")},
+		{"wrong offset", partial("bytes 0-3/10", "e1", "# Ti")},
+		{"short body", partial("bytes 4-7/10", "e1", "tl")},
+		{"missing content-range", protocol.Response{Status: protocol.StatusPartial, Metadata: map[string]string{"etag": "e1"}, Body: "tle\n"}},
+		{"error status", protocol.Response{Status: protocol.StatusBadRequest, Metadata: map[string]string{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := pager{next: 4, etag: "e1"}
+			if err := p.advance(tt.resp); err == nil {
+				t.Error("advance succeeded")
+			}
+		})
+	}
+}
+
+func TestPagerAdvanceWholeBody(t *testing.T) {
+	// A server without range support answers with the whole document.
+	var p pager
+	if err := p.advance(protocol.Response{Status: protocol.StatusOK, Body: "# Whole\n"}); err != nil || !p.done {
+		t.Errorf("ok response: done %v, err %v", p.done, err)
+	}
+}
@@ -0,0 +1,143 @@
+package fetch
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/latebit/demarkus/protocol"
+)
+
+// prefetchTTL is how long a prefetched document answers Fetch without
+// asking the server again.
+const prefetchTTL = time.Minute
+
+// prefetched is a response Prefetch got, kept for the next Fetch.
+type prefetched struct {
+	result Result
+	at     time.Time
+}
+
+// PrefetchOptions bounds a Prefetch.
+type PrefetchOptions struct {
+	Workers  int // concurrent fetches (default 4)
+	MaxLinks int // documents fetched at most (default 20)
+}
+
+func (o *PrefetchOptions) applyDefaults() {
+	if o.Workers <= 0 {
+		o.Workers = 4
+	}
+	if o.MaxLinks <= 0 {
+		o.MaxLinks = 20
+	}
+}
+
+// Prefetch fetches the documents at urls into the cache, so that the next
+// Fetch of one of them is answered without a round trip. It is meant for
+// the links on the page being read, and is polite about it, like a crawl
+// restricted to one host: only mark:// URLs on host are fetched, at most
+// MaxLinks of them and Workers at a time; requests are conditional, so
+// unchanged documents cost a not-modified; and the first rate-limited
+// response stops the prefetch instead of being waited out.
+//
+// It returns the successful responses keyed by URL, and does nothing
+// without a cache or for a host SkipCache names.
+func (c *Client) Prefetch(ctx context.Context, host string, urls []string, opts PrefetchOptions) map[string]protocol.Response {
+	if !c.caching(host) {
+		return nil
+	}
+	opts.applyDefaults()
+
+	type target struct{ url, path string }
+	var targets []target
+	seen := make(map[string]bool)
+	for _, raw := range urls {
+		h, path, err := ParseMarkURL(raw)
+		if err != nil || h != host || seen[path] {
+			continue
+		}
+		seen[path] = true
+		targets = append(targets, target{raw, path})
+		if len(targets) == opts.MaxLinks {
+			break
+		}
+	}
+
+	var (
+		mu       sync.Mutex
+		results  = make(map[string]protocol.Response)
+		limited  atomic.Bool
+		wg       sync.WaitGroup
+		jobs     = make(chan target)
+		prefetch = func(t target) {
+			conn, err := c.getConn(context.Background(), host)
+			if err != nil {
+				return
+			}
+			r, err := c.tracked(host, func() (Result, error) {
+				return c.conditionalRequest(context.Background(), conn, host, t.path, protocol.VerbFetch)
+			})
+			if err != nil {
+				return
+			}
+			if _, ok := RetryAfter(r.Response); ok {
+				limited.Store(true)
+				return
+			}
+			if r.Response.Status != protocol.StatusOK {
+				return
+			}
+			c.putPrefetched(host, t.path, r, time.Now())
+			mu.Lock()
+			results[t.url] = r.Response
+			mu.Unlock()
+		}
+	)
+	for range min(opts.Workers, len(targets)) {
+		wg.Go(func() {
+			for t := range jobs {
+				prefetch(t)
+			}
+		})
+	}
+	for _, t := range targets {
+		if ctx.Err() != nil || limited.Load() {
+			break
+		}
+		jobs <- t
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// putPrefetched keeps r, prefetched at now, for the next Fetch of path on
+// host. Prefetches no Fetch took are dropped here once they expire, so the
+// map holds at most the last prefetchTTL of them.
+func (c *Client) putPrefetched(host, path string, r Result, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, p := range c.prefetched {
+		if now.Sub(p.at) > prefetchTTL {
+			delete(c.prefetched, k)
+		}
+	}
+	c.prefetched[host+"\x00"+path] = prefetched{result: r, at: now}
+}
+
+// takePrefetched returns the result of prefetching a document less than
+// prefetchTTL ago. Each prefetch answers one Fetch, so a later Fetch, such
+// as a reload, asks the server again.
+func (c *Client) takePrefetched(host, path string) (Result, bool) {
+	key := host + "\x00" + path
+	c.mu.Lock()
+	p, ok := c.prefetched[key]
+	delete(c.prefetched, key)
+	c.mu.Unlock()
+	if !ok || time.Since(p.at) > prefetchTTL {
+		return Result{}, false
+	}
+	return p.result, true
+}
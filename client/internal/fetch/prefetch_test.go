@@ -0,0 +1,52 @@
+package fetch
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/latebit/demarkus/protocol"
+)
+
+func TestTakePrefetched(t *testing.T) {
+	c := NewClient(Options{})
+	ok := Result{Response: protocol.Response{Status: protocol.StatusOK, Body: "# Linked\n"}}
+	c.prefetched["h\x00/fresh.md"] = prefetched{result: ok, at: time.Now()}
+	c.prefetched["h\x00/stale.md"] = prefetched{result: ok, at: time.Now().Add(-2 * prefetchTTL)}
+
+	if r, found := c.takePrefetched("h", "/fresh.md"); !found || r.Response.Body != "# Linked\n" {
+		t.Errorf("fresh: got %+v, %v", r, found)
+	}
+	// Each prefetch answers one Fetch only.
+	if _, found := c.takePrefetched("h", "/fresh.md"); found {
+		t.Error("fresh: answered twice")
+	}
+	if _, found := c.takePrefetched("h", "/stale.md"); found {
+		t.Error("stale: answered after prefetchTTL")
+	}
+	if _, found := c.takePrefetched("other", "/fresh.md"); found {
+		t.Error("other host: answered")
+	}
+}
+
+func TestPutPrefetchedDropsExpired(t *testing.T) {
+	c := NewClient(Options{})
+	now := time.Now()
+	c.putPrefetched("h", "/old.md", Result{}, now.Add(-2*prefetchTTL))
+	c.putPrefetched("h", "/recent.md", Result{}, now.Add(-time.Second))
+	c.putPrefetched("h", "/new.md", Result{}, now)
+
+	if len(c.prefetched) != 2 {
+		t.Errorf("prefetched holds %d entries, want 2", len(c.prefetched))
+	}
+	if _, ok := c.prefetched["h\x00/old.md"]; ok {
+		t.Error("expired prefetch kept")
+	}
+}
+
+func TestPrefetchWithoutCache(t *testing.T) {
+	c := NewClient(Options{})
+	if got := c.Prefetch(context.Background(), "h", []string{"mark://h/a.md"}, PrefetchOptions{}); got != nil {
+		t.Errorf("Prefetch without cache = %v, want nil", got)
+	}
+}
@@ -0,0 +1,66 @@
+package fetch
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/latebit/demarkus/client/internal/links"
+	"github.com/latebit/demarkus/client/internal/urlnorm"
+	"github.com/latebit/demarkus/protocol"
+)
+
+// DefaultMaxRedirects is how many moved responses the clients follow for
+// one fetch.
+const DefaultMaxRedirects = 5
+
+// RedirectTarget returns the absolute URL a moved response from the URL
+// from points to. chain holds the URLs already redirected from for this
+// fetch, including from. Errors report a missing location, a loop, or more
+// than limit redirects.
+func RedirectTarget(from string, resp protocol.Response, chain []string, limit int) (string, error) {
+	loc := resp.Metadata["location"]
+	if loc == "" {
+		return "", fmt.Errorf("moved response from %s has no location", from)
+	}
+	target := links.Resolve(from, loc)
+	if slices.ContainsFunc(chain, func(u string) bool { return urlnorm.Normalize(u) == target }) {
+		return "", fmt.Errorf("redirect loop: %s -> %s", strings.Join(chain, " -> "), target)
+	}
+	if len(chain) > limit {
+		return "", fmt.Errorf("more than %d redirects starting at %s", limit, chain[0])
+	}
+	return target, nil
+}
+
+// followRedirects follows result, a moved response to a fetch of path from
+// host, and any moved response after it, up to MaxRedirects. It stops at a
+// moved response to another host unless CrossHostRedirects is set. It
+// returns the last response, with the URL it came from and the URLs
+// redirected from.
+func (c *Client) followRedirects(host, path string, result Result) (Result, error) {
+	from := (&url.URL{Scheme: protocol.ALPN, Host: host, Path: path}).String()
+	var chain []string
+	for result.Response.Status == protocol.StatusMoved {
+		target, err := RedirectTarget(from, result.Response, append(chain, from), c.opts.MaxRedirects)
+		if err != nil {
+			return Result{}, err
+		}
+		nextHost, nextPath, err := ParseMarkURL(target)
+		if err != nil {
+			return Result{}, fmt.Errorf("redirect from %s: %w", from, err)
+		}
+		if nextHost != host && !c.opts.CrossHostRedirects {
+			break
+		}
+		chain = append(chain, from)
+		host, path = nextHost, nextPath
+		if result, err = c.fetchOne(host, path); err != nil {
+			return Result{}, err
+		}
+		from = target
+	}
+	result.URL, result.Redirects = from, chain
+	return result, nil
+}
@@ -0,0 +1,95 @@
+package fetch
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/latebit/demarkus/protocol"
+)
+
+func moved(location string) Result {
+	return Result{Response: protocol.Response{Status: protocol.StatusMoved, Metadata: map[string]string{"location": location}}}
+}
+
+func TestRedirectTarget(t *testing.T) {
+	from := "mark://h/old/doc.md"
+
+	got, err := RedirectTarget(from, moved("/new/doc.md").Response, []string{from}, DefaultMaxRedirects)
+	if err != nil || got != "mark://h:6309/new/doc.md" {
+		t.Errorf("got %q, %v; want mark://h:6309/new/doc.md", got, err)
+	}
+
+	if _, err := RedirectTarget(from, moved("").Response, []string{from}, DefaultMaxRedirects); err == nil {
+		t.Error("expected error for missing location")
+	}
+
+	_, err = RedirectTarget(from, moved("mark://h/a.md").Response, []string{"mark://h/a.md", from}, DefaultMaxRedirects)
+	if err == nil || !strings.Contains(err.Error(), "loop") {
+		t.Errorf("expected loop error, got %v", err)
+	}
+
+	chain := make([]string, DefaultMaxRedirects+1)
+	for i := range chain {
+		chain[i] = "mark://h/" + string(rune('a'+i)) + ".md"
+	}
+	if _, err := RedirectTarget(from, moved("/z.md").Response, chain, DefaultMaxRedirects); err == nil {
+		t.Error("expected hop limit error")
+	}
+}
+
+// Prefetched results stand in for the server: Fetch takes them first.
+func TestFetchFollowsRedirects(t *testing.T) {
+	c := NewClient(Options{MaxRedirects: DefaultMaxRedirects, CrossHostRedirects: true})
+	defer c.Close()
+	answer := func(host, path string, r Result) {
+		c.prefetched[host+"\x00"+path] = prefetched{result: r, at: time.Now()}
+	}
+	answer("h:6309", "/old.md", moved("/mid.md"))
+	answer("h:6309", "/mid.md", moved("mark://other/new.md"))
+	answer("other:6309", "/new.md", Result{Response: protocol.Response{Status: protocol.StatusOK, Body: "# New\n"}})
+
+	r, err := c.Fetch("h:6309", "/old.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Response.Body != "# New\n" || r.URL != "mark://other:6309/new.md" {
+		t.Errorf("got %q from %q", r.Response.Body, r.URL)
+	}
+	if want := []string{"mark://h:6309/old.md", "mark://h:6309/mid.md"}; !slices.Equal(r.Redirects, want) {
+		t.Errorf("Redirects = %v, want %v", r.Redirects, want)
+	}
+
+	answer("h:6309", "/a.md", moved("/b.md"))
+	answer("h:6309", "/b.md", moved("/a.md"))
+	if _, err := c.Fetch("h:6309", "/a.md"); err == nil || !strings.Contains(err.Error(), "loop") {
+		t.Errorf("expected loop error, got %v", err)
+	}
+
+	// Without CrossHostRedirects, the redirect to another host is
+	// returned, from the URL that sent it.
+	c.opts.CrossHostRedirects = false
+	answer("h:6309", "/old.md", moved("/mid.md"))
+	answer("h:6309", "/mid.md", moved("mark://other/new.md"))
+	answer("other:6309", "/new.md", Result{Response: protocol.Response{Status: protocol.StatusOK, Body: "# New\n"}})
+	r, err = c.Fetch("h:6309", "/old.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Response.Status != protocol.StatusMoved || r.URL != "mark://h:6309/mid.md" {
+		t.Errorf("cross-host redirect: %q from %q", r.Response.Status, r.URL)
+	}
+	if want := []string{"mark://h:6309/old.md"}; !slices.Equal(r.Redirects, want) {
+		t.Errorf("Redirects = %v, want %v", r.Redirects, want)
+	}
+	if _, ok := c.prefetched["other:6309\x00/new.md"]; !ok {
+		t.Error("fetched from the other host")
+	}
+
+	c.opts.MaxRedirects = 0
+	answer("h:6309", "/old.md", moved("/mid.md"))
+	if r, err := c.Fetch("h:6309", "/old.md"); err != nil || r.Response.Status != protocol.StatusMoved || r.URL != "" {
+		t.Errorf("with redirects off: %+v, %v", r, err)
+	}
+}
@@ -0,0 +1,87 @@
+package fetch
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/latebit/demarkus/protocol"
+)
+
+// StatusError is a response whose status reports a failure, as an error.
+// Client methods return such responses in a Result with a nil error, since
+// their metadata is often needed (a conflict's server-version, say); call
+// Result.Err to handle them like any other error.
+type StatusError struct {
+	Status   string
+	Message  string // the error body's explanation, if any
+	Response protocol.Response
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return e.Status
+	}
+	return e.Status + ": " + e.Message
+}
+
+// successStatuses are the statuses that report the request was carried out,
+// or, for accepted, will be.
+var successStatuses = map[string]bool{
+	protocol.StatusOK:          true,
+	protocol.StatusCreated:     true,
+	protocol.StatusNotModified: true,
+	protocol.StatusPartial:     true,
+	protocol.StatusAccepted:    true,
+}
+
+// Err returns a *StatusError for a response whose status is not a
+// success, and nil otherwise.
+func (r Result) Err() error {
+	if successStatuses[r.Response.Status] {
+		return nil
+	}
+	return &StatusError{
+		Status:   r.Response.Status,
+		Message:  errorMessage(r.Response.Body),
+		Response: r.Response,
+	}
+}
+
+// HasStatus reports whether err is or wraps a *StatusError with status.
+func HasStatus(err error, status string) bool {
+	var se *StatusError
+	return errors.As(err, &se) && se.Status == status
+}
+
+// IsNotFound reports whether err is a not-found response.
+func IsNotFound(err error) bool { return HasStatus(err, protocol.StatusNotFound) }
+
+// IsConflict reports whether err is a conflict response to a write sent
+// with an expected version.
+func IsConflict(err error) bool { return HasStatus(err, protocol.StatusConflict) }
+
+// IsRateLimited reports whether err is a rate-limited response the client
+// did not wait out.
+func IsRateLimited(err error) bool { return HasStatus(err, protocol.StatusRateLimited) }
+
+// IsMoved reports whether err is a moved response; its location metadata
+// says where the document went.
+func IsMoved(err error) bool { return HasStatus(err, protocol.StatusMoved) }
+
+// errorMessage returns the first paragraph of an error body after its
+// "# Title" heading, on one line.
+func errorMessage(body string) string {
+	var lines []string
+	for line := range strings.SplitSeq(body, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "#"):
+			continue
+		case line == "" && len(lines) > 0:
+			return strings.Join(lines, " ")
+		case line != "":
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, " ")
+}
@@ -0,0 +1,42 @@
+package fetch
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/latebit/demarkus/protocol"
+)
+
+func TestResultErr(t *testing.T) {
+	for _, status := range []string{protocol.StatusOK, protocol.StatusCreated, protocol.StatusNotModified, protocol.StatusPartial, protocol.StatusAccepted} {
+		if err := (Result{Response: protocol.Response{Status: status}}).Err(); err != nil {
+			t.Errorf("%s: Err() = %v, want nil", status, err)
+		}
+	}
+
+	r := Result{Response: protocol.Response{
+		Status:   protocol.StatusNotFound,
+		Metadata: map[string]string{},
+		Body:     "\n# Not found\n\n/missing.md not found\n",
+	}}
+	err := r.Err()
+	if err == nil || err.Error() != "not-found: /missing.md not found" {
+		t.Fatalf("Err() = %v", err)
+	}
+	wrapped := fmt.Errorf("fetch: %w", err)
+	if !IsNotFound(wrapped) || IsConflict(wrapped) || IsRateLimited(wrapped) {
+		t.Errorf("predicates on %v: not-found %v, conflict %v, rate-limited %v",
+			wrapped, IsNotFound(wrapped), IsConflict(wrapped), IsRateLimited(wrapped))
+	}
+
+	conflict := Result{Response: protocol.Response{
+		Status:   protocol.StatusConflict,
+		Metadata: map[string]string{"server-version": "4"},
+	}}.Err()
+	if !IsConflict(conflict) || conflict.(*StatusError).Response.Metadata["server-version"] != "4" {
+		t.Errorf("conflict error = %#v", conflict)
+	}
+	if IsNotFound(nil) || IsNotFound(fmt.Errorf("dial: timeout")) {
+		t.Error("IsNotFound matched a non-status error")
+	}
+}
@@ -0,0 +1,34 @@
+package fetch
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/latebit/demarkus/protocol"
+)
+
+// ErrUnverified is wrapped by the error returned for a document whose
+// content-sha256 or signature does not check out. Its body is not cached.
+var ErrUnverified = errors.New("response failed verification")
+
+// verify checks a document response to a request for path against its
+// content-sha256 and, when a key is pinned for host, its signature. A
+// server that pins a key must sign: an unsigned response fails too. Only
+// whole documents are checked; a partial body cannot match the hash.
+func (c *Client) verify(host, path string, resp protocol.Response) error {
+	if resp.Status != protocol.StatusOK {
+		return nil
+	}
+	if c.opts.PinnedKey != nil {
+		if pub := c.opts.PinnedKey(host); pub != nil {
+			if err := resp.VerifySignature(path, pub); err != nil {
+				return fmt.Errorf("%s%s: %w: %w", host, path, ErrUnverified, err)
+			}
+			return nil
+		}
+	}
+	if err := resp.VerifyContentSHA256(); err != nil {
+		return fmt.Errorf("%s%s: %w: %w", host, path, ErrUnverified, err)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package fetch
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"testing"
+
+	"github.com/latebit/demarkus/protocol"
+)
+
+func TestVerify(t *testing.T) {
+	key := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+	pub := key.Public().(ed25519.PublicKey)
+	body := "# Doc\n"
+	digest := protocol.ContentSHA256(body)
+	signed := protocol.Response{Status: protocol.StatusOK, Body: body, Metadata: map[string]string{
+		"content-sha256": digest,
+		"signature":      protocol.Sign(key, "/doc.md", digest),
+		"key-id":         protocol.KeyID(pub),
+	}}
+	unsigned := protocol.Response{Status: protocol.StatusOK, Body: body, Metadata: map[string]string{"content-sha256": digest}}
+	corrupt := protocol.Response{Status: protocol.StatusOK, Body: "# Dog\n", Metadata: map[string]string{"content-sha256": digest}}
+	legacy := protocol.Response{Status: protocol.StatusOK, Body: body, Metadata: map[string]string{}}
+	notFound := protocol.Response{Status: protocol.StatusNotFound, Body: "not found\n", Metadata: map[string]string{}}
+
+	c := NewClient(Options{PinnedKey: func(host string) ed25519.PublicKey {
+		if host == "pinned:6309" {
+			return pub
+		}
+		return nil
+	}})
+	tests := []struct {
+		host, path string
+		resp       protocol.Response
+		wantErr    bool
+	}{
+		{"pinned:6309", "/doc.md", signed, false},
+		{"pinned:6309", "/other.md", signed, true},
+		{"pinned:6309", "/doc.md", unsigned, true},
+		{"pinned:6309", "/doc.md", legacy, true},
+		{"pinned:6309", "/doc.md", notFound, false},
+		{"open:6309", "/doc.md", unsigned, false},
+		{"open:6309", "/doc.md", legacy, false},
+		{"open:6309", "/doc.md", corrupt, true},
+	}
+	for _, tt := range tests {
+		err := c.verify(tt.host, tt.path, tt.resp)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("verify(%s, %s, %q): err = %v, wantErr %v", tt.host, tt.path, tt.resp.Body, err, tt.wantErr)
+		}
+		if err != nil && !errors.Is(err, ErrUnverified) {
+			t.Errorf("verify(%s, %s): %v does not wrap ErrUnverified", tt.host, tt.path, err)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package fetch
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/latebit/demarkus/protocol"
+)
+
+// VersionEntry is one version listed in a VERSIONS response.
+type VersionEntry struct {
+	Version  int
+	Modified time.Time
+	Agent    string // the tool that wrote the version for someone, if any
+}
+
+// VersionHistory is the parsed form of a VERSIONS response.
+type VersionHistory struct {
+	Entries    []VersionEntry // newest first, as listed by the server
+	Total      int
+	Current    int
+	ChainKnown bool   // the server reported chain-valid
+	ChainValid bool   // meaningful only when ChainKnown
+	ChainError string // server's explanation when the chain is invalid
+}
+
+// ParseVersionHistory parses a VERSIONS response. Non-ok responses and
+// malformed entries are errors; the markdown heading and blank lines are
+// skipped.
+func ParseVersionHistory(resp protocol.Response) (VersionHistory, error) {
+	if resp.Status != protocol.StatusOK {
+		return VersionHistory{}, fmt.Errorf("versions: %s", resp.Status)
+	}
+	var h VersionHistory
+	for line := range strings.SplitSeq(resp.Body, "\n") {
+		if !strings.HasPrefix(line, "- [v") {
+			continue
+		}
+		entry, err := parseVersionLine(line)
+		if err != nil {
+			return VersionHistory{}, err
+		}
+		h.Entries = append(h.Entries, entry)
+	}
+
+	h.Total = len(h.Entries)
+	if v, ok := resp.Metadata["total"]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return VersionHistory{}, fmt.Errorf("versions: invalid total %q", v)
+		}
+		h.Total = n
+	}
+	if v, ok := resp.Metadata["current"]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return VersionHistory{}, fmt.Errorf("versions: invalid current version %q", v)
+		}
+		h.Current = n
+	} else if len(h.Entries) > 0 {
+		h.Current = h.Entries[0].Version
+	}
+	if v, ok := resp.Metadata["chain-valid"]; ok {
+		h.ChainKnown = true
+		h.ChainValid = v == "true"
+		h.ChainError = resp.Metadata["chain-error"]
+	}
+	return h, nil
+}
+
+// parseVersionLine parses "- [vN](path/vN) - 2006-01-02T15:04:05Z",
+// optionally followed by " - agent: name".
+func parseVersionLine(line string) (VersionEntry, error) {
+	rest := strings.TrimPrefix(line, "- [v")
+	num, rest, ok := strings.Cut(rest, "]")
+	if !ok {
+		return VersionEntry{}, fmt.Errorf("versions: malformed entry %q", line)
+	}
+	n, err := strconv.Atoi(num)
+	if err != nil || n < 1 {
+		return VersionEntry{}, fmt.Errorf("versions: invalid version in %q", line)
+	}
+	entry := VersionEntry{Version: n}
+	if i := strings.LastIndex(rest, ") - "); i >= 0 {
+		stamp, agent, _ := strings.Cut(rest[i+len(") - "):], " - agent: ")
+		entry.Agent = strings.TrimSpace(agent)
+		t, err := time.Parse(time.RFC3339, strings.TrimSpace(stamp))
+		if err != nil {
+			return VersionEntry{}, fmt.Errorf("versions: invalid timestamp in %q", line)
+		}
+		entry.Modified = t
+	}
+	return entry, nil
+}
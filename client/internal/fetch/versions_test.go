@@ -0,0 +1,80 @@
+package fetch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/latebit/demarkus/protocol"
+)
+
+func TestParseVersionHistory(t *testing.T) {
+	resp := protocol.Response{
+		Status: protocol.StatusOK,
+		Metadata: map[string]string{
+			"total":       "2",
+			"current":     "2",
+			"chain-valid": "false",
+			"chain-error": "chain integrity check failed",
+		},
+		Body: "\n# Version History: /doc.md\n\n" +
+			"- [v2](/doc.md/v2) - 2026-03-02T10:00:00Z - agent: claude-code\n" +
+			"- [v1](/doc.md/v1) - 2026-03-01T09:30:00Z\n",
+	}
+
+	h, err := ParseVersionHistory(resp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if h.Total != 2 || h.Current != 2 {
+		t.Errorf("total/current: got %d/%d, want 2/2", h.Total, h.Current)
+	}
+	if !h.ChainKnown || h.ChainValid || h.ChainError == "" {
+		t.Errorf("chain: got known=%v valid=%v error=%q", h.ChainKnown, h.ChainValid, h.ChainError)
+	}
+	want := []VersionEntry{
+		{Version: 2, Modified: time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC), Agent: "claude-code"},
+		{Version: 1, Modified: time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)},
+	}
+	if len(h.Entries) != len(want) {
+		t.Fatalf("entries: got %d, want %d", len(h.Entries), len(want))
+	}
+	for i, e := range h.Entries {
+		if e.Version != want[i].Version || !e.Modified.Equal(want[i].Modified) || e.Agent != want[i].Agent {
+			t.Errorf("entry %d: got %+v, want %+v", i, e, want[i])
+		}
+	}
+}
+
+func TestParseVersionHistoryDefaults(t *testing.T) {
+	// Without metadata, total and current come from the entries and the
+	// chain status is unknown.
+	h, err := ParseVersionHistory(protocol.Response{
+		Status: protocol.StatusOK,
+		Body:   "- [v3](/a%20b.md/v3) - 2026-01-01T00:00:00Z\n",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if h.Total != 1 || h.Current != 3 || h.ChainKnown {
+		t.Errorf("got total=%d current=%d known=%v", h.Total, h.Current, h.ChainKnown)
+	}
+}
+
+func TestParseVersionHistoryErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		resp protocol.Response
+	}{
+		{"not ok", protocol.Response{Status: protocol.StatusNotFound}},
+		{"bad version", protocol.Response{Status: protocol.StatusOK, Body: "- [vx](/a/vx) - 2026-01-01T00:00:00Z\n"}},
+		{"bad timestamp", protocol.Response{Status: protocol.StatusOK, Body: "- [v1](/a/v1) - yesterday\n"}},
+		{"bad current", protocol.Response{Status: protocol.StatusOK, Metadata: map[string]string{"current": "two"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseVersionHistory(tt.resp); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
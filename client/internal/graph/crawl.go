@@ -6,6 +6,7 @@ import (
 	"sync"
 
 	"github.com/latebit/demarkus/client/internal/links"
+	"github.com/latebit/demarkus/client/internal/urlnorm"
 	"github.com/latebit/demarkus/protocol"
 )
 
@@ -14,17 +15,42 @@ type Fetcher interface {
 	Fetch(host, path string) (FetchResult, error)
 }
 
+// BatchFetcher is a Fetcher that can also fetch several documents from one
+// host at once. Crawl uses FetchMany for the documents of a level that
+// share a host.
+type BatchFetcher interface {
+	Fetcher
+	// FetchMany returns the results for paths, in order. On error, the
+	// crawl fetches the documents one by one instead.
+	FetchMany(host string, paths []string) ([]FetchResult, error)
+}
+
 // FetchResult holds the response from a fetch operation.
 type FetchResult struct {
 	Status string
 	Body   string
 }
 
+// StatusOffHost marks a mark:// node that was not fetched because its host
+// is outside the crawl's allowed hosts.
+const StatusOffHost = "off-host"
+
 // CrawlOptions configures the graph crawler.
 type CrawlOptions struct {
 	MaxDepth int         // maximum link hops from start (default: 2, 0 = start node only, -1 = use default)
+	MaxNodes int         // stop discovering nodes once this many are queued (0 = unlimited)
 	Workers  int         // concurrent fetch goroutines (default: 5)
 	OnNode   func(*Node) // called when a node is discovered, may be nil
+
+	// SameHost restricts fetching to the start URL's host. Links to other
+	// hosts are recorded with StatusOffHost but not fetched.
+	SameHost bool
+	// AllowHosts lists further hosts that may be fetched, as "host" or
+	// "host:port". Setting it implies SameHost.
+	AllowHosts []string
+	// HostDepth overrides MaxDepth for documents on the given hosts, keyed
+	// like AllowHosts.
+	HostDepth map[string]int
 }
 
 func (o *CrawlOptions) applyDefaults() {
@@ -36,121 +62,279 @@ func (o *CrawlOptions) applyDefaults() {
 	}
 }
 
+// hostScope decides which hosts a crawl may fetch and how deep.
+type hostScope struct {
+	restricted bool
+	allowed    []string
+	maxDepth   int
+	hostDepth  map[string]int
+}
+
+func newHostScope(startHost string, opts CrawlOptions) hostScope {
+	sc := hostScope{maxDepth: opts.MaxDepth, hostDepth: opts.HostDepth}
+	if opts.SameHost || len(opts.AllowHosts) > 0 {
+		sc.restricted = true
+		sc.allowed = append([]string{startHost}, opts.AllowHosts...)
+	}
+	return sc
+}
+
+// hostMatches reports whether host ("name:port") matches pattern, which
+// may omit the port.
+func hostMatches(host, pattern string) bool {
+	if strings.EqualFold(host, pattern) {
+		return true
+	}
+	name, _, ok := strings.Cut(host, ":")
+	return ok && !strings.Contains(pattern, ":") && strings.EqualFold(name, pattern)
+}
+
+func (sc hostScope) allows(host string) bool {
+	if !sc.restricted {
+		return true
+	}
+	for _, h := range sc.allowed {
+		if hostMatches(host, h) {
+			return true
+		}
+	}
+	return false
+}
+
+// depthFor returns the maximum depth at which documents on host are
+// fetched. An exact host:port entry wins over a bare host name.
+func (sc hostScope) depthFor(host string) int {
+	if d, ok := sc.hostDepth[host]; ok {
+		return d
+	}
+	for pattern, d := range sc.hostDepth {
+		if hostMatches(host, pattern) {
+			return d
+		}
+	}
+	return sc.maxDepth
+}
+
 type crawlItem struct {
 	url   string
 	depth int
 }
 
+// crawler holds the state of one Crawl.
+type crawler struct {
+	g        *Graph
+	fetcher  Fetcher
+	parseURL func(string) (string, string, error)
+	opts     CrawlOptions
+	scope    hostScope
+}
+
 // Crawl performs a BFS crawl starting from startURL, following mark:// links
 // up to opts.MaxDepth hops. It uses fetcher to retrieve documents and builds
 // a Graph of all discovered nodes and edges.
 //
 // ParseURL is used to split mark:// URLs into host and path for fetching.
-// Links to non-mark schemes are recorded as nodes but not crawled.
+// Links to non-mark schemes are recorded as nodes but not crawled, as are
+// links to hosts excluded by opts.SameHost or opts.AllowHosts.
+//
+// The crawl proceeds one depth level at a time with at most opts.Workers
+// fetches in flight. Which nodes are crawled is deterministic: links are
+// queued in document order, and opts.MaxNodes cuts the queue at the same
+// point on every run against unchanged content.
 func Crawl(ctx context.Context, startURL string, fetcher Fetcher, parseURL func(string) (string, string, error), opts CrawlOptions) (*Graph, error) {
 	opts.applyDefaults()
-	g := New()
+	// Links are resolved to normalized URLs; the start URL must match them
+	// or a link back to it would add a second node.
+	startURL = urlnorm.Normalize(startURL)
+	startHost, _, _ := parseURL(startURL)
+	c := &crawler{
+		g:        New(),
+		fetcher:  fetcher,
+		parseURL: parseURL,
+		opts:     opts,
+		scope:    newHostScope(startHost, opts),
+	}
+
+	visited := map[string]bool{startURL: true}
+	level := []crawlItem{{url: startURL, depth: 0}}
+	for len(level) > 0 && ctx.Err() == nil {
+		children := c.crawlLevel(ctx, level)
 
-	queue := make(chan crawlItem, 1000)
+		var next []crawlItem
+	queue:
+		for i, item := range level {
+			for _, child := range children[i] {
+				if opts.MaxNodes > 0 && len(visited) >= opts.MaxNodes {
+					break queue
+				}
+				if visited[child] || !c.depthOK(child, item.depth+1) {
+					continue
+				}
+				visited[child] = true
+				next = append(next, crawlItem{url: child, depth: item.depth + 1})
+			}
+		}
+		level = next
+	}
+
+	return c.g, nil
+}
+
+// crawlLevel visits every item with a bounded pool of workers and returns
+// the links found in each, indexed like items. Items not yet started when
+// ctx is cancelled are skipped.
+func (c *crawler) crawlLevel(ctx context.Context, items []crawlItem) [][]string {
+	children := make([][]string, len(items))
+	jobs := c.jobs(items)
+	work := make(chan fetchJob)
 	var wg sync.WaitGroup
+	for range min(c.opts.Workers, len(jobs)) {
+		wg.Go(func() {
+			for job := range work {
+				c.run(items, job, children)
+			}
+		})
+	}
+	for _, job := range jobs {
+		if ctx.Err() != nil {
+			break
+		}
+		work <- job // blocks until a worker is free
+	}
+	close(work)
+	wg.Wait()
+	return children
+}
 
-	// Track visited URLs to avoid duplicates.
-	visited := make(map[string]bool)
-	var visitMu sync.Mutex
+// fetchJob is a unit of work of crawlLevel: one item, or with a
+// BatchFetcher, up to protocol.MaxBatchSize items on one host.
+type fetchJob struct {
+	items []int // indexes into the level
+	host  string
+	paths []string // for a batch, the path of each item
+}
 
-	// markVisited returns true if the URL was not yet visited, and marks it.
-	markVisited := func(url string) bool {
-		visitMu.Lock()
-		defer visitMu.Unlock()
-		if visited[url] {
-			return false
+// jobs splits a level into fetchJobs, batching the items to fetch on each
+// host when the fetcher supports it.
+func (c *crawler) jobs(items []crawlItem) []fetchJob {
+	if _, ok := c.fetcher.(BatchFetcher); !ok {
+		jobs := make([]fetchJob, len(items))
+		for i := range items {
+			jobs[i] = fetchJob{items: []int{i}}
 		}
-		visited[url] = true
-		return true
+		return jobs
+	}
+
+	var jobs []fetchJob
+	batches := make(map[string]int) // host to its open batch in jobs
+	for i, item := range items {
+		host, path, status := c.locate(item)
+		if status != "" {
+			jobs = append(jobs, fetchJob{items: []int{i}})
+			continue
+		}
+		j, ok := batches[host]
+		if !ok || len(jobs[j].items) == protocol.MaxBatchSize {
+			j = len(jobs)
+			batches[host] = j
+			jobs = append(jobs, fetchJob{host: host})
+		}
+		jobs[j].items = append(jobs[j].items, i)
+		jobs[j].paths = append(jobs[j].paths, path)
 	}
+	return jobs
+}
 
-	// Seed the queue.
-	if !markVisited(startURL) {
-		return g, nil
-	}
-	wg.Add(1)
-	queue <- crawlItem{url: startURL, depth: 0}
-
-	// Start workers.
-	for range opts.Workers {
-		go func() {
-			for item := range queue {
-				func() {
-					defer wg.Done()
-
-					if ctx.Err() != nil {
-						return
-					}
-
-					node := &Node{
-						URL:   item.url,
-						Depth: item.depth,
-					}
-
-					// Only crawl mark:// URLs.
-					if !strings.HasPrefix(item.url, "mark://") {
-						node.Status = "external"
-						g.AddNode(node)
-						if opts.OnNode != nil {
-							opts.OnNode(node)
-						}
-						return
-					}
-
-					host, path, err := parseURL(item.url)
-					if err != nil {
-						node.Status = "error"
-						g.AddNode(node)
-						if opts.OnNode != nil {
-							opts.OnNode(node)
-						}
-						return
-					}
-
-					result, err := fetcher.Fetch(host, path)
-					if err != nil {
-						node.Status = "error"
-						g.AddNode(node)
-						if opts.OnNode != nil {
-							opts.OnNode(node)
-						}
-						return
-					}
-
-					node.Status = result.Status
-					if result.Status == protocol.StatusOK {
-						node.Title = links.ExtractTitle(result.Body)
-						extracted := links.Extract(result.Body)
-						node.LinkCount = len(extracted)
-
-						for _, dest := range extracted {
-							resolved := links.Resolve(item.url, dest)
-							g.AddEdge(item.url, resolved)
-
-							if item.depth < opts.MaxDepth && markVisited(resolved) {
-								wg.Add(1)
-								child := crawlItem{url: resolved, depth: item.depth + 1}
-								go func() { queue <- child }()
-							}
-						}
-					}
-
-					g.AddNode(node)
-					if opts.OnNode != nil {
-						opts.OnNode(node)
-					}
-				}()
+// run visits the items of job, storing the links found in children.
+func (c *crawler) run(items []crawlItem, job fetchJob, children [][]string) {
+	if len(job.paths) > 1 {
+		results, err := c.fetcher.(BatchFetcher).FetchMany(job.host, job.paths)
+		if err == nil && len(results) == len(job.items) {
+			for k, i := range job.items {
+				children[i] = c.record(items[i], results[k])
 			}
-		}()
+			return
+		}
+	}
+	for _, i := range job.items {
+		children[i] = c.visit(items[i])
 	}
+}
 
-	wg.Wait()
-	close(queue)
+// depthOK reports whether a link at depth may be followed, using the depth
+// limit of the link's own host.
+func (c *crawler) depthOK(url string, depth int) bool {
+	limit := c.opts.MaxDepth
+	if strings.HasPrefix(url, "mark://") {
+		if host, _, err := c.parseURL(url); err == nil {
+			limit = c.scope.depthFor(host)
+		}
+	}
+	return depth <= limit
+}
+
+// visit fetches one item, records its node and outgoing edges, and returns
+// the resolved links it contains.
+func (c *crawler) visit(item crawlItem) []string {
+	host, path, status := c.locate(item)
+	if status != "" {
+		c.addNode(&Node{URL: item.url, Depth: item.depth, Status: status})
+		return nil
+	}
+	result, err := c.fetcher.Fetch(host, path)
+	if err != nil {
+		result = FetchResult{Status: "error"}
+	}
+	return c.record(item, result)
+}
+
+// locate returns the host and path to fetch item from, or the status of
+// its node if it is not to be fetched.
+func (c *crawler) locate(item crawlItem) (host, path, status string) {
+	// Only crawl mark:// URLs.
+	if !strings.HasPrefix(item.url, "mark://") {
+		return "", "", "external"
+	}
+	host, path, err := c.parseURL(item.url)
+	if err != nil {
+		return "", "", "error"
+	}
+	if !c.scope.allows(host) {
+		return "", "", StatusOffHost
+	}
+	return host, path, ""
+}
+
+// record adds the node for a fetched item and its outgoing edges, and
+// returns the resolved links it contains.
+func (c *crawler) record(item crawlItem, result FetchResult) []string {
+	node := &Node{
+		URL:    item.url,
+		Depth:  item.depth,
+		Status: result.Status,
+	}
+	defer c.addNode(node)
+
+	if result.Status != protocol.StatusOK {
+		return nil
+	}
+	node.Title = links.ExtractTitle(result.Body)
+	extracted := links.Extract(result.Body)
+	node.LinkCount = len(extracted)
 
-	return g, nil
+	resolved := make([]string, 0, len(extracted))
+	for _, dest := range extracted {
+		target := links.Resolve(item.url, dest)
+		c.g.AddEdge(item.url, target)
+		resolved = append(resolved, target)
+	}
+	return resolved
+}
+
+// addNode adds node to the graph and reports it to OnNode.
+func (c *crawler) addNode(node *Node) {
+	c.g.AddNode(node)
+	if c.opts.OnNode != nil {
+		c.opts.OnNode(node)
+	}
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"sync"
 	"testing"
+	"time"
 )
 
 // mockFetcher returns canned responses keyed by "host/path".
@@ -240,3 +241,227 @@ func TestCrawlNoCycles(t *testing.T) {
 		t.Errorf("EdgeCount() = %d, want 3", g.EdgeCount())
 	}
 }
+
+func TestCrawlNormalizesURLs(t *testing.T) {
+	f := newMockFetcher()
+	f.add("host:6309", "/index.md", "# Home\n\n[a](./a.md) [again](mark://HOST//a.md) [home](mark://host/index.md)")
+	f.add("host:6309", "/a.md", "# A\n\n[home](index.md)")
+
+	g, err := Crawl(context.Background(), "mark://host/index.md", f, mockParseURL, CrawlOptions{MaxDepth: 10})
+	if err != nil {
+		t.Fatalf("Crawl() error: %v", err)
+	}
+	if g.NodeCount() != 2 {
+		t.Errorf("NodeCount() = %d, want 2 (one node per document)", g.NodeCount())
+	}
+	if g.GetNode("mark://host:6309/index.md") == nil || g.GetNode("mark://host:6309/a.md") == nil {
+		t.Error("expected nodes keyed by normalized URL")
+	}
+	if len(f.calls) != 2 {
+		t.Errorf("fetches = %v, want each document once", f.calls)
+	}
+}
+
+func TestCrawlSameHost(t *testing.T) {
+	f := newMockFetcher()
+	f.add("home:6309", "/index.md", "# Home\n\n[local](a.md) [away](mark://other:6309/b.md) [friend](mark://friend:6309/c.md)")
+	f.add("home:6309", "/a.md", "# A")
+	f.add("other:6309", "/b.md", "# B")
+	f.add("friend:6309", "/c.md", "# C")
+
+	g, err := Crawl(context.Background(), "mark://home:6309/index.md", f, mockParseURL, CrawlOptions{MaxDepth: 2, SameHost: true})
+	if err != nil {
+		t.Fatalf("Crawl() error: %v", err)
+	}
+	if n := g.GetNode("mark://home:6309/a.md"); n == nil || n.Status != "ok" {
+		t.Errorf("same-host node = %+v, want fetched", n)
+	}
+	for _, u := range []string{"mark://other:6309/b.md", "mark://friend:6309/c.md"} {
+		if n := g.GetNode(u); n == nil || n.Status != StatusOffHost {
+			t.Errorf("%s = %+v, want status %q", u, n, StatusOffHost)
+		}
+	}
+	for _, call := range f.calls {
+		if call != "home:6309/index.md" && call != "home:6309/a.md" {
+			t.Errorf("fetched off-host document %s", call)
+		}
+	}
+
+	// An allowlist adds hosts; a bare name matches any port.
+	f = newMockFetcher()
+	f.add("home:6309", "/index.md", "# Home\n\n[away](mark://other:6309/b.md) [friend](mark://friend:6309/c.md)")
+	f.add("friend:6309", "/c.md", "# C")
+	g, err = Crawl(context.Background(), "mark://home:6309/index.md", f, mockParseURL, CrawlOptions{MaxDepth: 2, AllowHosts: []string{"friend"}})
+	if err != nil {
+		t.Fatalf("Crawl() error: %v", err)
+	}
+	if n := g.GetNode("mark://friend:6309/c.md"); n == nil || n.Status != "ok" {
+		t.Errorf("allowed host node = %+v, want fetched", n)
+	}
+	if n := g.GetNode("mark://other:6309/b.md"); n == nil || n.Status != StatusOffHost {
+		t.Errorf("other host node = %+v, want status %q", n, StatusOffHost)
+	}
+}
+
+func TestCrawlHostDepth(t *testing.T) {
+	f := newMockFetcher()
+	f.add("home:6309", "/a.md", "# A\n\n[b](b.md) [far](mark://far:6309/x.md)")
+	f.add("home:6309", "/b.md", "# B\n\n[c](c.md)")
+	f.add("home:6309", "/c.md", "# C")
+	f.add("far:6309", "/x.md", "# X\n\n[y](y.md)")
+	f.add("far:6309", "/y.md", "# Y")
+
+	g, err := Crawl(context.Background(), "mark://home:6309/a.md", f, mockParseURL, CrawlOptions{
+		MaxDepth:  2,
+		HostDepth: map[string]int{"far:6309": 1},
+	})
+	if err != nil {
+		t.Fatalf("Crawl() error: %v", err)
+	}
+	if g.GetNode("mark://home:6309/c.md") == nil {
+		t.Error("home host should be crawled to MaxDepth")
+	}
+	if g.GetNode("mark://far:6309/x.md") == nil {
+		t.Error("far host should be crawled to its own depth")
+	}
+	if g.GetNode("mark://far:6309/y.md") != nil {
+		t.Error("far host should not be crawled beyond its depth limit")
+	}
+}
+
+func TestCrawlMaxNodesIsDeterministic(t *testing.T) {
+	f := newMockFetcher()
+	body := "# Hub\n\n"
+	for i := range 20 {
+		body += fmt.Sprintf("[p%d](p%02d.md) ", i, i)
+		f.add("host:6309", fmt.Sprintf("/p%02d.md", i), "# Leaf")
+	}
+	f.add("host:6309", "/hub.md", body)
+
+	for range 5 {
+		g, err := Crawl(context.Background(), "mark://host:6309/hub.md", f, mockParseURL, CrawlOptions{MaxDepth: 2, MaxNodes: 6, Workers: 4})
+		if err != nil {
+			t.Fatalf("Crawl() error: %v", err)
+		}
+		if g.NodeCount() != 6 {
+			t.Fatalf("NodeCount() = %d, want 6", g.NodeCount())
+		}
+		// The first five links in document order are the ones crawled.
+		for i := range 5 {
+			if g.GetNode(fmt.Sprintf("mark://host:6309/p%02d.md", i)) == nil {
+				t.Errorf("p%02d.md missing; MaxNodes should keep links in document order", i)
+			}
+		}
+	}
+}
+
+// slowFetcher records the peak number of concurrent fetches.
+type slowFetcher struct {
+	*mockFetcher
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+}
+
+func (s *slowFetcher) Fetch(host, path string) (FetchResult, error) {
+	s.mu.Lock()
+	s.inFlight++
+	s.peak = max(s.peak, s.inFlight)
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.inFlight--
+		s.mu.Unlock()
+	}()
+	time.Sleep(2 * time.Millisecond)
+	return s.mockFetcher.Fetch(host, path)
+}
+
+func TestCrawlBoundedWorkers(t *testing.T) {
+	f := &slowFetcher{mockFetcher: newMockFetcher()}
+	body := "# Hub\n\n"
+	for i := range 50 {
+		body += fmt.Sprintf("[p%d](p%d.md) ", i, i)
+	}
+	f.add("host:6309", "/hub.md", body)
+
+	g, err := Crawl(context.Background(), "mark://host:6309/hub.md", f, mockParseURL, CrawlOptions{MaxDepth: 1, Workers: 3})
+	if err != nil {
+		t.Fatalf("Crawl() error: %v", err)
+	}
+	if g.NodeCount() != 51 {
+		t.Errorf("NodeCount() = %d, want 51", g.NodeCount())
+	}
+	if f.peak > 3 {
+		t.Errorf("peak concurrent fetches = %d, want at most 3", f.peak)
+	}
+}
+
+// batchFetcher is a mockFetcher that also fetches in batches, failing those
+// that include a path in fail.
+type batchFetcher struct {
+	*mockFetcher
+	batches [][]string
+	fail    string
+}
+
+func (b *batchFetcher) FetchMany(host string, paths []string) ([]FetchResult, error) {
+	b.mu.Lock()
+	b.batches = append(b.batches, paths)
+	b.mu.Unlock()
+	results := make([]FetchResult, len(paths))
+	for i, path := range paths {
+		if path == b.fail {
+			return nil, fmt.Errorf("batch failed")
+		}
+		r, ok := b.pages[host+path]
+		if !ok {
+			r = FetchResult{Status: "not-found"}
+		}
+		results[i] = r
+	}
+	return results, nil
+}
+
+func TestCrawlBatches(t *testing.T) {
+	f := &batchFetcher{mockFetcher: newMockFetcher()}
+	f.add("a:6309", "/index.md", "# Home\n\n[one](/one.md) [two](/two.md) [other](mark://b:6309/x.md) [web](https://example.com)")
+	f.add("a:6309", "/one.md", "# One")
+	f.add("b:6309", "/x.md", "# X")
+
+	g, err := Crawl(context.Background(), "mark://a:6309/index.md", f, mockParseURL, CrawlOptions{MaxDepth: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := g.GetNode("mark://a:6309/one.md"); n == nil || n.Title != "One" {
+		t.Errorf("one.md = %+v", n)
+	}
+	if n := g.GetNode("mark://a:6309/two.md"); n == nil || n.Status != "not-found" {
+		t.Errorf("two.md = %+v", n)
+	}
+	if n := g.GetNode("https://example.com"); n == nil || n.Status != "external" {
+		t.Errorf("external = %+v", n)
+	}
+	// The start page alone, then one batch for each host of the level.
+	var sizes []int
+	for _, b := range f.batches {
+		sizes = append(sizes, len(b))
+	}
+	if len(f.calls) != 2 || fmt.Sprint(sizes) != "[2]" {
+		t.Errorf("fetches %v, batches %v", f.calls, f.batches)
+	}
+
+	// A failed batch is fetched one by one.
+	f = &batchFetcher{mockFetcher: f.mockFetcher, fail: "/two.md"}
+	f.calls = nil
+	g, err = Crawl(context.Background(), "mark://a:6309/index.md", f, mockParseURL, CrawlOptions{MaxDepth: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := g.GetNode("mark://a:6309/one.md"); n == nil || n.Title != "One" {
+		t.Errorf("after failed batch, one.md = %+v", n)
+	}
+	if len(f.calls) != 4 {
+		t.Errorf("fetches after failed batch = %v", f.calls)
+	}
+}
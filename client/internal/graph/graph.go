@@ -2,7 +2,10 @@
 // discovering link relationships between Mark Protocol documents.
 package graph
 
-import "sync"
+import (
+	"slices"
+	"sync"
+)
 
 // Node represents a document in the graph.
 type Node struct {
@@ -112,6 +115,47 @@ func (g *Graph) InDegrees() map[string]int {
 	return counts
 }
 
+// ShortestPath returns the URLs on a shortest route along links from one
+// URL to another, both included, or nil if there is none. Among routes of
+// the same length it prefers the one following links added earlier, so a
+// crawled graph gives the same answer each time. Links are followed out of
+// any URL with edges, whether or not it is a node.
+func (g *Graph) ShortestPath(from, to string) []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	if from == to {
+		return []string{from}
+	}
+	out := make(map[string][]string)
+	for _, e := range g.edges {
+		out[e.From] = append(out[e.From], e.To)
+	}
+	prev := map[string]string{from: ""}
+	queue := []string{from}
+	for len(queue) > 0 {
+		url := queue[0]
+		queue = queue[1:]
+		for _, next := range out[url] {
+			if _, seen := prev[next]; seen {
+				continue
+			}
+			prev[next] = url
+			if next == to {
+				var path []string
+				for u := to; u != from; u = prev[u] {
+					path = append(path, u)
+				}
+				path = append(path, from)
+				slices.Reverse(path)
+				return path
+			}
+			queue = append(queue, next)
+		}
+	}
+	return nil
+}
+
 // NodeCount returns the number of nodes in the graph.
 func (g *Graph) NodeCount() int {
 	g.mu.RLock()
@@ -1,6 +1,7 @@
 package graph
 
 import (
+	"slices"
 	"testing"
 )
 
@@ -126,6 +127,40 @@ func TestInNeighbors(t *testing.T) {
 	}
 }
 
+func TestShortestPath(t *testing.T) {
+	g := New()
+	for _, u := range []string{"index", "a", "b", "c", "orphan"} {
+		g.AddNode(&Node{URL: "mark://host/" + u + ".md"})
+	}
+	g.AddEdge("mark://host/index.md", "mark://host/a.md")
+	g.AddEdge("mark://host/index.md", "mark://host/b.md")
+	g.AddEdge("mark://host/a.md", "mark://host/c.md")
+	g.AddEdge("mark://host/b.md", "mark://host/c.md")
+	g.AddEdge("mark://host/c.md", "mark://host/index.md")
+
+	tests := []struct {
+		from, to string
+		want     []string
+	}{
+		// Both routes to c are two links long; the one through the earlier link wins.
+		{"index", "c", []string{"index", "a", "c"}},
+		{"c", "b", []string{"c", "index", "b"}},
+		{"a", "a", []string{"a"}},
+		{"index", "orphan", nil},
+		{"orphan", "index", nil},
+	}
+	for _, tt := range tests {
+		got := g.ShortestPath("mark://host/"+tt.from+".md", "mark://host/"+tt.to+".md")
+		var want []string
+		for _, u := range tt.want {
+			want = append(want, "mark://host/"+u+".md")
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("ShortestPath(%s, %s) = %v, want %v", tt.from, tt.to, got, want)
+		}
+	}
+}
+
 func TestAllNodes(t *testing.T) {
 	g := New()
 	if len(g.AllNodes()) != 0 {
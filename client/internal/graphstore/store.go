@@ -15,7 +15,6 @@ import (
 	"path/filepath"
 	"sort"
 	"sync"
-	"sync/atomic"
 	"time"
 
 	"github.com/latebit/demarkus/client/internal/graph"
@@ -306,6 +305,36 @@ func (f *EtagFetcher) Fetch(host, path string) (graph.FetchResult, error) {
 	return graph.FetchResult{Status: status, Body: body}, nil
 }
 
+// Fetched is the outcome of fetching one document for a crawl.
+type Fetched struct {
+	Status, Body, Etag string
+}
+
+// batchEtagFetcher is an EtagFetcher that also implements
+// graph.BatchFetcher.
+type batchEtagFetcher struct {
+	*EtagFetcher
+	fetchMany func(host string, paths []string) ([]Fetched, error)
+}
+
+// FetchMany implements graph.BatchFetcher.
+func (f *batchEtagFetcher) FetchMany(host string, paths []string) ([]graph.FetchResult, error) {
+	fetched, err := f.fetchMany(host, paths)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]graph.FetchResult, len(fetched))
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, r := range fetched {
+		if r.Etag != "" && i < len(paths) {
+			f.etags["mark://"+host+paths[i]] = r.Etag
+		}
+		results[i] = graph.FetchResult{Status: r.Status, Body: r.Body}
+	}
+	return results, nil
+}
+
 // Etags returns the collected etags keyed by URL.
 func (f *EtagFetcher) Etags() map[string]string {
 	f.mu.Lock()
@@ -321,6 +350,15 @@ type CrawlOptions struct {
 	MaxNodes int               // node cap (0 = unlimited)
 	Workers  int               // concurrent workers (0 = default 5)
 	OnNode   func(*graph.Node) // optional per-node callback
+
+	// Host restrictions, as in graph.CrawlOptions.
+	SameHost   bool
+	AllowHosts []string
+	HostDepth  map[string]int
+
+	// FetchMany, if set, fetches several documents on one host at once,
+	// returning their results in order, as graph.BatchFetcher does.
+	FetchMany func(host string, paths []string) ([]Fetched, error)
 }
 
 // CrawlAndPersist runs a graph crawl, merges results into the store, and saves.
@@ -336,24 +374,19 @@ func (s *Store) CrawlAndPersist(
 	opts CrawlOptions,
 ) (*graph.Graph, error) {
 	fetcher := NewEtagFetcher(fetchFunc)
+	var crawlFetcher graph.Fetcher = fetcher
+	if opts.FetchMany != nil {
+		crawlFetcher = &batchEtagFetcher{EtagFetcher: fetcher, fetchMany: opts.FetchMany}
+	}
 
-	var nodeCount atomic.Int32
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
-
-	g, err := graph.Crawl(ctx, startURL, fetcher, parseURL, graph.CrawlOptions{
-		MaxDepth: opts.MaxDepth,
-		Workers:  opts.Workers,
-		OnNode: func(n *graph.Node) {
-			if opts.OnNode != nil {
-				opts.OnNode(n)
-			}
-			if opts.MaxNodes > 0 {
-				if int(nodeCount.Add(1)) >= opts.MaxNodes {
-					cancel()
-				}
-			}
-		},
+	g, err := graph.Crawl(ctx, startURL, crawlFetcher, parseURL, graph.CrawlOptions{
+		MaxDepth:   opts.MaxDepth,
+		MaxNodes:   opts.MaxNodes,
+		Workers:    opts.Workers,
+		SameHost:   opts.SameHost,
+		AllowHosts: opts.AllowHosts,
+		HostDepth:  opts.HostDepth,
+		OnNode:     opts.OnNode,
 	})
 	if err != nil {
 		return g, err
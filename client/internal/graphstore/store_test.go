@@ -401,3 +401,42 @@ func TestCrawlAndPersist_NilStore(t *testing.T) {
 		t.Errorf("NodeCount = %d, want 1", g.NodeCount())
 	}
 }
+
+func TestCrawlAndPersist_FetchMany(t *testing.T) {
+	s, err := Load(filepath.Join(t.TempDir(), "graph.json"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	fetchFunc := func(_, path string) (string, string, string, error) {
+		if path == "/index.md" {
+			return "ok", "# Home\n[A](/a.md) [B](/b.md)\n", "etag-index", nil
+		}
+		return "", "", "", fmt.Errorf("unexpected fetch of %s", path)
+	}
+	var batched []string
+	fetchMany := func(_ string, paths []string) ([]Fetched, error) {
+		batched = append(batched, paths...)
+		results := make([]Fetched, len(paths))
+		for i, p := range paths {
+			results[i] = Fetched{Status: "ok", Body: "# " + p + "\n", Etag: "etag" + p}
+		}
+		return results, nil
+	}
+	parseURL := func(raw string) (string, string, error) {
+		return "host:6309", raw[len("mark://host:6309"):], nil
+	}
+
+	g, err := s.CrawlAndPersist(context.Background(), "mark://host:6309/index.md", fetchFunc, parseURL, CrawlOptions{
+		MaxDepth:  1,
+		FetchMany: fetchMany,
+	})
+	if err != nil {
+		t.Fatalf("CrawlAndPersist: %v", err)
+	}
+	if g.NodeCount() != 3 || fmt.Sprint(batched) != "[/a.md /b.md]" {
+		t.Errorf("NodeCount = %d, batched %v", g.NodeCount(), batched)
+	}
+	if n := s.GetNode("mark://host:6309/b.md"); n == nil || n.Etag != "etag/b.md" {
+		t.Errorf("batched node = %+v", n)
+	}
+}
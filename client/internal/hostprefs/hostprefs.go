@@ -0,0 +1,128 @@
+// Package hostprefs holds per-host display preferences for the client.
+//
+// Preferences are read from a TOML file (default ~/.mark/hosts.toml) that
+// the user edits by hand, with a table per host. A table named "host:port"
+// applies to that server; one named by hostname alone applies to the host
+// on any port, where no table names the port:
+//
+//	["ascii.example.com"]
+//	plain = true     # show the markdown source instead of rendering it
+//	width = 72       # wrap rendered documents at 72 columns
+//
+//	["news.example.com:6309"]
+//	no_cache = true  # always ask the server, never the cache
+//
+//	["docs.example.com"]
+//	signing_key = "O2onvM62pC1io6jQKm8Nc2UyFXcd4kOmOsBIoYtZ2ik="  # only accept documents it signed
+package hostprefs
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/latebit/demarkus/protocol"
+)
+
+// Prefs are the display preferences for one host. The zero value is the
+// default behaviour.
+type Prefs struct {
+	// Plain shows documents as their markdown source, for hosts that
+	// publish preformatted text rendering would mangle.
+	Plain bool `toml:"plain"`
+	// Width wraps rendered documents at this many columns, or at the
+	// terminal's width when that is narrower. 0 means the terminal's.
+	Width int `toml:"width"`
+	// NoCache fetches every document from the server, and keeps none of
+	// them in the cache.
+	NoCache bool `toml:"no_cache"`
+	// SigningKey pins the host's ed25519 public key, in base64 as the
+	// server logs it: documents not signed with it are rejected.
+	SigningKey string `toml:"signing_key"`
+}
+
+// PinnedKey returns the public key pinned by SigningKey, or nil if none
+// is.
+func (p Prefs) PinnedKey() ed25519.PublicKey {
+	if p.SigningKey == "" {
+		return nil
+	}
+	pub, err := protocol.ParsePublicKey(p.SigningKey)
+	if err != nil {
+		return nil // Load rejects unparsable keys
+	}
+	return pub
+}
+
+// Store holds the preferences of every configured host.
+type Store struct {
+	hosts map[string]Prefs
+}
+
+// DefaultPath returns the default preferences file path
+// (~/.mark/hosts.toml).
+func DefaultPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".mark", "hosts.toml")
+}
+
+// Load reads a preferences file. Returns an empty store if the file does
+// not exist. Returns an error if path is empty, or if a table sets an
+// unknown key, a negative width or a signing key that is not one.
+func Load(path string) (*Store, error) {
+	if path == "" {
+		return nil, fmt.Errorf("host preferences path is empty (could not determine home directory)")
+	}
+	s := &Store{hosts: make(map[string]Prefs)}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("read host preferences %q: %w", path, err)
+	}
+	md, err := toml.Decode(string(data), &s.hosts)
+	if err != nil {
+		return nil, fmt.Errorf("parse host preferences %q: %w", path, err)
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return nil, fmt.Errorf("parse host preferences %q: unknown key %s", path, undecoded[0])
+	}
+	hosts := make(map[string]Prefs, len(s.hosts))
+	for host, p := range s.hosts {
+		if p.Width < 0 {
+			return nil, fmt.Errorf("host preferences %q: %s: width must not be negative", path, host)
+		}
+		if p.SigningKey != "" {
+			if _, err := protocol.ParsePublicKey(p.SigningKey); err != nil {
+				return nil, fmt.Errorf("host preferences %q: %s: signing_key: %w", path, host, err)
+			}
+		}
+		hosts[strings.ToLower(host)] = p
+	}
+	s.hosts = hosts
+	return s, nil
+}
+
+// For returns the preferences for host ("host:port"). A nil store has
+// none.
+func (s *Store) For(host string) Prefs {
+	if s == nil {
+		return Prefs{}
+	}
+	host = strings.ToLower(host)
+	if p, ok := s.hosts[host]; ok {
+		return p
+	}
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		return s.hosts[name]
+	}
+	return Prefs{}
+}
@@ -0,0 +1,92 @@
+package hostprefs
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "hosts.toml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFor(t *testing.T) {
+	s, err := Load(writeFile(t, `
+["ascii.example.com"]
+plain = true
+
+["ascii.example.com:7000"]
+width = 72
+
+["News.example.com:6309"]
+no_cache = true
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]Prefs{
+		"ascii.example.com:6309": {Plain: true},
+		"ascii.example.com:7000": {Width: 72},
+		"news.example.com:6309":  {NoCache: true},
+		"news.example.com:7000":  {},
+		"other.example.com:6309": {},
+	}
+	for host, want := range tests {
+		if got := s.For(host); got != want {
+			t.Errorf("For(%q) = %+v, want %+v", host, got, want)
+		}
+	}
+
+	var none *Store
+	if got := none.For("ascii.example.com:6309"); got != (Prefs{}) {
+		t.Errorf("nil store: For = %+v", got)
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	s, err := Load(filepath.Join(t.TempDir(), "hosts.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.For("example.com:6309"); got != (Prefs{}) {
+		t.Errorf("For = %+v", got)
+	}
+	if _, err := Load(""); err == nil {
+		t.Error("Load(\"\") succeeded")
+	}
+}
+
+func TestLoadRejects(t *testing.T) {
+	for name, content := range map[string]string{
+		"unknown key":    "[\"a.example.com\"]\nplian = true\n",
+		"negative width": "[\"a.example.com\"]\nwidth = -1\n",
+		"wrong type":     "[\"a.example.com\"]\nplain = \"yes\"\n",
+		"bad key":        "[\"a.example.com\"]\nsigning_key = \"c2hvcnQ=\"\n",
+		"not toml":       "[a.example.com\n",
+	} {
+		if _, err := Load(writeFile(t, content)); err == nil {
+			t.Errorf("%s: Load succeeded", name)
+		}
+	}
+}
+
+func TestPinnedKey(t *testing.T) {
+	const key = "O2onvM62pC1io6jQKm8Nc2UyFXcd4kOmOsBIoYtZ2ik="
+	s, err := Load(writeFile(t, "[\"docs.example.com\"]\nsigning_key = \""+key+"\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := s.For("docs.example.com:6309").PinnedKey()
+	if got := base64.StdEncoding.EncodeToString(pub); got != key {
+		t.Errorf("PinnedKey = %q, want %q", got, key)
+	}
+	if pub := s.For("other.example.com:6309").PinnedKey(); pub != nil {
+		t.Errorf("unpinned host: PinnedKey = %x, want nil", pub)
+	}
+}
@@ -3,8 +3,11 @@ package links
 
 import (
 	"net/url"
+	"strconv"
 	"strings"
 
+	"github.com/latebit/demarkus/client/internal/urlnorm"
+	"github.com/latebit/demarkus/protocol"
 	"github.com/yuin/goldmark"
 	"github.com/yuin/goldmark/ast"
 	"github.com/yuin/goldmark/text"
@@ -40,10 +43,12 @@ func Extract(body string) []string {
 	return links
 }
 
-// Resolve resolves a possibly-relative link dest against baseURL.
+// Resolve resolves a possibly-relative link dest against baseURL. The
+// result is normalized with urlnorm, so links that name the same document
+// resolve to the same string.
 func Resolve(baseURL, dest string) string {
 	if strings.Contains(dest, "://") {
-		return dest
+		return urlnorm.Normalize(dest)
 	}
 	base, err := url.Parse(baseURL)
 	if err != nil || baseURL == "" {
@@ -53,7 +58,78 @@ func Resolve(baseURL, dest string) string {
 	if err != nil {
 		return dest
 	}
-	return base.ResolveReference(ref).String()
+	return urlnorm.Normalize(base.ResolveReference(ref).String())
+}
+
+// RelinkHost rewrites the absolute mark:// URLs in body that name the host
+// from so that they name to instead, for a site that changes domain or
+// port. Hosts match ignoring case, and a host without a port matches it
+// with the default one. URLs mentioned in the text are rewritten as well
+// as link destinations; those in code are left alone.
+func RelinkHost(body, from, to string) string {
+	src := []byte(body)
+	doc := goldmark.DefaultParser().Parse(text.NewReader(src))
+
+	// The byte ranges of code, in document order.
+	var code [][2]int
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.FencedCodeBlock, *ast.CodeBlock:
+			lines := n.Lines()
+			for i := range lines.Len() {
+				seg := lines.At(i)
+				code = append(code, [2]int{seg.Start, seg.Stop})
+			}
+			return ast.WalkSkipChildren, nil
+		case *ast.CodeSpan:
+			for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+				if t, ok := c.(*ast.Text); ok {
+					code = append(code, [2]int{t.Segment.Start, t.Segment.Stop})
+				}
+			}
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+
+	from = canonicalHost(from)
+	var b strings.Builder
+	last := 0
+	for i := 0; ; {
+		k := strings.Index(body[i:], markScheme)
+		if k < 0 {
+			break
+		}
+		start := i + k + len(markScheme)
+		end := start + strings.IndexFunc(body[start:]+" ", endsHost)
+		i = end
+		for len(code) > 0 && code[0][1] <= start {
+			code = code[1:]
+		}
+		if len(code) > 0 && code[0][0] < start || canonicalHost(body[start:end]) != from {
+			continue
+		}
+		b.WriteString(body[last:start])
+		b.WriteString(to)
+		last = end
+	}
+	b.WriteString(body[last:])
+	return b.String()
+}
+
+const markScheme = "mark://"
+
+// endsHost reports whether r ends the host of a URL in markdown text.
+func endsHost(r rune) bool {
+	return strings.ContainsRune("/?#)]>\"' \t\r\n", r)
+}
+
+// canonicalHost returns host in lowercase without the default port.
+func canonicalHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ":"+strconv.Itoa(protocol.DefaultPort))
 }
 
 // ExtractTitle returns the text of the first top-level heading in the markdown body.
@@ -85,3 +161,39 @@ func ExtractTitle(body string) string {
 	})
 	return title.String()
 }
+
+// Heading is a markdown heading: its level (1-6) and plain text.
+type Heading struct {
+	Level int
+	Text  string
+}
+
+// Headings returns the headings of the markdown body in document order.
+func Headings(body string) []Heading {
+	src := []byte(body)
+	reader := text.NewReader(src)
+	doc := goldmark.DefaultParser().Parse(reader)
+
+	var headings []Heading
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		heading, ok := n.(*ast.Heading)
+		if !ok {
+			return ast.WalkContinue, nil
+		}
+		var sb strings.Builder
+		_ = ast.Walk(heading, func(child ast.Node, entering bool) (ast.WalkStatus, error) {
+			if entering {
+				if t, ok := child.(*ast.Text); ok {
+					sb.Write(t.Value(src))
+				}
+			}
+			return ast.WalkContinue, nil
+		})
+		headings = append(headings, Heading{Level: heading.Level, Text: sb.String()})
+		return ast.WalkSkipChildren, nil
+	})
+	return headings
+}
@@ -93,6 +93,18 @@ func TestResolve(t *testing.T) {
 			dest:    "../c.md",
 			want:    "mark://host:6309/a/c.md",
 		},
+		{
+			name:    "normalized",
+			baseURL: "mark://Host/dir/page.md",
+			dest:    ".//sub//./doc.md",
+			want:    "mark://host:6309/dir/sub/doc.md",
+		},
+		{
+			name:    "absolute link normalized",
+			baseURL: "mark://host:6309/page.md",
+			dest:    "mark://other//doc.md",
+			want:    "mark://other:6309/doc.md",
+		},
 		{
 			name:    "empty base URL",
 			baseURL: "",
@@ -169,3 +181,44 @@ func TestExtractTitle(t *testing.T) {
 		})
 	}
 }
+
+func TestHeadings(t *testing.T) {
+	body := "# Title\n\nIntro.\n\n## Install\n\n### From *source*\n\n```\n# not a heading\n```\n\n## Use\n"
+	got := Headings(body)
+	want := []Heading{
+		{Level: 1, Text: "Title"},
+		{Level: 2, Text: "Install"},
+		{Level: 3, Text: "From source"},
+		{Level: 2, Text: "Use"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Headings = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("heading %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if got := Headings("No headings here."); len(got) != 0 {
+		t.Errorf("Headings = %v, want none", got)
+	}
+}
+
+func TestRelinkHost(t *testing.T) {
+	body := "See [guide](mark://Old.example:6309/guide.md), <mark://old.example/a.md#top>,\n" +
+		"mark://old.example and mark://old.example.com/x.md.\n\n" +
+		"[ref]: mark://old.example/ref.md\n\n" +
+		"`mark://old.example/code.md`\n\n```\nmark://old.example/fenced.md\n```\n\n" +
+		"    mark://old.example/indented.md\n"
+	want := "See [guide](mark://new.example:7000/guide.md), <mark://new.example:7000/a.md#top>,\n" +
+		"mark://new.example:7000 and mark://old.example.com/x.md.\n\n" +
+		"[ref]: mark://new.example:7000/ref.md\n\n" +
+		"`mark://old.example/code.md`\n\n```\nmark://old.example/fenced.md\n```\n\n" +
+		"    mark://old.example/indented.md\n"
+	if got := RelinkHost(body, "old.example", "new.example:7000"); got != want {
+		t.Errorf("RelinkHost:\n got %q\nwant %q", got, want)
+	}
+	if got := RelinkHost(body, "other.example", "new.example"); got != body {
+		t.Errorf("RelinkHost changed links to another host:\n%q", got)
+	}
+}
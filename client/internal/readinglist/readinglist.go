@@ -0,0 +1,217 @@
+// Package readinglist keeps documents saved to read later, shared by the
+// CLI and the TUI.
+//
+// The list is stored as JSON in ~/.mark/readinglist.json. Read state is
+// kept per document version: an item read at v3 becomes unread again once
+// v4 is seen, so the list also tracks pages that changed since reading.
+package readinglist
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// schemaVersion is the on-disk format version. Increment on breaking changes.
+const schemaVersion = 1
+
+// Item is a document on the reading list.
+type Item struct {
+	URL         string    `json:"url"`
+	Title       string    `json:"title"`
+	Added       time.Time `json:"added"`
+	Version     string    `json:"version,omitempty"` // latest version seen
+	Read        bool      `json:"read,omitempty"`
+	ReadVersion string    `json:"read_version,omitempty"`
+}
+
+// Unread reports whether the item has not been read, or has changed since.
+func (it Item) Unread() bool {
+	return !it.Read || it.Version != it.ReadVersion
+}
+
+// Updated reports whether the item was read and has changed since.
+func (it Item) Updated() bool {
+	return it.Read && it.Version != it.ReadVersion
+}
+
+// document is the on-disk JSON envelope.
+type document struct {
+	Version int    `json:"version"`
+	Items   []Item `json:"items"`
+}
+
+// Store manages the reading list persisted as a JSON file.
+type Store struct {
+	path  string
+	items []Item
+}
+
+// DefaultPath returns the default reading list path (~/.mark/readinglist.json).
+func DefaultPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".mark", "readinglist.json")
+}
+
+// Load reads the reading list from path. Returns an empty store if the
+// file does not exist yet.
+func Load(path string) (*Store, error) {
+	if path == "" {
+		return nil, errors.New("reading list path is empty (could not determine home directory)")
+	}
+	s := &Store{path: path}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("read reading list %q: %w", path, err)
+	}
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse reading list %q: %w", path, err)
+	}
+	if doc.Version > schemaVersion {
+		return nil, fmt.Errorf("reading list %q: unsupported version %d", path, doc.Version)
+	}
+	s.items = doc.Items
+	return s, nil
+}
+
+// List returns all items in the order they were added.
+func (s *Store) List() []Item {
+	return s.items
+}
+
+// Has reports whether url is on the list.
+func (s *Store) Has(url string) bool {
+	return s.index(url) >= 0
+}
+
+// UnreadCount returns the number of unread items.
+func (s *Store) UnreadCount() int {
+	n := 0
+	for _, it := range s.items {
+		if it.Unread() {
+			n++
+		}
+	}
+	return n
+}
+
+// Add saves url as unread. version is the version seen when saving, or
+// empty if unknown. Adding a listed URL is a no-op.
+func (s *Store) Add(url, title, version string) error {
+	if s.Has(url) {
+		return nil
+	}
+	s.items = append(s.items, Item{URL: url, Title: title, Added: time.Now(), Version: version})
+	return s.save()
+}
+
+// Remove deletes url from the list.
+func (s *Store) Remove(url string) error {
+	i := s.index(url)
+	if i < 0 {
+		return nil
+	}
+	s.items = append(s.items[:i], s.items[i+1:]...)
+	return s.save()
+}
+
+// MarkRead records that version of url has been read. It is a no-op for
+// URLs not on the list.
+func (s *Store) MarkRead(url, version string) error {
+	i := s.index(url)
+	if i < 0 {
+		return nil
+	}
+	it := &s.items[i]
+	if it.Read && it.ReadVersion == version && it.Version == version {
+		return nil
+	}
+	it.Version, it.Read, it.ReadVersion = version, true, version
+	return s.save()
+}
+
+// Observe records the latest version of url, seen without reading it.
+// It reports whether the version changed.
+func (s *Store) Observe(url, version string) (bool, error) {
+	i := s.index(url)
+	if i < 0 || version == "" || s.items[i].Version == version {
+		return false, nil
+	}
+	s.items[i].Version = version
+	return true, s.save()
+}
+
+// Render returns the reading list as a markdown document, unread items
+// first.
+func (s *Store) Render() string {
+	var sb strings.Builder
+	sb.WriteString("# Reading List\n")
+	if len(s.items) == 0 {
+		sb.WriteString("\nNothing saved yet. Press `R` on any page to read it later.\n")
+		return sb.String()
+	}
+	section := func(heading string, unread bool) {
+		first := true
+		for _, it := range s.items {
+			if it.Unread() != unread {
+				continue
+			}
+			if first {
+				fmt.Fprintf(&sb, "\n## %s\n\n", heading)
+				first = false
+			}
+			fmt.Fprintf(&sb, "- [%s](%s)", escapeTitle(it.Title), it.URL)
+			if it.Updated() {
+				sb.WriteString(" — updated")
+			}
+			if _, err := strconv.Atoi(it.Version); err == nil {
+				fmt.Fprintf(&sb, " (v%s)", it.Version)
+			}
+			sb.WriteString("\n")
+		}
+	}
+	section(fmt.Sprintf("Unread (%d)", s.UnreadCount()), true)
+	section("Read", false)
+	return sb.String()
+}
+
+func (s *Store) index(url string) int {
+	for i, it := range s.items {
+		if it.URL == url {
+			return i
+		}
+	}
+	return -1
+}
+
+// escapeTitle escapes characters that would break markdown link text.
+func escapeTitle(t string) string {
+	return strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`).Replace(t)
+}
+
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(document{Version: schemaVersion, Items: s.items}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("create reading list directory: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write reading list: %w", err)
+	}
+	return os.Rename(tmp, s.path)
+}
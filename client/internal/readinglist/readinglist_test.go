@@ -0,0 +1,62 @@
+package readinglist
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "readinglist.json")
+	s, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add("mark://h/a.md", "A [draft]", "3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add("mark://h/b.md", "B", ""); err != nil {
+		t.Fatal(err)
+	}
+	if s.UnreadCount() != 2 {
+		t.Fatalf("UnreadCount = %d, want 2", s.UnreadCount())
+	}
+
+	if err := s.MarkRead("mark://h/a.md", "3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.MarkRead("mark://h/missing.md", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if s.UnreadCount() != 1 || s.Has("mark://h/missing.md") {
+		t.Fatalf("after MarkRead: %+v", s.List())
+	}
+
+	// A new version makes a read item unread again; the same one does not.
+	if changed, err := s.Observe("mark://h/a.md", "3"); err != nil || changed {
+		t.Fatalf("Observe same version = %v, %v", changed, err)
+	}
+	if changed, err := s.Observe("mark://h/a.md", "4"); err != nil || !changed {
+		t.Fatalf("Observe new version = %v, %v", changed, err)
+	}
+
+	s, err = Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := s.List()[0]
+	if !a.Unread() || !a.Updated() || a.ReadVersion != "3" {
+		t.Errorf("reloaded item = %+v", a)
+	}
+	md := s.Render()
+	if !strings.Contains(md, "## Unread (2)\n\n- [A \\[draft\\]](mark://h/a.md) — updated (v4)\n- [B](mark://h/b.md)\n") {
+		t.Errorf("Render =\n%s", md)
+	}
+
+	if err := s.Remove("mark://h/a.md"); err != nil {
+		t.Fatal(err)
+	}
+	if s.Has("mark://h/a.md") || len(s.List()) != 1 {
+		t.Errorf("after Remove: %+v", s.List())
+	}
+}
@@ -0,0 +1,164 @@
+// Package search provides offline full-text search over documents the
+// client has already fetched. It works against any server, including
+// those without server-side search: the index is built from the local
+// response cache.
+package search
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/latebit/demarkus/client/internal/cache"
+	"github.com/latebit/demarkus/client/internal/links"
+	"github.com/latebit/demarkus/protocol"
+)
+
+// titleWeight is how much more a term in the title counts than one in the body.
+const titleWeight = 5
+
+// snippetWidth is the approximate length of a result snippet.
+const snippetWidth = 100
+
+// versionPathRe matches version-pinned paths (/doc.md/v3), which duplicate
+// the document they pin.
+var versionPathRe = regexp.MustCompile(`/v[0-9]+$`)
+
+// Hit is one search result.
+type Hit struct {
+	URL     string
+	Title   string
+	Score   int
+	Snippet string // line of the body around the first matching term
+}
+
+type document struct {
+	url   string
+	title string
+	body  string
+	terms map[string]int // term → weighted frequency
+}
+
+// Index is an in-memory inverted index of documents.
+type Index struct {
+	docs     []document
+	postings map[string][]int // term → indexes into docs
+}
+
+// New returns an empty index.
+func New() *Index {
+	return &Index{postings: make(map[string][]int)}
+}
+
+// FromCache indexes every successful FETCH in the cache, skipping
+// version-pinned copies.
+func FromCache(c *cache.Cache) (*Index, error) {
+	idx := New()
+	err := c.Each(protocol.VerbFetch, func(url string, e cache.Entry) error {
+		if e.Response.Status == protocol.StatusOK && !versionPathRe.MatchString(url) {
+			idx.Add(url, e.Response.Body)
+		}
+		return nil
+	})
+	return idx, err
+}
+
+// Len returns the number of indexed documents.
+func (idx *Index) Len() int {
+	return len(idx.docs)
+}
+
+// Add indexes a markdown document. Its title is its first top-level heading.
+func (idx *Index) Add(url, body string) {
+	d := document{url: url, title: links.ExtractTitle(body), body: body, terms: make(map[string]int)}
+	for _, t := range tokenize(d.title) {
+		d.terms[t] += titleWeight
+	}
+	for _, t := range tokenize(body) {
+		d.terms[t]++
+	}
+	n := len(idx.docs)
+	idx.docs = append(idx.docs, d)
+	for t := range d.terms {
+		idx.postings[t] = append(idx.postings[t], n)
+	}
+}
+
+// Search returns documents containing every term of query, best first,
+// at most limit of them (0 means no limit). Ties are broken by URL.
+func (idx *Index) Search(query string, limit int) []Hit {
+	terms := tokenize(query)
+	if len(terms) == 0 {
+		return nil
+	}
+	scores := make(map[int]int)
+	for i, t := range terms {
+		matched := make(map[int]int)
+		for _, n := range idx.postings[t] {
+			if i == 0 {
+				matched[n] = idx.docs[n].terms[t]
+			} else if s, ok := scores[n]; ok {
+				matched[n] = s + idx.docs[n].terms[t]
+			}
+		}
+		scores = matched
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for n, score := range scores {
+		d := idx.docs[n]
+		hits = append(hits, Hit{URL: d.url, Title: d.title, Score: score, Snippet: snippet(d.body, terms)})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].URL < hits[j].URL
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+// tokenize splits text into lowercase words of letters and digits.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// snippet returns the first non-heading line containing one of terms,
+// trimmed to about snippetWidth around the match.
+func snippet(body string, terms []string) string {
+	for line := range strings.SplitSeq(body, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lower := strings.ToLower(line)
+		for _, t := range terms {
+			at := strings.Index(lower, t)
+			if at < 0 {
+				continue
+			}
+			runes := []rune(line)
+			if len(runes) <= snippetWidth {
+				return line
+			}
+			start := len([]rune(lower[:at])) - snippetWidth/3
+			prefix := "…"
+			if start <= 0 {
+				start, prefix = 0, ""
+			}
+			end := start + snippetWidth
+			suffix := "…"
+			if end >= len(runes) {
+				start, end, suffix = len(runes)-snippetWidth, len(runes), ""
+			}
+			return prefix + string(runes[start:end]) + suffix
+		}
+	}
+	return ""
+}
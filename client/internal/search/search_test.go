@@ -0,0 +1,71 @@
+package search
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/latebit/demarkus/client/internal/cache"
+	"github.com/latebit/demarkus/protocol"
+)
+
+func TestSearch(t *testing.T) {
+	idx := New()
+	idx.Add("mark://h/quic.md", "# QUIC Transport\n\nDemarkus runs over QUIC streams.\n")
+	idx.Add("mark://h/tls.md", "# TLS\n\nQUIC requires TLS 1.3 for every connection.\n")
+	idx.Add("mark://h/other.md", "# Other\n\nNothing relevant here.\n")
+
+	hits := idx.Search("quic", 0)
+	if len(hits) != 2 {
+		t.Fatalf("hits = %+v, want 2", hits)
+	}
+	// A title match outranks a body match.
+	if hits[0].URL != "mark://h/quic.md" || hits[0].Title != "QUIC Transport" {
+		t.Errorf("first hit = %+v", hits[0])
+	}
+	if hits[1].Snippet != "QUIC requires TLS 1.3 for every connection." {
+		t.Errorf("snippet = %q", hits[1].Snippet)
+	}
+
+	// Every term must match.
+	if hits := idx.Search("QUIC tls", 0); len(hits) != 1 || hits[0].URL != "mark://h/tls.md" {
+		t.Errorf("AND search = %+v", hits)
+	}
+	if hits := idx.Search("quic", 1); len(hits) != 1 {
+		t.Errorf("limit 1 gave %d hits", len(hits))
+	}
+	if hits := idx.Search("  ", 0); hits != nil {
+		t.Errorf("blank query = %+v", hits)
+	}
+}
+
+func TestSnippetTrimsLongLines(t *testing.T) {
+	line := strings.Repeat("lorem ", 40) + "needle " + strings.Repeat("ipsum ", 40)
+	got := snippet("# T\n\n"+line, []string{"needle"})
+	if !strings.HasPrefix(got, "…") || !strings.HasSuffix(got, "…") || !strings.Contains(got, "needle") {
+		t.Errorf("snippet = %q", got)
+	}
+}
+
+func TestFromCache(t *testing.T) {
+	c := cache.New(t.TempDir())
+	put := func(path, status, body string) {
+		t.Helper()
+		if err := c.Put("h:6309", path, protocol.VerbFetch, protocol.Response{Status: status, Body: body}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put("/guide.md", protocol.StatusOK, "# Guide\n\nOffline search works.\n")
+	put("/guide.md/v1", protocol.StatusOK, "# Guide\n\nOffline search works.\n")
+	put("/missing.md", protocol.StatusNotFound, "# Not Found\n\nOffline.\n")
+
+	idx, err := FromCache(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idx.Len() != 1 {
+		t.Errorf("indexed %d documents, want 1", idx.Len())
+	}
+	if hits := idx.Search("offline", 0); len(hits) != 1 || hits[0].URL != "mark://h:6309/guide.md" {
+		t.Errorf("hits = %+v", hits)
+	}
+}
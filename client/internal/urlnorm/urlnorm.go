@@ -0,0 +1,62 @@
+// Package urlnorm puts mark:// URLs into one canonical form, so that the
+// cache, the TUI history and the graph crawler agree on when two URLs name
+// the same document.
+//
+// The canonical form has a lowercase scheme and host, an explicit port
+// (protocol.DefaultPort when none is given), no duplicate slashes or dot
+// segments in the path, canonical percent-escaping and no fragment:
+//
+//	MARK://Example.org//docs/./a/../b.md#intro -> mark://example.org:6309/docs/b.md
+//
+// A trailing slash is kept: it changes how relative links resolve, and the
+// server lists a directory with links relative to it.
+package urlnorm
+
+import (
+	"net"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/latebit/demarkus/protocol"
+)
+
+// Normalize returns the canonical form of a mark:// URL. Anything else,
+// including URLs that do not parse, is returned unchanged.
+func Normalize(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || !strings.EqualFold(u.Scheme, protocol.ALPN) || u.Host == "" {
+		return raw
+	}
+	u.Scheme = protocol.ALPN
+	u.Host = Host(u.Host)
+	u.Path = Path(u.Path)
+	u.RawPath = ""
+	u.Fragment = ""
+	u.RawFragment = ""
+	return u.String()
+}
+
+// Host returns host lowercased, with protocol.DefaultPort added if it has
+// no port.
+func Host(host string) string {
+	name, port, err := net.SplitHostPort(host)
+	if err != nil {
+		name, port = strings.Trim(host, "[]"), strconv.Itoa(protocol.DefaultPort)
+	}
+	return net.JoinHostPort(strings.ToLower(name), port)
+}
+
+// Path returns p with duplicate slashes and dot segments removed. An empty
+// path is "/", and a trailing slash is kept.
+func Path(p string) string {
+	if p == "" {
+		return "/"
+	}
+	cleaned := path.Clean("/" + p)
+	if strings.HasSuffix(p, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	return cleaned
+}
@@ -0,0 +1,53 @@
+package urlnorm
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{"canonical", "mark://host:6309/doc.md", "mark://host:6309/doc.md"},
+		{"default port", "mark://host/doc.md", "mark://host:6309/doc.md"},
+		{"other port", "mark://host:7000/doc.md", "mark://host:7000/doc.md"},
+		{"case", "MARK://Example.ORG/Doc.md", "mark://example.org:6309/Doc.md"},
+		{"empty path", "mark://host", "mark://host:6309/"},
+		{"duplicate slashes", "mark://host//a///b.md", "mark://host:6309/a/b.md"},
+		{"dot segments", "mark://host/a/./b/../c.md", "mark://host:6309/a/c.md"},
+		{"above root", "mark://host/../../c.md", "mark://host:6309/c.md"},
+		{"trailing slash kept", "mark://host/docs//", "mark://host:6309/docs/"},
+		{"fragment dropped", "mark://host/doc.md#intro", "mark://host:6309/doc.md"},
+		{"escaping", "mark://host/a%20b.md", "mark://host:6309/a%20b.md"},
+		{"needless escape", "mark://host/%61.md", "mark://host:6309/a.md"},
+		{"ipv6", "mark://[::1]/doc.md", "mark://[::1]:6309/doc.md"},
+		{"other scheme", "https://Example.org//x", "https://Example.org//x"},
+		{"relative", "doc.md", "doc.md"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Normalize(tt.raw); got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+			if got := Normalize(tt.want); got != tt.want {
+				t.Errorf("Normalize(%q) = %q, not idempotent", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHost(t *testing.T) {
+	tests := map[string]string{
+		"host":           "host:6309",
+		"Host:7000":      "host:7000",
+		"[::1]":          "[::1]:6309",
+		"[::1]:7000":     "[::1]:7000",
+		"127.0.0.1":      "127.0.0.1:6309",
+		"EXAMPLE.org:80": "example.org:80",
+	}
+	for in, want := range tests {
+		if got := Host(in); got != want {
+			t.Errorf("Host(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
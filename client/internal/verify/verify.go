@@ -0,0 +1,123 @@
+// Package verify checks a document's version history on the client,
+// independently of the server's chain-valid claim.
+//
+// For every version the server reports an etag (SHA-256 of the raw version
+// file), a content-hash (SHA-256 of the body) and, from v2 on, the
+// previous-hash recorded in the store frontmatter. Verification recomputes
+// the content hash from the body, rebuilds the raw version file from the
+// body and metadata to confirm the etag, and checks that each previous-hash
+// names the version before it.
+package verify
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/latebit/demarkus/protocol"
+)
+
+// Version is one fetched version of a document.
+type Version struct {
+	Number   int
+	Body     string
+	Metadata map[string]string
+}
+
+// Result is the outcome of verifying a single version.
+type Result struct {
+	Version  int
+	Problems []string
+}
+
+// OK reports whether the version passed every check.
+func (r Result) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// Chain verifies versions, which must be sorted oldest first. It returns
+// one result per version.
+func Chain(versions []Version) []Result {
+	results := make([]Result, len(versions))
+	for i, v := range versions {
+		r := Result{Version: v.Number}
+		if v.Number != i+1 {
+			r.Problems = append(r.Problems, fmt.Sprintf("expected v%d, got v%d", i+1, v.Number))
+		}
+
+		if want := v.Metadata["content-hash"]; want == "" {
+			r.Problems = append(r.Problems, "missing content-hash")
+		} else if got := "sha256-" + hashHex([]byte(v.Body)); got != want {
+			r.Problems = append(r.Problems, "content-hash does not match body")
+		}
+
+		etag := v.Metadata["etag"]
+		if etag == "" {
+			r.Problems = append(r.Problems, "missing etag")
+		} else if !matchesRaw(v, etag) {
+			r.Problems = append(r.Problems, "etag does not match reconstructed version file")
+		}
+
+		prev := v.Metadata["previous-hash"]
+		switch {
+		case i == 0 && prev != "":
+			r.Problems = append(r.Problems, "genesis version has a previous-hash")
+		case i > 0 && prev == "":
+			r.Problems = append(r.Problems, "missing previous-hash")
+		case i > 0 && prev != "sha256-"+versions[i-1].Metadata["etag"]:
+			r.Problems = append(r.Problems, fmt.Sprintf("previous-hash does not match v%d", versions[i-1].Number))
+		}
+
+		results[i] = r
+	}
+	return results
+}
+
+// matchesRaw reports whether any plausible on-disk encoding of v hashes to
+// etag. The archived flag is not exposed over the wire, so both values are
+// tried, as is the layout of v1 files migrated from flat documents.
+func matchesRaw(v Version, etag string) bool {
+	for _, raw := range candidates(v) {
+		if hashHex([]byte(raw)) == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// candidates returns the possible raw version files for v.
+func candidates(v Version) []string {
+	var publisher []string
+	for _, k := range slices.Sorted(maps.Keys(v.Metadata)) {
+		if !protocol.IsServerKey(k) {
+			publisher = append(publisher, "meta."+k+": "+v.Metadata[k]+"\n")
+		}
+	}
+	version := "version: " + strconv.Itoa(v.Number) + "\n"
+	var prev string
+	if p := v.Metadata["previous-hash"]; p != "" {
+		prev = "previous-hash: " + p + "\n"
+	}
+
+	var out []string
+	for _, archived := range []string{"false", "true"} {
+		out = append(out, "---\n"+version+"archived: "+archived+"\n"+prev+strings.Join(publisher, "")+"---\n"+v.Body)
+	}
+	if v.Number == 1 && prev == "" && len(publisher) == 0 {
+		out = append(out,
+			"---\n"+version+"---\n"+v.Body,
+			"---\n"+version+"archived: true\n---\n"+v.Body,
+			"---\n"+version+"archived: false\n---\n"+v.Body,
+		)
+	}
+	return out
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
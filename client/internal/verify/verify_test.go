@@ -0,0 +1,89 @@
+package verify
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// buildChain mimics the server store: each raw version file carries store
+// frontmatter and the hash of its predecessor.
+func buildChain(t *testing.T, bodies ...string) []Version {
+	t.Helper()
+	var versions []Version
+	prevRaw := ""
+	for i, body := range bodies {
+		n := i + 1
+		var fm strings.Builder
+		fmt.Fprintf(&fm, "---\nversion: %d\narchived: false\n", n)
+		meta := map[string]string{}
+		if n > 1 {
+			meta["previous-hash"] = "sha256-" + hashHex([]byte(prevRaw))
+			fmt.Fprintf(&fm, "previous-hash: %s\n", meta["previous-hash"])
+		}
+		fm.WriteString("meta.title: Doc\n---\n")
+		raw := fm.String() + body
+
+		meta["etag"] = hashHex([]byte(raw))
+		meta["content-hash"] = "sha256-" + hashHex([]byte(body))
+		meta["version"] = fmt.Sprint(n)
+		meta["modified"] = "2025-01-01T00:00:00Z"
+		meta["title"] = "Doc"
+		versions = append(versions, Version{Number: n, Body: body, Metadata: meta})
+		prevRaw = raw
+	}
+	return versions
+}
+
+func TestChainValid(t *testing.T) {
+	for _, r := range Chain(buildChain(t, "# One\n", "# Two\n", "# Three\n")) {
+		if !r.OK() {
+			t.Errorf("v%d: unexpected problems %v", r.Version, r.Problems)
+		}
+	}
+}
+
+func TestChainMigratedGenesis(t *testing.T) {
+	raw := "---\nversion: 1\n---\n# Flat\n"
+	v := Version{Number: 1, Body: "# Flat\n", Metadata: map[string]string{
+		"etag":         hashHex([]byte(raw)),
+		"content-hash": "sha256-" + hashHex([]byte("# Flat\n")),
+	}}
+	if r := Chain([]Version{v})[0]; !r.OK() {
+		t.Errorf("migrated v1: %v", r.Problems)
+	}
+}
+
+func TestChainTampered(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(vs []Version)
+		version int
+		want    string
+	}{
+		{"body", func(vs []Version) { vs[1].Body = "# Evil\n" }, 2, "content-hash"},
+		{"etag", func(vs []Version) { vs[0].Metadata["etag"] = strings.Repeat("0", 64) }, 1, "etag"},
+		{"link", func(vs []Version) { vs[2].Metadata["previous-hash"] = "sha256-" + strings.Repeat("0", 64) }, 3, "previous-hash does not match v2"},
+		{"metadata", func(vs []Version) { vs[1].Metadata["title"] = "Other" }, 2, "etag"},
+		{"genesis link", func(vs []Version) { vs[0].Metadata["previous-hash"] = "sha256-x" }, 1, "genesis"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vs := buildChain(t, "# One\n", "# Two\n", "# Three\n")
+			tt.mutate(vs)
+			results := Chain(vs)
+			r := results[tt.version-1]
+			if r.OK() || !strings.Contains(strings.Join(r.Problems, "; "), tt.want) {
+				t.Errorf("v%d problems = %v, want one containing %q", tt.version, r.Problems, tt.want)
+			}
+		})
+	}
+}
+
+func TestChainGap(t *testing.T) {
+	vs := buildChain(t, "# One\n", "# Two\n")
+	vs[1].Number = 3
+	if r := Chain(vs)[1]; r.OK() {
+		t.Error("expected a problem for a missing version")
+	}
+}
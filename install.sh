@@ -612,12 +612,13 @@ Description=Demarkus Mark Protocol Server
 After=network.target
 
 [Service]
-Type=simple
+Type=notify
 User=${SERVICE_NAME}
 ExecStart=${INSTALL_DIR}/demarkus-server
 ${env_lines}
 Restart=on-failure
 RestartSec=5
+WatchdogSec=30
 
 [Install]
 WantedBy=multi-user.target
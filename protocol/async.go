@@ -0,0 +1,34 @@
+package protocol
+
+// A PUBLISH whose body takes long to check and write, such as a large one
+// on a server with a content scanner, can ask to be answered as soon as it
+// is queued:
+//
+//	PUBLISH /big.md MARK/1.3
+//	---
+//	auth: <token>
+//	async: true
+//	---
+//
+// A server that queues it answers "accepted", naming the job in "job",
+// and writes it in the background. The client then asks for the outcome
+// with JOB on the same path, with the same token:
+//
+//	JOB /big.md MARK/1.3
+//	---
+//	auth: <token>
+//	job: <id>
+//	---
+//
+// JOB answers "accepted" while the write is pending, and then the
+// response the PUBLISH would have had. A server that does not queue
+// writes answers the PUBLISH itself, as if async were absent.
+
+const (
+	// MetaAsync is the PUBLISH metadata key asking to be answered before
+	// the document is written.
+	MetaAsync = "async"
+	// MetaJob names the job of an accepted PUBLISH, in the accepted
+	// response and in the JOB request asking about it.
+	MetaJob = "job"
+)
@@ -0,0 +1,137 @@
+package protocol
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A BATCH request carries several requests in one stream, saving a stream
+// per document when a client fetches many at once. Its body is a sequence
+// of records, each a header line giving an ID chosen by the client and the
+// length in bytes of the message that follows:
+//
+//	BATCH / MARK/1.2
+//	a 16
+//	FETCH /index.md
+//	b 16
+//	FETCH /about.md
+//
+// Each message is a complete request, as it would be sent on a stream of
+// its own. The response body holds one record per request, with the same
+// ID and a complete response as its message, in the order the server
+// finished them rather than the order they were sent. Servers speaking
+// BatchProtocol or later accept BATCH.
+//
+// A BATCH sent with "atomic: true" instead carries PUBLISH requests that
+// are applied together: either every document gets its new version or
+// none does. The response holds a record per request in the order they
+// were sent. If one fails, nothing is published, and the response has its
+// status, names it in "failed-request" and holds only its record.
+
+// BatchProtocol is the first protocol version with BATCH.
+const BatchProtocol = "MARK/1.2"
+
+// MaxBatchSize is the most requests one BATCH may carry.
+const MaxBatchSize = 32
+
+// maxBatchIDLength is the longest record ID.
+const maxBatchIDLength = 64
+
+// BatchVerbs are the verbs a BATCH may carry: reads only, so that a batch
+// never has to be partly undone.
+var BatchVerbs = map[string]bool{
+	VerbFetch:     true,
+	VerbInfo:      true,
+	VerbList:      true,
+	VerbVersions:  true,
+	VerbBacklinks: true,
+}
+
+// MetaAtomic is the BATCH metadata key asking for an atomic batch.
+const MetaAtomic = "atomic"
+
+// MetaFailedRequest is the response metadata key naming the request that
+// failed an atomic batch.
+const MetaFailedRequest = "failed-request"
+
+// AtomicBatchVerbs are the verbs an atomic batch may carry.
+var AtomicBatchVerbs = map[string]bool{
+	VerbPublish: true,
+}
+
+// SupportsBatch reports whether a peer speaking proto accepts BATCH.
+func SupportsBatch(proto string) bool {
+	return NegotiateProtocol(proto, BatchProtocol) == BatchProtocol
+}
+
+// BatchRecord is one message of a batch: a request in a BATCH body, or the
+// response to it.
+type BatchRecord struct {
+	ID      string
+	Message string
+}
+
+// FormatBatch returns the body of a batch holding records, in order.
+func FormatBatch(records []BatchRecord) (string, error) {
+	var b strings.Builder
+	for _, r := range records {
+		if err := checkBatchID(r.ID); err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "%s %d\n%s", r.ID, len(r.Message), r.Message)
+	}
+	return b.String(), nil
+}
+
+// ParseBatch splits the body of a batch into its records. It fails for
+// malformed or truncated records, repeated IDs, and more than
+// MaxBatchSize records.
+func ParseBatch(body string) ([]BatchRecord, error) {
+	var records []BatchRecord
+	seen := make(map[string]bool)
+	for body != "" {
+		header, rest, ok := strings.Cut(body, "\n")
+		if !ok {
+			return nil, fmt.Errorf("batch record %d: unterminated header", len(records)+1)
+		}
+		id, size, ok := strings.Cut(header, " ")
+		if !ok {
+			return nil, fmt.Errorf("batch record %d: want ID LENGTH, got %q", len(records)+1, header)
+		}
+		if err := checkBatchID(id); err != nil {
+			return nil, err
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("batch record ID %q repeated", id)
+		}
+		seen[id] = true
+		n, err := strconv.Atoi(size)
+		if err != nil || n < 0 || strconv.Itoa(n) != size {
+			return nil, fmt.Errorf("batch record %q: invalid length %q", id, size)
+		}
+		if n > len(rest) {
+			return nil, fmt.Errorf("batch record %q: %d bytes announced, %d left", id, n, len(rest))
+		}
+		if len(records) == MaxBatchSize {
+			return nil, fmt.Errorf("batch holds more than %d records", MaxBatchSize)
+		}
+		records = append(records, BatchRecord{ID: id, Message: rest[:n]})
+		body = rest[n:]
+	}
+	return records, nil
+}
+
+// checkBatchID reports whether id may identify a record: 1 to
+// maxBatchIDLength letters, digits, dots, hyphens or underscores.
+func checkBatchID(id string) error {
+	if id == "" || len(id) > maxBatchIDLength {
+		return fmt.Errorf("batch record ID %q: want 1 to %d characters", id, maxBatchIDLength)
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' || r == '_') {
+			return fmt.Errorf("batch record ID %q: invalid character %q", id, r)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package protocol
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBatchRoundTrip(t *testing.T) {
+	records := []BatchRecord{
+		{ID: "a", Message: "FETCH /index.md\n"},
+		{ID: "doc-2_v.1", Message: "FETCH /notes.md\n---\nif-none-match: abc\n---\n"},
+		{ID: "empty", Message: ""},
+	}
+	body, err := FormatBatch(records)
+	if err != nil {
+		t.Fatalf("FormatBatch: %v", err)
+	}
+	if !strings.HasPrefix(body, "a 16\nFETCH /index.md\ndoc-2_v.1 ") {
+		t.Errorf("body = %q", body)
+	}
+	got, err := ParseBatch(body)
+	if err != nil {
+		t.Fatalf("ParseBatch: %v", err)
+	}
+	if len(got) != len(records) {
+		t.Fatalf("got %d records, want %d", len(got), len(records))
+	}
+	for i := range records {
+		if got[i] != records[i] {
+			t.Errorf("record %d = %+v, want %+v", i, got[i], records[i])
+		}
+	}
+	if got, err := ParseBatch(""); err != nil || len(got) != 0 {
+		t.Errorf("empty batch = %v, %v", got, err)
+	}
+}
+
+func TestParseBatchRejects(t *testing.T) {
+	full := strings.Repeat("x 0\n", 1)
+	for i := range MaxBatchSize {
+		full += "r" + strings.Repeat("i", i) + " 0\n"
+	}
+	tests := map[string]string{
+		"no header newline": "a 16",
+		"no length":         "a\nFETCH /\n",
+		"bad length":        "a x\nFETCH /\n",
+		"signed length":     "a +8\nFETCH /\n",
+		"padded length":     "a 08\nFETCH /\n",
+		"truncated":         "a 99\nFETCH /\n",
+		"bad id":            "a/b 8\nFETCH /\n",
+		"empty id":          " 8\nFETCH /\n",
+		"repeated id":       "a 0\na 0\n",
+		"too many records":  full,
+	}
+	for name, body := range tests {
+		if _, err := ParseBatch(body); err == nil {
+			t.Errorf("%s: ParseBatch(%q) succeeded", name, body)
+		}
+	}
+	if _, err := FormatBatch([]BatchRecord{{ID: "a b", Message: "x"}}); err == nil {
+		t.Error("FormatBatch accepted an ID with a space")
+	}
+}
+
+func TestSupportsBatch(t *testing.T) {
+	for proto, want := range map[string]bool{
+		"":         false,
+		"MARK/1.1": false,
+		"MARK/1.2": true,
+		"MARK/1.9": true,
+		"MARK/2.0": false,
+		"bogus":    false,
+	} {
+		if got := SupportsBatch(proto); got != want {
+			t.Errorf("SupportsBatch(%q) = %v, want %v", proto, got, want)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+// Package conformance is a golden wire-format test suite for Mark Protocol
+// implementations. The cases live in fixtures/ as raw message bytes with
+// their expected parse results, so implementations in other languages can
+// use the same files; this package embeds them and runs them against a Go
+// implementation.
+//
+//	func TestConformance(t *testing.T) {
+//		conformance.Run(t, conformance.Implementation{
+//			ParseRequest:  myParseRequest,
+//			ParseResponse: myParseResponse,
+//		})
+//	}
+package conformance
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"maps"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/latebit/demarkus/protocol"
+)
+
+//go:embed fixtures
+var fixtures embed.FS
+
+// Kinds of case.
+const (
+	KindRequest  = "request"
+	KindResponse = "response"
+)
+
+// Case is one fixture: a message on the wire and how it must parse.
+type Case struct {
+	Name string
+	Kind string // KindRequest or KindResponse
+	Wire []byte
+	Want Expected
+}
+
+// Expected is the parse result of a fixture, as stored in its .json file.
+type Expected struct {
+	Error    bool              `json:"error,omitempty"`
+	Verb     string            `json:"verb,omitempty"`
+	Path     string            `json:"path,omitempty"`
+	Proto    string            `json:"proto,omitempty"`
+	Status   string            `json:"status,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Body     string            `json:"body,omitempty"`
+	Trailer  map[string]string `json:"trailer,omitempty"`
+}
+
+// Implementation is the code under test. Nil functions are not tested.
+type Implementation struct {
+	ParseRequest  func(wire []byte) (protocol.Request, error)
+	ParseResponse func(wire []byte) (protocol.Response, error)
+
+	// Encoders are checked by round trip: the expected result of each
+	// valid case is encoded, parsed back, and must be unchanged.
+	WriteRequest  func(protocol.Request) ([]byte, error)
+	WriteResponse func(protocol.Response) ([]byte, error)
+}
+
+// Reference returns this module's own implementation.
+func Reference() Implementation {
+	return Implementation{
+		ParseRequest: func(wire []byte) (protocol.Request, error) {
+			return protocol.ParseRequest(bytes.NewReader(wire))
+		},
+		ParseResponse: func(wire []byte) (protocol.Response, error) {
+			return protocol.ParseResponse(bytes.NewReader(wire))
+		},
+		WriteRequest: func(req protocol.Request) ([]byte, error) {
+			var buf bytes.Buffer
+			_, err := req.WriteTo(&buf)
+			return buf.Bytes(), err
+		},
+		WriteResponse: func(resp protocol.Response) ([]byte, error) {
+			var buf bytes.Buffer
+			_, err := resp.WriteTo(&buf)
+			return buf.Bytes(), err
+		},
+	}
+}
+
+// Cases returns every fixture, requests first, each group sorted by name.
+func Cases() ([]Case, error) {
+	var cases []Case
+	for _, kind := range []string{KindRequest, KindResponse} {
+		dir := "fixtures/" + kind + "s"
+		entries, err := fs.ReadDir(fixtures, dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			name, ok := strings.CutSuffix(e.Name(), ".mark")
+			if !ok {
+				continue
+			}
+			wire, err := fixtures.ReadFile(path.Join(dir, e.Name()))
+			if err != nil {
+				return nil, err
+			}
+			raw, err := fixtures.ReadFile(path.Join(dir, name+".json"))
+			if err != nil {
+				return nil, fmt.Errorf("%s/%s: missing expected result: %w", kind, name, err)
+			}
+			c := Case{Name: name, Kind: kind, Wire: wire}
+			if err := json.Unmarshal(raw, &c.Want); err != nil {
+				return nil, fmt.Errorf("%s/%s.json: %w", kind, name, err)
+			}
+			cases = append(cases, c)
+		}
+	}
+	return cases, nil
+}
+
+// Run runs every case against impl, one subtest per case and check.
+func Run(t *testing.T, impl Implementation) {
+	t.Helper()
+	cases, err := Cases()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cases {
+		t.Run(c.Kind+"/"+c.Name, func(t *testing.T) {
+			if err := Check(c, impl); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// Check runs one case against impl. It returns nil when the case passes or
+// impl has nothing to test for it.
+func Check(c Case, impl Implementation) error {
+	switch c.Kind {
+	case KindRequest:
+		return checkRequest(c, impl)
+	case KindResponse:
+		return checkResponse(c, impl)
+	}
+	return fmt.Errorf("unknown case kind %q", c.Kind)
+}
+
+func checkRequest(c Case, impl Implementation) error {
+	if impl.ParseRequest != nil {
+		req, err := impl.ParseRequest(c.Wire)
+		if err := compare(c.Want, requestResult(req), err); err != nil {
+			return fmt.Errorf("parse: %w", err)
+		}
+	}
+	if impl.WriteRequest == nil || c.Want.Error {
+		return nil
+	}
+	wire, err := impl.WriteRequest(protocol.Request{
+		Verb: c.Want.Verb, Path: c.Want.Path, Proto: c.Want.Proto, Metadata: c.Want.Metadata, Body: c.Want.Body,
+	})
+	if err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+	req, err := protocol.ParseRequest(bytes.NewReader(wire))
+	if err := compare(c.Want, requestResult(req), err); err != nil {
+		return fmt.Errorf("round trip of %q: %w", wire, err)
+	}
+	return nil
+}
+
+func checkResponse(c Case, impl Implementation) error {
+	if impl.ParseResponse != nil {
+		resp, err := impl.ParseResponse(c.Wire)
+		if err := compare(c.Want, responseResult(resp), err); err != nil {
+			return fmt.Errorf("parse: %w", err)
+		}
+	}
+	// A response without a status cannot be encoded: every encoded
+	// response carries one.
+	if impl.WriteResponse == nil || c.Want.Error || c.Want.Status == "" {
+		return nil
+	}
+	wire, err := impl.WriteResponse(protocol.Response{
+		Status: c.Want.Status, Metadata: c.Want.Metadata, Body: c.Want.Body, Trailer: c.Want.Trailer,
+	})
+	if err != nil {
+		return fmt.Errorf("encode: %w", err)
+	}
+	resp, err := protocol.ParseResponse(bytes.NewReader(wire))
+	if err := compare(c.Want, responseResult(resp), err); err != nil {
+		return fmt.Errorf("round trip of %q: %w", wire, err)
+	}
+	return nil
+}
+
+func requestResult(req protocol.Request) Expected {
+	return Expected{Verb: req.Verb, Path: req.Path, Proto: req.Proto, Metadata: req.Metadata, Body: req.Body}
+}
+
+func responseResult(resp protocol.Response) Expected {
+	return Expected{Status: resp.Status, Metadata: resp.Metadata, Body: resp.Body, Trailer: resp.Trailer}
+}
+
+// compare reports how a parse result differs from want. A nil and an empty
+// metadata or trailer map are equal.
+func compare(want, got Expected, err error) error {
+	switch {
+	case want.Error && err == nil:
+		return fmt.Errorf("accepted a message that must be rejected: %+v", got)
+	case want.Error:
+		return nil
+	case err != nil:
+		return fmt.Errorf("rejected a valid message: %w", err)
+	}
+	var diffs []string
+	if got.Verb != want.Verb {
+		diffs = append(diffs, fmt.Sprintf("verb = %q, want %q", got.Verb, want.Verb))
+	}
+	if got.Path != want.Path {
+		diffs = append(diffs, fmt.Sprintf("path = %q, want %q", got.Path, want.Path))
+	}
+	if got.Proto != want.Proto {
+		diffs = append(diffs, fmt.Sprintf("proto = %q, want %q", got.Proto, want.Proto))
+	}
+	if got.Status != want.Status {
+		diffs = append(diffs, fmt.Sprintf("status = %q, want %q", got.Status, want.Status))
+	}
+	if !maps.Equal(got.Metadata, want.Metadata) {
+		diffs = append(diffs, fmt.Sprintf("metadata = %v, want %v", got.Metadata, want.Metadata))
+	}
+	if got.Body != want.Body {
+		diffs = append(diffs, fmt.Sprintf("body = %q, want %q", got.Body, want.Body))
+	}
+	if !maps.Equal(got.Trailer, want.Trailer) {
+		diffs = append(diffs, fmt.Sprintf("trailer = %v, want %v", got.Trailer, want.Trailer))
+	}
+	if len(diffs) > 0 {
+		return fmt.Errorf("%s", strings.Join(diffs, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package conformance
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/latebit/demarkus/protocol"
+)
+
+func TestReference(t *testing.T) {
+	Run(t, Reference())
+}
+
+func TestCases(t *testing.T) {
+	cases, err := Cases()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := map[string]int{}
+	rejected := 0
+	for _, c := range cases {
+		kinds[c.Kind]++
+		if c.Want.Error {
+			rejected++
+		}
+	}
+	if kinds[KindRequest] == 0 || kinds[KindResponse] == 0 || rejected == 0 {
+		t.Errorf("cases by kind = %v, %d rejected; want both kinds and some rejections", kinds, rejected)
+	}
+}
+
+func TestCheckCatchesDifferences(t *testing.T) {
+	cases, err := Cases()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A parser that ignores frontmatter entirely.
+	lax := Implementation{
+		ParseResponse: func(wire []byte) (protocol.Response, error) {
+			return protocol.Response{Body: string(wire)}, nil
+		},
+	}
+	var failures []string
+	for _, c := range cases {
+		if err := Check(c, lax); err != nil {
+			failures = append(failures, c.Name)
+		}
+	}
+	for _, name := range []string{"ok-document", "error-unclosed-frontmatter"} {
+		if !strings.Contains(strings.Join(failures, " "), name) {
+			t.Errorf("lax parser passed %s; failures = %v", name, failures)
+		}
+	}
+
+	// An encoder that drops the body fails the round trip.
+	lossy := Implementation{
+		WriteResponse: func(resp protocol.Response) ([]byte, error) {
+			var buf bytes.Buffer
+			resp.Body = ""
+			_, err := resp.WriteTo(&buf)
+			return buf.Bytes(), err
+		},
+	}
+	for _, c := range cases {
+		if c.Name == "ok-document" {
+			if err := Check(c, lossy); err == nil || !strings.Contains(err.Error(), "round trip") {
+				t.Errorf("Check = %v, want round trip failure", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package protocol
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"maps"
+	"strings"
+)
+
+// A client that can decompress response bodies lists the codings it reads
+// in the accept-encoding request key, most preferred first. The server may
+// then send the body compressed with one of them, naming it in the
+// content-encoding response key:
+//
+//	---
+//	status: ok
+//	content-encoding: gzip
+//	---
+//	<gzip stream>
+//
+// Metadata, etag and content-hash keep describing the uncompressed body;
+// only a trailer's body-hash covers the body as sent.
+//
+// Bodies are raw bytes: an image or other asset published with a
+// content-type is sent as it is stored. A client that can only carry text
+// lists base64 instead, and gets the body in standard base64 (RFC 4648)
+// whatever its size. A publisher in the same position sends its body in
+// base64 and says so in the body-encoding request key; the server stores
+// the decoded bytes.
+
+const (
+	// EncodingGzip is the gzip content coding (RFC 1952).
+	EncodingGzip = "gzip"
+
+	// EncodingBase64 is the standard base64 coding (RFC 4648), which
+	// frames binary bodies as text.
+	EncodingBase64 = "base64"
+)
+
+// NegotiateEncoding returns the first coding in an accept-encoding value
+// that this package implements, or "" if there is none.
+func NegotiateEncoding(accept string) string {
+	for coding := range strings.SplitSeq(accept, ",") {
+		coding = strings.TrimSpace(coding)
+		for _, known := range []string{EncodingGzip, EncodingBase64} {
+			if strings.EqualFold(coding, known) {
+				return known
+			}
+		}
+	}
+	return ""
+}
+
+// EncodeBody encodes body with coding.
+func EncodeBody(body, coding string) (string, error) {
+	switch coding {
+	case EncodingBase64:
+		return base64.StdEncoding.EncodeToString([]byte(body)), nil
+	case EncodingGzip:
+	default:
+		return "", fmt.Errorf("unsupported content-encoding %q", coding)
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(body)); err != nil {
+		return "", fmt.Errorf("compress body: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return "", fmt.Errorf("compress body: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// Decode returns the response with its body decoded according to its
+// content-encoding, which is removed from the metadata. A response without
+// one is returned as it is. Bodies that decompress to more than
+// MaxBodyLength are rejected, so a small response cannot make the reader
+// allocate without bound.
+func (resp Response) Decode() (Response, error) {
+	coding := resp.Metadata["content-encoding"]
+	if coding == "" {
+		return resp, nil
+	}
+	var data []byte
+	switch coding {
+	case EncodingBase64:
+		var err error
+		if data, err = base64.StdEncoding.DecodeString(resp.Body); err != nil {
+			return Response{}, fmt.Errorf("decode base64 body: %w", err)
+		}
+	case EncodingGzip:
+		zr, err := gzip.NewReader(strings.NewReader(resp.Body))
+		if err != nil {
+			return Response{}, fmt.Errorf("decompress body: %w", err)
+		}
+		if data, err = io.ReadAll(io.LimitReader(zr, MaxBodyLength+1)); err != nil {
+			return Response{}, fmt.Errorf("decompress body: %w", err)
+		}
+		if len(data) > MaxBodyLength {
+			return Response{}, fmt.Errorf("decompressed body exceeds limit of %d bytes", MaxBodyLength)
+		}
+	default:
+		return Response{}, fmt.Errorf("unsupported content-encoding %q", coding)
+	}
+
+	resp.Metadata = maps.Clone(resp.Metadata)
+	delete(resp.Metadata, "content-encoding")
+	resp.Body = string(data)
+	return resp, nil
+}
+
+// DecodeReader returns a reader of body decoded according to coding, the
+// content-encoding of its response, for a body read with
+// ParseResponseHeader. An empty coding returns body as it is. Unlike
+// Response.Decode it does not limit the decoded length; the reader
+// decides how much to read.
+func DecodeReader(body io.Reader, coding string) (io.Reader, error) {
+	switch coding {
+	case "":
+		return body, nil
+	case EncodingBase64:
+		return base64.NewDecoder(base64.StdEncoding, body), nil
+	case EncodingGzip:
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("decompress body: %w", err)
+		}
+		return zr, nil
+	default:
+		return nil, fmt.Errorf("unsupported content-encoding %q", coding)
+	}
+}
+
+// Decode returns the request with its body decoded according to its
+// body-encoding, which is removed from the metadata. A request without one
+// is returned as it is.
+func (req Request) Decode() (Request, error) {
+	coding := req.Metadata["body-encoding"]
+	if coding == "" {
+		return req, nil
+	}
+	if !strings.EqualFold(coding, EncodingBase64) {
+		return Request{}, fmt.Errorf("unsupported body-encoding %q", coding)
+	}
+	data, err := base64.StdEncoding.DecodeString(req.Body)
+	if err != nil {
+		return Request{}, fmt.Errorf("decode base64 body: %w", err)
+	}
+	req.Metadata = maps.Clone(req.Metadata)
+	delete(req.Metadata, "body-encoding")
+	req.Body = string(data)
+	return req, nil
+}
@@ -0,0 +1,131 @@
+package protocol
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := map[string]string{
+		"":                   "",
+		"gzip":               EncodingGzip,
+		"zstd, gzip":         EncodingGzip,
+		" GZIP ":             EncodingGzip,
+		"br, zstd":           "",
+		"base64":             EncodingBase64,
+		"zstd, BASE64, gzip": EncodingBase64,
+	}
+	for accept, want := range tests {
+		if got := NegotiateEncoding(accept); got != want {
+			t.Errorf("NegotiateEncoding(%q) = %q, want %q", accept, got, want)
+		}
+	}
+}
+
+func TestResponseEncodingRoundTrip(t *testing.T) {
+	body := strings.Repeat("# Heading\n\nSome markdown that repeats.\n\n", 200)
+	encoded, err := EncodeBody(body, EncodingGzip)
+	if err != nil {
+		t.Fatalf("EncodeBody: %v", err)
+	}
+	if len(encoded) >= len(body) {
+		t.Errorf("encoded %d bytes, no smaller than %d", len(encoded), len(body))
+	}
+	original := Response{
+		Status:   StatusOK,
+		Metadata: map[string]string{"version": "1", "content-encoding": EncodingGzip},
+		Body:     encoded,
+	}
+	original.Trailer = map[string]string{TrailerBodyHash: BodyHash(encoded)}
+
+	var buf bytes.Buffer
+	if _, err := original.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+	parsed, err := ParseResponse(&buf)
+	if err != nil {
+		t.Fatalf("ParseResponse: %v", err)
+	}
+	if err := parsed.VerifyTrailer(); err != nil {
+		t.Errorf("VerifyTrailer on the encoded body: %v", err)
+	}
+	decoded, err := parsed.Decode()
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if decoded.Body != body {
+		t.Error("decoded body differs from the original")
+	}
+	if _, ok := decoded.Metadata["content-encoding"]; ok || decoded.Metadata["version"] != "1" {
+		t.Errorf("decoded metadata = %v", decoded.Metadata)
+	}
+	if parsed.Metadata["content-encoding"] != EncodingGzip {
+		t.Error("Decode modified the original response's metadata")
+	}
+
+	plain := Response{Status: StatusOK, Metadata: map[string]string{}, Body: "# Plain\n"}
+	if got, err := plain.Decode(); err != nil || got.Body != plain.Body {
+		t.Errorf("Decode without content-encoding = %q, %v", got.Body, err)
+	}
+}
+
+func TestResponseDecodeRejects(t *testing.T) {
+	bomb, err := EncodeBody(strings.Repeat("a", MaxBodyLength+1), EncodingGzip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name, coding, body string
+	}{
+		{"unknown coding", "zstd", "x"},
+		{"corrupt", EncodingGzip, "not gzip"},
+		{"corrupt base64", EncodingBase64, "not base64!"},
+		{"over the body limit", EncodingGzip, bomb},
+	}
+	for _, tt := range tests {
+		resp := Response{Status: StatusOK, Metadata: map[string]string{"content-encoding": tt.coding}, Body: tt.body}
+		if _, err := resp.Decode(); err == nil {
+			t.Errorf("%s: Decode succeeded", tt.name)
+		}
+	}
+}
+
+func TestBase64RoundTrip(t *testing.T) {
+	asset := "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\xff"
+	encoded, err := EncodeBody(asset, EncodingBase64)
+	if err != nil {
+		t.Fatalf("EncodeBody: %v", err)
+	}
+	if encoded != "iVBORw0KGgoAAAANSUhEUv8=" {
+		t.Errorf("encoded = %q", encoded)
+	}
+
+	resp := Response{Status: StatusOK, Metadata: map[string]string{"content-encoding": EncodingBase64}, Body: encoded}
+	decoded, err := resp.Decode()
+	if err != nil || decoded.Body != asset {
+		t.Errorf("Response.Decode = %q, %v", decoded.Body, err)
+	}
+
+	req := Request{Verb: VerbPublish, Path: "/img/logo.png", Metadata: map[string]string{"body-encoding": EncodingBase64, "content-type": "image/png"}, Body: encoded}
+	got, err := req.Decode()
+	if err != nil {
+		t.Fatalf("Request.Decode: %v", err)
+	}
+	if got.Body != asset {
+		t.Errorf("Request.Decode body = %q", got.Body)
+	}
+	if _, ok := got.Metadata["body-encoding"]; ok || got.Metadata["content-type"] != "image/png" {
+		t.Errorf("Request.Decode metadata = %v", got.Metadata)
+	}
+	if req.Metadata["body-encoding"] != EncodingBase64 {
+		t.Error("Request.Decode modified the original request's metadata")
+	}
+
+	for coding, body := range map[string]string{EncodingGzip: encoded, EncodingBase64: "not base64!"} {
+		req := Request{Verb: VerbPublish, Path: "/a.bin", Metadata: map[string]string{"body-encoding": coding}, Body: body}
+		if _, err := req.Decode(); err == nil {
+			t.Errorf("Request.Decode with body-encoding %q, body %q succeeded", coding, body)
+		}
+	}
+}
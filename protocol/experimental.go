@@ -0,0 +1,38 @@
+package protocol
+
+import "strings"
+
+// Verbs beginning with "X-" and metadata keys beginning with "x-" are
+// reserved for experiments: extensions prototyped outside this package,
+// which the protocol will never define. A parser accepts an experimental
+// verb like any other, so a server can answer it without a new parser;
+// one that does not implement it responds not-implemented. Experimental
+// request keys are neither control metadata a server must understand nor
+// publisher metadata it stores: servers that do not know one ignore it.
+
+// Prefixes of experimental verbs and metadata keys.
+const (
+	ExperimentalVerbPrefix = "X-"
+	ExperimentalKeyPrefix  = "x-"
+)
+
+// IsExperimentalVerb reports whether verb is an experimental verb: "X-"
+// followed by uppercase letters, digits and hyphens, starting with a
+// letter, as in X-SUBSCRIBE.
+func IsExperimentalVerb(verb string) bool {
+	name, ok := strings.CutPrefix(verb, ExperimentalVerbPrefix)
+	if !ok || name == "" || name[0] < 'A' || name[0] > 'Z' {
+		return false
+	}
+	for _, c := range name {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') && c != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// IsExperimentalKey reports whether k is an experimental metadata key.
+func IsExperimentalKey(k string) bool {
+	return strings.HasPrefix(k, ExperimentalKeyPrefix) && len(k) > len(ExperimentalKeyPrefix)
+}
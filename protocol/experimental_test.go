@@ -0,0 +1,55 @@
+package protocol
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsExperimentalVerb(t *testing.T) {
+	for verb, want := range map[string]bool{
+		"X-SUBSCRIBE": true,
+		"X-LOCK-2":    true,
+		"X-A":         true,
+		"X-":          false,
+		"X-2PC":       false,
+		"X-lock":      false,
+		"x-LOCK":      false,
+		"X-LOCK!":     false,
+		"FETCH":       false,
+	} {
+		if got := IsExperimentalVerb(verb); got != want {
+			t.Errorf("IsExperimentalVerb(%q) = %v, want %v", verb, got, want)
+		}
+	}
+}
+
+func TestParseExperimentalRequest(t *testing.T) {
+	req, err := ParseRequest(strings.NewReader("X-SUBSCRIBE /docs/ MARK/1.2\n---\nx-interval: 60\n---\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Verb != "X-SUBSCRIBE" || req.Path != "/docs/" || req.Metadata["x-interval"] != "60" {
+		t.Errorf("ParseRequest = %+v", req)
+	}
+	if err := ValidateRequestMeta(req.Metadata); err != nil {
+		t.Errorf("ValidateRequestMeta: %v", err)
+	}
+	if _, err := ParseRequest(strings.NewReader("X-subscribe /docs/\n")); err == nil {
+		t.Error("lowercase experimental verb accepted")
+	}
+}
+
+func TestExperimentalKeyKind(t *testing.T) {
+	if got := MetaKeyKind("x-interval"); got != KeyExperimental {
+		t.Errorf("MetaKeyKind(x-interval) = %v, want KeyExperimental", got)
+	}
+	if IsControlKey("x-interval") || IsServerKey("x-interval") {
+		t.Error("experimental key classed as control or server metadata")
+	}
+	if err := ValidateResponseMeta(map[string]string{"x-interval": "60"}); err != nil {
+		t.Errorf("ValidateResponseMeta: %v", err)
+	}
+	if got := MetaKeyKind("x-"); got != KeyPublisher {
+		t.Errorf("MetaKeyKind(x-) = %v, want KeyPublisher", got)
+	}
+}
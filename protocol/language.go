@@ -0,0 +1,117 @@
+package protocol
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// A document may have translations stored next to it, named by inserting
+// a language tag before the .md extension: /index.md is the default and
+// /index.de.md and /index.pt-br.md its German and Brazilian Portuguese
+// variants. A client that prefers other languages lists them in
+// "accept-language", as in HTTP:
+//
+//	FETCH /index.md MARK/1.2
+//	---
+//	accept-language: pt-BR, de;q=0.8, en;q=0.5
+//	---
+//
+// A server that understands it serves the first variant that exists, and
+// names its language in "content-language"; with none, it serves the
+// document itself without content-language. Tags are compared without
+// regard to case, and each is tried before the shorter tags it starts
+// with, so pt-BR falls back to pt before de is tried.
+
+const (
+	// MetaAcceptLanguage is the request metadata key listing the
+	// languages a client prefers.
+	MetaAcceptLanguage = "accept-language"
+	// MetaContentLanguage is the response metadata key naming the
+	// language of the variant served.
+	MetaContentLanguage = "content-language"
+)
+
+// maxLanguageTagLength is the longest language tag accepted, as in RFC
+// 5646's guidance for implementations with limited buffers.
+const maxLanguageTagLength = 35
+
+// ValidLanguageTag reports whether tag is a well-formed language tag: a
+// primary subtag of 1 to 8 letters, followed by subtags of 1 to 8 letters
+// or digits, separated by "-".
+func ValidLanguageTag(tag string) bool {
+	if tag == "" || len(tag) > maxLanguageTagLength {
+		return false
+	}
+	for i, sub := range strings.Split(tag, "-") {
+		if sub == "" || len(sub) > 8 {
+			return false
+		}
+		for _, c := range sub {
+			letter := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+			if !letter && (i == 0 || c < '0' || c > '9') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// AcceptedLanguages returns the languages an accept-language value lists,
+// lowercased, in the order a server tries their variants: by weight, then
+// as listed, each tag followed by the shorter tags it starts with. Tags
+// with weight 0, "*" and malformed entries are left out.
+func AcceptedLanguages(accept string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var tags []weighted
+	for entry := range strings.SplitSeq(accept, ",") {
+		tag, params, _ := strings.Cut(entry, ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if !ValidLanguageTag(tag) {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil || f < 0 || f > 1 {
+				continue
+			}
+			q = f
+		}
+		if q > 0 {
+			tags = append(tags, weighted{tag, q})
+		}
+	}
+	slices.SortStableFunc(tags, func(a, b weighted) int { return cmp.Compare(b.q, a.q) })
+
+	var langs []string
+	for _, t := range tags {
+		for tag := t.tag; ; {
+			if !slices.Contains(langs, tag) {
+				langs = append(langs, tag)
+			}
+			i := strings.LastIndex(tag, "-")
+			if i < 0 {
+				break
+			}
+			tag = tag[:i]
+		}
+	}
+	return langs
+}
+
+// LanguageVariant returns the path of the variant of the document at
+// docPath in language tag: /guide/index.md and "de" give
+// /guide/index.de.md. It returns "" for paths that are not markdown
+// documents.
+func LanguageVariant(docPath, tag string) string {
+	stem, ok := strings.CutSuffix(docPath, ".md")
+	if !ok || stem == "" || strings.HasSuffix(stem, "/") {
+		return ""
+	}
+	return stem + "." + strings.ToLower(tag) + ".md"
+}
@@ -0,0 +1,68 @@
+package protocol
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestValidLanguageTag(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want bool
+	}{
+		{"de", true},
+		{"pt-BR", true},
+		{"zh-Hant-TW", true},
+		{"es-419", true},
+		{"", false},
+		{"419", false},
+		{"de-", false},
+		{"de--at", false},
+		{"de_AT", false},
+		{"toolongtag", false},
+		{"en-" + strings.Repeat("a-", 17), false},
+		{"../x", false},
+	}
+	for _, tt := range tests {
+		if got := ValidLanguageTag(tt.tag); got != tt.want {
+			t.Errorf("ValidLanguageTag(%q) = %v, want %v", tt.tag, got, tt.want)
+		}
+	}
+}
+
+func TestAcceptedLanguages(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   []string
+	}{
+		{"", nil},
+		{"de", []string{"de"}},
+		{"pt-BR, de;q=0.8, en;q=0.5", []string{"pt-br", "pt", "de", "en"}},
+		{"en;q=0.5, de", []string{"de", "en"}},
+		{"zh-Hant-TW", []string{"zh-hant-tw", "zh-hant", "zh"}},
+		{"de-AT, de", []string{"de-at", "de"}},
+		{"fr;q=0, *, x_y, it;q=2, es", []string{"es"}},
+	}
+	for _, tt := range tests {
+		if got := AcceptedLanguages(tt.accept); !slices.Equal(got, tt.want) {
+			t.Errorf("AcceptedLanguages(%q) = %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestLanguageVariant(t *testing.T) {
+	tests := []struct {
+		path, tag, want string
+	}{
+		{"/index.md", "de", "/index.de.md"},
+		{"/guide/setup.md", "pt-BR", "/guide/setup.pt-br.md"},
+		{"/logo.png", "de", ""},
+		{"/.md", "de", ""},
+	}
+	for _, tt := range tests {
+		if got := LanguageVariant(tt.path, tt.tag); got != tt.want {
+			t.Errorf("LanguageVariant(%q, %q) = %q, want %q", tt.path, tt.tag, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package protocol
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A LIST request with "format: structured" metadata gets its entries as
+// data rather than as a markdown page: a YAML stream with one mapping per
+// entry, each opened by a "---" line, in the order the markdown listing
+// would give them:
+//
+//	---
+//	name: guide
+//	type: directory
+//	---
+//	name: index.md
+//	type: document
+//	size: 1234
+//	modified: 2026-01-02T15:04:05Z
+//	version: 3
+//
+// The response carries ListingContentType as its content-type. A server
+// that does not know the format ignores it and answers with markdown, so
+// clients tell the two apart by content-type.
+
+// FormatStructured is the format metadata value asking LIST for a
+// structured listing.
+const FormatStructured = "structured"
+
+// ListingContentType is the content-type of a structured listing.
+const ListingContentType = "application/yaml"
+
+// A LIST may also ask for one page of a large directory: "offset" entries
+// are skipped and at most "limit" returned, in the order "sort" names.
+// When entries remain after the page, the response's "next-offset" is the
+// offset of the next one.
+
+// Sort orders of a listing.
+const (
+	SortName     = "name"     // by name, the default
+	SortModified = "modified" // most recently modified first
+)
+
+// Entry types of a structured listing.
+const (
+	EntryDirectory = "directory"
+	EntryDocument  = "document"
+	EntryFile      = "file" // a file the server does not serve, such as one without version history
+)
+
+// ListEntry is one entry of a structured listing. Size, Modified and
+// Version are set for documents only; Size is the current version's body
+// in bytes.
+type ListEntry struct {
+	Name     string    `yaml:"name"`
+	Type     string    `yaml:"type"`
+	Size     int       `yaml:"size,omitempty"`
+	Modified time.Time `yaml:"modified,omitempty"`
+	Version  int       `yaml:"version,omitempty"`
+}
+
+// FormatListing returns the body of a structured listing of entries.
+func FormatListing(entries []ListEntry) (string, error) {
+	var b strings.Builder
+	for _, e := range entries {
+		e.Modified = e.Modified.UTC().Truncate(time.Second)
+		data, err := yaml.Marshal(e)
+		if err != nil {
+			return "", fmt.Errorf("listing entry %q: %w", e.Name, err)
+		}
+		b.WriteString("---\n")
+		b.Write(data)
+	}
+	return b.String(), nil
+}
+
+// ParseListing parses the body of a structured listing. Every entry must
+// have a name without slashes and a type; unknown keys are ignored, so
+// servers may add fields.
+func ParseListing(body string) ([]ListEntry, error) {
+	var entries []ListEntry
+	dec := yaml.NewDecoder(bytes.NewReader([]byte(body)))
+	for {
+		var e ListEntry
+		err := dec.Decode(&e)
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("listing entry %d: %w", len(entries)+1, err)
+		}
+		if e.Name == "" || strings.Contains(e.Name, "/") {
+			return nil, fmt.Errorf("listing entry %d: invalid name %q", len(entries)+1, e.Name)
+		}
+		if e.Type == "" {
+			return nil, fmt.Errorf("listing entry %q: missing type", e.Name)
+		}
+		entries = append(entries, e)
+	}
+}
@@ -0,0 +1,50 @@
+package protocol
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListingRoundTrip(t *testing.T) {
+	entries := []ListEntry{
+		{Name: "guide", Type: EntryDirectory},
+		{Name: "index.md", Type: EntryDocument, Size: 1234, Modified: time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC), Version: 3},
+		{Name: "notes: draft #1.md", Type: EntryFile},
+	}
+	body, err := FormatListing(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(body, "---\nname: guide\ntype: directory\n---\n") {
+		t.Errorf("body = %q", body)
+	}
+	if !strings.Contains(body, "modified: 2026-01-02T15:04:05Z\n") {
+		t.Errorf("modified not RFC 3339: %q", body)
+	}
+	got, err := ParseListing(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, entries) {
+		t.Errorf("ParseListing = %+v, want %+v", got, entries)
+	}
+
+	if got, err := ParseListing(""); err != nil || len(got) != 0 {
+		t.Errorf("empty listing = %+v, %v", got, err)
+	}
+}
+
+func TestParseListingInvalid(t *testing.T) {
+	for name, body := range map[string]string{
+		"no name":  "---\ntype: document\n",
+		"slash":    "---\nname: a/b.md\ntype: document\n",
+		"no type":  "---\nname: a.md\n",
+		"not yaml": "---\nname: [a\n",
+	} {
+		if _, err := ParseListing(body); err == nil {
+			t.Errorf("%s: ParseListing succeeded", name)
+		}
+	}
+}
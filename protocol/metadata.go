@@ -0,0 +1,251 @@
+package protocol
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// KeyKind classifies a metadata key by who may set it.
+type KeyKind int
+
+const (
+	// KeyPublisher is free-form author metadata stored with a document.
+	// Any valid key not defined by the protocol is publisher metadata.
+	KeyPublisher KeyKind = iota
+
+	// KeyControl is request metadata consumed by the server and never stored.
+	KeyControl
+
+	// KeyServer is response metadata the server sets on a document's own
+	// responses, or on those to writes. Publishers cannot set it.
+	KeyServer
+
+	// KeyExperimental is an experimental key (see IsExperimentalKey),
+	// understood only by the peers that agreed on it and never stored.
+	KeyExperimental
+
+	// KeyEcho is request metadata the server copies into its response,
+	// such as request-id. It is never stored.
+	KeyEcho
+
+	// KeyResponse is response metadata of listings, searches, redirects
+	// and other responses that carry no document metadata. A document may
+	// use the same key as publisher metadata, since the two never meet in
+	// one response.
+	KeyResponse
+)
+
+// metaKeys is the registry of metadata keys defined by the protocol.
+var metaKeys = map[string]KeyKind{
+	"accept":            KeyControl,
+	"accept-trailers":   KeyControl,
+	"accept-encoding":   KeyControl,
+	"body-encoding":     KeyControl,
+	"range":             KeyControl,
+	"auth":              KeyControl,
+	"expected-version":  KeyControl,
+	"if-none-match":     KeyControl,
+	"if-modified-since": KeyControl,
+	"query":             KeyControl,
+	"limit":             KeyControl,
+	"destination":       KeyControl,
+	"redirect":          KeyControl,
+	"format":            KeyControl,
+	"offset":            KeyControl,
+	"sort":              KeyControl,
+	"atomic":            KeyControl,
+	"accept-language":   KeyControl,
+	"async":             KeyControl,
+
+	"request-id": KeyEcho,
+	"job":        KeyEcho,
+
+	"version":          KeyServer,
+	"modified":         KeyServer,
+	"etag":             KeyServer,
+	"content-hash":     KeyServer,
+	"content-length":   KeyServer,
+	"previous-hash":    KeyServer,
+	"current-version":  KeyServer,
+	"server-version":   KeyServer,
+	"your-version":     KeyServer,
+	"chain-valid":      KeyServer,
+	"chain-error":      KeyServer,
+	"chain-checked":    KeyServer,
+	"archived":         KeyServer,
+	"moved-from":       KeyServer,
+	"tampered":         KeyServer,
+	"disposition":      KeyServer,
+	"content-encoding": KeyServer,
+	"content-range":    KeyServer,
+	"server-protocol":  KeyServer,
+	"trailer":          KeyServer,
+	"body-hash":        KeyServer,
+	"elapsed-ms":       KeyServer,
+	"content-sha256":   KeyServer,
+	"signature":        KeyServer,
+	"key-id":           KeyServer,
+	"status":           KeyServer,
+	"content-language": KeyServer,
+	"word-count":       KeyServer,
+	"reading-time":     KeyServer,
+
+	"total":            KeyResponse,
+	"current":          KeyResponse,
+	"entries":          KeyResponse,
+	"next-offset":      KeyResponse,
+	"results":          KeyResponse,
+	"from-version":     KeyResponse,
+	"to-version":       KeyResponse,
+	"purged":           KeyResponse,
+	"purged-versions":  KeyResponse,
+	"token-label":      KeyResponse,
+	"token-operations": KeyResponse,
+	"token-paths":      KeyResponse,
+	"token-expires":    KeyResponse,
+	"location":         KeyResponse,
+	"retry-after":      KeyResponse,
+	"max-versions":     KeyResponse,
+	"failed-request":   KeyResponse,
+}
+
+// writeKeys are the control keys PUBLISH and APPEND consume. The other
+// control keys mean nothing to a write, so in one they are publisher
+// metadata like any other key.
+var writeKeys = map[string]bool{
+	"accept":            true,
+	"accept-trailers":   true,
+	"accept-encoding":   true,
+	"accept-language":   true,
+	"body-encoding":     true,
+	"auth":              true,
+	"expected-version":  true,
+	"if-none-match":     true,
+	"if-modified-since": true,
+	"async":             true,
+}
+
+// timeKeys are keys whose values must be RFC 3339 timestamps.
+var timeKeys = map[string]bool{
+	"modified":          true,
+	"if-modified-since": true,
+}
+
+// conditionalPrefix marks request keys that make a request conditional.
+// Only the registered ones are understood, so others are rejected rather
+// than silently ignored.
+const conditionalPrefix = "if-"
+
+// MetaKeyKind returns the kind of a metadata key.
+func MetaKeyKind(k string) KeyKind {
+	if kind, ok := metaKeys[k]; ok {
+		return kind
+	}
+	if IsExperimentalKey(k) {
+		return KeyExperimental
+	}
+	return KeyPublisher
+}
+
+// IsControlKey reports whether k is request-only control metadata.
+func IsControlKey(k string) bool {
+	return metaKeys[k] == KeyControl
+}
+
+// IsServerKey reports whether k is server-owned response metadata.
+func IsServerKey(k string) bool {
+	return metaKeys[k] == KeyServer
+}
+
+// IsPublisherKey reports whether k, in the metadata of a PUBLISH or
+// APPEND, is publisher metadata to store with the document, and so
+// whether a stored key may be served back with it.
+func IsPublisherKey(k string) bool {
+	switch MetaKeyKind(k) {
+	case KeyPublisher, KeyResponse:
+		return true
+	case KeyControl:
+		return !writeKeys[k]
+	}
+	return false
+}
+
+// ValidateRequestMeta checks request metadata against the registry:
+// server-owned keys are rejected, unknown if-* keys are rejected, and
+// timestamp keys must be RFC 3339. Publisher keys are not checked here;
+// their limits apply only to writes.
+func ValidateRequestMeta(meta map[string]string) error {
+	for k, v := range meta {
+		kind, known := metaKeys[k]
+		switch {
+		case kind == KeyServer:
+			return fmt.Errorf("metadata key %q is set by the server", k)
+		case !known && strings.HasPrefix(k, conditionalPrefix):
+			return fmt.Errorf("unknown conditional metadata key %q", k)
+		}
+		if err := validateTime(k, v); err != nil {
+			return err
+		}
+		if k == MetaRequestID && !ValidRequestID(v) {
+			return fmt.Errorf("metadata key %q must be 1 to %d letters, digits, or any of -_.:, got %q", k, MaxRequestIDLength, v)
+		}
+	}
+	return nil
+}
+
+// ValidateResponseMeta checks response metadata against the registry:
+// control keys that cannot be publisher metadata are rejected and
+// timestamp keys must be RFC 3339.
+func ValidateResponseMeta(meta map[string]string) error {
+	for k, v := range meta {
+		if IsControlKey(k) && !IsPublisherKey(k) {
+			return fmt.Errorf("metadata key %q is only valid in requests", k)
+		}
+		if err := validateTime(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateTime(k, v string) error {
+	if !timeKeys[k] {
+		return nil
+	}
+	if _, err := time.Parse(time.RFC3339, v); err != nil {
+		return fmt.Errorf("metadata key %q must be an RFC 3339 timestamp, got %q", k, v)
+	}
+	return nil
+}
+
+// MetaLengthError reports a metadata key or value longer than
+// MaxMetaKeyLength or MaxMetaValueLength.
+type MetaLengthError struct {
+	Key    string // the key, cut to MaxMetaKeyLength if it is the one too long
+	Value  bool   // whether the value is too long, rather than the key
+	Length int
+	Limit  int
+}
+
+func (e *MetaLengthError) Error() string {
+	if e.Value {
+		return fmt.Sprintf("metadata value for key %q is %d bytes, over the limit of %d", e.Key, e.Length, e.Limit)
+	}
+	return fmt.Sprintf("metadata key starting %q is %d bytes, over the limit of %d", e.Key, e.Length, e.Limit)
+}
+
+// checkMetaLengths returns a *MetaLengthError for the first key or value
+// in meta over its length limit. Parsers call it on every metadata block
+// they read, so that neither side keeps an oversized entry from the other.
+func checkMetaLengths(meta map[string]string) error {
+	for k, v := range meta {
+		if len(k) > MaxMetaKeyLength {
+			return &MetaLengthError{Key: k[:MaxMetaKeyLength], Length: len(k), Limit: MaxMetaKeyLength}
+		}
+		if len(v) > MaxMetaValueLength {
+			return &MetaLengthError{Key: k, Value: true, Length: len(v), Limit: MaxMetaValueLength}
+		}
+	}
+	return nil
+}
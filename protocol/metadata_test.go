@@ -0,0 +1,153 @@
+package protocol
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMetaKeyKind(t *testing.T) {
+	tests := []struct {
+		key  string
+		want KeyKind
+	}{
+		{"auth", KeyControl},
+		{"if-none-match", KeyControl},
+		{"etag", KeyServer},
+		{"previous-hash", KeyServer},
+		{"location", KeyResponse},
+		{"content-length", KeyServer},
+		{"title", KeyPublisher},
+		{"request-id", KeyEcho},
+		{"atomic", KeyControl},
+		{"failed-request", KeyResponse},
+		{"accept-language", KeyControl},
+		{"content-language", KeyServer},
+		{"word-count", KeyServer},
+		{"reading-time", KeyServer},
+		{"async", KeyControl},
+		{"job", KeyEcho},
+	}
+	for _, tt := range tests {
+		if got := MetaKeyKind(tt.key); got != tt.want {
+			t.Errorf("MetaKeyKind(%q): got %d, want %d", tt.key, got, tt.want)
+		}
+	}
+	if !IsControlKey("expected-version") || IsControlKey("title") {
+		t.Error("IsControlKey misclassifies keys")
+	}
+	if !IsServerKey("chain-valid") || IsServerKey("auth") || IsServerKey("total") {
+		t.Error("IsServerKey misclassifies keys")
+	}
+}
+
+func TestIsPublisherKey(t *testing.T) {
+	for _, k := range []string{"title", "size", "location", "results", "format", "sort", "range"} {
+		if !IsPublisherKey(k) {
+			t.Errorf("IsPublisherKey(%q) = false, want true", k)
+		}
+	}
+	for _, k := range []string{"etag", "version", "auth", "expected-version", "async", "request-id", "x-trace"} {
+		if IsPublisherKey(k) {
+			t.Errorf("IsPublisherKey(%q) = true, want false", k)
+		}
+	}
+}
+
+func TestValidateRequestMeta(t *testing.T) {
+	tests := []struct {
+		name    string
+		meta    map[string]string
+		wantErr bool
+	}{
+		{"empty", nil, false},
+		{"control and publisher", map[string]string{"auth": "x", "title": "Hi"}, false},
+		{"valid if-modified-since", map[string]string{"if-modified-since": "2026-01-02T03:04:05Z"}, false},
+		{"invalid if-modified-since", map[string]string{"if-modified-since": "yesterday"}, true},
+		{"unknown conditional", map[string]string{"if-match": "abc"}, true},
+		{"server key", map[string]string{"etag": "abc"}, true},
+		{"ordinary names", map[string]string{"size": "large", "location": "Berlin"}, false},
+		{"valid request-id", map[string]string{"request-id": "ci-run:42.7"}, false},
+		{"invalid request-id", map[string]string{"request-id": "has space"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRequestMeta(tt.meta)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateResponseMeta(t *testing.T) {
+	tests := []struct {
+		name    string
+		meta    map[string]string
+		wantErr bool
+	}{
+		{"valid", map[string]string{"version": "2", "modified": "2026-01-02T03:04:05Z", "title": "Hi"}, false},
+		{"invalid modified", map[string]string{"modified": "Jan 2"}, true},
+		{"control key", map[string]string{"auth": "secret"}, true},
+		{"stored publisher key", map[string]string{"format": "letter", "total": "3"}, false},
+		{"echoed request-id", map[string]string{"request-id": "abc"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateResponseMeta(tt.meta)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMetaLengthLimits(t *testing.T) {
+	longKey := strings.Repeat("k", MaxMetaKeyLength+1)
+	longValue := strings.Repeat("v", MaxMetaValueLength+1)
+	okValue := strings.Repeat("v", MaxMetaValueLength)
+
+	tests := []struct {
+		name      string
+		parse     func(string) error
+		input     string
+		wantValue bool
+	}{
+		{"request key", parseRequest, "FETCH /doc.md\n---\n" + longKey + ": x\n---\n", false},
+		{"request value", parseRequest, "FETCH /doc.md\n---\nauth: " + longValue + "\n---\n", true},
+		{"response key", parseResponse, "---\nstatus: ok\n" + longKey + ": x\n---\n# Doc\n", false},
+		{"response value", parseResponse, "---\nstatus: ok\nlocation: " + longValue + "\n---\n", true},
+		{"trailer value", parseResponse, "---\nstatus: ok\ntrailer: body-hash\n---\n# Doc\n\n---\nbody-hash: " + longValue + "\n---\n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lenErr *MetaLengthError
+			if err := tt.parse(tt.input); !errors.As(err, &lenErr) {
+				t.Fatalf("got %v, want *MetaLengthError", err)
+			}
+			if lenErr.Value != tt.wantValue || len(lenErr.Key) > MaxMetaKeyLength {
+				t.Errorf("error = %+v", lenErr)
+			}
+		})
+	}
+
+	if err := parseRequest("FETCH /doc.md\n---\nauth: " + okValue + "\n---\n"); err != nil {
+		t.Errorf("value at the limit: %v", err)
+	}
+	if err := parseResponse("---\nstatus: ok\nlocation: " + okValue + "\n---\n"); err != nil {
+		t.Errorf("value at the limit: %v", err)
+	}
+	if IsValidMetaKey(longKey) || IsValidMetaValue(longValue) {
+		t.Error("IsValidMetaKey/IsValidMetaValue accept entries over the limits")
+	}
+}
+
+func parseRequest(s string) error {
+	_, err := ParseRequest(strings.NewReader(s))
+	return err
+}
+
+func parseResponse(s string) error {
+	_, err := ParseResponse(strings.NewReader(s))
+	return err
+}
@@ -28,21 +28,62 @@ const (
 	// VerbAppend appends content to the end of an existing document.
 	VerbAppend = "APPEND"
 
+	// VerbSearch finds the documents under a directory matching a query.
+	VerbSearch = "SEARCH"
+
+	// VerbDiff compares two versions of a document.
+	VerbDiff = "DIFF"
+
+	// VerbPurge deletes a document and its version history, leaving a tombstone.
+	VerbPurge = "PURGE"
+
+	// VerbInfo retrieves a document's metadata, as FETCH would, without its body.
+	VerbInfo = "INFO"
+
+	// VerbMove renames a document, keeping its version history.
+	VerbMove = "MOVE"
+
+	// VerbBatch carries several read requests in one stream.
+	VerbBatch = "BATCH"
+
+	// VerbBacklinks lists the documents that link to a document.
+	VerbBacklinks = "BACKLINKS"
+
+	// VerbJob asks how a PUBLISH the server accepted to process later went.
+	VerbJob = "JOB"
+
 	// WellKnownManifestPath is the conventional path for agent manifest discovery.
 	WellKnownManifestPath = "/.well-known/agent-manifest.md"
 
+	// WellKnownTokenPath describes the token sent in the auth metadata of a
+	// FETCH: what it grants, and when it expires.
+	WellKnownTokenPath = "/.well-known/token"
+
+	// WellKnownFeedPath is the server's change feed: the versions most
+	// recently published, newest first, for clients to poll.
+	WellKnownFeedPath = "/.well-known/feed.md"
+
 	// MaxMetaKeys is the maximum number of publisher metadata keys.
 	MaxMetaKeys = 10
 
 	// MaxMetaBytes is the approximate maximum size of publisher metadata
 	// (sum of key and value lengths, excluding serialization overhead).
 	MaxMetaBytes = 512
+
+	// MaxMetaKeyLength is the maximum length in bytes of any metadata key,
+	// in requests, responses and trailers alike.
+	MaxMetaKeyLength = 64
+
+	// MaxMetaValueLength is the maximum length in bytes of any metadata
+	// value, in requests, responses and trailers alike.
+	MaxMetaValueLength = 4096
 )
 
 // IsValidMetaKey checks that a metadata key contains only safe characters
-// for frontmatter serialization: lowercase letters, digits, and hyphens.
+// for frontmatter serialization: lowercase letters, digits, and hyphens,
+// and is at most MaxMetaKeyLength bytes.
 func IsValidMetaKey(k string) bool {
-	if k == "" {
+	if k == "" || len(k) > MaxMetaKeyLength {
 		return false
 	}
 	for _, c := range k {
@@ -54,7 +95,8 @@ func IsValidMetaKey(k string) bool {
 }
 
 // IsValidMetaValue checks that a metadata value is safe for frontmatter
-// serialization: no carriage returns or newlines.
+// serialization: no carriage returns or newlines, and at most
+// MaxMetaValueLength bytes.
 func IsValidMetaValue(v string) bool {
-	return !strings.ContainsAny(v, "\r\n")
+	return len(v) <= MaxMetaValueLength && !strings.ContainsAny(v, "\r\n")
 }
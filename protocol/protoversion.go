@@ -0,0 +1,86 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A request line may end with the protocol version the client speaks:
+//
+//	FETCH /index.md MARK/1.1
+//
+// A request line without one is MARK/1.0, the protocol as first
+// specified. Minor versions add verbs and metadata a peer that does not
+// know them can ignore or refuse; a new major version would change the
+// wire format. A server answers a request in the lower of the client's
+// version and its own, and names its own in the server-protocol response
+// key, so a client can tell which features it may use. A request for a
+// major version the server does not implement is rejected.
+//
+// Since a MARK/1.0 server would read the token as part of the path,
+// clients should send it only to servers that have answered with
+// server-protocol.
+//
+// MARK/1.1 added the version token and server-protocol; MARK/1.2 added
+// BATCH; MARK/1.3 added BACKLINKS, and JOB with asynchronous PUBLISH.
+
+// ProtocolVersion is the version of the Mark Protocol this package
+// implements.
+const ProtocolVersion = "MARK/1.3"
+
+// protocolMajor is the major version of ProtocolVersion.
+const protocolMajor = 1
+
+// protocolVersionPrefix starts every protocol version token.
+const protocolVersionPrefix = "MARK/"
+
+// ErrUnsupportedProtocol is returned by ParseRequest for a request line
+// naming a major protocol version this package does not implement.
+var ErrUnsupportedProtocol = errors.New("unsupported protocol version")
+
+// ParseProtocolVersion parses a protocol version token of the form
+// MARK/<major>.<minor>. An empty token is MARK/1.0.
+func ParseProtocolVersion(proto string) (major, minor int, err error) {
+	if proto == "" {
+		return 1, 0, nil
+	}
+	num, ok := strings.CutPrefix(proto, protocolVersionPrefix)
+	if !ok {
+		return 0, 0, fmt.Errorf("protocol version %q: want MARK/MAJOR.MINOR", proto)
+	}
+	majorStr, minorStr, ok := strings.Cut(num, ".")
+	if !ok {
+		return 0, 0, fmt.Errorf("protocol version %q: want MARK/MAJOR.MINOR", proto)
+	}
+	if major, err = parseVersionNumber(majorStr); err != nil {
+		return 0, 0, fmt.Errorf("protocol version %q: invalid major version", proto)
+	}
+	if minor, err = parseVersionNumber(minorStr); err != nil {
+		return 0, 0, fmt.Errorf("protocol version %q: invalid minor version", proto)
+	}
+	return major, minor, nil
+}
+
+// parseVersionNumber parses a decimal version number without sign or
+// leading zeros.
+func parseVersionNumber(s string) (int, error) {
+	if s == "" || (len(s) > 1 && s[0] == '0') || strings.ContainsAny(s, "+-") {
+		return 0, fmt.Errorf("invalid version number %q", s)
+	}
+	return strconv.Atoi(s)
+}
+
+// NegotiateProtocol returns the protocol version two peers speaking
+// versions a and b have in common: the lower of the two, or "" if their
+// major versions differ or either is malformed. An empty version is
+// MARK/1.0.
+func NegotiateProtocol(a, b string) string {
+	aMajor, aMinor, errA := ParseProtocolVersion(a)
+	bMajor, bMinor, errB := ParseProtocolVersion(b)
+	if errA != nil || errB != nil || aMajor != bMajor {
+		return ""
+	}
+	return fmt.Sprintf("%s%d.%d", protocolVersionPrefix, aMajor, min(aMinor, bMinor))
+}
@@ -0,0 +1,81 @@
+package protocol
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A FETCH may ask for part of a document's body with the range request
+// key, in bytes:
+//
+//	range: bytes=0-4095    the first 4096 bytes
+//	range: bytes=4096-     everything from byte 4096
+//	range: bytes=-512      the last 512 bytes
+//
+// The server answers with StatusPartial, the bytes asked for, and a
+// content-range key giving their position in the whole body:
+//
+//	content-range: bytes 0-4095/12288
+//
+// Offsets count bytes, so a range may end inside a UTF-8 sequence; only
+// the concatenated pages are guaranteed to be valid text.
+
+// ParseRange returns the first and last byte offsets, inclusive, that a
+// range value selects from a body of size bytes. The last offset is cut
+// to the end of the body. It fails for malformed values, multiple ranges
+// and ranges that select nothing.
+func ParseRange(value string, size int) (first, last int, err error) {
+	spec, ok := strings.CutPrefix(strings.TrimSpace(value), "bytes=")
+	if !ok {
+		return 0, 0, fmt.Errorf("range %q: want bytes=FIRST-LAST", value)
+	}
+	from, to, ok := strings.Cut(spec, "-")
+	if !ok || strings.Contains(to, ",") {
+		return 0, 0, fmt.Errorf("range %q: want a single bytes=FIRST-LAST", value)
+	}
+
+	if from == "" {
+		n, err := strconv.Atoi(to)
+		if err != nil || n <= 0 {
+			return 0, 0, fmt.Errorf("range %q: invalid suffix length", value)
+		}
+		if size == 0 {
+			return 0, 0, fmt.Errorf("range %q: the body is empty", value)
+		}
+		return max(size-n, 0), size - 1, nil
+	}
+
+	first, err = strconv.Atoi(from)
+	if err != nil || first < 0 {
+		return 0, 0, fmt.Errorf("range %q: invalid first offset", value)
+	}
+	last = size - 1
+	if to != "" {
+		if last, err = strconv.Atoi(to); err != nil || last < first {
+			return 0, 0, fmt.Errorf("range %q: invalid last offset", value)
+		}
+		last = min(last, size-1)
+	}
+	if first >= size {
+		return 0, 0, fmt.Errorf("range %q: starts past the end of the %d-byte body", value, size)
+	}
+	return first, last, nil
+}
+
+// FormatContentRange returns the content-range value for bytes first to
+// last, inclusive, of a body of size bytes.
+func FormatContentRange(first, last, size int) string {
+	return fmt.Sprintf("bytes %d-%d/%d", first, last, size)
+}
+
+// ParseContentRange parses a content-range value.
+func ParseContentRange(value string) (first, last, size int, err error) {
+	if _, err := fmt.Sscanf(value, "bytes %d-%d/%d", &first, &last, &size); err != nil {
+		return 0, 0, 0, fmt.Errorf("content-range %q: %w", value, err)
+	}
+	if first < 0 || last < first || last >= size {
+		return 0, 0, 0, fmt.Errorf("content-range %q: offsets out of order", value)
+	}
+	return first, last, size, nil
+}
@@ -0,0 +1,53 @@
+package protocol
+
+import "testing"
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		value       string
+		size        int
+		first, last int
+		wantErr     bool
+	}{
+		{"bytes=0-4095", 10000, 0, 4095, false},
+		{"bytes=0-4095", 100, 0, 99, false},
+		{"bytes=4096-", 10000, 4096, 9999, false},
+		{"bytes=-512", 10000, 9488, 9999, false},
+		{"bytes=-512", 100, 0, 99, false},
+		{" bytes=5-5", 10, 5, 5, false},
+		{"bytes=100-", 100, 0, 0, true},
+		{"bytes=-0", 100, 0, 0, true},
+		{"bytes=-5", 0, 0, 0, true},
+		{"bytes=5-4", 100, 0, 0, true},
+		{"bytes=0-1,5-6", 100, 0, 0, true},
+		{"bytes=a-b", 100, 0, 0, true},
+		{"lines=0-10", 100, 0, 0, true},
+		{"bytes=-", 100, 0, 0, true},
+	}
+	for _, tt := range tests {
+		first, last, err := ParseRange(tt.value, tt.size)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRange(%q, %d): err = %v, wantErr %v", tt.value, tt.size, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && (first != tt.first || last != tt.last) {
+			t.Errorf("ParseRange(%q, %d) = %d-%d, want %d-%d", tt.value, tt.size, first, last, tt.first, tt.last)
+		}
+	}
+}
+
+func TestContentRange(t *testing.T) {
+	value := FormatContentRange(4096, 8191, 12000)
+	if value != "bytes 4096-8191/12000" {
+		t.Errorf("FormatContentRange = %q", value)
+	}
+	first, last, size, err := ParseContentRange(value)
+	if err != nil || first != 4096 || last != 8191 || size != 12000 {
+		t.Errorf("ParseContentRange(%q) = %d, %d, %d, %v", value, first, last, size, err)
+	}
+	for _, bad := range []string{"", "bytes 5-4/10", "bytes 0-10/10", "bytes */10"} {
+		if _, _, _, err := ParseContentRange(bad); err == nil {
+			t.Errorf("ParseContentRange(%q) succeeded", bad)
+		}
+	}
+}
@@ -14,6 +14,7 @@ import (
 type Request struct {
 	Verb     string
 	Path     string
+	Proto    string // protocol version from the request line, such as "MARK/1.1"; "" is MARK/1.0
 	Metadata map[string]string
 	Body     string
 }
@@ -28,8 +29,11 @@ const MaxRequestFrontmatterLength = 65536 // 64KB
 const MaxBodyLength = 1 * 1024 * 1024
 
 // ParseRequest reads a request from r.
-// Format: "VERB /path\n" followed by optional YAML frontmatter and body.
+// Format: "VERB /path\n", or "VERB /path MARK/1.1\n" naming the protocol
+// version, followed by optional YAML frontmatter and body.
 // The body is read as raw bytes to preserve content verbatim.
+// A request for an unsupported major protocol version fails with an error
+// wrapping ErrUnsupportedProtocol, returned with the request line parsed.
 func ParseRequest(r io.Reader) (Request, error) {
 	br := bufio.NewReader(r)
 
@@ -47,14 +51,28 @@ func ParseRequest(r io.Reader) (Request, error) {
 		return Request{}, fmt.Errorf("malformed request: %q", line)
 	}
 
-	// Validate verb is non-empty and is a known verb
+	// Validate verb is non-empty and is a known or experimental verb
 	if verb == "" {
 		return Request{}, fmt.Errorf("empty verb")
 	}
-	if !isValidVerb(verb) {
+	if !isValidVerb(verb) && !IsExperimentalVerb(verb) {
 		return Request{}, fmt.Errorf("unknown verb: %q", verb)
 	}
 
+	// A last word of the form MARK/x.y is the protocol version, not part
+	// of the path.
+	var proto string
+	if i := strings.LastIndexByte(path, ' '); i >= 0 && strings.HasPrefix(path[i+1:], protocolVersionPrefix) {
+		path, proto = path[:i], path[i+1:]
+		major, _, err := ParseProtocolVersion(proto)
+		if err != nil {
+			return Request{}, fmt.Errorf("malformed request: %w", err)
+		}
+		if major != protocolMajor {
+			return Request{Verb: verb, Path: path, Proto: proto}, fmt.Errorf("%w: %s", ErrUnsupportedProtocol, proto)
+		}
+	}
+
 	// Validate path is non-empty and starts with /
 	if path == "" || !strings.HasPrefix(path, "/") {
 		return Request{}, fmt.Errorf("invalid path: %q", path)
@@ -64,7 +82,7 @@ func ParseRequest(r io.Reader) (Request, error) {
 		return Request{}, fmt.Errorf("invalid path: contains control characters")
 	}
 
-	req := Request{Verb: verb, Path: path, Metadata: make(map[string]string)}
+	req := Request{Verb: verb, Path: path, Proto: proto, Metadata: make(map[string]string)}
 
 	// Read remaining bytes with a size limit to prevent unbounded allocation.
 	// The limit accounts for frontmatter overhead on top of the body.
@@ -111,6 +129,9 @@ func ParseRequest(r io.Reader) (Request, error) {
 		if err := yaml.Unmarshal(fmBytes, &raw); err != nil {
 			return Request{}, fmt.Errorf("parsing request metadata: %w", err)
 		}
+		if err := checkMetaLengths(raw); err != nil {
+			return Request{}, err
+		}
 		req.Metadata = raw
 	}
 
@@ -154,7 +175,11 @@ func readLine(br *bufio.Reader) (string, error) {
 func (req Request) WriteTo(w io.Writer) (int64, error) {
 	var buf bytes.Buffer
 
-	fmt.Fprintf(&buf, "%s %s\n", req.Verb, req.Path)
+	if req.Proto != "" {
+		fmt.Fprintf(&buf, "%s %s %s\n", req.Verb, req.Path, req.Proto)
+	} else {
+		fmt.Fprintf(&buf, "%s %s\n", req.Verb, req.Path)
+	}
 
 	if len(req.Metadata) > 0 {
 		yamlBytes, err := yaml.Marshal(req.Metadata)
@@ -177,7 +202,7 @@ func (req Request) WriteTo(w io.Writer) (int64, error) {
 // isValidVerb returns true if verb is a known Mark Protocol verb.
 func isValidVerb(verb string) bool {
 	switch verb {
-	case VerbFetch, VerbList, VerbVersions, VerbPublish, VerbArchive, VerbAppend:
+	case VerbFetch, VerbList, VerbVersions, VerbPublish, VerbArchive, VerbAppend, VerbSearch, VerbDiff, VerbPurge, VerbInfo, VerbMove, VerbBatch, VerbBacklinks, VerbJob:
 		return true
 	default:
 		return false
@@ -2,6 +2,7 @@ package protocol
 
 import (
 	"bytes"
+	"errors"
 	"strings"
 	"testing"
 )
@@ -63,6 +64,36 @@ func TestParseRequest(t *testing.T) {
 			input:   "FETCH /index\x01.md\n",
 			wantErr: true,
 		},
+		{
+			name:  "protocol version",
+			input: "FETCH /index.md MARK/1.1\n",
+			want:  Request{Verb: "FETCH", Path: "/index.md", Proto: "MARK/1.1"},
+		},
+		{
+			name:  "later minor version",
+			input: "FETCH /index.md MARK/1.7\n",
+			want:  Request{Verb: "FETCH", Path: "/index.md", Proto: "MARK/1.7"},
+		},
+		{
+			name:  "space in path",
+			input: "FETCH /my notes.md\n",
+			want:  Request{Verb: "FETCH", Path: "/my notes.md"},
+		},
+		{
+			name:  "space in path with protocol version",
+			input: "FETCH /my notes.md MARK/1.0\n",
+			want:  Request{Verb: "FETCH", Path: "/my notes.md", Proto: "MARK/1.0"},
+		},
+		{
+			name:    "malformed protocol version",
+			input:   "FETCH /index.md MARK/one\n",
+			wantErr: true,
+		},
+		{
+			name:    "protocol version without path",
+			input:   "FETCH  MARK/1.1\n",
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -83,6 +114,9 @@ func TestParseRequest(t *testing.T) {
 			if got.Path != tt.want.Path {
 				t.Errorf("path: got %q, want %q", got.Path, tt.want.Path)
 			}
+			if got.Proto != tt.want.Proto {
+				t.Errorf("proto: got %q, want %q", got.Proto, tt.want.Proto)
+			}
 		})
 	}
 }
@@ -134,6 +168,47 @@ func TestRequestWriteTo(t *testing.T) {
 	}
 }
 
+func TestRequestWriteToWithProto(t *testing.T) {
+	req := Request{Verb: "FETCH", Path: "/hello.md", Proto: ProtocolVersion}
+	var buf bytes.Buffer
+	if _, err := req.WriteTo(&buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "FETCH /hello.md " + ProtocolVersion + "\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestParseRequestUnsupportedProtocol(t *testing.T) {
+	req, err := ParseRequest(strings.NewReader("FETCH /index.md MARK/2.0\n"))
+	if !errors.Is(err, ErrUnsupportedProtocol) {
+		t.Fatalf("err = %v, want ErrUnsupportedProtocol", err)
+	}
+	if req.Verb != "FETCH" || req.Path != "/index.md" || req.Proto != "MARK/2.0" {
+		t.Errorf("request line not returned: %+v", req)
+	}
+}
+
+func TestNegotiateProtocol(t *testing.T) {
+	tests := []struct {
+		a, b, want string
+	}{
+		{"MARK/1.1", "MARK/1.1", "MARK/1.1"},
+		{"MARK/1.3", "MARK/1.1", "MARK/1.1"},
+		{"MARK/1.1", "", "MARK/1.0"},
+		{"", "", "MARK/1.0"},
+		{"MARK/2.0", "MARK/1.1", ""},
+		{"MARK/1.x", "MARK/1.1", ""},
+		{"MARK/01.1", "MARK/1.1", ""},
+		{"HTTP/1.1", "MARK/1.1", ""},
+	}
+	for _, tt := range tests {
+		if got := NegotiateProtocol(tt.a, tt.b); got != tt.want {
+			t.Errorf("NegotiateProtocol(%q, %q) = %q, want %q", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
 func TestRequestWriteToWithMetadata(t *testing.T) {
 	req := Request{
 		Verb: "FETCH",
@@ -0,0 +1,48 @@
+package protocol
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// A client may name a request with "request-id", an identifier of its
+// choosing, to match a failure it sees with the server's log of the same
+// request. A server that understands it echoes it in the response and
+// records it in every log line about the request:
+//
+//	FETCH /index.md MARK/1.2
+//	---
+//	request-id: 6f1c2b9e0d4a47e3a1f05c8b2d7e9a16
+//	---
+//
+// The identifier is 1 to MaxRequestIDLength letters, digits, and any of
+// "-", "_", "." and ":", so it is safe to log as is. Nothing requires it
+// to be unique; NewRequestID makes one that is in practice. It is never
+// stored with a document.
+
+// MetaRequestID is the metadata key of a request's identifier.
+const MetaRequestID = "request-id"
+
+// MaxRequestIDLength is the longest request-id a server accepts.
+const MaxRequestIDLength = 64
+
+// NewRequestID returns a random request-id: 32 hex digits.
+func NewRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:]) // never fails
+	return hex.EncodeToString(b[:])
+}
+
+// ValidRequestID reports whether id is a well-formed request-id.
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > MaxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') &&
+			c != '-' && c != '_' && c != '.' && c != ':' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,38 @@
+package protocol
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewRequestID(t *testing.T) {
+	a, b := NewRequestID(), NewRequestID()
+	if len(a) != 32 || !ValidRequestID(a) {
+		t.Errorf("NewRequestID() = %q, want 32 hex digits", a)
+	}
+	if a == b {
+		t.Errorf("NewRequestID() returned %q twice", a)
+	}
+}
+
+func TestValidRequestID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"6f1c2b9e0d4a47e3a1f05c8b2d7e9a16", true},
+		{"deploy-7_host.a:3", true},
+		{strings.Repeat("a", MaxRequestIDLength), true},
+		{"", false},
+		{strings.Repeat("a", MaxRequestIDLength+1), false},
+		{"two words", false},
+		{"line\nbreak", false},
+		{"quote\"", false},
+		{"é", false},
+	}
+	for _, tt := range tests {
+		if got := ValidRequestID(tt.id); got != tt.want {
+			t.Errorf("ValidRequestID(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"io"
 	"maps"
-	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -22,60 +21,75 @@ const (
 	StatusConflict     = "conflict"
 	StatusBadRequest   = "bad-request"
 	StatusServerError  = "server-error"
+
+	// StatusMoved reports that the document lives at the path given in the
+	// location metadata key. Clients follow it with a bounded hop count.
+	StatusMoved = "moved"
+
+	// StatusRateLimited reports that the client is sending requests too
+	// fast. The retry-after metadata key carries the wait in seconds.
+	StatusRateLimited = "rate-limited"
+
+	// StatusVersionLimit reports that a write would take a document past
+	// the server's cap on versions, given in the max-versions metadata key.
+	// The document must be archived, or the cap raised by the operator.
+	StatusVersionLimit = "version-limit"
+
+	// StatusTooLarge reports that the response would exceed a server limit,
+	// such as the number of changed lines DIFF will compare.
+	StatusTooLarge = "too-large"
+
+	// StatusPartial reports that the body is the part of the document the
+	// request's range asked for, at the position given by content-range.
+	StatusPartial = "partial"
+
+	// StatusNotImplemented reports that the server does not implement the
+	// request's experimental verb.
+	StatusNotImplemented = "not-implemented"
+
+	// StatusMaintenance reports that the server is down for maintenance
+	// and did not process the request. The body is the operator's banner.
+	StatusMaintenance = "maintenance"
+
+	// StatusUnavailable reports that the server cannot process the request
+	// for now, such as a write whose body could not be scanned. The client
+	// may retry later.
+	StatusUnavailable = "unavailable"
+
+	// StatusAccepted reports that the server queued the request, a
+	// PUBLISH sent with async, to process later. The job metadata names
+	// it for JOB.
+	StatusAccepted = "accepted"
 )
 
+// MaxResponseFrontmatterLength is the maximum allowed size for response
+// metadata, as MaxRequestFrontmatterLength is for requests.
+const MaxResponseFrontmatterLength = 65536 // 64KB
+
 // Response represents a Mark Protocol response.
 type Response struct {
 	Status   string
 	Metadata map[string]string
 	Body     string
+
+	// Trailer holds the keys of the trailer block that follows the body,
+	// if the response has one. See trailer.go.
+	Trailer map[string]string
 }
 
 // ParseResponse reads a response from r.
 // The response has optional YAML frontmatter delimited by "---" lines,
-// followed by the markdown body.
+// followed by the markdown body. The whole body is read into memory; see
+// ParseResponseHeader to stream it.
 func ParseResponse(r io.Reader) (Response, error) {
-	data, err := io.ReadAll(r)
+	resp, body, err := ParseResponseHeader(r)
 	if err != nil {
-		return Response{}, fmt.Errorf("reading response: %w", err)
+		return Response{}, err
 	}
-
-	content := string(data)
-	resp := Response{Metadata: make(map[string]string)}
-
-	if strings.HasPrefix(content, "---\n") {
-		end := strings.Index(content[4:], "\n---\n")
-		if end == -1 {
-			return Response{}, fmt.Errorf("malformed frontmatter: missing closing ---")
-		}
-
-		fmData := content[4 : 4+end]
-
-		// Handle empty frontmatter gracefully
-		if strings.TrimSpace(fmData) == "" {
-			resp.Body = content[4+end+5:]
-			return resp, nil
-		}
-
-		// Parse as map[string]string to avoid YAML interpreting timestamps, numbers, etc.
-		var raw map[string]string
-		if err := yaml.Unmarshal([]byte(fmData), &raw); err != nil {
-			return Response{}, fmt.Errorf("parsing frontmatter: %w", err)
-		}
-
-		for k, v := range raw {
-			if k == "status" {
-				resp.Status = v
-			} else {
-				resp.Metadata[k] = v
-			}
-		}
-
-		resp.Body = content[4+end+5:] // skip past "\n---\n"
-	} else {
-		resp.Body = content
+	if resp.Body, err = readBody(body); err != nil {
+		return Response{}, err
 	}
-
+	resp.Trailer = body.Trailer()
 	return resp, nil
 }
 
@@ -86,6 +100,12 @@ func (resp Response) WriteTo(w io.Writer) (int64, error) {
 	fm := make(map[string]string, len(resp.Metadata)+1)
 	maps.Copy(fm, resp.Metadata)
 	fm["status"] = resp.Status
+	if len(resp.Trailer) > 0 {
+		fm["trailer"] = trailerNames(resp.Trailer)
+	} else {
+		// Without a trailer block, an announcement would eat the body.
+		delete(fm, "trailer")
+	}
 
 	yamlBytes, err := yaml.Marshal(fm)
 	if err != nil {
@@ -99,6 +119,13 @@ func (resp Response) WriteTo(w io.Writer) (int64, error) {
 	if resp.Body != "" {
 		buf.WriteString(resp.Body)
 	}
+	if len(resp.Trailer) > 0 {
+		trailer, err := encodeTrailer(resp.Trailer)
+		if err != nil {
+			return 0, err
+		}
+		buf.WriteString(trailer)
+	}
 
 	n, err := w.Write(buf.Bytes())
 	return int64(n), err
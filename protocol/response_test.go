@@ -133,3 +133,39 @@ func TestResponseRoundTrip(t *testing.T) {
 		}
 	}
 }
+
+func TestResponseTrailer(t *testing.T) {
+	body := "# Log\n\n---\n\nEntry.\n"
+	original := Response{
+		Status:   StatusOK,
+		Metadata: map[string]string{"version": "2"},
+		Body:     body,
+		Trailer:  map[string]string{TrailerBodyHash: BodyHash(body), TrailerElapsed: "1.250"},
+	}
+
+	var buf bytes.Buffer
+	if _, err := original.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+	parsed, err := ParseResponse(&buf)
+	if err != nil {
+		t.Fatalf("ParseResponse: %v", err)
+	}
+	if parsed.Body != body {
+		t.Errorf("body: got %q, want %q", parsed.Body, body)
+	}
+	if parsed.Metadata["trailer"] != "body-hash, elapsed-ms" {
+		t.Errorf("trailer announcement: got %q", parsed.Metadata["trailer"])
+	}
+	if parsed.Trailer[TrailerElapsed] != "1.250" {
+		t.Errorf("elapsed-ms: got %q", parsed.Trailer[TrailerElapsed])
+	}
+	if err := parsed.VerifyTrailer(); err != nil {
+		t.Errorf("VerifyTrailer: %v", err)
+	}
+
+	parsed.Body += "tampered"
+	if err := parsed.VerifyTrailer(); err == nil {
+		t.Error("VerifyTrailer accepted a body that does not match its hash")
+	}
+}
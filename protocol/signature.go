@@ -0,0 +1,116 @@
+package protocol
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// A FETCH or INFO response for a document carries "content-sha256", the
+// SHA-256 of the full body the server serves for the request, in hex: the
+// rendered body for "accept: text/html", before any range or content
+// coding is applied. Unlike etag, which hashes the stored file, a client
+// can check it against the body it receives.
+//
+// A server configured with an ed25519 signing key also sends "signature",
+// its signature of the request path and content-sha256, and "key-id",
+// naming the key:
+//
+//	content-sha256: 9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08
+//	signature: 2mB1...Zw==
+//	key-id: ed25519-4e1243bd22c66e76
+//
+// The signed message is SignatureMessage(path, content-sha256), so a
+// signed body cannot be passed off as another path's. Other metadata,
+// such as version, is not signed.
+
+// Metadata keys of response checksums and signatures.
+const (
+	MetaContentSHA256 = "content-sha256"
+	MetaSignature     = "signature"
+	MetaKeyID         = "key-id"
+)
+
+// ErrUnsigned is returned by VerifySignature for a response without a
+// signature.
+var ErrUnsigned = errors.New("response is not signed")
+
+// ContentSHA256 returns the content-sha256 value for body.
+func ContentSHA256(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:])
+}
+
+// KeyID returns the key-id of a signing key: "ed25519-" and the first 16
+// hex digits of the SHA-256 of the public key.
+func KeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return "ed25519-" + hex.EncodeToString(sum[:8])
+}
+
+// SignatureMessage returns the bytes a signature covers for the document
+// at path with the given content-sha256.
+func SignatureMessage(path, contentSHA256 string) []byte {
+	return []byte("mark-signature-v1\n" + path + "\n" + contentSHA256 + "\n")
+}
+
+// Sign returns the signature metadata value for the document at path with
+// the given content-sha256.
+func Sign(key ed25519.PrivateKey, path, contentSHA256 string) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(key, SignatureMessage(path, contentSHA256)))
+}
+
+// VerifyContentSHA256 checks the body of resp against its content-sha256
+// metadata. A response without one verifies trivially.
+func (resp Response) VerifyContentSHA256() error {
+	want := resp.Metadata[MetaContentSHA256]
+	if want == "" {
+		return nil
+	}
+	if got := ContentSHA256(resp.Body); got != want {
+		return fmt.Errorf("content-sha256 mismatch: metadata says %s, body is %s", want, got)
+	}
+	return nil
+}
+
+// VerifySignature checks that resp, the response to a request for path,
+// was signed with pub: that its key-id names pub, its body matches its
+// content-sha256, and its signature covers both. Returns ErrUnsigned if
+// it has no signature.
+func (resp Response) VerifySignature(path string, pub ed25519.PublicKey) error {
+	sig := resp.Metadata[MetaSignature]
+	if sig == "" {
+		return ErrUnsigned
+	}
+	if id := resp.Metadata[MetaKeyID]; id != KeyID(pub) {
+		return fmt.Errorf("signed with key %q, want %q", id, KeyID(pub))
+	}
+	digest := resp.Metadata[MetaContentSHA256]
+	if digest == "" {
+		return errors.New("signed response has no content-sha256")
+	}
+	if err := resp.VerifyContentSHA256(); err != nil {
+		return err
+	}
+	raw, err := base64.StdEncoding.DecodeString(sig)
+	if err != nil || !ed25519.Verify(pub, SignatureMessage(path, digest), raw) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// ParsePublicKey parses an ed25519 public key written as base64, the form
+// servers log it in for clients to pin.
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("public key: %w", err)
+	}
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key: %d bytes, want %d", len(raw), ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(raw), nil
+}
@@ -0,0 +1,74 @@
+package protocol
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func TestContentSHA256(t *testing.T) {
+	// sha256("test")
+	if got, want := ContentSHA256("test"), "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"; got != want {
+		t.Errorf("ContentSHA256 = %s, want %s", got, want)
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	seed := make([]byte, ed25519.SeedSize)
+	priv := ed25519.NewKeyFromSeed(seed)
+	pub := priv.Public().(ed25519.PublicKey)
+
+	body := "# Hello\n"
+	digest := ContentSHA256(body)
+	signed := func() Response {
+		return Response{Status: StatusOK, Body: body, Metadata: map[string]string{
+			MetaContentSHA256: digest,
+			MetaSignature:     Sign(priv, "/hello.md", digest),
+			MetaKeyID:         KeyID(pub),
+		}}
+	}
+
+	if err := signed().VerifySignature("/hello.md", pub); err != nil {
+		t.Errorf("valid signature: %v", err)
+	}
+	if err := signed().VerifySignature("/other.md", pub); err == nil {
+		t.Error("signature accepted for another path")
+	}
+
+	tampered := signed()
+	tampered.Body = "# Goodbye\n"
+	if err := tampered.VerifyContentSHA256(); err == nil {
+		t.Error("VerifyContentSHA256 accepted a tampered body")
+	}
+	if err := tampered.VerifySignature("/hello.md", pub); err == nil {
+		t.Error("VerifySignature accepted a tampered body")
+	}
+
+	other := ed25519.NewKeyFromSeed(append(make([]byte, ed25519.SeedSize-1), 1))
+	if err := signed().VerifySignature("/hello.md", other.Public().(ed25519.PublicKey)); err == nil {
+		t.Error("signature accepted for another key")
+	}
+
+	unsigned := signed()
+	delete(unsigned.Metadata, MetaSignature)
+	if err := unsigned.VerifySignature("/hello.md", pub); !errors.Is(err, ErrUnsigned) {
+		t.Errorf("unsigned response: err = %v, want ErrUnsigned", err)
+	}
+	if err := unsigned.VerifyContentSHA256(); err != nil {
+		t.Errorf("unsigned response: VerifyContentSHA256: %v", err)
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	pub := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)).Public().(ed25519.PublicKey)
+	got, err := ParsePublicKey(base64.StdEncoding.EncodeToString(pub))
+	if err != nil || !got.Equal(pub) {
+		t.Errorf("ParsePublicKey = %x, %v; want %x", got, err, pub)
+	}
+	for _, s := range []string{"", "not base64!", base64.StdEncoding.EncodeToString([]byte("short"))} {
+		if _, err := ParsePublicKey(s); err == nil {
+			t.Errorf("ParsePublicKey(%q): want error", s)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package protocol
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// trailerWindow is how much of the end of a body announcing a trailer a
+// BodyReader holds back, since the trailer is only known to start there
+// once the stream ends. A trailer may be as long as response metadata.
+const trailerWindow = MaxResponseFrontmatterLength + len("\n---\n") + len("---\n")
+
+// bodyChunk is how much a BodyReader reads from the stream at a time while
+// it holds back a trailer window.
+const bodyChunk = 32 * 1024
+
+// ParseResponseHeader reads the frontmatter of a response from r and
+// returns the response without its body, and a reader for the body, which
+// reads the rest of r. It parses the same messages as ParseResponse, but
+// the body need not fit in memory: a reader of a large document can copy
+// it to a file, or stop early.
+//
+// The body is read as sent, with any content-encoding still applied (see
+// DecodeReader). A trailer is separated from it, and available from the
+// BodyReader once the body has been read to the end.
+func ParseResponseHeader(r io.Reader) (Response, *BodyReader, error) {
+	br := bufio.NewReader(r)
+	resp := Response{Metadata: make(map[string]string)}
+	if start, err := br.Peek(4); err != nil || string(start) != "---\n" {
+		return resp, &BodyReader{r: br}, nil
+	}
+	_, _ = br.Discard(4)
+
+	fm, err := readFrontmatter(br)
+	if err != nil {
+		return Response{}, nil, err
+	}
+	// Parse as map[string]string to avoid YAML interpreting timestamps, numbers, etc.
+	var raw map[string]string
+	if strings.TrimSpace(fm) != "" {
+		if err := yaml.Unmarshal([]byte(fm), &raw); err != nil {
+			return Response{}, nil, fmt.Errorf("parsing frontmatter: %w", err)
+		}
+		if err := checkMetaLengths(raw); err != nil {
+			return Response{}, nil, err
+		}
+	}
+	for k, v := range raw {
+		if k == "status" {
+			resp.Status = v
+		} else {
+			resp.Metadata[k] = v
+		}
+	}
+
+	body := &BodyReader{r: br}
+	if resp.Metadata["trailer"] != "" {
+		body.split = true
+		body.hash = sha256.New()
+	}
+	return resp, body, nil
+}
+
+// readFrontmatter reads the frontmatter after its opening "---" line, up
+// to and including the closing one, and returns it without the newline
+// before the closing line. The first line cannot close it, so that
+// "---\n---\n" does not read as empty frontmatter.
+func readFrontmatter(br *bufio.Reader) (string, error) {
+	var fm strings.Builder
+	for first := true; ; first = false {
+		line, err := br.ReadString('\n')
+		if line == "---\n" && !first {
+			return strings.TrimSuffix(fm.String(), "\n"), nil
+		}
+		fm.WriteString(line)
+		if fm.Len() > MaxResponseFrontmatterLength+1 {
+			return "", fmt.Errorf("response metadata exceeds limit: more than %d bytes", MaxResponseFrontmatterLength)
+		}
+		if errors.Is(err, io.EOF) {
+			return "", fmt.Errorf("malformed frontmatter: missing closing ---")
+		}
+		if err != nil {
+			return "", fmt.Errorf("reading response: %w", err)
+		}
+	}
+}
+
+// BodyReader reads the body of a response parsed by ParseResponseHeader.
+type BodyReader struct {
+	r io.Reader
+
+	// For a body announcing a trailer: the bytes read but not yet
+	// returned, the hash of those returned, and the trailer once found.
+	split   bool
+	held    []byte
+	hash    hash.Hash
+	eof     bool
+	err     error
+	trailer map[string]string
+}
+
+// Read reads the body. It returns io.EOF at the end of the body, before
+// any trailer, and an error if the trailer is malformed.
+func (b *BodyReader) Read(p []byte) (int, error) {
+	if !b.split {
+		n, err := b.r.Read(p)
+		if err != nil && !errors.Is(err, io.EOF) {
+			err = fmt.Errorf("reading response: %w", err)
+		}
+		return n, err
+	}
+	for !b.eof && len(b.held) <= trailerWindow {
+		b.fill()
+	}
+	available := len(b.held)
+	if !b.eof {
+		available -= trailerWindow
+	}
+	if available == 0 {
+		if b.err != nil {
+			return 0, b.err
+		}
+		return 0, io.EOF
+	}
+	n := copy(p, b.held[:available])
+	b.hash.Write(p[:n])
+	b.held = b.held[n:]
+	return n, nil
+}
+
+// fill reads the next chunk of the stream into the held bytes, splitting
+// off the trailer at the end of the stream.
+func (b *BodyReader) fill() {
+	b.held = slices.Grow(b.held, bodyChunk)
+	n, err := b.r.Read(b.held[len(b.held) : len(b.held)+bodyChunk])
+	b.held = b.held[:len(b.held)+n]
+	switch {
+	case errors.Is(err, io.EOF):
+		b.eof = true
+		body, trailer, err := splitTrailer(string(b.held))
+		if err != nil {
+			b.held, b.err = nil, err
+			return
+		}
+		b.held, b.trailer = b.held[:len(body)], trailer
+	case err != nil:
+		b.held, b.eof, b.err = nil, true, fmt.Errorf("reading response: %w", err)
+	}
+}
+
+// Trailer returns the keys of the response's trailer, once the body has
+// been read to the end. It returns nil for a response without one.
+func (b *BodyReader) Trailer() map[string]string {
+	return b.trailer
+}
+
+// VerifyTrailer checks the body read against the body-hash trailer, as
+// Response.VerifyTrailer does. The body must have been read to the end.
+func (b *BodyReader) VerifyTrailer() error {
+	if !b.split {
+		return nil
+	}
+	if !b.eof || len(b.held) > 0 {
+		return errors.New("body hash: body not read to the end")
+	}
+	want := b.trailer[TrailerBodyHash]
+	if want == "" {
+		return nil
+	}
+	if got := fmt.Sprintf("sha256-%x", b.hash.Sum(nil)); got != want {
+		return fmt.Errorf("body hash mismatch: trailer says %s, body is %s", want, got)
+	}
+	return nil
+}
+
+// readBody reads the rest of a body into memory.
+func readBody(body *BodyReader) (string, error) {
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(body); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
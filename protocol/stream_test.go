@@ -0,0 +1,104 @@
+package protocol
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestParseResponseHeader(t *testing.T) {
+	// Larger than the trailer window, with thematic breaks on both sides
+	// of it, so the body is released in several reads.
+	body := strings.Repeat("# Log\n\n---\n\nEntry.\n", 2*trailerWindow/20)
+	resp := Response{
+		Status:   StatusOK,
+		Metadata: map[string]string{"version": "3"},
+		Body:     body,
+		Trailer:  map[string]string{TrailerBodyHash: BodyHash(body), TrailerElapsed: "2.000"},
+	}
+	var wire bytes.Buffer
+	if _, err := resp.WriteTo(&wire); err != nil {
+		t.Fatal(err)
+	}
+
+	header, r, err := ParseResponseHeader(iotest.HalfReader(bytes.NewReader(wire.Bytes())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Status != StatusOK || header.Metadata["version"] != "3" || header.Body != "" {
+		t.Errorf("header = %+v", header)
+	}
+	if err := r.VerifyTrailer(); err == nil {
+		t.Error("VerifyTrailer succeeded before the body was read")
+	}
+	got, err := io.ReadAll(iotest.OneByteReader(r))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != body {
+		t.Errorf("body: %d bytes, want %d", len(got), len(body))
+	}
+	if r.Trailer()[TrailerElapsed] != "2.000" {
+		t.Errorf("trailer = %v", r.Trailer())
+	}
+	if err := r.VerifyTrailer(); err != nil {
+		t.Errorf("VerifyTrailer: %v", err)
+	}
+
+	tampered := bytes.Replace(wire.Bytes(), []byte("Entry."), []byte("Entri."), 1)
+	_, r, err = ParseResponseHeader(bytes.NewReader(tampered))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(r); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.VerifyTrailer(); err == nil {
+		t.Error("VerifyTrailer accepted a tampered body")
+	}
+}
+
+func TestParseResponseHeaderErrors(t *testing.T) {
+	for name, input := range map[string]string{
+		"unclosed frontmatter": "---\nstatus: ok\n",
+		"oversized metadata":   "---\nstatus: ok\nnote: " + strings.Repeat("x", MaxResponseFrontmatterLength) + "\n---\n",
+	} {
+		if _, _, err := ParseResponseHeader(strings.NewReader(input)); err == nil {
+			t.Errorf("%s: want error", name)
+		}
+	}
+
+	// A missing trailer only shows at the end of the body.
+	_, r, err := ParseResponseHeader(strings.NewReader("---\nstatus: ok\ntrailer: body-hash\n---\n# Body\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(r); err == nil {
+		t.Error("missing trailer: want error reading the body")
+	}
+}
+
+func TestDecodeReader(t *testing.T) {
+	const body = "# Compressed\n\nSome text.\n"
+	for _, coding := range []string{"", EncodingGzip, EncodingBase64} {
+		encoded := body
+		if coding != "" {
+			var err error
+			if encoded, err = EncodeBody(body, coding); err != nil {
+				t.Fatal(err)
+			}
+		}
+		r, err := DecodeReader(strings.NewReader(encoded), coding)
+		if err != nil {
+			t.Fatalf("%q: %v", coding, err)
+		}
+		if got, err := io.ReadAll(r); err != nil || string(got) != body {
+			t.Errorf("%q: got %q, %v", coding, got, err)
+		}
+	}
+	if _, err := DecodeReader(strings.NewReader(""), "br"); err == nil {
+		t.Error("unsupported coding: want error")
+	}
+}
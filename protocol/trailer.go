@@ -0,0 +1,95 @@
+package protocol
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A response may end with a trailer: a second frontmatter block after the
+// body, for information only known once the body has been produced, such
+// as its hash or the time taken to serve it. The trailer metadata key in
+// the leading frontmatter names the trailer's keys, so a reader knows the
+// block is there and the body stops before it:
+//
+//	---
+//	status: ok
+//	trailer: body-hash, elapsed-ms
+//	---
+//	# Body
+//
+//	---
+//	body-hash: sha256-...
+//	elapsed-ms: "0.412"
+//	---
+//
+// The newline before the trailer's opening "---" is not part of the body.
+// Servers only send a trailer to clients that ask for one with
+// "accept-trailers: true", since older clients would read it as body.
+
+// Trailer keys.
+const (
+	// TrailerBodyHash is the SHA-256 of the body as sent, "sha256-<hex>".
+	TrailerBodyHash = "body-hash"
+
+	// TrailerElapsed is the server's processing time in milliseconds.
+	TrailerElapsed = "elapsed-ms"
+)
+
+// BodyHash returns the body-hash trailer value for body.
+func BodyHash(body string) string {
+	return fmt.Sprintf("sha256-%x", sha256.Sum256([]byte(body)))
+}
+
+// VerifyTrailer checks the response body against the body-hash trailer.
+// A response without one verifies trivially.
+func (resp Response) VerifyTrailer() error {
+	want := resp.Trailer[TrailerBodyHash]
+	if want == "" {
+		return nil
+	}
+	if got := BodyHash(resp.Body); got != want {
+		return fmt.Errorf("body hash mismatch: trailer says %s, body is %s", want, got)
+	}
+	return nil
+}
+
+// trailerNames returns the value of the trailer metadata key announcing t.
+func trailerNames(t map[string]string) string {
+	return strings.Join(slices.Sorted(maps.Keys(t)), ", ")
+}
+
+// encodeTrailer returns the wire form of a trailer, including the newline
+// that separates it from the body.
+func encodeTrailer(t map[string]string) (string, error) {
+	yamlBytes, err := yaml.Marshal(t)
+	if err != nil {
+		return "", fmt.Errorf("encoding trailer: %w", err)
+	}
+	return "\n---\n" + string(yamlBytes) + "---\n", nil
+}
+
+// splitTrailer separates the announced trailer from the end of content,
+// returning the body before it and the trailer's keys.
+func splitTrailer(content string) (string, map[string]string, error) {
+	if !strings.HasSuffix(content, "\n---\n") {
+		return "", nil, fmt.Errorf("malformed trailer: missing closing ---")
+	}
+	inner := content[:len(content)-len("---\n")]
+	start := strings.LastIndex(inner, "\n---\n")
+	if start == -1 {
+		return "", nil, fmt.Errorf("malformed trailer: missing opening ---")
+	}
+	trailer := make(map[string]string)
+	if err := yaml.Unmarshal([]byte(inner[start+len("\n---\n"):]), &trailer); err != nil {
+		return "", nil, fmt.Errorf("parsing trailer: %w", err)
+	}
+	if err := checkMetaLengths(trailer); err != nil {
+		return "", nil, err
+	}
+	return content[:start], trailer, nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/latebit/demarkus/server/internal/ratelimit"
+	"github.com/quic-go/quic-go"
+)
+
+// banErrorCode is the QUIC application error code sent when closing the
+// connection of a banned client.
+const banErrorCode quic.ApplicationErrorCode = 0x1
+
+// maxReportedOffenders caps the clients listed in one abuse summary.
+const maxReportedOffenders = 100
+
+// strike records an offence by the client behind conn and closes the
+// connection if it got the client banned.
+func strike(conn *quic.Conn, bans *ratelimit.BanList, key, ip, reason string, logger *slog.Logger) bool {
+	banned, err := bans.Strike(key, reason)
+	if err != nil {
+		logger.Error("saving ban list failed", "error", err)
+	}
+	if !banned {
+		return false
+	}
+	logger.Warn("client banned", "ip", ip, "reason", reason)
+	_ = conn.CloseWithError(banErrorCode, "banned")
+	return true
+}
+
+// abuseReport is the JSON body POSTed to the abuse webhook.
+type abuseReport struct {
+	From      time.Time            `json:"from"`
+	To        time.Time            `json:"to"`
+	Offenders []ratelimit.Offender `json:"offenders"`
+	Omitted   int                  `json:"omitted,omitempty"`
+}
+
+// startAbuseReporter summarizes the clients that collected strikes every
+// interval: as an "abuse summary" log event and, when webhook is set, as a
+// JSON POST to it. Quiet periods are not reported. It returns a function
+// that stops the reporter.
+func startAbuseReporter(bans *ratelimit.BanList, interval time.Duration, webhook string, logger *slog.Logger) func() {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		from := time.Now()
+		for {
+			select {
+			case to := <-ticker.C:
+				reportAbuse(bans.Summary(), from, to, webhook, logger)
+				from = to
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
+
+func reportAbuse(offenders []ratelimit.Offender, from, to time.Time, webhook string, logger *slog.Logger) {
+	if len(offenders) == 0 {
+		return
+	}
+	report := abuseReport{From: from.UTC(), To: to.UTC(), Offenders: offenders}
+	if len(offenders) > maxReportedOffenders {
+		report.Offenders = offenders[:maxReportedOffenders]
+		report.Omitted = len(offenders) - maxReportedOffenders
+	}
+
+	banned := 0
+	top := make([]string, 0, 5)
+	for i, o := range offenders {
+		if !o.BannedUntil.IsZero() {
+			banned++
+		}
+		if i < cap(top) {
+			top = append(top, formatOffender(o))
+		}
+	}
+	logger.Warn("abuse summary", "clients", len(offenders), "banned", banned, "top", top)
+
+	if webhook == "" {
+		return
+	}
+	if err := postReport(webhook, report); err != nil {
+		logger.Error("abuse webhook failed", "error", err)
+	}
+}
+
+// formatOffender renders o as "<ip> invalid-token=3 rate-limit=1".
+func formatOffender(o ratelimit.Offender) string {
+	var sb strings.Builder
+	sb.WriteString(o.IP)
+	for _, reason := range slices.Sorted(maps.Keys(o.Strikes)) {
+		fmt.Fprintf(&sb, " %s=%d", reason, o.Strikes[reason])
+	}
+	return sb.String()
+}
+
+func postReport(webhook string, report abuseReport) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
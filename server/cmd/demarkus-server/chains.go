@@ -0,0 +1,43 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/latebit/demarkus/server/internal/store"
+)
+
+// startChainScanner verifies the hash chain of every document now and
+// then every interval, so that FETCH and INFO can report each chain's
+// state from the last scan. It returns a function that stops the scanner.
+func startChainScanner(s *store.Store, interval time.Duration, logger *slog.Logger) func() {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		scanChains(s, logger)
+		for {
+			select {
+			case <-ticker.C:
+				scanChains(s, logger)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
+
+func scanChains(s *store.Store, logger *slog.Logger) {
+	start := time.Now()
+	checked, broken := s.ScanChains(func(reqPath string, err error) {
+		logger.Warn("chain verification failed", "path", reqPath, "error", err)
+	})
+	if broken > 0 {
+		logger.Warn("hash chains checked", "documents", checked, "broken", broken, "elapsed", time.Since(start).Round(time.Millisecond).String())
+		return
+	}
+	logger.Info("hash chains checked", "documents", checked, "elapsed", time.Since(start).Round(time.Millisecond).String())
+}
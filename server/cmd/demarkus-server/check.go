@@ -0,0 +1,249 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/latebit/demarkus/protocol"
+	"github.com/latebit/demarkus/server/internal/auth"
+	"github.com/latebit/demarkus/server/internal/config"
+	"github.com/latebit/demarkus/server/internal/logging"
+	"github.com/latebit/demarkus/server/internal/ratelimit"
+	"github.com/latebit/demarkus/server/internal/store"
+)
+
+// certExpiryWarning is how far ahead -check warns about certificate expiry.
+const certExpiryWarning = 14 * 24 * time.Hour
+
+// checkResult is the outcome of one -check step.
+type checkResult struct {
+	name   string
+	detail string // shown on success or as a warning
+	err    error
+	hint   string // how to fix err
+	warn   bool
+}
+
+// runChecks validates everything the server needs before it can serve,
+// without binding the port. Later checks are skipped when the content
+// directory is unusable.
+func runChecks(cfg *config.Config) []checkResult {
+	var results []checkResult
+
+	if err := cfg.Validate(); err != nil {
+		results = append(results, checkResult{name: "config", err: err, hint: "fix the environment variable or flag named in the error"})
+	} else {
+		results = append(results, checkResult{name: "config", detail: "environment and flags are valid"})
+	}
+	if _, err := logging.NewPrivacy(cfg.LogIPs, cfg.LogRedactPaths); err != nil {
+		results = append(results, checkResult{name: "log privacy", err: err, hint: "set DEMARKUS_LOG_IPS to full, truncate or hash"})
+	}
+
+	results = append(results, checkTLS(cfg), checkTokens(cfg))
+	if cfg.SigningKey != "" {
+		results = append(results, checkSigningKey(cfg))
+	}
+	if cfg.MaintenanceFile != "" {
+		results = append(results, checkMaintenance(cfg))
+	}
+	if cfg.BanAfter > 0 && cfg.BanFile != "" {
+		results = append(results, checkBanFile(cfg))
+	}
+
+	dirResult := checkContentDir(cfg)
+	results = append(results, dirResult)
+	if dirResult.err != nil {
+		return results
+	}
+	results = append(results, checkSymlinks(cfg.ContentDir), checkLayout(cfg.ContentDir), checkMigration(cfg.ContentDir))
+	return results
+}
+
+func checkTLS(cfg *config.Config) checkResult {
+	r := checkResult{name: "tls"}
+	switch {
+	case cfg.TLSCert == "" && cfg.TLSKey == "":
+		r.detail = "no certificate configured; a self-signed dev certificate will be used"
+		r.warn = true
+		return r
+	case cfg.TLSCert == "" || cfg.TLSKey == "":
+		r.err = fmt.Errorf("only one of certificate and key is set (cert=%q, key=%q)", cfg.TLSCert, cfg.TLSKey)
+		r.hint = "set both DEMARKUS_TLS_CERT and DEMARKUS_TLS_KEY, or neither for dev mode"
+		return r
+	}
+	pair, err := tls.LoadX509KeyPair(cfg.TLSCert, cfg.TLSKey)
+	if err != nil {
+		r.err = err
+		r.hint = "check that both files are readable PEM and that the key matches the certificate"
+		return r
+	}
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		r.err = fmt.Errorf("parse certificate: %w", err)
+		r.hint = "re-issue the certificate"
+		return r
+	}
+	now := time.Now()
+	switch {
+	case now.After(leaf.NotAfter):
+		r.err = fmt.Errorf("certificate expired on %s", leaf.NotAfter.Format(time.DateOnly))
+		r.hint = "renew the certificate (e.g. certbot renew) and send SIGHUP to reload"
+	case leaf.NotAfter.Sub(now) < certExpiryWarning:
+		r.detail = fmt.Sprintf("certificate expires soon, on %s", leaf.NotAfter.Format(time.DateOnly))
+		r.warn = true
+	default:
+		r.detail = fmt.Sprintf("certificate for %v valid until %s", leaf.DNSNames, leaf.NotAfter.Format(time.DateOnly))
+	}
+	return r
+}
+
+func checkTokens(cfg *config.Config) checkResult {
+	r := checkResult{name: "tokens"}
+	if cfg.TokensFile == "" {
+		r.detail = "no tokens file configured; the server will be read-only"
+		r.warn = true
+		return r
+	}
+	if _, err := auth.LoadTokens(cfg.TokensFile); err != nil {
+		r.err = err
+		r.hint = "fix the file or regenerate entries with demarkus-token"
+		return r
+	}
+	r.detail = "loaded " + cfg.TokensFile
+	return r
+}
+
+func checkSigningKey(cfg *config.Config) checkResult {
+	r := checkResult{name: "signing key"}
+	key, err := loadSigningKey(cfg.SigningKey)
+	if err != nil {
+		r.err = err
+		r.hint = "generate one with: openssl genpkey -algorithm ed25519 -out signing.pem"
+		return r
+	}
+	r.detail = "loaded " + cfg.SigningKey + " (" + protocol.KeyID(key.Public().(ed25519.PublicKey)) + ")"
+	return r
+}
+
+func checkMaintenance(cfg *config.Config) checkResult {
+	r := checkResult{name: "maintenance"}
+	if _, err := os.Stat(cfg.MaintenanceFile); err != nil {
+		r.detail = "off; create " + cfg.MaintenanceFile + " and send SIGHUP to turn it on"
+		return r
+	}
+	r.detail = cfg.MaintenanceFile + " exists; the server will answer every request with it until it is removed"
+	r.warn = true
+	return r
+}
+
+func checkBanFile(cfg *config.Config) checkResult {
+	r := checkResult{name: "ban list"}
+	if _, err := ratelimit.NewBanList(cfg.BanFile, cfg.BanAfter, cfg.BanWindow, cfg.BanDuration); err != nil {
+		r.err = err
+		r.hint = "fix or delete the ban file; it is rewritten whenever a client is banned"
+		return r
+	}
+	r.detail = "loaded " + cfg.BanFile
+	return r
+}
+
+func checkContentDir(cfg *config.Config) checkResult {
+	r := checkResult{name: "content directory"}
+	if cfg.ContentDir == "" {
+		r.err = fmt.Errorf("not set")
+		r.hint = "set DEMARKUS_ROOT or use -root"
+		return r
+	}
+	if _, err := os.ReadDir(cfg.ContentDir); err != nil {
+		r.err = err
+		r.hint = "make the directory readable by the server user"
+		return r
+	}
+	f, err := os.CreateTemp(cfg.ContentDir, ".demarkus-check-*")
+	if err != nil {
+		if cfg.TokensFile != "" {
+			r.err = fmt.Errorf("not writable: %w", err)
+			r.hint = "make the directory writable by the server user, or remove DEMARKUS_TOKENS to serve read-only"
+			return r
+		}
+		r.detail = cfg.ContentDir + " is read-only (fine without a tokens file)"
+		r.warn = true
+		return r
+	}
+	_ = f.Close()
+	_ = os.Remove(f.Name())
+	r.detail = cfg.ContentDir + " is readable and writable"
+	return r
+}
+
+func checkSymlinks(dir string) checkResult {
+	r := checkResult{name: "symlinks"}
+	link := filepath.Join(dir, fmt.Sprintf(".demarkus-check-link-%d", os.Getpid()))
+	if err := os.Symlink("versions", link); err != nil {
+		r.err = err
+		r.hint = "versioned documents are symlinks; use a filesystem that supports them (not FAT or some network mounts)"
+		return r
+	}
+	_ = os.Remove(link)
+	r.detail = "supported"
+	return r
+}
+
+func checkLayout(dir string) checkResult {
+	r := checkResult{name: "store layout"}
+	problems, err := store.New(dir).CheckLayout()
+	switch {
+	case err != nil:
+		r.err = err
+		r.hint = "make the content directory readable by the server user"
+	case len(problems) > 0:
+		r.err = fmt.Errorf("%d problem(s):\n  %s", len(problems), strings.Join(problems, "\n  "))
+		r.hint = "restore the affected files from backup, or repoint each symlink at its latest versions/ file"
+	default:
+		r.detail = "symlinks and hash chains are consistent"
+	}
+	return r
+}
+
+func checkMigration(dir string) checkResult {
+	r := checkResult{name: "layout version"}
+	from, plan, err := store.New(dir).PlanMigration()
+	switch {
+	case err != nil:
+		r.err = err
+		r.hint = "upgrade demarkus-server, or restore the content directory from a backup"
+	case len(plan) > 0:
+		r.detail = fmt.Sprintf("version %d with %d pending change(s); run demarkus-server migrate -dry-run to review them", from, len(plan))
+		r.warn = true
+	default:
+		r.detail = fmt.Sprintf("up to date (version %d)", store.LayoutVersion)
+	}
+	return r
+}
+
+// printChecks writes one line per result and returns the number of failures.
+func printChecks(w io.Writer, results []checkResult) int {
+	failed := 0
+	for _, r := range results {
+		switch {
+		case r.err != nil:
+			failed++
+			fmt.Fprintf(w, "FAIL  %s: %v\n", r.name, r.err)
+			if r.hint != "" {
+				fmt.Fprintf(w, "      fix: %s\n", r.hint)
+			}
+		case r.warn:
+			fmt.Fprintf(w, "WARN  %s: %s\n", r.name, r.detail)
+		default:
+			fmt.Fprintf(w, "ok    %s: %s\n", r.name, r.detail)
+		}
+	}
+	return failed
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/latebit/demarkus/server/internal/auth"
+	"github.com/latebit/demarkus/server/internal/config"
+	"github.com/latebit/demarkus/server/internal/store"
+)
+
+// demoDocs are the sample documents written by -demo, in order. A path
+// listed twice gets two versions.
+var demoDocs = []struct {
+	path, body string
+}{
+	{"/index.md", "# Welcome to Demarkus\n\nThis is a demo server.\n"},
+	{"/index.md", "# Welcome to Demarkus\n\nThis is a demo server with sample documents. It is deleted when the server stops.\n\n- [Getting started](/guide/getting-started.md)\n- [Writing documents](/guide/writing.md)\n- [About versions](/versions.md)\n"},
+	{"/guide/getting-started.md", "# Getting Started\n\nEvery document is markdown, fetched with `FETCH` over QUIC.\n\nBack to the [index](/index.md).\n"},
+	{"/guide/writing.md", "# Writing Documents\n\nPublish with the token printed at startup. Each publish creates a new immutable version.\n\nBack to the [index](/index.md).\n"},
+	{"/versions.md", "# About Versions\n\n`/index.md` has two versions. Fetch `/index.md/v1` to read the first, or list them with `VERSIONS /index.md`.\n"},
+}
+
+// setupDemo creates a throwaway deployment under a new temp directory: a
+// content directory seeded with versioned sample documents and a tokens
+// file holding one publish token. cfg is pointed at them and set to use a
+// dev certificate. It returns the temp directory and the raw token.
+func setupDemo(cfg *config.Config) (dir, token string, err error) {
+	dir, err = os.MkdirTemp("", "demarkus-demo-")
+	if err != nil {
+		return "", "", err
+	}
+	contentDir := filepath.Join(dir, "content")
+	if err := os.Mkdir(contentDir, 0o755); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", "", err
+	}
+	s := store.New(contentDir)
+	for _, d := range demoDocs {
+		if _, err := s.Write(d.path, []byte(d.body), nil); err != nil {
+			_ = os.RemoveAll(dir)
+			return "", "", fmt.Errorf("seed %s: %w", d.path, err)
+		}
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", "", err
+	}
+	token = hex.EncodeToString(secret)
+	tokensFile := filepath.Join(dir, "tokens.toml")
+	entry := fmt.Sprintf("[tokens.demo]\nhash = %q\npaths = [\"/*\"]\noperations = [\"publish\"]\n", auth.HashToken(token))
+	if err := os.WriteFile(tokensFile, []byte(entry), 0o600); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", "", err
+	}
+
+	cfg.ContentDir = contentDir
+	cfg.TokensFile = tokensFile
+	cfg.TLSCert = ""
+	cfg.TLSKey = ""
+	return dir, token, nil
+}
+
+// printDemo writes ready-to-paste commands for the demo server at addr.
+func printDemo(w io.Writer, addr net.Addr, dir, token string) {
+	port := 0
+	if udp, ok := addr.(*net.UDPAddr); ok {
+		port = udp.Port
+	}
+	base := fmt.Sprintf("mark://localhost:%d", port)
+	fmt.Fprintf(w, "\nDemarkus demo server\n\n")
+	fmt.Fprintf(w, "  url:     %s/index.md\n", base)
+	fmt.Fprintf(w, "  token:   %s\n", token)
+	fmt.Fprintf(w, "  content: %s (deleted on exit)\n\n", dir)
+	fmt.Fprintf(w, "Try:\n\n")
+	fmt.Fprintf(w, "  demarkus -insecure %s/index.md\n", base)
+	fmt.Fprintf(w, "  demarkus-tui -insecure %s/index.md\n", base)
+	fmt.Fprintf(w, "  demarkus -insecure -X PUBLISH -auth %s -expected-version 0 -body '# Hello' %s/hello.md\n\n", token, base)
+}
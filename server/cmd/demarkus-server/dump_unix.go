@@ -0,0 +1,65 @@
+//go:build !windows
+
+package main
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"time"
+
+	"github.com/latebit/demarkus/server/internal/config"
+	"github.com/latebit/demarkus/server/internal/ratelimit"
+	"github.com/latebit/demarkus/server/internal/store"
+)
+
+// startStateDumper logs a snapshot of the server's runtime state on each
+// SIGUSR1, for diagnosing a wedged server without attaching a debugger.
+func startStateDumper(cfg *config.Config, s *store.Store, rl *ratelimit.Limiter, bans *ratelimit.BanList, logger *slog.Logger) {
+	started := time.Now()
+	sigusr1Chan := make(chan os.Signal, 1)
+	signal.Notify(sigusr1Chan, syscall.SIGUSR1)
+	go func() {
+		for range sigusr1Chan {
+			dumpState(cfg, s, rl, bans, started, logger)
+		}
+	}()
+}
+
+func dumpState(cfg *config.Config, s *store.Store, rl *ratelimit.Limiter, bans *ratelimit.BanList, started time.Time, logger *slog.Logger) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	attrs := []any{
+		"uptime", time.Since(started).Round(time.Second).String(),
+		slog.Group("runtime",
+			"goroutines", runtime.NumGoroutine(),
+			"heap_alloc", mem.HeapAlloc,
+			"heap_objects", mem.HeapObjects,
+			"gc_cycles", mem.NumGC,
+		),
+		"open_connections", openConns.Load(),
+		"maintenance", maintenanceBanner.Load() != nil,
+	}
+	if rl != nil {
+		attrs = append(attrs, slog.Group("rate_limiter", "clients", rl.Size(), "in_flight", rl.InFlight()))
+	}
+	if bans != nil {
+		attrs = append(attrs, "active_bans", bans.Active())
+	}
+	st := s.Stats()
+	attrs = append(attrs,
+		slog.Group("store",
+			"hash_entries", st.HashEntries,
+			"aliases", st.Aliases,
+			"search_documents", st.SearchDocuments,
+			"search_terms", st.SearchTerms,
+			"journal", st.Journal,
+			"journal_pending", st.JournalPending,
+			"described", st.Described,
+		),
+		"config", cfg,
+	)
+	logger.Info("state dump", attrs...)
+}
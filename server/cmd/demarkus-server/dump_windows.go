@@ -0,0 +1,15 @@
+//go:build windows
+
+package main
+
+import (
+	"log/slog"
+
+	"github.com/latebit/demarkus/server/internal/config"
+	"github.com/latebit/demarkus/server/internal/ratelimit"
+	"github.com/latebit/demarkus/server/internal/store"
+)
+
+func startStateDumper(_ *config.Config, _ *store.Store, _ *ratelimit.Limiter, _ *ratelimit.BanList, _ *slog.Logger) {
+	// SIGUSR1 is not available on Windows.
+}
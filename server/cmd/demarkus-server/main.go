@@ -2,13 +2,18 @@ package main
 
 import (
 	"context"
+	"crypto/ed25519"
 	"crypto/tls"
+	"encoding/base64"
 	"flag"
 	"fmt"
 	"log/slog"
+	"math"
 	"os"
 	"os/signal"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -16,21 +21,35 @@ import (
 	"github.com/latebit/demarkus/server/internal/auth"
 	"github.com/latebit/demarkus/server/internal/config"
 	"github.com/latebit/demarkus/server/internal/handler"
+	"github.com/latebit/demarkus/server/internal/jobs"
 	"github.com/latebit/demarkus/server/internal/logging"
 	"github.com/latebit/demarkus/server/internal/ratelimit"
+	"github.com/latebit/demarkus/server/internal/scan"
 	"github.com/latebit/demarkus/server/internal/store"
 	servertls "github.com/latebit/demarkus/server/internal/tls"
+	"github.com/latebit/demarkus/server/internal/transform"
 	"github.com/quic-go/quic-go"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "relink" {
+		os.Exit(runRelink(os.Args[2:]))
+	}
+
 	root := flag.String("root", "", "content directory to serve (overrides DEMARKUS_ROOT)")
 	port := flag.Int("port", 0, "port to listen on (overrides DEMARKUS_PORT)")
 	tlsCert := flag.String("tls-cert", "", "path to TLS certificate PEM file (overrides DEMARKUS_TLS_CERT)")
 	tlsKey := flag.String("tls-key", "", "path to TLS private key PEM file (overrides DEMARKUS_TLS_KEY)")
 	tokens := flag.String("tokens", "", "path to TOML tokens file for auth (overrides DEMARKUS_TOKENS)")
+	check := flag.Bool("check", false, "validate config, TLS, tokens and the content directory, then exit without serving")
+	demo := flag.Bool("demo", false, "serve sample documents from a temporary directory with a dev certificate and a printed publish token")
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: demarkus-server [options]\n\n")
+		fmt.Fprintf(os.Stderr, "Usage: demarkus-server [options]\n")
+		fmt.Fprintf(os.Stderr, "       demarkus-server migrate [-root DIR] [-dry-run] [-backup DIR]\n")
+		fmt.Fprintf(os.Stderr, "       demarkus-server relink [-root DIR] [-dry-run] OLD-HOST NEW-HOST\n\n")
 		fmt.Fprintf(os.Stderr, "Serves markdown documents over the Mark Protocol (QUIC, port %d).\n", protocol.DefaultPort)
 		fmt.Fprintf(os.Stderr, "Options can also be set via environment variables (DEMARKUS_ROOT, etc.).\n\n")
 		flag.PrintDefaults()
@@ -40,11 +59,16 @@ func main() {
 	cfg, err := config.NewConfig()
 
 	// Create logger early so all subsequent output is structured.
-	logger := logging.New(cfg.LogFormat, cfg.LogLevel, nil)
+	privacy, privacyErr := logging.NewPrivacy(cfg.LogIPs, cfg.LogRedactPaths)
+	logger := logging.NewWithPrivacy(cfg.LogFormat, cfg.LogLevel, nil, privacy)
 
-	if err != nil {
+	if err != nil && !*check {
 		logger.Warn("config", "error", err)
 	}
+	if privacyErr != nil {
+		logger.Error("DEMARKUS_LOG_IPS", "error", privacyErr)
+		os.Exit(1)
+	}
 
 	// Flag overrides take precedence over env vars
 	if *root != "" {
@@ -62,6 +86,28 @@ func main() {
 	if *tokens != "" {
 		cfg.TokensFile = *tokens
 	}
+	var demoDir, demoToken string
+	if *demo {
+		demoDir, demoToken, err = setupDemo(cfg)
+		if err != nil {
+			logger.Error("demo setup failed", "error", err)
+			os.Exit(1)
+		}
+		defer func() { _ = os.RemoveAll(demoDir) }()
+	}
+	if *check {
+		if printChecks(os.Stdout, runChecks(cfg)) > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+	// An unusable deny pattern would silently serve what it meant to hide.
+	for _, pattern := range cfg.DenyPaths {
+		if err := auth.ValidatePattern(pattern); err != nil {
+			logger.Error("invalid deny pattern", "pattern", pattern, "error", err)
+			os.Exit(1)
+		}
+	}
 	if cfg.ContentDir == "" {
 		logger.Error("content directory is required (set DEMARKUS_ROOT or use -root flag)")
 		os.Exit(1)
@@ -91,20 +137,52 @@ func main() {
 		MaxIdleTimeout:        cfg.IdleTimeout,
 	}
 
-	addr := fmt.Sprintf(":%d", cfg.Port)
-	listener, err := quic.ListenAddr(addr, tlsConfig, quicConfig)
+	listener, err := listen(cfg.ListenNetwork(), cfg.Port, tlsConfig, quicConfig, logger)
 	if err != nil {
-		logger.Error("listen failed", "addr", addr, "error", err)
+		logger.Error("listen failed", "error", err)
 		os.Exit(1)
 	}
 	defer func() { _ = listener.Close() }()
 
 	s := store.New(cfg.ContentDir)
+	if _, plan, err := s.PlanMigration(); err != nil {
+		logger.Error("store layout", "error", err)
+		os.Exit(1)
+	} else if len(plan) > 0 {
+		logger.Warn("content directory uses an older store layout; run demarkus-server migrate", "changes", len(plan))
+	}
+	// Recover even when the journal is now off: it may predate the restart.
+	repairs, err := s.Recover()
+	for _, r := range repairs {
+		logger.Warn("store repaired an interrupted write", "repair", r)
+	}
+	if err != nil {
+		logger.Error("store recovery failed", "error", err)
+		os.Exit(1)
+	}
+	if cfg.Journal {
+		s.EnableJournal()
+	}
+	if cfg.CompressAfter > 0 || cfg.CompressAge > 0 {
+		s.EnableCompression(cfg.CompressAfter, cfg.CompressAge)
+		n, err := s.CompressVersions()
+		if err != nil {
+			logger.Warn("compressing old versions failed", "error", err)
+		}
+		logger.Info("old versions compressed", "files", n, "after_versions", cfg.CompressAfter, "after_age", cfg.CompressAge.String())
+	}
 	if err := s.BuildHashIndex(); err != nil {
 		logger.Warn("hash index build failed", "error", err)
 	} else {
 		logger.Info("content hash index built", "entries", s.HashIndexSize())
 	}
+	if cfg.Preload {
+		go preload(s, logger)
+	}
+	if cfg.ChainScan > 0 {
+		stopChainScans := startChainScanner(s, cfg.ChainScan, logger)
+		defer stopChainScans()
+	}
 
 	if cfg.TokensFile != "" {
 		if err := loadTokenStore(cfg.TokensFile); err != nil {
@@ -116,10 +194,57 @@ func main() {
 		logger.Info("auth: no tokens file configured, writes disabled")
 	}
 
+	var signingKey ed25519.PrivateKey
+	if cfg.SigningKey != "" {
+		signingKey, err = loadSigningKey(cfg.SigningKey)
+		if err != nil {
+			logger.Error("signing key loading failed", "error", err)
+			os.Exit(1)
+		}
+		pub := signingKey.Public().(ed25519.PublicKey)
+		logger.Info("response signing enabled", "key_id", protocol.KeyID(pub), "public_key", base64.StdEncoding.EncodeToString(pub))
+	}
+
+	// Validate has already parsed the transforms and redirects, so these
+	// cannot fail.
+	transforms, err := transform.New(cfg.Transforms, cfg.LinkRewrites)
+	if err != nil {
+		logger.Error("transforms", "error", err)
+		os.Exit(1)
+	}
+	redirects, err := handler.ParseRedirects(cfg.Redirects)
+	if err != nil {
+		logger.Error("redirects", "error", err)
+		os.Exit(1)
+	}
+
+	if cfg.MaintenanceFile != "" {
+		loadMaintenance(cfg.MaintenanceFile, logger)
+	}
+
+	var scanner *scan.Scanner
+	if cfg.Scan != "" {
+		if scanner, err = scan.New(cfg.Scan, cfg.ScanTimeout); err != nil {
+			logger.Error("scan", "error", err)
+			os.Exit(1)
+		}
+		logger.Info("scanning write bodies", "scanner", cfg.Scan, "fail_open", cfg.ScanFailOpen)
+	}
+
 	h := &handler.Handler{
-		ContentDir: cfg.ContentDir,
-		Store:      s,
-		Logger:     logger,
+		ContentDir:      cfg.ContentDir,
+		Store:           s,
+		Logger:          logger,
+		DenyPaths:       cfg.DenyPaths,
+		DetectTampering: cfg.DetectTampering,
+		RequestTimeout:  cfg.TimeoutFor,
+		TOCPaths:        cfg.TOCPaths,
+		MaxVersions:     cfg.MaxVersions,
+		Transforms:      transforms,
+		SigningKey:      signingKey,
+		Maintenance:     currentMaintenance,
+		Redirects:       redirects,
+		ScanFailOpen:    cfg.ScanFailOpen,
 		GetTokenStore: func() *auth.TokenStore {
 			tokenMu.RLock()
 			defer tokenMu.RUnlock()
@@ -127,25 +252,71 @@ func main() {
 		},
 	}
 
+	if scanner != nil {
+		h.Scan = scanner.Scan
+	}
+	if cfg.PublishQueue > 0 {
+		h.Jobs = jobs.New(cfg.PublishQueue, jobs.DefaultKeep)
+	}
+
+	if len(cfg.DenyPaths) > 0 {
+		logger.Info("deny list configured", "patterns", cfg.DenyPaths)
+	}
+	if len(transforms) > 0 {
+		logger.Info("transforms configured", "pipeline", transform.Key(transforms))
+	}
+	if len(cfg.TOCPaths) > 0 {
+		// Catch up with documents written while the server was down.
+		h.RefreshTOCs()
+	}
+
 	var rl *ratelimit.Limiter
 	if cfg.RateLimit > 0 {
 		rl = ratelimit.New(cfg.RateLimit, cfg.RateBurst)
+		rl.SetAdaptive(cfg.RateAdaptive)
 		defer rl.Stop()
-		logger.Info("rate limit configured", "req_per_sec", cfg.RateLimit, "burst", cfg.RateBurst)
+		logger.Info("rate limit configured", "req_per_sec", cfg.RateLimit, "burst", cfg.RateBurst, "adaptive_inflight", cfg.RateAdaptive)
+	}
+
+	var bans *ratelimit.BanList
+	if cfg.BanAfter > 0 {
+		bans, err = ratelimit.NewBanList(cfg.BanFile, cfg.BanAfter, cfg.BanWindow, cfg.BanDuration)
+		if err != nil {
+			logger.Error("ban list loading failed", "error", err)
+			os.Exit(1)
+		}
+		logger.Info("bans configured", "after", cfg.BanAfter, "window", cfg.BanWindow.String(), "duration", cfg.BanDuration.String(), "file", cfg.BanFile)
+		stopReporter := startAbuseReporter(bans, cfg.AbuseReport, cfg.AbuseWebhook, logger)
+		defer stopReporter()
 	}
 
 	logger.Info("server started",
-		"addr", addr,
+		"addr", listener.Addr().String(),
+		"address_family", cfg.AddressFamily,
 		"root", cfg.ContentDir,
 		"idle_timeout", cfg.IdleTimeout.String(),
-		"request_timeout", cfg.RequestTimeout.String())
+		"request_timeout", cfg.RequestTimeout.String(),
+		"log_ips", cfg.LogIPs,
+		"publish_timeout", cfg.TimeoutFor(protocol.VerbPublish).String())
 
 	// Set up signal handling for graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
-	// Start SIGHUP handler for certificate reload (Unix only, no-op on Windows)
-	startCertReloader(cfg, prodMode, logger)
+	// Start SIGHUP handler for certificate, token, content root and
+	// maintenance mode reload (Unix only, no-op on Windows)
+	startCertReloader(cfg, prodMode, s, logger)
+
+	// Log runtime state on SIGUSR1 (Unix only, no-op on Windows)
+	startStateDumper(cfg, s, rl, bans, logger)
+
+	if *demo {
+		printDemo(os.Stdout, listener.Addr(), demoDir, demoToken)
+	}
+
+	notifySystemd("READY=1", logger)
+	stopWatchdog := startWatchdog(logger)
+	defer stopWatchdog()
 
 	// Accept connections in a goroutine so we can listen for shutdown signals
 	var wg sync.WaitGroup
@@ -158,7 +329,7 @@ func main() {
 				return
 			}
 			wg.Go(func() {
-				handleConn(conn, h, cfg.RequestTimeout, rl, logger)
+				handleConn(conn, h, cfg.RequestTimeout, rl, bans, privacy, logger)
 			})
 		}
 	}()
@@ -171,6 +342,8 @@ func main() {
 		logger.Error("listener error", "error", err)
 	}
 
+	notifySystemd("STOPPING=1", logger)
+
 	// Close the listener to stop accepting new connections
 	_ = listener.Close()
 
@@ -188,30 +361,93 @@ func main() {
 		logger.Warn("shutdown timeout: some connections did not finish")
 	}
 
+	if h.Jobs != nil {
+		// Write the publishes already answered accepted.
+		if n := h.Jobs.Len(); n > 0 {
+			logger.Info("writing queued publishes", "pending", n)
+		}
+		h.Jobs.Close()
+	}
+
 	logger.Info("server stopped")
 }
 
-func handleConn(conn *quic.Conn, h *handler.Handler, requestTimeout time.Duration, rl *ratelimit.Limiter, logger *slog.Logger) {
+func handleConn(conn *quic.Conn, h *handler.Handler, requestTimeout time.Duration, rl *ratelimit.Limiter, bans *ratelimit.BanList, privacy logging.Privacy, logger *slog.Logger) {
+	openConns.Add(1)
+	defer openConns.Add(-1)
+
+	// Key the limiter and ban list by the client's network (its /64 for
+	// IPv6), anonymized too, so raw addresses are not retained in their
+	// state. The logger anonymizes "ip" itself.
+	ip := ratelimit.ExtractIP(conn.RemoteAddr())
+	key := privacy.IP(ratelimit.ClientKey(ip))
+	hc := *h
+	if bans != nil {
+		if until, banned := bans.Banned(key); banned {
+			logger.Debug("banned client refused", "ip", ip, "until", until.Format(time.RFC3339))
+			_ = conn.CloseWithError(banErrorCode, "banned")
+			return
+		}
+		hc.InvalidToken = func() { strike(conn, bans, key, ip, ratelimit.ReasonInvalidToken, logger) }
+	}
+	if rl != nil {
+		// Each request of a BATCH counts against the client's limit.
+		hc.AdmitBatch = func(n int) (bool, time.Duration) { return rl.ReserveN(key, n) }
+	}
+	h = &hc
+
 	for {
 		stream, err := conn.AcceptStream(context.Background())
 		if err != nil {
 			return // connection closed
 		}
 		if rl != nil {
-			ip := ratelimit.ExtractIP(conn.RemoteAddr())
-			if !rl.Allow(ip) {
-				logger.Warn("rate limited")
-				_ = stream.Close()
+			if ok, retryAfter := rl.Reserve(key); !ok {
+				logger.Warn("rate limited", "ip", ip, "retry_after", retryAfter.String())
+				writeRateLimited(stream, retryAfter, logger)
+				if bans != nil && strike(conn, bans, key, ip, ratelimit.ReasonRateLimit, logger) {
+					return
+				}
 				continue
 			}
 		}
+		// The handler moves this deadline to the per-verb timeout once
+		// the request line has been read.
 		if requestTimeout > 0 {
 			_ = stream.SetReadDeadline(time.Now().Add(requestTimeout))
 		}
-		go h.HandleStream(stream)
+		if rl == nil {
+			go h.HandleStream(stream)
+			continue
+		}
+		rl.Begin()
+		go func() {
+			defer rl.End()
+			h.HandleStream(stream)
+		}()
+	}
+}
+
+// writeRateLimited answers a rejected stream without reading the request,
+// telling the client how long to wait in whole seconds (at least one).
+func writeRateLimited(stream *quic.Stream, retryAfter time.Duration, logger *slog.Logger) {
+	secs := max(1, int(math.Ceil(retryAfter.Seconds())))
+	resp := protocol.Response{
+		Status:   protocol.StatusRateLimited,
+		Metadata: map[string]string{"retry-after": strconv.Itoa(secs), "server-protocol": protocol.ProtocolVersion},
+		Body:     fmt.Sprintf("\n# Rate limited\n\nToo many requests. Retry in %d seconds.\n", secs),
+	}
+	_ = stream.SetWriteDeadline(time.Now().Add(time.Second))
+	if _, err := resp.WriteTo(stream); err != nil {
+		logger.Debug("write rate-limited response failed", "error", err)
 	}
+	_ = stream.Close()
 }
 
+// openConns counts the connections being served, for the SIGUSR1 state
+// dump.
+var openConns atomic.Int64
+
 var (
 	certMu      sync.RWMutex
 	currentCert *tls.Certificate
@@ -244,6 +480,21 @@ func loadTokenStore(path string) error {
 	return nil
 }
 
+// preload describes every document in the store so the first request for
+// each after a restart is served from the cache, logging its progress.
+func preload(s *store.Store, logger *slog.Logger) {
+	start := time.Now()
+	logger.Info("preloading document info")
+	n, err := s.Preload(func(described int) {
+		logger.Info("preloading document info", "documents", described, "elapsed", time.Since(start).Round(time.Millisecond).String())
+	})
+	if err != nil {
+		logger.Warn("preloading document info failed", "documents", n, "error", err)
+		return
+	}
+	logger.Info("document info preloaded", "documents", n, "elapsed", time.Since(start).Round(time.Millisecond).String())
+}
+
 // loadTLS returns a TLS config based on the server configuration.
 // In production mode (cert+key provided), uses GetCertificate callback
 // so certificates can be reloaded at runtime via SIGHUP.
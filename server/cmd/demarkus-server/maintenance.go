@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// defaultMaintenanceBanner is served in maintenance mode when the
+// maintenance file is empty.
+const defaultMaintenanceBanner = "# Down for maintenance\n\nThis server is down for maintenance. Try again later.\n"
+
+// maintenanceBanner is the banner every request is answered with while the
+// server is in maintenance mode, or nil when it is not.
+var maintenanceBanner atomic.Pointer[string]
+
+// currentMaintenance reports whether the server is in maintenance mode,
+// and its banner, for handler.Handler.Maintenance.
+func currentMaintenance() (string, bool) {
+	banner := maintenanceBanner.Load()
+	if banner == nil {
+		return "", false
+	}
+	return *banner, true
+}
+
+// loadMaintenance puts the server in maintenance mode while the
+// maintenance file exists, with the file's markdown as banner, and takes
+// it out when the file is gone. Changes of mode are logged.
+func loadMaintenance(path string, logger *slog.Logger) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		if maintenanceBanner.Swap(nil) != nil {
+			logger.Info("maintenance mode off", "path", path)
+		}
+		return
+	}
+	if err != nil {
+		// Keep the current mode rather than guess.
+		logger.Error("maintenance file unreadable", "path", path, "error", err)
+		return
+	}
+	banner := string(data)
+	if strings.TrimSpace(banner) == "" {
+		banner = defaultMaintenanceBanner
+	}
+	if maintenanceBanner.Swap(&banner) == nil {
+		logger.Warn("maintenance mode on: every request is answered with the banner", "path", path)
+	}
+}
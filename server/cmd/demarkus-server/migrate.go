@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/latebit/demarkus/server/internal/config"
+	"github.com/latebit/demarkus/server/internal/store"
+)
+
+// runMigrate implements "demarkus-server migrate": it upgrades a content
+// directory to the current store layout, or with -dry-run reports what
+// that would change. It returns the process exit code.
+func runMigrate(args []string) int {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	root := fs.String("root", "", "content directory to migrate (overrides DEMARKUS_ROOT)")
+	dryRun := fs.Bool("dry-run", false, "report the changes without making them")
+	backup := fs.String("backup", "", "directory for copies of changed files (default: <root>.backup-<time>)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: demarkus-server migrate [-root DIR] [-dry-run] [-backup DIR]\n\n")
+		fmt.Fprintf(os.Stderr, "Upgrades a content directory in place to store layout version %d.\n", store.LayoutVersion)
+		fmt.Fprintf(os.Stderr, "Stop the server first; every changed file is copied to the backup directory.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	dir := *root
+	if dir == "" {
+		cfg, _ := config.NewConfig()
+		dir = cfg.ContentDir
+	}
+	if dir == "" {
+		fmt.Fprintln(os.Stderr, "error: content directory is required (set DEMARKUS_ROOT or use -root)")
+		return 2
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		fmt.Fprintf(os.Stderr, "error: %s is not a directory\n", dir)
+		return 1
+	}
+
+	s := store.New(dir)
+	from, plan, err := s.PlanMigration()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	if from == store.LayoutVersion {
+		fmt.Printf("%s is already at layout version %d\n", dir, from)
+		return 0
+	}
+	fmt.Printf("%s: layout version %d -> %d, %d change(s)\n", dir, from, store.LayoutVersion, len(plan))
+	if *dryRun {
+		for _, step := range plan {
+			fmt.Printf("  %s\n", step)
+		}
+		return 0
+	}
+
+	backupDir := *backup
+	if backupDir == "" {
+		// Beside the content directory, never inside it.
+		backupDir = fmt.Sprintf("%s.backup-%s", filepath.Clean(dir), time.Now().Format("20060102-150405"))
+	}
+	done, err := s.Migrate(backupDir)
+	for _, step := range done {
+		fmt.Printf("  done  %s\n", step)
+	}
+	if len(done) > 0 {
+		fmt.Printf("originals copied to %s\n", backupDir)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		fmt.Fprintln(os.Stderr, "fix the problem and run migrate again; completed steps are not repeated")
+		return 1
+	}
+	return 0
+}
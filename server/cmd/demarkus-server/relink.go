@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/latebit/demarkus/server/internal/config"
+	"github.com/latebit/demarkus/server/internal/store"
+	"github.com/latebit/demarkus/server/internal/transform"
+)
+
+// runRelink implements "demarkus-server relink": it rewrites absolute
+// mark:// links to one host across every current document, as new
+// versions written together, or with -dry-run lists the documents that
+// would change. It returns the process exit code.
+func runRelink(args []string) int {
+	fs := flag.NewFlagSet("relink", flag.ExitOnError)
+	root := fs.String("root", "", "content directory (overrides DEMARKUS_ROOT)")
+	dryRun := fs.Bool("dry-run", false, "list the documents that would change without writing them")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: demarkus-server relink [-root DIR] [-dry-run] OLD-HOST NEW-HOST\n\n")
+		fmt.Fprintf(os.Stderr, "Rewrites mark://OLD-HOST links to mark://NEW-HOST in every current document,\n")
+		fmt.Fprintf(os.Stderr, "adding a version to each one that changes. Either every document is rewritten\n")
+		fmt.Fprintf(os.Stderr, "or none is. Stop the server first.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+	from, to := fs.Arg(0), fs.Arg(1)
+
+	cfg, _ := config.NewConfig()
+	dir := *root
+	if dir == "" {
+		dir = cfg.ContentDir
+	}
+	if dir == "" {
+		fmt.Fprintln(os.Stderr, "error: content directory is required (set DEMARKUS_ROOT or use -root)")
+		return 2
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		fmt.Fprintf(os.Stderr, "error: %s is not a directory\n", dir)
+		return 1
+	}
+
+	s := store.New(dir)
+	if cfg.Journal {
+		s.EnableJournal()
+	}
+	rewrites, err := s.RewriteAll(func(_ string, body []byte) []byte {
+		return []byte(transform.RelinkHost(string(body), from, to))
+	}, *dryRun)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		fmt.Fprintln(os.Stderr, "no document was rewritten")
+		return 1
+	}
+	for _, r := range rewrites {
+		if *dryRun {
+			fmt.Printf("  %s (v%d)\n", r.Path, r.Version)
+		} else {
+			fmt.Printf("  %s -> v%d\n", r.Path, r.Version)
+		}
+	}
+	verb := "rewrote"
+	if *dryRun {
+		verb = "would rewrite"
+	}
+	fmt.Printf("%s: %s links to %s in %d document(s)\n", dir, verb, from, len(rewrites))
+	return 0
+}
@@ -9,13 +9,15 @@ import (
 	"syscall"
 
 	"github.com/latebit/demarkus/server/internal/config"
+	"github.com/latebit/demarkus/server/internal/store"
 )
 
-func startCertReloader(cfg *config.Config, prodMode bool, logger *slog.Logger) {
+func startCertReloader(cfg *config.Config, prodMode bool, s *store.Store, logger *slog.Logger) {
 	sighupChan := make(chan os.Signal, 1)
 	signal.Notify(sighupChan, syscall.SIGHUP)
 	go func() {
 		for range sighupChan {
+			s.ResetRoot()
 			if prodMode {
 				if err := loadCert(cfg.TLSCert, cfg.TLSKey); err != nil {
 					logger.Error("tls: certificate reload failed", "error", err)
@@ -23,6 +25,9 @@ func startCertReloader(cfg *config.Config, prodMode bool, logger *slog.Logger) {
 					logger.Info("tls: certificate reloaded", "path", cfg.TLSCert)
 				}
 			}
+			if cfg.MaintenanceFile != "" {
+				loadMaintenance(cfg.MaintenanceFile, logger)
+			}
 			if cfg.TokensFile != "" {
 				if err := loadTokenStore(cfg.TokensFile); err != nil {
 					logger.Error("auth: token reload failed", "error", err)
@@ -6,8 +6,10 @@ import (
 	"log/slog"
 
 	"github.com/latebit/demarkus/server/internal/config"
+	"github.com/latebit/demarkus/server/internal/store"
 )
 
-func startCertReloader(_ *config.Config, _ bool, _ *slog.Logger) {
-	// SIGHUP is not available on Windows. Certificate reload requires a server restart.
+func startCertReloader(_ *config.Config, _ bool, _ *store.Store, _ *slog.Logger) {
+	// SIGHUP is not available on Windows. Certificate reload, and entering
+	// or leaving maintenance mode, require a server restart.
 }
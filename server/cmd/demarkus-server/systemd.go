@@ -0,0 +1,70 @@
+package main
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/latebit/demarkus/server/internal/systemd"
+	"github.com/quic-go/quic-go"
+)
+
+// listen uses the UDP socket passed by systemd socket activation when there
+// is one, so the port stays bound across restarts and clients queue in the
+// kernel instead of being refused. Otherwise it binds port itself on
+// network: "udp" for dual-stack, "udp4" or "udp6" for a single family.
+func listen(network string, port int, tlsConfig *tls.Config, quicConfig *quic.Config, logger *slog.Logger) (*quic.Listener, error) {
+	conns, err := systemd.PacketConns()
+	if err != nil {
+		return nil, err
+	}
+	if len(conns) == 0 {
+		conn, err := net.ListenUDP(network, &net.UDPAddr{Port: port})
+		if err != nil {
+			return nil, err
+		}
+		return quic.Listen(conn, tlsConfig, quicConfig)
+	}
+	for _, extra := range conns[1:] {
+		logger.Warn("systemd: ignoring extra activated socket", "addr", extra.LocalAddr().String())
+		_ = extra.Close()
+	}
+	// The unit's ListenDatagram= decides the address family.
+	logger.Info("systemd: using activated socket", "addr", conns[0].LocalAddr().String())
+	return quic.Listen(conns[0], tlsConfig, quicConfig)
+}
+
+// notifySystemd sends state to systemd when running under Type=notify.
+func notifySystemd(state string, logger *slog.Logger) {
+	if _, err := systemd.Notify(state); err != nil {
+		logger.Warn("systemd notify failed", "state", state, "error", err)
+	}
+}
+
+// startWatchdog pings the systemd watchdog at half the configured interval
+// and returns a function that stops it. It does nothing unless the unit sets
+// WatchdogSec=.
+func startWatchdog(logger *slog.Logger) func() {
+	interval := systemd.WatchdogInterval()
+	if interval == 0 {
+		return func() {}
+	}
+	logger.Info("systemd: watchdog enabled", "interval", interval.String())
+	ticker := time.NewTicker(interval / 2)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				notifySystemd("WATCHDOG=1", logger)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
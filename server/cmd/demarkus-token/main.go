@@ -45,10 +45,12 @@ func cmdGenerate(args []string) {
 	fs := flag.NewFlagSet("generate", flag.ExitOnError)
 	label := fs.String("label", "", "human-readable label for this token (required)")
 	paths := fs.String("paths", "/*", "comma-separated path patterns (e.g. \"/docs/*,/public/*\")")
-	ops := fs.String("ops", "publish", "comma-separated operations (e.g. \"read,publish\")")
+	ops := fs.String("ops", "publish", "comma-separated operations: read, publish, versions, delete (e.g. \"read,publish\")")
 	tokensFile := fs.String("tokens", "", "path to tokens.toml file (appends entry if provided)")
+	meta := metaFlag{}
+	fs.Var(meta, "meta", "metadata key=value set on documents the token writes (repeatable, e.g. -meta author=ci-bot)")
 	fs.Usage = func() {
-		fmt.Fprintf(os.Stderr, "usage: demarkus-token generate -label NAME [-paths PATTERNS] [-ops OPERATIONS] [-tokens FILE]\n\n")
+		fmt.Fprintf(os.Stderr, "usage: demarkus-token generate -label NAME [-paths PATTERNS] [-ops OPERATIONS] [-meta key=value ...] [-tokens FILE]\n\n")
 		fmt.Fprintf(os.Stderr, "Generates a cryptographically random auth token for the Mark Protocol server.\n\n")
 		fs.PrintDefaults()
 	}
@@ -70,6 +72,10 @@ func cmdGenerate(args []string) {
 	rawToken := hex.EncodeToString(secret)
 	hashedToken := auth.HashToken(rawToken)
 
+	if err := auth.ValidateMeta(meta); err != nil {
+		log.Fatalf("invalid -meta: %v", err)
+	}
+
 	pathList := splitTrimmed(*paths)
 	opsList := splitTrimmed(*ops)
 
@@ -80,6 +86,9 @@ func cmdGenerate(args []string) {
 		quotedList(pathList),
 		quotedList(opsList),
 	)
+	if len(meta) > 0 {
+		entry += fmt.Sprintf("meta = { %s }\n", inlineTable(meta))
+	}
 
 	if *tokensFile != "" {
 		// Check for duplicate label in existing file.
@@ -125,6 +134,8 @@ type tokenEntry struct {
 	Paths      []string `toml:"paths"`
 	Operations []string `toml:"operations"`
 	Expires    string   `toml:"expires,omitempty"`
+
+	Meta map[string]string `toml:"meta,omitempty"`
 }
 
 func cmdList(args []string) {
@@ -162,7 +173,11 @@ func cmdList(args []string) {
 		tok := tf.Tokens[label]
 		paths := strings.Join(tok.Paths, ", ")
 		ops := strings.Join(tok.Operations, ", ")
-		fmt.Printf("%-20s  paths: %-20s  ops: %s\n", label, paths, ops)
+		line := fmt.Sprintf("%-20s  paths: %-20s  ops: %s", label, paths, ops)
+		if len(tok.Meta) > 0 {
+			line += "  meta: " + inlineTable(tok.Meta)
+		}
+		fmt.Println(line)
 	}
 }
 
@@ -236,3 +251,31 @@ func quotedList(items []string) string {
 	}
 	return strings.Join(quoted, ", ")
 }
+
+// inlineTable formats meta as the body of a TOML inline table, keys sorted.
+func inlineTable(meta map[string]string) string {
+	keys := make([]string, 0, len(meta))
+	for k := range meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = fmt.Sprintf("%q = %q", k, meta[k])
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// metaFlag collects repeated -meta key=value flags.
+type metaFlag map[string]string
+
+func (m metaFlag) String() string { return "" }
+
+func (m metaFlag) Set(s string) error {
+	k, v, ok := strings.Cut(s, "=")
+	if !ok || k == "" {
+		return fmt.Errorf("expected key=value, got %q", s)
+	}
+	m[k] = v
+	return nil
+}
@@ -5,6 +5,7 @@ go 1.26
 require (
 	github.com/latebit/demarkus/protocol v0.0.0
 	github.com/quic-go/quic-go v0.59.0
+	github.com/yuin/goldmark v1.7.8
 )
 
 require (
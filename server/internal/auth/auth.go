@@ -1,7 +1,7 @@
 // Package auth provides capability-based token authentication for the Mark Protocol.
 //
 // Tokens are loaded from a TOML file at startup. Each token grants specific
-// operations (read, publish) on specific path patterns. Tokens are capability-based:
+// operations (read, publish, versions, delete) on specific path patterns. Tokens are capability-based:
 // they grant what you can do, not who you are.
 //
 // This design supports both human and AI/agent access — tokens can be scoped
@@ -14,6 +14,7 @@
 //	hash = "sha256-abc123..."
 //	paths = ["/docs/*"]
 //	operations = ["publish"]
+//	meta = { author = "fritz" }  # optional metadata set on its writes
 package auth
 
 import (
@@ -27,6 +28,7 @@ import (
 	"time"
 
 	"github.com/BurntSushi/toml"
+	"github.com/latebit/demarkus/protocol"
 )
 
 // Token represents a single capability token's permissions.
@@ -37,6 +39,11 @@ type Token struct {
 	Label      string    `toml:"-"`       // set from TOML key, not stored in file
 	Expires    string    `toml:"expires"` // RFC 3339 timestamp, empty means no expiry
 	expiresAt  time.Time // parsed from Expires at load time
+
+	// Meta is publisher metadata added to every document the token
+	// publishes or appends to, replacing any value the request sets, so
+	// that provenance such as an author is recorded whatever the tool sends.
+	Meta map[string]string `toml:"meta"`
 }
 
 // tokensFile is the top-level TOML structure.
@@ -46,9 +53,10 @@ type tokensFile struct {
 
 // TokenStore holds loaded tokens and provides authorization checks.
 type TokenStore struct {
-	tokens    map[string]Token // keyed by hash for fast lookup
-	readPaths []string         // pre-computed path patterns from tokens with "read" op
-	now       func() time.Time // injectable clock for testing
+	tokens        map[string]Token // keyed by hash for fast lookup
+	readPaths     []string         // pre-computed path patterns from tokens with "read" op
+	versionsPaths []string         // pre-computed path patterns from tokens with "versions" op
+	now           func() time.Time // injectable clock for testing
 }
 
 // Sentinel errors for authorization results.
@@ -89,30 +97,38 @@ func LoadTokens(filePath string) (*TokenStore, error) {
 			tok.expiresAt = t
 		}
 		for _, p := range tok.Paths {
-			if err := validatePattern(p); err != nil {
+			if err := ValidatePattern(p); err != nil {
 				return nil, fmt.Errorf("token %q has invalid path pattern %q: %w", label, p, err)
 			}
 		}
+		if err := ValidateMeta(tok.Meta); err != nil {
+			return nil, fmt.Errorf("token %q has invalid meta: %w", label, err)
+		}
 		if existing, ok := byHash[tok.Hash]; ok {
 			return nil, fmt.Errorf("duplicate hash for labels %q and %q", existing.Label, label)
 		}
 		byHash[tok.Hash] = tok
 	}
-	return &TokenStore{tokens: byHash, readPaths: collectReadPaths(byHash), now: time.Now}, nil
+	return NewTokenStore(byHash), nil
 }
 
 // NewTokenStore creates a TokenStore from an in-memory token map keyed by hash.
 func NewTokenStore(tokens map[string]Token) *TokenStore {
-	return &TokenStore{tokens: tokens, readPaths: collectReadPaths(tokens), now: time.Now}
+	return &TokenStore{
+		tokens:        tokens,
+		readPaths:     collectPaths(tokens, "read"),
+		versionsPaths: collectPaths(tokens, "versions"),
+		now:           time.Now,
+	}
 }
 
-// collectReadPaths extracts path patterns from all tokens that have "read"
-// in their operations. Called once at load time so RequiresReadAuth avoids
-// iterating tokens on every request.
-func collectReadPaths(tokens map[string]Token) []string {
+// collectPaths extracts path patterns from all tokens that have operation
+// in their operations. Called once at load time so RequiresReadAuth and
+// RequiresVersionsAuth avoid iterating tokens on every request.
+func collectPaths(tokens map[string]Token, operation string) []string {
 	var paths []string
 	for _, tok := range tokens {
-		if hasOperation(tok.Operations, "read") {
+		if hasOperation(tok.Operations, operation) {
 			paths = append(paths, tok.Paths...)
 		}
 	}
@@ -126,6 +142,14 @@ func (ts *TokenStore) RequiresReadAuth(reqPath string) bool {
 	return matchesAnyPath(ts.readPaths, reqPath)
 }
 
+// RequiresVersionsAuth reports whether any versions token covers the given
+// path. If true, its history (VERSIONS and version-pinned FETCH) is
+// private: the caller must authorize with a valid versions token, on top
+// of any read token the path requires.
+func (ts *TokenStore) RequiresVersionsAuth(reqPath string) bool {
+	return matchesAnyPath(ts.versionsPaths, reqPath)
+}
+
 // HashToken returns the SHA-256 hash of a raw token in the format "sha256-<hex>".
 // The TOML tokens file stores these hashes. Clients send the raw secret,
 // and the server hashes it before lookup — so the tokens file never contains
@@ -168,6 +192,55 @@ func (ts *TokenStore) Authorize(token, reqPath, operation string) (string, error
 	return t.Label, nil
 }
 
+// Lookup returns the token whose raw secret is token, so that its holder
+// can see what it grants. Errors are as for Authorize, except that an
+// expired token is returned along with ErrTokenExpired, so that callers can
+// say when it expired.
+func (ts *TokenStore) Lookup(token string) (Token, error) {
+	if token == "" {
+		return Token{}, ErrNoToken
+	}
+	t, ok := ts.tokens[HashToken(token)]
+	if !ok {
+		return Token{}, ErrInvalidToken
+	}
+	if !t.expiresAt.IsZero() && ts.now().After(t.expiresAt) {
+		return t, ErrTokenExpired
+	}
+	return t, nil
+}
+
+// ValidateMeta checks a token's metadata: publisher keys only,
+// within the protocol's limits on their number and size.
+func ValidateMeta(meta map[string]string) error {
+	size := 0
+	for k, v := range meta {
+		if !protocol.IsPublisherKey(k) {
+			return fmt.Errorf("key %q is reserved", k)
+		}
+		if !protocol.IsValidMetaKey(k) {
+			return fmt.Errorf("key %q contains invalid characters", k)
+		}
+		if !protocol.IsValidMetaValue(v) {
+			return fmt.Errorf("value for key %q contains newlines", k)
+		}
+		size += len(k) + len(v)
+	}
+	if len(meta) > protocol.MaxMetaKeys {
+		return fmt.Errorf("too many keys (max %d)", protocol.MaxMetaKeys)
+	}
+	if size > protocol.MaxMetaBytes {
+		return fmt.Errorf("too large (max %d bytes)", protocol.MaxMetaBytes)
+	}
+	return nil
+}
+
+// ExpiresAt returns when the token expires, or the zero time if it never
+// does.
+func (t Token) ExpiresAt() time.Time {
+	return t.expiresAt
+}
+
 func hasOperation(ops []string, target string) bool {
 	return slices.Contains(ops, target)
 }
@@ -185,19 +258,19 @@ func hasOperation(ops []string, target string) bool {
 // forward slashes, and filepath.Match behavior varies by OS.
 func matchesAnyPath(patterns []string, reqPath string) bool {
 	for _, pattern := range patterns {
-		if matchPath(pattern, reqPath) {
+		if MatchPath(pattern, reqPath) {
 			return true
 		}
 	}
 	return false
 }
 
-// matchPath checks a single pattern against a path. It handles ** globs
-// by splitting on /**/ and checking prefix + suffix, falling back to
+// MatchPath reports whether reqPath matches a path pattern. It handles **
+// globs by splitting on /**/ and checking prefix + suffix, falling back to
 // path.Match for patterns without **.
-// Patterns are validated at load time by validatePattern, so path.Match
-// errors are unreachable here and safely ignored.
-func matchPath(pattern, reqPath string) bool {
+// Patterns must be checked with ValidatePattern beforehand; path.Match
+// errors are treated as no match.
+func MatchPath(pattern, reqPath string) bool {
 	if !strings.Contains(pattern, "**") {
 		matched, _ := path.Match(pattern, reqPath)
 		return matched
@@ -231,10 +304,10 @@ func matchPath(pattern, reqPath string) bool {
 	return false
 }
 
-// validatePattern checks that a glob pattern has valid syntax. At most one
+// ValidatePattern checks that a glob pattern has valid syntax. At most one
 // ** wildcard is supported, and it must appear as /** (trailing) or /**/
 // (infix). Bare ** without surrounding slashes is rejected.
-func validatePattern(pattern string) error {
+func ValidatePattern(pattern string) error {
 	if n := strings.Count(pattern, "**"); n > 1 {
 		return fmt.Errorf("only one ** wildcard is supported per pattern")
 	} else if n == 1 {
@@ -177,6 +177,47 @@ expires = "not-a-date"
 			t.Fatal("expected error for invalid expires format")
 		}
 	})
+
+	t.Run("default meta", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "tokens.toml")
+		data := `[tokens.ci]
+hash = "sha256-ci"
+paths = ["/*"]
+operations = ["publish"]
+meta = { author = "ci-bot", tags = "generated" }
+`
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		ts, err := LoadTokens(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := ts.tokens["sha256-ci"].Meta; got["author"] != "ci-bot" || got["tags"] != "generated" {
+			t.Errorf("meta: got %v", got)
+		}
+	})
+
+	t.Run("reserved meta key", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "tokens.toml")
+		data := `[tokens.bad]
+hash = "sha256-bad"
+paths = ["/*"]
+operations = ["publish"]
+meta = { version = "7" }
+`
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := LoadTokens(path)
+		if err == nil {
+			t.Fatal("expected error for reserved meta key")
+		}
+	})
 }
 
 func TestHashToken(t *testing.T) {
@@ -309,6 +350,30 @@ func TestAuthorizeExpiration(t *testing.T) {
 	})
 }
 
+func TestLookup(t *testing.T) {
+	expires := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	ts := NewTokenStore(map[string]Token{
+		HashToken("live"):    {Label: "laptop", Paths: []string{"/docs/**"}, Operations: []string{"publish"}},
+		HashToken("expired"): {Label: "old", Paths: []string{"/*"}, Operations: []string{"read"}, expiresAt: expires},
+	})
+	ts.now = func() time.Time { return time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC) }
+
+	tok, err := ts.Lookup("live")
+	if err != nil || tok.Label != "laptop" || !tok.ExpiresAt().IsZero() {
+		t.Errorf("live token: %+v, %v", tok, err)
+	}
+	tok, err = ts.Lookup("expired")
+	if !errors.Is(err, ErrTokenExpired) || tok.Label != "old" || !tok.ExpiresAt().Equal(expires) {
+		t.Errorf("expired token: %+v, %v", tok, err)
+	}
+	if _, err := ts.Lookup("unknown"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("unknown token: %v", err)
+	}
+	if _, err := ts.Lookup(""); !errors.Is(err, ErrNoToken) {
+		t.Errorf("empty token: %v", err)
+	}
+}
+
 func TestAuthorizeRecursiveGlob(t *testing.T) {
 	const secret = "recursive-secret"
 
@@ -460,3 +525,22 @@ func TestRequiresReadAuth(t *testing.T) {
 		})
 	}
 }
+
+func TestRequiresVersionsAuth(t *testing.T) {
+	ts := NewTokenStore(map[string]Token{
+		"sha256-read":    {Paths: []string{"/**"}, Operations: []string{"read"}},
+		"sha256-history": {Paths: []string{"/docs/**"}, Operations: []string{"versions"}},
+	})
+	if !ts.RequiresVersionsAuth("/docs/a.md") {
+		t.Error("path covered by versions token: got false")
+	}
+	if ts.RequiresVersionsAuth("/blog/a.md") {
+		t.Error("read token protected history: got true")
+	}
+	historyOnly := NewTokenStore(map[string]Token{
+		"sha256-history": {Paths: []string{"/docs/**"}, Operations: []string{"versions"}},
+	})
+	if historyOnly.RequiresReadAuth("/docs/a.md") {
+		t.Error("versions token protected current content: got true")
+	}
+}
@@ -4,27 +4,65 @@ package config
 import (
 	"errors"
 	"fmt"
+	"log/slog"
+	"maps"
+	"net/url"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/latebit/demarkus/protocol"
+	"github.com/latebit/demarkus/server/internal/auth"
+	"github.com/latebit/demarkus/server/internal/handler"
+	"github.com/latebit/demarkus/server/internal/scan"
+	"github.com/latebit/demarkus/server/internal/transform"
 )
 
 // Config holds the server configuration.
 type Config struct {
-	Port           int
-	ContentDir     string
-	MaxStreams     int
-	IdleTimeout    time.Duration // Timeout for idle connections
-	RequestTimeout time.Duration // Timeout for handling a single request
-	TLSCert        string        // Path to TLS certificate PEM file (empty = dev mode)
-	TLSKey         string        // Path to TLS private key PEM file (empty = dev mode)
-	TokensFile     string        // Path to TOML tokens file (empty = no auth)
-	RateLimit      float64       // Requests per second per IP (0 = disabled)
-	RateBurst      int           // Burst size for rate limiter
-	LogFormat      string        // Log format: "text" (default) or "json"
-	LogLevel       string        // Log level: "debug", "info" (default), "warn", "error"
+	Port            int
+	AddressFamily   string // Address family to listen on: "dual" (default), "v4" or "v6"
+	ContentDir      string
+	MaxStreams      int
+	IdleTimeout     time.Duration            // Timeout for idle connections
+	RequestTimeout  time.Duration            // Timeout for handling a single request
+	VerbTimeouts    map[string]time.Duration // Per-verb overrides of RequestTimeout
+	TLSCert         string                   // Path to TLS certificate PEM file (empty = dev mode)
+	TLSKey          string                   // Path to TLS private key PEM file (empty = dev mode)
+	TokensFile      string                   // Path to TOML tokens file (empty = no auth)
+	RateLimit       float64                  // Requests per second per IP (0 = disabled)
+	RateBurst       int                      // Burst size for rate limiter
+	RateAdaptive    int                      // In-flight streams above which rate limits tighten (0 = disabled)
+	BanAfter        int                      // Strikes within BanWindow that ban a client (0 = disabled)
+	BanWindow       time.Duration            // Window in which strikes are counted
+	BanDuration     time.Duration            // How long a ban lasts
+	BanFile         string                   // File bans are persisted to (empty = memory only)
+	AbuseWebhook    string                   // URL the periodic abuse summary is POSTed to (empty = log only)
+	AbuseReport     time.Duration            // Interval between abuse summaries
+	LogFormat       string                   // Log format: "text" (default) or "json"
+	LogLevel        string                   // Log level: "debug", "info" (default), "warn", "error"
+	DenyPaths       []string                 // Path patterns never served (e.g. /private/**, *.secret.md)
+	LogIPs          string                   // Client IP handling in logs and rate limiting: "full" (default), "truncate", "hash"
+	LogRedactPaths  []string                 // Path prefixes whose full paths are never logged
+	DetectTampering bool                     // Compare fetched versions against recorded hashes
+	TOCPaths        []string                 // Directories that get a generated _toc.md
+	Journal         bool                     // Journal writes so startup can repair ones a crash interrupted
+	MaxVersions     int                      // Versions a document may have before writes are refused (0 = unlimited)
+	CompressAfter   int                      // Versions behind the current one before a version file is gzipped (0 = any)
+	CompressAge     time.Duration            // Age after which a version file is gzipped (0 = any)
+	Preload         bool                     // Describe every document in the background at startup
+	SigningKey      string                   // Path to ed25519 PKCS#8 PEM key signing responses (empty = unsigned)
+	MaintenanceFile string                   // File whose presence puts the server in maintenance mode (empty = never)
+	Transforms      []string                 // Transforms applied to FETCH responses, in order
+	LinkRewrites    []string                 // FROM=TO link prefixes for the rewrite-links transform
+	Redirects       []string                 // FROM=TO paths whose reads are answered with moved
+	Scan            string                   // Command line, or unix:PATH socket, scanning write bodies (empty = none)
+	ScanTimeout     time.Duration            // How long one scan may take
+	ScanFailOpen    bool                     // Accept writes whose body could not be scanned
+	PublishQueue    int                      // Async PUBLISHes that may wait to be written (0 = async ignored)
+	ChainScan       time.Duration            // Interval between background checks of every hash chain (0 = never)
 }
 
 // NewConfig loads configuration from environment variables.
@@ -33,42 +71,251 @@ func NewConfig() (*Config, error) {
 	config := &Config{}
 
 	config.Port = getEnvAsInt("DEMARKUS_PORT", protocol.DefaultPort)
+	config.AddressFamily = getEnv("DEMARKUS_ADDRESS_FAMILY", "dual")
 	config.ContentDir = getEnv("DEMARKUS_ROOT", "")
 	config.MaxStreams = getEnvAsInt("DEMARKUS_MAX_STREAMS", 10)
 	config.IdleTimeout = getEnvAsDuration("DEMARKUS_IDLE_TIMEOUT", 30*time.Second)
 	config.RequestTimeout = getEnvAsDuration("DEMARKUS_REQUEST_TIMEOUT", 10*time.Second)
+	config.VerbTimeouts = loadVerbTimeouts(config.RequestTimeout)
 	config.TLSCert = getEnv("DEMARKUS_TLS_CERT", "")
 	config.TLSKey = getEnv("DEMARKUS_TLS_KEY", "")
 	config.TokensFile = getEnv("DEMARKUS_TOKENS", "")
 	config.RateLimit = getEnvAsFloat64("DEMARKUS_RATE_LIMIT", 50)
 	config.RateBurst = getEnvAsInt("DEMARKUS_RATE_BURST", 100)
+	config.RateAdaptive = getEnvAsInt("DEMARKUS_RATE_ADAPTIVE", 0)
+	config.BanAfter = getEnvAsInt("DEMARKUS_BAN_AFTER", 0)
+	config.BanWindow = getEnvAsDuration("DEMARKUS_BAN_WINDOW", time.Minute)
+	config.BanDuration = getEnvAsDuration("DEMARKUS_BAN_DURATION", time.Hour)
+	config.BanFile = getEnv("DEMARKUS_BAN_FILE", "")
+	config.AbuseWebhook = getEnv("DEMARKUS_ABUSE_WEBHOOK", "")
+	config.AbuseReport = getEnvAsDuration("DEMARKUS_ABUSE_REPORT_INTERVAL", time.Hour)
 	config.LogFormat = getEnv("DEMARKUS_LOG_FORMAT", "text")
 	config.LogLevel = getEnv("DEMARKUS_LOG_LEVEL", "info")
+	config.DenyPaths = getEnvAsList("DEMARKUS_DENY_PATHS")
+	config.LogIPs = getEnv("DEMARKUS_LOG_IPS", "full")
+	config.LogRedactPaths = getEnvAsList("DEMARKUS_LOG_REDACT_PATHS")
+	config.DetectTampering = getEnvAsBool("DEMARKUS_DETECT_TAMPERING", false)
+	config.TOCPaths = getEnvAsList("DEMARKUS_TOC_PATHS")
+	config.Journal = getEnvAsBool("DEMARKUS_JOURNAL", false)
+	config.MaxVersions = getEnvAsInt("DEMARKUS_MAX_VERSIONS", 0)
+	config.CompressAfter = getEnvAsInt("DEMARKUS_COMPRESS_AFTER_VERSIONS", 0)
+	config.CompressAge = getEnvAsDuration("DEMARKUS_COMPRESS_AFTER_AGE", 0)
+	config.Preload = getEnvAsBool("DEMARKUS_PRELOAD", false)
+	config.SigningKey = getEnv("DEMARKUS_SIGNING_KEY", "")
+	config.MaintenanceFile = getEnv("DEMARKUS_MAINTENANCE_FILE", "")
+	config.Transforms = getEnvAsList("DEMARKUS_TRANSFORMS")
+	config.LinkRewrites = getEnvAsList("DEMARKUS_LINK_REWRITES")
+	config.Redirects = getEnvAsList("DEMARKUS_REDIRECTS")
+	config.Scan = getEnv("DEMARKUS_SCAN", "")
+	config.ScanTimeout = getEnvAsDuration("DEMARKUS_SCAN_TIMEOUT", scan.DefaultTimeout)
+	config.ScanFailOpen = getEnvAsBool("DEMARKUS_SCAN_FAIL_OPEN", false)
+	config.PublishQueue = getEnvAsInt("DEMARKUS_PUBLISH_QUEUE", DefaultPublishQueue)
+	config.ChainScan = getEnvAsDuration("DEMARKUS_CHAIN_SCAN_INTERVAL", DefaultChainScan)
 
-	if config.RateLimit < 0 {
-		return config, fmt.Errorf("DEMARKUS_RATE_LIMIT must be non-negative (got %v)", config.RateLimit)
+	return config, config.Validate()
+}
+
+// Validate checks settings that would make the server unsafe or unable to
+// start. It is run by NewConfig and again after flag overrides.
+func (c *Config) Validate() error {
+	if _, ok := listenNetworks[c.AddressFamily]; !ok {
+		return fmt.Errorf("DEMARKUS_ADDRESS_FAMILY must be dual, v4 or v6 (got %q)", c.AddressFamily)
+	}
+	if c.RateLimit < 0 {
+		return fmt.Errorf("DEMARKUS_RATE_LIMIT must be non-negative (got %v)", c.RateLimit)
+	}
+	if c.RateBurst < 0 {
+		return fmt.Errorf("DEMARKUS_RATE_BURST must be non-negative (got %d)", c.RateBurst)
+	}
+	if c.RateAdaptive < 0 {
+		return fmt.Errorf("DEMARKUS_RATE_ADAPTIVE must be non-negative (got %d)", c.RateAdaptive)
+	}
+	if c.RateLimit > 0 && c.RateBurst < 1 {
+		return fmt.Errorf("DEMARKUS_RATE_BURST must be at least 1 when rate limiting is enabled (got %d)", c.RateBurst)
+	}
+
+	if c.BanAfter < 0 {
+		return fmt.Errorf("DEMARKUS_BAN_AFTER must be non-negative (got %d)", c.BanAfter)
+	}
+	if c.BanAfter > 0 && (c.BanWindow <= 0 || c.BanDuration <= 0) {
+		return fmt.Errorf("DEMARKUS_BAN_WINDOW and DEMARKUS_BAN_DURATION must be positive when bans are enabled (got %v, %v)", c.BanWindow, c.BanDuration)
+	}
+	if c.MaxVersions < 0 {
+		return fmt.Errorf("DEMARKUS_MAX_VERSIONS must be non-negative (got %d)", c.MaxVersions)
+	}
+	if c.PublishQueue < 0 {
+		return fmt.Errorf("DEMARKUS_PUBLISH_QUEUE must be non-negative (got %d)", c.PublishQueue)
+	}
+	if c.ChainScan < 0 {
+		return fmt.Errorf("DEMARKUS_CHAIN_SCAN_INTERVAL must be non-negative (got %v)", c.ChainScan)
+	}
+	if c.CompressAfter < 0 {
+		return fmt.Errorf("DEMARKUS_COMPRESS_AFTER_VERSIONS must be non-negative (got %d)", c.CompressAfter)
+	}
+	if c.CompressAge < 0 {
+		return fmt.Errorf("DEMARKUS_COMPRESS_AFTER_AGE must be non-negative (got %v)", c.CompressAge)
 	}
-	if config.RateBurst < 0 {
-		return config, fmt.Errorf("DEMARKUS_RATE_BURST must be non-negative (got %d)", config.RateBurst)
+	if c.AbuseReport <= 0 {
+		return fmt.Errorf("DEMARKUS_ABUSE_REPORT_INTERVAL must be positive (got %v)", c.AbuseReport)
 	}
-	if config.RateLimit > 0 && config.RateBurst < 1 {
-		return config, fmt.Errorf("DEMARKUS_RATE_BURST must be at least 1 when rate limiting is enabled (got %d)", config.RateBurst)
+	if c.AbuseWebhook != "" {
+		if u, err := url.Parse(c.AbuseWebhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("DEMARKUS_ABUSE_WEBHOOK must be an http or https URL (got %q)", c.AbuseWebhook)
+		}
 	}
 
-	if config.ContentDir == "" {
-		return config, errors.New("DEMARKUS_ROOT environment variable is required")
+	for _, pattern := range c.DenyPaths {
+		if err := auth.ValidatePattern(pattern); err != nil {
+			return fmt.Errorf("DEMARKUS_DENY_PATHS: invalid pattern %q: %w", pattern, err)
+		}
+	}
+
+	for _, dir := range c.TOCPaths {
+		if !strings.HasPrefix(dir, "/") || strings.Contains(dir, "..") || strings.ContainsAny(dir, "*?[") {
+			return fmt.Errorf("DEMARKUS_TOC_PATHS: %q must be an absolute directory path without wildcards", dir)
+		}
+	}
+
+	if _, err := transform.New(c.Transforms, c.LinkRewrites); err != nil {
+		return fmt.Errorf("DEMARKUS_TRANSFORMS: %w", err)
+	}
+	if _, err := handler.ParseRedirects(c.Redirects); err != nil {
+		return fmt.Errorf("DEMARKUS_REDIRECTS: %w", err)
+	}
+	if c.ScanTimeout <= 0 {
+		return fmt.Errorf("DEMARKUS_SCAN_TIMEOUT must be positive (got %v)", c.ScanTimeout)
+	}
+	if c.Scan != "" {
+		if _, err := scan.New(c.Scan, c.ScanTimeout); err != nil {
+			return fmt.Errorf("DEMARKUS_SCAN: %w", err)
+		}
+	}
+
+	if c.ContentDir == "" {
+		return errors.New("content directory is required (set DEMARKUS_ROOT or use -root)")
 	}
 
 	// Validate content directory exists and is readable.
-	info, err := os.Stat(config.ContentDir)
+	info, err := os.Stat(c.ContentDir)
 	if err != nil {
-		return config, fmt.Errorf("content directory %q: %w", config.ContentDir, err)
+		return fmt.Errorf("content directory %q: %w", c.ContentDir, err)
 	}
 	if !info.IsDir() {
-		return config, fmt.Errorf("content directory %q is not a directory", config.ContentDir)
+		return fmt.Errorf("content directory %q is not a directory", c.ContentDir)
+	}
+
+	return nil
+}
+
+// listenNetworks maps each address family to the UDP network the server
+// listens on. "udp" on the unspecified address accepts both IPv4 and IPv6;
+// "udp6" accepts IPv6 only.
+var listenNetworks = map[string]string{
+	"dual": "udp",
+	"v4":   "udp4",
+	"v6":   "udp6",
+}
+
+// ListenNetwork returns the UDP network for the configured address family.
+func (c *Config) ListenNetwork() string {
+	return listenNetworks[c.AddressFamily]
+}
+
+// DefaultPublishQueue is how many PUBLISHes sent with async may wait to be
+// written when DEMARKUS_PUBLISH_QUEUE is not set.
+const DefaultPublishQueue = 16
+
+// DefaultChainScan is how often every document's hash chain is verified
+// in the background, for the chain-valid metadata of reads.
+const DefaultChainScan = 6 * time.Hour
+
+// writeVerbTimeout is the minimum default for verbs that upload a body,
+// which legitimately take longer to receive than a FETCH.
+const writeVerbTimeout = 30 * time.Second
+
+// loadVerbTimeouts reads DEMARKUS_REQUEST_TIMEOUT_<VERB> overrides. PUBLISH
+// and APPEND default to the longer of base and writeVerbTimeout.
+func loadVerbTimeouts(base time.Duration) map[string]time.Duration {
+	timeouts := map[string]time.Duration{
+		protocol.VerbPublish: max(base, writeVerbTimeout),
+		protocol.VerbAppend:  max(base, writeVerbTimeout),
 	}
+	for _, verb := range []string{
+		protocol.VerbFetch, protocol.VerbList, protocol.VerbVersions,
+		protocol.VerbPublish, protocol.VerbArchive, protocol.VerbAppend,
+		protocol.VerbSearch, protocol.VerbDiff, protocol.VerbPurge, protocol.VerbInfo, protocol.VerbMove,
+		protocol.VerbBatch, protocol.VerbBacklinks, protocol.VerbJob,
+	} {
+		if d := getEnvAsDuration("DEMARKUS_REQUEST_TIMEOUT_"+verb, 0); d > 0 {
+			timeouts[verb] = d
+		}
+	}
+	return timeouts
+}
 
-	return config, nil
+// TimeoutFor returns the request timeout for verb, falling back to
+// RequestTimeout when no override is configured.
+func (c *Config) TimeoutFor(verb string) time.Duration {
+	if d, ok := c.VerbTimeouts[verb]; ok {
+		return d
+	}
+	return c.RequestTimeout
+}
+
+// LogValue returns the configuration as a log group. The abuse webhook is
+// reduced to its scheme and host, since its path or query often carries a
+// secret.
+func (c *Config) LogValue() slog.Value {
+	webhook := ""
+	if u, err := url.Parse(c.AbuseWebhook); err == nil && u.Host != "" {
+		webhook = u.Scheme + "://" + u.Host
+	}
+	timeouts := make([]slog.Attr, 0, len(c.VerbTimeouts))
+	for _, verb := range slices.Sorted(maps.Keys(c.VerbTimeouts)) {
+		timeouts = append(timeouts, slog.String(verb, c.VerbTimeouts[verb].String()))
+	}
+	return slog.GroupValue(
+		slog.Int("port", c.Port),
+		slog.String("address_family", c.AddressFamily),
+		slog.String("root", c.ContentDir),
+		slog.Int("max_streams", c.MaxStreams),
+		slog.String("idle_timeout", c.IdleTimeout.String()),
+		slog.String("request_timeout", c.RequestTimeout.String()),
+		slog.Any("verb_timeouts", slog.GroupValue(timeouts...)),
+		slog.String("tls_cert", c.TLSCert),
+		slog.String("tokens", c.TokensFile),
+		slog.Float64("rate_limit", c.RateLimit),
+		slog.Int("rate_burst", c.RateBurst),
+		slog.Int("rate_adaptive", c.RateAdaptive),
+		slog.Int("ban_after", c.BanAfter),
+		slog.String("ban_window", c.BanWindow.String()),
+		slog.String("ban_duration", c.BanDuration.String()),
+		slog.String("ban_file", c.BanFile),
+		slog.String("abuse_webhook", webhook),
+		slog.String("abuse_report", c.AbuseReport.String()),
+		slog.String("log_format", c.LogFormat),
+		slog.String("log_level", c.LogLevel),
+		slog.Any("deny_paths", c.DenyPaths),
+		slog.String("log_ips", c.LogIPs),
+		slog.Int("log_redact_paths", len(c.LogRedactPaths)),
+		slog.Bool("detect_tampering", c.DetectTampering),
+		slog.Any("toc_paths", c.TOCPaths),
+		slog.Bool("journal", c.Journal),
+		slog.Int("max_versions", c.MaxVersions),
+		slog.Int("compress_after_versions", c.CompressAfter),
+		slog.String("compress_after_age", c.CompressAge.String()),
+		slog.Bool("preload", c.Preload),
+		slog.String("signing_key", c.SigningKey),
+		slog.String("maintenance_file", c.MaintenanceFile),
+		slog.Any("transforms", c.Transforms),
+		slog.Any("link_rewrites", c.LinkRewrites),
+		slog.Any("redirects", c.Redirects),
+		slog.String("scan", c.Scan),
+		slog.String("scan_timeout", c.ScanTimeout.String()),
+		slog.Bool("scan_fail_open", c.ScanFailOpen),
+		slog.Int("publish_queue", c.PublishQueue),
+		slog.String("chain_scan_interval", c.ChainScan.String()),
+	)
 }
 
 func getEnv(key, defaultValue string) string {
@@ -79,6 +326,17 @@ func getEnv(key, defaultValue string) string {
 	return value
 }
 
+// getEnvAsList splits a comma-separated variable, dropping empty items.
+func getEnvAsList(key string) []string {
+	var items []string
+	for item := range strings.SplitSeq(getEnv(key, ""), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func getEnvAsInt(key string, defaultValue int) int {
 	valueStr := getEnv(key, "")
 	if valueStr == "" {
@@ -91,6 +349,18 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return value
 }
 
+func getEnvAsBool(key string, defaultValue bool) bool {
+	valueStr := getEnv(key, "")
+	if valueStr == "" {
+		return defaultValue
+	}
+	value, err := strconv.ParseBool(valueStr)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
 func getEnvAsFloat64(key string, defaultValue float64) float64 {
 	valueStr := getEnv(key, "")
 	if valueStr == "" {
@@ -1,10 +1,16 @@
 package config
 
 import (
+	"bytes"
+	"log/slog"
 	"os"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/latebit/demarkus/protocol"
+	"github.com/latebit/demarkus/server/internal/scan"
 )
 
 func TestNewConfig_Defaults(t *testing.T) {
@@ -101,6 +107,25 @@ func TestNewConfig_RateBurstZeroWithLimitEnabled(t *testing.T) {
 	}
 }
 
+func TestNewConfig_RateAdaptive(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DEMARKUS_ROOT", dir)
+	t.Setenv("DEMARKUS_RATE_ADAPTIVE", "200")
+
+	cfg, err := NewConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.RateAdaptive != 200 {
+		t.Errorf("rate adaptive: got %d, want %d", cfg.RateAdaptive, 200)
+	}
+
+	t.Setenv("DEMARKUS_RATE_ADAPTIVE", "-1")
+	if _, err := NewConfig(); err == nil {
+		t.Fatal("expected error for negative adaptive threshold")
+	}
+}
+
 func TestNewConfig_NegativeRateLimit(t *testing.T) {
 	dir := t.TempDir()
 	t.Setenv("DEMARKUS_ROOT", dir)
@@ -181,3 +206,341 @@ func TestNewConfig_InvalidInt(t *testing.T) {
 		t.Errorf("port: got %d, want default %d", cfg.Port, protocol.DefaultPort)
 	}
 }
+
+func TestNewConfig_VerbTimeouts(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DEMARKUS_ROOT", dir)
+	t.Setenv("DEMARKUS_REQUEST_TIMEOUT_PUBLISH", "2m")
+	t.Setenv("DEMARKUS_REQUEST_TIMEOUT_FETCH", "5s")
+	t.Setenv("DEMARKUS_REQUEST_TIMEOUT_LIST", "bogus")
+
+	cfg, err := NewConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		verb string
+		want time.Duration
+	}{
+		{protocol.VerbPublish, 2 * time.Minute},
+		{protocol.VerbFetch, 5 * time.Second},
+		{protocol.VerbList, 10 * time.Second},
+		{protocol.VerbAppend, 30 * time.Second},
+		{"UNKNOWN", 10 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := cfg.TimeoutFor(tt.verb); got != tt.want {
+			t.Errorf("TimeoutFor(%s): got %v, want %v", tt.verb, got, tt.want)
+		}
+	}
+}
+
+func TestNewConfig_WriteTimeoutFollowsLongerBase(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DEMARKUS_ROOT", dir)
+	t.Setenv("DEMARKUS_REQUEST_TIMEOUT", "90s")
+
+	cfg, err := NewConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := cfg.TimeoutFor(protocol.VerbPublish); got != 90*time.Second {
+		t.Errorf("publish timeout: got %v, want %v", got, 90*time.Second)
+	}
+}
+
+func TestNewConfig_DenyPaths(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DEMARKUS_ROOT", dir)
+	t.Setenv("DEMARKUS_DENY_PATHS", " /private/** , *.secret.md,,")
+
+	cfg, err := NewConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"/private/**", "*.secret.md"}
+	if !slices.Equal(cfg.DenyPaths, want) {
+		t.Errorf("deny paths: got %q, want %q", cfg.DenyPaths, want)
+	}
+}
+
+func TestNewConfig_InvalidDenyPath(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DEMARKUS_ROOT", dir)
+	t.Setenv("DEMARKUS_DENY_PATHS", "/a/**/b/**")
+
+	if _, err := NewConfig(); err == nil {
+		t.Fatal("expected error for invalid deny pattern")
+	}
+}
+
+func TestNewConfig_LogPrivacy(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DEMARKUS_ROOT", dir)
+	t.Setenv("DEMARKUS_LOG_IPS", "hash")
+	t.Setenv("DEMARKUS_LOG_REDACT_PATHS", "/medical,/hr/")
+
+	cfg, err := NewConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.LogIPs != "hash" {
+		t.Errorf("log ips: got %q, want %q", cfg.LogIPs, "hash")
+	}
+	if want := []string{"/medical", "/hr/"}; !slices.Equal(cfg.LogRedactPaths, want) {
+		t.Errorf("redact paths: got %q, want %q", cfg.LogRedactPaths, want)
+	}
+}
+
+func TestNewConfig_Bans(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DEMARKUS_ROOT", dir)
+
+	cfg, err := NewConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.BanAfter != 0 || cfg.BanWindow != time.Minute || cfg.BanDuration != time.Hour || cfg.AbuseReport != time.Hour {
+		t.Errorf("defaults: got after=%d window=%v duration=%v report=%v", cfg.BanAfter, cfg.BanWindow, cfg.BanDuration, cfg.AbuseReport)
+	}
+
+	t.Setenv("DEMARKUS_BAN_AFTER", "20")
+	t.Setenv("DEMARKUS_BAN_FILE", "/var/lib/demarkus/bans")
+	t.Setenv("DEMARKUS_ABUSE_WEBHOOK", "https://hooks.example.com/abuse")
+	cfg, err = NewConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.BanAfter != 20 || cfg.BanFile != "/var/lib/demarkus/bans" || cfg.AbuseWebhook != "https://hooks.example.com/abuse" {
+		t.Errorf("got after=%d file=%q webhook=%q", cfg.BanAfter, cfg.BanFile, cfg.AbuseWebhook)
+	}
+
+	t.Setenv("DEMARKUS_ABUSE_WEBHOOK", "hooks.example.com/abuse")
+	if _, err := NewConfig(); err == nil {
+		t.Error("expected error for webhook without scheme")
+	}
+}
+
+func TestNewConfig_TOCPaths(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DEMARKUS_ROOT", dir)
+	t.Setenv("DEMARKUS_TOC_PATHS", "/docs, /guides/")
+
+	cfg, err := NewConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"/docs", "/guides/"}; !slices.Equal(cfg.TOCPaths, want) {
+		t.Errorf("toc paths: got %q, want %q", cfg.TOCPaths, want)
+	}
+
+	for _, bad := range []string{"docs", "/docs/**", "/docs/../etc"} {
+		t.Setenv("DEMARKUS_TOC_PATHS", bad)
+		if _, err := NewConfig(); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestNewConfig_MaxVersions(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DEMARKUS_ROOT", dir)
+	t.Setenv("DEMARKUS_MAX_VERSIONS", "500")
+
+	cfg, err := NewConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.MaxVersions != 500 {
+		t.Errorf("max versions: got %d, want 500", cfg.MaxVersions)
+	}
+
+	t.Setenv("DEMARKUS_MAX_VERSIONS", "-1")
+	if _, err := NewConfig(); err == nil {
+		t.Error("expected error for negative max versions")
+	}
+}
+
+func TestNewConfig_PublishQueue(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DEMARKUS_ROOT", dir)
+
+	cfg, err := NewConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.PublishQueue != DefaultPublishQueue {
+		t.Errorf("default publish queue: got %d, want %d", cfg.PublishQueue, DefaultPublishQueue)
+	}
+
+	t.Setenv("DEMARKUS_PUBLISH_QUEUE", "0")
+	if cfg, err = NewConfig(); err != nil || cfg.PublishQueue != 0 {
+		t.Errorf("disabled: got %d, %v", cfg.PublishQueue, err)
+	}
+	t.Setenv("DEMARKUS_PUBLISH_QUEUE", "-1")
+	if _, err := NewConfig(); err == nil {
+		t.Error("expected error for a negative publish queue")
+	}
+}
+
+func TestNewConfig_ChainScan(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DEMARKUS_ROOT", dir)
+
+	cfg, err := NewConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ChainScan != DefaultChainScan {
+		t.Errorf("default chain scan: got %v, want %v", cfg.ChainScan, DefaultChainScan)
+	}
+
+	t.Setenv("DEMARKUS_CHAIN_SCAN_INTERVAL", "0")
+	if cfg, err = NewConfig(); err != nil || cfg.ChainScan != 0 {
+		t.Errorf("disabled: got %v, %v", cfg.ChainScan, err)
+	}
+	t.Setenv("DEMARKUS_CHAIN_SCAN_INTERVAL", "-1h")
+	if _, err := NewConfig(); err == nil {
+		t.Error("expected error for a negative chain scan interval")
+	}
+}
+
+func TestNewConfig_Compress(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DEMARKUS_ROOT", dir)
+	t.Setenv("DEMARKUS_COMPRESS_AFTER_VERSIONS", "10")
+	t.Setenv("DEMARKUS_COMPRESS_AFTER_AGE", "720h")
+
+	cfg, err := NewConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.CompressAfter != 10 || cfg.CompressAge != 720*time.Hour {
+		t.Errorf("compress: got %d, %v, want 10, 720h", cfg.CompressAfter, cfg.CompressAge)
+	}
+
+	t.Setenv("DEMARKUS_COMPRESS_AFTER_VERSIONS", "-1")
+	if _, err := NewConfig(); err == nil {
+		t.Error("expected error for negative compress-after versions")
+	}
+}
+
+func TestNewConfig_Transforms(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DEMARKUS_ROOT", dir)
+	t.Setenv("DEMARKUS_TRANSFORMS", "emoji, rewrite-links")
+	t.Setenv("DEMARKUS_LINK_REWRITES", "mark://docs.example.com/=mark://mirror.example.org/")
+
+	cfg, err := NewConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"emoji", "rewrite-links"}; !slices.Equal(cfg.Transforms, want) {
+		t.Errorf("transforms: got %q, want %q", cfg.Transforms, want)
+	}
+
+	t.Setenv("DEMARKUS_LINK_REWRITES", "")
+	if _, err := NewConfig(); err == nil {
+		t.Error("expected error for rewrite-links without rewrites")
+	}
+	t.Setenv("DEMARKUS_TRANSFORMS", "emoji,smileys")
+	if _, err := NewConfig(); err == nil {
+		t.Error("expected error for an unknown transform")
+	}
+}
+
+func TestNewConfig_Redirects(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DEMARKUS_ROOT", dir)
+	t.Setenv("DEMARKUS_REDIRECTS", "/old.md=/new.md, /wiki/=mark://wiki.example.com/")
+
+	cfg, err := NewConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"/old.md=/new.md", "/wiki/=mark://wiki.example.com/"}; !slices.Equal(cfg.Redirects, want) {
+		t.Errorf("redirects: got %q, want %q", cfg.Redirects, want)
+	}
+
+	t.Setenv("DEMARKUS_REDIRECTS", "old.md=/new.md")
+	if _, err := NewConfig(); err == nil {
+		t.Error("expected error for a relative FROM")
+	}
+}
+
+func TestNewConfig_Scan(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DEMARKUS_ROOT", dir)
+
+	cfg, err := NewConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Scan != "" || cfg.ScanTimeout != scan.DefaultTimeout || cfg.ScanFailOpen {
+		t.Errorf("defaults: scan %q, timeout %v, fail open %v", cfg.Scan, cfg.ScanTimeout, cfg.ScanFailOpen)
+	}
+
+	t.Setenv("DEMARKUS_SCAN", "unix:/run/clamd.sock")
+	t.Setenv("DEMARKUS_SCAN_TIMEOUT", "3s")
+	t.Setenv("DEMARKUS_SCAN_FAIL_OPEN", "true")
+	if cfg, err = NewConfig(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Scan != "unix:/run/clamd.sock" || cfg.ScanTimeout != 3*time.Second || !cfg.ScanFailOpen {
+		t.Errorf("got scan %q, timeout %v, fail open %v", cfg.Scan, cfg.ScanTimeout, cfg.ScanFailOpen)
+	}
+
+	t.Setenv("DEMARKUS_SCAN", "unix:")
+	if _, err := NewConfig(); err == nil {
+		t.Error("expected error for a socket without a path")
+	}
+	t.Setenv("DEMARKUS_SCAN", "/usr/bin/scan")
+	t.Setenv("DEMARKUS_SCAN_TIMEOUT", "0s")
+	if _, err := NewConfig(); err == nil {
+		t.Error("expected error for a zero scan timeout")
+	}
+}
+
+func TestNewConfig_AddressFamily(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DEMARKUS_ROOT", dir)
+
+	for family, network := range map[string]string{"dual": "udp", "v4": "udp4", "v6": "udp6"} {
+		t.Setenv("DEMARKUS_ADDRESS_FAMILY", family)
+		cfg, err := NewConfig()
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", family, err)
+		}
+		if got := cfg.ListenNetwork(); got != network {
+			t.Errorf("%q: network = %q, want %q", family, got, network)
+		}
+	}
+
+	t.Setenv("DEMARKUS_ADDRESS_FAMILY", "ipv6")
+	if _, err := NewConfig(); err == nil {
+		t.Error("expected error for unknown address family")
+	}
+}
+
+func TestConfig_LogValue(t *testing.T) {
+	t.Setenv("DEMARKUS_ROOT", t.TempDir())
+	t.Setenv("DEMARKUS_ABUSE_WEBHOOK", "https://hooks.example.com/services/SECRET?token=SECRET")
+	t.Setenv("DEMARKUS_REQUEST_TIMEOUT_FETCH", "3s")
+
+	cfg, err := NewConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var buf bytes.Buffer
+	slog.New(slog.NewJSONHandler(&buf, nil)).Info("config", "config", cfg)
+	out := buf.String()
+	for _, want := range []string{`"port":6309`, `"abuse_webhook":"https://hooks.example.com"`, `"FETCH":"3s"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log output lacks %s: %s", want, out)
+		}
+	}
+	if strings.Contains(out, "SECRET") {
+		t.Errorf("webhook secret logged: %s", out)
+	}
+}
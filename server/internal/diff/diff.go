@@ -0,0 +1,173 @@
+// Package diff compares document versions line by line for the DIFF verb.
+// It produces the same unified format as the client's diff package, but
+// bounds the work a request can cause.
+package diff
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ErrTooManyChanges is returned when two texts differ by more lines than
+// the caller allows.
+var ErrTooManyChanges = errors.New("too many changed lines")
+
+// Op is the kind of an edit.
+type Op int
+
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+// Edit is one line of a line-by-line comparison.
+type Edit struct {
+	Op   Op
+	Line string
+}
+
+// Lines returns the shortest edit script turning a into b, using Myers'
+// algorithm, or ErrTooManyChanges if it would insert or delete more than
+// maxChanges lines. Memory grows with the square of the number of changes,
+// not with the length of the texts. Deletions come before insertions
+// within a change.
+func Lines(a, b []string, maxChanges int) ([]Edit, error) {
+	n, m := len(a), len(b)
+	maxD := min(n+m, maxChanges)
+	off := maxD + 1
+	v := make([]int, 2*maxD+3)
+	// trace[d] holds v for diagonals -d..d before round d.
+	var trace [][]int
+	d := 0
+search:
+	for ; ; d++ {
+		if d > maxD {
+			return nil, ErrTooManyChanges
+		}
+		trace = append(trace, slices.Clone(v[off-d:off+d+1]))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	var edits []Edit
+	x, y := n, m
+	for ; d > 0; d-- {
+		prev := trace[d] // index k+d
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && prev[k-1+d] < prev[k+1+d]) {
+			prevK = k + 1
+		}
+		prevX := prev[prevK+d]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			edits = append(edits, Edit{Equal, a[x-1]})
+			x--
+			y--
+		}
+		if x == prevX {
+			edits = append(edits, Edit{Insert, b[y-1]})
+		} else {
+			edits = append(edits, Edit{Delete, a[x-1]})
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		edits = append(edits, Edit{Equal, a[x-1]})
+		x--
+		y--
+	}
+	slices.Reverse(edits)
+	return edits, nil
+}
+
+// Unified returns the changes from a to b in unified diff format, with
+// context unchanged lines around each hunk, or ErrTooManyChanges as for
+// Lines. It returns "" when a and b have the same lines.
+func Unified(a, b string, context, maxChanges int) (string, error) {
+	edits, err := Lines(splitLines(a), splitLines(b), maxChanges)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(edits); {
+		if edits[i].Op == Equal {
+			i++
+			continue
+		}
+		// Extend the hunk while changes are closer than two contexts apart.
+		start := max(i-context, 0)
+		end := i
+		for j := i; j < len(edits); j++ {
+			if edits[j].Op != Equal {
+				end = j + 1
+			} else if j-end >= 2*context {
+				break
+			}
+		}
+		end = min(end+context, len(edits))
+
+		aLine, bLine := 1, 1
+		for _, e := range edits[:start] {
+			if e.Op != Insert {
+				aLine++
+			}
+			if e.Op != Delete {
+				bLine++
+			}
+		}
+		var aCount, bCount int
+		for _, e := range edits[start:end] {
+			if e.Op != Insert {
+				aCount++
+			}
+			if e.Op != Delete {
+				bCount++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(aLine, aCount), hunkRange(bLine, bCount))
+		for _, e := range edits[start:end] {
+			sb.WriteString([]string{" ", "+", "-"}[e.Op] + e.Line + "\n")
+		}
+		i = end
+	}
+	return sb.String(), nil
+}
+
+// hunkRange formats a hunk's start and length; an empty range starts at
+// the line before it, as in diff -u.
+func hunkRange(line, count int) string {
+	if count == 0 {
+		line--
+	}
+	if count == 1 {
+		return fmt.Sprint(line)
+	}
+	return fmt.Sprintf("%d,%d", line, count)
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
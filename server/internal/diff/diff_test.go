@@ -0,0 +1,84 @@
+package diff
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	a := []string{"a", "b", "c", "a", "b", "b", "a"}
+	b := []string{"c", "b", "a", "b", "a", "c"}
+	edits, err := Lines(a, b, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var gotA, gotB []string
+	changes := 0
+	for _, e := range edits {
+		if e.Op != Insert {
+			gotA = append(gotA, e.Line)
+		}
+		if e.Op != Delete {
+			gotB = append(gotB, e.Line)
+		}
+		if e.Op != Equal {
+			changes++
+		}
+	}
+	if strings.Join(gotA, "") != strings.Join(a, "") || strings.Join(gotB, "") != strings.Join(b, "") {
+		t.Fatalf("edits do not rebuild the inputs: %v", edits)
+	}
+	// The classic example has a shortest edit script of 5.
+	if changes != 5 {
+		t.Errorf("%d changes, want 5: %v", changes, edits)
+	}
+	if _, err := Lines(a, b, 5); err != nil {
+		t.Errorf("exactly maxChanges: %v", err)
+	}
+	if _, err := Lines(a, b, 4); !errors.Is(err, ErrTooManyChanges) {
+		t.Errorf("over maxChanges: err = %v", err)
+	}
+	if got, err := Lines(nil, nil, 0); err != nil || len(got) != 0 {
+		t.Errorf("Lines(nil, nil) = %v, %v", got, err)
+	}
+}
+
+func TestUnified(t *testing.T) {
+	var lines []string
+	for i := range 20 {
+		lines = append(lines, string(rune('a'+i)))
+	}
+	old := strings.Join(lines, "\n") + "\n"
+	lines[1] = "B"
+	lines = append(lines[:15], lines[16:]...) // drop "p"
+	got, err := Unified(old, strings.Join(lines, "\n")+"\n", 2, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "@@ -1,4 +1,4 @@\n a\n-b\n+B\n c\n d\n" +
+		"@@ -14,5 +14,4 @@\n n\n o\n-p\n q\n r\n"
+	if got != want {
+		t.Errorf("Unified =\n%s\nwant\n%s", got, want)
+	}
+	if got, _ := Unified(old, old, 3, 0); got != "" {
+		t.Errorf("Unified of equal texts = %q", got)
+	}
+	if got, _ := Unified("", "new\n", 3, 1); got != "@@ -0,0 +1 @@\n+new\n" {
+		t.Errorf("Unified from empty = %q", got)
+	}
+}
+
+func TestUnifiedBounded(t *testing.T) {
+	// A long document with a small change needs little work.
+	long := strings.Repeat("same\n", 100000)
+	got, err := Unified(long, long+"extra\n", 1, 1)
+	if err != nil || got != "@@ -100000 +100000,2 @@\n same\n+extra\n" {
+		t.Errorf("small change to a long document = %q, %v", got, err)
+	}
+	// Rewriting it is refused rather than computed.
+	if _, err := Unified(long, strings.ReplaceAll(long, "same", "other"), 3, 1000); !errors.Is(err, ErrTooManyChanges) {
+		t.Errorf("rewrite: err = %v", err)
+	}
+}
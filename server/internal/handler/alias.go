@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"io"
+
+	"github.com/latebit/demarkus/protocol"
+	"github.com/latebit/demarkus/server/internal/auth"
+	"github.com/latebit/demarkus/server/internal/store"
+)
+
+// redirectAlias answers a request for a path that a document lists in its
+// aliases metadata with a moved response to the document's path, plus
+// suffix, and reports whether it did. Existing documents and directories
+// win over aliases unless archived, so callers try it only once a path is
+// missing or archived.
+func (h *Handler) redirectAlias(w io.Writer, reqPath, suffix string) bool {
+	canonical, ok := h.Store.ResolveAlias(reqPath)
+	if !ok || h.isDenied(canonical) {
+		return false
+	}
+	location := canonical + suffix
+	h.logger().Info("alias", "path", sanitize(reqPath), "location", sanitize(location))
+	resp := protocol.Response{
+		Status:   protocol.StatusMoved,
+		Metadata: map[string]string{"location": location},
+	}
+	h.writeResponse(w, resp)
+	return true
+}
+
+// isRetiredAlias reports whether reqPath is an archived document that
+// another document lists as an alias, so requests for it are redirected.
+func (h *Handler) isRetiredAlias(reqPath string) bool {
+	if h.Store == nil {
+		return false
+	}
+	if _, ok := h.Store.ResolveAlias(reqPath); !ok {
+		return false
+	}
+	doc, err := h.Store.Get(reqPath, 0)
+	return err == nil && doc.Archived
+}
+
+// authorizeAliases checks the aliases a write declares: each must be a
+// valid path that token may publish to, so that a publisher cannot
+// redirect paths outside their own scope. Returns false and writes an
+// error response otherwise.
+func (h *Handler) authorizeAliases(w io.Writer, req protocol.Request, ts *auth.TokenStore, meta map[string]string) bool {
+	aliases, err := store.ParseAliases(meta["aliases"])
+	if err != nil {
+		h.writeError(w, protocol.StatusBadRequest, "aliases: "+err.Error())
+		return false
+	}
+	for _, a := range aliases {
+		if _, err := ts.Authorize(req.Metadata["auth"], a, "publish"); err != nil {
+			h.writeAuthError(w, req.Verb, a, err)
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/latebit/demarkus/protocol"
+	"github.com/latebit/demarkus/server/internal/store"
+)
+
+// handleBacklinks serves BACKLINKS: the current documents that link to the
+// requested one, by path. A directory stands for its index.md. The
+// document need not exist, so that links to a page not yet written can be
+// found. Documents the request could not find through SEARCH are left out.
+func (h *Handler) handleBacklinks(w io.Writer, req protocol.Request) {
+	if _, version := parseVersionPath(req.Path); version > 0 {
+		h.writeError(w, protocol.StatusBadRequest, "BACKLINKS takes a document path, not a version")
+		return
+	}
+	if !h.authorizeRead(w, req) {
+		return
+	}
+
+	target := req.Path
+	isDir, err := h.Store.IsDir(target)
+	if err != nil && !os.IsNotExist(err) {
+		h.logger().Error("backlinks failed", "path", sanitize(req.Path), "error", err)
+		h.writeError(w, protocol.StatusServerError, "internal error")
+		return
+	}
+	if isDir || strings.HasSuffix(target, "/") {
+		target = strings.TrimSuffix(target, "/") + "/index.md"
+	} else if h.Store.CurrentVersion(target) == 0 && h.redirectAlias(w, target, "") {
+		return
+	}
+
+	links := h.Store.Backlinks(target, h.visibleTo(req.Metadata["auth"]))
+	resp := protocol.Response{
+		Status: protocol.StatusOK,
+		Metadata: map[string]string{
+			"results": strconv.Itoa(len(links)),
+		},
+		Body: buildBacklinks(target, links),
+	}
+	h.writeResponse(w, resp)
+}
+
+// buildBacklinks renders links as a markdown list of the documents they
+// come from.
+func buildBacklinks(target string, links []store.Backlink) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "\n# Links to %s\n\n", escapeMD(target))
+	if len(links) == 0 {
+		sb.WriteString("No documents link here.\n")
+		return sb.String()
+	}
+	for _, l := range links {
+		writeResultLink(&sb, l.Path, l.Title)
+	}
+	return sb.String()
+}
@@ -0,0 +1,223 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/latebit/demarkus/protocol"
+	"github.com/latebit/demarkus/server/internal/store"
+)
+
+// batchWorkers is how many requests of one BATCH are handled at once.
+const batchWorkers = 4
+
+// memStream is a Stream over one request of a batch, collecting the
+// response in out.
+type memStream struct {
+	io.Reader
+	out bytes.Buffer
+}
+
+func (s *memStream) Write(p []byte) (int, error) { return s.out.Write(p) }
+func (s *memStream) Close() error                { return nil }
+
+// handleBatch serves BATCH: each request in the body is handled as if it
+// had its own stream, with its own metadata, auth and checks, and its
+// response is returned as a record with the request's ID. Only read verbs
+// may be batched; any other fails the whole batch before anything runs.
+// A batch costs the client one rate-limit token per request. A batch
+// sent with "atomic: true" is handled by handleAtomicBatch instead.
+func (h *Handler) handleBatch(w io.Writer, req protocol.Request) {
+	atomic := false
+	switch req.Metadata[protocol.MetaAtomic] {
+	case "", "false":
+	case "true":
+		atomic = true
+	default:
+		h.writeError(w, protocol.StatusBadRequest, "atomic must be true or false")
+		return
+	}
+	verbs := protocol.BatchVerbs
+	if atomic {
+		verbs = protocol.AtomicBatchVerbs
+	}
+
+	records, err := protocol.ParseBatch(req.Body)
+	if err != nil {
+		h.writeError(w, protocol.StatusBadRequest, err.Error())
+		return
+	}
+	if len(records) == 0 {
+		h.writeError(w, protocol.StatusBadRequest, "BATCH requires at least one request")
+		return
+	}
+	for _, r := range records {
+		line, _, _ := strings.Cut(r.Message, "\n")
+		verb, _, _ := strings.Cut(line, " ")
+		if !verbs[verb] {
+			h.writeError(w, protocol.StatusBadRequest, "request "+r.ID+": "+sanitize(verb)+" cannot be batched")
+			return
+		}
+	}
+	if h.AdmitBatch != nil {
+		// The stream itself was admitted as one request.
+		if ok, retryAfter := h.AdmitBatch(len(records) - 1); !ok {
+			h.logger().Warn("batch rate limited", "requests", len(records))
+			h.writeResponse(w, protocol.Response{
+				Status:   protocol.StatusRateLimited,
+				Metadata: map[string]string{"retry-after": strconv.Itoa(max(1, int(math.Ceil(retryAfter.Seconds()))))},
+			})
+			return
+		}
+	}
+	if atomic {
+		h.handleAtomicBatch(w, records)
+		return
+	}
+
+	var (
+		mu   sync.Mutex
+		out  = make([]protocol.BatchRecord, 0, len(records))
+		wg   sync.WaitGroup
+		jobs = make(chan protocol.BatchRecord)
+	)
+	for range min(batchWorkers, len(records)) {
+		wg.Go(func() {
+			for r := range jobs {
+				s := &memStream{Reader: strings.NewReader(r.Message)}
+				h.HandleStream(s)
+				mu.Lock()
+				out = append(out, protocol.BatchRecord{ID: r.ID, Message: s.out.String()})
+				mu.Unlock()
+			}
+		})
+	}
+	for _, r := range records {
+		jobs <- r
+	}
+	close(jobs)
+	wg.Wait()
+
+	body, err := protocol.FormatBatch(out)
+	if err != nil {
+		h.logger().Error("format batch failed", "error", err)
+		h.writeError(w, protocol.StatusServerError, "internal error")
+		return
+	}
+	h.writeResponse(w, protocol.Response{Status: protocol.StatusOK, Metadata: map[string]string{}, Body: body})
+}
+
+// handleAtomicBatch serves an atomic BATCH of PUBLISH requests: each is
+// checked as handlePublish would, then the store writes them all or none.
+// If one fails, the response has its status, names it in failed-request
+// and holds only its record.
+func (h *Handler) handleAtomicBatch(w io.Writer, records []protocol.BatchRecord) {
+	plans := make([]publishPlan, len(records))
+	for i, r := range records {
+		var out bytes.Buffer
+		p, ok := h.planBatchPublish(&out, r)
+		if !ok {
+			h.writeBatchFailure(w, r.ID, out.String())
+			return
+		}
+		plans[i] = p
+	}
+
+	writes := make([]store.BatchWrite, len(plans))
+	for i, p := range plans {
+		writes[i] = p.write
+	}
+	results, err := h.Store.WriteBatch(writes)
+	if err != nil {
+		var out bytes.Buffer
+		var be *store.BatchError
+		if errors.As(err, &be) {
+			doc := be.Doc
+			if doc == nil {
+				doc = &store.Document{}
+			}
+			h.writePublishError(&out, plans[be.Index], doc, be.Err)
+			h.writeBatchFailure(w, records[be.Index].ID, out.String())
+			return
+		}
+		h.logger().Error("atomic batch failed", "error", err)
+		h.writeError(w, protocol.StatusServerError, "internal error")
+		return
+	}
+
+	out := make([]protocol.BatchRecord, len(records))
+	for i, res := range results {
+		var b bytes.Buffer
+		h.writeResponse(&b, h.published(plans[i], res.Doc, !res.Unchanged))
+		out[i] = protocol.BatchRecord{ID: records[i].ID, Message: b.String()}
+	}
+	h.logger().Info("atomic batch", "requests", len(records))
+	body, err := protocol.FormatBatch(out)
+	if err != nil {
+		h.logger().Error("format batch failed", "error", err)
+		h.writeError(w, protocol.StatusServerError, "internal error")
+		return
+	}
+	h.writeResponse(w, protocol.Response{Status: protocol.StatusOK, Metadata: map[string]string{}, Body: body})
+}
+
+// planBatchPublish parses and checks one request of an atomic batch, as
+// HandleStream and handlePublish would. If a check fails it writes the
+// error response to w and returns false.
+func (h *Handler) planBatchPublish(w io.Writer, r protocol.BatchRecord) (publishPlan, bool) {
+	req, err := protocol.ParseRequest(strings.NewReader(r.Message))
+	if err != nil {
+		h.writeError(w, protocol.StatusBadRequest, err.Error())
+		return publishPlan{}, false
+	}
+	if err := protocol.ValidateRequestMeta(req.Metadata); err != nil {
+		h.writeError(w, protocol.StatusBadRequest, err.Error())
+		return publishPlan{}, false
+	}
+	if req, err = req.Decode(); err != nil {
+		h.writeError(w, protocol.StatusBadRequest, err.Error())
+		return publishPlan{}, false
+	}
+	if containsDotDot(req.Path) || h.isDenied(req.Path) {
+		h.logger().Warn("batch path blocked", "path", sanitize(req.Path))
+		h.writeError(w, protocol.StatusNotFound, req.Path+" not found")
+		return publishPlan{}, false
+	}
+	if req.Body == "" {
+		h.writeError(w, protocol.StatusBadRequest, "an atomic batch cannot unarchive; publish "+req.Path+" on its own")
+		return publishPlan{}, false
+	}
+	p, ok := h.planPublish(w, req)
+	if !ok || !h.vetPublish(w, p) {
+		return publishPlan{}, false
+	}
+	return p, true
+}
+
+// writeBatchFailure answers an atomic batch that request id failed with
+// msg, the response to that request.
+func (h *Handler) writeBatchFailure(w io.Writer, id, msg string) {
+	resp, err := protocol.ParseResponse(strings.NewReader(msg))
+	if err != nil {
+		h.logger().Error("parse batch response failed", "error", err)
+		h.writeError(w, protocol.StatusServerError, "internal error")
+		return
+	}
+	body, err := protocol.FormatBatch([]protocol.BatchRecord{{ID: id, Message: msg}})
+	if err != nil {
+		h.logger().Error("format batch failed", "error", err)
+		h.writeError(w, protocol.StatusServerError, "internal error")
+		return
+	}
+	h.logger().Info("atomic batch rejected", "failed_request", id, "status", resp.Status)
+	h.writeResponse(w, protocol.Response{
+		Status:   resp.Status,
+		Metadata: map[string]string{protocol.MetaFailedRequest: id},
+		Body:     body,
+	})
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"bytes"
+	"strings"
+	"time"
+
+	"github.com/latebit/demarkus/protocol"
+)
+
+// readDeadliner is implemented by streams that support read deadlines,
+// such as *quic.Stream.
+type readDeadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+// verbDeadlineStream watches the request line as it is read and, once the
+// verb is known, resets the read deadline to the timeout for that verb.
+// The deadline is measured from when the stream was accepted, so a slow
+// request line still counts against the budget.
+type verbDeadlineStream struct {
+	Stream
+	deadliner readDeadliner
+	timeout   func(verb string) time.Duration
+	start     time.Time
+	line      []byte
+	done      bool
+}
+
+func (s *verbDeadlineStream) Read(p []byte) (int, error) {
+	n, err := s.Stream.Read(p)
+	if !s.done && n > 0 {
+		chunk := p[:n]
+		if i := bytes.IndexByte(chunk, '\n'); i >= 0 {
+			s.line = append(s.line, chunk[:i]...)
+			s.apply()
+		} else if len(s.line)+n > protocol.MaxRequestLineLength {
+			// ParseRequest will reject the line; leave the deadline alone.
+			s.done = true
+		} else {
+			s.line = append(s.line, chunk...)
+		}
+	}
+	return n, err
+}
+
+func (s *verbDeadlineStream) apply() {
+	s.done = true
+	verb, _, _ := strings.Cut(strings.TrimSpace(string(s.line)), " ")
+	s.line = nil
+	if d := s.timeout(verb); d > 0 {
+		_ = s.deadliner.SetReadDeadline(s.start.Add(d))
+	}
+}
+
+// withVerbDeadline wraps stream so that its read deadline follows the
+// per-verb RequestTimeout. Streams without deadline support are returned
+// unchanged.
+func (h *Handler) withVerbDeadline(stream Stream) Stream {
+	if h.RequestTimeout == nil {
+		return stream
+	}
+	d, ok := stream.(readDeadliner)
+	if !ok {
+		return stream
+	}
+	return &verbDeadlineStream{
+		Stream:    stream,
+		deadliner: d,
+		timeout:   h.RequestTimeout,
+		start:     time.Now(),
+	}
+}
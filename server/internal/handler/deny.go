@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"os"
+	"path"
+	"strings"
+
+	"github.com/latebit/demarkus/server/internal/auth"
+)
+
+// isDenied reports whether reqPath matches one of the configured deny
+// patterns. Versioned paths are checked against their document path, and
+// directories are checked with a trailing slash so /private/** also covers
+// /private itself. Patterns without a slash match the last path segment,
+// so *.secret.md denies that name in every directory.
+func (h *Handler) isDenied(reqPath string) bool {
+	if len(h.DenyPaths) == 0 {
+		return false
+	}
+	docPath, _ := parseVersionPath(reqPath)
+	name := path.Base(docPath)
+	for _, pattern := range h.DenyPaths {
+		if !strings.Contains(pattern, "/") {
+			if matched, _ := path.Match(pattern, name); matched {
+				return true
+			}
+			continue
+		}
+		if auth.MatchPath(pattern, docPath) {
+			return true
+		}
+		if !strings.HasSuffix(docPath, "/") && auth.MatchPath(pattern, docPath+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// visibleEntries drops directory entries whose paths are denied so that
+// listings do not reveal their names. Archived documents that another
+// document claims as an alias are dropped too: they are served as a
+// redirect to that document, which is listed under its own name.
+func (h *Handler) visibleEntries(dir string, entries []os.DirEntry) []os.DirEntry {
+	visible := make([]os.DirEntry, 0, len(entries))
+	for _, entry := range entries {
+		child := path.Join(dir, entry.Name())
+		if entry.IsDir() {
+			child += "/"
+		}
+		if !h.isDenied(child) && !h.isRetiredAlias(child) {
+			visible = append(visible, entry)
+		}
+	}
+	return visible
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/latebit/demarkus/protocol"
+	"github.com/latebit/demarkus/server/internal/diff"
+)
+
+// MaxDiffChanges is the most changed lines DIFF will compare. Larger diffs
+// are refused: the work grows with the square of the number of changes,
+// and a client can fetch both versions instead.
+const MaxDiffChanges = 1000
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// parseDiffPath splits a DIFF path such as /doc.md/v2..v4 into the
+// document path and the two versions. ok is false when the last segment is
+// not a version range.
+func parseDiffPath(reqPath string) (basePath string, from, to int, ok bool) {
+	dir, last := path.Split(reqPath)
+	a, b, found := strings.Cut(last, "..")
+	if !found || !strings.HasPrefix(a, "v") || !strings.HasPrefix(b, "v") {
+		return "", 0, 0, false
+	}
+	from, errA := strconv.Atoi(a[1:])
+	to, errB := strconv.Atoi(b[1:])
+	basePath = strings.TrimRight(dir, "/")
+	if errA != nil || errB != nil || from < 1 || to < 1 || basePath == "" {
+		return "", 0, 0, false
+	}
+	return basePath, from, to, true
+}
+
+// handleDiff serves DIFF /doc.md/vA..vB: the changes from version A to
+// version B as a unified diff, so that clients can follow a document's
+// history without fetching every version. It is gated like the versions
+// themselves.
+func (h *Handler) handleDiff(w io.Writer, req protocol.Request) {
+	basePath, from, to, ok := parseDiffPath(req.Path)
+	if !ok {
+		h.writeError(w, protocol.StatusBadRequest, "DIFF requires a version range, e.g. /doc.md/v1..v2")
+		return
+	}
+	// The range segment hides the document's name from DenyPaths patterns.
+	if h.isDenied(basePath) {
+		h.writeError(w, protocol.StatusNotFound, req.Path+" not found")
+		return
+	}
+	authReq := req
+	authReq.Path = basePath
+	if !h.authorizeHistory(w, authReq) {
+		return
+	}
+
+	var bodies [2]string
+	for i, version := range []int{from, to} {
+		doc, err := h.Store.Get(basePath, version)
+		if err != nil {
+			if os.IsNotExist(err) {
+				if h.redirectAlias(w, basePath, fmt.Sprintf("/v%d..v%d", from, to)) {
+					return
+				}
+				h.logger().Info("not found", "path", sanitize(basePath), "version", version)
+				h.writeError(w, protocol.StatusNotFound, fmt.Sprintf("%s/v%d not found", basePath, version))
+				return
+			}
+			h.logger().Error("diff failed", "path", sanitize(basePath), "version", version, "error", err)
+			h.writeError(w, protocol.StatusServerError, "internal error")
+			return
+		}
+		bodies[i] = stripFrontmatter(string(doc.Content))
+	}
+
+	d, err := diff.Unified(bodies[0], bodies[1], diffContext, MaxDiffChanges)
+	if errors.Is(err, diff.ErrTooManyChanges) {
+		h.writeError(w, protocol.StatusTooLarge, fmt.Sprintf("the versions differ by more than %d lines; fetch them instead", MaxDiffChanges))
+		return
+	}
+	if err != nil {
+		h.logger().Error("diff failed", "path", sanitize(basePath), "error", err)
+		h.writeError(w, protocol.StatusServerError, "internal error")
+		return
+	}
+
+	resp := protocol.Response{
+		Status: protocol.StatusOK,
+		Metadata: map[string]string{
+			"from-version": strconv.Itoa(from),
+			"to-version":   strconv.Itoa(to),
+		},
+		Body: buildDiffBody(basePath, from, to, d),
+	}
+	h.writeResponse(w, resp)
+}
+
+// buildDiffBody renders a unified diff as a markdown page: a heading naming
+// the versions, then the diff in a fenced block.
+func buildDiffBody(docPath string, from, to int, d string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "\n# Changes to %s: v%d → v%d\n\n", escapeMD(docPath), from, to)
+	if d == "" {
+		sb.WriteString("No changes.\n")
+		return sb.String()
+	}
+	// The fence must be longer than any backtick run in the diff.
+	fence := "```"
+	for strings.Contains(d, fence) {
+		fence += "`"
+	}
+	fmt.Fprintf(&sb, "%sdiff\n%s%s\n", fence, d, fence)
+	return sb.String()
+}
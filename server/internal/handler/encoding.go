@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"maps"
+
+	"github.com/latebit/demarkus/protocol"
+)
+
+// compressMinSize is the smallest body worth compressing. Below it the
+// gzip header and the client's work cost more than the bytes saved.
+const compressMinSize = 1024
+
+// encodingStream is a stream whose client accepts a content coding.
+// writeResponse recognizes it and compresses bodies of at least
+// compressMinSize bytes, or base64-encodes every body for a client that
+// asked for text.
+type encodingStream struct {
+	Stream
+	coding string
+}
+
+// withEncoding wraps stream when the request's accept-encoding names a
+// coding the server implements.
+func withEncoding(stream Stream, req protocol.Request) Stream {
+	coding := protocol.NegotiateEncoding(req.Metadata["accept-encoding"])
+	if coding == "" {
+		return stream
+	}
+	return &encodingStream{Stream: stream, coding: coding}
+}
+
+// encode returns resp with its body encoded. A compressed body is left
+// unchanged if it is too small or would not shrink.
+func (s *encodingStream) encode(resp protocol.Response) protocol.Response {
+	compress := s.coding != protocol.EncodingBase64
+	if resp.Body == "" || compress && len(resp.Body) < compressMinSize {
+		return resp
+	}
+	encoded, err := protocol.EncodeBody(resp.Body, s.coding)
+	if err != nil || compress && len(encoded) >= len(resp.Body) {
+		return resp
+	}
+	resp.Metadata = maps.Clone(resp.Metadata)
+	if resp.Metadata == nil {
+		resp.Metadata = make(map[string]string)
+	}
+	resp.Metadata["content-encoding"] = s.coding
+	resp.Body = encoded
+	return resp
+}
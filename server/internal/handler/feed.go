@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/latebit/demarkus/protocol"
+	"github.com/latebit/demarkus/server/internal/store"
+)
+
+// FeedEntries is the number of versions the change feed lists.
+const FeedEntries = 50
+
+// handleFeed serves FETCH and INFO of /.well-known/feed.md: the versions
+// most recently published, newest first, each linking to the version, so
+// that clients can poll one document instead of listing every directory.
+// Documents the request could not find through SEARCH are left out, and
+// the feed's etag and modified make a conditional FETCH of it cheap.
+func (h *Handler) handleFeed(w io.Writer, req protocol.Request) {
+	if !h.authorizeRead(w, req) {
+		return
+	}
+	changes := h.Store.Recent(FeedEntries, h.visibleTo(req.Metadata["auth"]))
+	body := buildFeed(changes)
+	meta := map[string]string{
+		"etag":    computeEtag([]byte(body)),
+		"results": strconv.Itoa(len(changes)),
+	}
+	if len(changes) > 0 {
+		meta["modified"] = changes[0].Modified.Format(time.RFC3339)
+	}
+
+	if ifNoneMatch, ok := req.Metadata["if-none-match"]; ok && ifNoneMatch == meta["etag"] {
+		h.writeNotModified(w)
+		return
+	}
+	if ifModSince, ok := req.Metadata["if-modified-since"]; ok && len(changes) > 0 {
+		if t, err := time.Parse(time.RFC3339, ifModSince); err == nil && !changes[0].Modified.After(t) {
+			h.writeNotModified(w)
+			return
+		}
+	}
+	h.writeDocument(w, req, protocol.Response{Status: protocol.StatusOK, Metadata: meta, Body: body})
+}
+
+// buildFeed renders changes as a markdown list, one version per line:
+// "- [title](/path/vN) - 2006-01-02T15:04:05Z", like the entries of a
+// version history.
+func buildFeed(changes []store.Change) string {
+	var sb strings.Builder
+	sb.WriteString("\n# Recent changes\n\n")
+	if len(changes) == 0 {
+		sb.WriteString("Nothing has been published yet.\n")
+		return sb.String()
+	}
+	for _, c := range changes {
+		title := c.Title
+		if title == "" {
+			title = strings.TrimSuffix(c.Path[strings.LastIndex(c.Path, "/")+1:], ".md")
+		}
+		segments := strings.Split(c.Path, "/")
+		for i, s := range segments {
+			segments[i] = escapeURL(s)
+		}
+		fmt.Fprintf(&sb, "- [%s](%s/v%d) - %s\n", escapeMD(title), strings.Join(segments, "/"), c.Version, c.Modified.Format(time.RFC3339))
+	}
+	return sb.String()
+}
@@ -2,12 +2,14 @@
 package handler
 
 import (
+	"crypto/ed25519"
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"net/url"
 	"os"
 	"path"
@@ -18,44 +20,73 @@ import (
 
 	"github.com/latebit/demarkus/protocol"
 	"github.com/latebit/demarkus/server/internal/auth"
+	"github.com/latebit/demarkus/server/internal/jobs"
+	"github.com/latebit/demarkus/server/internal/scan"
 	"github.com/latebit/demarkus/server/internal/store"
+	"github.com/latebit/demarkus/server/internal/transform"
 )
 
 // MaxDirectoryEntries is the maximum number of entries returned by LIST.
 const MaxDirectoryEntries = 1000
 
-// controlKeys are request metadata keys consumed by the handler and never stored.
-var controlKeys = map[string]bool{
-	"auth":              true,
-	"expected-version":  true,
-	"if-none-match":     true,
-	"if-modified-since": true,
-}
-
-// reservedKeys are server-owned response metadata keys that publishers cannot set.
-var reservedKeys = map[string]bool{
-	"version":         true,
-	"modified":        true,
-	"etag":            true,
-	"content-hash":    true,
-	"current-version": true,
-	"server-version":  true,
-	"your-version":    true,
-	"total":           true,
-	"current":         true,
-	"chain-valid":     true,
-	"chain-error":     true,
-	"archived":        true,
-	"entries":         true,
-	"status":          true,
-}
-
 // Handler serves markdown files from a content directory.
 type Handler struct {
 	ContentDir    string
 	Store         *store.Store
 	GetTokenStore func() *auth.TokenStore // nil callback or nil return means writes are denied
 	Logger        *slog.Logger
+	DenyPaths     []string // path patterns never served, for any verb
+	// DetectTampering compares each fetched version against its recorded
+	// hashes and flags mismatches with "tampered: true".
+	DetectTampering bool
+	// RequestTimeout returns the read deadline for a verb, measured from
+	// when the stream is handled. nil leaves the stream deadline untouched.
+	RequestTimeout func(verb string) time.Duration
+	// InvalidToken, if set, is called when a request presents a token that
+	// matches no entry. Missing and expired tokens are not reported.
+	InvalidToken func()
+	// TOCPaths lists directories whose generated _toc.md is refreshed
+	// whenever a document under them is published or archived.
+	TOCPaths []string
+	// MaxVersions caps the versions a client write may give a document.
+	// 0 means no cap.
+	MaxVersions int
+	// Transforms rewrite the markdown of current documents served by FETCH
+	// and INFO, in order. The etag covers their configuration; content-hash
+	// keeps describing the stored markdown.
+	Transforms []transform.Transform
+	// AdmitBatch, if set, is asked before a BATCH runs whether the client
+	// may make n more requests than the one its stream was admitted as.
+	// When it may not, retryAfter is how long it should wait.
+	AdmitBatch func(n int) (ok bool, retryAfter time.Duration)
+	// SigningKey, if set, signs the body of every document response, so
+	// clients that pin its public key can tell it came from this server.
+	SigningKey ed25519.PrivateKey
+	// Extensions answer experimental X- verbs, keyed by verb. Other
+	// experimental verbs get not-implemented. Requests reach an extension
+	// after the path checks every verb gets, but it must do its own auth.
+	Extensions map[string]func(req protocol.Request) protocol.Response
+	// Maintenance, if set, reports whether the server is down for
+	// maintenance, and the banner document to answer every request with
+	// while it is. The store is not touched meanwhile.
+	Maintenance func() (banner string, on bool)
+	// Redirects answer reads of the paths they cover with moved, before
+	// the store is consulted.
+	Redirects []Redirect
+	// Scan, if set, checks the body of every PUBLISH and APPEND before it
+	// is written. A *scan.Rejection refuses the write with not-permitted.
+	// Any other error means the body could not be scanned: the write is
+	// refused with unavailable, unless ScanFailOpen lets it through.
+	Scan         func(path string, body []byte) error
+	ScanFailOpen bool
+	// Jobs, if set, queues each PUBLISH sent with async: it is answered
+	// accepted once checked up to its body, and scanned and written in
+	// the background. Without it, async is ignored.
+	Jobs *jobs.Queue
+
+	// requestID is the request-id of the request a per-request copy of the
+	// handler serves, echoed in its responses.
+	requestID string
 }
 
 func (h *Handler) logger() *slog.Logger {
@@ -65,6 +96,15 @@ func (h *Handler) logger() *slog.Logger {
 	return slog.Default()
 }
 
+// withRequestID returns a copy of h for serving the request named id,
+// which logs it with every line and echoes it in every response.
+func (h *Handler) withRequestID(id string) *Handler {
+	rh := *h
+	rh.Logger = h.logger().With("request_id", id)
+	rh.requestID = id
+	return &rh
+}
+
 // Stream represents a bidirectional stream that can be read, written, and closed.
 type Stream interface {
 	io.ReadWriteCloser
@@ -72,17 +112,49 @@ type Stream interface {
 
 // HandleStream reads a request from the stream and writes a response.
 func (h *Handler) HandleStream(stream Stream) {
+	start := time.Now()
 	defer func() { _ = stream.Close() }()
+	stream = h.withVerbDeadline(stream)
 
 	req, err := protocol.ParseRequest(stream)
+	if errors.Is(err, protocol.ErrUnsupportedProtocol) {
+		h.logger().Warn("unsupported protocol version", "proto", sanitize(req.Proto))
+		h.writeError(stream, protocol.StatusBadRequest, "unsupported protocol version; this server speaks "+protocol.ProtocolVersion)
+		return
+	}
 	if err != nil {
 		h.logger().Error("parse request failed", "error", err)
 		h.writeError(stream, protocol.StatusServerError, "bad request")
 		return
 	}
 
+	// An invalid request-id is rejected below, but not logged.
+	if id := req.Metadata[protocol.MetaRequestID]; protocol.ValidRequestID(id) {
+		h = h.withRequestID(id)
+	}
 	h.logger().Info("request", "verb", sanitize(req.Verb), "path", sanitize(req.Path))
 
+	if err := protocol.ValidateRequestMeta(req.Metadata); err != nil {
+		h.logger().Warn("invalid request metadata", "path", sanitize(req.Path), "error", err)
+		h.writeError(stream, protocol.StatusBadRequest, err.Error())
+		return
+	}
+	// A base64 body is decoded here, so handlers only see the bytes sent.
+	if req, err = req.Decode(); err != nil {
+		h.logger().Warn("invalid request body encoding", "path", sanitize(req.Path), "error", err)
+		h.writeError(stream, protocol.StatusBadRequest, err.Error())
+		return
+	}
+	stream = withTrailers(stream, req, start)
+	stream = withEncoding(stream, req)
+
+	if h.Maintenance != nil {
+		if banner, on := h.Maintenance(); on {
+			h.writeResponse(stream, protocol.Response{Status: protocol.StatusMaintenance, Metadata: map[string]string{}, Body: banner})
+			return
+		}
+	}
+
 	// Reject path traversal attempts before any handler logic (including auth)
 	// to prevent scope bypass via paths like /allowed/../secret.md.
 	if containsDotDot(req.Path) {
@@ -91,14 +163,33 @@ func (h *Handler) HandleStream(stream Stream) {
 		return
 	}
 
+	// Denied paths look exactly like missing ones, whatever is on disk.
+	if h.isDenied(req.Path) {
+		h.logger().Warn("denied path blocked", "verb", sanitize(req.Verb), "path", sanitize(req.Path))
+		h.writeError(stream, protocol.StatusNotFound, req.Path+" not found")
+		return
+	}
+
+	if h.redirectConfigured(stream, req) {
+		return
+	}
+
 	// Health check endpoint: responds to FETCH /health with OK
 	if req.Path == "/health" && req.Verb == protocol.VerbFetch {
 		h.handleHealth(stream)
 		return
 	}
+	if req.Path == protocol.WellKnownTokenPath && req.Verb == protocol.VerbFetch {
+		h.handleTokenInfo(stream, req)
+		return
+	}
+	if req.Path == protocol.WellKnownFeedPath && (req.Verb == protocol.VerbFetch || req.Verb == protocol.VerbInfo) {
+		h.handleFeed(stream, req)
+		return
+	}
 
 	switch req.Verb {
-	case protocol.VerbFetch:
+	case protocol.VerbFetch, protocol.VerbInfo:
 		h.handleFetch(stream, req)
 	case protocol.VerbList:
 		h.handleList(stream, req)
@@ -110,11 +201,40 @@ func (h *Handler) HandleStream(stream Stream) {
 		h.handleArchive(stream, req)
 	case protocol.VerbAppend:
 		h.handleAppend(stream, req)
+	case protocol.VerbSearch:
+		h.handleSearch(stream, req)
+	case protocol.VerbDiff:
+		h.handleDiff(stream, req)
+	case protocol.VerbPurge:
+		h.handlePurge(stream, req)
+	case protocol.VerbMove:
+		h.handleMove(stream, req)
+	case protocol.VerbBatch:
+		h.handleBatch(stream, req)
+	case protocol.VerbBacklinks:
+		h.handleBacklinks(stream, req)
+	case protocol.VerbJob:
+		h.handleJob(stream, req)
 	default:
+		if protocol.IsExperimentalVerb(req.Verb) {
+			h.handleExperimental(stream, req)
+			return
+		}
 		h.writeError(stream, protocol.StatusServerError, "unsupported verb: "+sanitize(req.Verb))
 	}
 }
 
+// handleExperimental answers an experimental verb with its extension, or
+// not-implemented when there is none.
+func (h *Handler) handleExperimental(w io.Writer, req protocol.Request) {
+	ext, ok := h.Extensions[req.Verb]
+	if !ok {
+		h.writeError(w, protocol.StatusNotImplemented, sanitize(req.Verb)+" is not implemented by this server")
+		return
+	}
+	h.writeResponse(w, ext(req))
+}
+
 // parseVersionPath checks if a path ends with /vN (e.g., /doc.md/v3).
 // Returns the base path and version number, or the original path and 0.
 func parseVersionPath(reqPath string) (basePath string, version int) {
@@ -150,8 +270,9 @@ func isHashPath(reqPath string) (string, bool) {
 }
 
 func (h *Handler) handleFetchByHash(w io.Writer, req protocol.Request, hash string) {
+	// A hash resolving to a denied path is reported as unknown.
 	docPath, ok := h.Store.LookupHash(hash)
-	if !ok {
+	if !ok || h.isDenied(docPath) {
 		h.logger().Info("hash not found", "hash", hash)
 		h.writeError(w, protocol.StatusNotFound, "content not found for hash "+hash)
 		return
@@ -176,7 +297,7 @@ func (h *Handler) handleFetchByHash(w io.Writer, req protocol.Request, hash stri
 		return
 	}
 
-	h.serveDocument(w, req, doc, docPath)
+	h.serveDocument(w, req, doc, docPath, docPath, "")
 }
 
 // authorizeRead checks whether a read request is allowed. Returns true if the
@@ -200,14 +321,29 @@ func (h *Handler) authorizeRead(w io.Writer, req protocol.Request) bool {
 	token := req.Metadata["auth"]
 	_, err := ts.Authorize(token, req.Path, "read")
 	if err != nil {
-		switch {
-		case errors.Is(err, auth.ErrNoToken), errors.Is(err, auth.ErrInvalidToken), errors.Is(err, auth.ErrTokenExpired):
-			h.logger().Warn("unauthorized", "operation", req.Verb, "path", sanitize(req.Path))
-			h.writeError(w, protocol.StatusUnauthorized, "authentication required")
-		default:
-			h.logger().Warn("not permitted", "operation", req.Verb, "path", sanitize(req.Path))
-			h.writeError(w, protocol.StatusNotPermitted, "insufficient permissions")
-		}
+		h.writeAuthError(w, req.Verb, req.Path, err)
+		return false
+	}
+	return true
+}
+
+// authorizeHistory checks whether a request for a document's past versions
+// is allowed: it must pass authorizeRead and, when a versions token covers
+// the path, present a token granting versions. Returns false and writes an
+// error response otherwise.
+func (h *Handler) authorizeHistory(w io.Writer, req protocol.Request) bool {
+	if !h.authorizeRead(w, req) {
+		return false
+	}
+	var ts *auth.TokenStore
+	if h.GetTokenStore != nil {
+		ts = h.GetTokenStore()
+	}
+	if ts == nil || !ts.RequiresVersionsAuth(req.Path) {
+		return true
+	}
+	if _, err := ts.Authorize(req.Metadata["auth"], req.Path, "versions"); err != nil {
+		h.writeAuthError(w, req.Verb, req.Path, err)
 		return false
 	}
 	return true
@@ -221,12 +357,12 @@ func (h *Handler) handleFetch(w io.Writer, req protocol.Request) {
 		return
 	}
 
-	// For versioned paths, check read auth on the base path so that
-	// /doc.md/v2 is gated by the same token as /doc.md.
+	// For versioned paths, check auth on the base path so that /doc.md/v2
+	// is gated by the same tokens as /doc.md and its history.
 	if basePath, version := parseVersionPath(req.Path); version > 0 {
 		authReq := req
 		authReq.Path = basePath
-		if !h.authorizeRead(w, authReq) {
+		if !h.authorizeHistory(w, authReq) {
 			return
 		}
 		h.handleFetchVersion(w, req, basePath, version)
@@ -236,6 +372,10 @@ func (h *Handler) handleFetch(w io.Writer, req protocol.Request) {
 	if !h.authorizeRead(w, req) {
 		return
 	}
+	if doc, variant, lang := h.languageVariant(req, req.Path); doc != nil {
+		h.serveDocument(w, req, doc, variant, req.Path, lang)
+		return
+	}
 
 	doc, err := h.Store.Get(req.Path, 0)
 	if err != nil {
@@ -251,6 +391,9 @@ func (h *Handler) handleFetch(w io.Writer, req protocol.Request) {
 				h.handleFetchDirectory(w, req)
 				return
 			}
+			if h.redirectAlias(w, req.Path, "") {
+				return
+			}
 			h.logger().Info("not found", "path", sanitize(req.Path))
 			h.writeError(w, protocol.StatusNotFound, req.Path+" not found")
 			return
@@ -260,20 +403,30 @@ func (h *Handler) handleFetch(w io.Writer, req protocol.Request) {
 		return
 	}
 
-	h.serveDocument(w, req, doc, req.Path)
+	h.serveDocument(w, req, doc, req.Path, req.Path, "")
 }
 
 // serveDocument handles the common document-serving logic: archived check,
 // conditional request handling (etag / if-modified-since), frontmatter
-// stripping, and response assembly.
-func (h *Handler) serveDocument(w io.Writer, req protocol.Request, doc *store.Document, logPath string) {
+// stripping, transforms, and response assembly. docPath is the stored
+// document, which differs from logPath when a directory is served by its
+// index.md or a path by a language variant, whose language is lang.
+// INFO describes archived documents instead of refusing them.
+func (h *Handler) serveDocument(w io.Writer, req protocol.Request, doc *store.Document, docPath, logPath, lang string) {
 	if doc.Archived {
-		h.logger().Info("archived", "path", sanitize(logPath))
-		h.writeError(w, protocol.StatusArchived, logPath+" is archived")
-		return
+		if h.redirectAlias(w, docPath, "") {
+			return
+		}
+		if req.Verb != protocol.VerbInfo {
+			h.logger().Info("archived", "path", sanitize(logPath))
+			h.writeError(w, protocol.StatusArchived, logPath+" is archived")
+			return
+		}
 	}
 
-	etag := computeEtag(doc.Content)
+	info := h.Store.Describe(docPath, doc)
+	pipeline := h.transformsFor(req, doc)
+	etag := transformedEtag(doc, info.Etag, pipeline)
 
 	if ifNoneMatch, ok := req.Metadata["if-none-match"]; ok && ifNoneMatch == etag {
 		h.writeNotModified(w)
@@ -295,8 +448,54 @@ func (h *Handler) serveDocument(w io.Writer, req protocol.Request, doc *store.Do
 	meta["modified"] = doc.Modified.Format(time.RFC3339)
 	meta["etag"] = etag
 	meta["version"] = strconv.Itoa(doc.Version)
-	meta["content-hash"] = computeContentHash(body)
-	h.writeResponse(w, protocol.Response{Status: protocol.StatusOK, Metadata: meta, Body: body})
+	meta["content-hash"] = info.ContentHash
+	if doc.PreviousHash != "" {
+		meta["previous-hash"] = doc.PreviousHash
+	}
+	if doc.MovedFrom != "" {
+		meta["moved-from"] = doc.MovedFrom
+	}
+	if lang != "" {
+		meta[protocol.MetaContentLanguage] = lang
+	}
+	if req.Verb == protocol.VerbInfo {
+		meta["archived"] = strconv.FormatBool(doc.Archived)
+	}
+	h.checkTampered(meta, docPath, doc)
+	h.addChainStatus(meta, docPath)
+	body = transform.Apply(pipeline, docPath, body)
+	addReadingStats(meta, body)
+	h.writeDocument(w, req, protocol.Response{Status: protocol.StatusOK, Metadata: meta, Body: body})
+}
+
+// checkTampered flags doc in meta when DetectTampering is on and the file no
+// longer matches the hashes the store recorded for it. The document is
+// still served; the flag and the log line are for operators and clients to
+// act on.
+func (h *Handler) checkTampered(meta map[string]string, docPath string, doc *store.Document) {
+	if !h.DetectTampering {
+		return
+	}
+	if err := h.Store.CheckRecorded(docPath, doc); err != nil {
+		h.logger().Error("TAMPERED: version file modified outside the server", "path", sanitize(docPath), "version", doc.Version, "error", err)
+		meta["tampered"] = "true"
+	}
+}
+
+// addChainStatus reports in meta the state of docPath's hash chain as the
+// store last found it, without verifying the chain again: chain-valid,
+// chain-checked and, for a broken chain, chain-error. Nothing is added for
+// a chain not checked since the server started.
+func (h *Handler) addChainStatus(meta map[string]string, docPath string) {
+	st, ok := h.Store.ChainStatus(docPath)
+	if !ok {
+		return
+	}
+	meta["chain-valid"] = strconv.FormatBool(st.Valid)
+	meta["chain-checked"] = st.Checked.Format(time.RFC3339)
+	if !st.Valid {
+		meta["chain-error"] = "chain integrity check failed"
+	}
 }
 
 func (h *Handler) writeNotModified(w io.Writer) {
@@ -312,11 +511,6 @@ func computeEtag(data []byte) string {
 	return hex.EncodeToString(hash[:])
 }
 
-func computeContentHash(body string) string {
-	hash := sha256.Sum256([]byte(body))
-	return "sha256-" + hex.EncodeToString(hash[:])
-}
-
 func (h *Handler) handleList(w io.Writer, req protocol.Request) {
 	if !h.authorizeRead(w, req) {
 		return
@@ -326,6 +520,16 @@ func (h *Handler) handleList(w io.Writer, req protocol.Request) {
 		h.writeError(w, protocol.StatusNotFound, reqPath+" not found")
 		return
 	}
+	format := req.Metadata["format"]
+	if format != "" && format != protocol.FormatStructured {
+		h.writeError(w, protocol.StatusBadRequest, "unknown format "+sanitize(format))
+		return
+	}
+	page, err := parseListPage(req.Metadata)
+	if err != nil {
+		h.writeError(w, protocol.StatusBadRequest, err.Error())
+		return
+	}
 	entries, err := h.Store.ListDir(reqPath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -338,30 +542,23 @@ func (h *Handler) handleList(w io.Writer, req protocol.Request) {
 		return
 	}
 
-	body, entryCount := buildDirectoryIndex(reqPath, entries)
-
-	resp := protocol.Response{
-		Status: protocol.StatusOK,
-		Metadata: map[string]string{
-			"entries": fmt.Sprintf("%d", entryCount),
-		},
-		Body: body,
+	visible := h.visibleEntries(reqPath, entries)
+	shown, next := page.apply(visible)
+	if format == protocol.FormatStructured {
+		h.writeStructuredList(w, reqPath, shown, len(visible), next)
+		return
 	}
-	h.writeResponse(w, resp)
+	h.writeResponse(w, directoryIndex(reqPath, shown, next))
 }
 
-// buildDirectoryIndex renders a markdown listing from directory entries.
-// Returns the markdown body and the number of entries included.
-func buildDirectoryIndex(reqPath string, entries []os.DirEntry) (body string, entryCount int) {
+// directoryIndex returns the response holding a markdown listing of the
+// page of a directory's entries. next is the offset of the entry after
+// the page, or 0 if it is the last.
+func directoryIndex(reqPath string, page []os.DirEntry, next int) protocol.Response {
 	var sb strings.Builder
 	sb.WriteString("\n# Index of " + escapeMD(reqPath) + "\n\n")
 
-	for _, entry := range entries {
-		if entryCount >= MaxDirectoryEntries {
-			sb.WriteString("\n*...truncated, too many entries*\n")
-			break
-		}
-		entryCount++
+	for _, entry := range page {
 		display := escapeMD(entry.Name())
 		link := escapeURL(entry.Name())
 		if entry.IsDir() {
@@ -370,13 +567,21 @@ func buildDirectoryIndex(reqPath string, entries []os.DirEntry) (body string, en
 			sb.WriteString("- [" + display + "](" + link + ")\n")
 		}
 	}
-
-	return sb.String(), entryCount
+	meta := map[string]string{"entries": strconv.Itoa(len(page))}
+	if next > 0 {
+		sb.WriteString("\n*...truncated, too many entries*\n")
+		meta["next-offset"] = strconv.Itoa(next)
+	}
+	return protocol.Response{Status: protocol.StatusOK, Metadata: meta, Body: sb.String()}
 }
 
 func (h *Handler) handleFetchDirectory(w io.Writer, req protocol.Request) {
 	// Try index.md first — if the directory has an explicit index, serve it as a normal document.
 	indexPath := path.Join(req.Path, "index.md")
+	if doc, variant, lang := h.languageVariant(req, indexPath); doc != nil {
+		h.serveDocument(w, req, doc, variant, req.Path, lang)
+		return
+	}
 	doc, err := h.Store.Get(indexPath, 0)
 	if err != nil && !os.IsNotExist(err) {
 		h.logger().Error("fetch index failed", "path", sanitize(indexPath), "error", err)
@@ -384,7 +589,7 @@ func (h *Handler) handleFetchDirectory(w io.Writer, req protocol.Request) {
 		return
 	}
 	if err == nil {
-		h.serveDocument(w, req, doc, req.Path)
+		h.serveDocument(w, req, doc, indexPath, req.Path, "")
 		return
 	}
 
@@ -401,21 +606,18 @@ func (h *Handler) handleFetchDirectory(w io.Writer, req protocol.Request) {
 		return
 	}
 
-	body, entryCount := buildDirectoryIndex(req.Path, entries)
-	resp := protocol.Response{
-		Status: protocol.StatusOK,
-		Metadata: map[string]string{
-			"entries": fmt.Sprintf("%d", entryCount),
-		},
-		Body: body,
-	}
-	h.writeResponse(w, resp)
+	// LIST pages through the rest; a FETCH shows the first page.
+	shown, next := listPage{limit: MaxDirectoryEntries}.apply(h.visibleEntries(req.Path, entries))
+	h.writeDocument(w, req, directoryIndex(req.Path, shown, next))
 }
 
 func (h *Handler) handleFetchVersion(w io.Writer, req protocol.Request, basePath string, version int) {
 	doc, err := h.Store.Get(basePath, version)
 	if err != nil {
 		if os.IsNotExist(err) {
+			if h.redirectAlias(w, basePath, "/v"+strconv.Itoa(version)) {
+				return
+			}
 			h.logger().Info("not found", "path", sanitize(basePath), "version", version)
 			h.writeError(w, protocol.StatusNotFound, req.Path+" not found")
 			return
@@ -426,27 +628,40 @@ func (h *Handler) handleFetchVersion(w io.Writer, req protocol.Request, basePath
 	}
 
 	body := stripFrontmatter(string(doc.Content))
+	info := h.Store.Describe(basePath, doc)
 
 	// Copy publisher metadata first, then set server-owned keys so they can't be overwritten.
 	meta := make(map[string]string)
 	copyPublisherMeta(meta, doc.Metadata)
 	meta["modified"] = doc.Modified.Format(time.RFC3339)
+	meta["etag"] = info.Etag
 	meta["version"] = strconv.Itoa(doc.Version)
-	meta["content-hash"] = computeContentHash(body)
+	meta["content-hash"] = info.ContentHash
+	if doc.PreviousHash != "" {
+		meta["previous-hash"] = doc.PreviousHash
+	}
+	if doc.MovedFrom != "" {
+		meta["moved-from"] = doc.MovedFrom
+	}
 	// Indicate current version so client knows if this is historical.
 	current := h.Store.CurrentVersion(basePath)
 	meta["current-version"] = strconv.Itoa(current)
+	if req.Verb == protocol.VerbInfo {
+		meta["archived"] = strconv.FormatBool(doc.Archived)
+	}
+	h.checkTampered(meta, basePath, doc)
+	addReadingStats(meta, body)
 
 	resp := protocol.Response{
 		Status:   protocol.StatusOK,
 		Metadata: meta,
 		Body:     body,
 	}
-	h.writeResponse(w, resp)
+	h.writeDocument(w, req, resp)
 }
 
 func (h *Handler) handleVersions(w io.Writer, req protocol.Request) {
-	if !h.authorizeRead(w, req) {
+	if !h.authorizeHistory(w, req) {
 		return
 	}
 	reqPath := req.Path
@@ -462,6 +677,13 @@ func (h *Handler) handleVersions(w io.Writer, req protocol.Request) {
 	versions, err := h.Store.Versions(reqPath)
 	if err != nil {
 		if os.IsNotExist(err) {
+			if h.redirectAlias(w, reqPath, "") {
+				return
+			}
+			if tomb, err := h.Store.Tombstone(reqPath); err == nil {
+				h.writePurged(w, reqPath, tomb)
+				return
+			}
 			h.logger().Info("not found", "path", sanitize(reqPath))
 			h.writeError(w, protocol.StatusNotFound, reqPath+" not found")
 			return
@@ -474,15 +696,25 @@ func (h *Handler) handleVersions(w io.Writer, req protocol.Request) {
 	var body strings.Builder
 	body.WriteString("\n# Version History: " + escapeMD(reqPath) + "\n\n")
 	for _, v := range versions {
-		body.WriteString(fmt.Sprintf("- [v%d](%s/v%d) - %s\n",
+		body.WriteString(fmt.Sprintf("- [v%d](%s/v%d) - %s",
 			v.Version, escapeURL(reqPath), v.Version,
 			v.Modified.Format(time.RFC3339)))
+		if v.Agent != "" {
+			body.WriteString(" - agent: " + escapeMD(v.Agent))
+		}
+		body.WriteString("\n")
 	}
 
 	meta := map[string]string{
 		"total":   fmt.Sprintf("%d", len(versions)),
 		"current": fmt.Sprintf("%d", versions[0].Version),
 	}
+	// A history republished after a purge says that an older one existed.
+	if tomb, err := h.Store.Tombstone(reqPath); err == nil {
+		meta["purged"] = tomb.Purged.Format(time.RFC3339)
+		meta["purged-versions"] = strconv.Itoa(tomb.Versions)
+		body.WriteString(fmt.Sprintf("\nAn earlier history of %d versions was purged at %s.\n", tomb.Versions, meta["purged"]))
+	}
 
 	// Verify hash chain integrity and report result.
 	if err := h.Store.VerifyChain(reqPath); err != nil {
@@ -510,6 +742,10 @@ func (h *Handler) handleArchive(w io.Writer, req protocol.Request) {
 		h.writeError(w, protocol.StatusBadRequest, "paths matching /sha256-<hash> are reserved")
 		return
 	}
+	if h.isTOCPath(req.Path) {
+		h.writeError(w, protocol.StatusBadRequest, req.Path+" is generated by the server")
+		return
+	}
 
 	var ts *auth.TokenStore
 	if h.GetTokenStore != nil {
@@ -523,14 +759,7 @@ func (h *Handler) handleArchive(w io.Writer, req protocol.Request) {
 	token := req.Metadata["auth"]
 	tokenLabel, err := ts.Authorize(token, req.Path, "publish")
 	if err != nil {
-		switch {
-		case errors.Is(err, auth.ErrNoToken), errors.Is(err, auth.ErrInvalidToken), errors.Is(err, auth.ErrTokenExpired):
-			h.logger().Warn("unauthorized", "operation", "ARCHIVE", "path", sanitize(req.Path))
-			h.writeError(w, protocol.StatusUnauthorized, "authentication required")
-		default:
-			h.logger().Warn("not permitted", "operation", "ARCHIVE", "path", sanitize(req.Path))
-			h.writeError(w, protocol.StatusNotPermitted, "insufficient permissions")
-		}
+		h.writeAuthError(w, "ARCHIVE", req.Path, err)
 		return
 	}
 
@@ -552,6 +781,8 @@ func (h *Handler) handleArchive(w io.Writer, req protocol.Request) {
 		return
 	}
 
+	h.refreshTOCsFor(req.Path)
+
 	h.logger().Info("archive", "audit", true, "operation", "ARCHIVE", "path", sanitize(req.Path), "version", doc.Version, "token_label", sanitize(tokenLabel), "success", true)
 	resp := protocol.Response{
 		Status: protocol.StatusOK,
@@ -564,40 +795,17 @@ func (h *Handler) handleArchive(w io.Writer, req protocol.Request) {
 }
 
 func (h *Handler) handlePublish(w io.Writer, req protocol.Request) {
-	if h.Store == nil {
-		h.writeError(w, protocol.StatusServerError, "publishing not configured")
-		return
-	}
-	if _, ok := isHashPath(req.Path); ok {
-		h.writeError(w, protocol.StatusBadRequest, "paths matching /sha256-<hash> are reserved")
-		return
-	}
-	if int64(len(req.Body)) > protocol.MaxBodyLength {
-		h.logger().Error("body too large", "path", sanitize(req.Path), "size_bytes", len(req.Body))
-		h.writeError(w, protocol.StatusServerError, "content exceeds size limit")
-		return
-	}
-
-	var ts *auth.TokenStore
-	if h.GetTokenStore != nil {
-		ts = h.GetTokenStore()
-	}
-	if ts == nil {
-		h.writeError(w, protocol.StatusNotPermitted, "publishing requires auth configuration")
+	async := false
+	switch req.Metadata[protocol.MetaAsync] {
+	case "", "false":
+	case "true":
+		async = h.Jobs != nil
+	default:
+		h.writeError(w, protocol.StatusBadRequest, "async must be true or false")
 		return
 	}
-
-	token := req.Metadata["auth"]
-	tokenLabel, err := ts.Authorize(token, req.Path, "publish")
-	if err != nil {
-		switch {
-		case errors.Is(err, auth.ErrNoToken), errors.Is(err, auth.ErrInvalidToken), errors.Is(err, auth.ErrTokenExpired):
-			h.logger().Warn("unauthorized", "operation", "PUBLISH", "path", sanitize(req.Path))
-			h.writeError(w, protocol.StatusUnauthorized, "authentication required")
-		default:
-			h.logger().Warn("not permitted", "operation", "PUBLISH", "path", sanitize(req.Path))
-			h.writeError(w, protocol.StatusNotPermitted, "insufficient permissions")
-		}
+	p, ok := h.planPublish(w, req)
+	if !ok {
 		return
 	}
 
@@ -621,7 +829,8 @@ func (h *Handler) handlePublish(w io.Writer, req protocol.Request) {
 				h.writeError(w, protocol.StatusServerError, "internal error")
 				return
 			}
-			h.logger().Info("unarchive", "audit", true, "operation", "UNARCHIVE", "path", sanitize(req.Path), "version", doc.Version, "token_label", sanitize(tokenLabel), "success", true)
+			h.refreshTOCsFor(req.Path)
+			h.logger().Info("unarchive", "audit", true, "operation", "UNARCHIVE", "path", sanitize(req.Path), "version", doc.Version, "token_label", sanitize(p.tokenLabel), "success", true)
 		}
 
 		// Return OK (no-op for active documents, or unarchive response)
@@ -635,71 +844,184 @@ func (h *Handler) handlePublish(w io.Writer, req protocol.Request) {
 		return
 	}
 
+	if async {
+		h.acceptPublish(w, p)
+		return
+	}
+	if h.vetPublish(w, p) {
+		h.writePublish(w, p)
+	}
+}
+
+// writePublish writes a checked PUBLISH to the store and answers it.
+func (h *Handler) writePublish(w io.Writer, p publishPlan) {
+	doc, err := h.Store.WriteVersion(p.write.Path, p.write.ExpectedVersion, p.write.Content, p.write.Meta)
+	if err != nil && !errors.Is(err, store.ErrNotModified) {
+		h.writePublishError(w, p, doc, err)
+		return
+	}
+	h.writeResponse(w, h.published(p, doc, err == nil))
+}
+
+// publishPlan is a PUBLISH that passed every check, ready to be written.
+type publishPlan struct {
+	write      store.BatchWrite
+	tokenLabel string
+}
+
+// planPublish checks a PUBLISH request, from its path to its metadata, and
+// returns what to write; vetPublish makes the checks that read the body.
+// If a check fails it writes the error response to w and returns false. A
+// request with an empty body, which unarchives, is only checked up to its
+// authorization.
+func (h *Handler) planPublish(w io.Writer, req protocol.Request) (publishPlan, bool) {
+	if h.Store == nil {
+		h.writeError(w, protocol.StatusServerError, "publishing not configured")
+		return publishPlan{}, false
+	}
+	if _, ok := isHashPath(req.Path); ok {
+		h.writeError(w, protocol.StatusBadRequest, "paths matching /sha256-<hash> are reserved")
+		return publishPlan{}, false
+	}
+	if h.isTOCPath(req.Path) {
+		h.writeError(w, protocol.StatusBadRequest, req.Path+" is generated by the server")
+		return publishPlan{}, false
+	}
+	if int64(len(req.Body)) > protocol.MaxBodyLength {
+		h.logger().Error("body too large", "path", sanitize(req.Path), "size_bytes", len(req.Body))
+		h.writeError(w, protocol.StatusServerError, "content exceeds size limit")
+		return publishPlan{}, false
+	}
+
+	var ts *auth.TokenStore
+	if h.GetTokenStore != nil {
+		ts = h.GetTokenStore()
+	}
+	if ts == nil {
+		h.writeError(w, protocol.StatusNotPermitted, "publishing requires auth configuration")
+		return publishPlan{}, false
+	}
+
+	token := req.Metadata["auth"]
+	tokenLabel, err := ts.Authorize(token, req.Path, "publish")
+	if err != nil {
+		h.writeAuthError(w, "PUBLISH", req.Path, err)
+		return publishPlan{}, false
+	}
+	p := publishPlan{write: store.BatchWrite{Path: req.Path, ExpectedVersion: -1}, tokenLabel: tokenLabel}
+	if req.Body == "" {
+		return p, true
+	}
+
 	pubMeta, err := extractPublisherMeta(req.Metadata)
+	if err == nil {
+		pubMeta, err = withTokenMeta(ts, token, pubMeta)
+	}
 	if err != nil {
 		h.writeError(w, protocol.StatusBadRequest, err.Error())
-		return
+		return publishPlan{}, false
+	}
+	if !h.authorizeAliases(w, req, ts, pubMeta) {
+		return publishPlan{}, false
 	}
 
-	expectedVersion := -1 // default: no check when expected-version is absent
+	// The default, when expected-version is absent, is no check.
 	if ev := req.Metadata["expected-version"]; ev != "" {
 		v, err := strconv.Atoi(ev)
 		if err != nil || v < 0 {
 			h.writeError(w, protocol.StatusBadRequest, "invalid expected-version")
-			return
+			return publishPlan{}, false
 		}
-		expectedVersion = v
+		p.write.ExpectedVersion = v
 	}
 
-	doc, err := h.Store.WriteVersion(req.Path, expectedVersion, []byte(req.Body), pubMeta)
-	if err != nil {
-		if errors.Is(err, store.ErrConflict) {
-			h.logger().Info("publish conflict", "audit", true, "operation", "PUBLISH", "path", sanitize(req.Path), "expected_version", expectedVersion, "server_version", doc.Version, "token_label", sanitize(tokenLabel), "success", false)
-			var body string
-			if expectedVersion == 0 {
-				body = fmt.Sprintf("# Version Conflict\n\nA document already exists at this path (version %d).\n\nFetch the current version and publish with the correct expected-version to update it.\n", doc.Version)
-			} else {
-				body = fmt.Sprintf("# Version Conflict\n\nThe document has been modified since you last fetched it.\n\nYour version: %d\nServer version: %d\n\nPlease fetch the latest version and reapply your edits.\n", expectedVersion, doc.Version)
-			}
-			resp := protocol.Response{
-				Status: protocol.StatusConflict,
-				Metadata: map[string]string{
-					"your-version":   strconv.Itoa(expectedVersion),
-					"server-version": strconv.Itoa(doc.Version),
-				},
-				Body: body,
-			}
-			h.writeResponse(w, resp)
-			return
-		}
-		if errors.Is(err, store.ErrNotModified) {
-			h.logger().Info("publish unchanged", "audit", true, "operation", "PUBLISH", "path", sanitize(req.Path), "version", doc.Version, "token_label", sanitize(tokenLabel), "success", true)
-			resp := protocol.Response{
-				Status: protocol.StatusOK,
-				Metadata: map[string]string{
-					"version":  strconv.Itoa(doc.Version),
-					"modified": doc.Modified.Format(time.RFC3339),
-				},
-			}
-			h.writeResponse(w, resp)
-			return
-		}
-		if errors.Is(err, store.ErrArchived) {
-			h.logger().Info("publish rejected", "audit", true, "operation", "PUBLISH", "path", sanitize(req.Path), "token_label", sanitize(tokenLabel), "success", false, "reason", "archived")
-			h.writeError(w, protocol.StatusArchived, "document is archived; unarchive first")
-			return
+	p.write.Content = []byte(req.Body)
+	p.write.Meta = pubMeta
+	return p, true
+}
+
+// vetPublish passes the body of a planned PUBLISH to the scanner and
+// checks it against the version limit, and reports whether it may be
+// written. If not, it writes the error response to w.
+func (h *Handler) vetPublish(w io.Writer, p publishPlan) bool {
+	body := string(p.write.Content)
+	if !h.scanned(w, "PUBLISH", p.write.Path, p.tokenLabel, body) {
+		return false
+	}
+	if h.exceedsVersionLimit(p.write.Path, body, p.write.Meta) {
+		h.logger().Info("publish rejected", "audit", true, "operation", "PUBLISH", "path", sanitize(p.write.Path), "token_label", sanitize(p.tokenLabel), "success", false, "reason", "version limit")
+		h.writeVersionLimit(w, p.write.Path)
+		return false
+	}
+	return true
+}
+
+// scanned passes the body of a write to the scanner, if one is configured,
+// and reports whether the write may go ahead. If not, it writes the error
+// response to w.
+func (h *Handler) scanned(w io.Writer, operation, reqPath, tokenLabel, body string) bool {
+	if h.Scan == nil {
+		return true
+	}
+	err := h.Scan(reqPath, []byte(body))
+	if err == nil {
+		return true
+	}
+	var rejected *scan.Rejection
+	if errors.As(err, &rejected) {
+		h.logger().Warn(strings.ToLower(operation)+" rejected", "audit", true, "operation", operation, "path", sanitize(reqPath), "token_label", sanitize(tokenLabel), "success", false, "reason", "scan", "scan_reason", sanitize(rejected.Reason))
+		h.writeError(w, protocol.StatusNotPermitted, "content rejected by the server's scanner")
+		return false
+	}
+	if h.ScanFailOpen {
+		h.logger().Warn("scan failed; accepting unscanned", "operation", operation, "path", sanitize(reqPath), "error", err)
+		return true
+	}
+	h.logger().Error("scan failed", "operation", operation, "path", sanitize(reqPath), "error", err)
+	h.writeError(w, protocol.StatusUnavailable, "content could not be scanned; try again later")
+	return false
+}
+
+// writePublishError answers a PUBLISH that the store failed to write,
+// with err and the document it returned.
+func (h *Handler) writePublishError(w io.Writer, p publishPlan, doc *store.Document, err error) {
+	reqPath, expectedVersion := p.write.Path, p.write.ExpectedVersion
+	if errors.Is(err, store.ErrConflict) {
+		h.logger().Info("publish conflict", "audit", true, "operation", "PUBLISH", "path", sanitize(reqPath), "expected_version", expectedVersion, "server_version", doc.Version, "token_label", sanitize(p.tokenLabel), "success", false)
+		var body string
+		if expectedVersion == 0 {
+			body = fmt.Sprintf("# Version Conflict\n\nA document already exists at this path (version %d).\n\nFetch the current version and publish with the correct expected-version to update it.\n", doc.Version)
+		} else {
+			body = fmt.Sprintf("# Version Conflict\n\nThe document has been modified since you last fetched it.\n\nYour version: %d\nServer version: %d\n\nPlease fetch the latest version and reapply your edits.\n", expectedVersion, doc.Version)
 		}
-		if os.IsNotExist(err) {
-			h.logger().Warn("path traversal attempt", "path", sanitize(req.Path))
-			h.writeError(w, protocol.StatusNotFound, req.Path+" not found")
-			return
+		resp := protocol.Response{
+			Status: protocol.StatusConflict,
+			Metadata: map[string]string{
+				"your-version":   strconv.Itoa(expectedVersion),
+				"server-version": strconv.Itoa(doc.Version),
+			},
+			Body: body,
 		}
-		h.logger().Error("publish failed", "path", sanitize(req.Path), "error", err)
-		h.writeError(w, protocol.StatusServerError, "internal error")
+		h.writeResponse(w, resp)
+		return
+	}
+	if errors.Is(err, store.ErrArchived) {
+		h.logger().Info("publish rejected", "audit", true, "operation", "PUBLISH", "path", sanitize(reqPath), "token_label", sanitize(p.tokenLabel), "success", false, "reason", "archived")
+		h.writeError(w, protocol.StatusArchived, "document is archived; unarchive first")
+		return
+	}
+	if os.IsNotExist(err) {
+		h.logger().Warn("path traversal attempt", "path", sanitize(reqPath))
+		h.writeError(w, protocol.StatusNotFound, reqPath+" not found")
 		return
 	}
+	h.logger().Error("publish failed", "path", sanitize(reqPath), "error", err)
+	h.writeError(w, protocol.StatusServerError, "internal error")
+}
 
-	h.logger().Info("publish", "audit", true, "operation", "PUBLISH", "path", sanitize(req.Path), "version", doc.Version, "token_label", sanitize(tokenLabel), "success", true, "size_bytes", len(req.Body))
+// published logs a PUBLISH the store wrote, or found unchanged, and
+// returns the response to it.
+func (h *Handler) published(p publishPlan, doc *store.Document, created bool) protocol.Response {
 	resp := protocol.Response{
 		Status: protocol.StatusCreated,
 		Metadata: map[string]string{
@@ -707,7 +1029,14 @@ func (h *Handler) handlePublish(w io.Writer, req protocol.Request) {
 			"modified": doc.Modified.Format(time.RFC3339),
 		},
 	}
-	h.writeResponse(w, resp)
+	if !created {
+		h.logger().Info("publish unchanged", "audit", true, "operation", "PUBLISH", "path", sanitize(p.write.Path), "version", doc.Version, "token_label", sanitize(p.tokenLabel), "success", true)
+		resp.Status = protocol.StatusOK
+		return resp
+	}
+	h.refreshTOCsFor(p.write.Path)
+	h.logger().Info("publish", "audit", true, "operation", "PUBLISH", "path", sanitize(p.write.Path), "version", doc.Version, "token_label", sanitize(p.tokenLabel), "success", true, "size_bytes", len(p.write.Content), "agent", sanitize(p.write.Meta["agent"]))
+	return resp
 }
 
 func (h *Handler) handleAppend(w io.Writer, req protocol.Request) {
@@ -719,6 +1048,10 @@ func (h *Handler) handleAppend(w io.Writer, req protocol.Request) {
 		h.writeError(w, protocol.StatusBadRequest, "paths matching /sha256-<hash> are reserved")
 		return
 	}
+	if h.isTOCPath(req.Path) {
+		h.writeError(w, protocol.StatusBadRequest, req.Path+" is generated by the server")
+		return
+	}
 	if int64(len(req.Body)) > protocol.MaxBodyLength {
 		h.logger().Error("body too large", "path", sanitize(req.Path), "size_bytes", len(req.Body))
 		h.writeError(w, protocol.StatusServerError, "content exceeds size limit")
@@ -741,22 +1074,21 @@ func (h *Handler) handleAppend(w io.Writer, req protocol.Request) {
 	token := req.Metadata["auth"]
 	tokenLabel, err := ts.Authorize(token, req.Path, "publish")
 	if err != nil {
-		switch {
-		case errors.Is(err, auth.ErrNoToken), errors.Is(err, auth.ErrInvalidToken), errors.Is(err, auth.ErrTokenExpired):
-			h.logger().Warn("unauthorized", "operation", "APPEND", "path", sanitize(req.Path))
-			h.writeError(w, protocol.StatusUnauthorized, "authentication required")
-		default:
-			h.logger().Warn("not permitted", "operation", "APPEND", "path", sanitize(req.Path))
-			h.writeError(w, protocol.StatusNotPermitted, "insufficient permissions")
-		}
+		h.writeAuthError(w, "APPEND", req.Path, err)
 		return
 	}
 
 	pubMeta, err := extractPublisherMeta(req.Metadata)
+	if err == nil {
+		pubMeta, err = withTokenMeta(ts, token, pubMeta)
+	}
 	if err != nil {
 		h.writeError(w, protocol.StatusBadRequest, err.Error())
 		return
 	}
+	if !h.authorizeAliases(w, req, ts, pubMeta) {
+		return
+	}
 
 	ev := req.Metadata["expected-version"]
 	if ev == "" {
@@ -769,6 +1101,15 @@ func (h *Handler) handleAppend(w io.Writer, req protocol.Request) {
 		return
 	}
 
+	if !h.scanned(w, "APPEND", req.Path, tokenLabel, req.Body) {
+		return
+	}
+	if h.exceedsVersionLimit(req.Path, req.Body, pubMeta) {
+		h.logger().Info("append rejected", "audit", true, "operation", "APPEND", "path", sanitize(req.Path), "token_label", sanitize(tokenLabel), "success", false, "reason", "version limit")
+		h.writeVersionLimit(w, req.Path)
+		return
+	}
+
 	doc, err := h.Store.Append(req.Path, expectedVersion, []byte(req.Body), pubMeta)
 	if err != nil {
 		if errors.Is(err, store.ErrConflict) {
@@ -805,7 +1146,7 @@ func (h *Handler) handleAppend(w io.Writer, req protocol.Request) {
 		return
 	}
 
-	h.logger().Info("append", "audit", true, "operation", "APPEND", "path", sanitize(req.Path), "version", doc.Version, "token_label", sanitize(tokenLabel), "success", true, "size_bytes", len(req.Body))
+	h.logger().Info("append", "audit", true, "operation", "APPEND", "path", sanitize(req.Path), "version", doc.Version, "token_label", sanitize(tokenLabel), "success", true, "size_bytes", len(req.Body), "agent", sanitize(pubMeta["agent"]))
 	resp := protocol.Response{
 		Status: protocol.StatusCreated,
 		Metadata: map[string]string{
@@ -816,6 +1157,29 @@ func (h *Handler) handleAppend(w io.Writer, req protocol.Request) {
 	h.writeResponse(w, resp)
 }
 
+// exceedsVersionLimit reports whether writing body and meta to reqPath
+// would give it more than MaxVersions versions. Republishing the current
+// content creates no version, so it never exceeds the limit.
+func (h *Handler) exceedsVersionLimit(reqPath, body string, meta map[string]string) bool {
+	if h.MaxVersions <= 0 || h.Store.CurrentVersion(reqPath) < h.MaxVersions {
+		return false
+	}
+	doc, err := h.Store.Get(reqPath, 0)
+	return err != nil || stripFrontmatter(string(doc.Content)) != body || !maps.Equal(doc.Metadata, meta)
+}
+
+// writeVersionLimit tells the client that reqPath is out of versions and
+// what it can do about it.
+func (h *Handler) writeVersionLimit(w io.Writer, reqPath string) {
+	limit := strconv.Itoa(h.MaxVersions)
+	resp := protocol.Response{
+		Status:   protocol.StatusVersionLimit,
+		Metadata: map[string]string{"max-versions": limit},
+		Body:     fmt.Sprintf("# Version Limit\n\n%s has reached this server's limit of %s versions, so it cannot be changed.\n\nArchive it and publish the content under a new path, or ask the server operator to raise the limit.\n", reqPath, limit),
+	}
+	h.writeResponse(w, resp)
+}
+
 func (h *Handler) handleHealth(w io.Writer) {
 	resp := protocol.Response{
 		Status:   protocol.StatusOK,
@@ -825,6 +1189,22 @@ func (h *Handler) handleHealth(w io.Writer) {
 	h.writeResponse(w, resp)
 }
 
+// writeAuthError answers a request whose token was rejected for op on
+// reqPath, and reports invalid tokens to InvalidToken.
+func (h *Handler) writeAuthError(w io.Writer, op, reqPath string, err error) {
+	switch {
+	case errors.Is(err, auth.ErrNoToken), errors.Is(err, auth.ErrInvalidToken), errors.Is(err, auth.ErrTokenExpired):
+		h.logger().Warn("unauthorized", "operation", op, "path", sanitize(reqPath))
+		h.writeError(w, protocol.StatusUnauthorized, "authentication required")
+		if errors.Is(err, auth.ErrInvalidToken) && h.InvalidToken != nil {
+			h.InvalidToken()
+		}
+	default:
+		h.logger().Warn("not permitted", "operation", op, "path", sanitize(reqPath))
+		h.writeError(w, protocol.StatusNotPermitted, "insufficient permissions")
+	}
+}
+
 func (h *Handler) writeError(w io.Writer, status, message string) {
 	resp := protocol.Response{
 		Status:   status,
@@ -834,7 +1214,29 @@ func (h *Handler) writeError(w io.Writer, status, message string) {
 	h.writeResponse(w, resp)
 }
 
+// writeResponse writes resp to w, naming the protocol version the server
+// speaks, and echoing the request's request-id, in every response but
+// not-modified, which carries no metadata.
 func (h *Handler) writeResponse(w io.Writer, resp protocol.Response) {
+	if resp.Status != protocol.StatusNotModified {
+		resp.Metadata = maps.Clone(resp.Metadata)
+		if resp.Metadata == nil {
+			resp.Metadata = make(map[string]string)
+		}
+		resp.Metadata["server-protocol"] = protocol.ProtocolVersion
+		if h.requestID != "" {
+			resp.Metadata[protocol.MetaRequestID] = h.requestID
+		}
+	}
+	// The encoding wraps the trailer stream, so that the trailer's
+	// body-hash covers the body as sent.
+	if es, ok := w.(*encodingStream); ok {
+		resp = es.encode(resp)
+		w = es.Stream
+	}
+	if ts, ok := w.(*trailerStream); ok {
+		resp.Trailer = ts.trailer(resp.Body)
+	}
 	if _, err := resp.WriteTo(w); err != nil {
 		h.logger().Error("write response failed", "error", err)
 	}
@@ -894,18 +1296,20 @@ func escapeURL(s string) string {
 	return url.PathEscape(s)
 }
 
-// extractPublisherMeta returns non-control metadata keys from a request.
-// Returns nil if no publisher keys are present.
+// extractPublisherMeta returns the publisher metadata of a write request:
+// every key but the control keys writes consume, and experimental and
+// echoed keys, which are not stored either. Returns nil if no publisher
+// keys are present.
 func extractPublisherMeta(reqMeta map[string]string) (map[string]string, error) {
 	var meta map[string]string
 	size := 0
 	for k, v := range reqMeta {
-		if controlKeys[k] {
-			continue
-		}
-		if reservedKeys[k] {
+		if protocol.IsServerKey(k) {
 			return nil, fmt.Errorf("metadata key %q is reserved", k)
 		}
+		if !protocol.IsPublisherKey(k) {
+			continue
+		}
 		if !protocol.IsValidMetaKey(k) {
 			return nil, fmt.Errorf("metadata key %q contains invalid characters", k)
 		}
@@ -927,12 +1331,32 @@ func extractPublisherMeta(reqMeta map[string]string) (map[string]string, error)
 	return meta, nil
 }
 
+// withTokenMeta adds the metadata of the token a write presents to the
+// request's publisher metadata. The token's keys replace the request's,
+// so that a tool cannot forge provenance such as agent. The result must
+// still fit the protocol's limits.
+func withTokenMeta(ts *auth.TokenStore, token string, meta map[string]string) (map[string]string, error) {
+	tok, err := ts.Lookup(token)
+	if err != nil || len(tok.Meta) == 0 {
+		return meta, nil
+	}
+	merged := maps.Clone(meta)
+	if merged == nil {
+		merged = make(map[string]string, len(tok.Meta))
+	}
+	maps.Copy(merged, tok.Meta)
+	if err := auth.ValidateMeta(merged); err != nil {
+		return nil, fmt.Errorf("metadata with the token's defaults: %w", err)
+	}
+	return merged, nil
+}
+
 // copyPublisherMeta copies stored metadata into dst, filtering out any
-// reserved or control keys. This prevents tampered version files from
-// leaking server-owned keys into responses.
+// key a write could not have stored. This prevents tampered version files
+// from leaking server-owned keys into responses.
 func copyPublisherMeta(dst, src map[string]string) {
 	for k, v := range src {
-		if reservedKeys[k] || controlKeys[k] {
+		if !protocol.IsPublisherKey(k) {
 			continue
 		}
 		if !protocol.IsValidMetaKey(k) || !protocol.IsValidMetaValue(v) {
@@ -2,18 +2,27 @@ package handler
 
 import (
 	"bytes"
+	"crypto/ed25519"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	"github.com/latebit/demarkus/protocol"
 	"github.com/latebit/demarkus/server/internal/auth"
+	"github.com/latebit/demarkus/server/internal/jobs"
+	"github.com/latebit/demarkus/server/internal/scan"
 	"github.com/latebit/demarkus/server/internal/store"
+	"github.com/latebit/demarkus/server/internal/transform"
 )
 
 var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
@@ -49,7 +58,7 @@ func setupContentDir(t *testing.T, files map[string]string) string {
 
 // setupVersionedDir creates a content directory and writes files through the
 // store so they have proper version history. Returns the dir and store.
-func setupVersionedDir(t *testing.T, files map[string]string) (string, *store.Store) {
+func setupVersionedDir(t testing.TB, files map[string]string) (string, *store.Store) {
 	t.Helper()
 	dir := t.TempDir()
 	s := store.New(dir)
@@ -448,6 +457,120 @@ func TestHandleList(t *testing.T) {
 		}
 	})
 
+	t.Run("structured listing", func(t *testing.T) {
+		if _, err := s.Write("/docs/guide.md", []byte("# Guide, revised\n"), nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "flat.md"), []byte("# Flat\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = os.Remove(filepath.Join(dir, "flat.md")) }()
+
+		stream := newMockStream("LIST /\n---\nformat: structured\n---\n")
+		h.HandleStream(stream)
+		resp, err := protocol.ParseResponse(&stream.output)
+		if err != nil {
+			t.Fatalf("parse response: %v", err)
+		}
+		if resp.Status != protocol.StatusOK || resp.Metadata["content-type"] != protocol.ListingContentType {
+			t.Fatalf("response = %+v", resp)
+		}
+		if resp.Metadata["entries"] != "4" || resp.Metadata["total"] != "4" {
+			t.Errorf("entries, total = %q, %q, want 4, 4", resp.Metadata["entries"], resp.Metadata["total"])
+		}
+		entries, err := protocol.ParseListing(resp.Body)
+		if err != nil {
+			t.Fatalf("parse listing: %v\n%s", err, resp.Body)
+		}
+		byName := make(map[string]protocol.ListEntry)
+		for _, e := range entries {
+			byName[e.Name] = e
+		}
+		if e := byName["docs"]; e.Type != protocol.EntryDirectory {
+			t.Errorf("docs = %+v", e)
+		}
+		if e := byName["about.md"]; e.Type != protocol.EntryDocument || e.Size != len("# About\n") || e.Version != 1 || e.Modified.IsZero() {
+			t.Errorf("about.md = %+v", e)
+		}
+		if e := byName["flat.md"]; e.Type != protocol.EntryFile || e.Version != 0 {
+			t.Errorf("flat.md = %+v", e)
+		}
+
+		stream = newMockStream("LIST /docs/\n---\nformat: structured\n---\n")
+		h.HandleStream(stream)
+		resp, _ = protocol.ParseResponse(&stream.output)
+		entries, err = protocol.ParseListing(resp.Body)
+		if err != nil || len(entries) != 2 || entries[0].Name != "guide.md" || entries[0].Version != 2 {
+			t.Errorf("docs listing = %+v, %v", entries, err)
+		}
+	})
+
+	t.Run("pages and sort", func(t *testing.T) {
+		for _, name := range []string{"a.md", "b.md", "c.md", "d.md", "e.md", "a.md"} {
+			if _, err := s.Write("/paged/"+name, []byte("# "+name+" "+time.Now().String()+"\n"), nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+		list := func(meta string) (protocol.Response, []protocol.ListEntry) {
+			t.Helper()
+			stream := newMockStream("LIST /paged/\n---\nformat: structured\n" + meta + "---\n")
+			h.HandleStream(stream)
+			resp, err := protocol.ParseResponse(&stream.output)
+			if err != nil {
+				t.Fatalf("parse response: %v", err)
+			}
+			entries, _ := protocol.ParseListing(resp.Body)
+			return resp, entries
+		}
+		names := func(entries []protocol.ListEntry) string {
+			var b []string
+			for _, e := range entries {
+				b = append(b, e.Name)
+			}
+			return strings.Join(b, " ")
+		}
+
+		resp, entries := list("limit: 2\n")
+		if names(entries) != "a.md b.md" || resp.Metadata["next-offset"] != "2" || resp.Metadata["total"] != "5" {
+			t.Errorf("first page = %q, %v", names(entries), resp.Metadata)
+		}
+		resp, entries = list("offset: 4\nlimit: 2\n")
+		if names(entries) != "e.md" || resp.Metadata["next-offset"] != "" {
+			t.Errorf("last page = %q, %v", names(entries), resp.Metadata)
+		}
+		if _, entries = list("offset: 9\n"); len(entries) != 0 {
+			t.Errorf("page past the end = %q", names(entries))
+		}
+		if _, entries = list("sort: modified\nlimit: 3\n"); names(entries) != "a.md e.md d.md" {
+			t.Errorf("by modified = %q, want a.md e.md d.md", names(entries))
+		}
+
+		stream := newMockStream("LIST /paged/\n---\nlimit: 2\noffset: 2\n---\n")
+		h.HandleStream(stream)
+		md, _ := protocol.ParseResponse(&stream.output)
+		if !strings.Contains(md.Body, "[c.md]") || strings.Contains(md.Body, "[a.md]") || md.Metadata["next-offset"] != "4" || md.Metadata["entries"] != "2" {
+			t.Errorf("markdown page = %+v", md)
+		}
+
+		for _, meta := range []string{"offset: -1\n", "limit: 0\n", "limit: 1001\n", "sort: size\n"} {
+			if resp, _ := list(meta); resp.Status != protocol.StatusBadRequest {
+				t.Errorf("%q: status %q, want bad-request", meta, resp.Status)
+			}
+		}
+	})
+
+	t.Run("unknown listing format", func(t *testing.T) {
+		stream := newMockStream("LIST /\n---\nformat: csv\n---\n")
+		h.HandleStream(stream)
+		resp, err := protocol.ParseResponse(&stream.output)
+		if err != nil {
+			t.Fatalf("parse response: %v", err)
+		}
+		if resp.Status != protocol.StatusBadRequest {
+			t.Errorf("status: got %q, want %q", resp.Status, protocol.StatusBadRequest)
+		}
+	})
+
 	t.Run("list nonexistent directory", func(t *testing.T) {
 		stream := newMockStream("LIST /nope/\n")
 		h.HandleStream(stream)
@@ -902,6 +1025,28 @@ func TestHandleVersions(t *testing.T) {
 		}
 	})
 
+	t.Run("agent-authored versions", func(t *testing.T) {
+		if _, err := s.Write("/doc.md", []byte("# V3\n"), map[string]string{"agent": "claude-code"}); err != nil {
+			t.Fatalf("write v3: %v", err)
+		}
+		stream := newMockStream("VERSIONS /doc.md\n")
+		h.HandleStream(stream)
+
+		resp, err := protocol.ParseResponse(&stream.output)
+		if err != nil {
+			t.Fatalf("parse response: %v", err)
+		}
+		for line := range strings.SplitSeq(resp.Body, "\n") {
+			if !strings.HasPrefix(line, "- [v") {
+				continue
+			}
+			want := strings.HasPrefix(line, "- [v3]")
+			if got := strings.HasSuffix(line, " - agent: claude-code"); got != want {
+				t.Errorf("line %q: agent shown = %v, want %v", line, got, want)
+			}
+		}
+	})
+
 	t.Run("flat file not found", func(t *testing.T) {
 		flatDir := setupContentDir(t, map[string]string{
 			"flat.md": "# Flat\n",
@@ -985,6 +1130,36 @@ func TestFetchVersion(t *testing.T) {
 		}
 	})
 
+	t.Run("version fetch exposes chain metadata", func(t *testing.T) {
+		v1Raw, err := os.ReadFile(filepath.Join(dir, "versions", "doc.md.v1"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		stream := newMockStream("FETCH /doc.md/v1\n")
+		h.HandleStream(stream)
+		v1, err := protocol.ParseResponse(&stream.output)
+		if err != nil {
+			t.Fatalf("parse response: %v", err)
+		}
+		if v1.Metadata["etag"] != computeEtag(v1Raw) {
+			t.Errorf("etag: got %q, want hash of raw v1 file", v1.Metadata["etag"])
+		}
+		if _, ok := v1.Metadata["previous-hash"]; ok {
+			t.Error("v1 should not carry previous-hash")
+		}
+
+		stream = newMockStream("FETCH /doc.md/v2\n")
+		h.HandleStream(stream)
+		v2, err := protocol.ParseResponse(&stream.output)
+		if err != nil {
+			t.Fatalf("parse response: %v", err)
+		}
+		if want := "sha256-" + v1.Metadata["etag"]; v2.Metadata["previous-hash"] != want {
+			t.Errorf("previous-hash: got %q, want %q", v2.Metadata["previous-hash"], want)
+		}
+	})
+
 	t.Run("fetch nonexistent version", func(t *testing.T) {
 		stream := newMockStream("FETCH /doc.md/v99\n")
 		h.HandleStream(stream)
@@ -1062,6 +1237,50 @@ func TestVersionsChainValid(t *testing.T) {
 	})
 }
 
+func TestFetchChainStatus(t *testing.T) {
+	dir := t.TempDir()
+	s := store.New(dir)
+	for _, body := range []string{"# V1\n", "# V2\n"} {
+		if _, err := s.Write("/doc.md", []byte(body), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := &Handler{ContentDir: dir, Store: s, Logger: discardLogger}
+	fetch := func(verb string) protocol.Response {
+		t.Helper()
+		stream := newMockStream(verb + " /doc.md\n")
+		h.HandleStream(stream)
+		resp, err := protocol.ParseResponse(&stream.output)
+		if err != nil {
+			t.Fatalf("parse response: %v", err)
+		}
+		return resp
+	}
+
+	for _, verb := range []string{"FETCH", "INFO"} {
+		resp := fetch(verb)
+		if resp.Metadata["chain-valid"] != "true" || resp.Metadata["chain-checked"] == "" || resp.Metadata["chain-error"] != "" {
+			t.Errorf("%s metadata: %v", verb, resp.Metadata)
+		}
+	}
+
+	// A broken chain is reported once the scanner has found it.
+	if err := os.WriteFile(filepath.Join(dir, "versions", "doc.md.v1"), []byte("# TAMPERED\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if resp := fetch("FETCH"); resp.Metadata["chain-valid"] != "true" {
+		t.Errorf("chain-valid before the scan: %q", resp.Metadata["chain-valid"])
+	}
+	s.ScanChains(nil)
+	resp := fetch("FETCH")
+	if resp.Metadata["chain-valid"] != "false" || resp.Metadata["chain-error"] == "" {
+		t.Errorf("after the scan: %v", resp.Metadata)
+	}
+	if resp.Status != protocol.StatusOK || resp.Body != "# V2\n" {
+		t.Errorf("document not served: %s %q", resp.Status, resp.Body)
+	}
+}
+
 func TestHandlePublish(t *testing.T) {
 	// A permissive token store for tests that need to exercise write logic.
 	const testSecret = "test-publish-secret"
@@ -1882,6 +2101,42 @@ func TestPublisherMetadata(t *testing.T) {
 		}
 	})
 
+	t.Run("ordinary names survive a round trip", func(t *testing.T) {
+		dir := t.TempDir()
+		s := store.New(dir)
+		h := &Handler{ContentDir: dir, Store: s, Logger: discardLogger, GetTokenStore: func() *auth.TokenStore { return tokenStore }}
+
+		want := map[string]string{"size": "large", "location": "Berlin", "format": "letter", "sort": "b", "results": "none"}
+		var fm strings.Builder
+		fm.WriteString("---\nauth: " + testSecret + "\n")
+		for k, v := range want {
+			fm.WriteString(k + ": " + v + "\n")
+		}
+		fm.WriteString("---\n# Hello\n")
+
+		stream := newMockStream("PUBLISH /doc.md\n" + fm.String())
+		h.HandleStream(stream)
+		resp, err := protocol.ParseResponse(&stream.output)
+		if err != nil {
+			t.Fatalf("parse response: %v", err)
+		}
+		if resp.Status != protocol.StatusCreated {
+			t.Fatalf("status: got %q, want %q", resp.Status, protocol.StatusCreated)
+		}
+
+		stream = newMockStream("FETCH /doc.md\n")
+		h.HandleStream(stream)
+		resp, err = protocol.ParseResponse(&stream.output)
+		if err != nil {
+			t.Fatalf("parse fetch response: %v", err)
+		}
+		for k, v := range want {
+			if resp.Metadata[k] != v {
+				t.Errorf("%s: got %q, want %q", k, resp.Metadata[k], v)
+			}
+		}
+	})
+
 	t.Run("too many metadata keys", func(t *testing.T) {
 		dir := t.TempDir()
 		s := store.New(dir)
@@ -1999,10 +2254,12 @@ func TestPublisherMetadata(t *testing.T) {
 		if resp.Metadata["version"] != "1" {
 			t.Errorf("version: got %q, want %q", resp.Metadata["version"], "1")
 		}
-		// No extra keys beyond standard metadata: version, modified, etag, content-hash.
+		// No extra keys beyond standard metadata: version, modified, etag, content-hash, content-sha256, server-protocol,
+		// size, word-count, reading-time, chain-valid, chain-checked.
 		for k := range resp.Metadata {
 			switch k {
-			case "version", "modified", "etag", "content-hash":
+			case "version", "modified", "etag", "content-hash", "content-sha256", "server-protocol", "content-length", "word-count", "reading-time",
+				"chain-valid", "chain-checked":
 				// expected
 			default:
 				t.Errorf("unexpected metadata key %q in legacy document", k)
@@ -2202,3 +2459,2213 @@ func TestReadAuth(t *testing.T) {
 		}
 	})
 }
+
+// deadlineStream records read deadlines set by the handler.
+type deadlineStream struct {
+	mockStream
+	deadlines []time.Time
+}
+
+func (d *deadlineStream) SetReadDeadline(t time.Time) error {
+	d.deadlines = append(d.deadlines, t)
+	return nil
+}
+
+func TestVerbRequestTimeout(t *testing.T) {
+	dir := setupContentDir(t, map[string]string{"hello.md": "# Hello"})
+	timeouts := map[string]time.Duration{
+		protocol.VerbFetch:   5 * time.Second,
+		protocol.VerbPublish: time.Minute,
+	}
+	h := &Handler{
+		ContentDir:     dir,
+		Store:          store.New(dir),
+		Logger:         discardLogger,
+		RequestTimeout: func(verb string) time.Duration { return timeouts[verb] },
+	}
+
+	tests := []struct {
+		name    string
+		request string
+		want    time.Duration
+	}{
+		{"fetch", "FETCH /hello.md\n", 5 * time.Second},
+		{"publish", "PUBLISH /hello.md\n---\n---\nbody", time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// One byte per read so the request line spans many reads.
+			s := &deadlineStream{mockStream: mockStream{Reader: iotest.OneByteReader(strings.NewReader(tt.request))}}
+			before := time.Now()
+			h.HandleStream(s)
+
+			if len(s.deadlines) != 1 {
+				t.Fatalf("deadlines set: got %d, want 1", len(s.deadlines))
+			}
+			if got := s.deadlines[0].Sub(before); got < tt.want || got > tt.want+time.Second {
+				t.Errorf("deadline: got %v from start, want about %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("unconfigured verb keeps deadline", func(t *testing.T) {
+		s := &deadlineStream{mockStream: mockStream{Reader: strings.NewReader("LIST /\n")}}
+		h.HandleStream(s)
+		if len(s.deadlines) != 0 {
+			t.Errorf("deadlines set: got %d, want 0", len(s.deadlines))
+		}
+	})
+}
+
+func TestDenyPaths(t *testing.T) {
+	dir, s := setupVersionedDir(t, map[string]string{
+		"index.md":           "# Home",
+		"notes.secret.md":    "# Secret",
+		"private/plan.md":    "# Plan",
+		"public/ok.md":       "# OK",
+		"public/x.secret.md": "# Hidden",
+	})
+	h := &Handler{
+		ContentDir: dir,
+		Store:      s,
+		Logger:     discardLogger,
+		DenyPaths:  []string{"/private/**", "*.secret.md"},
+	}
+
+	tests := []struct {
+		name       string
+		request    string
+		wantStatus string
+	}{
+		{"fetch under denied dir", "FETCH /private/plan.md\n", protocol.StatusNotFound},
+		{"fetch denied dir itself", "FETCH /private\n", protocol.StatusNotFound},
+		{"list denied dir", "LIST /private/\n", protocol.StatusNotFound},
+		{"fetch denied name at root", "FETCH /notes.secret.md\n", protocol.StatusNotFound},
+		{"fetch denied name nested", "FETCH /public/x.secret.md\n", protocol.StatusNotFound},
+		{"fetch denied version", "FETCH /notes.secret.md/v1\n", protocol.StatusNotFound},
+		{"versions denied", "VERSIONS /private/plan.md\n", protocol.StatusNotFound},
+		{"publish denied", "PUBLISH /private/new.md\n---\nauth: x\n---\n# New", protocol.StatusNotFound},
+		{"fetch allowed", "FETCH /public/ok.md\n", protocol.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := newMockStream(tt.request)
+			h.HandleStream(stream)
+			resp, err := protocol.ParseResponse(&stream.output)
+			if err != nil {
+				t.Fatalf("parse response: %v", err)
+			}
+			if resp.Status != tt.wantStatus {
+				t.Errorf("status: got %q, want %q", resp.Status, tt.wantStatus)
+			}
+		})
+	}
+
+	t.Run("hash of denied document", func(t *testing.T) {
+		sum := sha256.Sum256([]byte("# Plan"))
+		stream := newMockStream("FETCH /sha256-" + hex.EncodeToString(sum[:]) + "\n")
+		h.HandleStream(stream)
+		resp, err := protocol.ParseResponse(&stream.output)
+		if err != nil {
+			t.Fatalf("parse response: %v", err)
+		}
+		if resp.Status != protocol.StatusNotFound {
+			t.Errorf("status: got %q, want %q", resp.Status, protocol.StatusNotFound)
+		}
+	})
+
+	t.Run("listing hides denied entries", func(t *testing.T) {
+		for _, req := range []string{"LIST /\n", "LIST /public/\n"} {
+			stream := newMockStream(req)
+			h.HandleStream(stream)
+			resp, err := protocol.ParseResponse(&stream.output)
+			if err != nil {
+				t.Fatalf("parse response: %v", err)
+			}
+			if strings.Contains(resp.Body, "private") || strings.Contains(resp.Body, "secret") {
+				t.Errorf("%s listing reveals denied entry: %q", strings.TrimSpace(req), resp.Body)
+			}
+		}
+	})
+}
+
+func TestInvalidRequestMetadata(t *testing.T) {
+	dir, s := setupVersionedDir(t, map[string]string{"doc.md": "# Doc"})
+	h := &Handler{ContentDir: dir, Store: s, Logger: discardLogger}
+
+	for _, meta := range []string{
+		"if-modified-since: last tuesday",
+		"if-match: abc",
+		"etag: abc",
+	} {
+		t.Run(meta, func(t *testing.T) {
+			stream := newMockStream("FETCH /doc.md\n---\n" + meta + "\n---\n")
+			h.HandleStream(stream)
+			resp, err := protocol.ParseResponse(&stream.output)
+			if err != nil {
+				t.Fatalf("parse response: %v", err)
+			}
+			if resp.Status != protocol.StatusBadRequest {
+				t.Errorf("status: got %q, want %q", resp.Status, protocol.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestDetectTampering(t *testing.T) {
+	dir, s := setupVersionedDir(t, map[string]string{"doc.md": "# Original"})
+	if _, err := s.Write("/doc.md", []byte("# Second"), nil); err != nil {
+		t.Fatal(err)
+	}
+	v1 := filepath.Join(dir, "versions", "doc.md.v1")
+	data, err := os.ReadFile(v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(v1, bytes.Replace(data, []byte("Original"), []byte("Rewritten"), 1), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	fetch := func(h *Handler, path string) protocol.Response {
+		t.Helper()
+		stream := newMockStream("FETCH " + path + "\n")
+		h.HandleStream(stream)
+		resp, err := protocol.ParseResponse(&stream.output)
+		if err != nil {
+			t.Fatalf("parse response: %v", err)
+		}
+		return resp
+	}
+
+	h := &Handler{ContentDir: dir, Store: s, Logger: discardLogger, DetectTampering: true}
+	if resp := fetch(h, "/doc.md/v1"); resp.Metadata["tampered"] != "true" {
+		t.Errorf("edited v1: tampered = %q, want true", resp.Metadata["tampered"])
+	}
+	if resp := fetch(h, "/doc.md"); resp.Metadata["tampered"] != "" {
+		t.Errorf("intact current version flagged: %v", resp.Metadata)
+	}
+
+	h.DetectTampering = false
+	if resp := fetch(h, "/doc.md/v1"); resp.Metadata["tampered"] != "" {
+		t.Errorf("detection disabled but flagged: %v", resp.Metadata)
+	}
+}
+
+func TestInvalidTokenHook(t *testing.T) {
+	const testSecret = "test-secret-token"
+	ts := auth.NewTokenStore(map[string]auth.Token{
+		auth.HashToken(testSecret): {
+			Paths:      []string{"/*"},
+			Operations: []string{"publish"},
+		},
+	})
+
+	tests := []struct {
+		name    string
+		request string
+		want    int
+	}{
+		{"invalid token", "PUBLISH /doc.md\n---\nauth: wrong\n---\n# Hi\n", 1},
+		{"missing token", "PUBLISH /doc.md\n\n# Hi\n", 0},
+		{"valid token", "PUBLISH /doc.md\n---\nauth: " + testSecret + "\n---\n# Hi\n", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			calls := 0
+			h := &Handler{
+				ContentDir:    dir,
+				Store:         store.New(dir),
+				Logger:        discardLogger,
+				GetTokenStore: func() *auth.TokenStore { return ts },
+				InvalidToken:  func() { calls++ },
+			}
+			h.HandleStream(newMockStream(tt.request))
+			if calls != tt.want {
+				t.Errorf("InvalidToken called %d times, want %d", calls, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchHTML(t *testing.T) {
+	dir, s := setupVersionedDir(t, map[string]string{
+		"page.md": "# Title\n\nSee [next](next.md) and [bad](javascript:alert(1)).\n\n<script>alert(1)</script>\n\n| a | b |\n|---|---|\n| 1 | 2 |\n",
+	})
+	h := &Handler{ContentDir: dir, Store: s, Logger: discardLogger}
+	fetch := func(req string) protocol.Response {
+		t.Helper()
+		stream := newMockStream(req)
+		h.HandleStream(stream)
+		resp, err := protocol.ParseResponse(&stream.output)
+		if err != nil {
+			t.Fatalf("parse response: %v", err)
+		}
+		return resp
+	}
+
+	md := fetch("FETCH /page.md\n")
+	if _, ok := md.Metadata["content-type"]; ok || !strings.HasPrefix(md.Body, "# Title") {
+		t.Fatalf("markdown response changed: %+v", md)
+	}
+
+	for _, req := range []string{
+		"FETCH /page.md\n---\naccept: text/markdown;q=0.5, TEXT/HTML\n---\n",
+		"FETCH /page.md/v1\n---\naccept: text/html\n---\n",
+	} {
+		resp := fetch(req)
+		if resp.Status != protocol.StatusOK || resp.Metadata["content-type"] != "text/html; charset=utf-8" {
+			t.Fatalf("%q: status %q, metadata %v", req, resp.Status, resp.Metadata)
+		}
+		for _, want := range []string{"<h1>Title</h1>", `<a href="next.md">next</a>`, "<table>"} {
+			if !strings.Contains(resp.Body, want) {
+				t.Errorf("%q: body missing %q:\n%s", req, want, resp.Body)
+			}
+		}
+		for _, unsafe := range []string{"<script>", "javascript:"} {
+			if strings.Contains(resp.Body, unsafe) {
+				t.Errorf("%q: body contains %q:\n%s", req, unsafe, resp.Body)
+			}
+		}
+		// Validators describe the markdown, whatever the representation.
+		if resp.Metadata["etag"] != md.Metadata["etag"] || resp.Metadata["content-hash"] != md.Metadata["content-hash"] {
+			t.Errorf("%q: validators differ from the markdown response", req)
+		}
+	}
+
+	// Directory listings are documents too.
+	if resp := fetch("FETCH /\n---\naccept: text/html\n---\n"); !strings.Contains(resp.Body, `<a href="page.md">page.md</a>`) {
+		t.Errorf("listing body:\n%s", resp.Body)
+	}
+}
+
+func TestTableOfContents(t *testing.T) {
+	const secret = "toc-secret"
+	ts := auth.NewTokenStore(map[string]auth.Token{
+		auth.HashToken(secret):   {Paths: []string{"/**"}, Operations: []string{"publish"}},
+		auth.HashToken("reader"): {Paths: []string{"/docs/private/*"}, Operations: []string{"read"}},
+	})
+	dir, s := setupVersionedDir(t, map[string]string{
+		"docs/intro.md":          "# Introduction\n\nHello.\n",
+		"docs/guides/[draft].md": "No heading here.\n",
+		"docs/private/secret.md": "# Secret\n",
+		"docs/hidden.secret.md":  "# Hidden\n",
+		"other.md":               "# Elsewhere\n",
+	})
+	h := &Handler{
+		ContentDir:    dir,
+		Store:         s,
+		Logger:        discardLogger,
+		DenyPaths:     []string{"*.secret.md"},
+		TOCPaths:      []string{"/docs"},
+		GetTokenStore: func() *auth.TokenStore { return ts },
+	}
+	send := func(req string) protocol.Response {
+		t.Helper()
+		stream := newMockStream(req)
+		h.HandleStream(stream)
+		resp, err := protocol.ParseResponse(&stream.output)
+		if err != nil {
+			t.Fatalf("parse response: %v", err)
+		}
+		return resp
+	}
+
+	h.RefreshTOCs()
+	toc := send("FETCH /docs/_toc.md\n")
+	if toc.Status != protocol.StatusOK || toc.Metadata["version"] != "1" {
+		t.Fatalf("toc: status %q, metadata %v", toc.Status, toc.Metadata)
+	}
+	want := "# Contents of /docs/\n\n" +
+		"_Generated by the server when a document in this directory is published._\n\n" +
+		"- [Introduction](intro.md)\n\n" +
+		"## guides/\n\n" +
+		"- [\\[draft\\]](guides/%5Bdraft%5D.md)\n"
+	if toc.Body != want {
+		t.Errorf("toc body:\n%s\nwant:\n%s", toc.Body, want)
+	}
+
+	// Unchanged contents do not add a version.
+	h.RefreshTOCs()
+	if v := s.CurrentVersion("/docs/_toc.md"); v != 1 {
+		t.Errorf("version after idle refresh = %d, want 1", v)
+	}
+
+	meta := "---\nauth: " + secret + "\ntitle: Setup\ndescription: Install and run.\n---\n"
+	if resp := send("PUBLISH /docs/guides/setup.md\n" + meta + "# Ignored heading\n"); resp.Status != protocol.StatusCreated {
+		t.Fatalf("publish: %q %s", resp.Status, resp.Body)
+	}
+	toc = send("FETCH /docs/_toc.md\n")
+	if !strings.Contains(toc.Body, "- [Setup](guides/setup.md) — Install and run.\n") || toc.Metadata["version"] != "2" {
+		t.Errorf("toc after publish (v%s):\n%s", toc.Metadata["version"], toc.Body)
+	}
+
+	// Publishing outside the directory leaves it alone.
+	send("PUBLISH /other.md\n" + meta + "# Moved\n")
+	if v := s.CurrentVersion("/docs/_toc.md"); v != 2 {
+		t.Errorf("version after unrelated publish = %d, want 2", v)
+	}
+
+	send("ARCHIVE /docs/intro.md\n---\nauth: " + secret + "\n---\n")
+	if toc = send("FETCH /docs/_toc.md\n"); strings.Contains(toc.Body, "intro.md") {
+		t.Errorf("archived document still listed:\n%s", toc.Body)
+	}
+
+	for _, verb := range []string{"PUBLISH", "ARCHIVE"} {
+		if resp := send(verb + " /docs/_toc.md\n" + meta + "# Mine\n"); resp.Status != protocol.StatusBadRequest {
+			t.Errorf("%s of the toc: status %q, want %q", verb, resp.Status, protocol.StatusBadRequest)
+		}
+	}
+}
+
+func TestMaxVersions(t *testing.T) {
+	const secret = "limit-secret"
+	ts := auth.NewTokenStore(map[string]auth.Token{
+		auth.HashToken(secret): {Paths: []string{"/**"}, Operations: []string{"publish"}},
+	})
+	dir, s := setupVersionedDir(t, map[string]string{"doc.md": "# One\n"})
+	h := &Handler{ContentDir: dir, Store: s, Logger: discardLogger, MaxVersions: 2, GetTokenStore: func() *auth.TokenStore { return ts }}
+	send := func(req string) protocol.Response {
+		t.Helper()
+		stream := newMockStream(req)
+		h.HandleStream(stream)
+		resp, err := protocol.ParseResponse(&stream.output)
+		if err != nil {
+			t.Fatalf("parse response: %v", err)
+		}
+		return resp
+	}
+	meta := "---\nauth: " + secret + "\n---\n"
+
+	if resp := send("PUBLISH /doc.md\n" + meta + "# Two\n"); resp.Status != protocol.StatusCreated {
+		t.Fatalf("publish v2: %q %s", resp.Status, resp.Body)
+	}
+	for _, req := range []string{
+		"PUBLISH /doc.md\n" + meta + "# Three\n",
+		"APPEND /doc.md\n---\nauth: " + secret + "\nexpected-version: 2\n---\nMore.\n",
+	} {
+		resp := send(req)
+		if resp.Status != protocol.StatusVersionLimit || resp.Metadata["max-versions"] != "2" || !strings.Contains(resp.Body, "Archive it") {
+			t.Errorf("%q: got %q %v\n%s", req, resp.Status, resp.Metadata, resp.Body)
+		}
+	}
+	if v := s.CurrentVersion("/doc.md"); v != 2 {
+		t.Errorf("version after refused writes = %d, want 2", v)
+	}
+
+	// Republishing the current content is still a no-op, and other
+	// documents are unaffected.
+	if resp := send("PUBLISH /doc.md\n" + meta + "# Two\n"); resp.Status != protocol.StatusOK {
+		t.Errorf("unchanged publish: %q %s", resp.Status, resp.Body)
+	}
+	if resp := send("PUBLISH /other.md\n" + meta + "# Other\n"); resp.Status != protocol.StatusCreated {
+		t.Errorf("other document: %q %s", resp.Status, resp.Body)
+	}
+}
+
+func TestScan(t *testing.T) {
+	const secret = "scan-secret"
+	ts := auth.NewTokenStore(map[string]auth.Token{
+		auth.HashToken(secret): {Paths: []string{"/**"}, Operations: []string{"publish"}},
+	})
+	dir, s := setupVersionedDir(t, map[string]string{"doc.md": "# One\n"})
+	var scanned []string
+	h := &Handler{ContentDir: dir, Store: s, Logger: discardLogger, GetTokenStore: func() *auth.TokenStore { return ts }}
+	h.Scan = func(path string, body []byte) error {
+		scanned = append(scanned, path)
+		switch {
+		case strings.Contains(string(body), "EICAR"):
+			return &scan.Rejection{Reason: "EICAR test file"}
+		case strings.Contains(string(body), "timeout"):
+			return errors.New("scanner did not answer")
+		}
+		return nil
+	}
+	send := func(req string) protocol.Response {
+		t.Helper()
+		stream := newMockStream(req)
+		h.HandleStream(stream)
+		resp, err := protocol.ParseResponse(&stream.output)
+		if err != nil {
+			t.Fatalf("parse response: %v", err)
+		}
+		return resp
+	}
+	meta := "---\nauth: " + secret + "\n---\n"
+	appendMeta := "---\nauth: " + secret + "\nexpected-version: 1\n---\n"
+
+	if resp := send("PUBLISH /doc.md\n" + meta + "# Two\n"); resp.Status != protocol.StatusCreated {
+		t.Errorf("clean publish: %q %s", resp.Status, resp.Body)
+	}
+	for _, tt := range []struct {
+		req  string
+		want string
+	}{
+		{"PUBLISH /doc.md\n" + meta + "# EICAR\n", protocol.StatusNotPermitted},
+		{"APPEND /doc.md\n" + appendMeta + "EICAR\n", protocol.StatusNotPermitted},
+		{"PUBLISH /doc.md\n" + meta + "# timeout\n", protocol.StatusUnavailable},
+		{"APPEND /doc.md\n" + appendMeta + "timeout\n", protocol.StatusUnavailable},
+	} {
+		if resp := send(tt.req); resp.Status != tt.want {
+			t.Errorf("%q: status %q, want %q", tt.req, resp.Status, tt.want)
+		}
+	}
+	if v := s.CurrentVersion("/doc.md"); v != 2 {
+		t.Errorf("version after refused writes = %d, want 2", v)
+	}
+
+	// Unauthenticated writes never reach the scanner.
+	scanned = nil
+	if resp := send("PUBLISH /doc.md\n---\nauth: wrong\n---\n# EICAR\n"); resp.Status != protocol.StatusUnauthorized || len(scanned) != 0 {
+		t.Errorf("unauthenticated: status %q, scanned %v", resp.Status, scanned)
+	}
+
+	// Failing open lets bodies that could not be scanned through, but
+	// still refuses rejected ones.
+	h.ScanFailOpen = true
+	if resp := send("PUBLISH /doc.md\n" + meta + "# timeout\n"); resp.Status != protocol.StatusCreated {
+		t.Errorf("fail open: %q %s", resp.Status, resp.Body)
+	}
+	if resp := send("PUBLISH /doc.md\n" + meta + "# EICAR\n"); resp.Status != protocol.StatusNotPermitted {
+		t.Errorf("fail open, rejected: %q", resp.Status)
+	}
+}
+
+func TestVersionsAuth(t *testing.T) {
+	const historySecret = "history-secret"
+	tokenStore := auth.NewTokenStore(map[string]auth.Token{
+		auth.HashToken(historySecret): {Label: "historian", Paths: []string{"/docs/**"}, Operations: []string{"versions"}},
+		auth.HashToken("publisher"):   {Label: "publisher", Paths: []string{"/**"}, Operations: []string{"publish"}},
+	})
+	dir, s := setupVersionedDir(t, map[string]string{
+		"docs/page.md": "# Page\n",
+		"blog/post.md": "# Post\n",
+	})
+	for _, p := range []string{"/docs/page.md", "/blog/post.md"} {
+		if _, err := s.Write(p, []byte("# Updated\n"), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := &Handler{
+		ContentDir:    dir,
+		Store:         s,
+		GetTokenStore: func() *auth.TokenStore { return tokenStore },
+		Logger:        discardLogger,
+	}
+
+	withToken := func(req, token string) string {
+		return req + "\n---\nauth: " + token + "\n---\n"
+	}
+	tests := []struct {
+		name       string
+		request    string
+		wantStatus string
+	}{
+		{"current content stays public", "FETCH /docs/page.md\n", protocol.StatusOK},
+		{"VERSIONS without token", "VERSIONS /docs/page.md\n", protocol.StatusUnauthorized},
+		{"pinned FETCH without token", "FETCH /docs/page.md/v1\n", protocol.StatusUnauthorized},
+		{"VERSIONS with versions token", withToken("VERSIONS /docs/page.md", historySecret), protocol.StatusOK},
+		{"pinned FETCH with versions token", withToken("FETCH /docs/page.md/v1", historySecret), protocol.StatusOK},
+		{"VERSIONS with another token", withToken("VERSIONS /docs/page.md", "publisher"), protocol.StatusNotPermitted},
+		{"uncovered history stays public", "VERSIONS /blog/post.md\n", protocol.StatusOK},
+		{"uncovered pinned FETCH stays public", "FETCH /blog/post.md/v1\n", protocol.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := newMockStream(tt.request)
+			h.HandleStream(stream)
+			resp, err := protocol.ParseResponse(&stream.output)
+			if err != nil {
+				t.Fatalf("parse response: %v", err)
+			}
+			if resp.Status != tt.wantStatus {
+				t.Errorf("status: got %q, want %q", resp.Status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestTrailers(t *testing.T) {
+	dir, s := setupVersionedDir(t, map[string]string{
+		"page.md": "# Title\n\n---\n\nAfter a break.\n",
+	})
+	h := &Handler{ContentDir: dir, Store: s, Logger: discardLogger}
+	fetch := func(req string) protocol.Response {
+		t.Helper()
+		stream := newMockStream(req)
+		h.HandleStream(stream)
+		resp, err := protocol.ParseResponse(&stream.output)
+		if err != nil {
+			t.Fatalf("parse response: %v", err)
+		}
+		return resp
+	}
+
+	plain := fetch("FETCH /page.md\n")
+	if plain.Trailer != nil || plain.Metadata["trailer"] != "" {
+		t.Fatalf("trailer sent without being asked for: %+v", plain)
+	}
+
+	for _, req := range []string{
+		"FETCH /page.md\n---\naccept-trailers: true\n---\n",
+		"FETCH /missing.md\n---\naccept-trailers: true\n---\n",
+		"LIST /\n---\naccept-trailers: true\n---\n",
+	} {
+		resp := fetch(req)
+		if resp.Trailer[protocol.TrailerBodyHash] == "" || resp.Trailer[protocol.TrailerElapsed] == "" {
+			t.Errorf("%q: trailer = %v", req, resp.Trailer)
+		}
+		if err := resp.VerifyTrailer(); err != nil {
+			t.Errorf("%q: %v", req, err)
+		}
+	}
+	if resp := fetch("FETCH /page.md\n---\naccept-trailers: true\n---\n"); resp.Body != plain.Body {
+		t.Errorf("body with trailer = %q, want %q", resp.Body, plain.Body)
+	}
+}
+
+func TestContentEncoding(t *testing.T) {
+	large := "# Large\n\n" + strings.Repeat("Markdown compresses well when it repeats itself.\n", 100)
+	dir, s := setupVersionedDir(t, map[string]string{"large.md": large, "small.md": "# Small\n"})
+	h := &Handler{ContentDir: dir, Store: s, Logger: discardLogger}
+	fetch := func(req string) protocol.Response {
+		t.Helper()
+		stream := newMockStream(req)
+		h.HandleStream(stream)
+		resp, err := protocol.ParseResponse(&stream.output)
+		if err != nil {
+			t.Fatalf("parse response: %v", err)
+		}
+		return resp
+	}
+
+	plain := fetch("FETCH /large.md\n")
+	if plain.Metadata["content-encoding"] != "" || plain.Body != large {
+		t.Fatalf("compressed without being asked: %v", plain.Metadata)
+	}
+
+	resp := fetch("FETCH /large.md\n---\naccept-encoding: zstd, gzip\naccept-trailers: true\n---\n")
+	if resp.Metadata["content-encoding"] != protocol.EncodingGzip || len(resp.Body) >= len(large) {
+		t.Fatalf("content-encoding = %q, %d bytes", resp.Metadata["content-encoding"], len(resp.Body))
+	}
+	if err := resp.VerifyTrailer(); err != nil {
+		t.Errorf("trailer over the encoded body: %v", err)
+	}
+	decoded, err := resp.Decode()
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if decoded.Body != large || decoded.Metadata["etag"] != plain.Metadata["etag"] || decoded.Metadata["content-hash"] != plain.Metadata["content-hash"] {
+		t.Errorf("decoded response differs from the plain one: %v", decoded.Metadata)
+	}
+
+	if resp := fetch("FETCH /small.md\n---\naccept-encoding: gzip\n---\n"); resp.Metadata["content-encoding"] != "" {
+		t.Errorf("small body compressed: %v", resp.Metadata)
+	}
+	if resp := fetch("FETCH /large.md\n---\naccept-encoding: br\n---\n"); resp.Metadata["content-encoding"] != "" {
+		t.Errorf("unsupported coding used: %v", resp.Metadata)
+	}
+}
+
+func TestAliases(t *testing.T) {
+	const secret, scoped = "alias-secret", "alias-scoped"
+	ts := auth.NewTokenStore(map[string]auth.Token{
+		auth.HashToken(secret): {Paths: []string{"/**"}, Operations: []string{"publish"}},
+		auth.HashToken(scoped): {Paths: []string{"/team/**"}, Operations: []string{"publish"}},
+	})
+	dir, s := setupVersionedDir(t, map[string]string{
+		"old.md":    "# Old home\n",
+		"kept.md":   "# Kept\n",
+		"other.md":  "# Other\n",
+		"team/a.md": "# A\n",
+	})
+	h := &Handler{ContentDir: dir, Store: s, Logger: discardLogger, GetTokenStore: func() *auth.TokenStore { return ts }}
+	send := func(req string) protocol.Response {
+		t.Helper()
+		stream := newMockStream(req)
+		h.HandleStream(stream)
+		resp, err := protocol.ParseResponse(&stream.output)
+		if err != nil {
+			t.Fatalf("parse response: %v", err)
+		}
+		return resp
+	}
+
+	// Move /old.md to /guide/new.md, keeping /gone.md and /kept.md as
+	// aliases; /kept.md still exists, so it is served as itself.
+	if resp := send("PUBLISH /guide/new.md\n---\nauth: " + secret + "\naliases: '[/old.md, /gone.md, /kept.md]'\n---\n# New home\n"); resp.Status != protocol.StatusCreated {
+		t.Fatalf("publish: %q %s", resp.Status, resp.Body)
+	}
+	if resp := send("ARCHIVE /old.md\n---\nauth: " + secret + "\n---\n"); resp.Status != protocol.StatusOK {
+		t.Fatalf("archive: %q %s", resp.Status, resp.Body)
+	}
+
+	for req, want := range map[string]string{
+		"FETCH /old.md\n":     "/guide/new.md",
+		"FETCH /gone.md\n":    "/guide/new.md",
+		"FETCH /gone.md/v1\n": "/guide/new.md/v1",
+		"VERSIONS /gone.md\n": "/guide/new.md",
+	} {
+		if resp := send(req); resp.Status != protocol.StatusMoved || resp.Metadata["location"] != want {
+			t.Errorf("%q: got %q %v, want moved to %s", req, resp.Status, resp.Metadata, want)
+		}
+	}
+	if resp := send("FETCH /kept.md\n"); resp.Status != protocol.StatusOK || !strings.Contains(resp.Body, "# Kept") {
+		t.Errorf("existing document behind an alias: %q %s", resp.Status, resp.Body)
+	}
+
+	list := send("LIST /\n")
+	if strings.Contains(list.Body, "old.md") || !strings.Contains(list.Body, "kept.md") {
+		t.Errorf("listing:\n%s", list.Body)
+	}
+
+	// Dropping the aliases ends the redirects.
+	if resp := send("PUBLISH /guide/new.md\n---\nauth: " + secret + "\n---\n# New home, again\n"); resp.Status != protocol.StatusCreated {
+		t.Fatalf("republish: %q %s", resp.Status, resp.Body)
+	}
+	if resp := send("FETCH /gone.md\n"); resp.Status != protocol.StatusNotFound {
+		t.Errorf("alias after removal: %q", resp.Status)
+	}
+
+	// Aliases must be paths the publisher could write to.
+	for req, want := range map[string]string{
+		"PUBLISH /team/b.md\n---\nauth: " + scoped + "\naliases: /other.md\n---\n# B\n":                  protocol.StatusNotPermitted,
+		"PUBLISH /team/b.md\n---\nauth: " + scoped + "\naliases: team/old.md\n---\n# B\n":                protocol.StatusBadRequest,
+		"PUBLISH /team/b.md\n---\nauth: " + scoped + "\naliases: /team/old.md\n---\n# B\n":               protocol.StatusCreated,
+		"APPEND /team/a.md\n---\nauth: " + scoped + "\nexpected-version: 1\naliases: /x.md\n---\nMore\n": protocol.StatusNotPermitted,
+	} {
+		if resp := send(req); resp.Status != want {
+			t.Errorf("%q: got %q, want %q\n%s", req, resp.Status, want, resp.Body)
+		}
+	}
+
+	// The aliases are found again when the index is rebuilt at startup.
+	if err := s.BuildHashIndex(); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := s.ResolveAlias("/team/old.md"); !ok || got != "/team/b.md" {
+		t.Errorf("after rebuild: ResolveAlias = %q, %v", got, ok)
+	}
+}
+
+func TestDisposition(t *testing.T) {
+	dir, s := setupVersionedDir(t, map[string]string{"page.md": "# Page\n"})
+	for name, contentType := range map[string]string{
+		"/notes.txt":  "text/plain; charset=utf-8",
+		"/raw.html":   "text/html",
+		"/tool.sh":    "application/x-sh",
+		"/upper.md":   "Text/Markdown",
+		"/report.pdf": "application/pdf",
+	} {
+		if _, err := s.Write(name, []byte("<script>alert(1)</script>\n"), map[string]string{"content-type": contentType}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := &Handler{ContentDir: dir, Store: s, Logger: discardLogger}
+	fetch := func(req string) protocol.Response {
+		t.Helper()
+		stream := newMockStream(req)
+		h.HandleStream(stream)
+		resp, err := protocol.ParseResponse(&stream.output)
+		if err != nil {
+			t.Fatalf("parse response: %v", err)
+		}
+		return resp
+	}
+
+	for req, want := range map[string]string{
+		"FETCH /page.md\n":       "",
+		"FETCH /notes.txt\n":     "",
+		"FETCH /upper.md\n":      "",
+		"FETCH /raw.html\n":      "attachment",
+		"FETCH /tool.sh\n":       "attachment",
+		"FETCH /report.pdf/v1\n": "attachment",
+		// Rendered HTML is the server's own, sanitized output.
+		"FETCH /raw.html\n---\naccept: text/html\n---\n": "",
+	} {
+		resp := fetch(req)
+		if resp.Status != protocol.StatusOK {
+			t.Fatalf("%q: status %q", req, resp.Status)
+		}
+		if got := resp.Metadata["disposition"]; got != want {
+			t.Errorf("%q: disposition %q, want %q", req, got, want)
+		}
+	}
+}
+
+func TestBinaryAssets(t *testing.T) {
+	const secret = "asset-secret"
+	const png = "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\xff\xfe# not a heading\n"
+	ts := auth.NewTokenStore(map[string]auth.Token{
+		auth.HashToken(secret): {Paths: []string{"/**"}, Operations: []string{"publish"}},
+	})
+	dir, s := setupVersionedDir(t, map[string]string{"page.md": "# Page\n\n![diagram](img/raw.png)\n"})
+	h := &Handler{ContentDir: dir, Store: s, Logger: discardLogger, GetTokenStore: func() *auth.TokenStore { return ts }}
+	send := func(req string) protocol.Response {
+		t.Helper()
+		stream := newMockStream(req)
+		h.HandleStream(stream)
+		resp, err := protocol.ParseResponse(&stream.output)
+		if err != nil {
+			t.Fatalf("parse response: %v", err)
+		}
+		return resp
+	}
+	encoded, err := protocol.EncodeBody(png, protocol.EncodingBase64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp := send("PUBLISH /img/raw.png\n---\nauth: " + secret + "\ncontent-type: image/png\n---\n" + png); resp.Status != protocol.StatusCreated {
+		t.Fatalf("raw publish: %s %q", resp.Status, resp.Body)
+	}
+	if resp := send("PUBLISH /img/b64.png\n---\nauth: " + secret + "\ncontent-type: image/png\nbody-encoding: base64\n---\n" + encoded); resp.Status != protocol.StatusCreated {
+		t.Fatalf("base64 publish: %s %q", resp.Status, resp.Body)
+	}
+	if resp := send("PUBLISH /img/bad.png\n---\nauth: " + secret + "\nbody-encoding: base64\n---\nnot base64!"); resp.Status != protocol.StatusBadRequest {
+		t.Errorf("malformed base64 publish: %s", resp.Status)
+	}
+	if resp := send("PUBLISH /img/bad.png\n---\nauth: " + secret + "\nbody-encoding: uuencode\n---\n" + encoded); resp.Status != protocol.StatusBadRequest {
+		t.Errorf("unknown body-encoding: %s", resp.Status)
+	}
+
+	for _, p := range []string{"/img/raw.png", "/img/b64.png"} {
+		resp := send("FETCH " + p + "\n")
+		if resp.Status != protocol.StatusOK || resp.Body != png {
+			t.Fatalf("FETCH %s: %s %q", p, resp.Status, resp.Body)
+		}
+		if resp.Metadata["content-type"] != "image/png" || resp.Metadata["disposition"] != "attachment" {
+			t.Errorf("FETCH %s: metadata %v", p, resp.Metadata)
+		}
+		if _, ok := resp.Metadata["body-encoding"]; ok {
+			t.Errorf("FETCH %s: control key stored: %v", p, resp.Metadata)
+		}
+	}
+
+	resp := send("FETCH /img/raw.png\n---\naccept-encoding: base64\naccept-trailers: true\n---\n")
+	if resp.Metadata["content-encoding"] != protocol.EncodingBase64 || resp.Body != encoded {
+		t.Fatalf("base64 fetch: %v %q", resp.Metadata, resp.Body)
+	}
+	if err := resp.VerifyTrailer(); err != nil {
+		t.Errorf("trailer over the encoded body: %v", err)
+	}
+	if decoded, err := resp.Decode(); err != nil || decoded.Body != png {
+		t.Errorf("Decode = %q, %v", decoded.Body, err)
+	}
+
+	// Ranges count the asset's bytes, before encoding.
+	resp = send("FETCH /img/raw.png\n---\nrange: bytes=0-7\naccept-encoding: base64\n---\n")
+	if decoded, err := resp.Decode(); err != nil || resp.Status != protocol.StatusPartial || decoded.Body != png[:8] {
+		t.Errorf("base64 range: %s %q, %v", resp.Status, decoded.Body, err)
+	}
+
+	// Assets are not rendered as markdown for HTML clients.
+	resp = send("FETCH /img/raw.png\n---\naccept: text/html\n---\n")
+	if resp.Body != png || resp.Metadata["content-type"] != "image/png" {
+		t.Errorf("asset rendered to HTML: %v %q", resp.Metadata, resp.Body)
+	}
+	if resp := send("INFO /img/raw.png\n"); resp.Metadata["content-length"] != fmt.Sprint(len(png)) {
+		t.Errorf("INFO size = %q", resp.Metadata["content-length"])
+	}
+}
+
+func TestSearch(t *testing.T) {
+	const readSecret = "read-secret"
+	tokenStore := auth.NewTokenStore(map[string]auth.Token{
+		auth.HashToken(readSecret): {Label: "reader", Paths: []string{"/docs/private/**"}, Operations: []string{"read"}},
+	})
+	dir, s := setupVersionedDir(t, map[string]string{
+		"docs/chains.md":         "# Hash chain\n\nEvery version links to the one before.\n",
+		"docs/guide.md":          "# Guide\n\nThe server keeps a hash chain of versions.\n",
+		"docs/notes.secret.md":   "# Notes\n\nA hash chain secret.\n",
+		"docs/private/keys.md":   "# Keys\n\nThe hash chain of keys.\n",
+		"other/chain-outside.md": "# Elsewhere\n\nA hash chain outside docs.\n",
+	})
+	h := &Handler{
+		ContentDir:    dir,
+		Store:         s,
+		Logger:        discardLogger,
+		DenyPaths:     []string{"*.secret.md"},
+		GetTokenStore: func() *auth.TokenStore { return tokenStore },
+	}
+	search := func(req string) protocol.Response {
+		t.Helper()
+		stream := newMockStream(req)
+		h.HandleStream(stream)
+		resp, err := protocol.ParseResponse(&stream.output)
+		if err != nil {
+			t.Fatalf("parse response: %v", err)
+		}
+		return resp
+	}
+
+	t.Run("ranked results", func(t *testing.T) {
+		resp := search("SEARCH /docs/\n---\nquery: Hash chain\n---\n")
+		if resp.Status != protocol.StatusOK {
+			t.Fatalf("status %q: %s", resp.Status, resp.Body)
+		}
+		if resp.Metadata["results"] != "2" {
+			t.Errorf("results = %q, want 2\n%s", resp.Metadata["results"], resp.Body)
+		}
+		// The title match ranks first; denied, read-protected and
+		// out-of-directory documents are left out.
+		first := strings.Index(resp.Body, "- [Hash chain](/docs/chains.md)")
+		second := strings.Index(resp.Body, "- [Guide](/docs/guide.md)\n  The server keeps a hash chain of versions.\n")
+		if first < 0 || second < first {
+			t.Errorf("unexpected ranking:\n%s", resp.Body)
+		}
+		for _, hidden := range []string{"secret", "keys", "outside"} {
+			if strings.Contains(resp.Body, hidden) {
+				t.Errorf("results reveal %q:\n%s", hidden, resp.Body)
+			}
+		}
+	})
+
+	t.Run("token reveals protected documents", func(t *testing.T) {
+		resp := search("SEARCH /docs/\n---\nauth: " + readSecret + "\nquery: keys\n---\n")
+		if resp.Metadata["results"] != "1" || !strings.Contains(resp.Body, "/docs/private/keys.md") {
+			t.Errorf("got %q:\n%s", resp.Metadata["results"], resp.Body)
+		}
+	})
+
+	t.Run("publish and archive update the index", func(t *testing.T) {
+		if _, err := s.Write("/docs/guide.md", []byte("# Guide\n\nNow about zebras.\n"), nil); err != nil {
+			t.Fatal(err)
+		}
+		if resp := search("SEARCH /docs/\n---\nquery: zebras\n---\n"); resp.Metadata["results"] != "1" {
+			t.Errorf("after publish: %q\n%s", resp.Metadata["results"], resp.Body)
+		}
+		if resp := search("SEARCH /docs/\n---\nquery: keeps\n---\n"); resp.Metadata["results"] != "0" {
+			t.Errorf("old content still found:\n%s", resp.Body)
+		}
+		if err := s.Archive("/docs/guide.md", true); err != nil {
+			t.Fatal(err)
+		}
+		if resp := search("SEARCH /docs/\n---\nquery: zebras\n---\n"); resp.Metadata["results"] != "0" {
+			t.Errorf("archived document found:\n%s", resp.Body)
+		}
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		resp := search("SEARCH /\n---\nlimit: 1\nquery: chain\n---\n")
+		if resp.Metadata["results"] != "1" {
+			t.Errorf("results = %q, want 1", resp.Metadata["results"])
+		}
+	})
+
+	for name, tc := range map[string]struct{ req, status string }{
+		"missing query":   {"SEARCH /docs/\n", protocol.StatusBadRequest},
+		"bad limit":       {"SEARCH /docs/\n---\nlimit: 0\nquery: chain\n---\n", protocol.StatusBadRequest},
+		"limit too large": {"SEARCH /docs/\n---\nlimit: 1000\nquery: chain\n---\n", protocol.StatusBadRequest},
+		"missing dir":     {"SEARCH /nope/\n---\nquery: chain\n---\n", protocol.StatusNotFound},
+		"document path":   {"SEARCH /docs/guide.md\n---\nquery: chain\n---\n", protocol.StatusNotFound},
+		"protected dir":   {"SEARCH /docs/private/\n---\nquery: keys\n---\n", protocol.StatusUnauthorized},
+	} {
+		if resp := search(tc.req); resp.Status != tc.status {
+			t.Errorf("%s: status %q, want %q", name, resp.Status, tc.status)
+		}
+	}
+}
+
+func TestBacklinks(t *testing.T) {
+	const readSecret = "read-secret"
+	tokenStore := auth.NewTokenStore(map[string]auth.Token{
+		auth.HashToken(readSecret): {Label: "reader", Paths: []string{"/private/**"}, Operations: []string{"read"}},
+	})
+	dir, s := setupVersionedDir(t, map[string]string{
+		"index.md":          "# Home\n\n[Setup](guide/setup.md) and the [guide](guide/).\n",
+		"guide/index.md":    "# Guide\n",
+		"guide/setup.md":    "# Setup\n",
+		"notes.secret.md":   "# Notes\n\n[Setup](/guide/setup.md)\n",
+		"private/plan.md":   "# Plan\n\n[Setup](/guide/setup.md)\n",
+		"private/secret.md": "# Secret\n",
+	})
+	h := &Handler{
+		ContentDir:    dir,
+		Store:         s,
+		Logger:        discardLogger,
+		DenyPaths:     []string{"*.secret.md"},
+		GetTokenStore: func() *auth.TokenStore { return tokenStore },
+	}
+	backlinks := func(req string) protocol.Response {
+		t.Helper()
+		stream := newMockStream(req)
+		h.HandleStream(stream)
+		resp, err := protocol.ParseResponse(&stream.output)
+		if err != nil {
+			t.Fatalf("parse response: %v", err)
+		}
+		return resp
+	}
+
+	t.Run("visible documents only", func(t *testing.T) {
+		resp := backlinks("BACKLINKS /guide/setup.md\n")
+		if resp.Status != protocol.StatusOK || resp.Metadata["results"] != "1" {
+			t.Fatalf("status %q, results %q:\n%s", resp.Status, resp.Metadata["results"], resp.Body)
+		}
+		if !strings.Contains(resp.Body, "# Links to /guide/setup.md\n") || !strings.Contains(resp.Body, "- [Home](/index.md)\n") {
+			t.Errorf("body:\n%s", resp.Body)
+		}
+		for _, hidden := range []string{"Notes", "Plan"} {
+			if strings.Contains(resp.Body, hidden) {
+				t.Errorf("results reveal %q:\n%s", hidden, resp.Body)
+			}
+		}
+	})
+
+	t.Run("token reveals protected documents", func(t *testing.T) {
+		resp := backlinks("BACKLINKS /guide/setup.md\n---\nauth: " + readSecret + "\n---\n")
+		if resp.Metadata["results"] != "2" || !strings.Contains(resp.Body, "/private/plan.md") {
+			t.Errorf("got %q:\n%s", resp.Metadata["results"], resp.Body)
+		}
+	})
+
+	t.Run("directory stands for its index", func(t *testing.T) {
+		resp := backlinks("BACKLINKS /guide/\n")
+		if resp.Metadata["results"] != "1" || !strings.Contains(resp.Body, "# Links to /guide/index.md\n") {
+			t.Errorf("got %q:\n%s", resp.Metadata["results"], resp.Body)
+		}
+	})
+
+	t.Run("missing document", func(t *testing.T) {
+		resp := backlinks("BACKLINKS /guide/missing.md\n")
+		if resp.Status != protocol.StatusOK || resp.Metadata["results"] != "0" || !strings.Contains(resp.Body, "No documents link here.") {
+			t.Errorf("status %q:\n%s", resp.Status, resp.Body)
+		}
+	})
+
+	for name, tc := range map[string]struct{ req, status string }{
+		"version path":   {"BACKLINKS /guide/setup.md/v1\n", protocol.StatusBadRequest},
+		"denied path":    {"BACKLINKS /notes.secret.md\n", protocol.StatusNotFound},
+		"protected path": {"BACKLINKS /private/secret.md\n", protocol.StatusUnauthorized},
+	} {
+		if resp := backlinks(tc.req); resp.Status != tc.status {
+			t.Errorf("%s: status %q, want %q", name, resp.Status, tc.status)
+		}
+	}
+}
+
+func TestFeed(t *testing.T) {
+	dir, s := setupVersionedDir(t, nil)
+	for _, w := range []struct{ path, body string }{
+		{"/a.md", "# Alpha\n"},
+		{"/docs/b.md", "# Beta\n"},
+		{"/a.md", "# Alpha\n\nMore.\n"},
+		{"/notes.secret.md", "# Notes\n"},
+	} {
+		if _, err := s.Write(w.path, []byte(w.body), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := &Handler{ContentDir: dir, Store: s, Logger: discardLogger, DenyPaths: []string{"*.secret.md"}}
+	feed := func(req string) protocol.Response {
+		t.Helper()
+		stream := newMockStream(req)
+		h.HandleStream(stream)
+		resp, err := protocol.ParseResponse(&stream.output)
+		if err != nil {
+			t.Fatalf("parse response: %v", err)
+		}
+		return resp
+	}
+
+	resp := feed("FETCH /.well-known/feed.md\n")
+	if resp.Status != protocol.StatusOK || resp.Metadata["results"] != "3" || resp.Metadata["etag"] == "" || resp.Metadata["modified"] == "" {
+		t.Fatalf("status %q, metadata %v:\n%s", resp.Status, resp.Metadata, resp.Body)
+	}
+	a2 := strings.Index(resp.Body, "- [Alpha](/a.md/v2) - ")
+	b1 := strings.Index(resp.Body, "- [Beta](/docs/b.md/v1) - ")
+	a1 := strings.Index(resp.Body, "- [Alpha](/a.md/v1) - ")
+	if a2 < 0 || b1 < a2 || a1 < b1 {
+		t.Errorf("entries missing or not newest first:\n%s", resp.Body)
+	}
+	if strings.Contains(resp.Body, "Notes") {
+		t.Errorf("denied document listed:\n%s", resp.Body)
+	}
+
+	if got := feed("FETCH /.well-known/feed.md\n---\nif-none-match: " + resp.Metadata["etag"] + "\n---\n"); got.Status != protocol.StatusNotModified {
+		t.Errorf("conditional fetch: status %q", got.Status)
+	}
+
+	if err := s.Archive("/docs/b.md", true); err != nil {
+		t.Fatal(err)
+	}
+	if resp := feed("FETCH /.well-known/feed.md\n"); resp.Metadata["results"] != "2" || strings.Contains(resp.Body, "Beta") {
+		t.Errorf("archived document listed: %q\n%s", resp.Metadata["results"], resp.Body)
+	}
+
+	// After a restart the feed starts from the current versions.
+	h.Store = store.New(dir)
+	if err := h.Store.BuildHashIndex(); err != nil {
+		t.Fatal(err)
+	}
+	if resp := feed("FETCH /.well-known/feed.md\n"); resp.Metadata["results"] != "1" || !strings.Contains(resp.Body, "(/a.md/v2)") {
+		t.Errorf("after restart: %q\n%s", resp.Metadata["results"], resp.Body)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	const historySecret = "history-secret"
+	tokenStore := auth.NewTokenStore(map[string]auth.Token{
+		auth.HashToken(historySecret): {Label: "historian", Paths: []string{"/private/**"}, Operations: []string{"versions"}},
+	})
+	dir, s := setupVersionedDir(t, map[string]string{
+		"doc.md":          "# Doc\n\nfirst\n",
+		"notes.secret.md": "# Secret\n",
+		"private/doc.md":  "# Private\n",
+		"new/home.md":     "# Home\n",
+		"big.md":          strings.Repeat("line\n", MaxDiffChanges+1),
+	})
+	for _, w := range []struct{ path, body string }{
+		{"/doc.md", "# Doc\n\nfirst\nsecond\n"},
+		{"/doc.md", "# Doc\n\n```go\ncode\n```\nsecond\n"},
+		{"/private/doc.md", "# Private\n\nmore\n"},
+		{"/big.md", strings.Repeat("other\n", MaxDiffChanges+1)},
+	} {
+		if _, err := s.Write(w.path, []byte(w.body), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.Write("/new/home.md", []byte("# Home\n"), map[string]string{"aliases": "/old/home.md"}); err != nil {
+		t.Fatal(err)
+	}
+	h := &Handler{
+		ContentDir:    dir,
+		Store:         s,
+		Logger:        discardLogger,
+		DenyPaths:     []string{"*.secret.md"},
+		GetTokenStore: func() *auth.TokenStore { return tokenStore },
+	}
+	diff := func(req string) protocol.Response {
+		t.Helper()
+		stream := newMockStream(req)
+		h.HandleStream(stream)
+		resp, err := protocol.ParseResponse(&stream.output)
+		if err != nil {
+			t.Fatalf("parse response: %v", err)
+		}
+		return resp
+	}
+
+	resp := diff("DIFF /doc.md/v1..v3\n")
+	if resp.Status != protocol.StatusOK {
+		t.Fatalf("status %q: %s", resp.Status, resp.Body)
+	}
+	// The fence outgrows the backticks in the document.
+	want := "\n# Changes to /doc.md: v1 → v3\n\n````diff\n@@ -1,3 +1,6 @@\n # Doc\n \n-first\n+```go\n+code\n+```\n+second\n````\n"
+	if resp.Body != want {
+		t.Errorf("body =\n%s\nwant\n%s", resp.Body, want)
+	}
+	if resp.Metadata["from-version"] != "1" || resp.Metadata["to-version"] != "3" {
+		t.Errorf("metadata = %v", resp.Metadata)
+	}
+	if resp := diff("DIFF /doc.md/v3..v2\n"); !strings.Contains(resp.Body, "-```go\n") {
+		t.Errorf("reverse diff:\n%s", resp.Body)
+	}
+	if resp := diff("DIFF /doc.md/v2..v2\n"); !strings.Contains(resp.Body, "No changes.") {
+		t.Errorf("same version:\n%s", resp.Body)
+	}
+	if resp := diff("DIFF /old/home.md/v1..v1\n"); resp.Status != protocol.StatusMoved || resp.Metadata["location"] != "/new/home.md/v1..v1" {
+		t.Errorf("alias: %q %v", resp.Status, resp.Metadata)
+	}
+
+	for name, tc := range map[string]struct{ req, status string }{
+		"no range":          {"DIFF /doc.md\n", protocol.StatusBadRequest},
+		"single version":    {"DIFF /doc.md/v2\n", protocol.StatusBadRequest},
+		"bad version":       {"DIFF /doc.md/v0..v2\n", protocol.StatusBadRequest},
+		"missing version":   {"DIFF /doc.md/v1..v9\n", protocol.StatusNotFound},
+		"missing document":  {"DIFF /nope.md/v1..v2\n", protocol.StatusNotFound},
+		"denied document":   {"DIFF /notes.secret.md/v1..v1\n", protocol.StatusNotFound},
+		"protected history": {"DIFF /private/doc.md/v1..v2\n", protocol.StatusUnauthorized},
+		"with token":        {"DIFF /private/doc.md/v1..v2\n---\nauth: " + historySecret + "\n---\n", protocol.StatusOK},
+		"too many changes":  {"DIFF /big.md/v1..v2\n", protocol.StatusTooLarge},
+	} {
+		if resp := diff(tc.req); resp.Status != tc.status {
+			t.Errorf("%s: status %q, want %q", name, resp.Status, tc.status)
+		}
+	}
+}
+
+func TestTokenInfo(t *testing.T) {
+	tokensFile := filepath.Join(t.TempDir(), "tokens.toml")
+	toml := fmt.Sprintf(`[tokens.laptop]
+hash = %q
+paths = ["/docs/**", "/blog/*"]
+operations = ["publish", "read"]
+
+[tokens.temp]
+hash = %q
+paths = ["/**"]
+operations = ["read"]
+expires = "2099-01-01T00:00:00Z"
+
+[tokens.old]
+hash = %q
+paths = ["/**"]
+operations = ["publish"]
+expires = "2001-01-01T00:00:00Z"
+`, auth.HashToken("live"), auth.HashToken("temp"), auth.HashToken("old"))
+	if err := os.WriteFile(tokensFile, []byte(toml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tokenStore, err := auth.LoadTokens(tokensFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, s := setupVersionedDir(t, nil)
+	invalid := 0
+	h := &Handler{
+		ContentDir:    dir,
+		Store:         s,
+		Logger:        discardLogger,
+		GetTokenStore: func() *auth.TokenStore { return tokenStore },
+		InvalidToken:  func() { invalid++ },
+	}
+	info := func(token string) protocol.Response {
+		t.Helper()
+		req := "FETCH /.well-known/token\n"
+		if token != "" {
+			req = "FETCH /.well-known/token\n---\nauth: " + token + "\n---\n"
+		}
+		stream := newMockStream(req)
+		h.HandleStream(stream)
+		resp, err := protocol.ParseResponse(&stream.output)
+		if err != nil {
+			t.Fatalf("parse response: %v", err)
+		}
+		return resp
+	}
+
+	resp := info("live")
+	if resp.Status != protocol.StatusOK {
+		t.Fatalf("status %q: %s", resp.Status, resp.Body)
+	}
+	want := map[string]string{"token-label": "laptop", "token-operations": "publish,read", "token-paths": "/docs/**,/blog/*"}
+	for k, v := range want {
+		if resp.Metadata[k] != v {
+			t.Errorf("%s = %q, want %q", k, resp.Metadata[k], v)
+		}
+	}
+	if _, ok := resp.Metadata["token-expires"]; ok || !strings.Contains(resp.Body, "- Expires: never") {
+		t.Errorf("token without expiry: %v\n%s", resp.Metadata, resp.Body)
+	}
+
+	if resp := info("temp"); resp.Metadata["token-expires"] != "2099-01-01T00:00:00Z" {
+		t.Errorf("expiring token: %v", resp.Metadata)
+	}
+	resp = info("old")
+	if resp.Status != protocol.StatusUnauthorized || resp.Metadata["token-expires"] != "2001-01-01T00:00:00Z" || resp.Metadata["token-label"] != "" {
+		t.Errorf("expired token: %q %v", resp.Status, resp.Metadata)
+	}
+
+	if resp := info(""); resp.Status != protocol.StatusUnauthorized {
+		t.Errorf("no token: %q", resp.Status)
+	}
+	if resp := info("guess"); resp.Status != protocol.StatusUnauthorized || invalid != 1 {
+		t.Errorf("unknown token: %q, invalid-token hook called %d times", resp.Status, invalid)
+	}
+
+	h.GetTokenStore = nil
+	if resp := info("live"); resp.Status != protocol.StatusNotPermitted {
+		t.Errorf("no token store: %q", resp.Status)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	ts := auth.NewTokenStore(map[string]auth.Token{
+		auth.HashToken("writer"):  {Paths: []string{"/*"}, Operations: []string{"publish"}},
+		auth.HashToken("janitor"): {Paths: []string{"/*"}, Operations: []string{"delete"}},
+	})
+	dir, s := setupVersionedDir(t, map[string]string{"doc.md": "# One\n"})
+	if _, err := s.Write("/doc.md", []byte("# Two\n"), nil); err != nil {
+		t.Fatal(err)
+	}
+	h := &Handler{ContentDir: dir, Store: s, Logger: discardLogger, GetTokenStore: func() *auth.TokenStore { return ts }}
+	do := func(req string) protocol.Response {
+		t.Helper()
+		stream := newMockStream(req)
+		h.HandleStream(stream)
+		resp, err := protocol.ParseResponse(&stream.output)
+		if err != nil {
+			t.Fatalf("parse response: %v", err)
+		}
+		return resp
+	}
+
+	if resp := do("PURGE /doc.md\n"); resp.Status != protocol.StatusUnauthorized {
+		t.Errorf("no token: status %q, want %q", resp.Status, protocol.StatusUnauthorized)
+	}
+	if resp := do("PURGE /doc.md\n---\nauth: writer\n---\n"); resp.Status != protocol.StatusNotPermitted {
+		t.Errorf("publish token: status %q, want %q", resp.Status, protocol.StatusNotPermitted)
+	}
+	if resp := do("PURGE /missing.md\n---\nauth: janitor\n---\n"); resp.Status != protocol.StatusNotFound {
+		t.Errorf("missing: status %q, want %q", resp.Status, protocol.StatusNotFound)
+	}
+
+	resp := do("PURGE /doc.md\n---\nauth: janitor\n---\n")
+	if resp.Status != protocol.StatusOK {
+		t.Fatalf("purge: status %q: %s", resp.Status, resp.Body)
+	}
+	if resp.Metadata["version"] != "2" || resp.Metadata["purged-versions"] != "2" || resp.Metadata["purged"] == "" {
+		t.Errorf("purge metadata = %v", resp.Metadata)
+	}
+
+	if resp := do("FETCH /doc.md\n"); resp.Status != protocol.StatusNotFound {
+		t.Errorf("fetch after purge: status %q, want %q", resp.Status, protocol.StatusNotFound)
+	}
+	resp = do("VERSIONS /doc.md\n")
+	if resp.Status != protocol.StatusNotFound || resp.Metadata["purged-versions"] != "2" || !strings.Contains(resp.Body, "deleted at") {
+		t.Errorf("versions after purge: %q %v\n%s", resp.Status, resp.Metadata, resp.Body)
+	}
+
+	if _, err := s.Write("/doc.md", []byte("# Fresh\n"), nil); err != nil {
+		t.Fatal(err)
+	}
+	resp = do("VERSIONS /doc.md\n")
+	if resp.Status != protocol.StatusOK || resp.Metadata["total"] != "1" || resp.Metadata["purged-versions"] != "2" {
+		t.Errorf("versions after republish: %q %v\n%s", resp.Status, resp.Metadata, resp.Body)
+	}
+}
+
+func TestMove(t *testing.T) {
+	ts := auth.NewTokenStore(map[string]auth.Token{
+		auth.HashToken("writer"): {Paths: []string{"/**"}, Operations: []string{"publish"}},
+		auth.HashToken("drafts"): {Paths: []string{"/drafts/*"}, Operations: []string{"publish"}},
+	})
+	dir, s := setupVersionedDir(t, map[string]string{"drafts/post.md": "# Post\n", "taken.md": "# Taken\n"})
+	if _, err := s.Write("/drafts/post.md", []byte("# Post\n\nEdited.\n"), nil); err != nil {
+		t.Fatal(err)
+	}
+	h := &Handler{ContentDir: dir, Store: s, Logger: discardLogger, GetTokenStore: func() *auth.TokenStore { return ts }, DenyPaths: []string{"/private/**"}}
+	do := func(req string) protocol.Response {
+		t.Helper()
+		stream := newMockStream(req)
+		h.HandleStream(stream)
+		resp, err := protocol.ParseResponse(&stream.output)
+		if err != nil {
+			t.Fatalf("parse response: %v", err)
+		}
+		return resp
+	}
+
+	for _, tc := range []struct {
+		name, req, want string
+	}{
+		{"no destination", "MOVE /drafts/post.md\n---\nauth: writer\n---\n", protocol.StatusBadRequest},
+		{"relative destination", "MOVE /drafts/post.md\n---\nauth: writer\ndestination: post.md\n---\n", protocol.StatusBadRequest},
+		{"traversal", "MOVE /drafts/post.md\n---\nauth: writer\ndestination: /a/../../post.md\n---\n", protocol.StatusBadRequest},
+		{"denied destination", "MOVE /drafts/post.md\n---\nauth: writer\ndestination: /private/post.md\n---\n", protocol.StatusNotFound},
+		{"bad redirect", "MOVE /drafts/post.md\n---\nauth: writer\ndestination: /post.md\nredirect: maybe\n---\n", protocol.StatusBadRequest},
+		{"no token", "MOVE /drafts/post.md\n---\ndestination: /post.md\n---\n", protocol.StatusUnauthorized},
+		{"destination out of scope", "MOVE /drafts/post.md\n---\nauth: drafts\ndestination: /post.md\n---\n", protocol.StatusNotPermitted},
+		{"missing", "MOVE /missing.md\n---\nauth: writer\ndestination: /post.md\n---\n", protocol.StatusNotFound},
+		{"taken", "MOVE /drafts/post.md\n---\nauth: writer\ndestination: /taken.md\n---\n", protocol.StatusConflict},
+	} {
+		if resp := do(tc.req); resp.Status != tc.want {
+			t.Errorf("%s: status %q, want %q: %s", tc.name, resp.Status, tc.want, resp.Body)
+		}
+	}
+
+	resp := do("MOVE /drafts/post.md\n---\nauth: writer\ndestination: /posts/post.md\nredirect: true\n---\n")
+	if resp.Status != protocol.StatusOK {
+		t.Fatalf("move: status %q: %s", resp.Status, resp.Body)
+	}
+	if resp.Metadata["version"] != "3" || resp.Metadata["location"] != "/posts/post.md" || resp.Metadata["moved-from"] != "/drafts/post.md" {
+		t.Errorf("move metadata = %v", resp.Metadata)
+	}
+
+	resp = do("FETCH /posts/post.md\n")
+	if resp.Status != protocol.StatusOK || resp.Metadata["moved-from"] != "/drafts/post.md" || !strings.Contains(resp.Body, "Edited.") {
+		t.Errorf("fetch new path: %q %v\n%s", resp.Status, resp.Metadata, resp.Body)
+	}
+	if resp := do("VERSIONS /posts/post.md\n"); resp.Status != protocol.StatusOK || resp.Metadata["total"] != "3" || resp.Metadata["chain-valid"] != "true" {
+		t.Errorf("versions new path: %q %v", resp.Status, resp.Metadata)
+	}
+	resp = do("FETCH /drafts/post.md\n")
+	if resp.Status != protocol.StatusMoved || resp.Metadata["location"] != "/posts/post.md" {
+		t.Errorf("fetch old path: %q %v", resp.Status, resp.Metadata)
+	}
+}
+
+func TestInfo(t *testing.T) {
+	dir, s := setupVersionedDir(t, map[string]string{"doc.md": "# Doc\n\nA body worth skipping.\n", "gone.md": "# Gone\n"})
+	if _, err := s.Write("/doc.md", []byte("# Doc\n\nA longer body worth skipping.\n"), map[string]string{"title": "Doc"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Archive("/gone.md", true); err != nil {
+		t.Fatal(err)
+	}
+	h := &Handler{ContentDir: dir, Store: s, Logger: discardLogger}
+	do := func(req string) protocol.Response {
+		t.Helper()
+		stream := newMockStream(req)
+		h.HandleStream(stream)
+		resp, err := protocol.ParseResponse(&stream.output)
+		if err != nil {
+			t.Fatalf("parse response: %v", err)
+		}
+		return resp
+	}
+
+	fetched := do("FETCH /doc.md\n")
+	info := do("INFO /doc.md\n")
+	if info.Status != protocol.StatusOK || info.Body != "" {
+		t.Fatalf("INFO: status %q, body %q", info.Status, info.Body)
+	}
+	for _, k := range []string{"etag", "version", "modified", "content-hash", "previous-hash", "title"} {
+		if info.Metadata[k] != fetched.Metadata[k] {
+			t.Errorf("%s = %q, FETCH gave %q", k, info.Metadata[k], fetched.Metadata[k])
+		}
+	}
+	if info.Metadata["content-length"] != fmt.Sprint(len(fetched.Body)) || info.Metadata["archived"] != "false" {
+		t.Errorf("size = %q (body is %d bytes), archived = %q", info.Metadata["content-length"], len(fetched.Body), info.Metadata["archived"])
+	}
+
+	pinned := do("INFO /doc.md/v1\n")
+	if pinned.Status != protocol.StatusOK || pinned.Metadata["version"] != "1" || pinned.Metadata["current-version"] != "2" || pinned.Body != "" {
+		t.Errorf("pinned INFO: %q %v %q", pinned.Status, pinned.Metadata, pinned.Body)
+	}
+
+	archived := do("INFO /gone.md\n")
+	if archived.Status != protocol.StatusOK || archived.Metadata["archived"] != "true" || archived.Metadata["etag"] == "" {
+		t.Errorf("archived INFO: %q %v", archived.Status, archived.Metadata)
+	}
+
+	if resp := do("INFO /doc.md\n---\nif-none-match: " + info.Metadata["etag"] + "\n---\n"); resp.Status != protocol.StatusNotModified {
+		t.Errorf("conditional INFO: status %q, want %q", resp.Status, protocol.StatusNotModified)
+	}
+	if resp := do("INFO /missing.md\n"); resp.Status != protocol.StatusNotFound {
+		t.Errorf("missing INFO: status %q, want %q", resp.Status, protocol.StatusNotFound)
+	}
+}
+
+func TestTokenDefaultMeta(t *testing.T) {
+	ts := auth.NewTokenStore(map[string]auth.Token{
+		auth.HashToken("ci"): {Paths: []string{"/*"}, Operations: []string{"publish"}, Meta: map[string]string{"author": "ci-bot", "tags": "generated"}},
+	})
+	dir, s := setupVersionedDir(t, nil)
+	h := &Handler{ContentDir: dir, Store: s, Logger: discardLogger, GetTokenStore: func() *auth.TokenStore { return ts }}
+	do := func(req string) protocol.Response {
+		t.Helper()
+		stream := newMockStream(req)
+		h.HandleStream(stream)
+		resp, err := protocol.ParseResponse(&stream.output)
+		if err != nil {
+			t.Fatalf("parse response: %v", err)
+		}
+		return resp
+	}
+
+	if resp := do("PUBLISH /report.md\n---\nauth: ci\n---\n# Report\n"); resp.Status != protocol.StatusCreated {
+		t.Fatalf("publish: status %q: %s", resp.Status, resp.Body)
+	}
+	doc, err := s.Get("/report.md", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Metadata["author"] != "ci-bot" || doc.Metadata["tags"] != "generated" {
+		t.Errorf("defaults not applied: %v", doc.Metadata)
+	}
+
+	// The token's values win over the request's.
+	if resp := do("APPEND /report.md\n---\nauth: ci\nexpected-version: 1\nauthor: alice\nstatus-note: draft\n---\nMore.\n"); resp.Status != protocol.StatusCreated {
+		t.Fatalf("append: status %q: %s", resp.Status, resp.Body)
+	}
+	doc, err = s.Get("/report.md", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Metadata["author"] != "ci-bot" || doc.Metadata["tags"] != "generated" || doc.Metadata["status-note"] != "draft" {
+		t.Errorf("token values not kept: %v", doc.Metadata)
+	}
+
+	var meta strings.Builder
+	for i := range protocol.MaxMetaKeys - 1 {
+		fmt.Fprintf(&meta, "k%d: v\n", i)
+	}
+	if resp := do("PUBLISH /full.md\n---\nauth: ci\n" + meta.String() + "---\n# Full\n"); resp.Status != protocol.StatusBadRequest {
+		t.Errorf("too many keys with defaults: status %q, want %q", resp.Status, protocol.StatusBadRequest)
+	}
+}
+
+// BenchmarkHandleFetch measures the FETCH hot path: request parsing, path
+// resolution and the response write, for concurrent readers of one document.
+func BenchmarkHandleFetch(b *testing.B) {
+	dir, s := setupVersionedDir(b, map[string]string{"docs/page.md": "# Page\n\n" + strings.Repeat("Some text.\n", 100)})
+	h := &Handler{ContentDir: dir, Store: s, Logger: discardLogger}
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			stream := newMockStream("FETCH /docs/page.md\n")
+			h.HandleStream(stream)
+			if !bytes.Contains(stream.output.Bytes(), []byte("\nstatus: ok\n")) {
+				b.Fatalf("unexpected response:\n%s", stream.output.String())
+			}
+		}
+	})
+}
+
+func TestRange(t *testing.T) {
+	body := "# Guide\n\n" + strings.Repeat("0123456789", 10) + "\n"
+	dir, s := setupVersionedDir(t, map[string]string{"guide.md": body, "empty.md": ""})
+	h := &Handler{ContentDir: dir, Store: s, Logger: discardLogger}
+	fetch := func(req string) protocol.Response {
+		t.Helper()
+		stream := newMockStream(req)
+		h.HandleStream(stream)
+		resp, err := protocol.ParseResponse(&stream.output)
+		if err != nil {
+			t.Fatalf("parse response: %v", err)
+		}
+		return resp
+	}
+	whole := fetch("FETCH /guide.md\n")
+
+	tests := []struct {
+		rng, contentRange, body string
+	}{
+		{"bytes=0-6", fmt.Sprintf("bytes 0-6/%d", len(body)), "# Guide"},
+		{"bytes=100-", fmt.Sprintf("bytes 100-%d/%d", len(body)-1, len(body)), body[100:]},
+		{"bytes=-11", fmt.Sprintf("bytes %d-%d/%d", len(body)-11, len(body)-1, len(body)), "0123456789\n"},
+		{"bytes=0-99999", fmt.Sprintf("bytes 0-%d/%d", len(body)-1, len(body)), body},
+	}
+	for _, tt := range tests {
+		resp := fetch("FETCH /guide.md\n---\nrange: " + tt.rng + "\n---\n")
+		if resp.Status != protocol.StatusPartial {
+			t.Errorf("%s: status = %q, want partial", tt.rng, resp.Status)
+			continue
+		}
+		if resp.Metadata["content-range"] != tt.contentRange || resp.Body != tt.body {
+			t.Errorf("%s: content-range %q, body %q", tt.rng, resp.Metadata["content-range"], resp.Body)
+		}
+		if resp.Metadata["etag"] != whole.Metadata["etag"] {
+			t.Errorf("%s: etag %q does not describe the whole document", tt.rng, resp.Metadata["etag"])
+		}
+	}
+
+	for _, rng := range []string{"bytes=500-", "bytes=5-1", "lines=1-2", "bytes=0-1,4-5"} {
+		if resp := fetch("FETCH /guide.md\n---\nrange: " + rng + "\n---\n"); resp.Status != protocol.StatusBadRequest {
+			t.Errorf("%s: status = %q, want bad-request", rng, resp.Status)
+		}
+	}
+	if resp := fetch("INFO /guide.md\n---\nrange: bytes=0-6\n---\n"); resp.Status != protocol.StatusOK || resp.Metadata["content-length"] != fmt.Sprint(len(body)) {
+		t.Errorf("INFO with range: status %q, size %q", resp.Status, resp.Metadata["content-length"])
+	}
+	if resp := fetch("FETCH /guide.md\n---\nrange: bytes=0-6\nif-none-match: " + whole.Metadata["etag"] + "\n---\n"); resp.Status != protocol.StatusNotModified {
+		t.Errorf("conditional range: status = %q, want not-modified", resp.Status)
+	}
+	if resp := fetch("FETCH /empty.md\n---\nrange: bytes=0-6\n---\n"); resp.Status != protocol.StatusOK || resp.Body != "" {
+		t.Errorf("empty document: status %q, body %q", resp.Status, resp.Body)
+	}
+}
+
+func TestSignatures(t *testing.T) {
+	body := "# Guide\n\nHello.\n"
+	dir, s := setupVersionedDir(t, map[string]string{"guide.md": body})
+	key := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+	pub := key.Public().(ed25519.PublicKey)
+	fetch := func(h *Handler, req string) protocol.Response {
+		t.Helper()
+		stream := newMockStream(req)
+		h.HandleStream(stream)
+		resp, err := protocol.ParseResponse(&stream.output)
+		if err != nil {
+			t.Fatalf("parse response: %v", err)
+		}
+		return resp
+	}
+
+	unsigned := &Handler{ContentDir: dir, Store: s, Logger: discardLogger}
+	resp := fetch(unsigned, "FETCH /guide.md\n")
+	if resp.Metadata["content-sha256"] != protocol.ContentSHA256(body) {
+		t.Errorf("content-sha256 = %q, want %q", resp.Metadata["content-sha256"], protocol.ContentSHA256(body))
+	}
+	if err := resp.VerifySignature("/guide.md", pub); !errors.Is(err, protocol.ErrUnsigned) {
+		t.Errorf("no signing key: VerifySignature = %v, want ErrUnsigned", err)
+	}
+
+	signed := &Handler{ContentDir: dir, Store: s, Logger: discardLogger, SigningKey: key}
+	for _, req := range []string{"FETCH /guide.md\n", "FETCH /guide.md/v1\n", "FETCH /\n"} {
+		resp := fetch(signed, req)
+		path := strings.Fields(req)[1]
+		if err := resp.VerifySignature(path, pub); err != nil {
+			t.Errorf("%q: VerifySignature: %v", req, err)
+		}
+	}
+
+	html := fetch(signed, "FETCH /guide.md\n---\naccept: text/html\n---\n")
+	if err := html.VerifySignature("/guide.md", pub); err != nil {
+		t.Errorf("html: VerifySignature: %v", err)
+	}
+	info := fetch(signed, "INFO /guide.md\n")
+	if info.Metadata["content-sha256"] != resp.Metadata["content-sha256"] || info.Metadata["signature"] == "" {
+		t.Errorf("INFO: content-sha256 %q, signature %q", info.Metadata["content-sha256"], info.Metadata["signature"])
+	}
+	partial := fetch(signed, "FETCH /guide.md\n---\nrange: bytes=0-6\n---\n")
+	if partial.Metadata["content-sha256"] != resp.Metadata["content-sha256"] {
+		t.Errorf("range: content-sha256 %q does not describe the whole document", partial.Metadata["content-sha256"])
+	}
+}
+
+func TestTransforms(t *testing.T) {
+	const body = "# Release :tada:\n\nSee [the guide](mark://docs.example.com/guide.md).\n"
+	dir, s := setupVersionedDir(t, map[string]string{"release.md": body})
+	if _, err := s.Write("/logo.svg", []byte("<svg>:tada:</svg>\n"), map[string]string{"content-type": "image/svg+xml"}); err != nil {
+		t.Fatal(err)
+	}
+	h := &Handler{ContentDir: dir, Store: s, Logger: discardLogger}
+	fetch := func(req string) protocol.Response {
+		t.Helper()
+		stream := newMockStream(req)
+		h.HandleStream(stream)
+		resp, err := protocol.ParseResponse(&stream.output)
+		if err != nil {
+			t.Fatalf("parse response: %v", err)
+		}
+		return resp
+	}
+	plain := fetch("FETCH /release.md\n")
+
+	pipeline, err := transform.New([]string{"emoji", "anchors", "rewrite-links"}, []string{"mark://docs.example.com/=mark://mirror.example.org/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Transforms = pipeline
+	const want = "# Release 🎉 [¶](#release-)\n\nSee [the guide](mark://mirror.example.org/guide.md).\n"
+	resp := fetch("FETCH /release.md\n")
+	if resp.Body != want {
+		t.Errorf("transformed body = %q, want %q", resp.Body, want)
+	}
+	if resp.Metadata["etag"] == plain.Metadata["etag"] {
+		t.Error("etag does not account for the transforms")
+	}
+	if resp.Metadata["content-hash"] != plain.Metadata["content-hash"] {
+		t.Errorf("content-hash %q no longer describes the stored markdown", resp.Metadata["content-hash"])
+	}
+	if r := fetch("FETCH /release.md\n---\nif-none-match: " + resp.Metadata["etag"] + "\n---\n"); r.Status != protocol.StatusNotModified {
+		t.Errorf("current etag: status %q, want not-modified", r.Status)
+	}
+	if r := fetch("FETCH /release.md\n---\nif-none-match: " + plain.Metadata["etag"] + "\n---\n"); r.Status != protocol.StatusOK || r.Body != want {
+		t.Errorf("untransformed etag: status %q, body %q", r.Status, r.Body)
+	}
+	if r := fetch("INFO /release.md\n"); r.Metadata["content-length"] != fmt.Sprint(len(want)) || r.Metadata["etag"] != resp.Metadata["etag"] {
+		t.Errorf("INFO: size %q, etag %q", r.Metadata["content-length"], r.Metadata["etag"])
+	}
+
+	// Another configuration is another representation.
+	h.Transforms = pipeline[:1]
+	if r := fetch("FETCH /release.md\n"); r.Metadata["etag"] == resp.Metadata["etag"] || r.Metadata["etag"] == plain.Metadata["etag"] {
+		t.Errorf("etag %q shared across configurations", r.Metadata["etag"])
+	}
+	h.Transforms = pipeline
+
+	// Numbered versions and content-addressed fetches stay verifiable.
+	for _, req := range []string{"FETCH /release.md/v1\n", "FETCH /" + plain.Metadata["content-hash"] + "\n"} {
+		if r := fetch(req); r.Body != body || r.Metadata["etag"] != plain.Metadata["etag"] {
+			t.Errorf("%q: body %q, etag %q", req, r.Body, r.Metadata["etag"])
+		}
+	}
+	if r := fetch("FETCH /logo.svg\n"); r.Body != "<svg>:tada:</svg>\n" {
+		t.Errorf("asset transformed: %q", r.Body)
+	}
+}
+
+func TestProtocolVersion(t *testing.T) {
+	dir, s := setupVersionedDir(t, map[string]string{"index.md": "# Home\n"})
+	h := &Handler{ContentDir: dir, Store: s, Logger: discardLogger}
+	send := func(req string) protocol.Response {
+		t.Helper()
+		stream := newMockStream(req)
+		h.HandleStream(stream)
+		resp, err := protocol.ParseResponse(&stream.output)
+		if err != nil {
+			t.Fatalf("parse response: %v", err)
+		}
+		return resp
+	}
+
+	for _, req := range []string{"FETCH /index.md\n", "FETCH /index.md MARK/1.0\n", "FETCH /index.md " + protocol.ProtocolVersion + "\n", "FETCH /index.md MARK/1.9\n"} {
+		resp := send(req)
+		if resp.Status != protocol.StatusOK || resp.Body != "# Home\n" {
+			t.Errorf("%q: status %q, body %q", req, resp.Status, resp.Body)
+		}
+		if resp.Metadata["server-protocol"] != protocol.ProtocolVersion {
+			t.Errorf("%q: server-protocol = %q", req, resp.Metadata["server-protocol"])
+		}
+	}
+	if resp := send("FETCH /missing.md MARK/1.1\n"); resp.Metadata["server-protocol"] != protocol.ProtocolVersion {
+		t.Errorf("error response: server-protocol = %q", resp.Metadata["server-protocol"])
+	}
+
+	resp := send("FETCH /index.md MARK/2.0\n")
+	if resp.Status != protocol.StatusBadRequest || resp.Metadata["server-protocol"] != protocol.ProtocolVersion {
+		t.Errorf("unsupported major version: status %q, server-protocol %q", resp.Status, resp.Metadata["server-protocol"])
+	}
+
+	etag := send("FETCH /index.md\n").Metadata["etag"]
+	if resp := send("FETCH /index.md MARK/1.1\n---\nif-none-match: " + etag + "\n---\n"); resp.Status != protocol.StatusNotModified || len(resp.Metadata) != 0 {
+		t.Errorf("not-modified: status %q, metadata %v", resp.Status, resp.Metadata)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	dir, s := setupVersionedDir(t, map[string]string{"index.md": "# Home\n", "about.md": "# About\n"})
+	h := &Handler{ContentDir: dir, Store: s, Logger: discardLogger}
+	batch := func(records ...protocol.BatchRecord) protocol.Response {
+		t.Helper()
+		body, err := protocol.FormatBatch(records)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stream := newMockStream("BATCH / MARK/1.2\n" + body)
+		h.HandleStream(stream)
+		resp, err := protocol.ParseResponse(&stream.output)
+		if err != nil {
+			t.Fatalf("parse response: %v", err)
+		}
+		return resp
+	}
+
+	resp := batch(
+		protocol.BatchRecord{ID: "a", Message: "FETCH /index.md\n"},
+		protocol.BatchRecord{ID: "b", Message: "INFO /about.md\n"},
+		protocol.BatchRecord{ID: "c", Message: "FETCH /missing.md\n"},
+		protocol.BatchRecord{ID: "d", Message: "FETCH index.md\n"},
+	)
+	if resp.Status != protocol.StatusOK {
+		t.Fatalf("status %q, want ok: %s", resp.Status, resp.Body)
+	}
+	records, err := protocol.ParseBatch(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]protocol.Response)
+	for _, r := range records {
+		inner, err := protocol.ParseResponse(strings.NewReader(r.Message))
+		if err != nil {
+			t.Fatalf("record %s: %v", r.ID, err)
+		}
+		got[r.ID] = inner
+	}
+	if len(got) != 4 {
+		t.Fatalf("got %d records, want 4", len(got))
+	}
+	if r := got["a"]; r.Status != protocol.StatusOK || r.Body != "# Home\n" {
+		t.Errorf("a: status %q, body %q", r.Status, r.Body)
+	}
+	if r := got["b"]; r.Status != protocol.StatusOK || r.Metadata["content-length"] != "8" || r.Body != "" {
+		t.Errorf("b: status %q, size %q, body %q", r.Status, r.Metadata["content-length"], r.Body)
+	}
+	if r := got["c"]; r.Status != protocol.StatusNotFound {
+		t.Errorf("c: status %q, want not-found", r.Status)
+	}
+	// A malformed request fails on its own, as it would on its own stream.
+	if r := got["d"]; r.Status != protocol.StatusServerError {
+		t.Errorf("d: status %q, want server-error", r.Status)
+	}
+
+	// Writes cannot be batched, and nothing runs when one is included.
+	resp = batch(
+		protocol.BatchRecord{ID: "a", Message: "FETCH /index.md\n"},
+		protocol.BatchRecord{ID: "b", Message: "PUBLISH /new.md\n---\nauth: x\n---\n# New\n"},
+	)
+	if resp.Status != protocol.StatusBadRequest {
+		t.Errorf("batched PUBLISH: status %q, want bad-request", resp.Status)
+	}
+	if _, err := s.Get("/new.md", 0); err == nil {
+		t.Error("batched PUBLISH was applied")
+	}
+	if resp = batch(protocol.BatchRecord{ID: "a", Message: "BATCH /\n"}); resp.Status != protocol.StatusBadRequest {
+		t.Errorf("nested BATCH: status %q, want bad-request", resp.Status)
+	}
+
+	for _, body := range []string{"", "a 99\nFETCH /index.md\n", "FETCH /index.md\n"} {
+		stream := newMockStream("BATCH /\n" + body)
+		h.HandleStream(stream)
+		if resp, err := protocol.ParseResponse(&stream.output); err != nil || resp.Status != protocol.StatusBadRequest {
+			t.Errorf("body %q: status %q, err %v, want bad-request", body, resp.Status, err)
+		}
+	}
+
+	// The rate limiter is asked for the requests beyond the stream's own.
+	var asked int
+	h.AdmitBatch = func(n int) (bool, time.Duration) {
+		asked = n
+		return false, 1500 * time.Millisecond
+	}
+	resp = batch(
+		protocol.BatchRecord{ID: "a", Message: "FETCH /index.md\n"},
+		protocol.BatchRecord{ID: "b", Message: "FETCH /about.md\n"},
+		protocol.BatchRecord{ID: "c", Message: "FETCH /about.md\n"},
+	)
+	if resp.Status != protocol.StatusRateLimited || resp.Metadata["retry-after"] != "2" || asked != 2 {
+		t.Errorf("refused batch: status %q, retry-after %q, asked for %d", resp.Status, resp.Metadata["retry-after"], asked)
+	}
+}
+
+func TestAsyncPublish(t *testing.T) {
+	const secret = "async-secret"
+	ts := auth.NewTokenStore(map[string]auth.Token{
+		auth.HashToken(secret): {Paths: []string{"/**"}, Operations: []string{"publish"}},
+	})
+	dir, s := setupVersionedDir(t, map[string]string{"doc.md": "# One\n"})
+	q := jobs.New(1, time.Minute)
+	defer q.Close()
+	release, started := make(chan struct{}), make(chan struct{}, 1)
+	free := sync.OnceFunc(func() { close(release) })
+	defer free()
+	h := &Handler{ContentDir: dir, Store: s, Logger: discardLogger, GetTokenStore: func() *auth.TokenStore { return ts }, Jobs: q}
+	h.Scan = func(path string, body []byte) error {
+		if strings.Contains(string(body), "slow") {
+			started <- struct{}{}
+			<-release
+		}
+		if strings.Contains(string(body), "EICAR") {
+			return &scan.Rejection{Reason: "EICAR test file"}
+		}
+		return nil
+	}
+	send := func(req string) protocol.Response {
+		t.Helper()
+		stream := newMockStream(req)
+		h.HandleStream(stream)
+		resp, err := protocol.ParseResponse(&stream.output)
+		if err != nil {
+			t.Fatalf("parse response: %v", err)
+		}
+		return resp
+	}
+	publish := func(path, body string) protocol.Response {
+		t.Helper()
+		return send("PUBLISH " + path + "\n---\nasync: true\nauth: " + secret + "\n---\n" + body)
+	}
+	job := func(path, id string) protocol.Response {
+		t.Helper()
+		return send("JOB " + path + "\n---\nauth: " + secret + "\njob: " + id + "\n---\n")
+	}
+	// wait asks JOB until the job is no longer pending.
+	wait := func(path, id string) protocol.Response {
+		t.Helper()
+		for range 500 {
+			if resp := job(path, id); resp.Status != protocol.StatusAccepted {
+				return resp
+			}
+			time.Sleep(2 * time.Millisecond)
+		}
+		t.Fatalf("job %s still pending", id)
+		return protocol.Response{}
+	}
+
+	slow := publish("/big.md", "# Big\n\nslow\n")
+	id := slow.Metadata[protocol.MetaJob]
+	if slow.Status != protocol.StatusAccepted || id == "" {
+		t.Fatalf("async publish: %q %v\n%s", slow.Status, slow.Metadata, slow.Body)
+	}
+	if resp := job("/big.md", id); resp.Status != protocol.StatusAccepted || resp.Metadata[protocol.MetaJob] != id {
+		t.Errorf("pending job: %q %v", resp.Status, resp.Metadata)
+	}
+	// One job runs and one waits; the queue has room for no more.
+	<-started
+	queued := publish("/doc.md", "# EICAR\n")
+	if queued.Status != protocol.StatusAccepted {
+		t.Fatalf("second async publish: %q", queued.Status)
+	}
+	if resp := publish("/other.md", "# Other\n"); resp.Status != protocol.StatusUnavailable {
+		t.Errorf("full queue: %q", resp.Status)
+	}
+
+	for name, tc := range map[string]struct{ req, status string }{
+		"no token":    {"JOB /big.md\n---\njob: " + id + "\n---\n", protocol.StatusUnauthorized},
+		"no job":      {"JOB /big.md\n---\nauth: " + secret + "\n---\n", protocol.StatusBadRequest},
+		"other path":  {"JOB /doc.md\n---\nauth: " + secret + "\njob: " + id + "\n---\n", protocol.StatusNotFound},
+		"unknown job": {"JOB /big.md\n---\nauth: " + secret + "\njob: nope\n---\n", protocol.StatusNotFound},
+		"bad async":   {"PUBLISH /big.md\n---\nasync: yes\nauth: " + secret + "\n---\n# Big\n", protocol.StatusBadRequest},
+	} {
+		if resp := send(tc.req); resp.Status != tc.status {
+			t.Errorf("%s: status %q, want %q", name, resp.Status, tc.status)
+		}
+	}
+
+	free()
+	if resp := wait("/big.md", id); resp.Status != protocol.StatusCreated || resp.Metadata["version"] != "1" || resp.Metadata[protocol.MetaJob] != id {
+		t.Errorf("finished job: %q %v", resp.Status, resp.Metadata)
+	}
+	if doc, err := s.Get("/big.md", 0); err != nil || !strings.Contains(string(doc.Content), "slow") {
+		t.Errorf("document not written: %v", err)
+	}
+	// Checks that read the body run in the background, and their
+	// failures are the job's outcome.
+	if resp := wait("/doc.md", queued.Metadata[protocol.MetaJob]); resp.Status != protocol.StatusNotPermitted {
+		t.Errorf("rejected job: %q", resp.Status)
+	}
+
+	// Without a queue, async is ignored.
+	h.Jobs = nil
+	if resp := publish("/sync.md", "# Sync\n"); resp.Status != protocol.StatusCreated {
+		t.Errorf("publish without a queue: %q", resp.Status)
+	}
+}
+
+func TestAtomicBatch(t *testing.T) {
+	const testSecret = "test-publish-secret"
+	dir, s := setupVersionedDir(t, map[string]string{"index.md": "# Home\n"})
+	h := &Handler{ContentDir: dir, Store: s, Logger: discardLogger, GetTokenStore: func() *auth.TokenStore {
+		return auth.NewTokenStore(map[string]auth.Token{
+			auth.HashToken(testSecret): {Paths: []string{"/*"}, Operations: []string{"publish"}},
+		})
+	}}
+	publish := func(id, path, meta, body string) protocol.BatchRecord {
+		return protocol.BatchRecord{ID: id, Message: "PUBLISH " + path + "\n---\nauth: " + testSecret + "\n" + meta + "---\n" + body}
+	}
+	batch := func(meta string, records ...protocol.BatchRecord) (protocol.Response, []protocol.BatchRecord) {
+		t.Helper()
+		body, err := protocol.FormatBatch(records)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stream := newMockStream("BATCH / MARK/1.2\n---\n" + meta + "---\n" + body)
+		h.HandleStream(stream)
+		resp, err := protocol.ParseResponse(&stream.output)
+		if err != nil {
+			t.Fatalf("parse response: %v", err)
+		}
+		out, err := protocol.ParseBatch(resp.Body)
+		if err != nil && resp.Status != protocol.StatusBadRequest {
+			t.Fatalf("parse batch: %v", err)
+		}
+		return resp, out
+	}
+
+	resp, records := batch("atomic: true\n",
+		publish("a", "/index.md", "expected-version: 1\n", "# Home v2\n"),
+		publish("b", "/guide.md", "expected-version: 0\n", "# Guide\n"),
+		publish("c", "/about.md", "", "# About\n"),
+	)
+	if resp.Status != protocol.StatusOK || len(records) != 3 {
+		t.Fatalf("status %q, %d records: %s", resp.Status, len(records), resp.Body)
+	}
+	for i, want := range []struct{ id, version string }{{"a", "2"}, {"b", "1"}, {"c", "1"}} {
+		inner, err := protocol.ParseResponse(strings.NewReader(records[i].Message))
+		if err != nil || records[i].ID != want.id || inner.Status != protocol.StatusCreated || inner.Metadata["version"] != want.version {
+			t.Errorf("record %d: id %q, status %q, version %q, err %v", i, records[i].ID, inner.Status, inner.Metadata["version"], err)
+		}
+	}
+
+	// A conflict in one request publishes none of them.
+	resp, records = batch("atomic: true\n",
+		publish("a", "/about.md", "expected-version: 1\n", "# About v2\n"),
+		publish("b", "/guide.md", "expected-version: 0\n", "# Guide again\n"),
+	)
+	if resp.Status != protocol.StatusConflict || resp.Metadata[protocol.MetaFailedRequest] != "b" || len(records) != 1 || records[0].ID != "b" {
+		t.Errorf("conflict: status %q, failed-request %q, records %v", resp.Status, resp.Metadata[protocol.MetaFailedRequest], records)
+	}
+	if v := s.CurrentVersion("/about.md"); v != 1 {
+		t.Errorf("about.md at v%d after a failed batch, want v1", v)
+	}
+
+	// So does a request failing its checks.
+	resp, _ = batch("atomic: true\n",
+		publish("a", "/about.md", "", "# About v2\n"),
+		protocol.BatchRecord{ID: "b", Message: "PUBLISH /x.md\n---\nauth: wrong\n---\n# X\n"},
+	)
+	if resp.Status != protocol.StatusUnauthorized || resp.Metadata[protocol.MetaFailedRequest] != "b" {
+		t.Errorf("unauthorized: status %q, failed-request %q", resp.Status, resp.Metadata[protocol.MetaFailedRequest])
+	}
+	if v := s.CurrentVersion("/about.md"); v != 1 {
+		t.Errorf("about.md at v%d after a rejected batch, want v1", v)
+	}
+
+	for _, tt := range []struct {
+		meta    string
+		records []protocol.BatchRecord
+	}{
+		{"atomic: true\n", []protocol.BatchRecord{{ID: "a", Message: "FETCH /index.md\n"}}},
+		{"atomic: true\n", []protocol.BatchRecord{publish("a", "/index.md", "", "")}},
+		{"atomic: maybe\n", []protocol.BatchRecord{publish("a", "/index.md", "", "# Home v3\n")}},
+	} {
+		if resp, _ := batch(tt.meta, tt.records...); resp.Status != protocol.StatusBadRequest {
+			t.Errorf("%q %v: status %q, want bad-request", tt.meta, tt.records, resp.Status)
+		}
+	}
+}
+
+func TestExperimentalVerbs(t *testing.T) {
+	dir, s := setupVersionedDir(t, map[string]string{"index.md": "# Home\n"})
+	h := &Handler{ContentDir: dir, Store: s, Logger: discardLogger, Extensions: map[string]func(protocol.Request) protocol.Response{
+		"X-ECHO": func(req protocol.Request) protocol.Response {
+			return protocol.Response{Status: protocol.StatusOK, Metadata: map[string]string{"x-echo": req.Metadata["x-echo"]}, Body: req.Path + "\n"}
+		},
+	}}
+	do := func(req string) protocol.Response {
+		t.Helper()
+		stream := newMockStream(req)
+		h.HandleStream(stream)
+		resp, err := protocol.ParseResponse(&stream.output)
+		if err != nil {
+			t.Fatalf("parse response: %v", err)
+		}
+		return resp
+	}
+
+	resp := do("X-ECHO /index.md\n---\nx-echo: hi\n---\n")
+	if resp.Status != protocol.StatusOK || resp.Body != "/index.md\n" || resp.Metadata["x-echo"] != "hi" {
+		t.Errorf("X-ECHO: status %q, metadata %v, body %q", resp.Status, resp.Metadata, resp.Body)
+	}
+	if resp.Metadata["server-protocol"] == "" {
+		t.Error("X-ECHO: no server-protocol")
+	}
+
+	resp = do("X-SUBSCRIBE /index.md\n")
+	if resp.Status != protocol.StatusNotImplemented || !strings.Contains(resp.Body, "X-SUBSCRIBE") {
+		t.Errorf("unknown experimental verb: status %q, body %q", resp.Status, resp.Body)
+	}
+	if resp := do("X-ECHO /../secret.md\n"); resp.Status != protocol.StatusNotFound {
+		t.Errorf("traversal: status = %q, want not-found", resp.Status)
+	}
+
+	// Experimental keys sent with a write are not stored.
+	if got, err := extractPublisherMeta(map[string]string{"title": "T", "x-trace": "1"}); err != nil || len(got) != 1 || got["title"] != "T" {
+		t.Errorf("extractPublisherMeta = %v, %v", got, err)
+	}
+}
+
+func TestMaintenance(t *testing.T) {
+	dir, s := setupVersionedDir(t, map[string]string{"index.md": "# Home\n"})
+	const banner = "# Back soon\n\nMigrating the store.\n"
+	on := true
+	h := &Handler{ContentDir: dir, Store: s, Logger: discardLogger, Maintenance: func() (string, bool) { return banner, on }}
+	do := func(req string) protocol.Response {
+		t.Helper()
+		stream := newMockStream(req)
+		h.HandleStream(stream)
+		resp, err := protocol.ParseResponse(&stream.output)
+		if err != nil {
+			t.Fatalf("parse response: %v", err)
+		}
+		return resp
+	}
+
+	for _, req := range []string{"FETCH /index.md\n", "LIST /\n", "PUBLISH /new.md\n---\nauth: x\n---\n# New\n", "FETCH /health\n"} {
+		if resp := do(req); resp.Status != protocol.StatusMaintenance || resp.Body != banner {
+			t.Errorf("%q: status %q, body %q", req, resp.Status, resp.Body)
+		}
+	}
+	on = false
+	if resp := do("FETCH /index.md\n"); resp.Status != protocol.StatusOK {
+		t.Errorf("after maintenance: status = %q, want ok", resp.Status)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	dir, s := setupVersionedDir(t, map[string]string{"index.md": "# Home\n"})
+	var logs bytes.Buffer
+	h := &Handler{ContentDir: dir, Store: s, Logger: slog.New(slog.NewTextHandler(&logs, nil))}
+	do := func(req string) protocol.Response {
+		t.Helper()
+		stream := newMockStream(req)
+		h.HandleStream(stream)
+		resp, err := protocol.ParseResponse(&stream.output)
+		if err != nil {
+			t.Fatalf("parse response: %v", err)
+		}
+		return resp
+	}
+
+	resp := do("FETCH /index.md\n---\nrequest-id: trace-1\n---\n")
+	if resp.Status != protocol.StatusOK || resp.Metadata["request-id"] != "trace-1" {
+		t.Errorf("FETCH: status %q, request-id %q", resp.Status, resp.Metadata["request-id"])
+	}
+	resp = do("FETCH /missing.md\n---\nrequest-id: trace-2\n---\n")
+	if resp.Status != protocol.StatusNotFound || resp.Metadata["request-id"] != "trace-2" {
+		t.Errorf("missing: status %q, request-id %q", resp.Status, resp.Metadata["request-id"])
+	}
+	if !strings.Contains(logs.String(), `msg="not found" request_id=trace-2 path=/missing.md`) {
+		t.Errorf("not found line lacks request_id:\n%s", logs.String())
+	}
+	if n := strings.Count(logs.String(), "request_id=trace-1"); n != 1 {
+		t.Errorf("trace-1 logged %d times, want once:\n%s", n, logs.String())
+	}
+
+	if resp := do("FETCH /index.md\n"); resp.Metadata["request-id"] != "" {
+		t.Errorf("request without request-id got %q", resp.Metadata["request-id"])
+	}
+	if resp := do("FETCH /index.md\n---\nrequest-id: two words\n---\n"); resp.Status != protocol.StatusBadRequest {
+		t.Errorf("invalid request-id: status %q, want bad-request", resp.Status)
+	}
+	if got, err := extractPublisherMeta(map[string]string{"title": "T", "request-id": "trace-3"}); err != nil || len(got) != 1 {
+		t.Errorf("extractPublisherMeta = %v, %v", got, err)
+	}
+}
+
+func TestParseRedirects(t *testing.T) {
+	for _, rule := range []string{
+		"/old.md",
+		"/old.md=",
+		"old.md=/new.md",
+		"/a/../b.md=/c.md",
+		"/docs/*=/d/",
+		"/old.md=new.md",
+		"/old.md=https://example.com/new.md",
+		"/wiki/=/pages/wiki.md",
+	} {
+		if _, err := ParseRedirects([]string{rule}); err == nil {
+			t.Errorf("ParseRedirects(%q): expected error", rule)
+		}
+	}
+	got, err := ParseRedirects([]string{"/old.md=/new.md", "/wiki/=mark://wiki.example.com/"})
+	if err != nil || len(got) != 2 || got[1] != (Redirect{From: "/wiki/", To: "mark://wiki.example.com/"}) {
+		t.Errorf("ParseRedirects = %v, %v", got, err)
+	}
+}
+
+func TestConfiguredRedirects(t *testing.T) {
+	dir, s := setupVersionedDir(t, map[string]string{"old.md": "# Old\n", "new.md": "# New\n"})
+	redirects, err := ParseRedirects([]string{"/old.md=/new.md", "/wiki/=mark://wiki.example.com/pages/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &Handler{ContentDir: dir, Store: s, Logger: discardLogger, Redirects: redirects}
+	do := func(req string) protocol.Response {
+		t.Helper()
+		stream := newMockStream(req)
+		h.HandleStream(stream)
+		resp, err := protocol.ParseResponse(&stream.output)
+		if err != nil {
+			t.Fatalf("parse response: %v", err)
+		}
+		return resp
+	}
+
+	tests := []struct {
+		req      string
+		location string
+	}{
+		// A redirect wins over the document still stored at its path.
+		{"FETCH /old.md\n", "/new.md"},
+		{"FETCH /old.md/v1\n", "/new.md/v1"},
+		{"VERSIONS /old.md\n", "/new.md"},
+		{"LIST /wiki/\n", "mark://wiki.example.com/pages/"},
+		{"FETCH /wiki\n", "mark://wiki.example.com/pages/"},
+		{"FETCH /wiki/a/b.md\n", "mark://wiki.example.com/pages/a/b.md"},
+	}
+	for _, tt := range tests {
+		resp := do(tt.req)
+		if resp.Status != protocol.StatusMoved || resp.Metadata["location"] != tt.location {
+			t.Errorf("%q: status %q, location %q; want moved to %q", tt.req, resp.Status, resp.Metadata["location"], tt.location)
+		}
+	}
+	for _, req := range []string{"FETCH /new.md\n", "FETCH /old.mdx\n", "FETCH /wikis/a.md\n"} {
+		if resp := do(req); resp.Status == protocol.StatusMoved {
+			t.Errorf("%q: unexpectedly moved to %q", req, resp.Metadata["location"])
+		}
+	}
+}
+
+func TestLanguageVariants(t *testing.T) {
+	const reader = "variant-reader"
+	ts := auth.NewTokenStore(map[string]auth.Token{
+		auth.HashToken(reader): {Paths: []string{"/notes.fr.md"}, Operations: []string{"read"}},
+	})
+	dir, s := setupVersionedDir(t, map[string]string{
+		"index.md":          "# Home\n",
+		"index.de.md":       "# Startseite\n",
+		"index.pt.md":       "# Início\n",
+		"index.es.md":       "# Inicio\n",
+		"guide/index.md":    "# Guide\n",
+		"guide/index.fr.md": "# Guide (fr)\n",
+		"notes.md":          "# Notes\n",
+		"notes.fr.md":       "# Notes (fr)\n",
+	})
+	if err := s.Archive("/index.es.md", true); err != nil {
+		t.Fatal(err)
+	}
+	h := &Handler{ContentDir: dir, Store: s, Logger: discardLogger, GetTokenStore: func() *auth.TokenStore { return ts }}
+	fetch := func(path, meta string) protocol.Response {
+		t.Helper()
+		req := "FETCH " + path + "\n"
+		if meta != "" {
+			req += "---\n" + meta + "---\n"
+		}
+		stream := newMockStream(req)
+		h.HandleStream(stream)
+		resp, err := protocol.ParseResponse(&stream.output)
+		if err != nil {
+			t.Fatalf("parse response: %v", err)
+		}
+		return resp
+	}
+
+	tests := []struct {
+		path, meta string
+		body, lang string
+	}{
+		{"/index.md", "", "# Home\n", ""},
+		{"/index.md", "accept-language: de\n", "# Startseite\n", "de"},
+		{"/index.md", "accept-language: pt-BR, de;q=0.5\n", "# Início\n", "pt"},
+		{"/index.md", "accept-language: ja, DE\n", "# Startseite\n", "de"},
+		{"/index.md", "accept-language: es\n", "# Home\n", ""}, // archived
+		{"/index.md", "accept-language: ja\n", "# Home\n", ""},
+		{"/guide/", "accept-language: fr\n", "# Guide (fr)\n", "fr"},
+		{"/notes.md", "accept-language: fr\n", "# Notes\n", ""}, // read-protected
+		{"/notes.md", "accept-language: fr\nauth: " + reader + "\n", "# Notes (fr)\n", "fr"},
+		{"/index.md/v1", "accept-language: de\n", "# Home\n", ""},
+	}
+	for _, tt := range tests {
+		resp := fetch(tt.path, tt.meta)
+		if resp.Status != protocol.StatusOK || resp.Body != tt.body || resp.Metadata["content-language"] != tt.lang {
+			t.Errorf("%s %q: got %q %q content-language %q, want %q %q", tt.path, tt.meta, resp.Status, resp.Body, resp.Metadata["content-language"], tt.body, tt.lang)
+		}
+	}
+
+	// A variant is its own document, so validators from one do not match
+	// the other.
+	etag := fetch("/index.md", "").Metadata["etag"]
+	if resp := fetch("/index.md", "accept-language: de\nif-none-match: "+etag+"\n"); resp.Status != protocol.StatusOK {
+		t.Errorf("if-none-match of the default answered %q for the variant", resp.Status)
+	}
+}
+
+func TestReadingStats(t *testing.T) {
+	long := strings.Repeat("word ", 450)
+	dir, s := setupVersionedDir(t, map[string]string{
+		"short.md": "# A short note\n\n- one item\n- *two* items | 3\n",
+		"long.md":  "# Long\n\n" + long + "\n",
+	})
+	if _, err := s.Write("/logo.png", []byte("\x89PNG"), map[string]string{"content-type": "image/png"}); err != nil {
+		t.Fatal(err)
+	}
+	h := &Handler{ContentDir: dir, Store: s, Logger: discardLogger}
+	send := func(req string) protocol.Response {
+		t.Helper()
+		stream := newMockStream(req)
+		h.HandleStream(stream)
+		resp, err := protocol.ParseResponse(&stream.output)
+		if err != nil {
+			t.Fatalf("parse response: %v", err)
+		}
+		return resp
+	}
+
+	tests := []struct {
+		req               string
+		size, words, mins string
+	}{
+		{"FETCH /short.md\n", "45", "8", "1"},
+		{"INFO /short.md\n", "45", "8", "1"},
+		{"FETCH /short.md/v1\n", "45", "8", "1"},
+		{"FETCH /long.md\n", fmt.Sprint(len("# Long\n\n" + long + "\n")), "451", "3"},
+		{"FETCH /logo.png\n", "4", "", ""},
+	}
+	for _, tt := range tests {
+		resp := send(tt.req)
+		if resp.Metadata["content-length"] != tt.size || resp.Metadata["word-count"] != tt.words || resp.Metadata["reading-time"] != tt.mins {
+			t.Errorf("%q: size %q, word-count %q, reading-time %q; want %q, %q, %q", tt.req,
+				resp.Metadata["content-length"], resp.Metadata["word-count"], resp.Metadata["reading-time"], tt.size, tt.words, tt.mins)
+		}
+	}
+
+	// A range leaves size describing the whole body.
+	if resp := send("FETCH /short.md\n---\nrange: bytes=0-9\n---\n"); resp.Status != protocol.StatusPartial || resp.Metadata["content-length"] != "45" {
+		t.Errorf("range: %q size %q", resp.Status, resp.Metadata["content-length"])
+	}
+}
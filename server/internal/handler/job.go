@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"bytes"
+	"io"
+	"maps"
+
+	"github.com/latebit/demarkus/protocol"
+	"github.com/latebit/demarkus/server/internal/auth"
+)
+
+// acceptPublish queues a PUBLISH sent with async that passed planPublish,
+// and answers it accepted with the ID of its job. The job scans and
+// writes the document as handlePublish would, and keeps the response for
+// JOB.
+func (h *Handler) acceptPublish(w io.Writer, p publishPlan) {
+	id, err := h.Jobs.Submit(p.write.Path, func() protocol.Response {
+		var out bytes.Buffer
+		if h.vetPublish(&out, p) {
+			h.writePublish(&out, p)
+		}
+		resp, err := protocol.ParseResponse(&out)
+		if err != nil {
+			h.logger().Error("publish job failed", "path", sanitize(p.write.Path), "error", err)
+			return protocol.Response{Status: protocol.StatusServerError, Body: "\n# Server error\n\ninternal error\n"}
+		}
+		// JOB echoes its own request-id instead.
+		delete(resp.Metadata, protocol.MetaRequestID)
+		return resp
+	})
+	if err != nil {
+		// The queue is full, or closed while the server shuts down.
+		h.logger().Warn("publish not queued", "path", sanitize(p.write.Path), "error", err)
+		h.writeError(w, protocol.StatusUnavailable, "the publish queue is full; try again later")
+		return
+	}
+	h.logger().Info("publish queued", "path", sanitize(p.write.Path), "job", id, "token_label", sanitize(p.tokenLabel), "size_bytes", len(p.write.Content))
+	h.writeResponse(w, protocol.Response{
+		Status:   protocol.StatusAccepted,
+		Metadata: map[string]string{protocol.MetaJob: id},
+		Body:     "\n# Accepted\n\n" + escapeMD(p.write.Path) + " will be written shortly. Ask JOB with this job for the outcome.\n",
+	})
+}
+
+// handleJob serves JOB: the outcome of the PUBLISH of the requested path
+// that was accepted as the job named in the job metadata. It is accepted
+// again while the job is pending, and then the response the PUBLISH would
+// have had. Asking takes a token granting publish on the path, as the
+// PUBLISH did.
+func (h *Handler) handleJob(w io.Writer, req protocol.Request) {
+	id := req.Metadata[protocol.MetaJob]
+	if id == "" {
+		h.writeError(w, protocol.StatusBadRequest, "JOB requires job metadata")
+		return
+	}
+	var ts *auth.TokenStore
+	if h.GetTokenStore != nil {
+		ts = h.GetTokenStore()
+	}
+	if ts == nil {
+		h.writeError(w, protocol.StatusNotPermitted, "publishing requires auth configuration")
+		return
+	}
+	if _, err := ts.Authorize(req.Metadata["auth"], req.Path, "publish"); err != nil {
+		h.writeAuthError(w, "JOB", req.Path, err)
+		return
+	}
+
+	var (
+		resp     protocol.Response
+		done, ok bool
+	)
+	if h.Jobs != nil {
+		resp, done, ok = h.Jobs.Result(id, req.Path)
+	}
+	if !ok {
+		h.writeError(w, protocol.StatusNotFound, "no job "+sanitize(id)+" for "+req.Path)
+		return
+	}
+	if !done {
+		h.writeResponse(w, protocol.Response{
+			Status:   protocol.StatusAccepted,
+			Metadata: map[string]string{protocol.MetaJob: id},
+		})
+		return
+	}
+	resp.Metadata = maps.Clone(resp.Metadata)
+	if resp.Metadata == nil {
+		resp.Metadata = make(map[string]string)
+	}
+	resp.Metadata[protocol.MetaJob] = id
+	h.writeResponse(w, resp)
+}
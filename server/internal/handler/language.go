@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"os"
+
+	"github.com/latebit/demarkus/protocol"
+	"github.com/latebit/demarkus/server/internal/store"
+)
+
+// maxLanguageCandidates is the most variants a request's accept-language
+// makes the server look for, so a long list cannot cost a lookup per tag.
+const maxLanguageCandidates = 8
+
+// languageVariant returns the variant of the document at docPath in the
+// language req's accept-language prefers, its path and its language, or a
+// nil document when the request names none or none exists. Variants the
+// request may not read, denied ones and archived ones are passed over.
+func (h *Handler) languageVariant(req protocol.Request, docPath string) (*store.Document, string, string) {
+	langs := protocol.AcceptedLanguages(req.Metadata[protocol.MetaAcceptLanguage])
+	for _, lang := range langs[:min(len(langs), maxLanguageCandidates)] {
+		variant := protocol.LanguageVariant(docPath, lang)
+		if variant == "" || h.isDenied(variant) || !h.mayRead(req, variant) {
+			continue
+		}
+		doc, err := h.Store.Get(variant, 0)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				h.logger().Error("fetch language variant failed", "path", sanitize(variant), "error", err)
+			}
+			continue
+		}
+		if !doc.Archived {
+			return doc, variant, lang
+		}
+	}
+	return nil, "", ""
+}
+
+// mayRead reports whether req's token, if any, may read reqPath, as
+// authorizeRead would decide without answering the request.
+func (h *Handler) mayRead(req protocol.Request, reqPath string) bool {
+	if h.GetTokenStore == nil {
+		return true
+	}
+	ts := h.GetTokenStore()
+	if ts == nil || !ts.RequiresReadAuth(reqPath) {
+		return true
+	}
+	_, err := ts.Authorize(req.Metadata["auth"], reqPath, "read")
+	return err == nil
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/latebit/demarkus/protocol"
+)
+
+// listPage is the part of a listing a LIST asked for.
+type listPage struct {
+	offset int
+	limit  int
+	sort   string
+}
+
+// parseListPage reads the offset, limit and sort metadata of a LIST. An
+// absent key means the first MaxDirectoryEntries entries by name.
+func parseListPage(meta map[string]string) (listPage, error) {
+	p := listPage{limit: MaxDirectoryEntries, sort: protocol.SortName}
+	if o := meta["offset"]; o != "" {
+		n, err := strconv.Atoi(o)
+		if err != nil || n < 0 {
+			return p, fmt.Errorf("invalid offset (must be a non-negative integer)")
+		}
+		p.offset = n
+	}
+	if l := meta["limit"]; l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > MaxDirectoryEntries {
+			return p, fmt.Errorf("invalid limit (must be 1-%d)", MaxDirectoryEntries)
+		}
+		p.limit = n
+	}
+	switch s := meta["sort"]; s {
+	case "", protocol.SortName:
+	case protocol.SortModified:
+		p.sort = s
+	default:
+		return p, fmt.Errorf("unknown sort %s (must be %s or %s)", sanitize(s), protocol.SortName, protocol.SortModified)
+	}
+	return p, nil
+}
+
+// apply returns the entries of the page, in its order, and the offset of
+// the entry after it, or 0 if it is the last page. entries are in name
+// order, as Store.ListDir returns them.
+func (p listPage) apply(entries []os.DirEntry) (page []os.DirEntry, next int) {
+	if p.sort == protocol.SortModified {
+		entries = slices.Clone(entries)
+		// A document's entry is its symlink, replaced on every write,
+		// so its modification time is that of the current version.
+		modified := make(map[string]time.Time, len(entries))
+		for _, e := range entries {
+			if info, err := e.Info(); err == nil {
+				modified[e.Name()] = info.ModTime()
+			}
+		}
+		slices.SortStableFunc(entries, func(a, b os.DirEntry) int {
+			return modified[b.Name()].Compare(modified[a.Name()])
+		})
+	}
+	from := min(p.offset, len(entries))
+	to := min(from+p.limit, len(entries))
+	if to < len(entries) {
+		next = to
+	}
+	return entries[from:to], next
+}
+
+// writeStructuredList answers a LIST asking for format: structured with
+// an entry per directory entry of the page, giving each document's size,
+// modification time and version. total counts every visible entry.
+func (h *Handler) writeStructuredList(w io.Writer, dir string, page []os.DirEntry, total, next int) {
+	list := make([]protocol.ListEntry, 0, len(page))
+	for _, entry := range page {
+		e := protocol.ListEntry{Name: entry.Name(), Type: protocol.EntryFile}
+		if entry.IsDir() {
+			e.Type = protocol.EntryDirectory
+		} else if doc, err := h.Store.Get(path.Join(dir, entry.Name()), 0); err == nil {
+			e.Type = protocol.EntryDocument
+			e.Size = h.Store.Describe(path.Join(dir, entry.Name()), doc).Size
+			e.Modified = doc.Modified
+			e.Version = doc.Version
+		}
+		list = append(list, e)
+	}
+
+	body, err := protocol.FormatListing(list)
+	if err != nil {
+		h.logger().Error("format listing failed", "path", sanitize(dir), "error", err)
+		h.writeError(w, protocol.StatusServerError, "internal error")
+		return
+	}
+	meta := map[string]string{
+		"entries":      strconv.Itoa(len(list)),
+		"total":        strconv.Itoa(total),
+		"content-type": protocol.ListingContentType,
+	}
+	if next > 0 {
+		meta["next-offset"] = strconv.Itoa(next)
+	}
+	h.writeResponse(w, protocol.Response{Status: protocol.StatusOK, Metadata: meta, Body: body})
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/latebit/demarkus/protocol"
+	"github.com/latebit/demarkus/server/internal/auth"
+	"github.com/latebit/demarkus/server/internal/store"
+)
+
+// handleMove serves MOVE: it renames the document at the request path to
+// the destination metadata path, keeping its version history. With
+// redirect: true, the old path is kept as an alias of the new one. The
+// token must be able to publish to both paths.
+func (h *Handler) handleMove(w io.Writer, req protocol.Request) {
+	if h.Store == nil {
+		h.writeError(w, protocol.StatusServerError, "moving not configured")
+		return
+	}
+	dest := req.Metadata["destination"]
+	if !strings.HasPrefix(dest, "/") || strings.HasSuffix(dest, "/") || containsDotDot(dest) || !protocol.IsValidMetaValue(dest) {
+		h.writeError(w, protocol.StatusBadRequest, "MOVE requires a destination document path")
+		return
+	}
+	for _, p := range []string{req.Path, dest} {
+		if _, ok := isHashPath(p); ok {
+			h.writeError(w, protocol.StatusBadRequest, "paths matching /sha256-<hash> are reserved")
+			return
+		}
+		if h.isTOCPath(p) {
+			h.writeError(w, protocol.StatusBadRequest, p+" is generated by the server")
+			return
+		}
+	}
+	// A denied destination looks like any path that is not there.
+	if h.isDenied(dest) {
+		h.logger().Warn("denied path blocked", "verb", sanitize(req.Verb), "path", sanitize(dest))
+		h.writeError(w, protocol.StatusNotFound, dest+" not found")
+		return
+	}
+	if path.Clean(req.Path) == path.Clean(dest) {
+		h.writeError(w, protocol.StatusBadRequest, "destination is the document's own path")
+		return
+	}
+	redirect := false
+	if v := req.Metadata["redirect"]; v != "" {
+		var err error
+		if redirect, err = strconv.ParseBool(v); err != nil {
+			h.writeError(w, protocol.StatusBadRequest, "invalid redirect: want true or false")
+			return
+		}
+	}
+
+	var ts *auth.TokenStore
+	if h.GetTokenStore != nil {
+		ts = h.GetTokenStore()
+	}
+	if ts == nil {
+		h.writeError(w, protocol.StatusNotPermitted, "moving requires auth configuration")
+		return
+	}
+
+	var tokenLabel string
+	for _, p := range []string{req.Path, dest} {
+		label, err := ts.Authorize(req.Metadata["auth"], p, "publish")
+		if err != nil {
+			h.writeAuthError(w, "MOVE", p, err)
+			return
+		}
+		tokenLabel = label
+	}
+
+	// A move adds a version, so it counts against the version limit.
+	if h.MaxVersions > 0 && h.Store.CurrentVersion(req.Path) >= h.MaxVersions {
+		h.logger().Info("move rejected", "audit", true, "operation", "MOVE", "path", sanitize(req.Path), "destination", sanitize(dest), "token_label", sanitize(tokenLabel), "success", false, "reason", "version limit")
+		h.writeVersionLimit(w, req.Path)
+		return
+	}
+
+	doc, err := h.Store.Move(req.Path, dest, redirect)
+	if err != nil {
+		switch {
+		case os.IsNotExist(err):
+			h.logger().Info("not found", "path", sanitize(req.Path))
+			h.writeError(w, protocol.StatusNotFound, req.Path+" not found")
+		case errors.Is(err, store.ErrArchived):
+			h.writeError(w, protocol.StatusArchived, "document is archived; unarchive first")
+		case errors.Is(err, store.ErrDestinationExists):
+			h.logger().Info("move rejected", "audit", true, "operation", "MOVE", "path", sanitize(req.Path), "destination", sanitize(dest), "token_label", sanitize(tokenLabel), "success", false, "reason", "destination exists")
+			h.writeError(w, protocol.StatusConflict, "a document already exists at "+dest)
+		default:
+			h.logger().Error("move failed", "path", sanitize(req.Path), "destination", sanitize(dest), "error", err)
+			h.writeError(w, protocol.StatusServerError, "internal error")
+		}
+		return
+	}
+
+	h.refreshTOCsFor(req.Path)
+	h.refreshTOCsFor(dest)
+
+	h.logger().Info("move", "audit", true, "operation", "MOVE", "path", sanitize(req.Path), "destination", sanitize(dest), "version", doc.Version, "redirect", redirect, "token_label", sanitize(tokenLabel), "success", true)
+	resp := protocol.Response{
+		Status: protocol.StatusOK,
+		Metadata: map[string]string{
+			"version":    strconv.Itoa(doc.Version),
+			"location":   dest,
+			"moved-from": doc.MovedFrom,
+		},
+	}
+	h.writeResponse(w, resp)
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/latebit/demarkus/protocol"
+	"github.com/latebit/demarkus/server/internal/auth"
+	"github.com/latebit/demarkus/server/internal/store"
+)
+
+// handlePurge serves PURGE: it deletes a document and its whole version
+// history, which archiving keeps. Purging destroys history, so it needs a
+// token granting the delete operation; publish is not enough.
+func (h *Handler) handlePurge(w io.Writer, req protocol.Request) {
+	if h.Store == nil {
+		h.writeError(w, protocol.StatusServerError, "purging not configured")
+		return
+	}
+	if _, ok := isHashPath(req.Path); ok {
+		h.writeError(w, protocol.StatusBadRequest, "paths matching /sha256-<hash> are reserved")
+		return
+	}
+	if h.isTOCPath(req.Path) {
+		h.writeError(w, protocol.StatusBadRequest, req.Path+" is generated by the server")
+		return
+	}
+
+	var ts *auth.TokenStore
+	if h.GetTokenStore != nil {
+		ts = h.GetTokenStore()
+	}
+	if ts == nil {
+		h.writeError(w, protocol.StatusNotPermitted, "purging requires auth configuration")
+		return
+	}
+
+	tokenLabel, err := ts.Authorize(req.Metadata["auth"], req.Path, "delete")
+	if err != nil {
+		h.writeAuthError(w, "PURGE", req.Path, err)
+		return
+	}
+
+	version := h.Store.CurrentVersion(req.Path)
+	tomb, err := h.Store.Purge(req.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			h.logger().Info("not found", "path", sanitize(req.Path))
+			h.writeError(w, protocol.StatusNotFound, req.Path+" not found")
+			return
+		}
+		h.logger().Error("purge failed", "path", sanitize(req.Path), "error", err)
+		h.writeError(w, protocol.StatusServerError, "internal error")
+		return
+	}
+
+	h.refreshTOCsFor(req.Path)
+
+	h.logger().Info("purge", "audit", true, "operation", "PURGE", "path", sanitize(req.Path), "version", version, "versions_deleted", tomb.Versions, "last_hash", tomb.LastHash, "token_label", sanitize(tokenLabel), "success", true)
+	resp := protocol.Response{
+		Status: protocol.StatusOK,
+		Metadata: map[string]string{
+			"version":         strconv.Itoa(version),
+			"purged":          tomb.Purged.Format(time.RFC3339),
+			"purged-versions": strconv.Itoa(tomb.Versions),
+		},
+	}
+	h.writeResponse(w, resp)
+}
+
+// writePurged answers VERSIONS for a document whose history was purged:
+// not-found, since there is nothing to list, but saying what there was.
+func (h *Handler) writePurged(w io.Writer, reqPath string, tomb *store.Tombstone) {
+	purged := tomb.Purged.Format(time.RFC3339)
+	resp := protocol.Response{
+		Status: protocol.StatusNotFound,
+		Metadata: map[string]string{
+			"purged":          purged,
+			"purged-versions": strconv.Itoa(tomb.Versions),
+		},
+		Body: fmt.Sprintf("\n# Purged\n\n%s had %d versions; they were deleted at %s.\n\nThe last version hashed to `%s`.\n",
+			escapeMD(reqPath), tomb.Versions, purged, tomb.LastHash),
+	}
+	h.writeResponse(w, resp)
+}
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// wordsPerMinute is the reading speed reading-time assumes.
+const wordsPerMinute = 200
+
+// addReadingStats sets the word-count and reading-time metadata of a
+// document's markdown body, so a reader can judge its length before
+// reading it. Attachments have no words to count.
+func addReadingStats(meta map[string]string, body string) {
+	if !isInline(meta["content-type"]) {
+		return
+	}
+	words := countWords(body)
+	meta["word-count"] = strconv.Itoa(words)
+	meta["reading-time"] = strconv.Itoa(readingMinutes(words))
+}
+
+// countWords counts the whitespace-separated words of a markdown body
+// that hold a letter or digit, so markup such as "#", "-" or "|" does not
+// count.
+func countWords(body string) int {
+	n := 0
+	for field := range strings.FieldsSeq(body) {
+		if strings.IndexFunc(field, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) >= 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// readingMinutes estimates the whole minutes words take to read, rounded
+// up: 0 only for no words at all.
+func readingMinutes(words int) int {
+	return (words + wordsPerMinute - 1) / wordsPerMinute
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/latebit/demarkus/protocol"
+)
+
+// Redirect is an operator's rule answering reads of a path with moved.
+// A From ending in "/" covers the directory and everything under it; any
+// other From covers the document and its version paths, such as
+// /old.md/v2. The rest of the request path after From is appended to To.
+type Redirect struct {
+	From string // absolute path
+	To   string // absolute path or mark:// URL
+}
+
+// ParseRedirects parses FROM=TO redirect rules, as configured.
+func ParseRedirects(rules []string) ([]Redirect, error) {
+	redirects := make([]Redirect, 0, len(rules))
+	for _, rule := range rules {
+		from, to, ok := strings.Cut(rule, "=")
+		if !ok || from == "" || to == "" {
+			return nil, fmt.Errorf("%q is not FROM=TO", rule)
+		}
+		if !strings.HasPrefix(from, "/") || containsDotDot(from) || strings.ContainsAny(from, "*?[") {
+			return nil, fmt.Errorf("%q: FROM must be an absolute path without wildcards", rule)
+		}
+		if !strings.HasPrefix(to, "/") && !strings.HasPrefix(to, "mark://") {
+			return nil, fmt.Errorf("%q: TO must be an absolute path or a mark:// URL", rule)
+		}
+		if strings.HasSuffix(from, "/") && !strings.HasSuffix(to, "/") {
+			return nil, fmt.Errorf("%q: a directory must redirect to a directory, ending in /", rule)
+		}
+		redirects = append(redirects, Redirect{From: from, To: to})
+	}
+	return redirects, nil
+}
+
+// match returns where r sends reqPath, if it covers it.
+func (r Redirect) match(reqPath string) (string, bool) {
+	if strings.HasSuffix(r.From, "/") {
+		if rest, ok := strings.CutPrefix(reqPath, r.From); ok {
+			return r.To + rest, true
+		}
+		if reqPath+"/" == r.From {
+			return r.To, true
+		}
+		return "", false
+	}
+	if reqPath == r.From {
+		return r.To, true
+	}
+	if rest, ok := strings.CutPrefix(reqPath, r.From+"/"); ok {
+		return r.To + "/" + rest, true
+	}
+	return "", false
+}
+
+// redirectConfigured answers a read covered by one of the configured
+// redirects, the first that matches, with moved, and reports whether it
+// did. Unlike aliases, redirects win over documents that exist.
+func (h *Handler) redirectConfigured(w io.Writer, req protocol.Request) bool {
+	switch req.Verb {
+	case protocol.VerbFetch, protocol.VerbInfo, protocol.VerbList, protocol.VerbVersions, protocol.VerbDiff:
+	default:
+		return false
+	}
+	for _, r := range h.Redirects {
+		if location, ok := r.match(req.Path); ok {
+			h.logger().Info("redirect", "path", sanitize(req.Path), "location", sanitize(location))
+			h.writeResponse(w, protocol.Response{
+				Status:   protocol.StatusMoved,
+				Metadata: map[string]string{"location": location},
+			})
+			return true
+		}
+	}
+	return false
+}
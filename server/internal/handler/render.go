@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/latebit/demarkus/protocol"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// htmlContentType is the content-type of a document rendered for a request
+// that accepts text/html.
+const htmlContentType = "text/html; charset=utf-8"
+
+// markdownRenderer converts documents to HTML. goldmark's default mode is
+// the sanitizer: raw HTML in the markdown is omitted and links with
+// dangerous schemes (javascript:, vbscript:, file:, data:) are dropped.
+var markdownRenderer = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+// dispositionAttachment is the disposition metadata value marking a body
+// clients should offer as a download rather than render. Without the key
+// a body is inline, just as without content-type it is markdown.
+const dispositionAttachment = "attachment"
+
+// inlineTypes are the media types a client may render in place. Anything
+// else a publisher declares, including raw text/html, is only safe to
+// save, since the server cannot vouch for what rendering it would do.
+var inlineTypes = map[string]bool{
+	"text/markdown": true,
+	"text/plain":    true,
+}
+
+// isInline reports whether a stored document with the given content-type
+// metadata may be rendered in place; none means markdown.
+func isInline(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	return inlineTypes[strings.ToLower(strings.TrimSpace(mediaType))]
+}
+
+// isText reports whether a stored document with the given content-type
+// metadata is text, and so can be rendered as markdown; none means
+// markdown.
+func isText(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	return mediaType == "" || strings.HasPrefix(mediaType, "text/")
+}
+
+// acceptsHTML reports whether the request's accept metadata, a comma
+// separated list of media types, includes text/html.
+func acceptsHTML(req protocol.Request) bool {
+	for mediaType := range strings.SplitSeq(req.Metadata["accept"], ",") {
+		mediaType, _, _ = strings.Cut(mediaType, ";")
+		if strings.EqualFold(strings.TrimSpace(mediaType), "text/html") {
+			return true
+		}
+	}
+	return false
+}
+
+// writeDocument writes a successful FETCH response, rendered to HTML when
+// the request asks for it and the document is text; assets such as images
+// are always sent as the bytes stored. etag and content-hash keep describing the
+// markdown, so conditional and content-addressed requests work the same
+// for either representation. The disposition metadata tells clients
+// whether the body is safe to render or should be treated as a download.
+// content-sha256, and with a signing key the signature, cover the body
+// as served before INFO or a range trims it.
+// For INFO the body is left out and its length given as size instead. A
+// FETCH with range metadata gets only the bytes asked for, as a partial
+// response; an empty body has no bytes to select and is sent whole.
+func (h *Handler) writeDocument(w io.Writer, req protocol.Request, resp protocol.Response) {
+	if !isInline(resp.Metadata["content-type"]) {
+		resp.Metadata["disposition"] = dispositionAttachment
+	}
+	if isText(resp.Metadata["content-type"]) && acceptsHTML(req) {
+		var buf bytes.Buffer
+		if err := markdownRenderer.Convert([]byte(resp.Body), &buf); err != nil {
+			h.logger().Error("render html failed", "path", sanitize(req.Path), "error", err)
+			h.writeError(w, protocol.StatusServerError, "internal error")
+			return
+		}
+		resp.Body = buf.String()
+		resp.Metadata["content-type"] = htmlContentType
+		delete(resp.Metadata, "disposition")
+	}
+	digest := protocol.ContentSHA256(resp.Body)
+	resp.Metadata[protocol.MetaContentSHA256] = digest
+	if h.SigningKey != nil {
+		resp.Metadata[protocol.MetaSignature] = protocol.Sign(h.SigningKey, req.Path, digest)
+		resp.Metadata[protocol.MetaKeyID] = protocol.KeyID(h.SigningKey.Public().(ed25519.PublicKey))
+	}
+	resp.Metadata["content-length"] = strconv.Itoa(len(resp.Body))
+	if req.Verb == protocol.VerbInfo {
+		resp.Body = ""
+	} else if rng := req.Metadata["range"]; rng != "" && resp.Body != "" {
+		first, last, err := protocol.ParseRange(rng, len(resp.Body))
+		if err != nil {
+			h.writeError(w, protocol.StatusBadRequest, err.Error())
+			return
+		}
+		resp.Status = protocol.StatusPartial
+		resp.Metadata["content-range"] = protocol.FormatContentRange(first, last, len(resp.Body))
+		resp.Body = resp.Body[first : last+1]
+	}
+	h.writeResponse(w, resp)
+}
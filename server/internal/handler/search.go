@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/latebit/demarkus/protocol"
+	"github.com/latebit/demarkus/server/internal/auth"
+	"github.com/latebit/demarkus/server/internal/store"
+)
+
+// DefaultSearchResults is the number of results SEARCH returns when the
+// request has no limit metadata; MaxSearchResults caps the limit.
+const (
+	DefaultSearchResults = 20
+	MaxSearchResults     = 100
+)
+
+// handleSearch serves SEARCH: the current documents under the requested
+// directory whose title or body contain every word of the query metadata,
+// and whose metadata matches its tag:, author: and date: filters, best
+// first. Denied documents and read-protected ones the request's token
+// cannot read are left out, as if they did not exist, and so are generated
+// tables of contents.
+func (h *Handler) handleSearch(w io.Writer, req protocol.Request) {
+	if !h.authorizeRead(w, req) {
+		return
+	}
+	query := strings.TrimSpace(req.Metadata["query"])
+	if query == "" {
+		h.writeError(w, protocol.StatusBadRequest, "SEARCH requires query metadata")
+		return
+	}
+	limit := DefaultSearchResults
+	if l := req.Metadata["limit"]; l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > MaxSearchResults {
+			h.writeError(w, protocol.StatusBadRequest, fmt.Sprintf("invalid limit (must be 1-%d)", MaxSearchResults))
+			return
+		}
+		limit = n
+	}
+
+	isDir, err := h.Store.IsDir(req.Path)
+	if err != nil && !os.IsNotExist(err) {
+		h.logger().Error("search failed", "path", sanitize(req.Path), "error", err)
+		h.writeError(w, protocol.StatusServerError, "internal error")
+		return
+	}
+	if !isDir {
+		h.logger().Info("not found", "path", sanitize(req.Path))
+		h.writeError(w, protocol.StatusNotFound, req.Path+" not found")
+		return
+	}
+
+	hits := h.Store.Search(req.Path, query, limit, h.visibleTo(req.Metadata["auth"]))
+
+	resp := protocol.Response{
+		Status: protocol.StatusOK,
+		Metadata: map[string]string{
+			"results": strconv.Itoa(len(hits)),
+		},
+		Body: buildSearchResults(req.Path, query, hits),
+	}
+	h.writeResponse(w, resp)
+}
+
+// visibleTo returns a filter for the documents a request with token may
+// find through SEARCH and BACKLINKS: those it could FETCH, other than
+// generated tables of contents, which repeat the titles of everything they
+// list.
+func (h *Handler) visibleTo(token string) func(reqPath string) bool {
+	var ts *auth.TokenStore
+	if h.GetTokenStore != nil {
+		ts = h.GetTokenStore()
+	}
+	return func(p string) bool {
+		if h.isDenied(p) || h.isTOCPath(p) {
+			return false
+		}
+		if ts == nil || !ts.RequiresReadAuth(p) {
+			return true
+		}
+		_, err := ts.Authorize(token, p, "read")
+		return err == nil
+	}
+}
+
+// buildSearchResults renders hits as a markdown list of links, each
+// followed by the snippet of the document around the match.
+func buildSearchResults(dir, query string, hits []store.SearchHit) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "\n# Results for \"%s\" in %s\n\n", escapeMD(query), escapeMD(dir))
+	if len(hits) == 0 {
+		sb.WriteString("No documents match.\n")
+		return sb.String()
+	}
+	for _, hit := range hits {
+		writeResultLink(&sb, hit.Path, hit.Title)
+		if hit.Snippet != "" {
+			fmt.Fprintf(&sb, "  %s\n", hit.Snippet)
+		}
+	}
+	return sb.String()
+}
+
+// writeResultLink writes a list item linking to the document at docPath,
+// named by its title or, without one, by its file name.
+func writeResultLink(sb *strings.Builder, docPath, title string) {
+	if title == "" {
+		title = strings.TrimSuffix(docPath[strings.LastIndex(docPath, "/")+1:], ".md")
+	}
+	segments := strings.Split(docPath, "/")
+	for i, s := range segments {
+		segments[i] = escapeURL(s)
+	}
+	fmt.Fprintf(sb, "- [%s](%s)\n", escapeMD(title), strings.Join(segments, "/"))
+}
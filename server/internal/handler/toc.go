@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/latebit/demarkus/server/internal/auth"
+	"github.com/latebit/demarkus/server/internal/store"
+)
+
+// tocName is the file name of a generated table of contents. Each
+// directory in TOCPaths gets one, e.g. /docs/_toc.md.
+const tocName = "_toc.md"
+
+// tocMu serializes table of contents rebuilds. It is package-level because
+// the server copies its Handler per connection.
+var tocMu sync.Mutex
+
+// tocPath returns the path of the table of contents for dir.
+func tocPath(dir string) string {
+	return path.Join("/", dir, tocName)
+}
+
+// isTOCPath reports whether reqPath is a generated table of contents,
+// which only the server writes.
+func (h *Handler) isTOCPath(reqPath string) bool {
+	clean := path.Clean("/" + reqPath)
+	for _, dir := range h.TOCPaths {
+		if clean == tocPath(dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// RefreshTOCs regenerates the table of contents of every directory in
+// TOCPaths. Directories that do not exist yet are skipped.
+func (h *Handler) RefreshTOCs() {
+	for _, dir := range h.TOCPaths {
+		h.refreshTOC(dir)
+	}
+}
+
+// refreshTOCsFor regenerates the tables of contents that list reqPath.
+// It is called after a write changes a document's listing.
+func (h *Handler) refreshTOCsFor(reqPath string) {
+	clean := path.Clean("/" + reqPath)
+	for _, dir := range h.TOCPaths {
+		prefix := strings.TrimSuffix(path.Clean("/"+dir), "/") + "/"
+		if strings.HasPrefix(clean, prefix) && clean != tocPath(dir) {
+			h.refreshTOC(dir)
+		}
+	}
+}
+
+// refreshTOC writes dir's table of contents as a new version when it
+// differs from the current one. Failures are logged: a stale table of
+// contents must not fail the write that triggered it.
+func (h *Handler) refreshTOC(dir string) {
+	if h.Store == nil {
+		return
+	}
+	tocMu.Lock()
+	defer tocMu.Unlock()
+
+	if ok, err := h.Store.IsDir(dir); err != nil || !ok {
+		return
+	}
+	body, err := h.buildTOC(dir)
+	if err != nil {
+		h.logger().Error("build table of contents failed", "path", sanitize(dir), "error", err)
+		return
+	}
+	doc, err := h.Store.Write(tocPath(dir), []byte(body), nil)
+	if errors.Is(err, store.ErrNotModified) {
+		return
+	}
+	if err != nil {
+		h.logger().Error("write table of contents failed", "path", sanitize(tocPath(dir)), "error", err)
+		return
+	}
+	h.logger().Info("table of contents updated", "path", sanitize(tocPath(dir)), "version", doc.Version)
+}
+
+// buildTOC renders a markdown page listing every document under dir, one
+// section per directory, with each document's title and description.
+// Denied and archived documents are left out, and so are read-protected
+// ones unless the table of contents is itself read-protected.
+func (h *Handler) buildTOC(dir string) (string, error) {
+	dir = strings.TrimSuffix(path.Clean("/"+dir), "/") + "/"
+	var ts *auth.TokenStore
+	if h.GetTokenStore != nil {
+		ts = h.GetTokenStore()
+	}
+	public := ts == nil || !ts.RequiresReadAuth(tocPath(dir))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Contents of %s\n\n", dir)
+	sb.WriteString("_Generated by the server when a document in this directory is published._\n")
+
+	var walk func(sub string) error
+	walk = func(sub string) error {
+		entries, err := h.Store.ListDir(dir + sub)
+		if err != nil {
+			return err
+		}
+		var items, subdirs []string
+		for _, e := range entries {
+			name := e.Name()
+			rel := sub + name
+			if h.isDenied(dir + rel) {
+				continue
+			}
+			if e.IsDir() {
+				subdirs = append(subdirs, rel+"/")
+				continue
+			}
+			if !strings.HasSuffix(name, ".md") || name == tocName {
+				continue
+			}
+			if public && ts != nil && ts.RequiresReadAuth(dir+rel) {
+				continue
+			}
+			doc, err := h.Store.Get(dir+rel, 0)
+			if err != nil || doc.Archived {
+				continue
+			}
+			items = append(items, tocItem(rel, doc, h.Store.Describe(dir+rel, doc).Title))
+		}
+		if len(items) > 0 {
+			if sub != "" {
+				fmt.Fprintf(&sb, "\n## %s\n", sub)
+			}
+			sb.WriteString("\n" + strings.Join(items, ""))
+		}
+		for _, s := range subdirs {
+			if err := walk(s); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(""); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// tocItem formats one document as a list item linking to rel. The title is
+// the document's, from its DocInfo, else the file name; the description
+// metadata follows it.
+func tocItem(rel string, doc *store.Document, title string) string {
+	if title == "" {
+		title = strings.TrimSuffix(path.Base(rel), ".md")
+	}
+	segments := strings.Split(rel, "/")
+	for i, s := range segments {
+		segments[i] = escapeURL(s)
+	}
+	item := fmt.Sprintf("- [%s](%s)", escapeMD(title), strings.Join(segments, "/"))
+	if desc := doc.Metadata["description"]; desc != "" {
+		item += " — " + desc
+	}
+	return item + "\n"
+}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/latebit/demarkus/protocol"
+	"github.com/latebit/demarkus/server/internal/auth"
+)
+
+// handleTokenInfo serves FETCH /.well-known/token: what the token in the
+// request's auth metadata grants, so that its holder can debug access
+// without trial writes. Only the holder of a token can see it, and an
+// expired token is reported as such rather than as unknown.
+func (h *Handler) handleTokenInfo(w io.Writer, req protocol.Request) {
+	var ts *auth.TokenStore
+	if h.GetTokenStore != nil {
+		ts = h.GetTokenStore()
+	}
+	if ts == nil {
+		h.writeError(w, protocol.StatusNotPermitted, "this server has no tokens configured")
+		return
+	}
+
+	tok, err := ts.Lookup(req.Metadata["auth"])
+	if errors.Is(err, auth.ErrTokenExpired) {
+		h.logger().Warn("expired token checked", "label", sanitize(tok.Label))
+		expires := tok.ExpiresAt().UTC().Format(time.RFC3339)
+		resp := protocol.Response{
+			Status:   protocol.StatusUnauthorized,
+			Metadata: map[string]string{"token-expires": expires},
+			Body:     fmt.Sprintf("\n# Unauthorized\n\nThe token expired at %s.\n", expires),
+		}
+		h.writeResponse(w, resp)
+		return
+	}
+	if err != nil {
+		h.writeAuthError(w, req.Verb, req.Path, err)
+		return
+	}
+
+	meta := map[string]string{
+		"token-label":      sanitize(tok.Label),
+		"token-operations": strings.Join(tok.Operations, ","),
+		"token-paths":      strings.Join(tok.Paths, ","),
+	}
+	expires := "never"
+	if at := tok.ExpiresAt(); !at.IsZero() {
+		expires = at.UTC().Format(time.RFC3339)
+		meta["token-expires"] = expires
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "\n# Token %s\n\n", escapeMD(sanitize(tok.Label)))
+	fmt.Fprintf(&sb, "- Operations: %s\n", strings.Join(tok.Operations, ", "))
+	sb.WriteString("- Paths:")
+	for i, p := range tok.Paths {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		fmt.Fprintf(&sb, " `%s`", p)
+	}
+	fmt.Fprintf(&sb, "\n- Expires: %s\n", expires)
+
+	h.writeResponse(w, protocol.Response{Status: protocol.StatusOK, Metadata: meta, Body: sb.String()})
+}
@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/latebit/demarkus/protocol"
+)
+
+// trailerStream is a stream whose client asked for a response trailer.
+// writeResponse recognizes it and appends the body hash and the time
+// spent since the stream was accepted.
+type trailerStream struct {
+	Stream
+	start time.Time
+}
+
+// withTrailers wraps stream when the request accepts trailers. Clients
+// that do not ask get none, since they would read it as part of the body.
+func withTrailers(stream Stream, req protocol.Request, start time.Time) Stream {
+	if req.Metadata["accept-trailers"] != "true" {
+		return stream
+	}
+	return &trailerStream{Stream: stream, start: start}
+}
+
+// trailer returns the trailer for a response with body.
+func (s *trailerStream) trailer(body string) map[string]string {
+	elapsed := float64(time.Since(s.start).Microseconds()) / 1000
+	return map[string]string{
+		protocol.TrailerBodyHash: protocol.BodyHash(body),
+		protocol.TrailerElapsed:  strconv.FormatFloat(elapsed, 'f', 3, 64),
+	}
+}
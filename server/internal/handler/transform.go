@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"github.com/latebit/demarkus/protocol"
+	"github.com/latebit/demarkus/server/internal/store"
+	"github.com/latebit/demarkus/server/internal/transform"
+)
+
+// transformsFor returns the transforms that apply to doc as served for req:
+// all of h.Transforms for a text document fetched by its path, none for an
+// asset or a content-addressed FETCH, whose body must match its hash.
+// Versions fetched by number never reach here, so that their bodies can be
+// checked against the hash chain.
+func (h *Handler) transformsFor(req protocol.Request, doc *store.Document) []transform.Transform {
+	if _, ok := isHashPath(req.Path); ok || !isText(doc.Metadata["content-type"]) {
+		return nil
+	}
+	return h.Transforms
+}
+
+// transformedEtag is the etag of doc served through pipeline: etag, that
+// of the stored file, or for a non-empty pipeline a hash of the file and
+// the pipeline's key, so that changing the configuration changes the etag.
+func transformedEtag(doc *store.Document, etag string, pipeline []transform.Transform) string {
+	if len(pipeline) == 0 {
+		return etag
+	}
+	data := make([]byte, 0, len(doc.Content)+1+len(transform.Key(pipeline)))
+	data = append(data, doc.Content...)
+	data = append(data, 0)
+	data = append(data, transform.Key(pipeline)...)
+	return computeEtag(data)
+}
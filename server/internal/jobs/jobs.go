@@ -0,0 +1,146 @@
+// Package jobs runs writes the server has accepted but not yet made, one
+// at a time in the order they were accepted, and keeps the response each
+// one ended with for a while, so that the client can ask for it.
+package jobs
+
+import (
+	"crypto/rand"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/latebit/demarkus/protocol"
+)
+
+// DefaultKeep is how long the result of a finished job is kept.
+const DefaultKeep = 10 * time.Minute
+
+// Errors returned by Submit.
+var (
+	ErrFull   = errors.New("job queue is full")
+	ErrClosed = errors.New("job queue is closed")
+)
+
+type job struct {
+	path     string
+	run      func() protocol.Response
+	done     bool
+	result   protocol.Response
+	finished time.Time
+}
+
+// Queue runs jobs in the background with a single worker.
+type Queue struct {
+	keep time.Duration
+	now  func() time.Time
+
+	mu     sync.Mutex
+	jobs   map[string]*job
+	closed bool
+
+	pending chan string
+	stopped chan struct{}
+}
+
+// New returns a queue holding up to size jobs not yet run, and starts its
+// worker. The result of a finished job is kept for keep.
+func New(size int, keep time.Duration) *Queue {
+	q := &Queue{
+		keep:    keep,
+		now:     time.Now,
+		jobs:    make(map[string]*job),
+		pending: make(chan string, size),
+		stopped: make(chan struct{}),
+	}
+	go q.work()
+	return q
+}
+
+// Submit queues run, a write to path, and returns the job's ID: random,
+// so that it cannot be guessed. It returns ErrFull if size jobs are
+// waiting already, and ErrClosed once Close has been called.
+func (q *Queue) Submit(path string, run func() protocol.Response) (string, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return "", ErrClosed
+	}
+	q.expireLocked()
+	id := rand.Text()
+	select {
+	case q.pending <- id:
+	default:
+		return "", ErrFull
+	}
+	q.jobs[id] = &job{path: path, run: run}
+	return id, nil
+}
+
+// Result returns the response the job id ended with, if it has finished.
+// ok is false if there is no such job for path, or its result has expired.
+func (q *Queue) Result(id, path string) (resp protocol.Response, done, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.expireLocked()
+	j, ok := q.jobs[id]
+	if !ok || j.path != path {
+		return protocol.Response{}, false, false
+	}
+	return j.result, j.done, true
+}
+
+// Len returns the number of jobs not yet finished.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	n := 0
+	for _, j := range q.jobs {
+		if !j.done {
+			n++
+		}
+	}
+	return n
+}
+
+// Close stops accepting jobs and waits for those already accepted to
+// finish.
+func (q *Queue) Close() {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		<-q.stopped
+		return
+	}
+	q.closed = true
+	close(q.pending)
+	q.mu.Unlock()
+	<-q.stopped
+}
+
+func (q *Queue) work() {
+	defer close(q.stopped)
+	for id := range q.pending {
+		q.mu.Lock()
+		j := q.jobs[id]
+		q.mu.Unlock()
+
+		resp := j.run()
+
+		q.mu.Lock()
+		j.run = nil
+		j.done = true
+		j.result = resp
+		j.finished = q.now()
+		q.mu.Unlock()
+	}
+}
+
+// expireLocked forgets the jobs that finished more than keep ago.
+func (q *Queue) expireLocked() {
+	cutoff := q.now().Add(-q.keep)
+	for id, j := range q.jobs {
+		if j.done && j.finished.Before(cutoff) {
+			delete(q.jobs, id)
+		}
+	}
+}
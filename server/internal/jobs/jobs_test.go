@@ -0,0 +1,96 @@
+package jobs
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/latebit/demarkus/protocol"
+)
+
+func TestQueue(t *testing.T) {
+	q := New(2, time.Minute)
+	release := make(chan struct{})
+	var (
+		mu    sync.Mutex
+		order []string
+	)
+	submit := func(path string) string {
+		t.Helper()
+		id, err := q.Submit(path, func() protocol.Response {
+			<-release
+			mu.Lock()
+			order = append(order, path)
+			mu.Unlock()
+			return protocol.Response{Status: protocol.StatusCreated, Metadata: map[string]string{"version": "1"}}
+		})
+		if err != nil {
+			t.Fatalf("submit %s: %v", path, err)
+		}
+		return id
+	}
+
+	// The first job is taken by the worker and blocks it, so two more
+	// fill the queue.
+	a := submit("/a.md")
+	for q.Len() != 1 || len(q.pending) != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	b := submit("/b.md")
+	submit("/c.md")
+	if _, err := q.Submit("/d.md", nil); !errors.Is(err, ErrFull) {
+		t.Errorf("full queue: err %v", err)
+	}
+	if a == b || len(a) < 16 {
+		t.Errorf("ids %q and %q", a, b)
+	}
+	if _, done, ok := q.Result(a, "/a.md"); !ok || done {
+		t.Errorf("pending job: done %v, ok %v", done, ok)
+	}
+	if _, _, ok := q.Result(a, "/b.md"); ok {
+		t.Error("job found under another path")
+	}
+	if _, _, ok := q.Result("nope", "/a.md"); ok {
+		t.Error("unknown job found")
+	}
+
+	close(release)
+	q.Close()
+	if got := order; len(got) != 3 || got[0] != "/a.md" || got[1] != "/b.md" || got[2] != "/c.md" {
+		t.Errorf("run order %v", got)
+	}
+	resp, done, ok := q.Result(b, "/b.md")
+	if !ok || !done || resp.Status != protocol.StatusCreated || resp.Metadata["version"] != "1" {
+		t.Errorf("finished job: %+v, done %v, ok %v", resp, done, ok)
+	}
+	if q.Len() != 0 {
+		t.Errorf("Len = %d after Close", q.Len())
+	}
+	if _, err := q.Submit("/e.md", nil); !errors.Is(err, ErrClosed) {
+		t.Errorf("closed queue: err %v", err)
+	}
+}
+
+func TestQueueExpiry(t *testing.T) {
+	q := New(1, time.Minute)
+	now := time.Now()
+	q.mu.Lock()
+	q.now = func() time.Time { return now }
+	q.mu.Unlock()
+	id, err := q.Submit("/a.md", func() protocol.Response { return protocol.Response{Status: protocol.StatusOK} })
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, done, _ := q.Result(id, "/a.md"); !done; _, done, _ = q.Result(id, "/a.md") {
+		time.Sleep(time.Millisecond)
+	}
+
+	q.mu.Lock()
+	now = now.Add(time.Minute + time.Second)
+	q.mu.Unlock()
+	if _, _, ok := q.Result(id, "/a.md"); ok {
+		t.Error("expired result still kept")
+	}
+	q.Close()
+}
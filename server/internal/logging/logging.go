@@ -13,6 +13,12 @@ import (
 // level: "debug", "info" (default), "warn", "error".
 // If w is nil, os.Stderr is used.
 func New(format, level string, w io.Writer) *slog.Logger {
+	return NewWithPrivacy(format, level, w, Privacy{})
+}
+
+// NewWithPrivacy is like New but rewrites "ip" and "path" attributes
+// according to p before they are written.
+func NewWithPrivacy(format, level string, w io.Writer, p Privacy) *slog.Logger {
 	if w == nil {
 		w = os.Stderr
 	}
@@ -30,6 +36,9 @@ func New(format, level string, w io.Writer) *slog.Logger {
 	}
 
 	opts := &slog.HandlerOptions{Level: lvl}
+	if p.enabled() {
+		opts.ReplaceAttr = p.replaceAttr
+	}
 
 	var handler slog.Handler
 	switch strings.ToLower(format) {
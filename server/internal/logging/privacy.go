@@ -0,0 +1,115 @@
+package logging
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"strings"
+)
+
+// IP anonymization modes.
+const (
+	IPFull     = "full"     // log client IPs as-is
+	IPTruncate = "truncate" // zero the host part: /24 for IPv4, /48 for IPv6
+	IPHash     = "hash"     // salted hash, stable only for the life of the process
+)
+
+// redacted replaces the part of a path below a sensitive prefix.
+const redacted = "[redacted]"
+
+// Privacy controls how client IPs and request paths appear in logs. The
+// zero value logs everything unchanged.
+type Privacy struct {
+	IPMode         string   // IPFull (or empty), IPTruncate or IPHash
+	RedactPrefixes []string // paths under these prefixes are logged as prefix + "/[redacted]"
+	salt           []byte
+}
+
+// NewPrivacy validates mode and returns a Privacy with a fresh random salt,
+// so hashed IPs cannot be correlated across restarts.
+func NewPrivacy(mode string, redactPrefixes []string) (Privacy, error) {
+	switch mode {
+	case "", IPFull, IPTruncate, IPHash:
+	default:
+		return Privacy{}, fmt.Errorf("unknown IP mode %q (want %s, %s or %s)", mode, IPFull, IPTruncate, IPHash)
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return Privacy{}, fmt.Errorf("generate salt: %w", err)
+	}
+	return Privacy{IPMode: mode, RedactPrefixes: redactPrefixes, salt: salt}, nil
+}
+
+// IP returns ip as it should be logged or used as a rate-limiter key.
+// In truncate mode, values that do not parse as an IP become "invalid".
+func (p Privacy) IP(ip string) string {
+	switch p.IPMode {
+	case IPTruncate:
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			return "invalid"
+		}
+		bits := 48
+		if addr.Unmap().Is4() {
+			addr, bits = addr.Unmap(), 24
+		}
+		prefix, _ := addr.Prefix(bits)
+		return prefix.String()
+	case IPHash:
+		h := sha256.New()
+		h.Write(p.salt)
+		h.Write([]byte(ip))
+		return hex.EncodeToString(h.Sum(nil)[:6])
+	default:
+		return ip
+	}
+}
+
+// Path returns reqPath with anything below a sensitive prefix removed.
+func (p Privacy) Path(reqPath string) string {
+	for _, prefix := range p.RedactPrefixes {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if reqPath == prefix || strings.HasPrefix(reqPath, prefix+"/") {
+			return prefix + "/" + redacted
+		}
+	}
+	return reqPath
+}
+
+// Location returns loc, a path or a mark:// URL such as a redirect's
+// target, with its path redacted as by Path.
+func (p Privacy) Location(loc string) string {
+	rest, ok := strings.CutPrefix(loc, "mark://")
+	if !ok {
+		return p.Path(loc)
+	}
+	host, reqPath, ok := strings.Cut(rest, "/")
+	if !ok {
+		return loc
+	}
+	return "mark://" + host + p.Path("/"+reqPath)
+}
+
+// replaceAttr applies p to the "ip", "path", "destination" and
+// "location" attributes of every record.
+func (p Privacy) replaceAttr(_ []string, a slog.Attr) slog.Attr {
+	if a.Value.Kind() != slog.KindString {
+		return a
+	}
+	switch a.Key {
+	case "ip":
+		return slog.String(a.Key, p.IP(a.Value.String()))
+	case "path", "destination":
+		return slog.String(a.Key, p.Path(a.Value.String()))
+	case "location":
+		return slog.String(a.Key, p.Location(a.Value.String()))
+	}
+	return a
+}
+
+func (p Privacy) enabled() bool {
+	return (p.IPMode != "" && p.IPMode != IPFull) || len(p.RedactPrefixes) > 0
+}
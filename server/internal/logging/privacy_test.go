@@ -0,0 +1,112 @@
+package logging
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestNewPrivacyRejectsUnknownMode(t *testing.T) {
+	if _, err := NewPrivacy("scramble", nil); err == nil {
+		t.Fatal("expected error for unknown IP mode")
+	}
+}
+
+func TestPrivacyIP(t *testing.T) {
+	truncate, err := NewPrivacy(IPTruncate, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"203.0.113.77", "203.0.113.0/24"},
+		{"::ffff:203.0.113.77", "203.0.113.0/24"},
+		{"2001:db8:abcd:12::1", "2001:db8:abcd::/48"},
+		{"not-an-ip", "invalid"},
+	}
+	for _, tt := range tests {
+		if got := truncate.IP(tt.ip); got != tt.want {
+			t.Errorf("truncate %q: got %q, want %q", tt.ip, got, tt.want)
+		}
+	}
+
+	hash, err := NewPrivacy(IPHash, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := hash.IP("203.0.113.77"), hash.IP("203.0.113.78")
+	if a == b || strings.Contains(a, "203") {
+		t.Errorf("hash: got %q and %q, want distinct opaque values", a, b)
+	}
+	if again := hash.IP("203.0.113.77"); again != a {
+		t.Errorf("hash not stable: got %q then %q", a, again)
+	}
+	other, _ := NewPrivacy(IPHash, nil)
+	if other.IP("203.0.113.77") == a {
+		t.Error("hash identical across salts")
+	}
+
+	if got := (Privacy{}).IP("203.0.113.77"); got != "203.0.113.77" {
+		t.Errorf("zero value: got %q, want unchanged", got)
+	}
+}
+
+func TestPrivacyPath(t *testing.T) {
+	p := Privacy{RedactPrefixes: []string{"/medical/", "/hr"}}
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/medical/alice.md", "/medical/[redacted]"},
+		{"/medical", "/medical/[redacted]"},
+		{"/hr/reviews/2026.md", "/hr/[redacted]"},
+		{"/hrdocs/a.md", "/hrdocs/a.md"},
+		{"/index.md", "/index.md"},
+	}
+	for _, tt := range tests {
+		if got := p.Path(tt.path); got != tt.want {
+			t.Errorf("Path(%q): got %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestPrivacyLocation(t *testing.T) {
+	p := Privacy{RedactPrefixes: []string{"/medical/"}}
+	tests := []struct {
+		loc  string
+		want string
+	}{
+		{"/medical/alice.md", "/medical/[redacted]"},
+		{"mark://docs.example.com/medical/alice.md", "mark://docs.example.com/medical/[redacted]"},
+		{"mark://docs.example.com:6309/index.md", "mark://docs.example.com:6309/index.md"},
+		{"mark://docs.example.com", "mark://docs.example.com"},
+	}
+	for _, tt := range tests {
+		if got := p.Location(tt.loc); got != tt.want {
+			t.Errorf("Location(%q): got %q, want %q", tt.loc, got, tt.want)
+		}
+	}
+}
+
+func TestNewWithPrivacy(t *testing.T) {
+	p, err := NewPrivacy(IPTruncate, []string{"/medical"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	logger := NewWithPrivacy("text", "info", &buf, p)
+	logger.Info("request", "ip", "198.51.100.9", "path", "/medical/bob.md")
+	logger.Info("move", "path", "/inbox/note.md", "destination", "/medical/bob.md")
+	logger.Info("alias", "path", "/old.md", "location", "/medical/bob.md")
+	logger.Info("redirect", "path", "/old.md", "location", "mark://docs.example.com/medical/bob.md")
+
+	out := buf.String()
+	if strings.Contains(out, "198.51.100.9") || strings.Contains(out, "bob") {
+		t.Errorf("log leaks raw values: %q", out)
+	}
+	if !strings.Contains(out, "ip=198.51.100.0/24") || !strings.Contains(out, "path=/medical/[redacted]") || !strings.Contains(out, "destination=/medical/[redacted]") || !strings.Contains(out, "location=/medical/[redacted]") || !strings.Contains(out, "location=mark://docs.example.com/medical/[redacted]") {
+		t.Errorf("log missing anonymized values: %q", out)
+	}
+}
@@ -0,0 +1,237 @@
+package ratelimit
+
+import (
+	"bufio"
+	"cmp"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Strike reasons recorded against a client.
+const (
+	ReasonRateLimit    = "rate-limit"
+	ReasonInvalidToken = "invalid-token"
+)
+
+// BanList temporarily bans clients that collect too many strikes within a
+// window. Active bans are written to a file, when one is configured, so
+// they survive restarts.
+type BanList struct {
+	path      string
+	threshold int
+	window    time.Duration
+	duration  time.Duration
+	now       func() time.Time
+
+	mu      sync.Mutex
+	strikes map[string][]time.Time    // recent strikes per client, oldest first
+	bans    map[string]time.Time      // client -> ban expiry
+	counts  map[string]map[string]int // strikes per client and reason since the last Summary
+}
+
+// Offender summarizes one client's strikes since the previous Summary.
+type Offender struct {
+	IP          string         `json:"ip"`
+	Strikes     map[string]int `json:"strikes"`               // by reason
+	BannedUntil time.Time      `json:"banned_until,omitzero"` // zero if not banned
+}
+
+// NewBanList creates a BanList that bans a client for duration once it has
+// threshold strikes within window. path names the file bans are persisted
+// to; empty keeps them in memory only. Unexpired bans in an existing file
+// are loaded.
+func NewBanList(path string, threshold int, window, duration time.Duration) (*BanList, error) {
+	b := &BanList{
+		path:      path,
+		threshold: threshold,
+		window:    window,
+		duration:  duration,
+		now:       time.Now,
+		strikes:   make(map[string][]time.Time),
+		bans:      make(map[string]time.Time),
+		counts:    make(map[string]map[string]int),
+	}
+	if path == "" {
+		return b, nil
+	}
+	if err := b.load(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Banned reports whether ip is banned, and until when.
+func (b *BanList) Banned(ip string) (time.Time, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	until, ok := b.bans[ip]
+	if !ok {
+		return time.Time{}, false
+	}
+	if !b.now().Before(until) {
+		delete(b.bans, ip)
+		return time.Time{}, false
+	}
+	return until, true
+}
+
+// Active returns the number of unexpired bans.
+func (b *BanList) Active() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	n := 0
+	for _, until := range b.bans {
+		if now.Before(until) {
+			n++
+		}
+	}
+	return n
+}
+
+// Strike records an offence by ip and reports whether it caused a new ban.
+// Strikes against a banned client are counted for Summary but do not
+// extend the ban.
+func (b *BanList) Strike(ip, reason string) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	if b.counts[ip] == nil {
+		b.counts[ip] = make(map[string]int)
+	}
+	b.counts[ip][reason]++
+
+	if until, ok := b.bans[ip]; ok && now.Before(until) {
+		return false, nil
+	}
+
+	cutoff := now.Add(-b.window)
+	recent := b.strikes[ip]
+	for len(recent) > 0 && recent[0].Before(cutoff) {
+		recent = recent[1:]
+	}
+	recent = append(recent, now)
+	if len(recent) < b.threshold {
+		b.strikes[ip] = recent
+		return false, nil
+	}
+
+	delete(b.strikes, ip)
+	b.bans[ip] = now.Add(b.duration)
+	return true, b.save()
+}
+
+// Summary returns the clients with strikes since the previous call, most
+// strikes first, and starts a new period. It also forgets expired bans and
+// strikes older than the window.
+func (b *BanList) Summary() []Offender {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	for ip, until := range b.bans {
+		if !now.Before(until) {
+			delete(b.bans, ip)
+		}
+	}
+	cutoff := now.Add(-b.window)
+	for ip, recent := range b.strikes {
+		if len(recent) == 0 || recent[len(recent)-1].Before(cutoff) {
+			delete(b.strikes, ip)
+		}
+	}
+
+	offenders := make([]Offender, 0, len(b.counts))
+	for ip, strikes := range b.counts {
+		offenders = append(offenders, Offender{IP: ip, Strikes: strikes, BannedUntil: b.bans[ip]})
+	}
+	b.counts = make(map[string]map[string]int)
+
+	total := func(o Offender) int {
+		n := 0
+		for _, c := range o.Strikes {
+			n += c
+		}
+		return n
+	}
+	slices.SortFunc(offenders, func(a, b Offender) int {
+		return cmp.Or(cmp.Compare(total(b), total(a)), strings.Compare(a.IP, b.IP))
+	})
+	return offenders
+}
+
+// load reads "<ip> <RFC 3339 expiry>" lines, skipping expired bans.
+// A missing file is not an error.
+func (b *BanList) load() error {
+	f, err := os.Open(b.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading ban list: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	now := b.now()
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ip, expiry, ok := strings.Cut(line, " ")
+		until, err := time.Parse(time.RFC3339, strings.TrimSpace(expiry))
+		if !ok || err != nil {
+			return fmt.Errorf("ban list %s:%d: want \"<ip> <RFC 3339 expiry>\", got %q", b.path, n, line)
+		}
+		if now.Before(until) {
+			b.bans[ip] = until
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("reading ban list: %w", err)
+	}
+	return nil
+}
+
+// save atomically rewrites the ban file with the unexpired bans.
+// The caller must hold b.mu.
+func (b *BanList) save() error {
+	if b.path == "" {
+		return nil
+	}
+	now := b.now()
+	var sb strings.Builder
+	sb.WriteString("# demarkus ban list: <ip> <expiry>\n")
+	for _, ip := range slices.Sorted(maps.Keys(b.bans)) {
+		if until := b.bans[ip]; now.Before(until) {
+			fmt.Fprintf(&sb, "%s %s\n", ip, until.UTC().Format(time.RFC3339))
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(b.path), ".bans-*")
+	if err != nil {
+		return fmt.Errorf("writing ban list: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.WriteString(sb.String()); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("writing ban list: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing ban list: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), b.path); err != nil {
+		return fmt.Errorf("writing ban list: %w", err)
+	}
+	return nil
+}
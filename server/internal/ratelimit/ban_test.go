@@ -0,0 +1,125 @@
+package ratelimit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBanAfterThreshold(t *testing.T) {
+	b, err := NewBanList("", 3, time.Minute, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	b.now = func() time.Time { return now }
+
+	for i := range 2 {
+		if banned, _ := b.Strike("10.0.0.1", ReasonRateLimit); banned {
+			t.Fatalf("strike %d should not ban", i+1)
+		}
+	}
+	banned, err := b.Strike("10.0.0.1", ReasonInvalidToken)
+	if err != nil || !banned {
+		t.Fatalf("third strike: got %v, %v; want ban", banned, err)
+	}
+	if until, ok := b.Banned("10.0.0.1"); !ok || !until.Equal(now.Add(time.Hour)) {
+		t.Errorf("Banned() = %v, %v; want %v, true", until, ok, now.Add(time.Hour))
+	}
+	if _, ok := b.Banned("10.0.0.2"); ok {
+		t.Error("other IP should not be banned")
+	}
+	if n := b.Active(); n != 1 {
+		t.Errorf("Active() = %d, want 1", n)
+	}
+
+	now = now.Add(time.Hour)
+	if n := b.Active(); n != 0 {
+		t.Errorf("Active() after expiry = %d, want 0", n)
+	}
+	if _, ok := b.Banned("10.0.0.1"); ok {
+		t.Error("ban should expire")
+	}
+}
+
+func TestStrikesOutsideWindowExpire(t *testing.T) {
+	b, err := NewBanList("", 2, time.Minute, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	b.now = func() time.Time { return now }
+
+	_, _ = b.Strike("10.0.0.1", ReasonRateLimit)
+	now = now.Add(2 * time.Minute)
+	if banned, _ := b.Strike("10.0.0.1", ReasonRateLimit); banned {
+		t.Error("strikes further apart than the window should not ban")
+	}
+}
+
+func TestBanListPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bans")
+	b, err := NewBanList(path, 1, time.Minute, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if banned, err := b.Strike("10.0.0.1", ReasonInvalidToken); err != nil || !banned {
+		t.Fatalf("Strike() = %v, %v; want ban", banned, err)
+	}
+
+	reloaded, err := NewBanList(path, 1, time.Minute, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reloaded.Banned("10.0.0.1"); !ok {
+		t.Error("ban should survive a reload")
+	}
+
+	// Expired entries are dropped on load.
+	expired := "10.0.0.2 " + time.Now().Add(-time.Minute).UTC().Format(time.RFC3339) + "\n"
+	if err := os.WriteFile(path, []byte(expired), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err = NewBanList(path, 1, time.Minute, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reloaded.Banned("10.0.0.2"); ok {
+		t.Error("expired ban should not be loaded")
+	}
+
+	if err := os.WriteFile(path, []byte("garbage\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewBanList(path, 1, time.Minute, time.Hour); err == nil || !strings.Contains(err.Error(), ":1:") {
+		t.Errorf("malformed file: got %v, want error naming line 1", err)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	b, err := NewBanList("", 3, time.Minute, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = b.Strike("10.0.0.1", ReasonRateLimit)
+	for range 3 {
+		_, _ = b.Strike("10.0.0.2", ReasonInvalidToken)
+	}
+
+	got := b.Summary()
+	if len(got) != 2 {
+		t.Fatalf("got %d offenders, want 2", len(got))
+	}
+	if got[0].IP != "10.0.0.2" || got[0].Strikes[ReasonInvalidToken] != 3 || got[0].BannedUntil.IsZero() {
+		t.Errorf("worst offender = %+v, want 10.0.0.2 banned with 3 invalid-token strikes", got[0])
+	}
+	if got[1].IP != "10.0.0.1" || !got[1].BannedUntil.IsZero() {
+		t.Errorf("second offender = %+v, want 10.0.0.1 not banned", got[1])
+	}
+
+	if got := b.Summary(); len(got) != 0 {
+		t.Errorf("Summary should start a new period, got %+v", got)
+	}
+}
@@ -1,8 +1,10 @@
-// Package ratelimit provides per-IP request rate limiting.
+// Package ratelimit provides per-IP request rate limiting and temporary
+// bans for abusive clients.
 package ratelimit
 
 import (
 	"net"
+	"net/netip"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -23,9 +25,18 @@ type Limiter struct {
 	staleAfter   time.Duration // evict entries idle longer than this
 	ips          sync.Map      // map[string]*entry
 
+	// Adaptive mode: once more than adaptiveAt streams are in flight,
+	// every per-IP rate and burst is scaled by adaptiveAt/inFlight.
+	adaptiveAt atomic.Int64
+	inFlight   atomic.Int64
+
 	stop chan struct{}
 }
 
+// minAdaptiveFactor bounds how far adaptive mode tightens the limits, so
+// clients under heavy load are slowed rather than starved.
+const minAdaptiveFactor = 0.1
+
 // New creates a Limiter that allows r requests per second with the given burst size.
 // A background goroutine evicts stale entries every 60 seconds.
 // Call Stop to release resources.
@@ -48,13 +59,43 @@ func NewWithCleanup(r float64, burst int, cleanupEvery, staleAfter time.Duration
 
 // Allow reports whether a request from the given IP should be permitted.
 func (l *Limiter) Allow(ip string) bool {
-	now := time.Now().UnixNano()
+	ok, _ := l.Reserve(ip)
+	return ok
+}
+
+// Reserve reports whether a request from the given IP should be permitted.
+// When it is not, retryAfter is how long until the IP's next request would
+// be allowed at the current limits. A rejected request consumes nothing.
+func (l *Limiter) Reserve(ip string) (ok bool, retryAfter time.Duration) {
+	return l.ReserveN(ip, 1)
+}
+
+// ReserveN is Reserve for n requests at once, as a BATCH makes. They are
+// permitted or rejected together; n beyond the burst size is capped at it,
+// so that a batch larger than the burst can still be admitted.
+func (l *Limiter) ReserveN(ip string, n int) (ok bool, retryAfter time.Duration) {
+	now := time.Now()
+	e := l.entry(ip, now.UnixNano())
+	l.adapt(e.limiter, now)
+
+	n = min(n, e.limiter.Burst())
+	r := e.limiter.ReserveN(now, n)
+	if !r.OK() {
+		return false, time.Second
+	}
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
 
+func (l *Limiter) entry(ip string, now int64) *entry {
 	// Fast path: reuse existing entry without allocating a new limiter.
 	if v, ok := l.ips.Load(ip); ok {
 		e := v.(*entry)
 		e.lastSeen.Store(now)
-		return e.limiter.Allow()
+		return e
 	}
 
 	// Slow path: create entry and attempt to store it.
@@ -63,7 +104,61 @@ func (l *Limiter) Allow(ip string) bool {
 	v, _ := l.ips.LoadOrStore(ip, e)
 	actual := v.(*entry)
 	actual.lastSeen.Store(now)
-	return actual.limiter.Allow()
+	return actual
+}
+
+// SetAdaptive enables adaptive mode: while more than inFlight streams are
+// being served, per-IP limits shrink in proportion to the excess. Zero
+// disables it.
+func (l *Limiter) SetAdaptive(inFlight int) {
+	l.adaptiveAt.Store(int64(inFlight))
+}
+
+// Begin records that a permitted stream is being served. Call End when it
+// finishes.
+func (l *Limiter) Begin() {
+	l.inFlight.Add(1)
+}
+
+// End records that a stream started with Begin has finished.
+func (l *Limiter) End() {
+	l.inFlight.Add(-1)
+}
+
+// InFlight returns the number of streams between Begin and End.
+func (l *Limiter) InFlight() int {
+	return int(l.inFlight.Load())
+}
+
+// Size returns the number of clients the limiter is tracking. Idle ones
+// are evicted by the cleanup goroutine.
+func (l *Limiter) Size() int {
+	n := 0
+	l.ips.Range(func(_, _ any) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+// factor returns the scale applied to the configured limits: 1 normally,
+// less while adaptive mode is on and the server is over its threshold.
+func (l *Limiter) factor() float64 {
+	at, n := l.adaptiveAt.Load(), l.inFlight.Load()
+	if at <= 0 || n <= at {
+		return 1
+	}
+	return max(float64(at)/float64(n), minAdaptiveFactor)
+}
+
+// adapt brings lim in line with the current load. Limits are updated
+// lazily, when the IP next makes a request.
+func (l *Limiter) adapt(lim *rate.Limiter, now time.Time) {
+	f := l.factor()
+	if want := l.rate * rate.Limit(f); lim.Limit() != want {
+		lim.SetLimitAt(now, want)
+		lim.SetBurstAt(now, max(1, int(float64(l.burst)*f)))
+	}
 }
 
 // Stop terminates the background cleanup goroutine.
@@ -99,3 +194,30 @@ func ExtractIP(addr net.Addr) string {
 	}
 	return host
 }
+
+// IPv6PrefixBits is the prefix length ClientKey groups IPv6 clients by. A
+// /64 is the smallest network normally assigned to one host or site, and
+// hosts pick their own interface IDs within it, so limiting each address
+// separately would let one client rotate through as many as it likes.
+const IPv6PrefixBits = 64
+
+// ClientKey returns the key rate limits and bans are applied to for ip, a
+// string from ExtractIP: an IPv4 address as is, including one mapped into
+// IPv6 by a dual-stack socket, and for IPv6 the first address of its /64,
+// such as "2001:db8:1:2::". Strings that are not IP addresses are
+// returned unchanged.
+func ClientKey(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ip
+	}
+	addr = addr.Unmap()
+	if addr.Is4() {
+		return addr.String()
+	}
+	prefix, err := addr.WithZone("").Prefix(IPv6PrefixBits)
+	if err != nil {
+		return ip
+	}
+	return prefix.Addr().String()
+}
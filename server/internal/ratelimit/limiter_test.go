@@ -41,6 +41,105 @@ func TestSeparateIPs(t *testing.T) {
 	if !l.Allow("10.0.0.2") {
 		t.Fatal("second IP first request should be allowed")
 	}
+	if n := l.Size(); n != 2 {
+		t.Errorf("Size() = %d, want 2", n)
+	}
+}
+
+func TestReserveRetryAfter(t *testing.T) {
+	// 2 requests/sec, burst of 1: the next token is 500ms away.
+	l := New(2, 1)
+	defer l.Stop()
+
+	if ok, _ := l.Reserve("10.0.0.1"); !ok {
+		t.Fatal("first request should be allowed")
+	}
+	ok, retryAfter := l.Reserve("10.0.0.1")
+	if ok {
+		t.Fatal("second request should be denied")
+	}
+	if retryAfter <= 0 || retryAfter > 500*time.Millisecond {
+		t.Errorf("retryAfter = %v, want (0, 500ms]", retryAfter)
+	}
+
+	// A denied request must not push the next token further away.
+	_, again := l.Reserve("10.0.0.1")
+	if again > retryAfter {
+		t.Errorf("retryAfter grew from %v to %v after a denied request", retryAfter, again)
+	}
+}
+
+func TestReserveN(t *testing.T) {
+	l := New(1, 5)
+	defer l.Stop()
+
+	if ok, _ := l.ReserveN("10.0.0.1", 3); !ok {
+		t.Fatal("3 of a burst of 5 should be allowed")
+	}
+	ok, retryAfter := l.ReserveN("10.0.0.1", 3)
+	if ok {
+		t.Fatal("3 more should be denied with 2 tokens left")
+	}
+	if retryAfter <= 0 || retryAfter > time.Second {
+		t.Errorf("retryAfter = %v, want (0, 1s]", retryAfter)
+	}
+	// The denied reservation consumed nothing.
+	if ok, _ := l.ReserveN("10.0.0.1", 2); !ok {
+		t.Fatal("the 2 remaining tokens should be allowed")
+	}
+
+	// More than the burst is capped at it rather than never allowed.
+	if ok, _ := l.ReserveN("10.0.0.2", 50); !ok {
+		t.Fatal("a reservation beyond the burst should be capped")
+	}
+	if ok, _ := l.Reserve("10.0.0.2"); ok {
+		t.Fatal("the capped reservation should have used the whole burst")
+	}
+}
+
+func TestAdaptive(t *testing.T) {
+	l := New(1, 10)
+	defer l.Stop()
+	l.SetAdaptive(2)
+
+	// Below the threshold the full burst is available.
+	l.Begin()
+	l.Begin()
+	for i := range 10 {
+		if !l.Allow("10.0.0.1") {
+			t.Fatalf("request %d should be allowed under normal load", i+1)
+		}
+	}
+
+	// With 4 streams in flight against a threshold of 2, a fresh IP gets
+	// half the burst.
+	l.Begin()
+	l.Begin()
+	if got := l.InFlight(); got != 4 {
+		t.Fatalf("InFlight() = %d, want 4", got)
+	}
+	allowed := 0
+	for range 10 {
+		if l.Allow("10.0.0.2") {
+			allowed++
+		}
+	}
+	if allowed != 5 {
+		t.Errorf("allowed %d requests under load, want 5", allowed)
+	}
+
+	// Once load drops, limits return to normal for new buckets.
+	l.End()
+	l.End()
+	allowed = 0
+	for range 10 {
+		if l.Allow("10.0.0.3") {
+			allowed++
+		}
+	}
+	if allowed != 10 {
+		t.Errorf("allowed %d requests after load dropped, want 10", allowed)
+	}
 }
 
 func TestCleanup(t *testing.T) {
@@ -112,3 +211,38 @@ func TestExtractIP(t *testing.T) {
 		})
 	}
 }
+
+func TestClientKey(t *testing.T) {
+	tests := []struct {
+		ip, want string
+	}{
+		{"192.168.1.1", "192.168.1.1"},
+		{"::ffff:192.168.1.1", "192.168.1.1"},
+		{"2001:db8:1:2:aaaa:bbbb:cccc:dddd", "2001:db8:1:2::"},
+		{"2001:db8:1:2::1", "2001:db8:1:2::"},
+		{"2001:db8:1:3::1", "2001:db8:1:3::"},
+		{"fe80::1%eth0", "fe80::"},
+		{"::1", "::"},
+		{"not-an-ip", "not-an-ip"},
+	}
+	for _, tt := range tests {
+		if got := ClientKey(tt.ip); got != tt.want {
+			t.Errorf("ClientKey(%q) = %q, want %q", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestLimiterGroupsIPv6Prefix(t *testing.T) {
+	l := New(1, 2)
+	defer l.Stop()
+
+	// Rotating interface IDs within one /64 draws on one bucket.
+	for i, ip := range []string{"2001:db8::1", "2001:db8::2", "2001:db8::3"} {
+		if got, want := l.Allow(ClientKey(ip)), i < 2; got != want {
+			t.Errorf("request %d from %s: allowed = %v, want %v", i, ip, got, want)
+		}
+	}
+	if !l.Allow(ClientKey("2001:db8:0:1::1")) {
+		t.Error("a different /64 was limited")
+	}
+}
@@ -0,0 +1,152 @@
+// Package scan passes the bodies of writes to an operator's scanner, such
+// as a virus or content checker, before the server accepts them. A scanner
+// is a command or a Unix socket; either way it sees the path and the body,
+// and answers whether the body may be stored.
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout is how long a scan may take when none is configured.
+const DefaultTimeout = 10 * time.Second
+
+// socketPrefix marks a scanner target that is a Unix socket.
+const socketPrefix = "unix:"
+
+// waitDelay is how long a command killed for its timeout may keep its
+// output open, through children of its own, before the scan gives up.
+const waitDelay = 100 * time.Millisecond
+
+// maxReason is the longest rejection reason kept, in bytes.
+const maxReason = 200
+
+// Rejection is the error of a scan whose scanner refused the body.
+type Rejection struct {
+	Reason string // what the scanner said, if anything
+}
+
+func (r *Rejection) Error() string {
+	if r.Reason == "" {
+		return "rejected by scanner"
+	}
+	return "rejected by scanner: " + r.Reason
+}
+
+// Scanner checks bodies with a command or a Unix socket.
+//
+// A command gets the body on stdin and the path in DEMARKUS_SCAN_PATH. It
+// exits 0 to accept the body and 1 to reject it, with the reason on the
+// first line of stdout. Any other exit is a failure to scan.
+//
+// A socket gets one connection per scan, on which the server writes
+// "SCAN <path> <length>\n" and the body. The scanner answers with one
+// line, "ok" or "reject <reason>", and closes it.
+type Scanner struct {
+	command []string
+	socket  string
+	timeout time.Duration
+}
+
+// New returns the scanner at target: "unix:" and the path of a socket, or
+// a command line, split on spaces. A timeout of 0 means DefaultTimeout.
+func New(target string, timeout time.Duration) (*Scanner, error) {
+	if timeout < 0 {
+		return nil, fmt.Errorf("timeout must be non-negative (got %v)", timeout)
+	}
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	if socket, ok := strings.CutPrefix(target, socketPrefix); ok {
+		if socket == "" {
+			return nil, errors.New("unix: needs the path of a socket")
+		}
+		return &Scanner{socket: socket, timeout: timeout}, nil
+	}
+	command := strings.Fields(target)
+	if len(command) == 0 {
+		return nil, errors.New("no scanner command")
+	}
+	return &Scanner{command: command, timeout: timeout}, nil
+}
+
+// Scan checks body, to be stored at path. It returns nil if the scanner
+// accepts it, a *Rejection if it refuses it, and any other error if the
+// scanner could not be asked or did not answer in time.
+func (s *Scanner) Scan(path string, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	if s.socket != "" {
+		return s.scanSocket(ctx, path, body)
+	}
+	return s.scanCommand(ctx, path, body)
+}
+
+func (s *Scanner) scanCommand(ctx context.Context, path string, body []byte) error {
+	cmd := exec.CommandContext(ctx, s.command[0], s.command[1:]...)
+	cmd.Env = append(os.Environ(), "DEMARKUS_SCAN_PATH="+path)
+	cmd.Stdin = bytes.NewReader(body)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.WaitDelay = waitDelay
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return fmt.Errorf("scanner did not answer within %v", s.timeout)
+	}
+	var exit *exec.ExitError
+	if errors.As(err, &exit) && exit.ExitCode() == 1 {
+		line, _, _ := strings.Cut(out.String(), "\n")
+		return &Rejection{Reason: reason(line)}
+	}
+	if err != nil {
+		return fmt.Errorf("scanner: %w", err)
+	}
+	return nil
+}
+
+func (s *Scanner) scanSocket(ctx context.Context, path string, body []byte) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", s.socket)
+	if err != nil {
+		return fmt.Errorf("scanner: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return fmt.Errorf("scanner: %w", err)
+	}
+
+	if _, err := fmt.Fprintf(conn, "SCAN %s %d\n%s", path, len(body), body); err != nil {
+		return fmt.Errorf("scanner: %w", err)
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil && line == "" {
+		return fmt.Errorf("scanner: %w", err)
+	}
+	verdict, rest, _ := strings.Cut(strings.TrimSpace(line), " ")
+	switch verdict {
+	case "ok":
+		return nil
+	case "reject":
+		return &Rejection{Reason: reason(rest)}
+	}
+	return fmt.Errorf("scanner: unexpected answer %q", reason(line))
+}
+
+// reason trims what a scanner said to one short line.
+func reason(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) > maxReason {
+		s = strings.ToValidUTF8(s[:maxReason], "")
+	}
+	return s
+}
@@ -0,0 +1,125 @@
+package scan
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// script writes a shell script to a temporary file and returns its path.
+func script(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "scan.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNew(t *testing.T) {
+	if s, err := New("unix:/run/scan.sock", 0); err != nil || s.socket != "/run/scan.sock" || s.timeout != DefaultTimeout {
+		t.Errorf("socket: %+v, %v", s, err)
+	}
+	if s, err := New("/usr/bin/scan --quiet", time.Second); err != nil || len(s.command) != 2 || s.timeout != time.Second {
+		t.Errorf("command: %+v, %v", s, err)
+	}
+	for _, target := range []string{"", "  ", "unix:"} {
+		if _, err := New(target, 0); err == nil {
+			t.Errorf("New(%q) succeeded", target)
+		}
+	}
+	if _, err := New("/usr/bin/scan", -time.Second); err == nil {
+		t.Error("negative timeout accepted")
+	}
+}
+
+func TestScanCommand(t *testing.T) {
+	s, err := New(script(t, `body=$(cat)
+case "$body" in
+*EICAR*) echo "found EICAR in $DEMARKUS_SCAN_PATH"; exit 1 ;;
+*crash*) exit 2 ;;
+*slow*) sleep 5 ;;
+esac
+exit 0
+`), 500*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Scan("/doc.md", []byte("# Clean\n")); err != nil {
+		t.Errorf("clean body: %v", err)
+	}
+	var r *Rejection
+	if err := s.Scan("/doc.md", []byte("EICAR test\n")); !errors.As(err, &r) || r.Reason != "found EICAR in /doc.md" {
+		t.Errorf("infected body: %v", err)
+	}
+	for _, body := range []string{"crash\n", "slow\n"} {
+		if err := s.Scan("/doc.md", []byte(body)); err == nil || errors.As(err, &r) {
+			t.Errorf("%q: err %v, want a failure to scan", body, err)
+		}
+	}
+
+	missing, _ := New(filepath.Join(t.TempDir(), "missing"), 0)
+	if err := missing.Scan("/doc.md", nil); err == nil || errors.As(err, &r) {
+		t.Errorf("missing command: err %v, want a failure to scan", err)
+	}
+}
+
+func TestScanSocket(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "scan.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	defer func() { _ = ln.Close() }()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			r := bufio.NewReader(conn)
+			header, _ := r.ReadString('\n')
+			fields := strings.Fields(header)
+			n, _ := strconv.Atoi(fields[2])
+			body := make([]byte, n)
+			_, _ = io.ReadFull(r, body)
+			switch {
+			case strings.Contains(string(body), "EICAR"):
+				_, _ = io.WriteString(conn, "reject EICAR in "+fields[1]+"\n")
+			case strings.Contains(string(body), "garbage"):
+				_, _ = io.WriteString(conn, "maybe\n")
+			default:
+				_, _ = io.WriteString(conn, "ok\n")
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	s, err := New("unix:"+sock, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Scan("/doc.md", []byte("# Clean\n")); err != nil {
+		t.Errorf("clean body: %v", err)
+	}
+	var r *Rejection
+	if err := s.Scan("/doc.md", []byte("EICAR\n")); !errors.As(err, &r) || r.Reason != "EICAR in /doc.md" {
+		t.Errorf("infected body: %v", err)
+	}
+	if err := s.Scan("/doc.md", []byte("garbage\n")); err == nil || errors.As(err, &r) {
+		t.Errorf("unexpected answer: err %v, want a failure to scan", err)
+	}
+
+	gone, _ := New("unix:"+filepath.Join(t.TempDir(), "none.sock"), time.Second)
+	if err := gone.Scan("/doc.md", nil); err == nil || errors.As(err, &r) {
+		t.Errorf("no socket: err %v, want a failure to scan", err)
+	}
+}
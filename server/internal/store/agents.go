@@ -0,0 +1,83 @@
+package store
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// agentEntry is the agent metadata recorded for one version file.
+type agentEntry struct {
+	modified time.Time // of the version file, to tell a purged and republished version apart
+	agent    string
+}
+
+func agentKey(reqPath string) string {
+	return filepath.Clean("/" + strings.TrimLeft(reqPath, "/"))
+}
+
+// recordAgent records the agent metadata of a version as it is written.
+func (s *Store) recordAgent(reqPath string, version int, modified time.Time, agent string) {
+	key := agentKey(reqPath)
+	s.agentMu.Lock()
+	defer s.agentMu.Unlock()
+	if s.agentIdx[key] == nil {
+		s.agentIdx[key] = make(map[int]agentEntry)
+	}
+	s.agentIdx[key][version] = agentEntry{modified: modified, agent: agent}
+}
+
+// fillAgents sets the Agent of each of versions from the index. Versions
+// written before the server started are read once and recorded; version
+// files never change, so the index needs no other upkeep than forgetAgents
+// and moveAgents.
+func (s *Store) fillAgents(reqPath string, versions []VersionInfo) {
+	key := agentKey(reqPath)
+	var missing []int
+	s.agentMu.RLock()
+	for i, v := range versions {
+		e, ok := s.agentIdx[key][v.Version]
+		if ok && e.modified.Equal(v.Modified) {
+			versions[i].Agent = e.agent
+			continue
+		}
+		missing = append(missing, i)
+	}
+	s.agentMu.RUnlock()
+
+	if len(missing) == 0 {
+		return
+	}
+	cleaned := strings.TrimLeft(filepath.Clean(reqPath), "/")
+	versionsDir := filepath.Join(s.root, filepath.Dir(cleaned), "versions")
+	base := filepath.Base(cleaned)
+	for _, i := range missing {
+		v := versions[i]
+		data, err := readVersionFile(filepath.Join(versionsDir, fmt.Sprintf("%s.v%d", base, v.Version)))
+		if err != nil {
+			continue
+		}
+		versions[i].Agent = extractMetadata(data)["agent"]
+		s.recordAgent(reqPath, v.Version, v.Modified, versions[i].Agent)
+	}
+}
+
+// moveAgents carries the recorded agents of a moved document, whose
+// version files keep their bytes, to its new path.
+func (s *Store) moveAgents(oldPath, newPath string) {
+	oldKey, newKey := agentKey(oldPath), agentKey(newPath)
+	s.agentMu.Lock()
+	defer s.agentMu.Unlock()
+	if e, ok := s.agentIdx[oldKey]; ok {
+		s.agentIdx[newKey] = e
+	}
+	delete(s.agentIdx, oldKey)
+}
+
+// forgetAgents drops the recorded agents of a purged document.
+func (s *Store) forgetAgents(reqPath string) {
+	s.agentMu.Lock()
+	defer s.agentMu.Unlock()
+	delete(s.agentIdx, agentKey(reqPath))
+}
@@ -0,0 +1,80 @@
+package store
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// aliasesKey is the publisher metadata key listing a document's former
+// paths, e.g. "aliases: [/old-path.md, /older.md]". Requests for an alias
+// are redirected to the document, so links to its old paths keep working.
+const aliasesKey = "aliases"
+
+// ParseAliases parses an aliases metadata value: absolute document paths
+// separated by commas, optionally in YAML flow-list brackets. Entries that
+// are not such paths are left out, and the first is reported in err.
+func ParseAliases(value string) (aliases []string, err error) {
+	value = strings.TrimSpace(value)
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+	for a := range strings.SplitSeq(value, ",") {
+		a = strings.Trim(strings.TrimSpace(a), `"'`)
+		if a == "" {
+			continue
+		}
+		if !strings.HasPrefix(a, "/") || strings.HasSuffix(a, "/") || containsDotDot(a) {
+			if err == nil {
+				err = fmt.Errorf("alias %q is not an absolute document path", a)
+			}
+			continue
+		}
+		if a = path.Clean(a); !slices.Contains(aliases, a) {
+			aliases = append(aliases, a)
+		}
+	}
+	return aliases, err
+}
+
+// ResolveAlias returns the path of the document that declares reqPath as
+// an alias, if any.
+func (s *Store) ResolveAlias(reqPath string) (string, bool) {
+	s.aliasMu.RLock()
+	defer s.aliasMu.RUnlock()
+	canonical, ok := s.aliasIdx[path.Clean("/"+reqPath)]
+	return canonical, ok
+}
+
+// setAliases records the aliases declared by meta as those of reqPath,
+// replacing any it had. nil meta removes them, as for an archived
+// document. When two documents claim an alias, the later write wins.
+func (s *Store) setAliases(reqPath string, meta map[string]string) {
+	s.aliasMu.Lock()
+	defer s.aliasMu.Unlock()
+	s.setAliasesLocked(reqPath, meta)
+}
+
+func (s *Store) setAliasesLocked(reqPath string, meta map[string]string) {
+	for _, a := range s.aliasesOf[reqPath] {
+		if s.aliasIdx[a] == reqPath {
+			delete(s.aliasIdx, a)
+		}
+	}
+	delete(s.aliasesOf, reqPath)
+
+	var aliases []string
+	parsed, _ := ParseAliases(meta[aliasesKey])
+	for _, a := range parsed {
+		if a == reqPath {
+			continue
+		}
+		if prev, ok := s.aliasIdx[a]; ok {
+			s.aliasesOf[prev] = slices.DeleteFunc(s.aliasesOf[prev], func(p string) bool { return p == a })
+		}
+		s.aliasIdx[a] = reqPath
+		aliases = append(aliases, a)
+	}
+	if len(aliases) > 0 {
+		s.aliasesOf[reqPath] = aliases
+	}
+}
@@ -0,0 +1,144 @@
+package store
+
+import (
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// linkIndex records the links between the current, unarchived markdown
+// documents, so that Backlinks can answer which documents link to a page
+// without reading them. Like searchIndex it is guarded by searchMu.
+type linkIndex struct {
+	out map[string][]string        // path → paths it links to
+	in  map[string]map[string]bool // path → paths linking to it
+}
+
+func newLinkIndex() *linkIndex {
+	return &linkIndex{
+		out: make(map[string][]string),
+		in:  make(map[string]map[string]bool),
+	}
+}
+
+// add records the links of a document, replacing those recorded for its
+// path.
+func (idx *linkIndex) add(reqPath string, meta map[string]string, body []byte) {
+	idx.remove(reqPath)
+	if !isMarkdown(meta["content-type"]) {
+		return
+	}
+	targets := linkTargets(reqPath, body)
+	if len(targets) == 0 {
+		return
+	}
+	idx.out[reqPath] = targets
+	for _, t := range targets {
+		if idx.in[t] == nil {
+			idx.in[t] = make(map[string]bool)
+		}
+		idx.in[t][reqPath] = true
+	}
+}
+
+// remove drops the links of a document. Links to it are kept: they still
+// point at its path, and count again if it comes back.
+func (idx *linkIndex) remove(reqPath string) {
+	for _, t := range idx.out[reqPath] {
+		delete(idx.in[t], reqPath)
+		if len(idx.in[t]) == 0 {
+			delete(idx.in, t)
+		}
+	}
+	delete(idx.out, reqPath)
+}
+
+// linkTargets returns the paths on this server that the markdown body of
+// the document at reqPath links to, once each, in document order. Relative
+// links are resolved against the document's directory; queries and
+// fragments are dropped, and a trailing slash is kept, so a link to a
+// directory stays one. Links with a scheme or host, including mark:// URLs,
+// are left out: a server cannot tell every name it is reached by. So are
+// links from the document to itself.
+func linkTargets(reqPath string, body []byte) []string {
+	doc := goldmark.DefaultParser().Parse(text.NewReader(body))
+	var targets []string
+	seen := make(map[string]bool)
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		link, ok := n.(*ast.Link)
+		if !ok {
+			return ast.WalkContinue, nil
+		}
+		u, err := url.Parse(string(link.Destination))
+		if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" {
+			return ast.WalkContinue, nil
+		}
+		target := u.Path
+		if !strings.HasPrefix(target, "/") {
+			target = path.Join(path.Dir(reqPath), target)
+		}
+		target = path.Clean(target)
+		if strings.HasSuffix(u.Path, "/") && target != "/" {
+			target += "/"
+		}
+		if target != reqPath && !seen[target] {
+			seen[target] = true
+			targets = append(targets, target)
+		}
+		return ast.WalkContinue, nil
+	})
+	return targets
+}
+
+// isMarkdown reports whether a document with the given content-type
+// metadata is markdown; none means it is.
+func isMarkdown(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	return mediaType == "" || mediaType == "text/markdown"
+}
+
+// Backlink is one result of Backlinks.
+type Backlink struct {
+	Path  string
+	Title string
+}
+
+// Backlinks returns the current documents that link to reqPath, by path.
+// A link counts if it names the document, one of its aliases or, for an
+// index.md, its directory. Documents for which visible returns false are
+// left out; visible may be nil.
+func (s *Store) Backlinks(reqPath string, visible func(reqPath string) bool) []Backlink {
+	reqPath = path.Clean("/" + reqPath)
+	names := []string{reqPath}
+	if dir, ok := strings.CutSuffix(reqPath, "/index.md"); ok {
+		names = append(names, dir+"/")
+	}
+	s.aliasMu.RLock()
+	names = append(names, s.aliasesOf[reqPath]...)
+	s.aliasMu.RUnlock()
+
+	s.searchMu.RLock()
+	defer s.searchMu.RUnlock()
+	var links []Backlink
+	seen := make(map[string]bool)
+	for _, name := range names {
+		for p := range s.linkIdx.in[name] {
+			if seen[p] || p == reqPath || (visible != nil && !visible(p)) {
+				continue
+			}
+			seen[p] = true
+			links = append(links, Backlink{Path: p, Title: s.searchIdx.titles[p]})
+		}
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].Path < links[j].Path })
+	return links
+}
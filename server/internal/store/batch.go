@@ -0,0 +1,152 @@
+package store
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// BatchWrite is one document written by WriteBatch.
+type BatchWrite struct {
+	Path            string
+	ExpectedVersion int // as for WriteVersion
+	Content         []byte
+	Meta            map[string]string
+}
+
+// BatchResult is what WriteBatch did with one document.
+type BatchResult struct {
+	Doc       *Document
+	Unchanged bool // its content and metadata were current already
+}
+
+// BatchError reports the write that failed a WriteBatch. Doc is what
+// WriteVersion would have returned with Err, such as the current version
+// with ErrConflict.
+type BatchError struct {
+	Index int // into the writes
+	Doc   *Document
+	Err   error
+}
+
+func (e *BatchError) Error() string { return fmt.Sprintf("write %d: %v", e.Index, e.Err) }
+
+func (e *BatchError) Unwrap() error { return e.Err }
+
+// WriteBatch writes a new version of each document, as WriteVersion would,
+// and either every one becomes current or none does. It returns the
+// results in the order of writes; a document whose content and metadata
+// are unchanged keeps its current version, which WriteVersion would have
+// reported with ErrNotModified.
+//
+// Every version file is created before any current file is pointed at
+// its new version, and the batch holds the store to itself, so no other
+// write sees part of it. If a step fails, the current files already
+// switched are pointed back and the new version files removed. With a
+// journal, a batch interrupted by a crash is undone by Recover.
+//
+// A failed write is reported as a *BatchError wrapping the error
+// WriteVersion would have returned; nothing is written then.
+func (s *Store) WriteBatch(writes []BatchWrite) ([]BatchResult, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	results := make([]BatchResult, len(writes))
+	var (
+		plans []writePlan
+		index []int // into writes, of each plan
+	)
+	seen := make(map[string]bool, len(writes))
+	for i, w := range writes {
+		key := path.Clean("/" + w.Path)
+		if seen[key] {
+			return nil, &BatchError{Index: i, Err: fmt.Errorf("%s is written twice", key)}
+		}
+		seen[key] = true
+
+		// Nothing else writes while the batch holds the store, so the
+		// check cannot go stale before the version file is created.
+		if w.ExpectedVersion >= 0 {
+			if current := s.CurrentVersion(w.Path); current != w.ExpectedVersion {
+				return nil, &BatchError{Index: i, Doc: &Document{Version: current}, Err: ErrConflict}
+			}
+		}
+		p, doc, err := s.planWrite(w.Path, w.Content, w.Meta)
+		if errors.Is(err, ErrNotModified) {
+			results[i] = BatchResult{Doc: doc, Unchanged: true}
+			continue
+		}
+		if err != nil {
+			return nil, &BatchError{Index: i, Doc: doc, Err: err}
+		}
+		plans = append(plans, p)
+		index = append(index, i)
+	}
+	if len(plans) == 0 {
+		return results, nil
+	}
+
+	if s.journal {
+		entries := make([]journalEntry, len(plans))
+		for k, p := range plans {
+			entries[k] = journalEntry{path: p.reqPath, version: p.version, hash: fmt.Sprintf("sha256-%x", sha256.Sum256(p.stored))}
+		}
+		if err := s.journalStage(entries); err != nil {
+			return nil, err
+		}
+		defer s.journalCommit()
+	}
+
+	for k, p := range plans {
+		if err := s.createVersionFile(p); err != nil {
+			unstage(plans[:k], 0)
+			if errors.Is(err, ErrVersionExists) {
+				return nil, &BatchError{Index: index[k], Doc: &Document{Version: s.CurrentVersion(p.reqPath)}, Err: ErrConflict}
+			}
+			return nil, &BatchError{Index: index[k], Err: err}
+		}
+	}
+	// As in makeCurrent, hashMu is held until the hash index matches the
+	// flipped current files.
+	s.hashMu.Lock()
+	for k, p := range plans {
+		if err := pointCurrent(p.currentFile, p.target()); err != nil {
+			unstage(plans, k)
+			s.hashMu.Unlock()
+			return nil, &BatchError{Index: index[k], Err: err}
+		}
+	}
+	for _, p := range plans {
+		s.updateHashIndexLocked(p.reqPath, p.content)
+	}
+	s.hashMu.Unlock()
+
+	for k, p := range plans {
+		doc, err := s.written(p)
+		if err != nil {
+			return nil, err
+		}
+		results[index[k]] = BatchResult{Doc: doc}
+	}
+	return results, nil
+}
+
+// unstage undoes the plans of a failed batch: the first flipped were made
+// current and are pointed back at the version before them, or removed if
+// they were new, and every version file is removed.
+func unstage(plans []writePlan, flipped int) {
+	for _, p := range plans[:flipped] {
+		if p.version == 1 {
+			_ = os.Remove(p.currentFile)
+			continue
+		}
+		prev := fmt.Sprintf("%s.v%d", filepath.Base(p.currentFile), p.version-1)
+		_ = pointCurrent(p.currentFile, filepath.Join("versions", prev))
+	}
+	for _, p := range plans {
+		_ = os.Remove(p.versionFile)
+	}
+}
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"time"
+)
+
+// ChainStatus is the outcome of the last check of a document's hash chain.
+type ChainStatus struct {
+	Valid   bool
+	Error   string // the first broken link, when not Valid
+	Checked time.Time
+}
+
+// ScanChains verifies the hash chain of every current document with
+// VerifyChain and keeps the outcomes for ChainStatus, so that reads can
+// report a chain's state without reading its history. Documents gone
+// since the last scan are forgotten. onBroken, if set, is called with each
+// broken chain's document and error. It returns how many documents it
+// checked and how many of their chains are broken.
+func (s *Store) ScanChains(onBroken func(reqPath string, err error)) (checked, broken int) {
+	start := time.Now().UTC().Truncate(time.Second)
+	s.hashMu.RLock()
+	paths := make([]string, 0, len(s.pathIdx))
+	for p := range s.pathIdx {
+		paths = append(paths, p)
+	}
+	s.hashMu.RUnlock()
+
+	for _, p := range paths {
+		st := ChainStatus{Valid: true, Checked: time.Now().UTC().Truncate(time.Second)}
+		if err := s.VerifyChain(p); err != nil {
+			st = ChainStatus{Error: err.Error(), Checked: st.Checked}
+			broken++
+			if onBroken != nil {
+				onBroken(p, err)
+			}
+		}
+		s.setChainStatus(p, st)
+		checked++
+	}
+
+	s.chainMu.Lock()
+	defer s.chainMu.Unlock()
+	for p, st := range s.chainIdx {
+		if st.Checked.Before(start) {
+			delete(s.chainIdx, p)
+		}
+	}
+	return checked, broken
+}
+
+// ChainStatus returns the outcome of the last check of reqPath's hash
+// chain, by ScanChains or when the document was created. ok is false if
+// the chain has not been checked since the server started.
+func (s *Store) ChainStatus(reqPath string) (st ChainStatus, ok bool) {
+	s.chainMu.RLock()
+	defer s.chainMu.RUnlock()
+	st, ok = s.chainIdx[reqPath]
+	return st, ok
+}
+
+func (s *Store) setChainStatus(reqPath string, st ChainStatus) {
+	s.chainMu.Lock()
+	defer s.chainMu.Unlock()
+	s.chainIdx[reqPath] = st
+}
+
+// chainWritten updates the chain status of reqPath after version was
+// written. The store links each version it writes to the one before, so
+// a write leaves a checked chain as it was, and a new document's chain,
+// with nothing to link, is valid.
+func (s *Store) chainWritten(reqPath string, version int, modified time.Time) {
+	if version == 1 {
+		s.setChainStatus(reqPath, ChainStatus{Valid: true, Checked: modified})
+	}
+}
+
+// moveChainStatus carries the chain status of a moved document, whose
+// version files keep their bytes, to its new path.
+func (s *Store) moveChainStatus(oldPath, newPath string) {
+	s.chainMu.Lock()
+	defer s.chainMu.Unlock()
+	if st, ok := s.chainIdx[oldPath]; ok {
+		s.chainIdx[newPath] = st
+	}
+	delete(s.chainIdx, oldPath)
+}
+
+// forgetChainStatus drops the chain status of a purged document.
+func (s *Store) forgetChainStatus(reqPath string) {
+	s.chainMu.Lock()
+	defer s.chainMu.Unlock()
+	delete(s.chainIdx, reqPath)
+}
@@ -0,0 +1,186 @@
+package store
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/latebit/demarkus/protocol"
+)
+
+// gzipMagic starts every gzip stream. A version file always starts with
+// its "---" frontmatter, so one that starts with these bytes is compressed.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// EnableCompression makes the store gzip old version files: those at least
+// keep versions behind the current one and last modified more than age
+// ago. A zero keep or age drops that condition; at least one must be set.
+// The current version is never compressed.
+//
+// A compressed file keeps its name and modification time, and reading it
+// yields the original bytes, so the hash chain and the responses are
+// unchanged. Versions are compressed when their document is written and
+// by CompressVersions. Call it before serving.
+func (s *Store) EnableCompression(keep int, age time.Duration) {
+	s.compressKeep = keep
+	s.compressAge = age
+}
+
+// compressing reports whether EnableCompression was called with a policy.
+func (s *Store) compressing() bool {
+	return s.compressKeep > 0 || s.compressAge > 0
+}
+
+// CompressVersions walks the content root and compresses every version
+// file the policy set by EnableCompression allows, returning how many it
+// compressed. Run it at startup, before serving, to catch up with versions
+// that have aged since their document was last written.
+func (s *Store) CompressVersions() (int, error) {
+	if !s.compressing() {
+		return 0, nil
+	}
+	absRoot, err := s.resolvedRoot()
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	err = filepath.WalkDir(absRoot, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil // skip unreadable entries
+		}
+		if d.IsDir() {
+			if d.Name() == "versions" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type()&os.ModeSymlink == 0 || strings.HasSuffix(path, ".tmp") {
+			return nil
+		}
+		rel, err := filepath.Rel(absRoot, path)
+		if err != nil {
+			return nil
+		}
+		n, err := s.compressOld("/"+filepath.ToSlash(rel), false)
+		total += n
+		if err != nil {
+			return fmt.Errorf("/%s: %w", filepath.ToSlash(rel), err)
+		}
+		return nil
+	})
+	return total, err
+}
+
+// compressOld compresses the versions of reqPath the policy allows, newest
+// first, and returns how many it compressed. With incremental, it stops at
+// the first one already compressed: after a write, older versions were
+// handled by the writes before it.
+func (s *Store) compressOld(reqPath string, incremental bool) (int, error) {
+	versions := s.findVersions(reqPath)
+	if len(versions) < 2 {
+		return 0, nil
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].Version > versions[j].Version
+	})
+	current := versions[0].Version
+
+	cleaned := strings.TrimLeft(filepath.Clean(reqPath), "/")
+	base := filepath.Base(cleaned)
+	versionsDir := filepath.Join(s.root, filepath.Dir(cleaned), "versions")
+	cutoff := time.Now().Add(-s.compressAge)
+
+	n := 0
+	for _, v := range versions[1:] {
+		if s.compressKeep > 0 && v.Version > current-s.compressKeep {
+			continue
+		}
+		if s.compressAge > 0 && !v.Modified.Before(cutoff) {
+			continue
+		}
+		compressed, err := compressFile(filepath.Join(versionsDir, fmt.Sprintf("%s.v%d", base, v.Version)))
+		if err != nil {
+			return n, fmt.Errorf("compress v%d: %w", v.Version, err)
+		}
+		if !compressed {
+			if incremental {
+				break
+			}
+			continue
+		}
+		n++
+	}
+	return n, nil
+}
+
+// compressFile replaces the file at path with its gzipped bytes, keeping
+// its modification time. Reports false if it was already compressed.
+func compressFile(path string) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	if bytes.HasPrefix(data, gzipMagic) {
+		return false, nil
+	}
+
+	// A unique temp name: two writes of a document may compress the same
+	// version at once. The leading dot keeps it out of findVersions.
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return false, err
+	}
+	zw := gzip.NewWriter(tmp)
+	if _, err := zw.Write(data); err == nil {
+		err = zw.Close()
+	}
+	if err == nil {
+		err = tmp.Chmod(info.Mode().Perm())
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chtimes(tmp.Name(), info.ModTime(), info.ModTime())
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return false, err
+	}
+	return true, nil
+}
+
+// readVersionFile reads a version file, decompressing it if it was
+// compressed. Every read of a version file goes through it.
+func readVersionFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil || !bytes.HasPrefix(data, gzipMagic) {
+		return data, err
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decompress %s: %w", filepath.Base(path), err)
+	}
+	limit := int64(protocol.MaxBodyLength + maxStoreFrontmatter)
+	out, err := io.ReadAll(io.LimitReader(zr, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("decompress %s: %w", filepath.Base(path), err)
+	}
+	if int64(len(out)) > limit {
+		return nil, fmt.Errorf("file exceeds size limit")
+	}
+	return out, nil
+}
@@ -0,0 +1,112 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DocInfo is what the server derives from a document's bytes to describe
+// it in responses, listings and tables of contents.
+type DocInfo struct {
+	Etag        string // SHA-256 of the raw version file, in hex
+	ContentHash string // "sha256-" hash of the body
+	Size        int    // body length in bytes
+	Title       string // title metadata, else the first heading; may be empty
+}
+
+// infoEntry is a cached DocInfo and the version it describes.
+type infoEntry struct {
+	version  int
+	modified time.Time
+	length   int // of the raw file, to tell a purged and republished version apart
+	info     DocInfo
+}
+
+// Describe returns the DocInfo of doc, a version of reqPath returned by Get.
+// Version files never change, so it is computed once per document version
+// and cached until the document is written again; the cache holds one entry
+// per document.
+func (s *Store) Describe(reqPath string, doc *Document) DocInfo {
+	key := filepath.Clean("/" + strings.TrimLeft(reqPath, "/"))
+	s.infoMu.RLock()
+	e, ok := s.infoIdx[key]
+	s.infoMu.RUnlock()
+	if ok && e.version == doc.Version && e.modified.Equal(doc.Modified) && e.length == len(doc.Content) {
+		return e.info
+	}
+
+	sum := sha256.Sum256(doc.Content)
+	info := DocInfo{
+		Etag:        hex.EncodeToString(sum[:]),
+		ContentHash: contentHash(extractBody(doc.Content)),
+		Size:        len(extractBody(doc.Content)),
+		Title:       doc.Metadata["title"],
+	}
+	if info.Title == "" {
+		info.Title = FirstHeading(extractBody(doc.Content))
+	}
+	s.infoMu.Lock()
+	s.infoIdx[key] = infoEntry{version: doc.Version, modified: doc.Modified, length: len(doc.Content), info: info}
+	s.infoMu.Unlock()
+	return info
+}
+
+// Preload describes the current version of every document, so the first
+// request for each after a restart finds its DocInfo cached. progress, if
+// not nil, is called with the number described so far after every batch of
+// documents. Returns how many were described. Meant to run in the
+// background: the server answers requests meanwhile.
+func (s *Store) Preload(progress func(described int)) (int, error) {
+	const batch = 500
+	absRoot, err := s.resolvedRoot()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	err = filepath.WalkDir(absRoot, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil // skip unreadable entries
+		}
+		if d.IsDir() {
+			if d.Name() == "versions" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type()&os.ModeSymlink == 0 || strings.HasSuffix(path, ".tmp") {
+			return nil
+		}
+		rel, err := filepath.Rel(absRoot, path)
+		if err != nil {
+			return nil
+		}
+		reqPath := "/" + filepath.ToSlash(rel)
+		doc, err := s.Get(reqPath, 0)
+		if err != nil {
+			return nil // skip documents that cannot be served
+		}
+		s.Describe(reqPath, doc)
+		n++
+		if progress != nil && n%batch == 0 {
+			progress(n)
+		}
+		return nil
+	})
+	if err != nil {
+		return n, fmt.Errorf("preload: %w", err)
+	}
+	return n, nil
+}
+
+// forgetInfo drops the cached DocInfo of a document that was moved or
+// purged.
+func (s *Store) forgetInfo(reqPath string) {
+	s.infoMu.Lock()
+	delete(s.infoIdx, filepath.Clean("/"+strings.TrimLeft(reqPath, "/")))
+	s.infoMu.Unlock()
+}
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FeedSize is how many of the most recently published versions the store
+// remembers for Recent.
+const FeedSize = 200
+
+// Change is a version published to the store, as returned by Recent.
+type Change struct {
+	Path     string
+	Version  int
+	Modified time.Time
+	Title    string // the document's current title
+}
+
+// changeLog holds the most recently published versions, oldest first, at
+// most FeedSize of them. Like searchIndex it is guarded by searchMu.
+type changeLog struct {
+	changes []Change
+}
+
+// add records a version, forgetting the oldest beyond FeedSize.
+func (l *changeLog) add(c Change) {
+	l.changes = append(l.changes, c)
+	if n := len(l.changes) - FeedSize; n > 0 {
+		l.changes = append(l.changes[:0:0], l.changes[n:]...)
+	}
+}
+
+// recordChange adds a version just made current to the change log.
+func (s *Store) recordChange(reqPath string, version int, modified time.Time) {
+	s.searchMu.Lock()
+	defer s.searchMu.Unlock()
+	s.feed.add(Change{Path: reqPath, Version: version, Modified: modified})
+}
+
+// Recent returns up to limit of the versions most recently published,
+// newest first, with each document's current title. Since the store keeps
+// no log on disk, after a restart it starts from the current version of
+// each document. Versions of documents that are no longer current, having
+// been archived, moved or purged, are left out, and so are documents for
+// which visible returns false; visible may be nil.
+func (s *Store) Recent(limit int, visible func(reqPath string) bool) []Change {
+	s.searchMu.RLock()
+	defer s.searchMu.RUnlock()
+	var out []Change
+	for i := len(s.feed.changes) - 1; i >= 0 && len(out) < limit; i-- {
+		c := s.feed.changes[i]
+		title, current := s.searchIdx.titles[c.Path]
+		if !current || (visible != nil && !visible(c.Path)) {
+			continue
+		}
+		c.Title = title
+		out = append(out, c)
+	}
+	return out
+}
+
+// seedFeed returns a change log holding the latest FeedSize of changes,
+// the current versions found by the startup walk.
+func seedFeed(changes []Change) changeLog {
+	sort.Slice(changes, func(i, j int) bool {
+		if !changes[i].Modified.Equal(changes[j].Modified) {
+			return changes[i].Modified.Before(changes[j].Modified)
+		}
+		return changes[i].Path < changes[j].Path
+	})
+	var l changeLog
+	for _, c := range changes {
+		l.add(c)
+	}
+	return l
+}
+
+// versionFileChange returns the change a current version file stands for,
+// reading the version from its name (doc.md.v3).
+func versionFileChange(reqPath string, file os.FileInfo) (Change, bool) {
+	i := strings.LastIndex(file.Name(), ".v")
+	if i < 0 {
+		return Change{}, false
+	}
+	version, err := strconv.Atoi(file.Name()[i+2:])
+	if err != nil || version < 1 {
+		return Change{}, false
+	}
+	return Change{Path: reqPath, Version: version, Modified: file.ModTime().UTC().Truncate(time.Second)}, true
+}
@@ -0,0 +1,319 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// journalFile is the write-ahead journal in the content root. Each line is
+//
+//	begin<TAB>N<TAB>sha256-<hex><TAB>/request/path
+//	end<TAB>N<TAB>sha256-<hex><TAB>/request/path
+//
+// A begin is written and synced before version N's file is created, with
+// the hash the file will have; its end follows once the write succeeded
+// or was undone. A begin without an end is a write a crash interrupted.
+// The file is emptied whenever no write is in flight, so it stays small.
+//
+// A batch (see WriteBatch) writes instead
+//
+//	stage<TAB>N<TAB>sha256-<hex><TAB>/request/path
+//	commit<TAB>0<TAB>-<TAB>/
+//
+// a stage for each of its documents, synced together before any version
+// file is created, and a commit once all of them are current or undone.
+// Stages without a commit after them are a batch a crash interrupted;
+// since a batch holds the store to itself, they are all from the same one.
+const journalFile = ".demarkus-journal"
+
+// journalEntry is a write recorded by a begin line.
+type journalEntry struct {
+	path    string
+	version int
+	hash    string // of the full version file
+}
+
+func (e journalEntry) line(op string) string {
+	return fmt.Sprintf("%s\t%d\t%s\t%s\n", op, e.version, e.hash, e.path)
+}
+
+// EnableJournal makes Write record each write in the journal before
+// making it, and sync version files to disk before pointing at them, so
+// Recover can repair writes interrupted by a crash.
+func (s *Store) EnableJournal() {
+	s.journalMu.Lock()
+	defer s.journalMu.Unlock()
+	s.journal = true
+	s.inFlight = make(map[journalEntry]int)
+}
+
+// journalBegin records that version of reqPath is about to be written
+// with the given stored bytes, and returns the entry to pass to journalEnd.
+func (s *Store) journalBegin(reqPath string, version int, stored []byte) (journalEntry, error) {
+	e := journalEntry{path: reqPath, version: version, hash: fmt.Sprintf("sha256-%x", sha256.Sum256(stored))}
+	s.journalMu.Lock()
+	defer s.journalMu.Unlock()
+	if err := s.appendJournal(e.line("begin")); err != nil {
+		return e, fmt.Errorf("journal: %w", err)
+	}
+	s.inFlight[e]++
+	return e, nil
+}
+
+// journalEnd records that a write started by journalBegin finished, or
+// was undone, and empties the journal once no write is in flight.
+func (s *Store) journalEnd(e journalEntry) {
+	s.journalMu.Lock()
+	defer s.journalMu.Unlock()
+	if s.inFlight[e]--; s.inFlight[e] == 0 {
+		delete(s.inFlight, e)
+	}
+	if len(s.inFlight) == 0 {
+		_ = os.Truncate(filepath.Join(s.root, journalFile), 0)
+		return
+	}
+	_ = s.appendJournal(e.line("end"))
+}
+
+// journalStage records that a batch is about to write entries.
+func (s *Store) journalStage(entries []journalEntry) error {
+	var b strings.Builder
+	for _, e := range entries {
+		b.WriteString(e.line("stage"))
+	}
+	s.journalMu.Lock()
+	defer s.journalMu.Unlock()
+	if err := s.appendJournal(b.String()); err != nil {
+		return fmt.Errorf("journal: %w", err)
+	}
+	return nil
+}
+
+// journalCommit records that the staged batch finished, or was undone,
+// and empties the journal: no other write was in flight during the batch.
+func (s *Store) journalCommit() {
+	s.journalMu.Lock()
+	defer s.journalMu.Unlock()
+	_ = s.appendJournal(journalEntry{path: "/", hash: "-"}.line("commit"))
+	_ = os.Truncate(filepath.Join(s.root, journalFile), 0)
+}
+
+// appendJournal appends line to the journal and syncs it. The caller
+// holds journalMu.
+func (s *Store) appendJournal(line string) error {
+	f, err := os.OpenFile(filepath.Join(s.root, journalFile), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(line); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// pendingWrites returns the journal's begins without an end, and its
+// stages without a commit, in order.
+func (s *Store) pendingWrites() (pending, staged []journalEntry, err error) {
+	data, err := os.ReadFile(filepath.Join(s.root, journalFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		// A line torn by the crash is skipped: its write never started.
+		fields := strings.SplitN(sc.Text(), "\t", 4)
+		if len(fields) != 4 {
+			continue
+		}
+		v, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+		e := journalEntry{path: fields[3], version: v, hash: fields[2]}
+		switch fields[0] {
+		case "begin":
+			pending = append(pending, e)
+		case "end":
+			if i := slices.Index(pending, e); i >= 0 {
+				pending = slices.Delete(pending, i, i+1)
+			}
+		case "stage":
+			staged = append(staged, e)
+		case "commit":
+			staged = nil
+		}
+	}
+	return pending, staged, sc.Err()
+}
+
+// Recover repairs the writes the journal shows were interrupted, then
+// empties it. A version file that was written in full is made current;
+// one that was not is removed, along with any temp link left behind. An
+// interrupted batch is undone as a whole. It returns a description of
+// each repair. Call it at startup, before the store serves requests.
+func (s *Store) Recover() ([]string, error) {
+	pending, staged, err := s.pendingWrites()
+	if err != nil {
+		return nil, fmt.Errorf("read journal: %w", err)
+	}
+
+	var repairs []string
+	for _, e := range staged {
+		repair, err := s.undoStaged(e)
+		if err != nil {
+			return repairs, fmt.Errorf("%s: %w", e.path, err)
+		}
+		if repair != "" {
+			repairs = append(repairs, e.path+": "+repair)
+		}
+	}
+
+	// Writers racing for the same version each journal a begin; at most
+	// one of them created the file.
+	type write struct {
+		path    string
+		version int
+	}
+	var order []write
+	hashes := make(map[write][]string)
+	for _, e := range pending {
+		w := write{e.path, e.version}
+		if hashes[w] == nil {
+			order = append(order, w)
+		}
+		hashes[w] = append(hashes[w], e.hash)
+	}
+
+	for _, e := range order {
+		repair, err := s.recoverWrite(e.path, e.version, hashes[e])
+		if err != nil {
+			return repairs, fmt.Errorf("%s: %w", e.path, err)
+		}
+		if repair != "" {
+			repairs = append(repairs, e.path+": "+repair)
+		}
+	}
+	if err := os.Truncate(filepath.Join(s.root, journalFile), 0); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return repairs, fmt.Errorf("reset journal: %w", err)
+	}
+	return repairs, nil
+}
+
+// recoverWrite finishes or undoes an interrupted write of version of
+// reqPath, whose file should have one of hashes.
+func (s *Store) recoverWrite(reqPath string, version int, hashes []string) (string, error) {
+	if containsDotDot(reqPath) {
+		return "", nil
+	}
+	cleaned := strings.TrimLeft(filepath.Clean(reqPath), "/")
+	base := filepath.Base(cleaned)
+	dir := filepath.Join(s.root, filepath.Dir(cleaned))
+	currentFile := filepath.Join(dir, base)
+	name := fmt.Sprintf("%s.v%d", base, version)
+	versionFile := filepath.Join(dir, "versions", name)
+
+	var repairs []string
+	if err := os.Remove(currentFile + ".tmp"); err == nil {
+		repairs = append(repairs, "removed stale temp link")
+	}
+
+	data, err := readVersionFile(versionFile)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		// The crash came before the version file was created.
+	case err != nil:
+		return "", err
+	case !slices.Contains(hashes, fmt.Sprintf("sha256-%x", sha256.Sum256(data))):
+		if s.CurrentVersion(reqPath) != version {
+			repairs = append(repairs, fmt.Sprintf("v%d is incomplete but later versions follow it; left in place", version))
+			break
+		}
+		if err := os.Remove(versionFile); err != nil {
+			return "", err
+		}
+		repairs = append(repairs, fmt.Sprintf("removed incomplete v%d", version))
+	default:
+		if target, err := os.Readlink(currentFile); err != nil || filepath.Base(target) != name {
+			if s.CurrentVersion(reqPath) != version {
+				// A later write already superseded this one.
+				break
+			}
+			tmpLink := currentFile + ".tmp"
+			if err := os.Symlink(filepath.Join("versions", name), tmpLink); err != nil {
+				return "", err
+			}
+			if err := os.Rename(tmpLink, currentFile); err != nil {
+				_ = os.Remove(tmpLink)
+				return "", err
+			}
+			repairs = append(repairs, fmt.Sprintf("pointed at v%d, which was written but not made current", version))
+		}
+	}
+	return strings.Join(repairs, "; "), nil
+}
+
+// undoStaged undoes an interrupted batch's write of a version: if its
+// file is the one the batch wrote, the current file is pointed back at
+// the version before it, or removed if it was the first, and the file is
+// removed.
+func (s *Store) undoStaged(e journalEntry) (string, error) {
+	if containsDotDot(e.path) {
+		return "", nil
+	}
+	cleaned := strings.TrimLeft(filepath.Clean(e.path), "/")
+	base := filepath.Base(cleaned)
+	dir := filepath.Join(s.root, filepath.Dir(cleaned))
+	currentFile := filepath.Join(dir, base)
+	name := fmt.Sprintf("%s.v%d", base, e.version)
+	versionFile := filepath.Join(dir, "versions", name)
+
+	var repairs []string
+	if err := os.Remove(currentFile + ".tmp"); err == nil {
+		repairs = append(repairs, "removed stale temp link")
+	}
+
+	data, err := readVersionFile(versionFile)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		// The crash came before the version file was created.
+		return strings.Join(repairs, "; "), nil
+	case err != nil:
+		return "", err
+	case fmt.Sprintf("sha256-%x", sha256.Sum256(data)) != e.hash && s.CurrentVersion(e.path) != e.version:
+		// Not the batch's file, and not current: a later write made it.
+		return strings.Join(repairs, "; "), nil
+	}
+
+	if target, err := os.Readlink(currentFile); err == nil && filepath.Base(target) == name {
+		if e.version == 1 {
+			err = os.Remove(currentFile)
+		} else {
+			err = pointCurrent(currentFile, filepath.Join("versions", fmt.Sprintf("%s.v%d", base, e.version-1)))
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	if err := os.Remove(versionFile); err != nil {
+		return "", err
+	}
+	repairs = append(repairs, fmt.Sprintf("removed v%d of a batch that did not finish", e.version))
+	return strings.Join(repairs, "; "), nil
+}
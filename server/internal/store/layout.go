@@ -0,0 +1,89 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CheckLayout walks the content root and reports inconsistencies in the
+// versioned layout: current-version symlinks that are broken, escape the
+// root, point outside their versions/ directory or at an older version,
+// stale temp links left by an interrupted write, broken hash chains, and
+// journaled writes that Recover has yet to repair.
+// Flat files are not reported; they are migrated on their first write.
+// Each problem names the request path it concerns.
+func (s *Store) CheckLayout() ([]string, error) {
+	absRoot, err := s.resolvedRoot()
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []string
+	report := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	pending, staged, err := s.pendingWrites()
+	if err != nil {
+		report("%s: unreadable: %v", journalFile, err)
+	}
+	for _, e := range pending {
+		report("%s: write of v%d was interrupted; the server repairs it at startup", e.path, e.version)
+	}
+	for _, e := range staged {
+		report("%s: batch writing v%d was interrupted; the server undoes it at startup", e.path, e.version)
+	}
+
+	err = filepath.WalkDir(absRoot, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			report("%s: unreadable: %v", path, err)
+			return nil
+		}
+		if d.IsDir() {
+			if d.Name() == "versions" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type()&os.ModeSymlink == 0 {
+			return nil
+		}
+		rel, err := filepath.Rel(absRoot, path)
+		if err != nil {
+			return nil
+		}
+		reqPath := "/" + filepath.ToSlash(rel)
+		if strings.HasSuffix(path, ".tmp") {
+			report("%s: stale temp link from an interrupted write; remove it", reqPath)
+			return nil
+		}
+
+		resolved, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			report("%s: broken symlink: %v", reqPath, err)
+			return nil
+		}
+		if !isContained(resolved, absRoot) {
+			report("%s: symlink escapes the content root (%s)", reqPath, resolved)
+			return nil
+		}
+		wantDir := filepath.Join(filepath.Dir(path), "versions")
+		if realDir, err := filepath.EvalSymlinks(wantDir); err == nil {
+			wantDir = realDir
+		}
+		if filepath.Dir(resolved) != wantDir {
+			report("%s: symlink points outside its versions/ directory (%s)", reqPath, resolved)
+			return nil
+		}
+		if latest := fmt.Sprintf("%s.v%d", d.Name(), s.CurrentVersion(reqPath)); filepath.Base(resolved) != latest {
+			report("%s: symlink points at %s but the latest version is %s", reqPath, filepath.Base(resolved), latest)
+		}
+		if err := s.VerifyChain(reqPath); err != nil {
+			report("%s: %v", reqPath, err)
+		}
+		return nil
+	})
+	return problems, err
+}
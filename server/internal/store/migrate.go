@@ -0,0 +1,237 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// LayoutVersion is the content directory layout this store writes. Each
+// layout change adds a migration that upgrades directories from the one
+// before it.
+//
+//	0  no marker: documents may be flat files, which are not served
+//	1  every document is a symlink into its versions/ directory
+const LayoutVersion = 1
+
+// layoutFile records the layout version of a content directory. Dot-files
+// are never listed or served.
+const layoutFile = ".demarkus-layout"
+
+// MigrationStep is one change made by a migration.
+type MigrationStep struct {
+	Path   string // request path
+	Action string
+}
+
+func (m MigrationStep) String() string {
+	return m.Path + ": " + m.Action
+}
+
+// migration upgrades a directory to layout version to. plan lists the
+// changes without making them; apply makes one of them.
+type migration struct {
+	to    int
+	plan  func(s *Store, absRoot string) ([]migrationChange, error)
+	apply func(s *Store, absRoot string, c migrationChange) error
+}
+
+// migrationChange is a planned step with the file it changes.
+type migrationChange struct {
+	MigrationStep
+	file   string // absolute path, backed up before apply
+	remove bool
+}
+
+var migrations = []migration{
+	{to: 1, plan: planVersionedLayout, apply: applyVersionedLayout},
+}
+
+// Layout returns the layout version recorded in the content directory,
+// or 0 when there is none.
+func (s *Store) Layout() (int, error) {
+	data, err := os.ReadFile(filepath.Join(s.root, layoutFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("%s: invalid layout version %q", layoutFile, strings.TrimSpace(string(data)))
+	}
+	if v > LayoutVersion {
+		return v, fmt.Errorf("%s: layout version %d is newer than this server supports (%d)", layoutFile, v, LayoutVersion)
+	}
+	return v, nil
+}
+
+// PlanMigration reports the current layout version and the steps Migrate
+// would take, without changing anything. Steps of later migrations are
+// planned against the current directory, so the plan is only a preview
+// when more than one migration is pending.
+func (s *Store) PlanMigration() (from int, steps []MigrationStep, err error) {
+	from, err = s.Layout()
+	if err != nil {
+		return from, nil, err
+	}
+	absRoot, err := s.resolvedRoot()
+	if err != nil {
+		return from, nil, err
+	}
+	for _, m := range migrations {
+		if m.to <= from {
+			continue
+		}
+		changes, err := m.plan(s, absRoot)
+		if err != nil {
+			return from, nil, err
+		}
+		for _, c := range changes {
+			steps = append(steps, c.MigrationStep)
+		}
+	}
+	return from, steps, nil
+}
+
+// Migrate upgrades the content directory in place to LayoutVersion. Each
+// file is copied under backupDir, at its path relative to the root, before
+// it is changed. The layout version is recorded only once every step has
+// succeeded, so an interrupted migration can be run again.
+func (s *Store) Migrate(backupDir string) ([]MigrationStep, error) {
+	from, err := s.Layout()
+	if err != nil {
+		return nil, err
+	}
+	absRoot, err := s.resolvedRoot()
+	if err != nil {
+		return nil, err
+	}
+	var done []MigrationStep
+	for _, m := range migrations {
+		if m.to <= from {
+			continue
+		}
+		changes, err := m.plan(s, absRoot)
+		if err != nil {
+			return done, err
+		}
+		for _, c := range changes {
+			if err := backupFile(absRoot, c.file, backupDir); err != nil {
+				return done, fmt.Errorf("%s: backup: %w", c.Path, err)
+			}
+			if err := m.apply(s, absRoot, c); err != nil {
+				return done, fmt.Errorf("%s: %w", c.Path, err)
+			}
+			done = append(done, c.MigrationStep)
+		}
+	}
+	if from < LayoutVersion {
+		marker := filepath.Join(s.root, layoutFile)
+		if err := os.WriteFile(marker, []byte(strconv.Itoa(LayoutVersion)+"\n"), 0o644); err != nil {
+			return done, fmt.Errorf("record layout version: %w", err)
+		}
+	}
+	return done, nil
+}
+
+// planVersionedLayout finds flat markdown files, which become the latest
+// version of their document, and temp links left by interrupted writes.
+func planVersionedLayout(_ *Store, absRoot string) ([]migrationChange, error) {
+	var changes []migrationChange
+	err := filepath.WalkDir(absRoot, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if name == "versions" || (strings.HasPrefix(name, ".") && path != absRoot) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(name, ".") {
+			return nil
+		}
+		rel, err := filepath.Rel(absRoot, path)
+		if err != nil {
+			return err
+		}
+		reqPath := "/" + filepath.ToSlash(rel)
+		switch {
+		case d.Type()&os.ModeSymlink != 0 && strings.HasSuffix(name, ".tmp"):
+			changes = append(changes, migrationChange{MigrationStep{reqPath, "remove stale temp link"}, path, true})
+		case d.Type().IsRegular() && strings.HasSuffix(name, ".md"):
+			changes = append(changes, migrationChange{MigrationStep{reqPath, "promote flat file to a versioned document"}, path, false})
+		}
+		return nil
+	})
+	return changes, err
+}
+
+func applyVersionedLayout(s *Store, _ string, c migrationChange) error {
+	if c.remove {
+		return os.Remove(c.file)
+	}
+	content, err := os.ReadFile(c.file)
+	if err != nil {
+		return err
+	}
+	// Write versions the flat content: as v1, or after any existing
+	// history. Identical content needs no new version, only the link.
+	_, err = s.Write(c.Path, content, nil)
+	if !errors.Is(err, ErrNotModified) {
+		return err
+	}
+	base := filepath.Base(c.file)
+	rel := filepath.Join("versions", fmt.Sprintf("%s.v%d", base, s.CurrentVersion(c.Path)))
+	tmp := c.file + ".tmp"
+	_ = os.Remove(tmp)
+	if err := os.Symlink(rel, tmp); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.file)
+}
+
+// backupFile copies file (or the symlink itself) to the same relative path
+// under backupDir.
+func backupFile(absRoot, file, backupDir string) error {
+	rel, err := filepath.Rel(absRoot, file)
+	if err != nil {
+		return err
+	}
+	dst := filepath.Join(backupDir, rel)
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	info, err := os.Lstat(file)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(file)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, dst)
+	}
+	src, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = src.Close() }()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, src); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
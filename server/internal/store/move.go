@@ -0,0 +1,236 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/latebit/demarkus/protocol"
+)
+
+// ErrDestinationExists is returned by Move when a document already exists
+// at the new path.
+var ErrDestinationExists = fmt.Errorf("destination exists")
+
+// movedFromKey is the store frontmatter field recording the path a
+// document was moved from, in the version Move writes at its new path.
+const movedFromKey = "moved-from"
+
+// Move renames a document, keeping its version history. The version files
+// keep their bytes under the new name, so the hash chain still verifies,
+// and a new version recording the old path as moved-from is added on top:
+//
+//	---
+//	version: 4
+//	archived: false
+//	moved-from: /drafts/post.md
+//	previous-hash: sha256-<hex>
+//	---
+//
+// With redirect, the old path is added to the document's aliases, so that
+// requests for it are redirected to the new one.
+//
+// The versions are hard-linked to their new names before anything is
+// removed: a crash leaves the document readable at its old path, and at
+// most also at the new one. The old path is removed once the new one is
+// current.
+//
+// Returns os.ErrNotExist if there is no document at oldPath, ErrArchived
+// if it is archived, and ErrDestinationExists if newPath is taken.
+func (s *Store) Move(oldPath, newPath string, redirect bool) (*Document, error) {
+	s.writeMu.RLock()
+	defer s.writeMu.RUnlock()
+
+	for _, p := range []string{oldPath, newPath} {
+		if _, err := s.resolve(p); err != nil {
+			if os.IsNotExist(err) {
+				return nil, os.ErrNotExist
+			}
+			return nil, fmt.Errorf("resolve path: %w", err)
+		}
+	}
+	if path.Clean(oldPath) == path.Clean(newPath) {
+		return nil, fmt.Errorf("cannot move %s onto itself", oldPath)
+	}
+
+	versions := s.findVersions(oldPath)
+	if len(versions) == 0 {
+		return nil, os.ErrNotExist
+	}
+	current := s.CurrentVersion(oldPath)
+
+	oldCleaned := strings.TrimLeft(filepath.Clean(oldPath), "/")
+	oldBase := filepath.Base(oldCleaned)
+	oldDir := filepath.Join(s.root, filepath.Dir(oldCleaned))
+	oldVersionsDir := filepath.Join(oldDir, "versions")
+
+	newCleaned := strings.TrimLeft(filepath.Clean(newPath), "/")
+	newBase := filepath.Base(newCleaned)
+	newDir := filepath.Join(s.root, filepath.Dir(newCleaned))
+	newVersionsDir := filepath.Join(newDir, "versions")
+	newCurrent := filepath.Join(newDir, newBase)
+
+	if _, err := os.Lstat(newCurrent); err == nil || s.CurrentVersion(newPath) > 0 {
+		return nil, ErrDestinationExists
+	}
+
+	data, err := readVersionFile(filepath.Join(oldVersionsDir, fmt.Sprintf("%s.v%d", oldBase, current)))
+	if err != nil {
+		return nil, fmt.Errorf("read v%d: %w", current, err)
+	}
+	if isArchived(data) {
+		return nil, ErrArchived
+	}
+	body := extractBody(data)
+	meta := extractMetadata(data)
+	movedFrom := path.Clean("/" + oldPath)
+	if redirect {
+		aliases, _ := ParseAliases(meta[aliasesKey])
+		if !slices.Contains(aliases, movedFrom) {
+			aliases = append(aliases, movedFrom)
+		}
+		if meta == nil {
+			meta = make(map[string]string)
+		}
+		meta[aliasesKey] = "[" + strings.Join(aliases, ", ") + "]"
+	}
+
+	if err := os.MkdirAll(newVersionsDir, 0o755); err != nil {
+		return nil, fmt.Errorf("create versions dir: %w", err)
+	}
+	var linked []string
+	unlink := func() {
+		for _, f := range linked {
+			_ = os.Remove(f)
+		}
+	}
+	for _, v := range versions {
+		dst := filepath.Join(newVersionsDir, fmt.Sprintf("%s.v%d", newBase, v.Version))
+		if err := os.Link(filepath.Join(oldVersionsDir, fmt.Sprintf("%s.v%d", oldBase, v.Version)), dst); err != nil {
+			unlink()
+			if os.IsExist(err) {
+				return nil, ErrDestinationExists
+			}
+			return nil, fmt.Errorf("link v%d: %w", v.Version, err)
+		}
+		linked = append(linked, dst)
+	}
+
+	next := current + 1
+	stored, err := buildVersionFile(newVersionsDir, newBase, next, body, meta)
+	if err != nil {
+		unlink()
+		return nil, err
+	}
+	stored = []byte(strings.Replace(string(stored), "archived: false\n", "archived: false\n"+movedFromKey+": "+movedFrom+"\n", 1))
+	if int64(len(stored)) > int64(protocol.MaxBodyLength+maxStoreFrontmatter) {
+		unlink()
+		return nil, fmt.Errorf("content exceeds size limit")
+	}
+
+	if s.journal {
+		entry, err := s.journalBegin(newPath, next, stored)
+		if err != nil {
+			unlink()
+			return nil, err
+		}
+		defer s.journalEnd(entry)
+	}
+
+	versionFile := filepath.Join(newVersionsDir, fmt.Sprintf("%s.v%d", newBase, next))
+	f, err := os.OpenFile(versionFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		unlink()
+		return nil, fmt.Errorf("create version file: %w", err)
+	}
+	linked = append(linked, versionFile)
+	if _, err := f.Write(stored); err != nil {
+		_ = f.Close()
+		unlink()
+		return nil, fmt.Errorf("write version file: %w", err)
+	}
+	if s.journal {
+		if err := f.Sync(); err != nil {
+			_ = f.Close()
+			unlink()
+			return nil, fmt.Errorf("sync version file: %w", err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		unlink()
+		return nil, fmt.Errorf("close version file: %w", err)
+	}
+
+	tmpLink := newCurrent + ".tmp"
+	_ = os.Remove(tmpLink)
+	if err := os.Symlink(filepath.Join("versions", fmt.Sprintf("%s.v%d", newBase, next)), tmpLink); err != nil {
+		unlink()
+		return nil, fmt.Errorf("symlink current file: %w", err)
+	}
+	if err := os.Rename(tmpLink, newCurrent); err != nil {
+		_ = os.Remove(tmpLink)
+		unlink()
+		return nil, fmt.Errorf("rename current file: %w", err)
+	}
+
+	// The document is now current at its new path; drop the old one as
+	// Purge does, current symlink first.
+	if err := os.Remove(filepath.Join(oldDir, oldBase)); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("remove old current file: %w", err)
+	}
+	for _, v := range versions {
+		name := fmt.Sprintf("%s.v%d", oldBase, v.Version)
+		if err := os.Remove(filepath.Join(oldVersionsDir, name)); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("remove %s: %w", name, err)
+		}
+	}
+
+	s.RemoveHashEntry(oldPath)
+	s.setAliases(oldPath, nil)
+	s.unindexDocument(oldPath)
+	s.forgetInfo(oldPath)
+	s.moveChainStatus(oldPath, newPath)
+	s.moveAgents(oldPath, newPath)
+	s.UpdateHashIndex(newPath, body)
+	s.setAliases(newPath, meta)
+	s.indexDocument(newPath, meta, body)
+
+	info, err := os.Stat(versionFile)
+	if err != nil {
+		return nil, fmt.Errorf("stat version file: %w", err)
+	}
+	modified := info.ModTime().UTC().Truncate(time.Second)
+	s.recordChange(newPath, next, modified)
+	return &Document{
+		Content:      body,
+		Modified:     modified,
+		Version:      next,
+		Metadata:     meta,
+		PreviousHash: extractPreviousHash(stored),
+		MovedFrom:    movedFrom,
+	}, nil
+}
+
+// extractMovedFrom returns the moved-from field of a version file's store
+// frontmatter, or "" if absent.
+func extractMovedFrom(data []byte) string {
+	content := string(data)
+	if !strings.HasPrefix(content, "---\n") {
+		return ""
+	}
+	end := strings.Index(content[4:], "\n---\n")
+	if end == -1 {
+		return ""
+	}
+	for line := range strings.SplitSeq(content[4:4+end], "\n") {
+		key, val, ok := strings.Cut(line, ": ")
+		if ok && strings.TrimSpace(key) == movedFromKey {
+			return strings.TrimSpace(val)
+		}
+	}
+	return ""
+}
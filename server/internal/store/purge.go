@@ -0,0 +1,148 @@
+package store
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tombstoneSuffix names the record a purge leaves in the versions
+// directory, e.g. versions/doc.md.purged. It cannot be mistaken for a
+// version file, which always ends in .vN.
+const tombstoneSuffix = ".purged"
+
+// Tombstone records that a document's history existed and was deleted.
+type Tombstone struct {
+	Purged   time.Time
+	Versions int    // the number of versions deleted
+	LastHash string // sha256-<hex> of the last version file, as previous-hash would record it
+}
+
+// Purge deletes a document and every version of it, freeing the disk that
+// archiving keeps, and leaves a tombstone that Tombstone returns. The
+// tombstone is written first, so a purge interrupted by a crash leaves
+// the document intact and can simply be repeated. Publishing to the path
+// again starts a new history at v1; the tombstone stays until the next
+// purge replaces it.
+// Returns os.ErrNotExist if the document has no version history.
+func (s *Store) Purge(reqPath string) (*Tombstone, error) {
+	s.writeMu.RLock()
+	defer s.writeMu.RUnlock()
+
+	if _, err := s.resolve(reqPath); err != nil {
+		if os.IsNotExist(err) {
+			return nil, os.ErrNotExist
+		}
+		return nil, fmt.Errorf("resolve path: %w", err)
+	}
+
+	versions := s.findVersions(reqPath)
+	if len(versions) == 0 {
+		return nil, os.ErrNotExist
+	}
+	current := s.CurrentVersion(reqPath)
+
+	cleaned := strings.TrimLeft(filepath.Clean(reqPath), "/")
+	base := filepath.Base(cleaned)
+	dir := filepath.Dir(cleaned)
+	versionsDir := filepath.Join(s.root, dir, "versions")
+
+	last, err := readVersionFile(filepath.Join(versionsDir, fmt.Sprintf("%s.v%d", base, current)))
+	if err != nil {
+		return nil, fmt.Errorf("read v%d: %w", current, err)
+	}
+	ts := &Tombstone{
+		Purged:   time.Now().UTC().Truncate(time.Second),
+		Versions: len(versions),
+		LastHash: fmt.Sprintf("sha256-%x", sha256.Sum256(last)),
+	}
+	tombFile := filepath.Join(versionsDir, base+tombstoneSuffix)
+	tmp := tombFile + ".tmp"
+	if err := os.WriteFile(tmp, formatTombstone(ts), 0o644); err != nil {
+		return nil, fmt.Errorf("write tombstone: %w", err)
+	}
+	if err := os.Rename(tmp, tombFile); err != nil {
+		_ = os.Remove(tmp)
+		return nil, fmt.Errorf("rename tombstone: %w", err)
+	}
+
+	// Drop the current symlink before the versions it points at, so
+	// readers see the document vanish rather than break.
+	if err := os.Remove(filepath.Join(s.root, dir, base)); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("remove current file: %w", err)
+	}
+	for _, v := range versions {
+		name := fmt.Sprintf("%s.v%d", base, v.Version)
+		if err := os.Remove(filepath.Join(versionsDir, name)); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("remove %s: %w", name, err)
+		}
+	}
+
+	s.RemoveHashEntry(reqPath)
+	s.setAliases(reqPath, nil)
+	s.unindexDocument(reqPath)
+	s.forgetInfo(reqPath)
+	s.forgetChainStatus(reqPath)
+	s.forgetAgents(reqPath)
+	return ts, nil
+}
+
+// Tombstone returns the record left by the last purge of a document.
+// Returns os.ErrNotExist if it was never purged.
+func (s *Store) Tombstone(reqPath string) (*Tombstone, error) {
+	cleaned := strings.TrimLeft(filepath.Clean(reqPath), "/")
+	tombPath := "/" + filepath.Join(filepath.Dir(cleaned), "versions", filepath.Base(cleaned)+tombstoneSuffix)
+	filePath, err := s.resolve(tombPath)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return parseTombstone(data)
+}
+
+// formatTombstone renders a tombstone in the store's frontmatter style:
+//
+//	---
+//	purged: 2026-01-02T15:04:05Z
+//	versions: 3
+//	last-hash: sha256-<hex>
+//	---
+func formatTombstone(ts *Tombstone) []byte {
+	return fmt.Appendf(nil, "---\npurged: %s\nversions: %d\nlast-hash: %s\n---\n",
+		ts.Purged.Format(time.RFC3339), ts.Versions, ts.LastHash)
+}
+
+func parseTombstone(data []byte) (*Tombstone, error) {
+	content, ok := strings.CutPrefix(string(data), "---\n")
+	if !ok {
+		return nil, fmt.Errorf("invalid tombstone format")
+	}
+	fm, _, ok := strings.Cut(content, "---\n")
+	if !ok {
+		return nil, fmt.Errorf("invalid tombstone format")
+	}
+	ts := &Tombstone{}
+	for line := range strings.Lines(fm) {
+		key, value, _ := strings.Cut(strings.TrimSpace(line), ": ")
+		var err error
+		switch key {
+		case "purged":
+			ts.Purged, err = time.Parse(time.RFC3339, value)
+		case "versions":
+			ts.Versions, err = strconv.Atoi(value)
+		case "last-hash":
+			ts.LastHash = value
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid tombstone %s: %w", key, err)
+		}
+	}
+	return ts, nil
+}
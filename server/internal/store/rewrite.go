@@ -0,0 +1,53 @@
+package store
+
+import (
+	"bytes"
+	"cmp"
+	"os"
+	"slices"
+)
+
+// Rewrite is a document RewriteAll changed.
+type Rewrite struct {
+	Path    string
+	Version int // the version written, or the current one for a dry run
+}
+
+// RewriteAll passes the body of every current, unarchived document to
+// rewrite, and writes each one it changes as a new version with the same
+// metadata. The new versions are written with one WriteBatch, so either
+// every document is rewritten or none is. It returns the documents
+// changed, sorted by path; with dryRun it writes nothing.
+func (s *Store) RewriteAll(rewrite func(reqPath string, body []byte) []byte, dryRun bool) ([]Rewrite, error) {
+	var (
+		writes   []BatchWrite
+		rewrites []Rewrite
+	)
+	err := s.walkCurrent(func(reqPath string, data []byte, _ os.FileInfo) {
+		if isArchived(data) {
+			return
+		}
+		body := extractBody(data)
+		changed := rewrite(reqPath, body)
+		if bytes.Equal(changed, body) {
+			return
+		}
+		version := s.CurrentVersion(reqPath)
+		writes = append(writes, BatchWrite{Path: reqPath, ExpectedVersion: version, Content: changed, Meta: extractMetadata(data)})
+		rewrites = append(rewrites, Rewrite{Path: reqPath, Version: version})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !dryRun && len(writes) > 0 {
+		results, err := s.WriteBatch(writes)
+		if err != nil {
+			return nil, err
+		}
+		for i, r := range results {
+			rewrites[i].Version = r.Doc.Version
+		}
+	}
+	slices.SortFunc(rewrites, func(a, b Rewrite) int { return cmp.Compare(a.Path, b.Path) })
+	return rewrites, nil
+}
@@ -0,0 +1,268 @@
+package store
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// titleWeight is how much more a term in a document's title counts than
+// one in its body.
+const titleWeight = 5
+
+// snippetWidth is the approximate length of a search result snippet.
+const snippetWidth = 100
+
+// searchIndex is an inverted index over the current, unarchived documents.
+// Bodies are not kept: snippets are cut from the document when a search
+// returns it.
+type searchIndex struct {
+	titles   map[string]string         // path → title
+	terms    map[string]map[string]int // path → term → weighted frequency
+	postings map[string]map[string]int // term → path → weighted frequency
+
+	// The metadata search filters match (searchFilters), lowercased.
+	values map[string]map[string][]string        // path → filter → values
+	facets map[string]map[string]map[string]bool // filter → value → paths
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{
+		titles:   make(map[string]string),
+		terms:    make(map[string]map[string]int),
+		postings: make(map[string]map[string]int),
+		values:   make(map[string]map[string][]string),
+		facets:   make(map[string]map[string]map[string]bool),
+	}
+}
+
+// add indexes a document, replacing what was indexed for its path.
+func (idx *searchIndex) add(reqPath string, meta map[string]string, body []byte) {
+	idx.remove(reqPath)
+	if !isText(meta["content-type"]) {
+		// Only a binary asset's declared title is searchable.
+		body = nil
+	}
+	title := documentTitle(meta, body)
+	terms := make(map[string]int)
+	for _, t := range tokenize(title) {
+		terms[t] += titleWeight
+	}
+	for _, t := range tokenize(string(body)) {
+		terms[t]++
+	}
+	idx.titles[reqPath] = title
+	idx.terms[reqPath] = terms
+	for t, n := range terms {
+		if idx.postings[t] == nil {
+			idx.postings[t] = make(map[string]int)
+		}
+		idx.postings[t][reqPath] = n
+	}
+	values := facetValues(meta)
+	idx.values[reqPath] = values
+	for name, vs := range values {
+		if idx.facets[name] == nil {
+			idx.facets[name] = make(map[string]map[string]bool)
+		}
+		for _, v := range vs {
+			if idx.facets[name][v] == nil {
+				idx.facets[name][v] = make(map[string]bool)
+			}
+			idx.facets[name][v][reqPath] = true
+		}
+	}
+}
+
+// remove drops a document from the index.
+func (idx *searchIndex) remove(reqPath string) {
+	for t := range idx.terms[reqPath] {
+		delete(idx.postings[t], reqPath)
+		if len(idx.postings[t]) == 0 {
+			delete(idx.postings, t)
+		}
+	}
+	delete(idx.terms, reqPath)
+	delete(idx.titles, reqPath)
+	for name, vs := range idx.values[reqPath] {
+		for _, v := range vs {
+			delete(idx.facets[name][v], reqPath)
+			if len(idx.facets[name][v]) == 0 {
+				delete(idx.facets[name], v)
+			}
+		}
+	}
+	delete(idx.values, reqPath)
+}
+
+// SearchHit is one result of Search.
+type SearchHit struct {
+	Path    string
+	Title   string
+	Score   int
+	Snippet string // line of the body around the first matching term
+}
+
+// indexDocument indexes a written or unarchived document for Search and
+// Backlinks.
+func (s *Store) indexDocument(reqPath string, meta map[string]string, body []byte) {
+	s.searchMu.Lock()
+	defer s.searchMu.Unlock()
+	s.searchIdx.add(reqPath, meta, body)
+	s.linkIdx.add(reqPath, meta, body)
+}
+
+// unindexDocument removes an archived document from Search and Backlinks.
+func (s *Store) unindexDocument(reqPath string) {
+	s.searchMu.Lock()
+	defer s.searchMu.Unlock()
+	s.searchIdx.remove(reqPath)
+	s.linkIdx.remove(reqPath)
+}
+
+// Search returns the current documents under dir containing every term of
+// query and matching each of its filters (see parseQuery), best first:
+// terms in the title count titleWeight times those in the body, and ties
+// are broken by path. Documents for which visible returns false are left
+// out before limit applies (0 means no limit).
+func (s *Store) Search(dir, query string, limit int, visible func(reqPath string) bool) []SearchHit {
+	terms, filters := parseQuery(query)
+	if len(terms) == 0 && len(filters) == 0 {
+		return nil
+	}
+	prefix := strings.TrimSuffix(path.Clean("/"+dir), "/") + "/"
+
+	s.searchMu.RLock()
+	scores := make(map[string]int)
+	if len(terms) == 0 {
+		for p := range s.searchIdx.matching(filters[0]) {
+			if strings.HasPrefix(p, prefix) {
+				scores[p] = 0
+			}
+		}
+		filters = filters[1:]
+	}
+	for i, t := range terms {
+		matched := make(map[string]int)
+		for p, n := range s.searchIdx.postings[t] {
+			if i == 0 {
+				if strings.HasPrefix(p, prefix) {
+					matched[p] = n
+				}
+			} else if score, ok := scores[p]; ok {
+				matched[p] = score + n
+			}
+		}
+		scores = matched
+	}
+	for _, f := range filters {
+		matched := s.searchIdx.matching(f)
+		for p := range scores {
+			if !matched[p] {
+				delete(scores, p)
+			}
+		}
+	}
+	hits := make([]SearchHit, 0, len(scores))
+	for p, score := range scores {
+		hits = append(hits, SearchHit{Path: p, Title: s.searchIdx.titles[p], Score: score})
+	}
+	s.searchMu.RUnlock()
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Path < hits[j].Path
+	})
+
+	out := hits[:0]
+	for _, h := range hits {
+		if limit > 0 && len(out) == limit {
+			break
+		}
+		if visible != nil && !visible(h.Path) {
+			continue
+		}
+		if doc, err := s.Get(h.Path, 0); err == nil && isText(doc.Metadata["content-type"]) {
+			h.Snippet = snippet(string(extractBody(doc.Content)), terms)
+		}
+		out = append(out, h)
+	}
+	return out
+}
+
+// FirstHeading returns the text of the first level-one ATX heading outside
+// code fences, or "".
+func FirstHeading(content []byte) string {
+	inFence := false
+	for line := range strings.SplitSeq(string(content), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~") {
+			inFence = !inFence
+			continue
+		}
+		if !inFence && strings.HasPrefix(line, "# ") {
+			return strings.TrimSpace(line[2:])
+		}
+	}
+	return ""
+}
+
+// documentTitle is the publisher's title metadata, else the first heading.
+func documentTitle(meta map[string]string, body []byte) string {
+	if title := meta["title"]; title != "" {
+		return title
+	}
+	return FirstHeading(body)
+}
+
+// isText reports whether a document with the given content-type metadata
+// holds text worth indexing; none means markdown.
+func isText(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	return mediaType == "" || strings.HasPrefix(mediaType, "text/")
+}
+
+// tokenize splits text into lowercase words of letters and digits.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// snippet returns the first non-heading line containing one of terms,
+// trimmed to about snippetWidth around the match.
+func snippet(body string, terms []string) string {
+	for line := range strings.SplitSeq(body, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lower := strings.ToLower(line)
+		for _, t := range terms {
+			at := strings.Index(lower, t)
+			if at < 0 {
+				continue
+			}
+			runes := []rune(line)
+			if len(runes) <= snippetWidth {
+				return line
+			}
+			start := len([]rune(lower[:at])) - snippetWidth/3
+			prefix := "…"
+			if start <= 0 {
+				start, prefix = 0, ""
+			}
+			end := start + snippetWidth
+			suffix := "…"
+			if end >= len(runes) {
+				start, end, suffix = len(runes)-snippetWidth, len(runes), ""
+			}
+			return prefix + string(runes[start:end]) + suffix
+		}
+	}
+	return ""
+}
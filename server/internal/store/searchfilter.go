@@ -0,0 +1,99 @@
+package store
+
+import (
+	"strings"
+	"unicode"
+)
+
+// searchFilters maps the filters a search query may hold, as name:value
+// words, to the publisher metadata each one matches.
+var searchFilters = map[string]string{
+	"tag":    "tags",   // one of the comma-separated tags
+	"author": "author", // the author
+	"date":   "date",   // the date, or a prefix of it: date:2026-03
+}
+
+// searchFilter is a name:value word of a search query.
+type searchFilter struct {
+	name  string
+	value string // lowercase
+}
+
+// parseQuery splits a search query into the words to look for and its
+// filters. A filter's value may be quoted to hold spaces: author:"Ada
+// Lovelace". A name:value word whose name is not a filter is searched for
+// as words.
+func parseQuery(query string) (terms []string, filters []searchFilter) {
+	for _, word := range queryWords(query) {
+		name, value, ok := strings.Cut(word, ":")
+		name = strings.ToLower(name)
+		value = strings.ToLower(strings.TrimSpace(strings.Trim(value, `"`)))
+		if _, known := searchFilters[name]; ok && known && value != "" {
+			filters = append(filters, searchFilter{name: name, value: value})
+			continue
+		}
+		terms = append(terms, tokenize(word)...)
+	}
+	return terms, filters
+}
+
+// queryWords splits query at spaces outside double quotes.
+func queryWords(query string) []string {
+	var words []string
+	var word strings.Builder
+	quoted := false
+	for _, r := range query {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			word.WriteRune(r)
+		case unicode.IsSpace(r) && !quoted:
+			if word.Len() > 0 {
+				words = append(words, word.String())
+				word.Reset()
+			}
+		default:
+			word.WriteRune(r)
+		}
+	}
+	if word.Len() > 0 {
+		words = append(words, word.String())
+	}
+	return words
+}
+
+// facetValues returns the lowercase values a document's metadata gives
+// each filter.
+func facetValues(meta map[string]string) map[string][]string {
+	values := make(map[string][]string)
+	for name, key := range searchFilters {
+		v := meta[key]
+		if name == "tag" {
+			for tag := range strings.SplitSeq(v, ",") {
+				if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+					values[name] = append(values[name], tag)
+				}
+			}
+		} else if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+			values[name] = []string{v}
+		}
+	}
+	return values
+}
+
+// matching returns the documents f matches: those with its value, or for
+// date, a value it is a prefix of.
+func (idx *searchIndex) matching(f searchFilter) map[string]bool {
+	if f.name != "date" {
+		return idx.facets[f.name][f.value]
+	}
+	paths := make(map[string]bool)
+	for v, ps := range idx.facets[f.name] {
+		if strings.HasPrefix(v, f.value) {
+			for p := range ps {
+				paths[p] = true
+			}
+		}
+	}
+	return paths
+}
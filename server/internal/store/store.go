@@ -12,6 +12,8 @@
 //	    doc.md.v1
 //	    doc.md.v2
 //	    doc.md.v3
+//
+// Old version files may be gzipped in place (see EnableCompression).
 package store
 
 import (
@@ -33,17 +35,20 @@ import (
 
 // Document holds a document's content and metadata.
 type Document struct {
-	Content  []byte
-	Modified time.Time
-	Version  int
-	Archived bool
-	Metadata map[string]string
+	Content      []byte
+	Modified     time.Time
+	Version      int
+	Archived     bool
+	Metadata     map[string]string
+	PreviousHash string // previous-hash from the store frontmatter; empty for v1
+	MovedFrom    string // moved-from from the store frontmatter; set on the version Move wrote
 }
 
 // VersionInfo describes a single version of a document.
 type VersionInfo struct {
 	Version  int
 	Modified time.Time
+	Agent    string // agent publisher metadata; empty if a person published it
 }
 
 // ErrArchived is returned by Write when the document is archived.
@@ -61,6 +66,10 @@ var ErrConflict = fmt.Errorf("version conflict")
 // file already exists (O_EXCL race with a concurrent writer).
 var ErrVersionExists = fmt.Errorf("version already exists")
 
+// ErrTampered is returned by CheckRecorded when a version file no longer
+// matches the hash the store recorded for it.
+var ErrTampered = fmt.Errorf("document modified outside the server")
+
 // ErrSizeLimit is returned when combined content exceeds protocol.MaxBodyLength.
 var ErrSizeLimit = fmt.Errorf("combined content exceeds size limit")
 
@@ -76,17 +85,55 @@ const metaPrefix = "meta."
 // Store provides read access to a versioned document directory.
 type Store struct {
 	root    string
+	rootMu  sync.RWMutex
+	absRoot string // root resolved by resolvedRoot; "" until then
 	hashMu  sync.RWMutex
 	hashIdx map[string]string // content hash → request path
 	pathIdx map[string]string // request path → content hash (reverse index)
+
+	aliasMu   sync.RWMutex
+	aliasIdx  map[string]string   // alias path → document path
+	aliasesOf map[string][]string // document path → its aliases
+
+	searchMu  sync.RWMutex
+	searchIdx *searchIndex
+	linkIdx   *linkIndex
+	feed      changeLog
+
+	// writeMu is held shared by each write, and exclusively by WriteBatch
+	// so that no other write interleaves with a batch.
+	writeMu sync.RWMutex
+
+	journalMu sync.Mutex
+	journal   bool                 // set by EnableJournal
+	inFlight  map[journalEntry]int // journaled writes not yet ended
+
+	compressKeep int           // set by EnableCompression
+	compressAge  time.Duration // set by EnableCompression
+
+	infoMu  sync.RWMutex
+	infoIdx map[string]infoEntry // request path → DocInfo of a version, see Describe
+
+	chainMu  sync.RWMutex
+	chainIdx map[string]ChainStatus // request path → last check of its chain, see ScanChains
+
+	agentMu  sync.RWMutex
+	agentIdx map[string]map[int]agentEntry // request path → version → its agent, see Versions
 }
 
 // New creates a store rooted at the given directory.
 func New(root string) *Store {
 	return &Store{
-		root:    root,
-		hashIdx: make(map[string]string),
-		pathIdx: make(map[string]string),
+		root:      root,
+		hashIdx:   make(map[string]string),
+		pathIdx:   make(map[string]string),
+		aliasIdx:  make(map[string]string),
+		aliasesOf: make(map[string][]string),
+		searchIdx: newSearchIndex(),
+		linkIdx:   newLinkIndex(),
+		infoIdx:   make(map[string]infoEntry),
+		chainIdx:  make(map[string]ChainStatus),
+		agentIdx:  make(map[string]map[int]agentEntry),
 	}
 }
 
@@ -96,15 +143,51 @@ func contentHash(body []byte) string {
 	return "sha256-" + hex.EncodeToString(h[:])
 }
 
-// BuildHashIndex walks the content root and indexes current versions by content hash.
-// Skips versions/ directories and archived documents.
+// BuildHashIndex walks the content root and indexes current versions by content hash,
+// along with the aliases they declare, their text for Search, their links
+// for Backlinks and their dates for Recent. Skips versions/
+// directories and archived documents.
 func (s *Store) BuildHashIndex() error {
 	s.hashMu.Lock()
 	defer s.hashMu.Unlock()
+	s.aliasMu.Lock()
+	defer s.aliasMu.Unlock()
+	s.searchMu.Lock()
+	defer s.searchMu.Unlock()
 
 	s.hashIdx = make(map[string]string)
 	s.pathIdx = make(map[string]string)
+	s.aliasIdx = make(map[string]string)
+	s.aliasesOf = make(map[string][]string)
+	s.searchIdx = newSearchIndex()
+	s.linkIdx = newLinkIndex()
+
+	var changes []Change
+	defer func() { s.feed = seedFeed(changes) }()
+	return s.walkCurrent(func(reqPath string, data []byte, file os.FileInfo) {
+		// Skip archived documents
+		if isArchived(data) {
+			return
+		}
 
+		body := extractBody(data)
+		hash := contentHash(body)
+		s.hashIdx[hash] = reqPath
+		s.pathIdx[reqPath] = hash
+		meta := extractMetadata(data)
+		s.setAliasesLocked(reqPath, meta)
+		s.searchIdx.add(reqPath, meta, body)
+		s.linkIdx.add(reqPath, meta, body)
+		if c, ok := versionFileChange(reqPath, file); ok {
+			changes = append(changes, c)
+		}
+	})
+}
+
+// walkCurrent calls fn with the request path, current version file and
+// its file info of every versioned document under the root, skipping
+// broken or oversized ones and links that escape the root.
+func (s *Store) walkCurrent(fn func(reqPath string, data []byte, file os.FileInfo)) error {
 	absRoot, err := s.resolvedRoot()
 	if err != nil {
 		return err
@@ -136,25 +219,15 @@ func (s *Store) BuildHashIndex() error {
 		if err != nil || info.Size() > int64(protocol.MaxBodyLength+maxStoreFrontmatter) {
 			return nil // skip unreadable or oversized files
 		}
-		data, err := os.ReadFile(resolved)
+		data, err := readVersionFile(resolved)
 		if err != nil {
 			return nil // skip unreadable files
 		}
-
-		// Skip archived documents
-		if isArchived(data) {
-			return nil
-		}
-
-		body := extractBody(data)
-		hash := contentHash(body)
 		rel, err := filepath.Rel(absRoot, path)
 		if err != nil {
 			return nil
 		}
-		reqPath := "/" + rel
-		s.hashIdx[hash] = reqPath
-		s.pathIdx[reqPath] = hash
+		fn("/"+rel, data, info)
 		return nil
 	})
 }
@@ -169,9 +242,13 @@ func (s *Store) LookupHash(hash string) (string, bool) {
 
 // UpdateHashIndex adds or updates the hash index entry for a document.
 func (s *Store) UpdateHashIndex(reqPath string, body []byte) {
-	hash := contentHash(body)
 	s.hashMu.Lock()
 	defer s.hashMu.Unlock()
+	s.updateHashIndexLocked(reqPath, body)
+}
+
+func (s *Store) updateHashIndexLocked(reqPath string, body []byte) {
+	hash := contentHash(body)
 	// Remove old hash entry for this path (content changed)
 	if oldHash, ok := s.pathIdx[reqPath]; ok {
 		delete(s.hashIdx, oldHash)
@@ -197,6 +274,42 @@ func (s *Store) HashIndexSize() int {
 	return len(s.hashIdx)
 }
 
+// Stats is a snapshot of the store's in-memory indexes, for diagnostics.
+type Stats struct {
+	HashEntries     int  // documents in the content hash index
+	Aliases         int  // alias paths redirecting to a document
+	SearchDocuments int  // documents in the search index
+	SearchTerms     int  // distinct terms in the search index
+	Journal         bool // writes are journaled
+	JournalPending  int  // journaled writes not yet ended
+	Described       int  // documents with a cached DocInfo
+}
+
+// Stats returns the current sizes of the store's indexes.
+func (s *Store) Stats() Stats {
+	var st Stats
+	s.hashMu.RLock()
+	st.HashEntries = len(s.hashIdx)
+	s.hashMu.RUnlock()
+	s.aliasMu.RLock()
+	st.Aliases = len(s.aliasIdx)
+	s.aliasMu.RUnlock()
+	s.searchMu.RLock()
+	st.SearchDocuments = len(s.searchIdx.terms)
+	st.SearchTerms = len(s.searchIdx.postings)
+	s.searchMu.RUnlock()
+	s.journalMu.Lock()
+	st.Journal = s.journal
+	for _, n := range s.inFlight {
+		st.JournalPending += n
+	}
+	s.journalMu.Unlock()
+	s.infoMu.RLock()
+	st.Described = len(s.infoIdx)
+	s.infoMu.RUnlock()
+	return st
+}
+
 // Root returns the content directory path.
 func (s *Store) Root() string {
 	return s.root
@@ -232,19 +345,26 @@ func (s *Store) Get(reqPath string, version int) (*Document, error) {
 		return nil, os.ErrNotExist
 	}
 
-	data, err := os.ReadFile(filePath)
+	data, err := readVersionFile(filePath)
 	if err != nil {
 		return nil, err
 	}
 
-	ver := s.CurrentVersion(reqPath)
+	// The version is that of the file the current symlink led to: a write
+	// creates the next version file before it makes it current.
+	ver, ok := linkedVersion(reqPath, filePath)
+	if !ok {
+		ver = s.CurrentVersion(reqPath)
+	}
 
 	return &Document{
-		Content:  data,
-		Modified: info.ModTime().UTC().Truncate(time.Second),
-		Version:  ver,
-		Archived: isArchived(data),
-		Metadata: extractMetadata(data),
+		Content:      data,
+		Modified:     info.ModTime().UTC().Truncate(time.Second),
+		Version:      ver,
+		Archived:     isArchived(data),
+		Metadata:     extractMetadata(data),
+		PreviousHash: extractPreviousHash(data),
+		MovedFrom:    extractMovedFrom(data),
 	}, nil
 }
 
@@ -294,6 +414,8 @@ func (s *Store) IsDir(reqPath string) (bool, error) {
 }
 
 // Versions returns the version history for a document, newest first.
+// Agents come from an index recorded as each version is written, so a
+// listing does not read the version files again.
 // Returns os.ErrNotExist if the document has no version history.
 func (s *Store) Versions(reqPath string) ([]VersionInfo, error) {
 	filePath, err := s.resolve(reqPath)
@@ -316,11 +438,21 @@ func (s *Store) Versions(reqPath string) ([]VersionInfo, error) {
 	sort.Slice(versions, func(i, j int) bool {
 		return versions[i].Version > versions[j].Version
 	})
+	s.fillAgents(reqPath, versions)
 	return versions, nil
 }
 
 // resolvedRoot returns the absolute, symlink-resolved path for the content root.
+// It is resolved once and memoized, since every request needs it; failures
+// are not cached, so a root that does not exist yet is retried.
 func (s *Store) resolvedRoot() (string, error) {
+	s.rootMu.RLock()
+	cached := s.absRoot
+	s.rootMu.RUnlock()
+	if cached != "" {
+		return cached, nil
+	}
+
 	absRoot, err := filepath.Abs(s.root)
 	if err != nil {
 		return "", fmt.Errorf("resolve root: %w", err)
@@ -329,9 +461,21 @@ func (s *Store) resolvedRoot() (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("resolve root symlinks: %w", err)
 	}
+	s.rootMu.Lock()
+	s.absRoot = resolved
+	s.rootMu.Unlock()
 	return resolved, nil
 }
 
+// ResetRoot forgets the memoized content root, so the next request resolves
+// it again. Call it when the root may have been repointed, e.g. a symlink
+// switched to a new release of the content.
+func (s *Store) ResetRoot() {
+	s.rootMu.Lock()
+	s.absRoot = ""
+	s.rootMu.Unlock()
+}
+
 // isContained reports whether absPath is equal to or beneath absRoot.
 func isContained(absPath, absRoot string) bool {
 	return absPath == absRoot || strings.HasPrefix(absPath, absRoot+string(filepath.Separator))
@@ -397,6 +541,20 @@ func (s *Store) CurrentVersion(reqPath string) int {
 	return latest
 }
 
+// linkedVersion returns the version number of resolved, the file the
+// current file of reqPath resolved to, if it is one of its version files.
+func linkedVersion(reqPath, resolved string) (int, bool) {
+	if filepath.Base(filepath.Dir(resolved)) != "versions" {
+		return 0, false
+	}
+	n, ok := strings.CutPrefix(filepath.Base(resolved), filepath.Base(filepath.Clean(reqPath))+".v")
+	if !ok {
+		return 0, false
+	}
+	v, err := strconv.Atoi(n)
+	return v, err == nil && v > 0
+}
+
 // findVersions looks for versioned files in the versions directory.
 // Returns nil if no versions directory or no matching files exist.
 func (s *Store) findVersions(reqPath string) []VersionInfo {
@@ -455,17 +613,19 @@ func (s *Store) getVersion(reqPath string, version int) (*Document, error) {
 		return nil, fmt.Errorf("file exceeds size limit")
 	}
 
-	data, err := os.ReadFile(filePath)
+	data, err := readVersionFile(filePath)
 	if err != nil {
 		return nil, err
 	}
 
 	return &Document{
-		Content:  data,
-		Modified: info.ModTime().UTC().Truncate(time.Second),
-		Version:  version,
-		Archived: isArchived(data),
-		Metadata: extractMetadata(data),
+		Content:      data,
+		Modified:     info.ModTime().UTC().Truncate(time.Second),
+		Version:      version,
+		Archived:     isArchived(data),
+		Metadata:     extractMetadata(data),
+		PreviousHash: extractPreviousHash(data),
+		MovedFrom:    extractMovedFrom(data),
 	}, nil
 }
 
@@ -479,6 +639,9 @@ func (s *Store) getVersion(reqPath string, version int) (*Document, error) {
 // content. The hash chain remains valid because only subsequent versions
 // hash their predecessor, and the current version (tip) has no successor yet.
 func (s *Store) Archive(reqPath string, archived bool) error {
+	s.writeMu.RLock()
+	defer s.writeMu.RUnlock()
+
 	if _, err := s.resolve(reqPath); err != nil {
 		if os.IsNotExist(err) {
 			return os.ErrNotExist
@@ -506,7 +669,7 @@ func (s *Store) Archive(reqPath string, archived bool) error {
 	}
 
 	// Read current version file
-	data, err := os.ReadFile(versionFile)
+	data, err := readVersionFile(versionFile)
 	if err != nil {
 		return fmt.Errorf("read version file: %w", err)
 	}
@@ -565,9 +728,14 @@ func (s *Store) Archive(reqPath string, archived bool) error {
 
 	if archived {
 		s.RemoveHashEntry(reqPath)
+		s.setAliases(reqPath, nil)
+		s.unindexDocument(reqPath)
 	} else {
 		body := extractBody(data)
+		meta := extractMetadata(data)
 		s.UpdateHashIndex(reqPath, body)
+		s.setAliases(reqPath, meta)
+		s.indexDocument(reqPath, meta, body)
 	}
 
 	return nil
@@ -589,19 +757,67 @@ func (s *Store) Archive(reqPath string, archived bool) error {
 // The previous-hash is the SHA-256 of the raw on-disk bytes of version N-1,
 // forming a hash chain that allows chain integrity to be verified later.
 func (s *Store) Write(reqPath string, content []byte, meta map[string]string) (*Document, error) {
+	s.writeMu.RLock()
+	defer s.writeMu.RUnlock()
+
+	p, doc, err := s.planWrite(reqPath, content, meta)
+	if err != nil {
+		return doc, err
+	}
+
+	if s.journal {
+		entry, err := s.journalBegin(reqPath, p.version, p.stored)
+		if err != nil {
+			return nil, err
+		}
+		defer s.journalEnd(entry)
+	}
+
+	if err := s.createVersionFile(p); err != nil {
+		return nil, err
+	}
+	if err := s.makeCurrent(p); err != nil {
+		return nil, err
+	}
+	return s.written(p)
+}
+
+// writePlan is a new version that passed Write's checks, with the bytes
+// of its version file.
+type writePlan struct {
+	reqPath     string
+	content     []byte
+	meta        map[string]string
+	version     int
+	versionFile string
+	currentFile string
+	stored      []byte
+}
+
+// target is the current file's symlink target once p is current. It is
+// relative so the content directory can be relocated without breaking
+// links.
+func (p writePlan) target() string {
+	return filepath.Join("versions", filepath.Base(p.versionFile))
+}
+
+// planWrite checks a write of content to reqPath and builds the version
+// file it would create. If the document already has this content and
+// metadata, it returns the current version with ErrNotModified.
+func (s *Store) planWrite(reqPath string, content []byte, meta map[string]string) (writePlan, *Document, error) {
 	if int64(len(content)) > protocol.MaxBodyLength {
-		return nil, fmt.Errorf("content exceeds size limit")
+		return writePlan{}, nil, fmt.Errorf("content exceeds size limit")
 	}
 	if err := validateMeta(meta); err != nil {
-		return nil, err
+		return writePlan{}, nil, err
 	}
 
 	// Validate path stays within the store root (resolve handles traversal + symlinks).
 	if _, err := s.resolve(reqPath); err != nil {
 		if os.IsNotExist(err) {
-			return nil, os.ErrNotExist
+			return writePlan{}, nil, os.ErrNotExist
 		}
-		return nil, fmt.Errorf("resolve path: %w", err)
+		return writePlan{}, nil, fmt.Errorf("resolve path: %w", err)
 	}
 
 	cleaned := filepath.Clean(reqPath)
@@ -611,7 +827,7 @@ func (s *Store) Write(reqPath string, content []byte, meta map[string]string) (*
 
 	versionsDir := filepath.Join(s.root, dir, "versions")
 	if err := os.MkdirAll(versionsDir, 0o755); err != nil {
-		return nil, fmt.Errorf("create versions dir: %w", err)
+		return writePlan{}, nil, fmt.Errorf("create versions dir: %w", err)
 	}
 
 	// Determine the next version number. For a truly new document (no current
@@ -622,7 +838,7 @@ func (s *Store) Write(reqPath string, content []byte, meta map[string]string) (*
 		if os.IsNotExist(err) {
 			next = 1
 		} else {
-			return nil, fmt.Errorf("stat current file: %w", err)
+			return writePlan{}, nil, fmt.Errorf("stat current file: %w", err)
 		}
 	} else {
 		next = s.CurrentVersion(reqPath) + 1
@@ -632,22 +848,22 @@ func (s *Store) Write(reqPath string, content []byte, meta map[string]string) (*
 	// TOCTOU gap with handler) and migrate flat files to v1 if needed.
 	if next > 1 {
 		if s.isCurrentArchived(versionsDir, base, next-1) {
-			return nil, ErrArchived
+			return writePlan{}, nil, ErrArchived
 		}
 		if err := s.migrateFlatFile(versionsDir, base, currentFile); err != nil {
-			return nil, err
+			return writePlan{}, nil, err
 		}
 
 		// Skip creating a new version if content and metadata are identical.
 		prevFile := filepath.Join(versionsDir, fmt.Sprintf("%s.v%d", base, next-1))
-		prevData, err := os.ReadFile(prevFile)
+		prevData, err := readVersionFile(prevFile)
 		if err == nil {
 			if bytes.Equal(extractBody(prevData), content) && metaEqual(extractMetadata(prevData), meta) {
 				info, err := os.Stat(prevFile)
 				if err != nil {
-					return nil, fmt.Errorf("stat current version: %w", err)
+					return writePlan{}, nil, fmt.Errorf("stat current version: %w", err)
 				}
-				return &Document{
+				return writePlan{}, &Document{
 					Content:  content,
 					Modified: info.ModTime().UTC().Truncate(time.Second),
 					Version:  next - 1,
@@ -658,65 +874,114 @@ func (s *Store) Write(reqPath string, content []byte, meta map[string]string) (*
 		}
 	}
 
-	versionFile := filepath.Join(versionsDir, fmt.Sprintf("%s.v%d", base, next))
-
 	stored, err := buildVersionFile(versionsDir, base, next, content, meta)
 	if err != nil {
-		return nil, err
+		return writePlan{}, nil, err
 	}
 
 	// Validate stored size after prepending frontmatter.
 	if int64(len(stored)) > int64(protocol.MaxBodyLength+maxStoreFrontmatter) {
-		return nil, fmt.Errorf("content exceeds size limit")
-	}
+		return writePlan{}, nil, fmt.Errorf("content exceeds size limit")
+	}
+
+	return writePlan{
+		reqPath:     reqPath,
+		content:     content,
+		meta:        meta,
+		version:     next,
+		versionFile: filepath.Join(versionsDir, fmt.Sprintf("%s.v%d", base, next)),
+		currentFile: currentFile,
+		stored:      stored,
+	}, nil, nil
+}
 
+// createVersionFile writes p's version file.
+func (s *Store) createVersionFile(p writePlan) error {
 	// Immutability guard + atomic write: O_CREATE|O_EXCL fails if the file
 	// already exists, preventing TOCTOU races between a stat check and rename.
-	f, err := os.OpenFile(versionFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	f, err := os.OpenFile(p.versionFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
 	if err != nil {
 		if os.IsExist(err) {
-			return nil, fmt.Errorf("version %d: %w", next, ErrVersionExists)
+			return fmt.Errorf("version %d: %w", p.version, ErrVersionExists)
 		}
-		return nil, fmt.Errorf("create version file: %w", err)
+		return fmt.Errorf("create version file: %w", err)
 	}
-	if _, err := f.Write(stored); err != nil {
+	if _, err := f.Write(p.stored); err != nil {
 		_ = f.Close()
-		_ = os.Remove(versionFile)
-		return nil, fmt.Errorf("write version file: %w", err)
+		_ = os.Remove(p.versionFile)
+		return fmt.Errorf("write version file: %w", err)
+	}
+	// With a journal, the version file must be on disk before anything
+	// points at it, or recovery could find it torn after a power loss.
+	if s.journal {
+		if err := f.Sync(); err != nil {
+			_ = f.Close()
+			_ = os.Remove(p.versionFile)
+			return fmt.Errorf("sync version file: %w", err)
+		}
 	}
 	if err := f.Close(); err != nil {
-		_ = os.Remove(versionFile)
-		return nil, fmt.Errorf("close version file: %w", err)
+		_ = os.Remove(p.versionFile)
+		return fmt.Errorf("close version file: %w", err)
 	}
+	return nil
+}
 
-	// Atomically update the current file to point at the new version.
-	// Create a temp symlink then rename over the current path so readers
-	// never see a missing file. Use a relative target so the content
-	// directory can be relocated without breaking links.
-	relTarget := filepath.Join("versions", fmt.Sprintf("%s.v%d", base, next))
+// pointCurrent makes currentFile a symlink to target. It creates a temp
+// symlink then renames it over the current path, so readers never see a
+// missing file.
+func pointCurrent(currentFile, target string) error {
 	tmpLink := currentFile + ".tmp"
 	_ = os.Remove(tmpLink) // clean up any stale temp link
-	if err := os.Symlink(relTarget, tmpLink); err != nil {
-		return nil, fmt.Errorf("symlink current file: %w", err)
+	if err := os.Symlink(target, tmpLink); err != nil {
+		return fmt.Errorf("symlink current file: %w", err)
 	}
 	if err := os.Rename(tmpLink, currentFile); err != nil {
 		_ = os.Remove(tmpLink)
-		return nil, fmt.Errorf("rename current file: %w", err)
+		return fmt.Errorf("rename current file: %w", err)
 	}
+	return nil
+}
+
+// makeCurrent points p's current file at its new version and updates the
+// hash index to match. hashMu is held across both, so that CheckRecorded
+// never compares the new version with the hash of the one before it and
+// reports it tampered.
+func (s *Store) makeCurrent(p writePlan) error {
+	s.hashMu.Lock()
+	defer s.hashMu.Unlock()
+	if err := pointCurrent(p.currentFile, p.target()); err != nil {
+		return err
+	}
+	s.updateHashIndexLocked(p.reqPath, p.content)
+	return nil
+}
 
-	info, err := os.Stat(versionFile)
+// written updates the other indexes once p is current and returns its document.
+func (s *Store) written(p writePlan) (*Document, error) {
+	info, err := os.Stat(p.versionFile)
 	if err != nil {
 		return nil, fmt.Errorf("stat version file: %w", err)
 	}
 
-	s.UpdateHashIndex(reqPath, content)
+	s.setAliases(p.reqPath, p.meta)
+	s.indexDocument(p.reqPath, p.meta, p.content)
+	modified := info.ModTime().UTC().Truncate(time.Second)
+	s.recordChange(p.reqPath, p.version, modified)
+	s.chainWritten(p.reqPath, p.version, modified)
+	s.recordAgent(p.reqPath, p.version, modified, p.meta["agent"])
+	if s.compressing() {
+		// Best effort: the write succeeded, and CompressVersions catches
+		// up with anything left at the next startup.
+		_, _ = s.compressOld(p.reqPath, true)
+	}
 
 	return &Document{
-		Content:  content,
-		Modified: info.ModTime().UTC().Truncate(time.Second),
-		Version:  next,
+		Content:  p.content,
+		Modified: modified,
+		Version:  p.version,
 		Archived: false,
-		Metadata: meta,
+		Metadata: p.meta,
 	}, nil
 }
 
@@ -840,14 +1105,14 @@ func (s *Store) VerifyChain(reqPath string) error {
 		prevFile := filepath.Join(versionsDir, fmt.Sprintf("%s.v%d", base, prev.Version))
 		currFile := filepath.Join(versionsDir, fmt.Sprintf("%s.v%d", base, curr.Version))
 
-		prevData, err := os.ReadFile(prevFile)
+		prevData, err := readVersionFile(prevFile)
 		if err != nil {
 			return fmt.Errorf("read v%d: %w", prev.Version, err)
 		}
 		h := sha256.Sum256(prevData)
 		expected := fmt.Sprintf("sha256-%x", h)
 
-		currData, err := os.ReadFile(currFile)
+		currData, err := readVersionFile(currFile)
 		if err != nil {
 			return fmt.Errorf("read v%d: %w", curr.Version, err)
 		}
@@ -863,6 +1128,40 @@ func (s *Store) VerifyChain(reqPath string) error {
 	return nil
 }
 
+// CheckRecorded compares a fetched version against the hashes recorded
+// for it: the hash index entry for the current version (taken at startup
+// or on the last write) and the previous-hash stored in the following
+// version for older ones. It returns an error wrapping ErrTampered on a
+// mismatch and nil when nothing was recorded to compare against. Unlike
+// VerifyChain it reads at most one other file, so it is cheap enough to
+// run on every FETCH.
+func (s *Store) CheckRecorded(reqPath string, doc *Document) error {
+	if doc.Version >= s.CurrentVersion(reqPath) {
+		s.hashMu.RLock()
+		recorded, ok := s.pathIdx[reqPath]
+		s.hashMu.RUnlock()
+		if !ok || recorded == contentHash(extractBody(doc.Content)) {
+			return nil
+		}
+		// A write may have made a later version current since doc was
+		// read; the index then holds that version's hash, and doc is
+		// checked against the previous-hash recorded in it instead.
+		if doc.Version >= s.CurrentVersion(reqPath) {
+			return fmt.Errorf("v%d content differs from the indexed hash: %w", doc.Version, ErrTampered)
+		}
+	}
+
+	next, err := s.getVersion(reqPath, doc.Version+1)
+	if err != nil || next.PreviousHash == "" {
+		return nil
+	}
+	h := sha256.Sum256(doc.Content)
+	if got := fmt.Sprintf("sha256-%x", h); got != next.PreviousHash {
+		return fmt.Errorf("v%d does not match previous-hash recorded in v%d: %w", doc.Version, doc.Version+1, ErrTampered)
+	}
+	return nil
+}
+
 // resolveNonExistent resolves a path that doesn't exist yet by walking up
 // to find the closest existing ancestor, resolving its symlinks, then
 // appending the remaining path segments. This ensures symlink escapes
@@ -978,7 +1277,7 @@ func buildVersionFile(versionsDir, base string, version int, content []byte, met
 	sb.WriteString("archived: false\n")
 	if version > 1 {
 		prevFile := filepath.Join(versionsDir, fmt.Sprintf("%s.v%d", base, version-1))
-		prevData, err := os.ReadFile(prevFile)
+		prevData, err := readVersionFile(prevFile)
 		if err != nil {
 			return nil, fmt.Errorf("read previous version for hashing: %w", err)
 		}
@@ -1115,7 +1414,7 @@ func (s *Store) migrateFlatFile(versionsDir, base, currentFile string) error {
 // isCurrentArchived checks whether the given version file is archived.
 func (s *Store) isCurrentArchived(versionsDir, base string, version int) bool {
 	path := filepath.Join(versionsDir, fmt.Sprintf("%s.v%d", base, version))
-	data, err := os.ReadFile(path)
+	data, err := readVersionFile(path)
 	if err != nil {
 		return false
 	}
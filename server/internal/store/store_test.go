@@ -8,8 +8,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/latebit/demarkus/protocol"
 )
@@ -221,6 +225,45 @@ func TestVersions_MultipleVersions(t *testing.T) {
 	}
 }
 
+func TestVersions_Agents(t *testing.T) {
+	root := t.TempDir()
+	s := New(root)
+	if _, err := s.Write("/doc.md", []byte("# V1\n"), map[string]string{"agent": "mcp"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Write("/doc.md", []byte("# V2\n"), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(s *Store) {
+		t.Helper()
+		versions, err := s.Versions("/doc.md")
+		if err != nil {
+			t.Fatalf("Versions: %v", err)
+		}
+		if len(versions) != 2 || versions[0].Agent != "" || versions[1].Agent != "mcp" {
+			t.Errorf("versions = %+v, want v1 by mcp and v2 by nobody", versions)
+		}
+	}
+	check(s)
+
+	// A restarted store reads the agents once, then keeps them.
+	restarted := New(root)
+	check(restarted)
+	v1 := filepath.Join(root, "versions", "doc.md.v1")
+	info, err := os.Stat(v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(v1, []byte("---\nversion: 1\n---\n# V1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(v1, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	check(restarted)
+}
+
 func TestVersions_NotFound(t *testing.T) {
 	root := t.TempDir()
 	s := New(root)
@@ -797,6 +840,160 @@ func TestArchive(t *testing.T) {
 	})
 }
 
+func TestPurge(t *testing.T) {
+	root := t.TempDir()
+	s := New(root)
+	for _, body := range []string{"# Draft\n", "# Draft\n\nMore.\n"} {
+		if _, err := s.Write("/drafts/doc.md", []byte(body), map[string]string{"aliases": "/old.md"}); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	last, err := os.ReadFile(filepath.Join(root, "drafts", "versions", "doc.md.v2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Tombstone("/drafts/doc.md"); !os.IsNotExist(err) {
+		t.Fatalf("Tombstone before purge: got %v, want not-exist", err)
+	}
+	ts, err := s.Purge("/drafts/doc.md")
+	if err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+	if ts.Versions != 2 || ts.LastHash != fmt.Sprintf("sha256-%x", sha256.Sum256(last)) {
+		t.Errorf("tombstone = %+v", ts)
+	}
+
+	if _, err := s.Get("/drafts/doc.md", 0); !os.IsNotExist(err) {
+		t.Errorf("Get after purge: got %v, want not-exist", err)
+	}
+	if _, err := s.Versions("/drafts/doc.md"); !os.IsNotExist(err) {
+		t.Errorf("Versions after purge: got %v, want not-exist", err)
+	}
+	entries, err := os.ReadDir(filepath.Join(root, "drafts", "versions"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "doc.md.purged" {
+		t.Errorf("versions dir holds %v, want only the tombstone", entries)
+	}
+	if _, ok := s.ResolveAlias("/old.md"); ok {
+		t.Error("alias survived the purge")
+	}
+	if results := s.Search("/", "draft", 10, nil); len(results) != 0 {
+		t.Errorf("search still finds the purged document: %v", results)
+	}
+
+	got, err := s.Tombstone("/drafts/doc.md")
+	if err != nil {
+		t.Fatalf("Tombstone: %v", err)
+	}
+	if *got != *ts {
+		t.Errorf("Tombstone = %+v, want %+v", got, ts)
+	}
+
+	// A new history starts at v1 and the tombstone stays.
+	doc, err := s.Write("/drafts/doc.md", []byte("# Again\n"), nil)
+	if err != nil {
+		t.Fatalf("Write after purge: %v", err)
+	}
+	if doc.Version != 1 {
+		t.Errorf("version after purge = %d, want 1", doc.Version)
+	}
+	if _, err := s.Tombstone("/drafts/doc.md"); err != nil {
+		t.Errorf("Tombstone after republish: %v", err)
+	}
+
+	if _, err := s.Purge("/missing.md"); !os.IsNotExist(err) {
+		t.Errorf("Purge missing: got %v, want not-exist", err)
+	}
+	if _, err := s.Purge("/../etc/passwd"); !os.IsNotExist(err) {
+		t.Errorf("Purge traversal: got %v, want not-exist", err)
+	}
+}
+
+func TestMove(t *testing.T) {
+	root := t.TempDir()
+	s := New(root)
+	for _, body := range []string{"# Post\n", "# Post\n\nEdited.\n"} {
+		if _, err := s.Write("/drafts/post.md", []byte(body), map[string]string{"tag": "blog"}); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+
+	doc, err := s.Move("/drafts/post.md", "/posts/post.md", true)
+	if err != nil {
+		t.Fatalf("Move: %v", err)
+	}
+	if doc.Version != 3 || doc.MovedFrom != "/drafts/post.md" {
+		t.Errorf("moved doc = version %d, moved-from %q; want 3, /drafts/post.md", doc.Version, doc.MovedFrom)
+	}
+
+	// The history came along and still verifies.
+	versions, err := s.Versions("/posts/post.md")
+	if err != nil || len(versions) != 3 {
+		t.Fatalf("Versions at new path = %v, %v; want 3", versions, err)
+	}
+	if err := s.VerifyChain("/posts/post.md"); err != nil {
+		t.Errorf("VerifyChain after move: %v", err)
+	}
+	got, err := s.Get("/posts/post.md", 0)
+	if err != nil {
+		t.Fatalf("Get new path: %v", err)
+	}
+	if string(extractBody(got.Content)) != "# Post\n\nEdited.\n" || got.MovedFrom != "/drafts/post.md" || got.Metadata["tag"] != "blog" {
+		t.Errorf("Get new path = %q, moved-from %q, meta %v", extractBody(got.Content), got.MovedFrom, got.Metadata)
+	}
+	v1, err := s.Get("/posts/post.md", 1)
+	if err != nil || string(extractBody(v1.Content)) != "# Post\n" || v1.MovedFrom != "" {
+		t.Errorf("v1 at new path = %v, %v", v1, err)
+	}
+
+	// The old path is gone, and redirects.
+	if _, err := s.Get("/drafts/post.md", 0); !os.IsNotExist(err) {
+		t.Errorf("Get old path: got %v, want not-exist", err)
+	}
+	if _, err := s.Versions("/drafts/post.md"); !os.IsNotExist(err) {
+		t.Errorf("Versions old path: got %v, want not-exist", err)
+	}
+	if canonical, ok := s.ResolveAlias("/drafts/post.md"); !ok || canonical != "/posts/post.md" {
+		t.Errorf("ResolveAlias(old) = %q, %v", canonical, ok)
+	}
+	if hits := s.Search("/", "edited", 10, nil); len(hits) != 1 || hits[0].Path != "/posts/post.md" {
+		t.Errorf("Search after move = %v", hits)
+	}
+
+	// Without redirect, no alias is added.
+	if _, err := s.Move("/posts/post.md", "/post.md", false); err != nil {
+		t.Fatalf("Move without redirect: %v", err)
+	}
+	if _, ok := s.ResolveAlias("/posts/post.md"); ok {
+		t.Error("alias added without redirect")
+	}
+	if err := s.VerifyChain("/post.md"); err != nil {
+		t.Errorf("VerifyChain after second move: %v", err)
+	}
+
+	if _, err := s.Write("/other.md", []byte("# Other\n"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Move("/post.md", "/other.md", false); !errors.Is(err, ErrDestinationExists) {
+		t.Errorf("Move onto existing: got %v, want ErrDestinationExists", err)
+	}
+	if _, err := s.Move("/missing.md", "/new.md", false); !os.IsNotExist(err) {
+		t.Errorf("Move missing: got %v, want not-exist", err)
+	}
+	if _, err := s.Move("/post.md", "/../escape.md", false); !os.IsNotExist(err) {
+		t.Errorf("Move traversal: got %v, want not-exist", err)
+	}
+	if err := s.Archive("/other.md", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Move("/other.md", "/new.md", false); !errors.Is(err, ErrArchived) {
+		t.Errorf("Move archived: got %v, want ErrArchived", err)
+	}
+}
+
 func TestVerifyChain_Valid(t *testing.T) {
 	root := t.TempDir()
 	s := New(root)
@@ -838,6 +1035,131 @@ func TestVerifyChain_Tampered(t *testing.T) {
 	}
 }
 
+func TestScanChains(t *testing.T) {
+	root := t.TempDir()
+	s := New(root)
+	for _, p := range []string{"/doc.md", "/other.md"} {
+		for i := 1; i <= 2; i++ {
+			if _, err := s.Write(p, fmt.Appendf(nil, "# V%d\n", i), nil); err != nil {
+				t.Fatalf("write %s v%d: %v", p, i, err)
+			}
+		}
+	}
+	// A new document's chain is known to be valid; later writes keep it so.
+	if st, ok := s.ChainStatus("/doc.md"); !ok || !st.Valid {
+		t.Fatalf("ChainStatus before a scan = %+v, %v", st, ok)
+	}
+
+	// Tampering goes unseen until the next scan.
+	if err := os.WriteFile(filepath.Join(root, "versions", "doc.md.v1"), []byte("# TAMPERED\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if st, _ := s.ChainStatus("/doc.md"); !st.Valid {
+		t.Fatal("ChainStatus verified the chain again")
+	}
+	var reported []string
+	checked, broken := s.ScanChains(func(reqPath string, err error) { reported = append(reported, reqPath) })
+	if checked != 2 || broken != 1 || len(reported) != 1 || reported[0] != "/doc.md" {
+		t.Errorf("ScanChains = %d checked, %d broken, reported %v", checked, broken, reported)
+	}
+	if st, ok := s.ChainStatus("/doc.md"); !ok || st.Valid || !strings.Contains(st.Error, "chain broken") {
+		t.Errorf("ChainStatus(/doc.md) = %+v, %v", st, ok)
+	}
+	if st, ok := s.ChainStatus("/other.md"); !ok || !st.Valid {
+		t.Errorf("ChainStatus(/other.md) = %+v, %v", st, ok)
+	}
+
+	// The status follows a move and goes with a purge.
+	if _, err := s.Move("/doc.md", "/moved.md", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.ChainStatus("/doc.md"); ok {
+		t.Error("status kept at the old path")
+	}
+	if st, ok := s.ChainStatus("/moved.md"); !ok || st.Valid {
+		t.Errorf("ChainStatus(/moved.md) = %+v, %v", st, ok)
+	}
+	if _, err := s.Purge("/other.md"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.ChainStatus("/other.md"); ok {
+		t.Error("status kept after a purge")
+	}
+}
+
+func TestCompression(t *testing.T) {
+	root := t.TempDir()
+	s := New(root)
+	s.EnableCompression(2, 0)
+
+	versionFile := func(v int) string {
+		return filepath.Join(root, "versions", fmt.Sprintf("doc.md.v%d", v))
+	}
+	compressed := func(v int) bool {
+		data, err := os.ReadFile(versionFile(v))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return bytes.HasPrefix(data, gzipMagic)
+	}
+
+	if _, err := s.Write("/doc.md", []byte("# V1\n"), map[string]string{"type": "note"}); err != nil {
+		t.Fatal(err)
+	}
+	original, err := os.ReadFile(versionFile(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.Stat(versionFile(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 2; i <= 4; i++ {
+		if _, err := s.Write("/doc.md", fmt.Appendf(nil, "# V%d\n", i), nil); err != nil {
+			t.Fatalf("write v%d: %v", i, err)
+		}
+	}
+
+	for v, want := range map[int]bool{1: true, 2: true, 3: false, 4: false} {
+		if got := compressed(v); got != want {
+			t.Errorf("v%d compressed = %v, want %v", v, got, want)
+		}
+	}
+	doc, err := s.Get("/doc.md", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(doc.Content, original) || doc.Metadata["type"] != "note" {
+		t.Errorf("v1 = %q, want %q", doc.Content, original)
+	}
+	if after, err := os.Stat(versionFile(1)); err != nil || !after.ModTime().Equal(before.ModTime()) {
+		t.Errorf("v1 modification time changed: %v -> %v (%v)", before.ModTime(), after.ModTime(), err)
+	}
+	if err := s.VerifyChain("/doc.md"); err != nil {
+		t.Errorf("chain broken by compression: %v", err)
+	}
+	if err := s.CheckRecorded("/doc.md", doc); err != nil {
+		t.Errorf("CheckRecorded v1: %v", err)
+	}
+
+	// An age policy catches up at startup, without a write.
+	aged := New(root)
+	aged.EnableCompression(0, time.Hour)
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(versionFile(3), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := aged.CompressVersions(); err != nil || n != 1 {
+		t.Errorf("CompressVersions = %d, %v, want 1", n, err)
+	}
+	if !compressed(3) || compressed(4) {
+		t.Error("want v3 compressed and the current v4 not")
+	}
+	if n, err := aged.CompressVersions(); err != nil || n != 0 {
+		t.Errorf("second CompressVersions = %d, %v, want 0", n, err)
+	}
+}
+
 func TestAppend(t *testing.T) {
 	root := t.TempDir()
 	s := New(root)
@@ -1332,3 +1654,833 @@ func TestHashIndex(t *testing.T) {
 		}
 	})
 }
+
+func TestCheckRecorded(t *testing.T) {
+	root := t.TempDir()
+	s := New(root)
+	for i := 1; i <= 2; i++ {
+		if _, err := s.Write("/doc.md", fmt.Appendf(nil, "# V%d\n", i), nil); err != nil {
+			t.Fatalf("write v%d: %v", i, err)
+		}
+	}
+
+	check := func(version int) error {
+		t.Helper()
+		doc, err := s.Get("/doc.md", version)
+		if err != nil {
+			t.Fatalf("get v%d: %v", version, err)
+		}
+		return s.CheckRecorded("/doc.md", doc)
+	}
+
+	if err := check(1); err != nil {
+		t.Errorf("v1 untouched: %v", err)
+	}
+	if err := check(2); err != nil {
+		t.Errorf("v2 untouched: %v", err)
+	}
+
+	tamper := func(name string) {
+		t.Helper()
+		p := filepath.Join(root, "versions", name)
+		data, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = bytes.Replace(data, []byte("# V"), []byte("# EDITED V"), 1)
+		if err := os.WriteFile(p, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tamper("doc.md.v1")
+	if err := check(1); !errors.Is(err, ErrTampered) {
+		t.Errorf("v1 edited: got %v, want ErrTampered", err)
+	}
+
+	tamper("doc.md.v2")
+	if err := check(2); !errors.Is(err, ErrTampered) {
+		t.Errorf("v2 edited: got %v, want ErrTampered", err)
+	}
+
+	// Without an index entry there is nothing recorded for the current
+	// version to compare against.
+	s.RemoveHashEntry("/doc.md")
+	if err := check(2); err != nil {
+		t.Errorf("v2 without index entry: %v", err)
+	}
+}
+
+func TestCheckRecordedDuringWrites(t *testing.T) {
+	root := t.TempDir()
+	s := New(root)
+	if _, err := s.Write("/doc.md", []byte("# V1\n"), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Go(func() {
+		defer close(done)
+		for i := 2; i <= 50; i++ {
+			if _, err := s.Write("/doc.md", fmt.Appendf(nil, "# V%d\n", i), nil); err != nil {
+				t.Errorf("write v%d: %v", i, err)
+				return
+			}
+		}
+	})
+	for range 4 {
+		wg.Go(func() {
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				doc, err := s.Get("/doc.md", 0)
+				if err != nil {
+					continue
+				}
+				if err := s.CheckRecorded("/doc.md", doc); errors.Is(err, ErrTampered) {
+					t.Errorf("v%d read during a write: %v", doc.Version, err)
+					return
+				}
+			}
+		})
+	}
+	wg.Wait()
+}
+
+func TestCheckLayout(t *testing.T) {
+	root := t.TempDir()
+	s := New(root)
+	for i, p := range []string{"/a.md", "/a.md", "/sub/b.md"} {
+		if _, err := s.Write(p, fmt.Appendf(nil, "# %s %d\n", p, i), nil); err != nil {
+			t.Fatalf("write %s: %v", p, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "flat.md"), []byte("# Flat"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	problems, err := s.CheckLayout()
+	if err != nil {
+		t.Fatalf("CheckLayout: %v", err)
+	}
+	if len(problems) != 0 {
+		t.Fatalf("healthy store reported problems: %q", problems)
+	}
+
+	// Point a.md back at v1, break sub/b.md and leave a temp link behind.
+	aLink := filepath.Join(root, "a.md")
+	if err := os.Remove(aLink); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join("versions", "a.md.v1"), aLink); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(root, "sub", "versions", "b.md.v1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join("versions", "a.md.v2"), filepath.Join(root, "a.md.tmp")); err != nil {
+		t.Fatal(err)
+	}
+
+	problems, err = s.CheckLayout()
+	if err != nil {
+		t.Fatalf("CheckLayout: %v", err)
+	}
+	want := []string{"/a.md: symlink points at a.md.v1", "/a.md.tmp: stale temp link", "/sub/b.md: broken symlink"}
+	if len(problems) != len(want) {
+		t.Fatalf("problems: got %q, want %d", problems, len(want))
+	}
+	for i, prefix := range want {
+		if !strings.HasPrefix(problems[i], prefix) {
+			t.Errorf("problem %d: got %q, want prefix %q", i, problems[i], prefix)
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	root := t.TempDir()
+	s := New(root)
+	if _, err := s.Write("/kept.md", []byte("# Kept\n"), nil); err != nil {
+		t.Fatal(err)
+	}
+	// A flat file with no history, a flat file replacing a versioned
+	// document, and a temp link from an interrupted write.
+	if err := os.MkdirAll(filepath.Join(root, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "sub", "flat.md"), []byte("# Flat\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Write("/edited.md", []byte("# Old\n"), nil); err != nil {
+		t.Fatal(err)
+	}
+	edited := filepath.Join(root, "edited.md")
+	if err := os.Remove(edited); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(edited, []byte("# New\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join("versions", "kept.md.v1"), filepath.Join(root, "kept.md.tmp")); err != nil {
+		t.Fatal(err)
+	}
+
+	from, plan, err := s.PlanMigration()
+	if err != nil {
+		t.Fatalf("PlanMigration: %v", err)
+	}
+	if from != 0 || len(plan) != 3 {
+		t.Fatalf("plan from %d: %v, want 3 steps from 0", from, plan)
+	}
+	if _, err := os.Lstat(filepath.Join(root, "kept.md.tmp")); err != nil {
+		t.Fatal("dry run changed the directory")
+	}
+
+	backup := filepath.Join(t.TempDir(), "backup")
+	done, err := s.Migrate(backup)
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if len(done) != 3 {
+		t.Errorf("done = %v, want 3 steps", done)
+	}
+
+	if doc, err := s.Get("/sub/flat.md", 0); err != nil || doc.Version != 1 || !strings.HasSuffix(string(doc.Content), "---\n# Flat\n") {
+		t.Errorf("flat.md after migrate: %+v, %v", doc, err)
+	}
+	if doc, err := s.Get("/edited.md", 0); err != nil || doc.Version != 2 || !strings.HasSuffix(string(doc.Content), "---\n# New\n") {
+		t.Errorf("edited.md after migrate: %+v, %v", doc, err)
+	}
+	if err := s.VerifyChain("/edited.md"); err != nil {
+		t.Errorf("edited.md chain: %v", err)
+	}
+	if got, err := os.ReadFile(filepath.Join(backup, "sub", "flat.md")); err != nil || string(got) != "# Flat\n" {
+		t.Errorf("backup of flat.md = %q, %v", got, err)
+	}
+	if target, err := os.Readlink(filepath.Join(backup, "kept.md.tmp")); err != nil || target != filepath.Join("versions", "kept.md.v1") {
+		t.Errorf("backup of temp link = %q, %v", target, err)
+	}
+	if problems, err := s.CheckLayout(); err != nil || len(problems) != 0 {
+		t.Errorf("CheckLayout after migrate: %q, %v", problems, err)
+	}
+
+	if v, err := s.Layout(); err != nil || v != LayoutVersion {
+		t.Errorf("Layout = %d, %v; want %d", v, err, LayoutVersion)
+	}
+	if _, plan, _ := s.PlanMigration(); len(plan) != 0 {
+		t.Errorf("plan after migrate = %v, want none", plan)
+	}
+}
+
+func TestLayoutNewerThanSupported(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, ".demarkus-layout"), []byte("99\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(root).Migrate(t.TempDir()); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("Migrate = %v, want newer-layout error", err)
+	}
+}
+
+func TestJournalRecover(t *testing.T) {
+	root := t.TempDir()
+	s := New(root)
+	s.EnableJournal()
+	for _, p := range []string{"/a.md", "/b.md"} {
+		if _, err := s.Write(p, []byte("# "+p+"\n"), nil); err != nil {
+			t.Fatalf("write %s: %v", p, err)
+		}
+	}
+	journal := filepath.Join(root, journalFile)
+	if data, err := os.ReadFile(journal); err != nil || len(data) != 0 {
+		t.Fatalf("journal after clean writes = %q, %v", data, err)
+	}
+
+	// Simulate crashes: a.md v2 written in full but never made current,
+	// b.md v2 torn half-way, c.md v1 never created, and a torn last line.
+	versions := filepath.Join(root, "versions")
+	var lines []string
+	for _, p := range []string{"a.md", "b.md", "c.md"} {
+		version := 2
+		if p == "c.md" {
+			version = 1
+		}
+		stored, err := buildVersionFile(versions, p, version, []byte("# "+p+" updated\n"), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch p {
+		case "a.md":
+			err = os.WriteFile(filepath.Join(versions, "a.md.v2"), stored, 0o644)
+			if err == nil {
+				err = os.Symlink(filepath.Join("versions", "a.md.v2"), filepath.Join(root, "a.md.tmp"))
+			}
+		case "b.md":
+			err = os.WriteFile(filepath.Join(versions, "b.md.v2"), stored[:len(stored)/2], 0o644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, journalEntry{path: "/" + p, version: version, hash: fmt.Sprintf("sha256-%x", sha256.Sum256(stored))}.line("begin"))
+	}
+	lines = append(lines, "begin\t3\tsha256-")
+	if err := os.WriteFile(journal, []byte(strings.Join(lines, "")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	problems, err := s.CheckLayout()
+	if err != nil {
+		t.Fatalf("CheckLayout: %v", err)
+	}
+	if len(problems) < 3 || !strings.Contains(problems[0], "/a.md: write of v2 was interrupted") {
+		t.Errorf("CheckLayout before recovery: %q", problems)
+	}
+
+	repairs, err := s.Recover()
+	if err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+	want := []string{
+		"/a.md: removed stale temp link; pointed at v2, which was written but not made current",
+		"/b.md: removed incomplete v2",
+	}
+	if !slices.Equal(repairs, want) {
+		t.Errorf("repairs:\ngot  %q\nwant %q", repairs, want)
+	}
+	if doc, err := s.Get("/a.md", 0); err != nil || doc.Version != 2 {
+		t.Errorf("a.md after recovery = %+v, %v; want v2", doc, err)
+	}
+	if doc, err := s.Get("/b.md", 0); err != nil || doc.Version != 1 {
+		t.Errorf("b.md after recovery = %+v, %v; want v1", doc, err)
+	}
+	if problems, err := s.CheckLayout(); err != nil || len(problems) != 0 {
+		t.Errorf("CheckLayout after recovery: %q, %v", problems, err)
+	}
+
+	// The next write continues the chain from the recovered version.
+	if doc, err := s.Write("/b.md", []byte("# again\n"), nil); err != nil || doc.Version != 2 {
+		t.Errorf("write after recovery = %+v, %v", doc, err)
+	}
+	if err := s.VerifyChain("/b.md"); err != nil {
+		t.Errorf("b.md chain: %v", err)
+	}
+}
+
+func TestWriteBatch(t *testing.T) {
+	root := t.TempDir()
+	s := New(root)
+	if _, err := s.Write("/a.md", []byte("# A\n"), nil); err != nil {
+		t.Fatal(err)
+	}
+	versionOf := func(p string) int {
+		t.Helper()
+		doc, err := s.Get(p, 0)
+		if err != nil {
+			t.Fatalf("get %s: %v", p, err)
+		}
+		return doc.Version
+	}
+
+	results, err := s.WriteBatch([]BatchWrite{
+		{Path: "/a.md", ExpectedVersion: 1, Content: []byte("# A2\n")},
+		{Path: "/guide/b.md", ExpectedVersion: 0, Content: []byte("# B\n"), Meta: map[string]string{"title": "B"}},
+	})
+	if err != nil {
+		t.Fatalf("WriteBatch: %v", err)
+	}
+	if a, b := results[0].Doc, results[1].Doc; a.Version != 2 || b.Version != 1 || b.Metadata["title"] != "B" {
+		t.Errorf("docs = %+v, %+v", a, b)
+	}
+	if err := s.VerifyChain("/a.md"); err != nil {
+		t.Errorf("a.md chain: %v", err)
+	}
+	if hashPath, ok := s.LookupHash(contentHash([]byte("# B\n"))); !ok || hashPath != "/guide/b.md" {
+		t.Errorf("hash index has %q, %v for b.md", hashPath, ok)
+	}
+
+	// An unchanged document keeps its version.
+	results, err = s.WriteBatch([]BatchWrite{
+		{Path: "/a.md", ExpectedVersion: -1, Content: []byte("# A2\n")},
+		{Path: "/c.md", ExpectedVersion: -1, Content: []byte("# C\n")},
+	})
+	if err != nil || !results[0].Unchanged || results[0].Doc.Version != 2 || results[1].Unchanged || results[1].Doc.Version != 1 {
+		t.Fatalf("WriteBatch with an unchanged document = %+v, %v", results, err)
+	}
+
+	// Any failure writes nothing.
+	if err := s.Archive("/c.md", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "dir.md"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		second BatchWrite
+		want   error
+	}{
+		{"conflict", BatchWrite{Path: "/guide/b.md", ExpectedVersion: 0, Content: []byte("# B again\n")}, ErrConflict},
+		{"archived", BatchWrite{Path: "/c.md", ExpectedVersion: -1, Content: []byte("# C2\n")}, ErrArchived},
+		{"repeated path", BatchWrite{Path: "/a.md", ExpectedVersion: -1, Content: []byte("# A4\n")}, nil},
+		{"current file not replaceable", BatchWrite{Path: "/dir.md", ExpectedVersion: -1, Content: []byte("# D\n")}, nil},
+	}
+	for _, tt := range tests {
+		_, err := s.WriteBatch([]BatchWrite{{Path: "/a.md", ExpectedVersion: 2, Content: []byte("# A3\n")}, tt.second})
+		var be *BatchError
+		if !errors.As(err, &be) || be.Index != 1 || (tt.want != nil && !errors.Is(err, tt.want)) {
+			t.Errorf("%s: err = %v, want write 1 to fail with %v", tt.name, err, tt.want)
+		}
+		if v := versionOf("/a.md"); v != 2 {
+			t.Errorf("%s: a.md at v%d after a failed batch, want v2", tt.name, v)
+		}
+		if _, err := os.Stat(filepath.Join(root, "versions", "a.md.v3")); !os.IsNotExist(err) {
+			t.Errorf("%s: a.md.v3 left behind: %v", tt.name, err)
+		}
+	}
+	if err := s.VerifyChain("/a.md"); err != nil {
+		t.Errorf("a.md chain after failed batches: %v", err)
+	}
+}
+
+func TestRewriteAll(t *testing.T) {
+	s := New(t.TempDir())
+	for p, body := range map[string]string{
+		"/a.md":       "# A\n\nSee mark://old/b.md\n",
+		"/guide/b.md": "# B\n\nSee mark://old/a.md\n",
+		"/c.md":       "# C\n",
+		"/gone.md":    "# Gone\n\nmark://old/\n",
+	} {
+		if _, err := s.Write(p, []byte(body), map[string]string{"title": p}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Archive("/gone.md", true); err != nil {
+		t.Fatal(err)
+	}
+	rewrite := func(_ string, body []byte) []byte {
+		return bytes.ReplaceAll(body, []byte("mark://old/"), []byte("mark://new/"))
+	}
+
+	planned, err := s.RewriteAll(rewrite, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(planned) != 2 || planned[0] != (Rewrite{Path: "/a.md", Version: 1}) || planned[1].Path != "/guide/b.md" {
+		t.Fatalf("dry run = %+v", planned)
+	}
+	if v := s.CurrentVersion("/a.md"); v != 1 {
+		t.Fatalf("dry run wrote a.md v%d", v)
+	}
+
+	done, err := s.RewriteAll(rewrite, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != 2 || done[0].Version != 2 || done[1].Version != 2 {
+		t.Errorf("rewrites = %+v", done)
+	}
+	doc, err := s.Get("/guide/b.md", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := string(extractBody(doc.Content)); body != "# B\n\nSee mark://new/a.md\n" || doc.Metadata["title"] != "/guide/b.md" {
+		t.Errorf("b.md = %q, metadata %v", body, doc.Metadata)
+	}
+	if v := s.CurrentVersion("/c.md"); v != 1 {
+		t.Errorf("unchanged c.md at v%d", v)
+	}
+	if doc, err := s.Get("/gone.md", 0); err != nil || !doc.Archived || bytes.Contains(doc.Content, []byte("mark://new/")) {
+		t.Errorf("archived gone.md was rewritten: %v", err)
+	}
+
+	if again, err := s.RewriteAll(rewrite, false); err != nil || len(again) != 0 {
+		t.Errorf("second run = %+v, %v", again, err)
+	}
+}
+
+func TestJournalRecoverBatch(t *testing.T) {
+	root := t.TempDir()
+	s := New(root)
+	s.EnableJournal()
+	if _, err := s.Write("/a.md", []byte("# A\n"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.WriteBatch([]BatchWrite{
+		{Path: "/a.md", ExpectedVersion: -1, Content: []byte("# A2\n")},
+		{Path: "/b.md", ExpectedVersion: -1, Content: []byte("# B\n")},
+	}); err != nil {
+		t.Fatalf("WriteBatch: %v", err)
+	}
+	journal := filepath.Join(root, journalFile)
+	if data, err := os.ReadFile(journal); err != nil || len(data) != 0 {
+		t.Fatalf("journal after a batch = %q, %v", data, err)
+	}
+
+	// Simulate a crash after a.md v3 was made current but before new.md
+	// v1 was: both must be undone.
+	versions := filepath.Join(root, "versions")
+	var lines []string
+	for _, w := range []struct {
+		name    string
+		version int
+	}{{"a.md", 3}, {"new.md", 1}} {
+		stored, err := buildVersionFile(versions, w.name, w.version, []byte("# "+w.name+" batch\n"), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file := fmt.Sprintf("%s.v%d", w.name, w.version)
+		if err := os.WriteFile(filepath.Join(versions, file), stored, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, journalEntry{path: "/" + w.name, version: w.version, hash: fmt.Sprintf("sha256-%x", sha256.Sum256(stored))}.line("stage"))
+	}
+	if err := pointCurrent(filepath.Join(root, "a.md"), filepath.Join("versions", "a.md.v3")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(journal, []byte(strings.Join(lines, "")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	problems, err := s.CheckLayout()
+	if err != nil || len(problems) == 0 || !strings.Contains(problems[0], "/a.md: batch writing v3 was interrupted") {
+		t.Errorf("CheckLayout before recovery: %q, %v", problems, err)
+	}
+	repairs, err := s.Recover()
+	if err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+	want := []string{
+		"/a.md: removed v3 of a batch that did not finish",
+		"/new.md: removed v1 of a batch that did not finish",
+	}
+	if !slices.Equal(repairs, want) {
+		t.Errorf("repairs:\ngot  %q\nwant %q", repairs, want)
+	}
+	if doc, err := s.Get("/a.md", 0); err != nil || doc.Version != 2 {
+		t.Errorf("a.md after recovery = %+v, %v; want v2", doc, err)
+	}
+	if _, err := s.Get("/new.md", 0); !os.IsNotExist(err) {
+		t.Errorf("new.md after recovery: %v, want not found", err)
+	}
+	if problems, err := s.CheckLayout(); err != nil || len(problems) != 0 {
+		t.Errorf("CheckLayout after recovery: %q, %v", problems, err)
+	}
+}
+
+func TestResetRoot(t *testing.T) {
+	base := t.TempDir()
+	for _, release := range []string{"a", "b"} {
+		if err := os.Mkdir(filepath.Join(base, release), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := New(filepath.Join(base, release)).Write("/doc.md", []byte("# "+release+"\n"), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	root := filepath.Join(base, "current")
+	if err := os.Symlink("a", root); err != nil {
+		t.Fatal(err)
+	}
+	s := New(root)
+	get := func() string {
+		t.Helper()
+		doc, err := s.Get("/doc.md", 0)
+		if err != nil {
+			return err.Error()
+		}
+		return string(doc.Content)
+	}
+	if got := get(); !strings.HasSuffix(got, "# a\n") {
+		t.Fatalf("before switch: %q", got)
+	}
+
+	// Repointing the root is not noticed until ResetRoot: the memoized root
+	// no longer contains the documents, which are refused rather than
+	// served from the wrong release.
+	if err := os.Remove(root); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("b", root); err != nil {
+		t.Fatal(err)
+	}
+	if got := get(); strings.HasSuffix(got, "# a\n") || strings.HasSuffix(got, "# b\n") {
+		t.Errorf("served %q from a stale root", got)
+	}
+	s.ResetRoot()
+	if got := get(); !strings.HasSuffix(got, "# b\n") {
+		t.Errorf("after ResetRoot: %q", got)
+	}
+}
+
+func TestSearchIndexRebuilt(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := New(dir).Write("/docs/a.md", []byte("# Alpha\n\n"+strings.Repeat("filler ", 30)+"needle in the middle "+strings.Repeat("filler ", 30)+"\n"), map[string]string{"title": "Custom"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new store knows nothing until the startup walk.
+	s := New(dir)
+	if hits := s.Search("/", "needle", 0, nil); len(hits) != 0 {
+		t.Fatalf("unindexed store returned %+v", hits)
+	}
+	if err := s.BuildHashIndex(); err != nil {
+		t.Fatal(err)
+	}
+	hits := s.Search("/docs", "NEEDLE", 0, nil)
+	if len(hits) != 1 || hits[0].Path != "/docs/a.md" || hits[0].Title != "Custom" {
+		t.Fatalf("hits = %+v", hits)
+	}
+	if sn := hits[0].Snippet; !strings.Contains(sn, "needle in the middle") || !strings.HasPrefix(sn, "…") || !strings.HasSuffix(sn, "…") {
+		t.Errorf("snippet = %q", sn)
+	}
+	if hits := s.Search("/doc", "needle", 0, nil); len(hits) != 0 {
+		t.Errorf("directory prefix matched a sibling: %+v", hits)
+	}
+	if hits := s.Search("/", "needle", 0, func(string) bool { return false }); len(hits) != 0 {
+		t.Errorf("invisible hit returned: %+v", hits)
+	}
+}
+
+func TestSearchSkipsBinaryBodies(t *testing.T) {
+	s := New(t.TempDir())
+	body := []byte("\x89PNG\r\n\x1a\nneedle\x00\xff")
+	if _, err := s.Write("/img/diagram.png", body, map[string]string{"content-type": "image/png", "title": "Architecture diagram"}); err != nil {
+		t.Fatal(err)
+	}
+	if hits := s.Search("/", "needle", 0, nil); len(hits) != 0 {
+		t.Errorf("binary body indexed: %+v", hits)
+	}
+	hits := s.Search("/", "diagram", 0, nil)
+	if len(hits) != 1 || hits[0].Path != "/img/diagram.png" || hits[0].Snippet != "" {
+		t.Errorf("hits = %+v", hits)
+	}
+}
+
+func TestSearchFilters(t *testing.T) {
+	s := New(t.TempDir())
+	for _, w := range []struct {
+		path string
+		meta map[string]string
+	}{
+		{"/notes/release.md", map[string]string{"tags": "Release, ops", "author": "Ada Lovelace", "date": "2026-03-14"}},
+		{"/notes/plan.md", map[string]string{"tags": "planning", "author": "fritz", "date": "2026-04-01"}},
+		{"/blog/launch.md", map[string]string{"tags": "release", "author": "fritz", "date": "2025-12-01"}},
+	} {
+		if _, err := s.Write(w.path, []byte("# Notes\n\nThe release plan.\n"), w.meta); err != nil {
+			t.Fatal(err)
+		}
+	}
+	search := func(dir, query string) []string {
+		var ps []string
+		for _, h := range s.Search(dir, query, 0, nil) {
+			ps = append(ps, h.Path)
+		}
+		return ps
+	}
+
+	for _, tt := range []struct {
+		dir, query string
+		want       []string
+	}{
+		{"/", "tag:release", []string{"/blog/launch.md", "/notes/release.md"}},
+		{"/", "tag:RELEASE author:fritz", []string{"/blog/launch.md"}},
+		{"/", `author:"ada lovelace"`, []string{"/notes/release.md"}},
+		{"/", "date:2026", []string{"/notes/plan.md", "/notes/release.md"}},
+		{"/", "date:2026-03 plan", []string{"/notes/release.md"}},
+		{"/notes", "tag:release", []string{"/notes/release.md"}},
+		{"/", "tag:release nowhere", nil},
+		{"/", "author:nobody", nil},
+		// An unknown filter is searched for as words.
+		{"/", "notes:plan", []string{"/blog/launch.md", "/notes/plan.md", "/notes/release.md"}},
+	} {
+		if got := search(tt.dir, tt.query); !slices.Equal(got, tt.want) {
+			t.Errorf("Search(%q, %q) = %v, want %v", tt.dir, tt.query, got, tt.want)
+		}
+	}
+
+	// Tags follow the current version, and archived documents drop out.
+	if _, err := s.Write("/notes/release.md", []byte("# Notes\n"), map[string]string{"tags": "ops"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Archive("/blog/launch.md", true); err != nil {
+		t.Fatal(err)
+	}
+	if got := search("/", "tag:release"); got != nil {
+		t.Errorf("tag:release after retagging and archiving = %v", got)
+	}
+	if got := search("/", "tag:ops"); !slices.Equal(got, []string{"/notes/release.md"}) {
+		t.Errorf("tag:ops = %v", got)
+	}
+}
+
+func TestBacklinks(t *testing.T) {
+	dir := t.TempDir()
+	for _, w := range []struct{ path, body string }{
+		{"/guide/setup.md", "# Setup\n\nSee the [index](./), [install](install.md#linux) and [setup](setup.md).\n"},
+		{"/guide/index.md", "# Guide\n\n- [Install](install.md?raw)\n- [Setup](/guide/setup.md)\n"},
+		{"/blog/post.md", "# Post\n\n[Install](../guide/install.md), [elsewhere](mark://example.com/guide/install.md).\n\n`[code](/guide/install.md)`\n"},
+		{"/guide/install.md", "# Install\n\nBack to the [guide](/guide/).\n"},
+	} {
+		if _, err := New(dir).Write(w.path, []byte(w.body), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := New(dir)
+	if err := s.BuildHashIndex(); err != nil {
+		t.Fatal(err)
+	}
+	paths := func(links []Backlink) []string {
+		var ps []string
+		for _, l := range links {
+			ps = append(ps, l.Path)
+		}
+		return ps
+	}
+
+	// Relative links resolve against the linking document; queries,
+	// fragments, other hosts, code and self-links are not links here.
+	if got := s.Backlinks("/guide/install.md", nil); !slices.Equal(paths(got), []string{"/blog/post.md", "/guide/index.md", "/guide/setup.md"}) {
+		t.Errorf("install: %+v", got)
+	} else if got[0].Title != "Post" {
+		t.Errorf("title = %q", got[0].Title)
+	}
+	if got := paths(s.Backlinks("/guide/setup.md", nil)); !slices.Equal(got, []string{"/guide/index.md"}) {
+		t.Errorf("setup: %v", got)
+	}
+	// A link to a directory is a link to its index.
+	if got := paths(s.Backlinks("/guide/index.md", nil)); !slices.Equal(got, []string{"/guide/install.md", "/guide/setup.md"}) {
+		t.Errorf("index: %v", got)
+	}
+	if got := paths(s.Backlinks("/guide/install.md", func(p string) bool { return p != "/blog/post.md" })); len(got) != 2 {
+		t.Errorf("invisible document returned: %v", got)
+	}
+
+	// Writes, archives and moves keep the index current; a moved document
+	// keeps the links to its old path through the alias.
+	if _, err := s.Write("/blog/post.md", []byte("# Post\n\nNo links now.\n"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Archive("/guide/index.md", true); err != nil {
+		t.Fatal(err)
+	}
+	if got := paths(s.Backlinks("/guide/install.md", nil)); !slices.Equal(got, []string{"/guide/setup.md"}) {
+		t.Errorf("after write and archive: %v", got)
+	}
+	if _, err := s.Move("/guide/install.md", "/guide/installing.md", true); err != nil {
+		t.Fatal(err)
+	}
+	if got := paths(s.Backlinks("/guide/installing.md", nil)); !slices.Equal(got, []string{"/guide/setup.md"}) {
+		t.Errorf("after move: %v", got)
+	}
+}
+
+func TestStats(t *testing.T) {
+	s := New(t.TempDir())
+	if st := s.Stats(); st != (Stats{}) {
+		t.Errorf("empty store: %+v", st)
+	}
+	s.EnableJournal()
+	if _, err := s.Write("/a.md", []byte("# Alpha\n\nfirst words\n"), map[string]string{"aliases": "/old-a.md, /older-a.md"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Write("/b.md", []byte("# Beta\n\nsecond words\n"), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	st := s.Stats()
+	if st.HashEntries != 2 || st.Aliases != 2 || st.SearchDocuments != 2 || !st.Journal || st.JournalPending != 0 {
+		t.Errorf("Stats() = %+v", st)
+	}
+	if st.SearchTerms == 0 {
+		t.Error("no search terms counted")
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	root := t.TempDir()
+	s := New(root)
+	if _, err := s.Write("/doc.md", []byte("# Heading\n\nBody.\n"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Write("/titled.md", []byte("# Heading\n"), map[string]string{"title": "Given"}); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := s.Preload(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 || s.Stats().Described != 2 {
+		t.Errorf("preloaded %d, described %d, want 2", n, s.Stats().Described)
+	}
+
+	doc, err := s.Get("/doc.md", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := s.Describe("/doc.md", doc)
+	sum := sha256.Sum256(doc.Content)
+	if info.Etag != hex.EncodeToString(sum[:]) {
+		t.Errorf("etag = %q", info.Etag)
+	}
+	if info.ContentHash != contentHash([]byte("# Heading\n\nBody.\n")) || info.Size != len("# Heading\n\nBody.\n") {
+		t.Errorf("content-hash %q, size %d", info.ContentHash, info.Size)
+	}
+	if info.Title != "Heading" {
+		t.Errorf("title = %q, want Heading", info.Title)
+	}
+	titled, err := s.Get("/titled.md", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Describe("/titled.md", titled).Title; got != "Given" {
+		t.Errorf("title = %q, want the title metadata", got)
+	}
+
+	// A new version is described afresh.
+	if _, err := s.Write("/doc.md", []byte("# Changed\n"), nil); err != nil {
+		t.Fatal(err)
+	}
+	doc, err = s.Get("/doc.md", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Describe("/doc.md", doc).Title; got != "Changed" {
+		t.Errorf("title after write = %q, want Changed", got)
+	}
+
+	if _, err := s.Purge("/doc.md"); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Stats().Described; got != 1 {
+		t.Errorf("described after purge = %d, want 1", got)
+	}
+}
+
+func TestRecent(t *testing.T) {
+	s := New(t.TempDir())
+	for _, p := range []string{"/a.md", "/b.md", "/a.md", "/c.md"} {
+		if _, err := s.Write(p, []byte("# "+p+" "+strconv.Itoa(s.CurrentVersion(p))+"\n"), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := s.Recent(3, func(p string) bool { return p != "/c.md" })
+	if len(got) != 3 || got[0].Path != "/a.md" || got[0].Version != 2 || got[1].Path != "/b.md" || got[2].Version != 1 {
+		t.Fatalf("Recent = %+v", got)
+	}
+	if got[0].Title != "/a.md 1" || got[2].Title != "/a.md 1" {
+		t.Errorf("titles %q, %q: want the current title", got[0].Title, got[2].Title)
+	}
+
+	var l changeLog
+	for i := range FeedSize + 1 {
+		l.add(Change{Path: "/doc.md", Version: i + 1})
+	}
+	if len(l.changes) != FeedSize || l.changes[0].Version != 2 {
+		t.Errorf("log holds %d changes from v%d", len(l.changes), l.changes[0].Version)
+	}
+}
@@ -0,0 +1,90 @@
+// Package systemd implements the parts of the systemd service protocol the
+// server uses: socket activation (LISTEN_FDS) and readiness and watchdog
+// notification (sd_notify). Everything is a no-op when the process was not
+// started by systemd.
+package systemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// listenFDsStart is the first file descriptor passed by socket activation.
+const listenFDsStart = 3
+
+// PacketConns returns the datagram sockets passed by systemd socket
+// activation, or nil when the process was not socket-activated. The
+// activation variables are cleared so child processes do not inherit them.
+func PacketConns() ([]net.PacketConn, error) {
+	if !forThisProcess("LISTEN_PID") {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 1 {
+		return nil, nil
+	}
+	for _, key := range []string{"LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES"} {
+		_ = os.Unsetenv(key)
+	}
+
+	conns := make([]net.PacketConn, 0, n)
+	for fd := listenFDsStart; fd < listenFDsStart+n; fd++ {
+		f := os.NewFile(uintptr(fd), "systemd-socket-"+strconv.Itoa(fd))
+		c, err := net.FilePacketConn(f)
+		_ = f.Close() // FilePacketConn holds its own duplicate
+		if err != nil {
+			for _, open := range conns {
+				_ = open.Close()
+			}
+			return nil, fmt.Errorf("socket activation fd %d is not a datagram socket (use ListenDatagram= in the .socket unit): %w", fd, err)
+		}
+		conns = append(conns, c)
+	}
+	return conns, nil
+}
+
+// Notify sends state (e.g. "READY=1") to the service manager. It reports
+// false without error when NOTIFY_SOCKET is unset.
+func Notify(state string) (bool, error) {
+	name := os.Getenv("NOTIFY_SOCKET")
+	if name == "" {
+		return false, nil
+	}
+	// A leading @ denotes a socket in the Linux abstract namespace.
+	if strings.HasPrefix(name, "@") {
+		name = "\x00" + name[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: name, Net: "unixgram"})
+	if err != nil {
+		return false, fmt.Errorf("notify: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, fmt.Errorf("notify: %w", err)
+	}
+	return true, nil
+}
+
+// WatchdogInterval returns the watchdog timeout configured with
+// WatchdogSec=, or zero when the watchdog is disabled. Callers should send
+// "WATCHDOG=1" at about half this interval.
+func WatchdogInterval() time.Duration {
+	if os.Getenv("WATCHDOG_PID") != "" && !forThisProcess("WATCHDOG_PID") {
+		return 0
+	}
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// forThisProcess reports whether the PID in the named variable is ours.
+func forThisProcess(key string) bool {
+	pid, err := strconv.Atoi(os.Getenv(key))
+	return err == nil && pid == os.Getpid()
+}
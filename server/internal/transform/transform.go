@@ -0,0 +1,304 @@
+// Package transform rewrites markdown documents on their way out of a
+// FETCH. Operators enable transforms by name, in the order they should run;
+// the stored documents, and so their hashes and version history, are never
+// changed.
+package transform
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/latebit/demarkus/protocol"
+)
+
+// Transform rewrites the body of the document at docPath.
+type Transform struct {
+	// Key identifies the transform and its settings. Servers mix the keys
+	// of a pipeline into the etag of every transformed document, so that a
+	// configuration change invalidates cached copies.
+	Key   string
+	Apply func(docPath, body string) string
+}
+
+// Names of the built-in transforms, as configured.
+const (
+	NameEmoji        = "emoji"
+	NameAnchors      = "anchors"
+	NameRewriteLinks = "rewrite-links"
+)
+
+// New returns the pipeline of built-in transforms named in names, in order.
+// rewrites holds FROM=TO link prefixes for rewrite-links, which requires at
+// least one.
+func New(names, rewrites []string) ([]Transform, error) {
+	var pipeline []Transform
+	for _, name := range names {
+		switch name {
+		case NameEmoji:
+			pipeline = append(pipeline, Emoji())
+		case NameAnchors:
+			pipeline = append(pipeline, HeadingAnchors())
+		case NameRewriteLinks:
+			if len(rewrites) == 0 {
+				return nil, fmt.Errorf("%s needs at least one FROM=TO link prefix", name)
+			}
+			var pairs [][2]string
+			for _, r := range rewrites {
+				from, to, ok := strings.Cut(r, "=")
+				if !ok || from == "" || to == "" {
+					return nil, fmt.Errorf("%s: %q is not FROM=TO", name, r)
+				}
+				pairs = append(pairs, [2]string{from, to})
+			}
+			pipeline = append(pipeline, RewriteLinks(pairs))
+		default:
+			return nil, fmt.Errorf("unknown transform %q", name)
+		}
+	}
+	return pipeline, nil
+}
+
+// Key returns the combined key of a pipeline, or "" for none.
+func Key(pipeline []Transform) string {
+	keys := make([]string, len(pipeline))
+	for i, t := range pipeline {
+		keys[i] = t.Key
+	}
+	return strings.Join(keys, ",")
+}
+
+// Apply runs body through each transform of pipeline in turn.
+func Apply(pipeline []Transform, docPath, body string) string {
+	for _, t := range pipeline {
+		body = t.Apply(docPath, body)
+	}
+	return body
+}
+
+// mapLines calls fn on each line of body outside code blocks, including
+// its line ending, and returns the result. Fenced code blocks, and
+// indented ones after a blank line, are left untouched.
+func mapLines(body string, fn func(line string) string) string {
+	var b strings.Builder
+	var fence string
+	blank, indented := true, false
+	for line := range strings.SplitAfterSeq(body, "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		isIndented := strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t")
+		switch {
+		case fence != "":
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+		case strings.HasPrefix(trimmed, "```"), strings.HasPrefix(trimmed, "~~~"):
+			fence = trimmed[:3]
+		case isIndented && (blank || indented):
+			indented = true
+		default:
+			indented = false
+			line = fn(line)
+		}
+		b.WriteString(line)
+		blank = strings.TrimSpace(line) == ""
+		if blank {
+			indented = false
+		}
+	}
+	return b.String()
+}
+
+// mapText calls fn on the prose of body and returns the result: code
+// blocks and code spans are left untouched. fn also gets whether the text
+// starts a line.
+func mapText(body string, fn func(text string, lineStart bool) string) string {
+	return mapLines(body, func(line string) string {
+		var b strings.Builder
+		// Odd segments between backticks are code spans.
+		for i, segment := range strings.Split(line, "`") {
+			if i > 0 {
+				b.WriteByte('`')
+			}
+			if i%2 == 1 {
+				b.WriteString(segment)
+			} else {
+				b.WriteString(fn(segment, i == 0))
+			}
+		}
+		return b.String()
+	})
+}
+
+// emojiShortcodes are the shortcodes Emoji replaces, a common subset of
+// those GitHub and Slack understand.
+var emojiShortcodes = map[string]string{
+	"+1":               "👍",
+	"-1":               "👎",
+	"bug":              "🐛",
+	"bulb":             "💡",
+	"check":            "✔️",
+	"construction":     "🚧",
+	"eyes":             "👀",
+	"fire":             "🔥",
+	"heart":            "❤️",
+	"hourglass":        "⌛",
+	"info":             "ℹ️",
+	"link":             "🔗",
+	"lock":             "🔒",
+	"memo":             "📝",
+	"question":         "❓",
+	"rocket":           "🚀",
+	"smile":            "😄",
+	"sparkles":         "✨",
+	"star":             "⭐",
+	"tada":             "🎉",
+	"thumbsdown":       "👎",
+	"thumbsup":         "👍",
+	"warning":          "⚠️",
+	"white_check_mark": "✅",
+	"x":                "❌",
+	"zap":              "⚡",
+}
+
+// shortcodePattern matches :name: shortcodes.
+var shortcodePattern = regexp.MustCompile(`:[a-z0-9_+-]+:`)
+
+// Emoji replaces known :shortcode: emoji with the characters they name.
+// Unknown shortcodes, and those in code, are left as they are.
+func Emoji() Transform {
+	return Transform{
+		Key: NameEmoji,
+		Apply: func(_, body string) string {
+			return mapText(body, func(text string, _ bool) string {
+				return shortcodePattern.ReplaceAllStringFunc(text, func(code string) string {
+					if emoji, ok := emojiShortcodes[strings.Trim(code, ":")]; ok {
+						return emoji
+					}
+					return code
+				})
+			})
+		},
+	}
+}
+
+// inlineLinkPattern matches an inline link, capturing its text.
+var inlineLinkPattern = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+
+// HeadingAnchors ends each ATX heading with a link to its own anchor, such
+// as "## Getting started [¶](#getting-started)", so readers can copy a link
+// to a section. Anchors are GitHub-style slugs of the heading text, links
+// reduced to theirs, numbered from -1 when a heading repeats.
+func HeadingAnchors() Transform {
+	return Transform{
+		Key: NameAnchors,
+		Apply: func(_, body string) string {
+			seen := make(map[string]int)
+			return mapLines(body, func(text string) string {
+				level := len(text) - len(strings.TrimLeft(text, "#"))
+				if level == 0 || level > 6 || !strings.HasPrefix(text[level:], " ") {
+					return text
+				}
+				content := strings.TrimRight(text, "\r\n")
+				title := inlineLinkPattern.ReplaceAllString(strings.TrimSpace(content[level:]), "$1")
+				slug := Slug(title)
+				if slug == "" {
+					return text
+				}
+				if n := seen[slug]; n > 0 {
+					seen[slug] = n + 1
+					slug += "-" + strconv.Itoa(n)
+				} else {
+					seen[slug] = 1
+				}
+				return strings.TrimRight(content, " ") + " [¶](#" + slug + ")" + text[len(content):]
+			})
+		},
+	}
+}
+
+// RelinkHost rewrites the absolute mark:// URLs in body that name the host
+// from so that they name to instead, for a site that changes domain or
+// port. Hosts match ignoring case, and a host without a port matches it
+// with the default one. Unlike RewriteLinks, it also rewrites URLs that are
+// only mentioned in the text; those in code are left alone.
+func RelinkHost(body, from, to string) string {
+	from = canonicalHost(from)
+	return mapText(body, func(text string, _ bool) string {
+		var b strings.Builder
+		for {
+			i := strings.Index(text, markScheme)
+			if i < 0 {
+				break
+			}
+			start := i + len(markScheme)
+			end := start + strings.IndexFunc(text[start:]+" ", endsHost)
+			b.WriteString(text[:start])
+			if host := text[start:end]; canonicalHost(host) == from {
+				b.WriteString(to)
+			} else {
+				b.WriteString(host)
+			}
+			text = text[end:]
+		}
+		b.WriteString(text)
+		return b.String()
+	})
+}
+
+const markScheme = "mark://"
+
+// endsHost reports whether r ends the host of a URL in markdown text.
+func endsHost(r rune) bool {
+	return strings.ContainsRune("/?#)]>\"' \t\r\n", r)
+}
+
+// canonicalHost returns host in lowercase without the default port.
+func canonicalHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ":"+strconv.Itoa(protocol.DefaultPort))
+}
+
+// Slug returns the GitHub-style anchor for a heading: lowercase letters,
+// digits, hyphens and underscores, with spaces turned into hyphens.
+func Slug(heading string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(heading) {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r), r == '-', r == '_':
+			b.WriteRune(r)
+		case r == ' ':
+			b.WriteByte('-')
+		}
+	}
+	return b.String()
+}
+
+// RewriteLinks replaces the FROM prefix of link destinations with TO, for
+// each pair in order, so that a mirror can serve documents whose absolute
+// links point at the original host. Inline links, autolinks and reference
+// definitions are rewritten; text that only mentions an address is not.
+func RewriteLinks(pairs [][2]string) Transform {
+	keys := make([]string, len(pairs))
+	for i, p := range pairs {
+		keys[i] = p[0] + "=" + p[1]
+	}
+	return Transform{
+		Key: NameRewriteLinks + "(" + strings.Join(keys, ";") + ")",
+		Apply: func(_, body string) string {
+			return mapText(body, func(text string, lineStart bool) string {
+				for _, p := range pairs {
+					from, to := p[0], p[1]
+					text = strings.ReplaceAll(text, "]("+from, "]("+to)
+					text = strings.ReplaceAll(text, "<"+from, "<"+to)
+					if lineStart {
+						if label, dest, ok := strings.Cut(text, "]: "+from); ok && strings.HasPrefix(strings.TrimLeft(label, " "), "[") {
+							text = label + "]: " + to + dest
+						}
+					}
+				}
+				return text
+			})
+		},
+	}
+}
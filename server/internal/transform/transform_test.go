@@ -0,0 +1,98 @@
+package transform
+
+import (
+	"testing"
+)
+
+func TestEmoji(t *testing.T) {
+	body := "# Release :tada:\n\nShipped :rocket: with :unknown: codes.\n\nRun `echo :fire:` or:\n\n```\n:fire:\n```\n\n    :fire:\n"
+	want := "# Release 🎉\n\nShipped 🚀 with :unknown: codes.\n\nRun `echo :fire:` or:\n\n```\n:fire:\n```\n\n    :fire:\n"
+	if got := Emoji().Apply("/a.md", body); got != want {
+		t.Errorf("Emoji:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestHeadingAnchors(t *testing.T) {
+	body := "# Getting Started\n\n## Install `demarkus`\n\nText with a # sign.\n\n## Getting started\r\n\n```\n# not a heading\n```\n#hashtag\n"
+	want := "# Getting Started [¶](#getting-started)\n\n## Install `demarkus` [¶](#install-demarkus)\n\nText with a # sign.\n\n## Getting started [¶](#getting-started-1)\r\n\n```\n# not a heading\n```\n#hashtag\n"
+	if got := HeadingAnchors().Apply("/a.md", body); got != want {
+		t.Errorf("HeadingAnchors:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestSlug(t *testing.T) {
+	tests := map[string]string{
+		"Getting Started":     "getting-started",
+		"What's new in v1.2?": "whats-new-in-v12",
+		"snake_case & dashes": "snake_case--dashes",
+		"Café":                "café",
+	}
+	for heading, want := range tests {
+		if got := Slug(heading); got != want {
+			t.Errorf("Slug(%q) = %q, want %q", heading, got, want)
+		}
+	}
+}
+
+func TestRewriteLinks(t *testing.T) {
+	rewrite := RewriteLinks([][2]string{{"mark://docs.example.com/", "mark://mirror.example.org/"}})
+	body := "See [the guide](mark://docs.example.com/guide.md) and <mark://docs.example.com/faq.md>.\n\n" +
+		"The original is mark://docs.example.com/ itself. Run `curl mark://docs.example.com/x`.\n\n" +
+		"[ref]: mark://docs.example.com/ref.md\n"
+	want := "See [the guide](mark://mirror.example.org/guide.md) and <mark://mirror.example.org/faq.md>.\n\n" +
+		"The original is mark://docs.example.com/ itself. Run `curl mark://docs.example.com/x`.\n\n" +
+		"[ref]: mark://mirror.example.org/ref.md\n"
+	if got := rewrite.Apply("/a.md", body); got != want {
+		t.Errorf("RewriteLinks:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestRelinkHost(t *testing.T) {
+	body := "See [guide](mark://Old.example:6309/guide.md), <mark://old.example/a.md#top>,\n" +
+		"mark://old.example and mark://old.example.com/x.md.\n\n" +
+		"[ref]: mark://old.example/ref.md\n\n" +
+		"`mark://old.example/code.md`\n\n```\nmark://old.example/fenced.md\n```\n"
+	want := "See [guide](mark://new.example:7000/guide.md), <mark://new.example:7000/a.md#top>,\n" +
+		"mark://new.example:7000 and mark://old.example.com/x.md.\n\n" +
+		"[ref]: mark://new.example:7000/ref.md\n\n" +
+		"`mark://old.example/code.md`\n\n```\nmark://old.example/fenced.md\n```\n"
+	if got := RelinkHost(body, "old.example", "new.example:7000"); got != want {
+		t.Errorf("RelinkHost:\n got %q\nwant %q", got, want)
+	}
+	if got := RelinkHost(body, "other.example", "new.example"); got != body {
+		t.Errorf("RelinkHost changed links to another host:\n%q", got)
+	}
+}
+
+func TestNew(t *testing.T) {
+	pipeline, err := New([]string{"anchors", "emoji", "rewrite-links"}, []string{"mark://a/=mark://b/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Key(pipeline); got != "anchors,emoji,rewrite-links(mark://a/=mark://b/)" {
+		t.Errorf("Key = %q", got)
+	}
+	// Transforms run in the configured order: anchors sees the shortcode.
+	if got := Apply(pipeline, "/a.md", "# Hi :wave: [x](mark://a/x.md)\n"); got != "# Hi :wave: [x](mark://b/x.md) [¶](#hi-wave-x)\n" {
+		t.Errorf("Apply = %q", got)
+	}
+	if got := Apply(pipeline, "/a.md", "## Done :tada:\n"); got != "## Done 🎉 [¶](#done-tada)\n" {
+		t.Errorf("Apply = %q", got)
+	}
+	if pipeline, err := New(nil, nil); err != nil || len(pipeline) != 0 || Key(pipeline) != "" {
+		t.Errorf("New(nil) = %v, %v", pipeline, err)
+	}
+
+	for _, tt := range []struct {
+		names, rewrites []string
+	}{
+		{[]string{"smileys"}, nil},
+		{[]string{"rewrite-links"}, nil},
+		{[]string{"rewrite-links"}, []string{"mark://a/"}},
+		{[]string{"rewrite-links"}, []string{"=mark://b/"}},
+	} {
+		if _, err := New(tt.names, tt.rewrites); err == nil {
+			t.Errorf("New(%q, %q) succeeded", tt.names, tt.rewrites)
+		}
+	}
+}